@@ -1,8 +1,11 @@
+//go:build linux
+
 package main
 
 import (
 	"os"
 	"path/filepath"
+
 	"vistara-node/internal/hypercore"
 	"vistara-node/pkg/shim"
 )
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "vistara-node/internal/hypercore"
+
+// Only the CLI is built for other systems, the shim runs the VMs of Linux
+// nodes
+func main() {
+	hypercore.Run()
+}
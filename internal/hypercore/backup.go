@@ -0,0 +1,96 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"os"
+
+	"vistara-node/pkg/cluster"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func ClusterBackupCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "archive the state of the cluster agent of this node",
+		Long: `Archives the billing records and revision history the cluster agent keeps
+in ` + defaults.DataRootDir + `, along with the specs of the workloads of this node,
+into a gzipped tarball that hypercore cluster restore restores onto a
+replacement node. The agent can keep running`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerCluster))
+			if err != nil {
+				return err
+			}
+
+			output, err := os.OpenFile(cfg.ClusterBackup.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			defer output.Close()
+
+			workloads, err := cluster.WriteBackup(cmd.Context(), repo, defaults.DataRootDir, output)
+			if err != nil {
+				return err
+			}
+
+			if err := output.Close(); err != nil {
+				return err
+			}
+
+			log.Infof("Backed up the agent state and %d workloads to %s", workloads, cfg.ClusterBackup.Output)
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddClusterBackupFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterRestoreCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore ARCHIVE",
+		Short: "restore the state of a cluster agent backup onto this node",
+		Long: `Restores a backup of hypercore cluster backup into ` + defaults.DataRootDir + `,
+with the cluster agent stopped. The agent spawns the workloads of the
+backup on this node the next time it starts`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			archive, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			workloads, err := cluster.RestoreBackup(archive, defaults.DataRootDir, cfg.ClusterRestore.Force)
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Restored the agent state, %d workloads will be spawned once the cluster agent starts", workloads)
+
+			return nil
+		},
+	}
+
+	AddClusterRestoreFlags(cmd, cfg)
+
+	return cmd
+}
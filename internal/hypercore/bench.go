@@ -0,0 +1,237 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/cloudhypervisor"
+	"vistara-node/pkg/hypervisor/firecracker"
+	pb "vistara-node/pkg/proto/shimdebug"
+
+	toml "github.com/pelletier/go-toml/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// BootBenchReport is the outcome of a boot benchmark, written as JSON to
+// compare releases
+type BootBenchReport struct {
+	Provider string    `json:"provider"`
+	Started  time.Time `json:"started"`
+	Cycles   int       `json:"cycles"`
+	// VMs restored from a warm pool snapshot rather than booted
+	Restored int `json:"restored"`
+	// From the creation of the task to the agent handshake, to the first
+	// start of the task as measured by the shim, and until the task was
+	// started as seen by the client
+	Agent Percentiles `json:"agent"`
+	Start Percentiles `json:"start"`
+	Total Percentiles `json:"total"`
+}
+
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// percentiles returns the nearest-rank percentiles of the samples
+func percentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+
+	sorted := slices.Sorted(slices.Values(samples))
+	rank := func(p int) time.Duration {
+		return sorted[max((p*len(sorted)+99)/100-1, 0)]
+	}
+
+	return Percentiles{P50: rank(50), P95: rank(95), P99: rank(99), Max: sorted[len(sorted)-1]}
+}
+
+func BenchCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "benchmark the local hypercore components",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return c.Help()
+		},
+	}
+
+	cmd.AddCommand(BenchBootCommand(cfg))
+	cmd.AddCommand(BenchIOCommand(cfg))
+
+	return cmd
+}
+
+func BenchBootCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "boot",
+		Short: "boot VMs of the HAC file one after the other and report the percentiles of their boot times",
+		Long: `Creates and deletes a VM of the HAC file per cycle, after unmeasured warm up
+cycles pulling the image. The shim of each VM reports the time from the
+creation of its task to the agent handshake and to the start of the task,
+the client the time until the task was started`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			provider := cfg.DefaultVMProvider
+			if provider != firecracker.HypervisorName && provider != cloudhypervisor.HypervisorName {
+				return fmt.Errorf("boot benchmarks are only supported for %s and %s VMs", firecracker.HypervisorName, cloudhypervisor.HypervisorName)
+			}
+
+			if cfg.BenchBoot.Cycles < 1 {
+				return fmt.Errorf("--%s must be at least 1", cyclesFlag)
+			}
+
+			hacPath, err := filepath.Abs(cfg.HACFile)
+			if err != nil {
+				return err
+			}
+
+			hacContents, err := os.ReadFile(hacPath)
+			if err != nil {
+				return err
+			}
+
+			var hacConfig HacConfig
+			if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+				return err
+			}
+
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			report := BootBenchReport{Provider: provider, Started: time.Now(), Cycles: cfg.BenchBoot.Cycles}
+
+			var agent, start, total []time.Duration
+
+			for cycle := -cfg.BenchBoot.Warmup; cycle < cfg.BenchBoot.Cycles; cycle++ {
+				sample, err := bootCycle(cmd.Context(), repo, provider, &hacConfig, int(cfg.StdioBufferSize))
+				if err != nil && cycle < 0 {
+					return fmt.Errorf("warm up boot failed: %w", err)
+				} else if err != nil {
+					return fmt.Errorf("boot cycle %d failed: %w", cycle+1, err)
+				}
+
+				if cycle < 0 {
+					log.Infof("Warm up boot in %s", sample.total)
+
+					continue
+				}
+
+				log.Infof("Boot %d/%d: agent %s, start %s, total %s, restored %t", cycle+1, cfg.BenchBoot.Cycles,
+					sample.agent, sample.start, sample.total, sample.restored)
+
+				agent = append(agent, sample.agent)
+				start = append(start, sample.start)
+				total = append(total, sample.total)
+
+				if sample.restored {
+					report.Restored++
+				}
+			}
+
+			report.Agent = percentiles(agent)
+			report.Start = percentiles(start)
+			report.Total = percentiles(total)
+
+			for _, stage := range []struct {
+				name        string
+				percentiles Percentiles
+			}{{"agent", report.Agent}, {"start", report.Start}, {"total", report.Total}} {
+				log.Infof("%s %s: p50 %s, p95 %s, p99 %s, max %s", provider, stage.name,
+					stage.percentiles.P50, stage.percentiles.P95, stage.percentiles.P99, stage.percentiles.Max)
+			}
+
+			if report.Restored > 0 {
+				log.Warnf("%d of the %d VMs were restored from the warm pool", report.Restored, report.Cycles)
+			}
+
+			if cfg.BenchBoot.Output == "" {
+				return nil
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			return os.WriteFile(cfg.BenchBoot.Output, data, defaults.DataFilePerm)
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddBenchBootFlags(cmd, cfg)
+
+	return cmd
+}
+
+type bootSample struct {
+	agent    time.Duration
+	start    time.Duration
+	total    time.Duration
+	restored bool
+}
+
+// bootCycle creates a VM, reads the boot times recorded by its shim and
+// deletes it
+func bootCycle(ctx context.Context, repo *containerd.Repo, provider string, hacConfig *HacConfig, bufferSize int) (bootSample, error) {
+	began := time.Now()
+
+	id, err := repo.CreateContainer(ctx, containerd.CreateContainerOpts{
+		ImageRef:    hacConfig.Hardware.Ref,
+		Snapshotter: "devmapper",
+		Runtime: struct {
+			Name    string
+			Options interface{}
+		}{
+			Name:    "hypercore.example",
+			Options: hacVMSpec(provider, hacConfig),
+		},
+		CioCreator: containerd.BufferedIO(bufferSize),
+	})
+	if err != nil {
+		return bootSample{}, err
+	}
+
+	sample := bootSample{total: time.Since(began)}
+
+	defer func() {
+		if _, err := repo.DeleteContainer(context.Background(), id, 0); err != nil {
+			log.WithError(err).Errorf("failed to delete benchmark microVM %s", id)
+		}
+	}()
+
+	conn, err := dialShim(id)
+	if err != nil {
+		return bootSample{}, err
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewShimDebugServiceClient(conn).Inspect(ctx, &pb.InspectRequest{})
+	if err != nil {
+		return bootSample{}, fmt.Errorf("failed to inspect shim: %w", err)
+	}
+
+	sample.agent = time.Duration(resp.GetBootAgentMs()) * time.Millisecond
+	sample.start = time.Duration(resp.GetBootStartMs()) * time.Millisecond
+	sample.restored = resp.GetVm().GetRestored()
+
+	return sample, nil
+}
@@ -0,0 +1,329 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/shimdebug"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Size of the block repeated as the stdin of the throughput benchmark
+const ioBenchBlockSize = 1 << 20
+
+// IOBenchReport is the outcome of an IO benchmark, written as JSON to
+// compare releases
+type IOBenchReport struct {
+	Task    string    `json:"task"`
+	Started time.Time `json:"started"`
+	// Throughput of the stdin of a process in the guest, and of its stdout
+	StdinBytes     int64   `json:"stdin_bytes"`
+	StdinMBPerSec  float64 `json:"stdin_mb_per_sec"`
+	StdoutBytes    int64   `json:"stdout_bytes"`
+	StdoutMBPerSec float64 `json:"stdout_mb_per_sec"`
+	// Round trips of messages echoed by a process in the guest
+	Pings    int         `json:"pings"`
+	PingSize int         `json:"ping_size"`
+	Latency  Percentiles `json:"latency"`
+}
+
+func BenchIOCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "io TASK-ID",
+		Short: "measure the throughput and latency of the stdin and stdout of processes in the VM of a task",
+		Long: `Execs processes in the running VM of the task, whose image needs sh, cat and
+/dev/zero: stdin is written to cat > /dev/null and stdout read from
+cat /dev/zero for the duration each, then messages are echoed one at a
+time by cat. The IO of the processes goes through the IO proxy of the shim
+and the vsock of the VM, --profile captures CPU profiles of the shim and
+of this command during the transfers`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := cfg.BenchIO
+			if opts.Duration < time.Second {
+				return fmt.Errorf("--%s must be at least 1s", durationFlag)
+			}
+
+			if opts.PingSize < 1 || opts.Pings < 1 {
+				return fmt.Errorf("--%s and --%s must be at least 1", pingsFlag, pingSizeFlag)
+			}
+
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			bench := &ioBench{repo: repo, taskID: args[0], duration: opts.Duration, profileDir: opts.ProfileDir}
+			report := IOBenchReport{Task: args[0], Started: time.Now(), Pings: opts.Pings, PingSize: opts.PingSize}
+
+			if bench.profileDir != "" {
+				if err := os.MkdirAll(bench.profileDir, defaults.DataDirPerm); err != nil {
+					return err
+				}
+			}
+
+			report.StdinBytes, report.StdinMBPerSec, err = bench.profiled(cmd.Context(), "stdin", bench.stdin)
+			if err != nil {
+				return fmt.Errorf("stdin benchmark failed: %w", err)
+			}
+
+			log.Infof("stdin: %d bytes, %.1f MB/s", report.StdinBytes, report.StdinMBPerSec)
+
+			report.StdoutBytes, report.StdoutMBPerSec, err = bench.profiled(cmd.Context(), "stdout", bench.stdout)
+			if err != nil {
+				return fmt.Errorf("stdout benchmark failed: %w", err)
+			}
+
+			log.Infof("stdout: %d bytes, %.1f MB/s", report.StdoutBytes, report.StdoutMBPerSec)
+
+			report.Latency, err = bench.pings(cmd.Context(), opts.Pings, opts.PingSize)
+			if err != nil {
+				return fmt.Errorf("latency benchmark failed: %w", err)
+			}
+
+			log.Infof("round trips of %d bytes: p50 %s, p95 %s, p99 %s, max %s",
+				opts.PingSize, report.Latency.P50, report.Latency.P95, report.Latency.P99, report.Latency.Max)
+
+			if opts.Output == "" {
+				return nil
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			return os.WriteFile(opts.Output, data, defaults.DataFilePerm)
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddBenchIOFlags(cmd, cfg)
+
+	return cmd
+}
+
+type ioBench struct {
+	repo       *containerd.Repo
+	taskID     string
+	duration   time.Duration
+	profileDir string
+}
+
+// profiled runs a throughput benchmark, returning the bytes transferred
+// and the throughput in MB/s. With a profile directory the shim and this
+// process are profiled meanwhile
+func (b *ioBench) profiled(ctx context.Context, name string, run func(ctx context.Context) (int64, time.Duration, error)) (int64, float64, error) {
+	if b.profileDir == "" {
+		transferred, elapsed, err := run(ctx)
+
+		return transferred, float64(transferred) / 1e6 / elapsed.Seconds(), err
+	}
+
+	conn, err := dialShim(b.taskID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	type profileResult struct {
+		resp *pb.ProfileResponse
+		err  error
+	}
+
+	shimProfile := make(chan profileResult, 1)
+
+	go func() {
+		resp, err := pb.NewShimDebugServiceClient(conn).Profile(ctx, &pb.ProfileRequest{
+			Kind:            "cpu",
+			DurationSeconds: int64(b.duration / time.Second),
+		})
+		shimProfile <- profileResult{resp, err}
+	}()
+
+	var clientProfile bytes.Buffer
+	if err := pprof.StartCPUProfile(&clientProfile); err != nil {
+		return 0, 0, err
+	}
+
+	transferred, elapsed, err := run(ctx)
+
+	pprof.StopCPUProfile()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result := <-shimProfile
+	if result.err != nil {
+		return 0, 0, fmt.Errorf("failed to profile shim: %w", result.err)
+	}
+
+	for file, profile := range map[string][]byte{
+		"shim-" + name + ".pprof":   result.resp.GetProfile(),
+		"client-" + name + ".pprof": clientProfile.Bytes(),
+	} {
+		if err := os.WriteFile(filepath.Join(b.profileDir, file), profile, defaults.DataFilePerm); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return transferred, float64(transferred) / 1e6 / elapsed.Seconds(), nil
+}
+
+// stdin writes to a process discarding its stdin until the duration
+// elapsed, returning once the process read all of it
+func (b *ioBench) stdin(ctx context.Context) (int64, time.Duration, error) {
+	block := make([]byte, ioBenchBlockSize)
+	if _, err := rand.Read(block); err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	stdin := &timedReader{block: block, deadline: start.Add(b.duration)}
+
+	code, err := b.repo.ExecStreams(ctx, b.taskID, []string{"sh", "-c", "cat > /dev/null"}, stdin, nil, io.Discard)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if code != 0 {
+		return 0, 0, fmt.Errorf("cat exited with status %d", code)
+	}
+
+	return stdin.read, time.Since(start), nil
+}
+
+// stdout reads the output of a process writing zeroes for the duration
+func (b *ioBench) stdout(ctx context.Context) (int64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.duration)
+	defer cancel()
+
+	stdout := &countingWriter{}
+	start := time.Now()
+
+	_, err := b.repo.ExecStreams(ctx, b.taskID, []string{"cat", "/dev/zero"}, nil, stdout, io.Discard)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		if err == nil {
+			err = errors.New("cat exited before the end of the benchmark")
+		}
+
+		return 0, 0, err
+	}
+
+	return stdout.written.Load(), time.Since(start), nil
+}
+
+// pings sends messages to a process echoing them one at a time, returning
+// the percentiles of their round trips
+func (b *ioBench) pings(ctx context.Context, count, size int) (Percentiles, error) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	done := make(chan error, 1)
+
+	go func() {
+		code, err := b.repo.ExecStreams(ctx, b.taskID, []string{"cat"}, stdinReader, stdoutWriter, io.Discard)
+		if err == nil && code != 0 {
+			err = fmt.Errorf("cat exited with status %d", code)
+		}
+
+		// Unblocks the pings if the process is gone
+		_ = stdinReader.CloseWithError(err)
+		_ = stdoutWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	// Newline terminated so the messages also come back from a terminal
+	message := append(bytes.Repeat([]byte("x"), size-1), '\n')
+	echo := make([]byte, size)
+	samples := make([]time.Duration, 0, count)
+
+	for range count {
+		start := time.Now()
+
+		if _, err := stdinWriter.Write(message); err != nil {
+			return Percentiles{}, pingError(err, done)
+		}
+
+		if _, err := io.ReadFull(stdoutReader, echo); err != nil {
+			return Percentiles{}, pingError(err, done)
+		}
+
+		samples = append(samples, time.Since(start))
+	}
+
+	_ = stdinWriter.Close()
+
+	if err := <-done; err != nil {
+		return Percentiles{}, err
+	}
+
+	return percentiles(samples), nil
+}
+
+// pingError returns the error of the echoing process if it is the reason
+// the pings failed
+func pingError(err error, done <-chan error) error {
+	select {
+	case execErr := <-done:
+		if execErr != nil {
+			return execErr
+		}
+
+		return fmt.Errorf("cat exited: %w", err)
+	case <-time.After(time.Second):
+		return err
+	}
+}
+
+// timedReader repeats a block until its deadline
+type timedReader struct {
+	block    []byte
+	deadline time.Time
+	offset   int
+	read     int64
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	if time.Now().After(r.deadline) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.block[r.offset:])
+	r.offset = (r.offset + n) % len(r.block)
+	r.read += int64(n)
+
+	return n, nil
+}
+
+type countingWriter struct {
+	written atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.written.Add(int64(len(p)))
+
+	return len(p), nil
+}
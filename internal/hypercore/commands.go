@@ -1,376 +1,1597 @@
 package hypercore
 
 import (
-	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"net"
+	"io"
+	"maps"
 	"os"
-	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
-	"vistara-node/pkg/cluster"
+	"time"
 
-	"google.golang.org/grpc"
+	"vistara-node/pkg/client"
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func ClusterSpawnCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "spawn",
+		Short: "spawn a VM in a cluster",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ports := map[uint32]uint32{}
+			for _, portMap := range strings.Split(cfg.ClusterSpawn.Ports, ",") {
+				hostToContainer := strings.Split(portMap, ":")
+				if len(hostToContainer) != 2 {
+					return fmt.Errorf("invalid port mapping: %s", portMap)
+				}
+
+				hostPort, err := strconv.Atoi(hostToContainer[0])
+				if err != nil {
+					return err
+				}
+
+				containerPort, err := strconv.Atoi(hostToContainer[1])
+				if err != nil {
+					return err
+				}
+
+				if _, ok := ports[uint32(hostPort)]; ok {
+					return fmt.Errorf("host port %d is mapped more than once", hostPort)
+				}
+
+				ports[uint32(hostPort)] = uint32(containerPort)
+			}
+
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var horizontalScaling *pb.HorizontalScalingPolicy
+			if cfg.ClusterSpawn.MaxReplicas > 0 {
+				horizontalScaling = &pb.HorizontalScalingPolicy{
+					MinReplicas:     uint32(max(cfg.ClusterSpawn.Replicas, 1)),
+					MaxReplicas:     uint32(cfg.ClusterSpawn.MaxReplicas),
+					TargetRps:       cfg.ClusterSpawn.TargetRPS,
+					TargetLatencyMs: uint32(cfg.ClusterSpawn.TargetLatency),
+					PrometheusQuery: cfg.ClusterSpawn.PrometheusQuery,
+				}
+			}
+
+			var verticalScaling *pb.VerticalScalingPolicy
+			if cfg.ClusterSpawn.MaxCPU > cfg.ClusterSpawn.CPU || cfg.ClusterSpawn.MaxMemory > cfg.ClusterSpawn.Memory {
+				verticalScaling = &pb.VerticalScalingPolicy{
+					MinCores:  uint32(cfg.ClusterSpawn.CPU),
+					MaxCores:  uint32(max(cfg.ClusterSpawn.MaxCPU, cfg.ClusterSpawn.CPU)),
+					MinMemory: uint32(cfg.ClusterSpawn.Memory),
+					MaxMemory: uint32(max(cfg.ClusterSpawn.MaxMemory, cfg.ClusterSpawn.Memory)),
+				}
+			}
+
+			env, err := parseEnv(cfg.ClusterSpawn.Env)
+			if err != nil {
+				return err
+			}
+
+			labels, err := parseKeyValues("label", cfg.ClusterSpawn.Labels)
+			if err != nil {
+				return err
+			}
+
+			annotations, err := parseKeyValues("annotation", cfg.ClusterSpawn.Annotations)
+			if err != nil {
+				return err
+			}
+
+			readinessProbe, err := parseProbe(cfg.ClusterSpawn.ReadinessProbe)
+			if err != nil {
+				return err
+			}
+
+			livenessProbe, err := parseProbe(cfg.ClusterSpawn.LivenessProbe)
+			if err != nil {
+				return err
+			}
+
+			initSteps, err := parseInitSteps(cfg.ClusterSpawn.InitSteps, cfg.ClusterSpawn.InitTimeout)
+			if err != nil {
+				return err
+			}
+
+			containers, err := parsePodContainers(cfg.ClusterSpawn.Containers)
+			if err != nil {
+				return err
+			}
+
+			ingressRules, err := parseIngressRules(cfg.ClusterSpawn.Ingress)
+			if err != nil {
+				return err
+			}
+
+			extraHosts, err := parseExtraHosts(cfg.ClusterSpawn.ExtraHosts)
+			if err != nil {
+				return err
+			}
+
+			volumes, err := parseVolumes(cfg.ClusterSpawn.Volumes, cfg.ClusterSpawn.VolumeSnapshots)
+			if err != nil {
+				return err
+			}
+
+			var metricsEndpoint *pb.MetricsEndpoint
+			if cfg.ClusterSpawn.MetricsEndpoint != "" {
+				port, path, _ := strings.Cut(cfg.ClusterSpawn.MetricsEndpoint, "/")
+
+				value, err := strconv.ParseUint(port, 10, 16)
+				if err != nil {
+					return fmt.Errorf("invalid metrics endpoint %q, expected PORT or PORT/PATH", cfg.ClusterSpawn.MetricsEndpoint)
+				}
+
+				metricsEndpoint = &pb.MetricsEndpoint{Port: uint32(value)}
+				if path != "" {
+					metricsEndpoint.Path = "/" + path
+				}
+			}
+
+			restartPolicy, ok := pb.RestartPolicy_value[strings.ToUpper(strings.ReplaceAll(cfg.ClusterSpawn.RestartPolicy, "-", "_"))]
+			if !ok {
+				return fmt.Errorf("invalid restart policy %q", cfg.ClusterSpawn.RestartPolicy)
+			}
+
+			sessionAffinity, ok := pb.SessionAffinity_value["AFFINITY_"+strings.ToUpper(strings.ReplaceAll(cfg.ClusterSpawn.SessionAffinity, "-", "_"))]
+			if !ok {
+				return fmt.Errorf("invalid session affinity %q", cfg.ClusterSpawn.SessionAffinity)
+			}
+
+			req := &pb.VmSpawnRequest{
+				Cores:                  uint32(cfg.ClusterSpawn.CPU),
+				Memory:                 uint32(cfg.ClusterSpawn.Memory),
+				MemoryHigh:             uint32(cfg.ClusterSpawn.MemoryHigh),
+				ImageRef:               cfg.ClusterSpawn.ImageRef,
+				Ports:                  ports,
+				VerticalScaling:        verticalScaling,
+				HorizontalScaling:      horizontalScaling,
+				Name:                   cfg.ClusterSpawn.Name,
+				Labels:                 labels,
+				Annotations:            annotations,
+				IngressRules:           ingressRules,
+				MetricsEndpoint:        metricsEndpoint,
+				AccessLog:              cfg.ClusterSpawn.AccessLog,
+				SessionAffinity:        pb.SessionAffinity(sessionAffinity),
+				Tenant:                 cfg.ClusterSpawn.Tenant,
+				IdempotencyKey:         cfg.ClusterSpawn.IdempotencyKey,
+				Env:                    env,
+				ReadinessProbe:         readinessProbe,
+				LivenessProbe:          livenessProbe,
+				RestartPolicy:          pb.RestartPolicy(restartPolicy),
+				StopGracePeriodSeconds: uint32(cfg.ClusterSpawn.StopGracePeriod.Seconds()),
+				PreStopCommand:         strings.Fields(cfg.ClusterSpawn.PreStop),
+				InitSteps:              initSteps,
+				Containers:             containers,
+				ExtraHosts:             extraHosts,
+				Volumes:                volumes,
+				PendingTtlSeconds:      uint32(cfg.ClusterSpawn.PendingTTL.Seconds()),
+			}
+
+			if len(cfg.ClusterSpawn.DNS) > 0 || len(cfg.ClusterSpawn.DNSSearches) > 0 || cfg.ClusterSpawn.DNSNdots > 0 {
+				req.Dns = &pb.DNSConfig{
+					Nameservers: cfg.ClusterSpawn.DNS,
+					Searches:    cfg.ClusterSpawn.DNSSearches,
+					Ndots:       uint32(cfg.ClusterSpawn.DNSNdots),
+				}
+			}
+
+			if cfg.ClusterSpawn.IOReadBPS > 0 || cfg.ClusterSpawn.IOWriteBPS > 0 || cfg.ClusterSpawn.IOReadIOPS > 0 || cfg.ClusterSpawn.IOWriteIOPS > 0 {
+				req.IoLimits = &pb.IOLimits{
+					ReadBytesPerSecond:  uint64(cfg.ClusterSpawn.IOReadBPS),
+					WriteBytesPerSecond: uint64(cfg.ClusterSpawn.IOWriteBPS),
+					ReadIops:            cfg.ClusterSpawn.IOReadIOPS,
+					WriteIops:           cfg.ClusterSpawn.IOWriteIOPS,
+				}
+			}
+
+			if cfg.ClusterSpawn.Explain {
+				explanation, err := c.Explain(context.Background(), req)
+				if err != nil {
+					return err
+				}
+
+				printExplanation(explanation)
+
+				return nil
+			}
+
+			if cfg.ClusterSpawn.Plan {
+				plan, err := c.PlanSpawn(context.Background(), req)
+				if err != nil {
+					return err
+				}
+
+				printPlan(plan)
+
+				return nil
+			}
+
+			resp, err := c.Spawn(context.Background(), req)
+			if err != nil {
+				return err
+			}
+
+			if resp.GetPending() && resp.GetQueuePosition() == 0 {
+				log.Infof("Workload %s is pending until a node has capacity for it", resp.GetId())
+
+				return nil
+			}
+
+			if resp.GetPending() {
+				log.Infof("Workload %s queued at position %d", resp.GetId(), resp.GetQueuePosition())
+
+				return nil
+			}
+
+			log.Infof("Got response: %v", resp)
+
+			return nil
+		},
+	}
+
+	AddClusterSpawnFlags(cmd, cfg)
+
+	return cmd
+}
+
+// parseProbe converts http:PORT/PATH, tcp:PORT and exec:COMMAND to a
+// probe with the default timings, nil if probe is empty
+func parseProbe(probe string) (*pb.Probe, error) {
+	if probe == "" {
+		return nil, nil
+	}
+
+	kind, target, _ := strings.Cut(probe, ":")
+
+	switch kind {
+	case "exec":
+		command := strings.Fields(target)
+		if len(command) == 0 {
+			return nil, fmt.Errorf("invalid probe %q, expected exec:COMMAND", probe)
+		}
+
+		return &pb.Probe{Command: command}, nil
+	case "http", "tcp":
+		port, path, _ := strings.Cut(target, "/")
+
+		containerPort, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in probe %q: %w", probe, err)
+		}
+
+		if kind == "tcp" {
+			return &pb.Probe{Port: uint32(containerPort)}, nil
+		}
+
+		return &pb.Probe{Port: uint32(containerPort), HttpPath: "/" + path}, nil
+	default:
+		return nil, fmt.Errorf("invalid probe %q, expected http:PORT/PATH, tcp:PORT or exec:COMMAND", probe)
+	}
+}
+
+// parseInitSteps parses IMAGE [COMMAND] init steps, - standing for the
+// image of the workload
+func parseInitSteps(specs []string, timeout time.Duration) ([]*pb.InitStep, error) {
+	steps := make([]*pb.InitStep, 0, len(specs))
+
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid init step %q, expected IMAGE [COMMAND]", spec)
+		}
+
+		step := &pb.InitStep{Command: fields[1:], TimeoutSeconds: uint32(timeout.Seconds())}
+		if fields[0] != "-" {
+			step.ImageRef = fields[0]
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// parsePodContainers parses NAME IMAGE [COMMAND] pod containers, - standing
+// for the image of the workload
+func parsePodContainers(specs []string) ([]*pb.PodContainer, error) {
+	containers := make([]*pb.PodContainer, 0, len(specs))
+
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid container %q, expected NAME IMAGE [COMMAND]", spec)
+		}
+
+		container := &pb.PodContainer{Name: fields[0], Command: fields[2:]}
+		if fields[1] != "-" {
+			container.ImageRef = fields[1]
+		}
+
+		containers = append(containers, container)
+	}
+
+	return containers, nil
+}
+
+// parseEnv converts KEY=value entries to a map
+func parseEnv(entries []string) (map[string]string, error) {
+	return parseKeyValues("environment variable", entries)
+}
+
+func parseKeyValues(kind string, entries []string) (map[string]string, error) {
+	values := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s %q, expected KEY=value", kind, entry)
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// parseIngressRules parses rules in the CIDR,...=PORT,... format, either
+// side being optional
+func parseIngressRules(entries []string) ([]*pb.IngressRule, error) {
+	rules := make([]*pb.IngressRule, 0, len(entries))
+
+	for _, entry := range entries {
+		cidrs, ports, _ := strings.Cut(entry, "=")
+		rule := &pb.IngressRule{}
+
+		if cidrs != "" {
+			rule.SourceCidrs = strings.Split(cidrs, ",")
+		}
+
+		if ports != "" {
+			for _, port := range strings.Split(ports, ",") {
+				value, err := strconv.ParseUint(port, 10, 16)
+				if err != nil || value == 0 {
+					return nil, fmt.Errorf("invalid port %q in ingress rule %q", port, entry)
+				}
+
+				rule.Ports = append(rule.Ports, uint32(value))
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parseExtraHosts parses HOSTNAME:IP entries, the hostnames of the same
+// IP address sharing its line. The IP addresses are checked by the node
+func parseExtraHosts(entries []string) ([]*pb.HostEntry, error) {
+	var hosts []*pb.HostEntry
+
+	byIP := make(map[string]*pb.HostEntry)
+
+	for _, entry := range entries {
+		hostname, ip, ok := strings.Cut(entry, ":")
+		if !ok || hostname == "" || ip == "" {
+			return nil, fmt.Errorf("invalid extra host %q, expected HOSTNAME:IP", entry)
+		}
+
+		if host, ok := byIP[ip]; ok {
+			host.Hostnames = append(host.Hostnames, hostname)
+
+			continue
+		}
+
+		byIP[ip] = &pb.HostEntry{Ip: ip, Hostnames: []string{hostname}}
+		hosts = append(hosts, byIP[ip])
+	}
+
+	return hosts, nil
+}
+
+// parseVolumes parses NAME:PATH[:ro] volume mounts
+func parseVolumes(entries []string, fromSnapshots map[string]string) ([]*pb.VolumeMount, error) {
+	volumes := make([]*pb.VolumeMount, 0, len(entries))
+
+	for _, entry := range entries {
+		name, mountPath, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || mountPath == "" {
+			return nil, fmt.Errorf("invalid volume %q, expected NAME:PATH[:ro]", entry)
+		}
+
+		volume := &pb.VolumeMount{Name: name, MountPath: mountPath}
+		if path, mode, ok := strings.Cut(mountPath, ":"); ok {
+			if mode != "ro" {
+				return nil, fmt.Errorf("invalid volume %q, expected NAME:PATH[:ro]", entry)
+			}
+
+			volume.MountPath = path
+			volume.ReadOnly = true
+		}
+
+		if ref, ok := fromSnapshots[name]; ok {
+			volume.FromSnapshot = ref
+		}
+
+		volumes = append(volumes, volume)
+	}
+
+	for name := range fromSnapshots {
+		if !slices.ContainsFunc(volumes, func(volume *pb.VolumeMount) bool { return volume.GetName() == name }) {
+			return nil, fmt.Errorf("volume %s restored from a snapshot isn't mounted with --%s", name, volumeFlag)
+		}
+	}
+
+	return volumes, nil
+}
+
+func ClusterUpdateCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update ID",
+		Short: "replace the replicas of a workload with updated ones, one at a time",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := parseEnv(cfg.ClusterUpdate.Env)
+			if err != nil {
+				return err
+			}
+
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.UpdateWorkload(cmd.Context(), &pb.UpdateWorkloadRequest{
+				Id:       args[0],
+				ImageRef: cfg.ClusterUpdate.ImageRef,
+				Env:      env,
+				Cores:    uint32(cfg.ClusterUpdate.CPU),
+				Memory:   uint32(cfg.ClusterUpdate.Memory),
+			})
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Workload %s updated to revision %d, replicas: %v", args[0], resp.GetRevision().GetRevision(), resp.GetIds())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterUpdateFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterRollbackCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback ID",
+		Short: "roll a workload back to a previous revision",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Rollback(cmd.Context(), args[0], uint32(cfg.ClusterUpdate.Revision))
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Workload %s rolled back as revision %d, replicas: %v", args[0], resp.GetRevision().GetRevision(), resp.GetIds())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterRollbackFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterRevisionsCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revisions ID",
+		Short: "show the revision history of a workload",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.ListRevisions(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, revision := range resp.GetRevisions() {
+				spec := revision.GetSpec()
+				log.Infof("Revision %d at %s: image %s, %d vCPUs, %d MB, env %v", revision.GetRevision(), time.Unix(revision.GetUnixTime(), 0).Format(time.RFC3339),
+					spec.GetImageRef(), spec.GetCores(), spec.GetMemory(), spec.GetEnv())
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterCanaryCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "canary ID",
+		Short: "send a share of the traffic of a workload to updated replicas",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := parseEnv(cfg.ClusterUpdate.Env)
+			if err != nil {
+				return err
+			}
+
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Canary(cmd.Context(), &pb.CanaryRequest{
+				Update: &pb.UpdateWorkloadRequest{
+					Id:       args[0],
+					ImageRef: cfg.ClusterUpdate.ImageRef,
+					Env:      env,
+					Cores:    uint32(cfg.ClusterUpdate.CPU),
+					Memory:   uint32(cfg.ClusterUpdate.Memory),
+				},
+				Replicas:        uint32(cfg.ClusterCanary.Replicas),
+				Weight:          uint32(cfg.ClusterCanary.Weight),
+				MaxErrorRate:    cfg.ClusterCanary.MaxErrorRate,
+				AnalysisSeconds: uint32(cfg.ClusterCanary.Analysis.Seconds()),
+				MinRequests:     uint64(cfg.ClusterCanary.MinRequests),
+			})
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Canary of workload %s revision %d receiving %d%% of the traffic, replicas: %v", args[0], resp.GetRevision().GetRevision(), cfg.ClusterCanary.Weight, resp.GetIds())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterCanaryFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterTrafficCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "traffic ID",
+		Short: "split the traffic of a workload between its revisions",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			split, err := c.SetTrafficSplit(cmd.Context(), &pb.TrafficSplit{
+				Id:       args[0],
+				Revision: uint32(cfg.ClusterTraffic.Revision),
+				Weight:   uint32(cfg.ClusterTraffic.Weight),
+				Clear:    cfg.ClusterTraffic.Clear,
+			})
+			if err != nil {
+				return err
+			}
+
+			if split.GetClear() {
+				log.Infof("Traffic of workload %s balanced across all its replicas", args[0])
+			} else {
+				log.Infof("Sending %d%% of the traffic of workload %s to revision %d", split.GetWeight(), args[0], split.GetRevision())
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterTrafficFlags(cmd, cfg)
+
+	return cmd
+}
+
+func printExplanation(explanation *pb.ExplainResponse) {
+	if existing := explanation.GetExisting(); existing != nil {
+		log.Infof("Already spawned with this idempotency key as %s", existing.GetId())
+	}
+
+	for _, candidate := range explanation.GetCandidates() {
+		breakdown := make([]string, 0, len(candidate.GetScoreBreakdown()))
+		for name, value := range candidate.GetScoreBreakdown() {
+			breakdown = append(breakdown, fmt.Sprintf("%s=%.2f", name, value))
+		}
+		sort.Strings(breakdown)
+
+		log.Infof("Node %s: rank %d, eligible %t, score %.2f (%s)", candidate.GetNode().GetId(), candidate.GetRank(), candidate.GetEligible(), candidate.GetScore(), strings.Join(breakdown, ", "))
+
+		for _, constraint := range candidate.GetConstraints() {
+			result := "passed"
+			if !constraint.GetPassed() {
+				result = "failed"
+			}
+
+			log.Infof("  %s %s: %s", constraint.GetName(), result, constraint.GetMessage())
+		}
+	}
+
+	if explanation.GetSelectedNode() == "" {
+		log.Warn("No eligible node for the request")
+
+		return
+	}
+
+	log.Infof("Selected node: %s", explanation.GetSelectedNode())
+}
+
+func printPlan(plan *pb.PlanSpawnResponse) {
+	for i, node := range plan.GetNodes() {
+		log.Infof("Replica %d: node %s", i+1, node)
+	}
+
+	if placed := len(plan.GetNodes()); placed < int(plan.GetReplicas()) {
+		log.Warnf("Only %d of the %d replicas fit in the cluster", placed, plan.GetReplicas())
+	}
+
+	for _, headroom := range plan.GetNodeHeadroom() {
+		log.Infof("Node %s: %d vCPUs, %d MB left", headroom.GetNode(), headroom.GetCpus(), headroom.GetMemory())
+	}
+
+	log.Infof("Cluster: %d vCPUs, %d MB left", plan.GetClusterHeadroom().GetCpus(), plan.GetClusterHeadroom().GetMemory())
+}
+
+func clusterClient(cfg *Config, opts ...client.Option) (*client.Client, error) {
+	if cfg.GrpcAuthToken != "" {
+		opts = append(opts, client.WithToken(cfg.GrpcAuthToken))
+	}
+
+	if cfg.GrpcTLSCert != "" || cfg.GrpcTLSKey != "" || cfg.GrpcTLSCA != "" {
+		opts = append(opts, client.WithTLSFiles(cfg.GrpcTLSCert, cfg.GrpcTLSKey, cfg.GrpcTLSCA))
+	}
+
+	opts = append(opts, client.WithCompression(cfg.GrpcCompression), client.WithMaxMessageSize(cfg.GrpcMaxMessageSize))
+
+	return client.New(cfg.GrpcBindAddr, opts...)
+}
+
+func ClusterStopCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop ID|NAME|-l SELECTOR",
+		Short: "stop a workload or replica group in a cluster, or every workload matching a label selector",
+		Args:  selectorArgs(&cfg.ClusterStop.LabelSelector),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterStop.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var resp *pb.VmStopResponse
+			if cfg.ClusterStop.LabelSelector != "" {
+				resp, err = c.StopSelected(cmd.Context(), cfg.ClusterStop.LabelSelector)
+			} else {
+				resp, err = c.Stop(cmd.Context(), args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Stopped workloads: %v", resp.GetStoppedIds())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterStopFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterRestartCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restart ID|NAME",
+		Short: "stop a workload gracefully and start it again on the same node, keeping its ID",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterRestart.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Restart(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Restarted workload %s on node %s", resp.GetId(), resp.GetNode())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterRestartFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterStopManyCommand(cfg *Config) *cobra.Command {
+	return clusterBulkCommand(cfg, "stop-many", "stop many workloads in a cluster with bounded parallelism", "stopped", (*client.Client).StopMany)
+}
+
+func ClusterRestartManyCommand(cfg *Config) *cobra.Command {
+	return clusterBulkCommand(cfg, "restart-many", "restart many workloads in a cluster with bounded parallelism", "restarted", (*client.Client).RestartMany)
+}
+
+// clusterBulkCommand runs a bulk request on the workloads given as
+// arguments or matching the selector, printing the result of each of them
+func clusterBulkCommand(cfg *Config, name, short, done string,
+	run func(*client.Client, context.Context, *pb.BulkRequest) (<-chan *pb.BulkProgress, <-chan error),
+) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name + " [ID|NAME...]|-l SELECTOR",
+		Short: short,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (cfg.ClusterBulk.LabelSelector == "") == (len(args) == 0) {
+				return errors.New("either workloads or a label selector are required, not both")
+			}
+
+			return nil
+		},
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterBulk.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			progress, errCh := run(c, cmd.Context(), &pb.BulkRequest{
+				LabelSelector: cfg.ClusterBulk.LabelSelector,
+				Ids:           args,
+				Parallelism:   uint32(cfg.ClusterBulk.Parallelism),
+				MaxPerNode:    uint32(cfg.ClusterBulk.MaxPerNode),
+			})
+
+			var summary *pb.BulkSummary
+
+			for message := range progress {
+				if message.GetSummary() != nil {
+					summary = message.GetSummary()
+
+					continue
+				}
+
+				item := message.GetItem()
+				if item.GetError() != "" {
+					log.Errorf("Workload %s on node %s failed: %s", item.GetId(), item.GetNode(), item.GetError())
+
+					continue
+				}
+
+				log.Infof("Workload %s on node %s %s", item.GetId(), item.GetNode(), done)
+			}
+
+			if err := <-errCh; err != nil {
+				return err
+			}
+
+			log.Infof("%d of %d workloads %s, %d failed", summary.GetSucceeded(), summary.GetTotal(), done, summary.GetFailed())
+
+			if summary.GetFailed() > 0 {
+				return fmt.Errorf("%d of %d workloads failed", summary.GetFailed(), summary.GetTotal())
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterBulkFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterListCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list workloads running in a cluster",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			status, ok := pb.WorkloadStatus_value[strings.ToUpper(strings.ReplaceAll(cfg.ClusterList.Status, "-", "_"))]
+			if !ok && cfg.ClusterList.Status != "" {
+				return fmt.Errorf("invalid status %q", cfg.ClusterList.Status)
+			}
+
+			resp, err := c.List(cmd.Context(), &pb.VmQueryRequest{
+				Node:          cfg.ClusterList.Node,
+				Tenant:        cfg.ClusterList.Tenant,
+				LabelSelector: cfg.ClusterList.LabelSelector,
+				Status:        pb.WorkloadStatus(status),
+				Limit:         uint32(cfg.ClusterList.Limit),
+				PageToken:     cfg.ClusterList.PageToken,
+			})
+			if err != nil {
+				return err
+			}
 
-	"vistara-node/pkg/containerd"
-	"vistara-node/pkg/defaults"
-	pb "vistara-node/pkg/proto/cluster"
+			ids := make([]string, 0, len(resp.GetVms())+len(resp.GetPending()))
+			for id := range resp.GetVms() {
+				ids = append(ids, id)
+			}
+			for id := range resp.GetPending() {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
 
-	"github.com/spf13/cobra"
+			for _, id := range ids {
+				if pending, ok := resp.GetPending()[id]; ok {
+					log.Infof("Workload %s pending until %s (%s): %v", id,
+						time.Unix(pending.GetExpiresUnixTime(), 0).Format(time.RFC3339), pending.GetReason(), pending.GetSpec())
 
-	"vistara-node/pkg/models"
+					continue
+				}
 
-	"github.com/containerd/containerd/cio"
-	"github.com/containerd/typeurl/v2"
-	"github.com/google/uuid"
-	toml "github.com/pelletier/go-toml/v2"
-	"google.golang.org/grpc/credentials/insecure"
+				log.Infof("Workload %s: %v", id, resp.GetVms()[id])
+			}
 
-	log "github.com/sirupsen/logrus"
-)
+			if resp.GetNextPageToken() != "" {
+				log.Infof("More workloads with --%s %s", pageTokenFlag, resp.GetNextPageToken())
+			}
 
-type HacConfig struct {
-	Spacecore struct {
-		name        string
-		description string
-	}
-	Hardware struct {
-		Cores     int32
-		Memory    int32
-		Kernel    string
-		Drive     string
-		Interface string
-		Ref       string
+			return nil
+		},
 	}
-}
 
-func containerdConfig(cfg *Config) *containerd.Config {
-	return &containerd.Config{
-		SocketPath:         cfg.CtrSocketPath,
-		ContainerNamespace: cfg.CtrNamespace,
-	}
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterListFlags(cmd, cfg)
+
+	return cmd
 }
 
-func AttachCommand(cfg *Config) *cobra.Command {
+func ClusterLogsCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "attach",
-		Short: "attach to a VM",
-		Args:  cobra.ExactArgs(1),
+		Use:   "logs ID|NAME|-l SELECTOR",
+		Short: "show the logs of a workload in a cluster, or of every workload matching a label selector",
+		Args:  selectorArgs(&cfg.ClusterLogs.LabelSelector),
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg))
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterLogs.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if cfg.ClusterLogs.LabelSelector != "" {
+				logs, err := c.SelectedLogs(cmd.Context(), cfg.ClusterLogs.LabelSelector, uint32(cfg.ClusterLogs.TailBytes))
+				if err != nil {
+					return err
+				}
+
+				return writeSelectedLogs(os.Stdout, logs)
+			}
+
+			logs, err := c.Logs(cmd.Context(), args[0], uint32(cfg.ClusterLogs.TailBytes))
 			if err != nil {
 				return err
 			}
 
-			return repo.Attach(cmd.Context(), os.Args[2])
+			_, err = os.Stdout.Write(logs)
+
+			return err
 		},
 	}
 
-	AddCommonFlags(cmd, cfg)
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterLogsFlags(cmd, cfg)
 
 	return cmd
 }
 
-func ClusterSpawnCommand(cfg *Config) *cobra.Command {
+// selectorArgs requires either the ID or name of a workload as the only
+// argument, or the label selector but no argument
+func selectorArgs(selector *string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if *selector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+}
+
+// writeSelectedLogs writes the logs of each workload in the order of their
+// IDs, after a header naming it
+func writeSelectedLogs(w io.Writer, logs map[string][]byte) error {
+	for i, id := range slices.Sorted(maps.Keys(logs)) {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "==> %s <==\n", id); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(logs[id]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ClusterDescribeCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "spawn",
-		Short: "spawn a VM in a cluster",
+		Use:   "describe ID|NAME",
+		Short: "show the spec and the active firewall ruleset of a workload in a cluster",
+		Args:  cobra.ExactArgs(1),
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
-		RunE: func(_ *cobra.Command, _ []string) error {
-			ports := map[uint32]uint32{}
-			for _, portMap := range strings.Split(cfg.ClusterSpawn.Ports, ",") {
-				hostToContainer := strings.Split(portMap, ":")
-				if len(hostToContainer) != 2 {
-					return fmt.Errorf("invalid port mapping: %s", portMap)
-				}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterDescribe.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
 
-				hostPort, err := strconv.Atoi(hostToContainer[0])
-				if err != nil {
-					return err
+			resp, err := c.DescribeWorkload(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Workload %s on node %s, image %s", resp.GetId(), resp.GetNode(), resp.GetSpec().GetImageRef())
+
+			if len(resp.GetSpec().GetIngressRules()) == 0 {
+				log.Infof("Ingress: all connections accepted")
+			}
+
+			for _, rule := range resp.GetSpec().GetIngressRules() {
+				sources := "any source"
+				if len(rule.GetSourceCidrs()) > 0 {
+					sources = strings.Join(rule.GetSourceCidrs(), ", ")
 				}
 
-				containerPort, err := strconv.Atoi(hostToContainer[1])
-				if err != nil {
-					return err
+				ports := "any port"
+				if len(rule.GetPorts()) > 0 {
+					ports = fmt.Sprint(rule.GetPorts())
 				}
 
-				ports[uint32(hostPort)] = uint32(containerPort)
+				log.Infof("Ingress: from %s to %s", sources, ports)
 			}
 
-			conn, err := grpc.NewClient(cfg.GrpcBindAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			log.Infof("Active ruleset:\n%s", resp.GetRuleset())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterDescribeFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterEventsCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "follow the events of a cluster, optionally for a single workload",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
-			defer conn.Close()
+			defer c.Close()
 
-			c := pb.NewClusterServiceClient(conn)
-			resp, err := c.Spawn(context.Background(), &pb.VmSpawnRequest{
-				Cores:    uint32(cfg.ClusterSpawn.CPU),
-				Memory:   uint32(cfg.ClusterSpawn.Memory),
-				ImageRef: cfg.ClusterSpawn.ImageRef,
-				Ports:    ports,
-			})
-			if err != nil {
-				return err
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
 			}
 
-			log.Infof("Got response: %v", resp)
+			events, errCh := c.WatchEvents(cmd.Context(), id)
+			for event := range events {
+				log.Infof("[%s] %s %s on node %s: %s", time.Unix(event.GetUnixTime(), 0).Format(time.RFC3339), event.GetEvent(), event.GetId(), event.GetNode().GetId(), event.GetMessage())
+			}
 
-			return nil
+			return <-errCh
 		},
 	}
 
-	AddClusterSpawnFlags(cmd, cfg)
+	AddClusterClientFlags(cmd, cfg)
 
 	return cmd
 }
 
-func ClusterCommand(cfg *Config) *cobra.Command {
+func ClusterMetricsCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "cluster",
-		Short: "join a hypercore cluster",
-		Args:  cobra.MaximumNArgs(1),
+		Use:   "metrics",
+		Short: "show the capacity and usage of the cluster nodes",
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
-		RunE: func(_ *cobra.Command, args []string) error {
-			logger := log.New()
-
-			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg))
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
+			defer c.Close()
 
-			var tlsConfig *cluster.TLSConfig
+			if cfg.ClusterMetrics.Format != metricsFormatText && cfg.ClusterMetrics.Format != metricsFormatJSON {
+				return fmt.Errorf("unknown format %s, expected %s or %s", cfg.ClusterMetrics.Format, metricsFormatText, metricsFormatJSON)
+			}
 
-			if cfg.ClusterTLSKey != "" && cfg.ClusterTLSCert != "" {
-				tlsConfig = &cluster.TLSConfig{
-					CertFile: cfg.ClusterTLSCert,
-					KeyFile:  cfg.ClusterTLSKey,
-				}
+			var since time.Time
+			if cfg.ClusterMetrics.Since > 0 {
+				since = time.Now().Add(-cfg.ClusterMetrics.Since)
 			}
 
-			agent, err := cluster.NewAgent(logger, cfg.ClusterBaseURL, cfg.ClusterBindAddr, cfg.RespawnOnNodeFailure, repo, tlsConfig)
+			resp, err := c.Metrics(cmd.Context(), since)
 			if err != nil {
 				return err
 			}
 
-			if len(args) > 0 {
-				if err := agent.Join(args[0]); err != nil {
+			if cfg.ClusterMetrics.Format == metricsFormatJSON {
+				encoded, err := protojson.MarshalOptions{Multiline: true}.Marshal(resp)
+				if err != nil {
 					return err
 				}
+
+				_, err = fmt.Fprintln(os.Stdout, string(encoded))
+
+				return err
+			}
+
+			for _, bucket := range resp.GetHistory() {
+				log.Infof("At %s:", time.Unix(bucket.GetUnixTime(), 0).Format(time.RFC3339))
+				logMetrics(bucket.GetNodes(), bucket.GetServices(), "  ")
+			}
+
+			if len(resp.GetHistory()) > 0 {
+				log.Info("Now:")
+				logMetrics(resp.GetNodes(), resp.GetServices(), "  ")
+			} else {
+				logMetrics(resp.GetNodes(), resp.GetServices(), "")
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterMetricsFlags(cmd, cfg)
+
+	return cmd
+}
+
+// Output formats of the metrics command
+const (
+	metricsFormatText = "text"
+	metricsFormatJSON = "json"
+)
+
+func logMetrics(nodes []*pb.NodeMetrics, services []*pb.ServiceMetrics, indent string) {
+	for _, node := range nodes {
+		log.Infof("%sNode %s: %d/%d vCPUs, %d/%d MB, %d workloads, load %.2f, %d MB disk free, %.0f%% network utilization", indent, node.GetNode().GetId(), node.GetCpusUsed(), node.GetCpus(), node.GetMemoryUsed(), node.GetMemory(), node.GetWorkloads(),
+			node.GetHost().GetLoad1(), node.GetHost().GetDiskFree(), node.GetHost().GetNetworkUtilization()*100)
+	}
+
+	for _, service := range services {
+		log.Infof("%sService %s: %d requests, %d errors, %.2fms average latency", indent, service.GetServiceId(), service.GetRequests(), service.GetErrors(), service.GetAvgLatencyMs())
+
+		for _, revision := range service.GetRevisions() {
+			log.Infof("%s  Revision %d: %d requests, %d errors", indent, revision.GetRevision(), revision.GetRequests(), revision.GetErrors())
+		}
+	}
+}
+
+func ClusterApplyCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply FILE",
+		Short: "spawn all the workloads of a batch file in a cluster at once",
+		Long: `Spawns the workloads of a JSON batch file, {"requests": [...], "spread": false}
+where each request has the fields of a spawn request, in a single placement
+across the cluster nodes`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			contents, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			var batch pb.SpawnBatchRequest
+			if err := protojson.Unmarshal(contents, &batch); err != nil {
+				return fmt.Errorf("failed to parse batch file %s: %w", args[0], err)
 			}
 
-			grpcServer := cluster.NewServer(logger, agent)
-			grpcListener, err := net.Listen("tcp", cfg.GrpcBindAddr)
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
+			defer c.Close()
+
+			failed := 0
 
-			quitWg := sync.WaitGroup{}
-			quitWg.Add(2)
+			results, errCh := c.SpawnBatch(cmd.Context(), batch.GetRequests(), batch.GetSpread() || cfg.ClusterApply.Spread)
+			for result := range results {
+				if result.GetError() != "" {
+					failed++
+					log.Errorf("Workload %d failed: %s", result.GetIndex(), result.GetError())
 
-			go func() {
-				defer quitWg.Done()
-				if err := grpcServer.Serve(grpcListener); err != nil {
-					panic(err)
+					continue
 				}
-			}()
 
-			go func() {
-				defer quitWg.Done()
-				agent.Handler()
-			}()
+				if result.GetResponse().GetPending() {
+					log.Infof("Workload %d queued on node %s at position %d: %v", result.GetIndex(), result.GetNode(), result.GetResponse().GetQueuePosition(), result.GetResponse())
+
+					continue
+				}
+
+				log.Infof("Workload %d spawned on node %s: %v", result.GetIndex(), result.GetNode(), result.GetResponse())
+			}
+
+			if err := <-errCh; err != nil {
+				return err
+			}
 
-			quitWg.Wait()
+			if failed > 0 {
+				return fmt.Errorf("%d of %d workloads failed to spawn", failed, len(batch.GetRequests()))
+			}
 
 			return nil
 		},
 	}
 
-	cmd.AddCommand(ClusterSpawnCommand(cfg))
-
-	// TODO remove hac/vmm flags
-	AddCommonFlags(cmd, cfg)
-	AddClusterFlags(cmd, cfg)
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterApplyFlags(cmd, cfg)
 
 	return cmd
 }
 
-func ListCommand(cfg *Config) *cobra.Command {
+func ClusterUsageCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "list",
-		Short: "List running VMs",
+		Use:   "usage",
+		Short: "show the metered usage and cost of the workloads across the cluster",
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg))
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
+			defer c.Close()
 
-			tasks, err := repo.GetTasks(cmd.Context())
+			resp, err := c.Billing(cmd.Context(), cfg.ClusterUsage.Tenant)
 			if err != nil {
 				return err
 			}
 
-			for _, task := range tasks {
-				log.Infof("Task %s, Container %s\n", task.GetID(), task.GetContainerID())
+			for _, record := range resp.GetRecords() {
+				log.Infof("Workload %s (tenant %q) on node %s since %s: %.1f CPU-seconds, %.3f GB-hours, %.3f GB egress, cost %.4f", record.GetId(), record.GetTenant(), record.GetNode().GetId(),
+					time.Unix(record.GetStartUnixTime(), 0).Format(time.RFC3339), record.GetCpuSeconds(), record.GetGbHours(), record.GetEgressGb(), record.GetCost())
+			}
+
+			for _, node := range resp.GetUnreachableNodes() {
+				log.Warnf("Node %s is unreachable, its workloads are missing", node)
+			}
+
+			for _, tenant := range slices.Sorted(maps.Keys(resp.GetTenantEgressBytes())) {
+				log.Infof("Tenant %q egress: %d bytes", tenant, resp.GetTenantEgressBytes()[tenant])
 			}
 
+			log.Infof("Total cost: %.4f", resp.GetTotalCost())
+
 			return nil
 		},
 	}
 
-	AddCommonFlags(cmd, cfg)
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterUsageFlags(cmd, cfg)
 
 	return cmd
 }
 
-func SpawnCommand(cfg *Config) *cobra.Command {
+func ClusterTopologyCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "spawn",
-		Short: "Spawn a VM under Hypercore",
+		Use:   "topology",
+		Short: "show the connections between the workloads across the cluster",
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			typeurl.Register(&models.MicroVMSpec{}, "models.MicroVMSpec")
+			format := cfg.ClusterTopology.Format
+			if format != topologyFormatText && format != topologyFormatDOT && format != topologyFormatJSON {
+				return fmt.Errorf("unknown format %s, expected %s, %s or %s", format, topologyFormatText, topologyFormatDOT, topologyFormatJSON)
+			}
 
-			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg))
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
+			defer c.Close()
 
-			hacPath, err := filepath.Abs(cfg.HACFile)
+			resp, err := c.Topology(cmd.Context(), cfg.ClusterTopology.Tenant)
 			if err != nil {
 				return err
 			}
 
-			hacContents, err := os.ReadFile(hacPath)
-			if err != nil {
+			for _, node := range resp.GetUnreachableNodes() {
+				log.Warnf("Node %s is unreachable, the connections of its workloads are missing", node)
+			}
+
+			switch format {
+			case topologyFormatJSON:
+				encoded, err := protojson.MarshalOptions{Multiline: true}.Marshal(resp)
+				if err != nil {
+					return err
+				}
+
+				_, err = fmt.Fprintln(os.Stdout, string(encoded))
+
+				return err
+			case topologyFormatDOT:
+				_, err = fmt.Fprint(os.Stdout, topologyDOT(resp.GetEdges()))
+
 				return err
 			}
 
-			vmUUID := uuid.NewString()
-			hacConfig := HacConfig{}
+			for _, edge := range resp.GetEdges() {
+				log.Infof("%s -> %s:%d/%s on node %s: %d bytes sent, %d bytes received, %d connections, last seen %s", topologySource(edge), topologyDestination(edge), edge.GetPort(), edge.GetProtocol(),
+					edge.GetNode(), edge.GetSentBytes(), edge.GetReceivedBytes(), edge.GetConnections(), time.Unix(edge.GetLastSeenUnixTime(), 0).Format(time.RFC3339))
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterTopologyFlags(cmd, cfg)
+
+	return cmd
+}
+
+// Output formats of the topology command
+const (
+	topologyFormatText = "text"
+	topologyFormatDOT  = "dot"
+	topologyFormatJSON = "json"
+)
+
+// topologySource returns the workload an edge comes from, its address if
+// it isn't one
+func topologySource(edge *pb.TopologyEdge) string {
+	return cmp.Or(edge.GetSourceId(), edge.GetSourceAddress())
+}
+
+// topologyDestination returns the workload an edge goes to, its address
+// if it isn't one
+func topologyDestination(edge *pb.TopologyEdge) string {
+	return cmp.Or(edge.GetDestinationId(), edge.GetDestinationAddress())
+}
+
+// topologyDOT renders the edges as a Graphviz digraph, the workloads in
+// boxes and the other addresses in ellipses
+func topologyDOT(edges []*pb.TopologyEdge) string {
+	var b strings.Builder
+
+	b.WriteString("digraph topology {\n")
+
+	workloads := make(map[string]bool)
+
+	for _, edge := range edges {
+		for _, id := range []string{edge.GetSourceId(), edge.GetDestinationId()} {
+			if id != "" && !workloads[id] {
+				workloads[id] = true
+				fmt.Fprintf(&b, "  %q [shape=box];\n", id)
+			}
+		}
+	}
+
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", topologySource(edge), topologyDestination(edge),
+			fmt.Sprintf("%d/%s %dB/%dB", edge.GetPort(), edge.GetProtocol(), edge.GetSentBytes(), edge.GetReceivedBytes()))
+	}
+
+	b.WriteString("}\n")
 
-			if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+	return b.String()
+}
+
+func ClusterCacheCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "show the images the nodes pulled ahead of the placements they are likely to get",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
 				return err
 			}
+			defer c.Close()
 
-			log.Infof("Creating VM '%s' with config %+v\n", vmUUID, hacConfig)
+			resp, err := c.CacheStatus(cmd.Context(), &pb.CacheStatusRequest{
+				Node:     cfg.ClusterCache.Node,
+				ImageRef: cfg.ClusterCache.ImageRef,
+			})
+			if err != nil {
+				return err
+			}
 
-			var id string
+			if len(resp.GetEntries()) == 0 {
+				log.Info("No images were pulled ahead")
 
-			switch cfg.DefaultVMProvider {
-			case "runc":
-				id, err = repo.CreateContainer(cmd.Context(), containerd.CreateContainerOpts{
-					ImageRef:    hacConfig.Hardware.Ref,
-					Snapshotter: "",
-					Runtime: struct {
-						Name    string
-						Options interface{}
-					}{
-						Name: "io.containerd.runc.v2",
-					},
-					CioCreator: cio.NewCreator(cio.WithStdio),
-				})
-			case "firecracker":
-				fallthrough
-			case "cloudhypervisor":
-				id, err = repo.CreateContainer(cmd.Context(), containerd.CreateContainerOpts{
-					ImageRef:    hacConfig.Hardware.Ref,
-					Snapshotter: "devmapper",
-					Runtime: struct {
-						Name    string
-						Options interface{}
-					}{
-						Name: "hypercore.example",
-						Options: &models.MicroVMSpec{
-							Provider:   cfg.DefaultVMProvider,
-							VCPU:       hacConfig.Hardware.Cores,
-							MemoryInMb: hacConfig.Hardware.Memory,
-							HostNetDev: hacConfig.Hardware.Interface,
-							Kernel:     hacConfig.Hardware.Kernel,
-							RootfsPath: hacConfig.Hardware.Drive,
-						},
-					},
-					CioCreator: cio.NewCreator(cio.WithFIFODir(defaults.StateRootDir+"/fifo"), cio.WithStreams(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{})),
-				})
-			case "docker":
-				client, err := NewDockerClient()
-				if err != nil {
-					return err
-				}
+				return nil
+			}
 
-				id, err = client.Start(cmd.Context(), hacConfig.Hardware.Ref)
-				if err != nil {
-					return err
+			for _, entry := range resp.GetEntries() {
+				status := strings.ToLower(strings.TrimPrefix(entry.GetStatus().String(), "IMAGE_"))
+				if entry.GetError() != "" {
+					status += ": " + entry.GetError()
 				}
+
+				log.Infof("Node %s: image %s %s since %s (%s)", entry.GetNode(), entry.GetImageRef(), status,
+					time.Unix(entry.GetUpdatedUnixTime(), 0).Format(time.RFC3339), entry.GetReason())
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterCacheFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterConfigCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "show the effective tuning of a node's cluster agent",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
 			}
+			defer c.Close()
 
+			resp, err := c.Config(cmd.Context())
 			if err != nil {
 				return err
 			}
 
-			log.Infof("ID: %s\n", id)
+			log.Infof("Node %s: broadcast period %dms, gossip interval %dms, probe interval %dms, user event size limit %d bytes, queue depth warning %d, max queue depth %d",
+				resp.GetNode().GetId(), resp.GetBroadcastPeriodMs(), resp.GetGossipIntervalMs(), resp.GetProbeIntervalMs(), resp.GetUserEventSizeLimit(), resp.GetQueueDepthWarning(), resp.GetMaxQueueDepth())
+			log.Infof("Admission: %d concurrent creates, %d queued spawns, %.2f spawns/s per tenant (burst %d)",
+				resp.GetMaxConcurrentCreates(), resp.GetMaxQueuedSpawns(), resp.GetSpawnRateLimit(), resp.GetSpawnRateBurst())
 
 			return nil
 		},
 	}
 
-	AddCommonFlags(cmd, cfg)
+	AddClusterClientFlags(cmd, cfg)
 
 	return cmd
 }
 
-func StopCommand(cfg *Config) *cobra.Command {
+func ClusterFaultsCommand(cfg *Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "stop",
-		Short: "stop a VM",
-		Args:  cobra.ExactArgs(1),
+		Use:   "faults",
+		Short: "show or set the faults injected by a node built with the chaos tag",
 		PreRunE: func(c *cobra.Command, _ []string) error {
 			BindCommandToViper(c)
 
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg))
+			c, err := clusterClient(cfg)
 			if err != nil {
 				return err
 			}
+			defer c.Close()
+
+			var faults *pb.FaultConfig
+			if cmd.Flags().Changed(dropUserEventsFlag) || cmd.Flags().Changed(queryDelayFlag) || cmd.Flags().Changed(killWorkloadsFlag) {
+				faults, err = c.SetFaults(cmd.Context(), &pb.FaultConfig{
+					DropUserEventsPercent: cfg.ClusterFaults.DropUserEventsPercent,
+					QueryDelayMs:          uint32(cfg.ClusterFaults.QueryDelay.Milliseconds()),
+					KillWorkloadsPercent:  cfg.ClusterFaults.KillWorkloadsPercent,
+				})
+			} else {
+				faults, err = c.GetFaults(cmd.Context())
+			}
 
-			code, err := repo.DeleteContainer(cmd.Context(), os.Args[2])
 			if err != nil {
 				return err
 			}
 
-			os.Exit(int(code))
+			log.Infof("Dropping %.2f%% of user events, delaying queries by %dms, killing %.2f%% of workloads", faults.GetDropUserEventsPercent(), faults.GetQueryDelayMs(), faults.GetKillWorkloadsPercent())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterFaultsFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "join a hypercore cluster",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
 
 			return nil
 		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runClusterAgent(cfg, args)
+		},
 	}
 
-	AddCommonFlags(cmd, cfg)
+	cmd.AddCommand(ClusterSpawnCommand(cfg))
+	cmd.AddCommand(ClusterStopCommand(cfg))
+	cmd.AddCommand(ClusterRestartCommand(cfg))
+	cmd.AddCommand(ClusterStopManyCommand(cfg))
+	cmd.AddCommand(ClusterRestartManyCommand(cfg))
+	cmd.AddCommand(ClusterListCommand(cfg))
+	cmd.AddCommand(ClusterLogsCommand(cfg))
+	cmd.AddCommand(ClusterDescribeCommand(cfg))
+	cmd.AddCommand(ClusterTopCommand(cfg))
+	cmd.AddCommand(ClusterEventsCommand(cfg))
+	cmd.AddCommand(ClusterMetricsCommand(cfg))
+	cmd.AddCommand(ClusterApplyCommand(cfg))
+	cmd.AddCommand(ClusterUpdateCommand(cfg))
+	cmd.AddCommand(ClusterRollbackCommand(cfg))
+	cmd.AddCommand(ClusterRevisionsCommand(cfg))
+	cmd.AddCommand(ClusterCanaryCommand(cfg))
+	cmd.AddCommand(ClusterTrafficCommand(cfg))
+	cmd.AddCommand(ClusterUsageCommand(cfg))
+	cmd.AddCommand(ClusterCacheCommand(cfg))
+	cmd.AddCommand(ClusterTopologyCommand(cfg))
+	cmd.AddCommand(ClusterSnapshotCommand(cfg))
+	cmd.AddCommand(ClusterSnapshotsCommand(cfg))
+	cmd.AddCommand(ClusterConfigCommand(cfg))
+	cmd.AddCommand(ClusterFaultsCommand(cfg))
+	cmd.AddCommand(ClusterIssueCertCommand(cfg))
+	cmd.AddCommand(ClusterNodesCommand(cfg))
+	cmd.AddCommand(ClusterStatusCommand(cfg))
+	cmd.AddCommand(ClusterJoinCommand(cfg))
+	cmd.AddCommand(ClusterDrainCommand(cfg))
+	cmd.AddCommand(ClusterLeaveCommand(cfg))
+	cmd.AddCommand(ClusterAdoptCommand(cfg))
+	cmd.AddCommand(ClusterUpgradeCommand(cfg))
+	cmd.AddCommand(clusterNodeCommands(cfg)...)
+
+	addClusterAgentFlags(cmd, cfg)
 
 	return cmd
 }
@@ -0,0 +1,711 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/api/services/microvm"
+	"vistara-node/pkg/cluster"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/pool"
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/pushmetrics"
+	"vistara-node/pkg/shim"
+
+	"github.com/containerd/containerd/cio"
+	"github.com/google/uuid"
+	toml "github.com/pelletier/go-toml/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type HacConfig struct {
+	Spacecore struct {
+		name        string
+		description string
+	}
+	Hardware struct {
+		Cores     int32
+		Memory    int32
+		Kernel    string
+		Drive     string
+		Interface string
+		Ref       string
+		// guest architecture, the one of the host if empty
+		Arch string
+		// additional block devices of the VM
+		Disks []HacDisk
+		// leave out the virtio-rng device of the VM
+		DisableEntropy bool `toml:"disable_entropy"`
+		// pause the whole VM when the task is paused
+		PauseVM bool `toml:"pause_vm"`
+		// static CPU template of firecracker
+		CPUTemplate string `toml:"cpu_template"`
+		// sev-snp or tdx, launched from the firmware
+		Confidential string `toml:"confidential"`
+		Firmware     string `toml:"firmware"`
+		// expose the virtualization extensions of the host CPU
+		NestedVirt bool `toml:"nested_virt"`
+		// resolver of the guest, 1.1.1.1 if unset
+		DNS *HacDNS `toml:"dns"`
+		// entries added to the /etc/hosts of the guest
+		ExtraHosts []HacHostEntry `toml:"extra_hosts"`
+	}
+}
+
+// HacHostEntry is a [[hardware.extra_hosts]] entry of the HAC file
+type HacHostEntry struct {
+	IP        string   `toml:"ip"`
+	Hostnames []string `toml:"hostnames"`
+}
+
+// HacDNS is the [hardware.dns] table of the HAC file
+type HacDNS struct {
+	Nameservers []string `toml:"nameservers"`
+	Searches    []string `toml:"searches"`
+	Ndots       uint32   `toml:"ndots"`
+}
+
+// HacDisk is a [[hardware.disks]] entry of the HAC file
+type HacDisk struct {
+	Path      string `toml:"path"`
+	ReadOnly  bool   `toml:"read_only"`
+	CacheMode string `toml:"cache_mode"`
+	Format    string `toml:"format"`
+	MountPath string `toml:"mount_path"`
+}
+
+// containerdConfig returns the containerd config of a command, owning the
+// containers it creates for the purpose
+func containerdConfig(cfg *Config, owner string) *containerd.Config {
+	socketPath := cfg.CtrSocketPath
+	if cfg.Rootless && socketPath == defaults.ContainerdSocket {
+		// Socket of containerd-rootless.sh
+		socketPath = filepath.Join(containerd.RootlessRuntimeDir(), "containerd", "containerd.sock")
+	}
+
+	return &containerd.Config{
+		SocketPath:         socketPath,
+		ContainerNamespace: cfg.CtrNamespace,
+		Owner:              owner,
+		MaxConcurrentPulls: cfg.MaxConcurrentPulls,
+		MaxPullBandwidth:   cfg.MaxPullBandwidth,
+		Rootless:           cfg.Rootless,
+		RootlessNetwork:    cfg.RootlessNetwork,
+		Bridge:             cfg.Bridge,
+	}
+}
+
+func AttachCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach",
+		Short: "attach to a VM",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			return repo.Attach(cmd.Context(), os.Args[2])
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+
+	return cmd
+}
+
+// runClusterAgent runs the cluster agent and its servers, joining the
+// cluster through the node of args if any
+func runClusterAgent(cfg *Config, args []string) error {
+	logger := log.New()
+
+	repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerCluster))
+	if err != nil {
+		return err
+	}
+
+	var proxyCerts *cluster.CertSource
+
+	if cfg.ClusterTLSKey != "" && cfg.ClusterTLSCert != "" {
+		proxyCerts, err = cluster.NewCertSource(logger, cfg.ClusterTLSCert, cfg.ClusterTLSKey, cfg.ClusterTLSCA, cfg.SpiffeTrustDomain)
+		if err != nil {
+			return err
+		}
+
+		go proxyCerts.Watch(context.Background())
+	}
+
+	serverConfig := &cluster.ServerConfig{
+		AuthToken:        cfg.GrpcAuthToken,
+		MaxMessageSize:   cfg.GrpcMaxMessageSize,
+		SimulatedLatency: cfg.Dev.SimulatedLatency,
+	}
+	agentConfig := &cluster.AgentConfig{
+		BaseURL:          cfg.ClusterBaseURL,
+		BindAddr:         cfg.ClusterBindAddr,
+		GrpcBindAddr:     cfg.GrpcBindAddr,
+		Respawn:          cfg.RespawnOnNodeFailure,
+		OOMMemoryCeiling: cfg.OOMMemoryCeiling,
+		PrometheusURL:    cfg.PrometheusURL,
+		ProxyCerts:       proxyCerts,
+		GrpcCompression:  cfg.GrpcCompression,
+
+		MaxConcurrentCreates: cfg.MaxConcurrentCreates,
+		MaxQueuedSpawns:      cfg.MaxQueuedSpawns,
+		SpawnRateLimit:       cfg.SpawnRateLimit,
+		SpawnRateBurst:       cfg.SpawnRateBurst,
+
+		BroadcastPeriod:    cfg.BroadcastPeriod,
+		GossipInterval:     cfg.GossipInterval,
+		ProbeInterval:      cfg.ProbeInterval,
+		UserEventSizeLimit: cfg.UserEventSizeLimit,
+		QueueDepthWarning:  cfg.QueueDepthWarning,
+		MaxQueueDepth:      cfg.MaxQueueDepth,
+
+		Prices: &pb.NodePrices{
+			CpuHour:  cfg.PriceCPUHour,
+			GbHour:   cfg.PriceGBHour,
+			GbEgress: cfg.PriceGBEgress,
+		},
+		EgressProxyAddr:       cfg.EgressProxyAddr,
+		MetadataAddr:          cfg.MetadataAddr,
+		MetadataTokenTTL:      cfg.MetadataTokenTTL,
+		SigningKeyRotation:    cfg.SigningKeyRotation,
+		TrackConnections:      cfg.TrackConnections,
+		EBPFNetworkMetrics:    cfg.EBPFNetworkMetrics,
+		TrustDomain:           cfg.SpiffeTrustDomain,
+		ScrapeWorkloadMetrics: cfg.WorkloadMetrics,
+		WarmImages:            cfg.WarmImages,
+		MaxLogSize:            cfg.WorkloadLogMaxSize,
+		Alerts: &cluster.AlertConfig{
+			Webhooks:      cfg.Alerts.Webhooks,
+			SlackWebhooks: cfg.Alerts.SlackWebhooks,
+			DedupWindow:   cfg.Alerts.DedupWindow,
+			RateLimit:     cfg.Alerts.RateLimit,
+		},
+		EndpointPublish: &cluster.EndpointPublishConfig{
+			ZoneFile:          cfg.EndpointPublish.ZoneFile,
+			Zone:              cfg.EndpointPublish.Zone,
+			HAProxyRuntimeAPI: cfg.EndpointPublish.HAProxyRuntimeAPI,
+			Webhooks:          cfg.EndpointPublish.Webhooks,
+		},
+		Maintenance: &cluster.MaintenanceConfig{
+			Windows:  cfg.Maintenance.Windows,
+			Duration: cfg.Maintenance.Duration,
+			Drain:    cfg.Maintenance.Drain,
+		},
+		MaintenanceLead: cfg.Maintenance.Lead,
+	}
+
+	if cfg.ClusterDNS != "" {
+		agentConfig.ClusterDNS = &models.DNSConfig{
+			Nameservers: []string{cfg.ClusterDNS},
+			Searches:    []string{cfg.EndpointPublish.Zone},
+		}
+	}
+
+	if cfg.Rootless {
+		agentConfig.LogDir = filepath.Join(containerd.RootlessRuntimeDir(), "hypercore", "logs")
+	}
+
+	if cfg.EgressPolicyFile != "" {
+		agentConfig.EgressPolicies, err = cluster.LoadEgressPolicies(cfg.EgressPolicyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.MetadataAddr != "" && cfg.Rootless {
+		return errors.New("the metadata service isn't supported in rootless mode, whose containers have no firewall")
+	}
+
+	if cfg.TrackConnections && cfg.Rootless {
+		return errors.New("connection tracking isn't supported in rootless mode, whose containers have no firewall")
+	}
+
+	if cfg.EBPFNetworkMetrics && cfg.Rootless {
+		return errors.New("eBPF network metrics aren't supported in rootless mode")
+	}
+
+	if cfg.ImagePolicyFile != "" {
+		agentConfig.ImagePolicy, err = cluster.LoadImagePolicy(cfg.ImagePolicyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Snapshots.Bucket != "" {
+		store, err := snapshotStore(cfg)
+		if err != nil {
+			return err
+		}
+
+		agentConfig.VolumeSnapshots = &cluster.VolumeSnapshotConfig{
+			Store:     store,
+			Interval:  cfg.Snapshots.Interval,
+			Retention: snapshotRetention(cfg),
+		}
+	}
+
+	if cfg.SidecarPolicyFile != "" {
+		if cfg.Rootless {
+			return errors.New("sidecars can't be injected in rootless mode, which doesn't support pods")
+		}
+
+		agentConfig.SidecarPolicy, err = cluster.LoadSidecarPolicy(cfg.SidecarPolicyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.MetricsPush.Protocol != "" {
+		agentConfig.MetricsPush = &pushmetrics.Config{
+			Protocol:    cfg.MetricsPush.Protocol,
+			Endpoint:    cfg.MetricsPush.Endpoint,
+			Headers:     cfg.MetricsPush.Headers,
+			Interval:    cfg.MetricsPush.Interval,
+			BatchSize:   cfg.MetricsPush.BatchSize,
+			Labels:      cfg.MetricsPush.Labels,
+			NodeLabel:   cfg.MetricsPush.NodeLabel,
+			TenantLabel: cfg.MetricsPush.TenantLabel,
+		}
+	}
+
+	if cfg.GrpcTLSCert != "" && cfg.GrpcTLSKey != "" {
+		serverConfig.TLS, err = cluster.NewCertSource(logger, cfg.GrpcTLSCert, cfg.GrpcTLSKey, cfg.GrpcTLSCA, cfg.SpiffeTrustDomain)
+		if err != nil {
+			return err
+		}
+
+		go serverConfig.TLS.Watch(context.Background())
+
+		// Nodes present their own certificate when
+		// forwarding requests to each other
+		agentConfig.GrpcClientTLS = serverConfig.TLS.ClientTLSConfig()
+	}
+
+	agent, err := cluster.NewAgent(logger, agentConfig, repo)
+	if err != nil {
+		return err
+	}
+
+	// An agent restarted by an upgrade rejoins the cluster it was part of
+	if rejoin := os.Getenv(cluster.UpgradeRejoinEnv); rejoin != "" && len(args) == 0 {
+		args = []string{rejoin}
+	}
+
+	os.Unsetenv(cluster.UpgradeRejoinEnv)
+
+	if len(args) > 0 {
+		if err := agent.Join(args[0]); err != nil {
+			return err
+		}
+	} else if err := agent.MarkReady(); err != nil {
+		return err
+	}
+
+	grpcServer, err := cluster.NewServer(logger, agent, serverConfig)
+	if err != nil {
+		return err
+	}
+
+	grpcListener, err := net.Listen("tcp", cfg.GrpcBindAddr)
+	if err != nil {
+		return err
+	}
+
+	go notifyReady(context.Background(), repo)
+
+	quitWg := sync.WaitGroup{}
+	quitWg.Add(2)
+
+	if cfg.GatewayBindAddr != "" {
+		gateway := cluster.NewGateway(logger, agent, &cluster.GatewayConfig{
+			AuthToken:   cfg.GrpcAuthToken,
+			CORSOrigins: cfg.GatewayCORSOrigins,
+		})
+
+		quitWg.Add(1)
+		go func() {
+			defer quitWg.Done()
+			if err := cluster.ServeGateway(context.Background(), cfg.GatewayBindAddr, gateway, serverConfig.TLS); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
+	go func() {
+		defer quitWg.Done()
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			panic(err)
+		}
+	}()
+
+	go func() {
+		defer quitWg.Done()
+		agent.Handler()
+	}()
+
+	quitWg.Wait()
+
+	return nil
+}
+
+func ListCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List running VMs",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			tasks, err := repo.GetTasks(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			for _, task := range tasks {
+				log.Infof("Task %s, Container %s\n", task.GetID(), task.GetContainerID())
+			}
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+
+	return cmd
+}
+
+func SpawnCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "spawn",
+		Short: "Spawn a VM under Hypercore",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			hacPath, err := filepath.Abs(cfg.HACFile)
+			if err != nil {
+				return err
+			}
+
+			hacContents, err := os.ReadFile(hacPath)
+			if err != nil {
+				return err
+			}
+
+			vmUUID := uuid.NewString()
+			hacConfig := HacConfig{}
+
+			if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+				return err
+			}
+
+			log.Infof("Creating VM '%s' with config %+v\n", vmUUID, hacConfig)
+
+			var id string
+
+			switch cfg.DefaultVMProvider {
+			case "runc":
+				id, err = repo.CreateContainer(cmd.Context(), containerd.CreateContainerOpts{
+					ImageRef:    hacConfig.Hardware.Ref,
+					Snapshotter: "",
+					Runtime: struct {
+						Name    string
+						Options interface{}
+					}{
+						Name: "io.containerd.runc.v2",
+					},
+					CioCreator: cio.NewCreator(cio.WithStdio),
+				})
+			case "firecracker":
+				fallthrough
+			case "cloudhypervisor":
+				id, err = repo.CreateContainer(cmd.Context(), containerd.CreateContainerOpts{
+					ImageRef:    hacConfig.Hardware.Ref,
+					Snapshotter: "devmapper",
+					Runtime: struct {
+						Name    string
+						Options interface{}
+					}{
+						Name:    "hypercore.example",
+						Options: hacVMSpec(cfg.DefaultVMProvider, &hacConfig),
+					},
+					CioCreator: containerd.BufferedIO(int(cfg.StdioBufferSize)),
+				})
+			case "docker":
+				client, err := NewDockerClient()
+				if err != nil {
+					return err
+				}
+
+				id, err = client.Start(cmd.Context(), hacConfig.Hardware.Ref)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			log.Infof("ID: %s\n", id)
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+
+	return cmd
+}
+
+// hacVMSpec returns the spec of the VMs described by the HAC file
+func hacVMSpec(provider string, hacConfig *HacConfig) *models.MicroVMSpec {
+	return &models.MicroVMSpec{
+		Provider:       provider,
+		VCPU:           hacConfig.Hardware.Cores,
+		MemoryInMb:     hacConfig.Hardware.Memory,
+		HostNetDev:     hacConfig.Hardware.Interface,
+		Kernel:         hacConfig.Hardware.Kernel,
+		RootfsPath:     hacConfig.Hardware.Drive,
+		Arch:           hacConfig.Hardware.Arch,
+		Disks:          hacDisks(hacConfig.Hardware.Disks),
+		DisableEntropy: hacConfig.Hardware.DisableEntropy,
+		PauseVM:        hacConfig.Hardware.PauseVM,
+		CPUTemplate:    hacConfig.Hardware.CPUTemplate,
+		Confidential:   hacConfig.Hardware.Confidential,
+		Firmware:       hacConfig.Hardware.Firmware,
+		NestedVirt:     hacConfig.Hardware.NestedVirt,
+		DNS:            hacDNS(hacConfig.Hardware.DNS),
+		ExtraHosts:     hacExtraHosts(hacConfig.Hardware.ExtraHosts),
+	}
+}
+
+func hacDNS(dns *HacDNS) *models.DNSConfig {
+	if dns == nil {
+		return nil
+	}
+
+	return &models.DNSConfig{Nameservers: dns.Nameservers, Searches: dns.Searches, Ndots: dns.Ndots}
+}
+
+func hacExtraHosts(entries []HacHostEntry) []models.HostEntry {
+	hosts := make([]models.HostEntry, 0, len(entries))
+	for _, entry := range entries {
+		hosts = append(hosts, models.HostEntry{IP: entry.IP, Hostnames: entry.Hostnames})
+	}
+
+	return hosts
+}
+
+func hacDisks(entries []HacDisk) []models.Disk {
+	disks := make([]models.Disk, 0, len(entries))
+
+	for _, entry := range entries {
+		disks = append(disks, models.Disk(entry))
+	}
+
+	return disks
+}
+
+func ServeCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the VMService gRPC API to manage the microVMs of this node",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logger := log.New()
+
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			if len(cfg.Pool.Shapes) > 0 {
+				manager, err := poolManager(logger, cfg)
+				if err != nil {
+					return err
+				}
+
+				go func() {
+					if err := manager.Run(cmd.Context()); err != nil {
+						logger.WithError(err).Error("warm pool stopped")
+					}
+				}()
+			}
+
+			// MicroVMs created by releases predating the owner label
+			adopted, err := repo.AdoptContainers(cmd.Context(), fmt.Sprintf("labels.%q", microvm.SpecLabel))
+			if err != nil {
+				return err
+			}
+
+			for _, id := range adopted {
+				logger.Infof("Adopted microVM %s created by an older release", id)
+			}
+
+			listener, err := net.Listen("tcp", cfg.VMServiceBindAddr)
+			if err != nil {
+				return err
+			}
+
+			logger.Infof("Serving VMService at %s", cfg.VMServiceBindAddr)
+
+			return microvm.NewServer(logger, repo).Serve(listener)
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddServeFlags(cmd, cfg)
+
+	return cmd
+}
+
+func poolManager(logger *log.Logger, cfg *Config) (*pool.Manager, error) {
+	hacContents, err := os.ReadFile(cfg.HACFile)
+	if err != nil {
+		return nil, err
+	}
+
+	hacConfig := HacConfig{}
+	if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+		return nil, err
+	}
+
+	shapes := make([]pool.Shape, 0, len(cfg.Pool.Shapes))
+
+	for _, value := range cfg.Pool.Shapes {
+		shape, err := pool.ParseShape(value)
+		if err != nil {
+			return nil, err
+		}
+
+		shapes = append(shapes, shape)
+	}
+
+	return pool.NewManager(logger, &pool.Config{
+		Dir:            defaults.PoolDir,
+		StateRoot:      defaults.StateRootDir + "/pool",
+		FirecrackerBin: "/usr/bin/firecracker",
+		Kernel:         hacConfig.Hardware.Kernel,
+		RootfsPath:     hacConfig.Hardware.Drive,
+		Shapes:         shapes,
+		AgentPort:      shim.VSockPort,
+		RefreshPeriod:  cfg.Pool.RefreshPeriod,
+	}), nil
+}
+
+func PoolCommand(_ *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pool",
+		Short: "Show the warm pool hit ratio and the task creation latencies",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			stats, err := pool.ReadStats(defaults.PoolDir)
+			if err != nil {
+				return err
+			}
+
+			average := func(total float64, count uint64) time.Duration {
+				if count == 0 {
+					return 0
+				}
+
+				return time.Duration(total / float64(count) * float64(time.Second))
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Hit ratio:\t%.2f\n", stats.HitRatio())
+			fmt.Fprintf(out, "Restores:\t%d (avg %s)\n", stats.Restores, average(stats.RestoreSeconds, stats.Restores))
+			fmt.Fprintf(out, "Cold boots:\t%d (avg %s)\n", stats.ColdBoots, average(stats.ColdBootSeconds, stats.ColdBoots))
+			fmt.Fprintf(out, "Builds:\t\t%d (avg %s, %d failed)\n", stats.Builds, average(stats.BuildSeconds, stats.Builds), stats.BuildFailures)
+
+			phases := make([]string, 0, len(stats.Phases))
+			for phase := range stats.Phases {
+				phases = append(phases, phase)
+			}
+			sort.Strings(phases)
+
+			for _, phase := range phases {
+				phaseStats := stats.Phases[phase]
+				fmt.Fprintf(out, "Phase %s:\tavg %s, max %s, last %s\n", phase,
+					average(phaseStats.Seconds, phaseStats.Count),
+					average(phaseStats.MaxSeconds, 1),
+					average(phaseStats.LastSeconds, 1))
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func StopCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "stop a VM",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+			if err != nil {
+				return err
+			}
+
+			code, err := repo.DeleteContainer(cmd.Context(), os.Args[2], 0)
+			if err != nil {
+				return err
+			}
+
+			os.Exit(int(code))
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+
+	return cmd
+}
@@ -1,20 +1,320 @@
 package hypercore
 
+import "time"
+
 type Config struct {
 	CtrSocketPath        string
 	CtrNamespace         string
+	Rootless             bool
+	RootlessNetwork      string
+	Bridge               string
 	DefaultVMProvider    string
 	HACFile              string
 	RespawnOnNodeFailure bool
+	OOMMemoryCeiling     uint32
+	PrometheusURL        string
+	MaxConcurrentPulls   int
+	MaxPullBandwidth     int64
+	MaxConcurrentCreates int
+	MaxQueuedSpawns      int
+	SpawnRateLimit       float64
+	SpawnRateBurst       int
+	BroadcastPeriod      time.Duration
+	GossipInterval       time.Duration
+	ProbeInterval        time.Duration
+	UserEventSizeLimit   int
+	QueueDepthWarning    int
+	MaxQueueDepth        int
+	PriceCPUHour         float64
+	PriceGBHour          float64
+	PriceGBEgress        float64
+	EgressPolicyFile     string
+	ImagePolicyFile      string
+	SidecarPolicyFile    string
+	EgressProxyAddr      string
+	MetadataAddr         string
+	MetadataTokenTTL     time.Duration
+	SigningKeyRotation   time.Duration
+	TrackConnections     bool
+	EBPFNetworkMetrics   bool
+	ClusterDNS           string
+	WorkloadMetrics      bool
+	WarmImages           bool
+	WorkloadLogMaxSize   int64
+	StdioBufferSize      int64
 	ClusterBindAddr      string
 	ClusterBaseURL       string
 	ClusterTLSCert       string
 	ClusterTLSKey        string
+	ClusterTLSCA         string
+	SpiffeTrustDomain    string
 	GrpcBindAddr         string
+	GrpcAuthToken        string
+	GrpcTLSCert          string
+	GrpcTLSKey           string
+	GrpcTLSCA            string
+	GrpcCompression      string
+	GrpcMaxMessageSize   int
+	GatewayBindAddr      string
+	VMServiceBindAddr    string
+	GatewayCORSOrigins   []string
 	ClusterSpawn         struct {
+		CPU             int
+		Memory          int
+		MaxCPU          int
+		MaxMemory       int
+		Replicas        int
+		MaxReplicas     int
+		TargetRPS       float64
+		TargetLatency   int
+		PrometheusQuery string
+		ImageRef        string
+		Ports           string
+		Ingress         []string
+		MetricsEndpoint string
+		AccessLog       bool
+		SessionAffinity string
+		Name            string
+		Labels          []string
+		Annotations     []string
+		Tenant          string
+		IdempotencyKey  string
+		Explain         bool
+		Plan            bool
+		Env             []string
+		ReadinessProbe  string
+		LivenessProbe   string
+		RestartPolicy   string
+		StopGracePeriod time.Duration
+		PreStop         string
+		InitSteps       []string
+		InitTimeout     time.Duration
+		Containers      []string
+		DNS             []string
+		DNSSearches     []string
+		DNSNdots        int
+		ExtraHosts      []string
+		Volumes         []string
+		VolumeSnapshots map[string]string
+		PendingTTL      time.Duration
+		MemoryHigh      int
+		IOReadBPS       int64
+		IOWriteBPS      int64
+		IOReadIOPS      uint64
+		IOWriteIOPS     uint64
+	}
+	ClusterUpdate struct {
 		CPU      int
 		Memory   int
 		ImageRef string
-		Ports    string
+		Env      []string
+		Revision int
+	}
+	ClusterCanary struct {
+		Replicas     int
+		Weight       int
+		MaxErrorRate float64
+		Analysis     time.Duration
+		MinRequests  int
+	}
+	ClusterTraffic struct {
+		Revision int
+		Weight   int
+		Clear    bool
+	}
+	ClusterStop struct {
+		Tenant        string
+		LabelSelector string
+	}
+	ClusterRestart struct {
+		Tenant string
+	}
+	ClusterBulk struct {
+		Tenant        string
+		LabelSelector string
+		Parallelism   int
+		MaxPerNode    int
+	}
+	ClusterLeave struct {
+		Force bool
+	}
+	ClusterBackup struct {
+		Output string
+	}
+	ClusterRestore struct {
+		Force bool
+	}
+	ClusterUpgrade struct {
+		BinaryURL string
+		SHA256    string
+		Timeout   time.Duration
+	}
+	ClusterAdopt struct {
+		Node   string
+		Tenant string
+		Name   string
+		CPU    int
+		Memory uint32
+	}
+	ClusterList struct {
+		Node          string
+		Tenant        string
+		LabelSelector string
+		Status        string
+		Limit         int
+		PageToken     string
+	}
+	ClusterLogs struct {
+		TailBytes     int
+		Tenant        string
+		LabelSelector string
+	}
+	ClusterMetrics struct {
+		Since  time.Duration
+		Format string
+	}
+	ClusterDescribe struct {
+		Tenant string
+	}
+	ClusterTop struct {
+		Tenant string
+	}
+	ClusterApply struct {
+		Spread bool
+	}
+	ClusterUsage struct {
+		Tenant string
+	}
+	ClusterCache struct {
+		Node     string
+		ImageRef string
+	}
+	ClusterTopology struct {
+		Tenant string
+		Format string
+	}
+	ClusterSnapshot struct {
+		Tenant string
+	}
+	ClusterSnapshots struct {
+		Volume string
+		Tenant string
+	}
+	Snapshots struct {
+		Endpoint string
+		Region   string
+		Bucket   string
+		Prefix   string
+		Interval time.Duration
+		KeepLast int
+		MaxAge   time.Duration
+	}
+	ClusterFaults struct {
+		DropUserEventsPercent float64
+		QueryDelay            time.Duration
+		KillWorkloadsPercent  float64
+	}
+	Pool struct {
+		Shapes        []string
+		RefreshPeriod time.Duration
+	}
+	RuntimeClass struct {
+		Name       string
+		ConfigPath string
+	}
+	ClusterDaemon struct {
+		Install        bool
+		UnitPath       string
+		EnvFile        string
+		ContainerdUnit string
+	}
+	InstallRuntime struct {
+		ContainerdConfig string
+		ContainerdUnit   string
+		ShimDir          string
+		VerifyImage      string
+	}
+	DebugCollect struct {
+		OutDir string
+		Tenant string
+	}
+	DebugAttest struct {
+		ReportData string
+		Output     string
+	}
+	DebugDescribeVM struct {
+		Raw bool
+	}
+	BenchBoot struct {
+		Cycles int
+		Warmup int
+		Output string
+	}
+	BenchIO struct {
+		Duration   time.Duration
+		Pings      int
+		PingSize   int
+		ProfileDir string
+		Output     string
+	}
+	DebugProfile struct {
+		Kind     string
+		Duration time.Duration
+		Output   string
+	}
+	Dev struct {
+		RegistryAddr     string
+		RegistryDir      string
+		SampleImages     []string
+		SimulatedLatency time.Duration
+	}
+	MetricsPush struct {
+		Protocol    string
+		Endpoint    string
+		Interval    time.Duration
+		BatchSize   int
+		Headers     map[string]string
+		Labels      map[string]string
+		NodeLabel   string
+		TenantLabel string
+	}
+	Alerts struct {
+		Webhooks      []string
+		SlackWebhooks []string
+		DedupWindow   time.Duration
+		RateLimit     int
+	}
+	EndpointPublish struct {
+		ZoneFile          string
+		Zone              string
+		HAProxyRuntimeAPI string
+		Webhooks          []string
+	}
+	Maintenance struct {
+		Windows  []string
+		Duration time.Duration
+		Lead     time.Duration
+		Drain    bool
+	}
+	IssueCert struct {
+		CACert  string
+		CAKey   string
+		OutCert string
+		OutKey  string
+		SANs    []string
+		TTL     time.Duration
+	}
+	ImageBuildAgent struct {
+		BaseRootfs  string
+		Agent       string
+		AgentArgs   []string
+		Runc        string
+		ModulesDir  string
+		Modules     []string
+		Hostname    string
+		TemplateDir string
+		Vars        map[string]string
+		Format      string
+		Output      string
 	}
 }
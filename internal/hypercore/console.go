@@ -0,0 +1,106 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	pb "vistara-node/pkg/proto/shimdebug"
+
+	"github.com/containerd/console"
+	"github.com/spf13/cobra"
+)
+
+// Ctrl-], detaches from the console like telnet
+const consoleDetachKey = 0x1d
+
+func ConsoleCommand(_ *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "console TASK-ID",
+		Short: "attach to the serial console of a VM, Ctrl-] detaches",
+		Long: `Attaches the terminal in raw mode to the serial console of the VM of a
+task running on this node. Unlike attach, it doesn't go through the guest
+agent, so it works with kernels failing before the agent starts`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := dialShim(args[0])
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			stream, err := pb.NewShimDebugServiceClient(conn).AttachConsole(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to attach to the console: %w", err)
+			}
+
+			if current, err := console.ConsoleFromFile(os.Stdin); err == nil {
+				if err := current.SetRaw(); err != nil {
+					return fmt.Errorf("failed to set the terminal in raw mode: %w", err)
+				}
+				defer current.Reset() //nolint:errcheck
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Attached to the console of %s, Ctrl-] detaches\r\n", args[0])
+
+			go forwardConsoleInput(stream)
+
+			for {
+				out, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+
+				if _, err := cmd.OutOrStdout().Write(out.GetData()); err != nil {
+					return err
+				}
+			}
+		},
+	}
+
+	return cmd
+}
+
+// forwardConsoleInput sends stdin to the console until the detach key
+func forwardConsoleInput(stream pb.ShimDebugService_AttachConsoleClient) {
+	defer stream.CloseSend() //nolint:errcheck
+
+	buf := make([]byte, 1024)
+
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+			detach := false
+
+			if i := bytes.IndexByte(data, consoleDetachKey); i >= 0 {
+				data, detach = data[:i], true
+			}
+
+			if len(data) > 0 {
+				if err := stream.Send(&pb.ConsoleInput{Data: bytes.Clone(data)}); err != nil {
+					return
+				}
+			}
+
+			if detach {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
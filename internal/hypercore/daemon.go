@@ -0,0 +1,183 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Arguments of the cluster command, set in the environment file
+const clusterArgsEnv = "HYPERCORE_CLUSTER_ARGS"
+
+// The agent bind mounts network namespaces under /run/netns for containerd
+// to use, so the options giving the service its own mount namespace
+// (ProtectSystem, ProtectHome, PrivateTmp...) can't be used
+const systemdUnitTemplate = `[Unit]
+Description=Hypercore cluster agent
+After=network-online.target %[3]s
+Wants=network-online.target
+Requires=%[3]s
+
+[Service]
+Type=notify
+NotifyAccess=main
+EnvironmentFile=-%[2]s
+ExecStart=%[1]s cluster $%[4]s
+Restart=always
+RestartSec=5s
+TimeoutStartSec=5min
+LimitNOFILE=1048576
+UMask=0027
+NoNewPrivileges=yes
+LockPersonality=yes
+MemoryDenyWriteExecute=yes
+RestrictRealtime=yes
+RestrictSUIDSGID=yes
+ProtectClock=yes
+ProtectHostname=yes
+KeyringMode=private
+SystemCallArchitectures=native
+
+[Install]
+WantedBy=multi-user.target
+`
+
+func ClusterDaemonCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon [-- CLUSTER ARGS]",
+		Short: "render the systemd unit running the cluster agent",
+		Long: `Prints the systemd unit running this binary as the cluster agent, the
+arguments after -- are passed to the cluster command through the environment
+file. With --install the unit and the environment file are written, and the
+unit is enabled and started`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the hypercore binary: %w", err)
+			}
+
+			unit := fmt.Sprintf(systemdUnitTemplate, executable, cfg.ClusterDaemon.EnvFile, cfg.ClusterDaemon.ContainerdUnit, clusterArgsEnv)
+
+			if !cfg.ClusterDaemon.Install {
+				fmt.Fprint(cmd.OutOrStdout(), unit)
+
+				return nil
+			}
+
+			return installDaemon(cfg, unit, args)
+		},
+	}
+
+	AddClusterDaemonFlags(cmd, cfg)
+
+	return cmd
+}
+
+func installDaemon(cfg *Config, unit string, args []string) error {
+	if err := os.WriteFile(cfg.ClusterDaemon.UnitPath, []byte(unit), defaults.DataFilePerm); err != nil {
+		return fmt.Errorf("failed to write unit %s: %w", cfg.ClusterDaemon.UnitPath, err)
+	}
+
+	log.Infof("Wrote %s", cfg.ClusterDaemon.UnitPath)
+
+	// Keep the arguments of a previous install unless new ones are given
+	_, err := os.Stat(cfg.ClusterDaemon.EnvFile)
+	if len(args) > 0 || errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(cfg.ClusterDaemon.EnvFile), defaults.DataDirPerm); err != nil {
+			return err
+		}
+
+		// May hold the gRPC auth token
+		env := fmt.Sprintf("%s=%s\n", clusterArgsEnv, strconv.Quote(strings.Join(args, " ")))
+		if err := os.WriteFile(cfg.ClusterDaemon.EnvFile, []byte(env), 0o600); err != nil {
+			return fmt.Errorf("failed to write environment file %s: %w", cfg.ClusterDaemon.EnvFile, err)
+		}
+
+		log.Infof("Wrote %s", cfg.ClusterDaemon.EnvFile)
+	}
+
+	name := filepath.Base(cfg.ClusterDaemon.UnitPath)
+
+	for _, systemctlArgs := range [][]string{{"daemon-reload"}, {"enable", "--now", name}} {
+		if output, err := exec.Command("systemctl", systemctlArgs...).CombinedOutput(); err != nil {
+			return fmt.Errorf("systemctl %s failed: %w: %s", strings.Join(systemctlArgs, " "), err, output)
+		}
+	}
+
+	log.Infof("Enabled and started %s", name)
+
+	return nil
+}
+
+// sdNotify sends a state to the service manager, it does nothing if the
+// agent isn't run by systemd with Type=notify
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// Abstract socket
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify service manager: %w", err)
+	}
+
+	return nil
+}
+
+// notifyReady waits for containerd to be reachable before telling the
+// service manager the agent is ready
+func notifyReady(ctx context.Context, repo *containerd.Repo) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		_, err := repo.GetTasks(ctx)
+		if err == nil {
+			break
+		}
+
+		if notifyErr := sdNotify("STATUS=waiting for containerd: " + err.Error()); notifyErr != nil {
+			log.WithError(notifyErr).Warn("failed to notify service manager")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	if err := sdNotify("READY=1\nSTATUS=serving"); err != nil {
+		log.WithError(err).Warn("failed to notify service manager")
+	}
+}
@@ -0,0 +1,99 @@
+package hypercore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vistara-node/pkg/client"
+	"vistara-node/pkg/defaults"
+	clusterpb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func DebugCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "inspect the internals of the local hypercore components",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return c.Help()
+		},
+	}
+
+	cmd.AddCommand(DebugCollectCommand(cfg))
+	cmd.AddCommand(debugNodeCommands(cfg)...)
+
+	return cmd
+}
+
+func DebugCollectCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect WORKLOAD",
+		Short: "fetch the crash bundles of a workload from every node of the cluster",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.DebugCollect.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.CollectCrash(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, node := range resp.GetUnreachableNodes() {
+				log.Warnf("Node %s is unreachable, its crash bundles are missing", node)
+			}
+
+			if len(resp.GetBundles()) == 0 {
+				log.Infof("No crash bundles found for %s", args[0])
+
+				return nil
+			}
+
+			for _, bundle := range resp.GetBundles() {
+				dir, err := writeCrashBundle(cfg.DebugCollect.OutDir, bundle)
+				if err != nil {
+					return err
+				}
+
+				log.Infof("Crash at %s on node %s written to %s",
+					time.Unix(bundle.GetExitedUnixTime(), 0).Format(time.RFC3339), bundle.GetNode(), dir)
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddDebugCollectFlags(cmd, cfg)
+
+	return cmd
+}
+
+func writeCrashBundle(outDir string, bundle *clusterpb.CrashBundle) (string, error) {
+	dir := filepath.Join(outDir, fmt.Sprintf("%s-%s", filepath.Base(bundle.GetNode()), filepath.Base(bundle.GetPath())))
+
+	if err := os.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	for _, file := range bundle.GetFiles() {
+		path := filepath.Join(dir, filepath.Base(file.GetName()))
+		if err := os.WriteFile(path, file.GetContent(), defaults.DataFilePerm); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,286 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/shimdebug"
+	"vistara-node/pkg/shim"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func DebugShimCommand(_ *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shim TASK-ID",
+		Short: "print the VM config, vsock ports, IO streams and recent errors of the shim of a task",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := dialShim(args[0])
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			resp, err := pb.NewShimDebugServiceClient(conn).Inspect(cmd.Context(), &pb.InspectRequest{})
+			if err != nil {
+				return fmt.Errorf("failed to inspect shim: %w", err)
+			}
+
+			printShimState(resp)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// dialShim connects to the introspection endpoint of the shim of the task
+func dialShim(taskID string) (*grpc.ClientConn, error) {
+	path := shim.DebugSocketPath(taskID)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no shim debug socket for task %s: %w", taskID, err)
+	}
+
+	conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", path, err)
+	}
+
+	return conn, nil
+}
+
+func printShimState(resp *pb.InspectResponse) {
+	log.Infof("Shim %s (pid %d), agent on vsock port %d", resp.GetShimId(), resp.GetShimPid(), resp.GetAgentPort())
+
+	if resp.GetSandbox() {
+		log.Infof("Sandbox task, no VM")
+	}
+
+	if resp.GetBootAgentMs() != 0 {
+		log.Infof("Agent handshake %dms after the task creation, task started after %dms", resp.GetBootAgentMs(), resp.GetBootStartMs())
+	}
+
+	if resp.GetAgentProtocolVersion() != 0 {
+		log.Infof("Agent protocol version %d, release %q", resp.GetAgentProtocolVersion(), resp.GetAgentVersion())
+	}
+
+	if vm := resp.GetVm(); vm != nil {
+		log.Infof("VM %s: %s %s, %d vCPU, %d MB, restored from pool: %t",
+			vm.GetId(), vm.GetProvider(), vm.GetArch(), vm.GetVcpu(), vm.GetMemoryMb(), vm.GetRestored())
+		log.Infof("  kernel %s, rootfs %s, image %s", vm.GetKernel(), vm.GetRootfsPath(), vm.GetImagePath())
+		log.Infof("  net dev %s, guest MAC %s", vm.GetHostNetDev(), vm.GetGuestMac())
+		log.Infof("  vsock %s, console %s (socket %s)", vm.GetVsockPath(), vm.GetConsolePath(), vm.GetConsoleSocketPath())
+
+		if vm.GetConfidential() != "" {
+			log.Infof("  confidential %s", vm.GetConfidential())
+		}
+	}
+
+	for _, ports := range resp.GetPorts() {
+		log.Infof("Ports of %s (exec %q): stdin %d, stdout %d, stderr %d",
+			ports.GetTaskId(), ports.GetExecId(), ports.GetStdinPort(), ports.GetStdoutPort(), ports.GetStderrPort())
+	}
+
+	for _, stream := range resp.GetStreams() {
+		log.Infof("IO of %s (exec %q, terminal %t): stdin %s, stdout %s, stderr %s",
+			stream.GetTaskId(), stream.GetExecId(), stream.GetTerminal(), stream.GetStdin(), stream.GetStdout(), stream.GetStderr())
+	}
+
+	if balloon := resp.GetBalloon(); balloon.GetConfigured() {
+		log.Infof("Balloon: %d MiB, deflate on OOM %t, stats every %ds",
+			balloon.GetAmountMib(), balloon.GetDeflateOnOom(), balloon.GetStatsPollingIntervalS())
+	} else if resp.GetVm() != nil {
+		log.Infof("Balloon: none")
+	}
+
+	for _, shimErr := range resp.GetRecentErrors() {
+		log.Infof("Error at %s: %s", time.Unix(shimErr.GetTimestamp(), 0).Format(time.RFC3339), shimErr.GetMessage())
+	}
+}
+
+func DebugAttestCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attest TASK-ID",
+		Short: "fetch an attestation report of the confidential VM of a task, with the inputs of its measured launch",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reportData, err := hex.DecodeString(cfg.DebugAttest.ReportData)
+			if err != nil {
+				return fmt.Errorf("invalid report data: %w", err)
+			}
+
+			if len(reportData) == 0 {
+				reportData = make([]byte, 64)
+				if _, err := rand.Read(reportData); err != nil {
+					return err
+				}
+			}
+
+			conn, err := dialShim(args[0])
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			resp, err := pb.NewShimDebugServiceClient(conn).AttestationReport(cmd.Context(), &pb.AttestationReportRequest{ReportData: reportData})
+			if err != nil {
+				return fmt.Errorf("failed to fetch attestation report: %w", err)
+			}
+
+			output := cfg.DebugAttest.Output
+			if output == "" {
+				output = args[0] + ".report"
+			}
+
+			if err := os.WriteFile(output, resp.GetReport(), defaults.DataFilePerm); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+
+			launch := resp.GetLaunch()
+			log.Infof("%s report of %d bytes written to %s, report data %s", resp.GetTechnology(), len(resp.GetReport()), output, hex.EncodeToString(reportData))
+			log.Infof("Launched with %d vCPU from firmware %s (sha256 %s)", launch.GetVcpu(), launch.GetFirmwarePath(), launch.GetFirmwareSha256())
+			log.Infof("  kernel %s (sha256 %s)", launch.GetKernelPath(), launch.GetKernelSha256())
+			log.Infof("  cmdline %q", launch.GetCmdline())
+
+			return nil
+		},
+	}
+
+	AddDebugAttestFlags(cmd, cfg)
+
+	return cmd
+}
+
+func DebugDescribeVMCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe-vm TASK-ID",
+		Short: "print the configuration the hypervisor runs the VM of a task with, and how it drifted from the requested spec",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := dialShim(args[0])
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			resp, err := pb.NewShimDebugServiceClient(conn).DescribeVM(cmd.Context(), &pb.DescribeVMRequest{})
+			if err != nil {
+				return fmt.Errorf("failed to describe VM: %w", err)
+			}
+
+			printVMDescription(resp, cfg.DebugDescribeVM.Raw)
+
+			return nil
+		},
+	}
+
+	AddDebugDescribeVMFlags(cmd, cfg)
+
+	return cmd
+}
+
+func printVMDescription(resp *pb.DescribeVMResponse, raw bool) {
+	log.Infof("%s VM %s: %d vCPU, %d MiB, balloon %d MiB", resp.GetProvider(), resp.GetState(), resp.GetVcpu(), resp.GetMemoryMib(), resp.GetBalloonMib())
+
+	for _, device := range resp.GetDevices() {
+		log.Infof("  %s %s: %s, read-only %t", device.GetKind(), device.GetId(), device.GetPath(), device.GetReadOnly())
+
+		if device.GetGuestMac() != "" {
+			log.Infof("    guest MAC %s", device.GetGuestMac())
+		}
+
+		for _, limit := range device.GetRateLimits() {
+			log.Infof("    %s limit: size %d, burst %d, refill every %dms",
+				strings.TrimSpace(limit.GetDirection()+" "+limit.GetKind()), limit.GetSize(), limit.GetOneTimeBurst(), limit.GetRefillTimeMs())
+		}
+	}
+
+	if len(resp.GetDrift()) == 0 {
+		log.Infof("No drift from the requested spec")
+	}
+
+	for _, drift := range resp.GetDrift() {
+		log.Warnf("Drift: %s", drift)
+	}
+
+	if raw {
+		log.Infof("Config: %s", resp.GetConfigJson())
+		log.Infof("Metrics: %s", resp.GetMetricsJson())
+	}
+}
+
+func DebugProfileCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile TASK-ID",
+		Short: "write a pprof profile of the shim of a task, e.g. to profile its IO proxy",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := dialShim(args[0])
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if cfg.DebugProfile.Kind == "cpu" {
+				log.Infof("Profiling the CPU of the shim for %s", cfg.DebugProfile.Duration)
+			}
+
+			resp, err := pb.NewShimDebugServiceClient(conn).Profile(cmd.Context(), &pb.ProfileRequest{
+				Kind:            cfg.DebugProfile.Kind,
+				DurationSeconds: int64(cfg.DebugProfile.Duration / time.Second),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to profile shim: %w", err)
+			}
+
+			output := cfg.DebugProfile.Output
+			if output == "" {
+				output = args[0] + "." + cfg.DebugProfile.Kind + ".pprof"
+			}
+
+			if err := os.WriteFile(output, resp.GetProfile(), defaults.DataFilePerm); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+
+			log.Infof("Profile written to %s, open it with go tool pprof", output)
+
+			return nil
+		},
+	}
+
+	AddDebugProfileFlags(cmd, cfg)
+
+	return cmd
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/registry"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func DevCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "run a hypercore cluster for development",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return c.Help()
+		},
+	}
+
+	cmd.AddCommand(DevUpCommand(cfg))
+
+	return cmd
+}
+
+func DevUpCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "start a single node cluster with an embedded registry",
+		Long: `Starts a single node cluster listening on localhost only, without
+authentication, its workloads attached to a local bridge. Images pushed to
+the embedded registry can be spawned as localhost:PORT/NAME:TAG, and the
+sample images are pulled before the node starts so the first spawns start at
+once. Each GRPC request is delayed by the simulated latency, to surface the
+timeouts and retries of clients written against a node reached over a WAN`,
+		Args: cobra.NoArgs,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			reg, err := registry.New(log.StandardLogger(), cfg.Dev.RegistryDir)
+			if err != nil {
+				return err
+			}
+
+			go func() {
+				if err := reg.Serve(context.Background(), cfg.Dev.RegistryAddr); err != nil {
+					log.WithError(err).Error("embedded registry stopped")
+				}
+			}()
+
+			pullSampleImages(cmd.Context(), cfg)
+
+			_, registryPort, _ := net.SplitHostPort(cfg.Dev.RegistryAddr)
+
+			fmt.Fprintf(cmd.OutOrStdout(), `Development node starting:
+  GRPC       %[1]s
+  gateway    %[2]s
+  registry   %[3]s
+
+Push an image and spawn it with:
+  docker tag IMAGE localhost:%[4]s/app:latest && docker push localhost:%[4]s/app:latest
+  %[5]s cluster spawn --grpc-bind-addr %[1]s --image-ref localhost:%[4]s/app:latest --ports 80
+`, cfg.GrpcBindAddr, cfg.GatewayBindAddr, cfg.Dev.RegistryAddr, registryPort, cmd.Root().Name())
+
+			return runClusterAgent(cfg, nil)
+		},
+	}
+
+	AddDevUpFlags(cmd, cfg)
+
+	return cmd
+}
+
+// pullSampleImages pulls the sample images ahead of the first spawns, the
+// node still starts if they can't be pulled, e.g. while offline
+func pullSampleImages(ctx context.Context, cfg *Config) {
+	if len(cfg.Dev.SampleImages) == 0 {
+		return
+	}
+
+	repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerCluster))
+	if err != nil {
+		log.WithError(err).Warn("Not pulling the sample images")
+
+		return
+	}
+
+	for _, ref := range cfg.Dev.SampleImages {
+		log.Infof("Pulling sample image %s", ref)
+
+		if err := repo.PullImage(ctx, ref); err != nil {
+			log.WithError(err).Warnf("failed to pull sample image %s", ref)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package hypercore
 
 import (
 	"fmt"
+	"math"
+	"time"
 	"vistara-node/pkg/defaults"
-	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/resource"
+	"vistara-node/pkg/snapshot"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -14,56 +17,458 @@ const (
 	hacFileFlag              = "hac"
 	containerdSocketFlag     = "containerd-socket"
 	containerdNamespace      = "containerd-ns"
+	rootlessFlag             = "rootless"
+	rootlessNetworkFlag      = "rootless-network"
+	bridgeFlag               = "bridge"
 	vmProviderFlag           = "provider"
 	grpcBindAddrFlag         = "grpc-bind-addr"
+	grpcAuthTokenFlag        = "grpc-auth-token"
+	grpcTLSCertFlag          = "grpc-tls-cert"
+	grpcTLSKeyFlag           = "grpc-tls-key"
+	grpcTLSCAFlag            = "grpc-tls-ca"
+	grpcCompressionFlag      = "grpc-compression"
+	grpcMaxMessageSizeFlag   = "grpc-max-message-size"
+	gatewayBindAddrFlag      = "gateway-bind-addr"
+	gatewayCORSOriginsFlag   = "gateway-cors-origins"
+	vmServiceBindAddrFlag    = "vm-service-bind-addr"
 	clusterBindAddrFlag      = "cluster-bind-addr"
 	clusterBaseURLFlag       = "cluster-base-url"
 	clusterTLSCertFlag       = "cluster-tls-cert"
 	clusterTLSKeyFlag        = "cluster-tls-key"
+	clusterTLSCAFlag         = "cluster-tls-ca"
+	spiffeTrustDomainFlag    = "spiffe-trust-domain"
 	respawnOnNodeFailureFlag = "respawn-on-node-failure"
+	oomMemoryCeilingFlag     = "oom-memory-ceiling"
+	prometheusURLFlag        = "prometheus-url"
+	maxConcurrentPullsFlag   = "max-concurrent-pulls"
+	maxPullBandwidthFlag     = "max-pull-bandwidth"
+	maxConcurrentCreatesFlag = "max-concurrent-creates"
+	maxQueuedSpawnsFlag      = "max-queued-spawns"
+	spawnRateLimitFlag       = "spawn-rate-limit"
+	spawnRateBurstFlag       = "spawn-rate-burst"
+	tenantFlag               = "tenant"
+	workloadNameFlag         = "name"
+	labelFlag                = "label"
+	annotationFlag           = "annotation"
+	nodeFlag                 = "node"
+	selectorFlag             = "selector"
+	parallelismFlag          = "parallelism"
+	maxPerNodeFlag           = "max-per-node"
+	statusFlag               = "status"
+	limitFlag                = "limit"
+	pageTokenFlag            = "page-token"
+	idempotencyKeyFlag       = "idempotency-key"
 	cpuFlag                  = "cpu"
 	memoryFlag               = "mem"
+	maxCPUFlag               = "max-cpu"
+	maxMemoryFlag            = "max-mem"
+	replicasFlag             = "replicas"
+	maxReplicasFlag          = "max-replicas"
+	targetRPSFlag            = "target-rps"
+	targetLatencyFlag        = "target-latency"
+	prometheusQueryFlag      = "prometheus-query"
 	imageRefFlag             = "image-ref"
 	portsFlag                = "ports"
+	ingressFlag              = "ingress"
+	tailFlag                 = "tail"
+	spreadFlag               = "spread"
+	broadcastPeriodFlag      = "broadcast-period"
+	gossipIntervalFlag       = "gossip-interval"
+	probeIntervalFlag        = "probe-interval"
+	userEventSizeLimitFlag   = "user-event-size-limit"
+	queueDepthWarningFlag    = "queue-depth-warning"
+	maxQueueDepthFlag        = "max-queue-depth"
+	explainFlag              = "explain"
+	planFlag                 = "plan"
+	pendingTTLFlag           = "pending-ttl"
+	envFlag                  = "env"
+	revisionFlag             = "revision"
+	weightFlag               = "weight"
+	maxErrorRateFlag         = "max-error-rate"
+	analysisFlag             = "analysis"
+	minRequestsFlag          = "min-requests"
+	clearFlag                = "clear"
+	readinessProbeFlag       = "readiness-probe"
+	livenessProbeFlag        = "liveness-probe"
+	restartPolicyFlag        = "restart-policy"
+	stopGracePeriodFlag      = "stop-grace-period"
+	preStopFlag              = "pre-stop"
+	initStepFlag             = "init-step"
+	initTimeoutFlag          = "init-timeout"
+	containerFlag            = "container"
+	priceCPUHourFlag         = "price-cpu-hour"
+	priceGBHourFlag          = "price-gb-hour"
+	priceGBEgressFlag        = "price-gb-egress"
+	egressPolicyFileFlag     = "egress-policy-file"
+	imagePolicyFileFlag      = "image-policy-file"
+	sidecarPolicyFileFlag    = "sidecar-policy-file"
+	egressProxyAddrFlag      = "egress-proxy-addr"
+	metadataAddrFlag         = "metadata-addr"
+	signingKeyRotationFlag   = "signing-key-rotation"
+	metadataTokenTTLFlag     = "metadata-token-ttl"
+	trackConnectionsFlag     = "track-connections"
+	ebpfNetworkMetricsFlag   = "ebpf-network-metrics"
+	workloadMetricsFlag      = "scrape-workload-metrics"
+	warmImagesFlag           = "warm-images"
+	pushProtocolFlag         = "metrics-push"
+	pushEndpointFlag         = "metrics-push-endpoint"
+	pushIntervalFlag         = "metrics-push-interval"
+	pushBatchSizeFlag        = "metrics-push-batch-size"
+	pushHeadersFlag          = "metrics-push-header"
+	pushLabelsFlag           = "metrics-push-label"
+	pushNodeLabelFlag        = "metrics-push-node-label"
+	pushTenantLabelFlag      = "metrics-push-tenant-label"
+	alertWebhookFlag         = "alert-webhook"
+	alertSlackWebhookFlag    = "alert-slack-webhook"
+	alertDedupWindowFlag     = "alert-dedup-window"
+	alertRateLimitFlag       = "alert-rate-limit"
+	endpointZoneFileFlag     = "endpoint-zone-file"
+	endpointZoneFlag         = "endpoint-zone"
+	haproxyRuntimeAPIFlag    = "haproxy-runtime-api"
+	endpointWebhookFlag      = "endpoint-webhook"
+	metricsEndpointFlag      = "metrics-endpoint"
+	accessLogFlag            = "access-log"
+	sessionAffinityFlag      = "session-affinity"
+	dropUserEventsFlag       = "drop-user-events"
+	queryDelayFlag           = "query-delay"
+	killWorkloadsFlag        = "kill-workloads"
+	runtimeClassNameFlag     = "name"
+	warmPoolFlag             = "warm-pool"
+	warmPoolRefreshFlag      = "warm-pool-refresh"
+	runtimeConfigFlag        = "runtime-config"
+	containerdConfigFlag     = "containerd-config"
+	containerdUnitFlag       = "containerd-unit"
+	shimDirFlag              = "shim-dir"
+	verifyImageFlag          = "verify-image"
+	installFlag              = "install"
+	unitPathFlag             = "unit-path"
+	envFileFlag              = "env-file"
+	caCertFlag               = "ca-cert"
+	caKeyFlag                = "ca-key"
+	outCertFlag              = "out-cert"
+	outKeyFlag               = "out-key"
+	sanFlag                  = "san"
+	ttlFlag                  = "ttl"
+	registryAddrFlag         = "registry-addr"
+	forceFlag                = "force"
+	registryDirFlag          = "registry-dir"
+	sampleImagesFlag         = "sample-images"
+	simulatedLatencyFlag     = "simulated-latency"
+	outDirFlag               = "out-dir"
+	reportDataFlag           = "report-data"
+	outputFlag               = "output"
+	rawFlag                  = "raw"
+	cyclesFlag               = "cycles"
+	warmupFlag               = "warmup"
+	durationFlag             = "duration"
+	pingsFlag                = "pings"
+	pingSizeFlag             = "ping-size"
+	profileFlag              = "profile"
+	kindFlag                 = "kind"
+	binaryURLFlag            = "binary-url"
+	sha256Flag               = "sha256"
+	timeoutFlag              = "timeout"
+	baseRootfsFlag           = "base-rootfs"
+	agentFlag                = "agent"
+	agentArgFlag             = "agent-arg"
+	runcFlag                 = "runc"
+	modulesDirFlag           = "modules-dir"
+	moduleFlag               = "module"
+	hostnameFlag             = "hostname"
+	templateDirFlag          = "template-dir"
+	varFlag                  = "var"
+	formatFlag               = "format"
+	clusterDNSFlag           = "cluster-dns"
+	dnsFlag                  = "dns"
+	dnsSearchFlag            = "dns-search"
+	dnsNdotsFlag             = "dns-ndots"
+	extraHostFlag            = "extra-host"
+	maintenanceWindowFlag    = "maintenance-window"
+	maintenanceDurationFlag  = "maintenance-duration"
+	maintenanceLeadFlag      = "maintenance-lead"
+	maintenanceDrainFlag     = "maintenance-drain"
+	sinceFlag                = "since"
+	memoryHighFlag           = "mem-high"
+	ioReadBPSFlag            = "io-read-bps"
+	ioWriteBPSFlag           = "io-write-bps"
+	ioReadIOPSFlag           = "io-read-iops"
+	ioWriteIOPSFlag          = "io-write-iops"
+	volumeFlag               = "volume"
+	volumeFromSnapshotFlag   = "volume-from-snapshot"
+	snapshotEndpointFlag     = "snapshot-endpoint"
+	snapshotRegionFlag       = "snapshot-region"
+	snapshotBucketFlag       = "snapshot-bucket"
+	snapshotPrefixFlag       = "snapshot-prefix"
+	snapshotIntervalFlag     = "snapshot-interval"
+	snapshotKeepFlag         = "snapshot-keep"
+	snapshotMaxAgeFlag       = "snapshot-max-age"
+	stdioBufferSizeFlag      = "stdio-buffer-size"
+	workloadLogMaxSizeFlag   = "workload-log-max-size"
 )
 
-func AddCommonFlags(cmd *cobra.Command, cfg *Config) {
-	cmd.Flags().StringVar(&cfg.DefaultVMProvider,
-		vmProviderFlag,
-		firecracker.HypervisorName,
-		"VM Provider to use")
+func AddServeFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.VMServiceBindAddr, vmServiceBindAddrFlag, "127.0.0.1:8001", "VMService GRPC Server bind address")
+	cmd.Flags().StringSliceVar(&cfg.Pool.Shapes, warmPoolFlag, nil, "VM shapes (VCPU:MEMORY_MB) to keep pre-booted firecracker snapshots of, using the kernel and drive of hac.toml")
+	cmd.Flags().DurationVar(&cfg.Pool.RefreshPeriod, warmPoolRefreshFlag, time.Minute, "How often the warm pool snapshots are checked against the kernel and drive")
+}
 
-	cmd.Flags().StringVar(&cfg.HACFile,
-		hacFileFlag,
-		defaults.HACFile,
-		"Path to hac.toml")
+func AddSnapshotStoreFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.Snapshots.Endpoint, snapshotEndpointFlag, "", "URL of the S3-compatible object store the snapshots are kept in, credentials are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	cmd.Flags().StringVar(&cfg.Snapshots.Region, snapshotRegionFlag, snapshot.DefaultRegion, "Region of the snapshot object store")
+	cmd.Flags().StringVar(&cfg.Snapshots.Bucket, snapshotBucketFlag, "", "Bucket the snapshots are kept in, empty to disable snapshots")
+	cmd.Flags().StringVar(&cfg.Snapshots.Prefix, snapshotPrefixFlag, "hypercore", "Prefix of the keys of the snapshots in the bucket")
+	cmd.Flags().IntVar(&cfg.Snapshots.KeepLast, snapshotKeepFlag, 7, "Snapshots kept per volume or disk, the older ones are pruned, 0 to keep them all")
+	cmd.Flags().DurationVar(&cfg.Snapshots.MaxAge, snapshotMaxAgeFlag, 0, "Age past which snapshots are pruned, the latest one of each volume or disk being kept, 0 to keep them whatever their age")
+}
 
-	cmd.Flags().StringVar(&cfg.CtrSocketPath,
-		containerdSocketFlag,
-		defaults.ContainerdSocket,
-		"The path to the containerd socket.")
+func AddClusterClientFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.GrpcBindAddr, grpcBindAddrFlag, "0.0.0.0:8000", "GRPC Server bind address")
+	cmd.Flags().StringVar(&cfg.GrpcAuthToken, grpcAuthTokenFlag, "", "Token to authenticate with the GRPC server")
+	cmd.Flags().StringVar(&cfg.GrpcTLSCert, grpcTLSCertFlag, "", "GRPC client tls cert path")
+	cmd.Flags().StringVar(&cfg.GrpcTLSKey, grpcTLSKeyFlag, "", "GRPC client tls key path")
+	cmd.Flags().StringVar(&cfg.GrpcTLSCA, grpcTLSCAFlag, "", "CA used to verify the GRPC server certificate")
+	cmd.Flags().StringVar(&cfg.GrpcCompression, grpcCompressionFlag, "", "Compression (gzip or zstd) of the GRPC requests and responses, empty for none")
+	cmd.Flags().IntVar(&cfg.GrpcMaxMessageSize, grpcMaxMessageSizeFlag, defaults.GrpcMaxMessageSize, "Size limit in bytes of the GRPC messages received and sent by the client")
+}
 
-	cmd.Flags().StringVar(&cfg.CtrNamespace,
-		containerdNamespace,
-		defaults.ContainerdNamespace,
-		"The name of the containerd namespace to use.")
+func AddClusterListFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterList.Node, nodeFlag, "", "Only list the workloads of this node")
+	cmd.Flags().StringVar(&cfg.ClusterList.Tenant, tenantFlag, "", "Only list the workloads of this tenant")
+	cmd.Flags().StringVarP(&cfg.ClusterList.LabelSelector, selectorFlag, "l", "", "Only list the workloads matching the comma separated key=value, key!=value, key or !key label requirements")
+	cmd.Flags().StringVar(&cfg.ClusterList.Status, statusFlag, "", "Only list the workloads with this status (ready, not-ready, pending)")
+	cmd.Flags().IntVar(&cfg.ClusterList.Limit, limitFlag, 0, "Workloads listed per page, 0 for the server default")
+	cmd.Flags().StringVar(&cfg.ClusterList.PageToken, pageTokenFlag, "", "Token printed by the previous page, empty for the first one")
 }
 
-func AddClusterFlags(cmd *cobra.Command, cfg *Config) {
-	cmd.Flags().StringVar(&cfg.GrpcBindAddr, grpcBindAddrFlag, "0.0.0.0:8000", "GRPC Server bind address")
-	cmd.Flags().StringVar(&cfg.ClusterBindAddr, clusterBindAddrFlag, ":7946", "Cluster bind address")
-	cmd.Flags().StringVar(&cfg.ClusterBaseURL, clusterBaseURLFlag, "example.com", "Cluster base URL")
-	cmd.Flags().StringVar(&cfg.ClusterTLSCert, clusterTLSCertFlag, "", "Cluster tls cert path")
-	cmd.Flags().StringVar(&cfg.ClusterTLSKey, clusterTLSKeyFlag, "", "Cluster tls key path")
-	cmd.Flags().BoolVar(&cfg.RespawnOnNodeFailure, respawnOnNodeFailureFlag, false, "Whether this node monitors other cluster nodes and re-schedules their tasks on failure")
+func AddClusterStopFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterStop.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in, and the only one whose workloads a selector stops")
+	cmd.Flags().StringVarP(&cfg.ClusterStop.LabelSelector, selectorFlag, "l", "", "Stop every workload matching the comma separated key=value, key!=value, key or !key label requirements instead of the argument")
+}
+
+func AddClusterRestartFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterRestart.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in")
+}
+
+func AddClusterBulkFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterBulk.Tenant, tenantFlag, "", "Tenant whose workload names the arguments are looked up in, and the only one whose workloads a selector matches")
+	cmd.Flags().StringVarP(&cfg.ClusterBulk.LabelSelector, selectorFlag, "l", "", "Act on every workload matching the comma separated key=value, key!=value, key or !key label requirements instead of the arguments")
+	cmd.Flags().IntVar(&cfg.ClusterBulk.Parallelism, parallelismFlag, 0, fmt.Sprintf("Workloads handled at once across the cluster, 0 for the server default (%d, at most %d)", defaults.BulkParallelism, defaults.MaxBulkParallelism))
+	cmd.Flags().IntVar(&cfg.ClusterBulk.MaxPerNode, maxPerNodeFlag, 0, "Workloads handled at once on a single node, 0 for no other limit than the parallelism")
+}
+
+func AddClusterLeaveFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().BoolVar(&cfg.ClusterLeave.Force, forceFlag, false, "Leave even if workloads still run on the node, they are respawned elsewhere once it is considered failed")
+}
+
+func AddClusterAdoptFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterAdopt.Node, nodeFlag, "", "Node running the container, the one the client is connected to if empty")
+	cmd.Flags().StringVar(&cfg.ClusterAdopt.Tenant, tenantFlag, "", "Tenant the workload belongs to")
+	cmd.Flags().StringVar(&cfg.ClusterAdopt.Name, workloadNameFlag, "", "Name the workload can be stopped and its logs read by, unique for the tenant")
+	cmd.Flags().IntVar(&cfg.ClusterAdopt.CPU, cpuFlag, 0, "CPU count of the workload, 0 for the one of the CPU limit of the container")
+	cmd.Flags().Var(newMemoryValue(0, &cfg.ClusterAdopt.Memory), memoryFlag, "Memory of the workload (in MB, or with a unit like 2GiB), 0 for the memory limit of the container")
+}
+
+func AddClusterBackupFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVarP(&cfg.ClusterBackup.Output, outputFlag, "o", "hypercore-backup.tar.gz", "File the backup archive is written to")
+}
+
+func AddClusterRestoreFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().BoolVar(&cfg.ClusterRestore.Force, forceFlag, false, "Overwrite the state the node already has")
+}
+
+func AddClusterUpgradeFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterUpgrade.BinaryURL, binaryURLFlag, "", "HTTP(S) URL the nodes download the new hypercore binary from")
+	cmd.Flags().StringVar(&cfg.ClusterUpgrade.SHA256, sha256Flag, "", "Hex encoded SHA-256 digest of the new binary")
+	cmd.Flags().DurationVar(&cfg.ClusterUpgrade.Timeout, timeoutFlag, time.Minute*5, "Time each node has to restart and report its workloads again")
+}
+
+func AddClusterLogsFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().IntVar(&cfg.ClusterLogs.TailBytes, tailFlag, 0, "Number of bytes from the end of the logs to show, 0 for the server default")
+	cmd.Flags().StringVar(&cfg.ClusterLogs.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in, and the only one whose workloads a selector matches")
+	cmd.Flags().StringVarP(&cfg.ClusterLogs.LabelSelector, selectorFlag, "l", "", "Show the logs of every workload matching the comma separated key=value, key!=value, key or !key label requirements instead of the argument")
+}
+
+func AddClusterMetricsFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().DurationVar(&cfg.ClusterMetrics.Since, sinceFlag, 0, fmt.Sprintf("Also show the metrics recorded by the node every %s over this long (up to %s), 0 for only the current ones", defaults.MetricsHistoryPeriod, defaults.MetricsHistoryRetention))
+	cmd.Flags().StringVar(&cfg.ClusterMetrics.Format, formatFlag, metricsFormatText, fmt.Sprintf("Output format, %s or %s", metricsFormatText, metricsFormatJSON))
+}
+
+func AddClusterDescribeFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterDescribe.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in")
+}
+
+func AddClusterTopFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterTop.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in")
+}
+
+func AddClusterApplyFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().BoolVar(&cfg.ClusterApply.Spread, spreadFlag, false, "Spread the workloads across as many nodes as possible instead of packing them")
+}
+
+func AddClusterFaultsFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().Float64Var(&cfg.ClusterFaults.DropUserEventsPercent, dropUserEventsFlag, 0, "Percentage of the user events received by the node that are dropped")
+	cmd.Flags().DurationVar(&cfg.ClusterFaults.QueryDelay, queryDelayFlag, 0, "Delay before the node handles each received query")
+	cmd.Flags().Float64Var(&cfg.ClusterFaults.KillWorkloadsPercent, killWorkloadsFlag, 0, "Percentage of the node workloads killed every broadcast period")
+}
+
+func AddClusterUpdateFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().IntVar(&cfg.ClusterUpdate.CPU, cpuFlag, 0, "New CPU count, 0 to keep the current one")
+	cmd.Flags().Var(newMemoryValue(0, &cfg.ClusterUpdate.Memory), memoryFlag, "New memory (in MB, or with a unit like 2GiB), 0 to keep the current one")
+	cmd.Flags().StringVar(&cfg.ClusterUpdate.ImageRef, imageRefFlag, "", "New image reference, empty to keep the current one")
+	cmd.Flags().StringSliceVar(&cfg.ClusterUpdate.Env, envFlag, nil, "Environment variables (KEY=value) replacing the current ones")
+}
+
+func AddClusterRollbackFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().IntVar(&cfg.ClusterUpdate.Revision, revisionFlag, 0, "Revision to roll back to, 0 for the one before the current revision")
+}
+
+func AddClusterCanaryFlags(cmd *cobra.Command, cfg *Config) {
+	AddClusterUpdateFlags(cmd, cfg)
+	cmd.Flags().IntVar(&cfg.ClusterCanary.Replicas, replicasFlag, 1, "Number of canary replicas")
+	cmd.Flags().IntVar(&cfg.ClusterCanary.Weight, weightFlag, 10, "Percentage (0-100) of the requests sent to the canary")
+	cmd.Flags().Float64Var(&cfg.ClusterCanary.MaxErrorRate, maxErrorRateFlag, 0, "Error rate (0-1) above which the canary is rolled back, 0 to leave it running without analysis")
+	cmd.Flags().DurationVar(&cfg.ClusterCanary.Analysis, analysisFlag, defaults.CanaryAnalysis, "How long the canary must stay below the maximum error rate to be promoted")
+	cmd.Flags().IntVar(&cfg.ClusterCanary.MinRequests, minRequestsFlag, 100, "Requests the canary must serve during the analysis to be promoted")
+}
+
+func AddClusterTrafficFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().IntVar(&cfg.ClusterTraffic.Revision, revisionFlag, 0, "Revision the weighted share of the requests is sent to")
+	cmd.Flags().IntVar(&cfg.ClusterTraffic.Weight, weightFlag, 100, "Percentage (0-100) of the requests sent to the revision")
+	cmd.Flags().BoolVar(&cfg.ClusterTraffic.Clear, clearFlag, false, "Balance the requests across all the replicas again")
+}
+
+func AddClusterUsageFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterUsage.Tenant, tenantFlag, "", "Only show the usage of this tenant")
+}
+
+func AddClusterCacheFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterCache.Node, nodeFlag, "", "Only show the images of this node")
+	cmd.Flags().StringVar(&cfg.ClusterCache.ImageRef, imageRefFlag, "", "Only show this image")
+}
+
+func AddClusterTopologyFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterTopology.Tenant, tenantFlag, "", "Only show the connections of the workloads of this tenant")
+	cmd.Flags().StringVar(&cfg.ClusterTopology.Format, formatFlag, topologyFormatText, fmt.Sprintf("Output format, %s, %s for Graphviz or %s", topologyFormatText, topologyFormatDOT, topologyFormatJSON))
+}
+
+func AddClusterSnapshotFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterSnapshot.Tenant, tenantFlag, "", "Tenant the volume belongs to")
+}
+
+func AddClusterSnapshotsFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ClusterSnapshots.Volume, volumeFlag, "", "Only list the snapshots of this volume")
+	cmd.Flags().StringVar(&cfg.ClusterSnapshots.Tenant, tenantFlag, "", "Only list the snapshots of the volumes of this tenant")
+}
+
+func AddRuntimeClassFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.RuntimeClass.Name, runtimeClassNameFlag, "hypercore", "Name of the RuntimeClass and of the containerd CRI runtime handler")
+	cmd.Flags().StringVar(&cfg.RuntimeClass.ConfigPath, runtimeConfigFlag, "/etc/hypercore/runtime.json", "Path the VM defaults read by the shim are written to")
+}
+
+func AddInstallRuntimeFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.RuntimeClass.Name, runtimeClassNameFlag, "hypercore", "Name of the containerd CRI runtime handler")
+	cmd.Flags().StringVar(&cfg.InstallRuntime.ContainerdConfig, containerdConfigFlag, "/etc/containerd/config.toml", "containerd config the runtime is added to")
+	cmd.Flags().StringVar(&cfg.InstallRuntime.ContainerdUnit, containerdUnitFlag, "containerd", "systemd unit of containerd, restarted once the runtime is added, containerd is sent SIGHUP if it isn't active")
+	cmd.Flags().StringVar(&cfg.InstallRuntime.ShimDir, shimDirFlag, "/usr/local/bin", "Directory in the PATH of containerd the shim binary is linked in")
+	cmd.Flags().StringVar(&cfg.InstallRuntime.VerifyImage, verifyImageFlag, "docker.io/library/hello-world:latest", "Image run as a microVM to verify the runtime, empty to skip the verification")
+}
+
+func AddClusterDaemonFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().BoolVar(&cfg.ClusterDaemon.Install, installFlag, false, "Write the unit and the environment file, then enable and start the unit")
+	cmd.Flags().StringVar(&cfg.ClusterDaemon.UnitPath, unitPathFlag, "/etc/systemd/system/hypercore-cluster.service", "Path the systemd unit is written to")
+	cmd.Flags().StringVar(&cfg.ClusterDaemon.EnvFile, envFileFlag, "/etc/hypercore/cluster.env", "Environment file holding the arguments of the cluster command")
+	cmd.Flags().StringVar(&cfg.ClusterDaemon.ContainerdUnit, containerdUnitFlag, "containerd.service", "systemd unit of the containerd instance the agent uses")
+}
+
+func AddClusterIssueCertFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.IssueCert.CACert, caCertFlag, "/etc/hypercore/pki/ca.crt", "Certificate of the cluster CA, created with its key if neither exists")
+	cmd.Flags().StringVar(&cfg.IssueCert.CAKey, caKeyFlag, "/etc/hypercore/pki/ca.key", "Key of the cluster CA")
+	cmd.Flags().StringVar(&cfg.IssueCert.OutCert, outCertFlag, "/etc/hypercore/pki/node.crt", "Path the issued certificate is written to")
+	cmd.Flags().StringVar(&cfg.IssueCert.OutKey, outKeyFlag, "/etc/hypercore/pki/node.key", "Path the key of the issued certificate is written to")
+	cmd.Flags().StringSliceVar(&cfg.IssueCert.SANs, sanFlag, nil, "IP addresses and DNS names the certificate is valid for")
+	cmd.Flags().DurationVar(&cfg.IssueCert.TTL, ttlFlag, time.Hour*24, "Validity of the issued certificate")
+	cmd.Flags().StringVar(&cfg.SpiffeTrustDomain, spiffeTrustDomainFlag, "", "Trust domain of the SPIFFE ID embedded in the certificate, empty for none")
+}
+
+func AddDebugCollectFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.DebugCollect.OutDir, outDirFlag, ".", "Directory the crash bundles are written to, one subdirectory per node and crash")
+	cmd.Flags().StringVar(&cfg.DebugCollect.Tenant, tenantFlag, "", "Tenant whose workload names the argument is looked up in")
+}
+
+func AddDebugAttestFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.DebugAttest.ReportData, reportDataFlag, "", "Hex encoded data of up to 64 bytes bound into the report, e.g. a nonce of the verifier, random if empty")
+	cmd.Flags().StringVarP(&cfg.DebugAttest.Output, outputFlag, "o", "", "File the report is written to, TASK-ID.report if empty")
+}
+
+func AddBenchBootFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().IntVar(&cfg.BenchBoot.Cycles, cyclesFlag, 10, "Number of VMs booted and measured")
+	cmd.Flags().IntVar(&cfg.BenchBoot.Warmup, warmupFlag, 1, "Number of VMs booted before the measured ones, pulling the image and warming the caches")
+	cmd.Flags().StringVarP(&cfg.BenchBoot.Output, outputFlag, "o", "", "File the percentiles are written to as JSON, to compare releases")
+}
+
+func AddBenchIOFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().DurationVar(&cfg.BenchIO.Duration, durationFlag, 10*time.Second, "Duration of the stdin and of the stdout transfers")
+	cmd.Flags().IntVar(&cfg.BenchIO.Pings, pingsFlag, 1000, "Number of messages echoed to measure the round trips")
+	cmd.Flags().IntVar(&cfg.BenchIO.PingSize, pingSizeFlag, 64, "Size of the echoed messages in bytes")
+	cmd.Flags().StringVar(&cfg.BenchIO.ProfileDir, profileFlag, "", "Directory the CPU profiles of the shim and of this command during the transfers are written to, none if empty")
+	cmd.Flags().StringVarP(&cfg.BenchIO.Output, outputFlag, "o", "", "File the results are written to as JSON, to compare releases")
+}
+
+func AddDebugProfileFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.DebugProfile.Kind, kindFlag, "cpu", "cpu, or a profile of runtime/pprof such as heap, goroutine or block")
+	cmd.Flags().DurationVar(&cfg.DebugProfile.Duration, durationFlag, 30*time.Second, "Duration of CPU profiles")
+	cmd.Flags().StringVarP(&cfg.DebugProfile.Output, outputFlag, "o", "", "File the profile is written to, TASK-ID.KIND.pprof if empty")
+}
+
+func AddDebugDescribeVMFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().BoolVar(&cfg.DebugDescribeVM.Raw, rawFlag, false, "Also print the configuration and the metrics as returned by the API of the hypervisor")
 }
 
 func AddClusterSpawnFlags(cmd *cobra.Command, cfg *Config) {
-	cmd.Flags().StringVar(&cfg.GrpcBindAddr, grpcBindAddrFlag, "0.0.0.0:8000", "GRPC Server bind address")
+	AddClusterClientFlags(cmd, cfg)
 	cmd.Flags().IntVar(&cfg.ClusterSpawn.CPU, cpuFlag, 1, "CPU count")
-	cmd.Flags().IntVar(&cfg.ClusterSpawn.Memory, memoryFlag, 512, "Memory (in MB)")
+	cmd.Flags().Var(newMemoryValue(512, &cfg.ClusterSpawn.Memory), memoryFlag, "Memory (in MB, or with a unit like 2GiB)")
+	cmd.Flags().IntVar(&cfg.ClusterSpawn.MaxCPU, maxCPUFlag, 0, "Maximum CPU count the workload can be vertically scaled up to")
+	cmd.Flags().Var(newMemoryValue(0, &cfg.ClusterSpawn.MemoryHigh), memoryHighFlag, "Memory (in MB, or with a unit like 2GiB) above which the workload is throttled and its memory reclaimed, below --mem, 0 for none")
+	cmd.Flags().Var(&byteSizeValue{bytes: &cfg.ClusterSpawn.IOReadBPS}, ioReadBPSFlag, "Disk read bandwidth (bytes per second, or with a unit like 50MiB) of the workload, 0 for no limit")
+	cmd.Flags().Var(&byteSizeValue{bytes: &cfg.ClusterSpawn.IOWriteBPS}, ioWriteBPSFlag, "Disk write bandwidth (bytes per second, or with a unit like 50MiB) of the workload, 0 for no limit")
+	cmd.Flags().Uint64Var(&cfg.ClusterSpawn.IOReadIOPS, ioReadIOPSFlag, 0, "Disk reads per second of the workload, 0 for no limit")
+	cmd.Flags().Uint64Var(&cfg.ClusterSpawn.IOWriteIOPS, ioWriteIOPSFlag, 0, "Disk writes per second of the workload, 0 for no limit")
+	cmd.Flags().Var(newMemoryValue(0, &cfg.ClusterSpawn.MaxMemory), maxMemoryFlag, "Maximum memory (in MB, or with a unit like 2GiB) the workload can be vertically scaled up to")
+	cmd.Flags().IntVar(&cfg.ClusterSpawn.Replicas, replicasFlag, 1, "Minimum number of replicas for a horizontally scaled workload")
+	cmd.Flags().IntVar(&cfg.ClusterSpawn.MaxReplicas, maxReplicasFlag, 0, "Maximum number of replicas the workload can be horizontally scaled up to, 0 disables horizontal scaling")
+	cmd.Flags().Float64Var(&cfg.ClusterSpawn.TargetRPS, targetRPSFlag, 0, "Requests per second a single replica should handle")
+	cmd.Flags().IntVar(&cfg.ClusterSpawn.TargetLatency, targetLatencyFlag, 0, "Average latency (in ms) above which a replica is added, one is removed below half of it unless --target-rps is set")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.PrometheusQuery, prometheusQueryFlag, "", "Prometheus query to scale on instead of the proxy observed RPS")
 	cmd.Flags().StringVar(&cfg.ClusterSpawn.ImageRef, imageRefFlag, "", "Image Reference")
 	cmd.Flags().StringVar(&cfg.ClusterSpawn.Ports, portsFlag, "", "comma-separated list of ports to expose")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.MetricsEndpoint, metricsEndpointFlag, "", "Prometheus endpoint (PORT or PORT/PATH) of the workload, scraped by the node running it")
+	cmd.Flags().BoolVar(&cfg.ClusterSpawn.AccessLog, accessLogFlag, false, "Log each request the proxies route to the workload")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.SessionAffinity, sessionAffinityFlag, "none", "How the clients of the workload are pinned to one of its replicas: none, cookie or source-ip")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.Ingress, ingressFlag, nil, "Ingress rule (CIDR,...=PORT,...) new connections to the workload must match one of, from any source or to any port if either is omitted")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.Tenant, tenantFlag, "", "Tenant the spawn request is rate limited as")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.Name, workloadNameFlag, "", "Name the workload can be stopped and its logs read by, unique for the tenant")
+	cmd.Flags().StringSliceVar(&cfg.ClusterSpawn.Labels, labelFlag, nil, "Labels (KEY=VALUE) the workload can be selected by when listing, stopping or showing the logs of workloads")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.Annotations, annotationFlag, nil, "Annotation (KEY=VALUE) kept with the workload, which it can't be selected by")
+	cmd.Flags().BoolVar(&cfg.ClusterSpawn.Explain, explainFlag, false, "Show the nodes the workload would be placed on and why, without spawning it")
+	cmd.Flags().BoolVar(&cfg.ClusterSpawn.Plan, planFlag, false, "Show the nodes the workload and its replicas would be placed on and the capacity left, without spawning it")
+	cmd.Flags().StringSliceVar(&cfg.ClusterSpawn.Env, envFlag, nil, "Environment variables (KEY=value) set in the workload")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.ReadinessProbe, readinessProbeFlag, "", "Probe (http:PORT/PATH, tcp:PORT or exec:COMMAND) that must pass before the workload receives traffic")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.LivenessProbe, livenessProbeFlag, "", "Probe (http:PORT/PATH, tcp:PORT or exec:COMMAND) whose failure kills the workload")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.RestartPolicy, restartPolicyFlag, "always", "Whether the workload is respawned when it stops: always, on-failure or never")
+	cmd.Flags().DurationVar(&cfg.ClusterSpawn.StopGracePeriod, stopGracePeriodFlag, defaults.StopTimeout, "Time the workload has to exit once stopped, including the pre-stop command, before it is killed")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.PreStop, preStopFlag, "", "Command run in the workload before it is sent SIGTERM")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.InitSteps, initStepFlag, nil, "Init step (IMAGE [COMMAND], - for the image of the workload) run to completion before the workload starts, in order")
+	cmd.Flags().DurationVar(&cfg.ClusterSpawn.InitTimeout, initTimeoutFlag, defaults.InitStepTimeout, "Time each init step has to complete before it is killed and fails")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.Containers, containerFlag, nil, "Container (NAME IMAGE [COMMAND], - for the image of the workload) run along with the workload in its network namespace")
+	cmd.Flags().StringSliceVar(&cfg.ClusterSpawn.DNS, dnsFlag, nil, "Nameservers (IP addresses, at most 3) of the workload, the cluster DNS of the node or its resolver if empty")
+	cmd.Flags().StringSliceVar(&cfg.ClusterSpawn.DNSSearches, dnsSearchFlag, nil, "Search domains of the resolver of the workload")
+	cmd.Flags().IntVar(&cfg.ClusterSpawn.DNSNdots, dnsNdotsFlag, 0, "Dots a name needs to be looked up as is before the search domains, the resolver default of 1 if 0")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.ExtraHosts, extraHostFlag, nil, "Entry (HOSTNAME:IP) added to the /etc/hosts of the workload, for names that aren't in DNS")
+	cmd.Flags().StringArrayVar(&cfg.ClusterSpawn.Volumes, volumeFlag, nil, "Persistent volume (NAME:PATH[:ro]) mounted into the workload, which is placed on the node holding it, created there if it doesn't exist")
+	cmd.Flags().StringToStringVar(&cfg.ClusterSpawn.VolumeSnapshots, volumeFromSnapshotFlag, nil, "Snapshot (VOLUME=SNAPSHOT, SNAPSHOT being NAME or NAME@ID for a given one) a volume is restored from if it doesn't exist yet")
+	cmd.Flags().DurationVar(&cfg.ClusterSpawn.PendingTTL, pendingTTLFlag, 0, "Time the workload waits as pending for a node with capacity when the cluster is full, the spawn failing right away if 0")
+	cmd.Flags().StringVar(&cfg.ClusterSpawn.IdempotencyKey, idempotencyKeyFlag, "", "Key making retries of the command return the workload spawned by the first attempt, generated if empty")
 }
 
 func BindCommandToViper(cmd *cobra.Command) {
@@ -82,3 +487,77 @@ func bindFlagsToViper(fs *pflag.FlagSet) {
 		}
 	})
 }
+
+// memoryValue is a memory size flag kept in MB, taking sizes with a unit
+// such as 512MiB or 2G as well as plain numbers of MB
+type memoryValue[T int | uint32] struct {
+	mb *T
+}
+
+func newMemoryValue[T int | uint32](value T, mb *T) *memoryValue[T] {
+	*mb = value
+
+	return &memoryValue[T]{mb: mb}
+}
+
+func (m *memoryValue[T]) String() string {
+	return fmt.Sprintf("%d", *m.mb)
+}
+
+func (m *memoryValue[T]) Set(value string) error {
+	size, err := resource.ParseMemory(value, resource.MiB)
+	if err != nil {
+		return err
+	}
+
+	if size.MiB() > math.MaxInt32 {
+		return fmt.Errorf("memory size %q is too large", value)
+	}
+
+	*m.mb = T(size.MiB())
+
+	return nil
+}
+
+func (m *memoryValue[T]) Type() string {
+	return "memory"
+}
+
+// byteSizeValue is a size flag kept in bytes, taking sizes with a unit
+// such as 50MiB as well as plain numbers of bytes
+type byteSizeValue struct {
+	bytes *int64
+}
+
+func newByteSizeValue(value int64, bytes *int64) *byteSizeValue {
+	*bytes = value
+
+	return &byteSizeValue{bytes: bytes}
+}
+
+func (b *byteSizeValue) String() string {
+	if *b.bytes == 0 {
+		return "0"
+	}
+
+	return resource.Bytes(*b.bytes).String()
+}
+
+func (b *byteSizeValue) Set(value string) error {
+	size, err := resource.ParseMemory(value, resource.Byte)
+	if err != nil {
+		return err
+	}
+
+	if size > math.MaxInt64 {
+		return fmt.Errorf("size %q is too large", value)
+	}
+
+	*b.bytes = int64(size)
+
+	return nil
+}
+
+func (b *byteSizeValue) Type() string {
+	return "bytes"
+}
@@ -0,0 +1,154 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"vistara-node/pkg/cluster"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/guestimage"
+	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/pushmetrics"
+
+	"github.com/spf13/cobra"
+)
+
+func AddCommonFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.DefaultVMProvider,
+		vmProviderFlag,
+		firecracker.HypervisorName,
+		"VM Provider to use")
+
+	cmd.Flags().StringVar(&cfg.HACFile,
+		hacFileFlag,
+		defaults.HACFile,
+		"Path to hac.toml")
+
+	cmd.Flags().StringVar(&cfg.CtrSocketPath,
+		containerdSocketFlag,
+		defaults.ContainerdSocket,
+		"The path to the containerd socket.")
+
+	cmd.Flags().StringVar(&cfg.CtrNamespace,
+		containerdNamespace,
+		defaults.ContainerdNamespace,
+		"The name of the containerd namespace to use.")
+
+	cmd.Flags().BoolVar(&cfg.Rootless, rootlessFlag, false, "Run workloads without root against a rootless containerd, in a user-mode network stack instead of CNI networks")
+	cmd.Flags().StringVar(&cfg.RootlessNetwork, rootlessNetworkFlag, containerd.RootlessNetworkSlirp4netns,
+		fmt.Sprintf("User-mode network stack of rootless workloads, %s or %s", containerd.RootlessNetworkSlirp4netns, containerd.RootlessNetworkPasta))
+	cmd.Flags().Var(newByteSizeValue(1<<20, &cfg.StdioBufferSize), stdioBufferSizeFlag, "Bytes (or with a unit like 4MiB) of the stdout and stderr of the VMs started by this command kept in memory, the older output being dropped")
+}
+
+func AddClusterFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.GrpcBindAddr, grpcBindAddrFlag, "0.0.0.0:8000", "GRPC Server bind address")
+	cmd.Flags().StringVar(&cfg.GrpcAuthToken, grpcAuthTokenFlag, "", "Token required from GRPC clients, empty to disable authentication")
+	cmd.Flags().StringVar(&cfg.GrpcTLSCert, grpcTLSCertFlag, "", "GRPC Server tls cert path")
+	cmd.Flags().StringVar(&cfg.GrpcTLSKey, grpcTLSKeyFlag, "", "GRPC Server tls key path")
+	cmd.Flags().StringVar(&cfg.GrpcTLSCA, grpcTLSCAFlag, "", "CA used to verify GRPC client certificates (mTLS)")
+	cmd.Flags().StringVar(&cfg.GrpcCompression, grpcCompressionFlag, "", "Compression (gzip or zstd) of the requests forwarded to other nodes, empty for none")
+	cmd.Flags().IntVar(&cfg.GrpcMaxMessageSize, grpcMaxMessageSizeFlag, defaults.GrpcMaxMessageSize, "Size limit in bytes of the GRPC messages received and sent by the server")
+	cmd.Flags().StringVar(&cfg.Bridge, bridgeFlag, "", "Linux bridge attaching the workloads to each other, empty for a point-to-point link to the host each")
+	cmd.Flags().StringVar(&cfg.GatewayBindAddr, gatewayBindAddrFlag, "", "HTTP+JSON gateway bind address, empty to disable it")
+	cmd.Flags().StringSliceVar(&cfg.GatewayCORSOrigins, gatewayCORSOriginsFlag, nil, "Origins allowed to make cross-origin requests to the gateway, * allows any")
+	cmd.Flags().StringVar(&cfg.ClusterBindAddr, clusterBindAddrFlag, ":7946", "Cluster bind address")
+	cmd.Flags().StringVar(&cfg.ClusterBaseURL, clusterBaseURLFlag, "example.com", "Cluster base URL")
+	cmd.Flags().StringVar(&cfg.ClusterTLSCert, clusterTLSCertFlag, "", "Cluster tls cert path")
+	cmd.Flags().StringVar(&cfg.ClusterTLSKey, clusterTLSKeyFlag, "", "Cluster tls key path")
+	cmd.Flags().StringVar(&cfg.ClusterTLSCA, clusterTLSCAFlag, "", "CA that must have issued the certificate of proxies forwarding requests to this node")
+	cmd.Flags().StringVar(&cfg.SpiffeTrustDomain, spiffeTrustDomainFlag, "", "Trust domain of the SPIFFE ID peers must present in their certificate, empty to only verify the CA")
+	cmd.Flags().BoolVar(&cfg.RespawnOnNodeFailure, respawnOnNodeFailureFlag, false, "Whether this node monitors other cluster nodes and re-schedules their tasks on failure")
+	cmd.Flags().Var(newMemoryValue(0, &cfg.OOMMemoryCeiling), oomMemoryCeilingFlag, "Memory ceiling (in MB, or with a unit like 4GiB) up to which repeatedly OOM killed workloads get their memory bumped on respawn, 0 to disable")
+	cmd.Flags().StringVar(&cfg.PrometheusURL, prometheusURLFlag, "", "Prometheus server used to evaluate horizontal scaling queries")
+	cmd.Flags().IntVar(&cfg.MaxConcurrentPulls, maxConcurrentPullsFlag, 2, "Maximum number of images pulled at once on this node, 0 for no limit")
+	cmd.Flags().Var(&byteSizeValue{bytes: &cfg.MaxPullBandwidth}, maxPullBandwidthFlag, "Bandwidth (bytes per second, or with a unit like 50MiB) the image pulls of this node share, 0 for no limit")
+	cmd.Flags().IntVar(&cfg.MaxConcurrentCreates, maxConcurrentCreatesFlag, cluster.DefaultMaxConcurrentCreates, "Maximum number of workloads created at once on this node")
+	cmd.Flags().IntVar(&cfg.MaxQueuedSpawns, maxQueuedSpawnsFlag, cluster.DefaultMaxQueuedSpawns, "Maximum number of spawns waiting for a creation slot on this node, further spawns are rejected")
+	cmd.Flags().Float64Var(&cfg.SpawnRateLimit, spawnRateLimitFlag, 0, "Spawn requests per second accepted from each tenant by this node, 0 disables rate limiting")
+	cmd.Flags().IntVar(&cfg.SpawnRateBurst, spawnRateBurstFlag, 10, "Spawn requests a tenant can make at once above the rate limit")
+	cmd.Flags().DurationVar(&cfg.BroadcastPeriod, broadcastPeriodFlag, cluster.DefaultWorkloadBroadcastPeriod, "Period of the workload state broadcasts, nodes missing three broadcasts are considered failed")
+	cmd.Flags().DurationVar(&cfg.GossipInterval, gossipIntervalFlag, 0, "Interval between serf gossip rounds, 0 for the serf default")
+	cmd.Flags().DurationVar(&cfg.ProbeInterval, probeIntervalFlag, 0, "Interval between serf failure detection probes, 0 for the serf default")
+	cmd.Flags().IntVar(&cfg.UserEventSizeLimit, userEventSizeLimitFlag, 0, "Maximum size (in bytes) of serf user events such as state broadcasts, 0 for the serf default")
+	cmd.Flags().IntVar(&cfg.QueueDepthWarning, queueDepthWarningFlag, 0, "Serf broadcast queue depth above which warnings are logged, 0 for the serf default")
+	cmd.Flags().IntVar(&cfg.MaxQueueDepth, maxQueueDepthFlag, 0, "Serf broadcast queue depth above which messages are dropped, 0 for the serf default")
+	cmd.Flags().Float64Var(&cfg.PriceCPUHour, priceCPUHourFlag, 0, "Price billed per hour of vCPU time used by the workloads of this node")
+	cmd.Flags().Float64Var(&cfg.PriceGBHour, priceGBHourFlag, 0, "Price billed per GB of memory allocated to the workloads of this node for an hour")
+	cmd.Flags().Float64Var(&cfg.PriceGBEgress, priceGBEgressFlag, 0, "Price billed per GB sent by the workloads of this node")
+	cmd.Flags().StringVar(&cfg.EgressPolicyFile, egressPolicyFileFlag, "", "JSON file of the egress policies of the tenants, keyed by tenant with * for the others")
+	cmd.Flags().StringVar(&cfg.ImagePolicyFile, imagePolicyFileFlag, "", "JSON file of the allow and deny patterns of the images workloads can run")
+	cmd.Flags().StringVar(&cfg.SidecarPolicyFile, sidecarPolicyFileFlag, "", "JSON file of the sidecars injected into the pods of the matching workloads")
+	cmd.Flags().StringVar(&cfg.EgressProxyAddr, egressProxyAddrFlag, "0.0.0.0:3129", "Address the egress proxy logging the HTTP(S) connections of the workloads listens on")
+	cmd.Flags().StringVar(&cfg.MetadataAddr, metadataAddrFlag, "", "Address the metadata service the workloads reach on 169.254.169.254 listens on, e.g. 0.0.0.0:8169, empty to disable it")
+	cmd.Flags().DurationVar(&cfg.MetadataTokenTTL, metadataTokenTTLFlag, cluster.DefaultMetadataTokenTTL, "Lifetime of the identity tokens issued by the metadata service")
+	cmd.Flags().DurationVar(&cfg.SigningKeyRotation, signingKeyRotationFlag, cluster.DefaultSigningKeyRotation, "Age of the key of the node signing the identity tokens of its workloads it is replaced past")
+	cmd.Flags().BoolVar(&cfg.TrackConnections, trackConnectionsFlag, false, "Track the connections of the containers and sample them into the service topology of the cluster")
+	cmd.Flags().BoolVar(&cfg.EBPFNetworkMetrics, ebpfNetworkMetricsFlag, false, "Count the bytes and packets of the containers with eBPF programs for billing and Prometheus, rather than with their interface counters")
+	cmd.Flags().BoolVar(&cfg.WorkloadMetrics, workloadMetricsFlag, false, "Scrape the metrics endpoints declared by the workloads and serve them on the gateway's /metrics with workload and tenant labels")
+	cmd.Flags().Var(newByteSizeValue(cluster.DefaultMaxLogSize, &cfg.WorkloadLogMaxSize), workloadLogMaxSizeFlag, "Size (in bytes, or with a unit like 64MiB) the log file of a workload is capped at, its oldest half being dropped past it, 0 for no cap")
+	cmd.Flags().BoolVar(&cfg.WarmImages, warmImagesFlag, true, "Pull the images placed repeatedly ahead on the next best nodes so failed over replicas respawn fast, and pull those other nodes ask this one to")
+	cmd.Flags().StringVar(&cfg.MetricsPush.Protocol, pushProtocolFlag, "", "Push the metrics of the node and its shims with otlp (OTLP/HTTP) or remote-write (Prometheus), empty to only serve them")
+	cmd.Flags().StringVar(&cfg.MetricsPush.Endpoint, pushEndpointFlag, "", "OTLP receiver (without the /v1/metrics path) or remote-write URL the metrics are pushed to")
+	cmd.Flags().DurationVar(&cfg.MetricsPush.Interval, pushIntervalFlag, pushmetrics.DefaultInterval, "Interval between two pushes of the metrics")
+	cmd.Flags().IntVar(&cfg.MetricsPush.BatchSize, pushBatchSizeFlag, pushmetrics.DefaultBatchSize, "Maximum number of samples pushed per request")
+	cmd.Flags().StringToStringVar(&cfg.MetricsPush.Headers, pushHeadersFlag, nil, "Headers (NAME=VALUE) of the push requests, like an Authorization")
+	cmd.Flags().StringToStringVar(&cfg.MetricsPush.Labels, pushLabelsFlag, nil, "Labels (KEY=VALUE) added to every pushed sample")
+	cmd.Flags().StringVar(&cfg.MetricsPush.NodeLabel, pushNodeLabelFlag, "node", "Name of the label holding the node of the pushed samples")
+	cmd.Flags().StringVar(&cfg.MetricsPush.TenantLabel, pushTenantLabelFlag, "tenant", "Name of the label holding the tenant of the pushed samples")
+	cmd.Flags().StringArrayVar(&cfg.Alerts.Webhooks, alertWebhookFlag, nil, "URL the alerts of critical events (node failures, crash loops, respawn storms, queue overloads, policy violations) are posted to as JSON")
+	cmd.Flags().StringArrayVar(&cfg.Alerts.SlackWebhooks, alertSlackWebhookFlag, nil, "Slack incoming webhook URL the alerts are posted to")
+	cmd.Flags().DurationVar(&cfg.Alerts.DedupWindow, alertDedupWindowFlag, cluster.DefaultAlertDedupWindow, "Window during which repeated alerts of the same kind about the same subject aren't sent again")
+	cmd.Flags().IntVar(&cfg.Alerts.RateLimit, alertRateLimitFlag, cluster.DefaultAlertRateLimit, "Maximum number of alerts sent per minute, further alerts are dropped")
+	cmd.Flags().StringVar(&cfg.EndpointPublish.ZoneFile, endpointZoneFileFlag, "", "Zone file the endpoints of the services are written to whenever they change, for the file plugin of CoreDNS")
+	cmd.Flags().StringVar(&cfg.EndpointPublish.Zone, endpointZoneFlag, cluster.DefaultEndpointZone, "Origin of the endpoint zone file")
+	cmd.Flags().StringVar(&cfg.EndpointPublish.HAProxyRuntimeAPI, haproxyRuntimeAPIFlag, "", "HAProxy runtime API (host:port or unix socket path) whose SERVICE_PORT backends are pointed at the endpoints of the services")
+	cmd.Flags().StringArrayVar(&cfg.EndpointPublish.Webhooks, endpointWebhookFlag, nil, "URL the endpoints of the services are posted to as JSON whenever they change")
+	cmd.Flags().StringVar(&cfg.ClusterDNS, clusterDNSFlag, "", "Nameserver of the workloads spawned without a DNS config, e.g. the CoreDNS serving the endpoint zone, which is their search domain. The resolver of the node if empty")
+	cmd.Flags().StringArrayVar(&cfg.Maintenance.Windows, maintenanceWindowFlag, nil, "Cron expression (minute hour day-of-month month day-of-week, in local time) of the starts of the maintenance windows of this node")
+	cmd.Flags().DurationVar(&cfg.Maintenance.Duration, maintenanceDurationFlag, time.Hour, "Length of the maintenance windows of this node")
+	cmd.Flags().DurationVar(&cfg.Maintenance.Lead, maintenanceLeadFlag, cluster.DefaultMaintenanceLead, "How long before the maintenance window of a node new workloads stop being placed on it")
+	cmd.Flags().BoolVar(&cfg.Maintenance.Drain, maintenanceDrainFlag, false, "Drain this node when its maintenance windows start and make it ready again when they end")
+	AddSnapshotStoreFlags(cmd, cfg)
+	cmd.Flags().DurationVar(&cfg.Snapshots.Interval, snapshotIntervalFlag, 0, "Period of the snapshots of the persistent volumes of this node, 0 to only snapshot them on request")
+}
+
+func AddImageBuildAgentFlags(cmd *cobra.Command, cfg *Config) {
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.BaseRootfs, baseRootfsFlag, "", "Tarball, optionally gzipped, of the root filesystem the image is built on, providing systemd as /usr/sbin/init")
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.Agent, agentFlag, "", "Guest agent binary installed as "+guestimage.AgentPath)
+	cmd.Flags().StringArrayVar(&cfg.ImageBuildAgent.AgentArgs, agentArgFlag, nil, "Argument the guest agent is started with, repeatable")
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.Runc, runcFlag, "", "runc binary installed as "+guestimage.RuncPath)
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.ModulesDir, modulesDirFlag, "", "Modules of the guest kernel (/lib/modules/VERSION) copied into the image, none if empty")
+	cmd.Flags().StringSliceVar(&cfg.ImageBuildAgent.Modules, moduleFlag, nil, "Kernel modules loaded on boot, e.g. overlay")
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.Hostname, hostnameFlag, "microvm", "Hostname of the guests")
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.TemplateDir, templateDirFlag, "", "Directory of templates laid out as the root filesystem, rendered into the image over the builtin ones")
+	cmd.Flags().StringToStringVar(&cfg.ImageBuildAgent.Vars, varFlag, nil, "Variables (KEY=VALUE) of the templates, as .Vars")
+	cmd.Flags().StringVar(&cfg.ImageBuildAgent.Format, formatFlag, guestimage.FormatSquashfs, "Format of the image, squashfs for the drive of the VMs or initramfs")
+	cmd.Flags().StringVarP(&cfg.ImageBuildAgent.Output, outputFlag, "o", "rootfs.img", "File the image is written to")
+}
+
+func AddDevUpFlags(cmd *cobra.Command, cfg *Config) {
+	AddCommonFlags(cmd, cfg)
+	AddClusterFlags(cmd, cfg)
+	cmd.Flags().StringVar(&cfg.Dev.RegistryAddr, registryAddrFlag, "127.0.0.1:5000", "Bind address of the embedded registry, images pushed to it are spawned as localhost:PORT/NAME")
+	cmd.Flags().StringVar(&cfg.Dev.RegistryDir, registryDirFlag, filepath.Join(defaults.DataRootDir, "dev", "registry"), "Directory the images pushed to the embedded registry are stored in")
+	cmd.Flags().StringSliceVar(&cfg.Dev.SampleImages, sampleImagesFlag, []string{"docker.io/library/nginx:alpine", "docker.io/library/hello-world:latest"}, "Images pulled before the node is ready so the first spawns start at once")
+	cmd.Flags().DurationVar(&cfg.Dev.SimulatedLatency, simulatedLatencyFlag, time.Millisecond*50, "Delay added to each GRPC request, simulating a node reached over a WAN, 0 to disable")
+
+	// Single node cluster reachable from this host only, without
+	// authentication
+	for name, value := range map[string]string{
+		clusterBindAddrFlag: "127.0.0.1:7946",
+		grpcBindAddrFlag:    "127.0.0.1:8000",
+		gatewayBindAddrFlag: "127.0.0.1:8080",
+		bridgeFlag:          "hypercore0",
+		clusterBaseURLFlag:  "localhost",
+	} {
+		flag := cmd.Flags().Lookup(name)
+		_ = flag.Value.Set(value)
+		flag.DefValue = flag.Value.String()
+	}
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"vistara-node/pkg/guestimage"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func ImageCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "build the images the microVMs boot from",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return c.Help()
+		},
+	}
+
+	cmd.AddCommand(ImageBuildAgentCommand(cfg))
+
+	return cmd
+}
+
+func ImageBuildAgentCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build-agent",
+		Short: "build the guest rootfs or initramfs with the guest agent, runc and kernel modules",
+		Long: `Builds the image the microVMs boot from on a base root filesystem, installing
+the guest agent and runc, the modules of the guest kernel, and the boot files
+rendered from the builtin templates: the overlay init, the systemd target and
+unit starting the agent, the hostname and the modules loaded on boot. Files of
+the template directory override the builtin templates or add to them`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			build := cfg.ImageBuildAgent
+
+			err := guestimage.Build(&guestimage.Config{
+				BaseRootfs:  build.BaseRootfs,
+				AgentBin:    build.Agent,
+				AgentArgs:   build.AgentArgs,
+				RuncBin:     build.Runc,
+				ModulesDir:  build.ModulesDir,
+				Modules:     build.Modules,
+				Hostname:    build.Hostname,
+				TemplateDir: build.TemplateDir,
+				Vars:        build.Vars,
+				Format:      build.Format,
+				Output:      build.Output,
+			})
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Wrote %s image %s", build.Format, build.Output)
+
+			return nil
+		},
+	}
+
+	AddImageBuildAgentFlags(cmd, cfg)
+
+	return cmd
+}
@@ -0,0 +1,343 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+
+	ctask "github.com/containerd/containerd/api/types/task"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// Binary name containerd resolves shimRuntimeType to
+	shimBinaryName = "containerd-shim-hypercore-example"
+
+	// How long containerd has to come back after being restarted
+	containerdRestartTimeout = time.Second * 30
+	// How long the verification microVM has to run to completion
+	verifyTimeout = time.Minute * 2
+)
+
+func InstallRuntimeCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install-runtime",
+		Short: "Register the hypercore shim with containerd and check that it runs microVMs",
+		Long: `Links the shim binary under the name containerd resolves the runtime to,
+adds the runtime to the containerd config (the original is kept as .bak),
+restarts containerd and runs a microVM from the verification image`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			shimPath, err := installShimBinary(cfg.InstallRuntime.ShimDir)
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Linked shim binary at %s", shimPath)
+
+			if err := writeRuntimeConfig(cfg.InstallRuntime.ContainerdConfig, cfg.RuntimeClass.Name, shimPath); err != nil {
+				return err
+			}
+
+			log.Infof("Added runtime %s to %s", cfg.RuntimeClass.Name, cfg.InstallRuntime.ContainerdConfig)
+
+			if err := reloadContainerd(cfg.InstallRuntime.ContainerdUnit); err != nil {
+				return err
+			}
+
+			if cfg.InstallRuntime.VerifyImage == "" {
+				return nil
+			}
+
+			code, err := verifyRuntime(cmd.Context(), cfg)
+			if err != nil {
+				return fmt.Errorf("failed to run verification microVM: %w", err)
+			}
+
+			if code != 0 {
+				return fmt.Errorf("verification microVM exited with status %d", code)
+			}
+
+			log.Infof("Verification microVM of %s ran successfully", cfg.InstallRuntime.VerifyImage)
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddInstallRuntimeFlags(cmd, cfg)
+
+	return cmd
+}
+
+// installShimBinary links the running binary as the shim binary in dir,
+// returning the path of the link
+func installShimBinary(dir string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the hypercore binary: %w", err)
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the hypercore binary: %w", err)
+	}
+
+	shimPath := filepath.Join(dir, shimBinaryName)
+	if shimPath == executable {
+		return shimPath, nil
+	}
+
+	if err := os.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+		return "", err
+	}
+
+	if err := os.Remove(shimPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to replace %s: %w", shimPath, err)
+	}
+
+	if err := os.Symlink(executable, shimPath); err != nil {
+		return "", fmt.Errorf("failed to link %s to %s: %w", shimPath, executable, err)
+	}
+
+	return shimPath, nil
+}
+
+// writeRuntimeConfig adds the runtime to the CRI plugin section of the
+// containerd config (version 2), creating it if needed
+func writeRuntimeConfig(path, name, shimPath string) error {
+	config := map[string]interface{}{}
+
+	contents, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read containerd config %s: %w", path, err)
+	default:
+		if err := toml.Unmarshal(contents, &config); err != nil {
+			return fmt.Errorf("failed to parse containerd config %s: %w", path, err)
+		}
+
+		if err := os.WriteFile(path+".bak", contents, defaults.DataFilePerm); err != nil {
+			return fmt.Errorf("failed to back up containerd config %s: %w", path, err)
+		}
+	}
+
+	if version, ok := config["version"]; ok && fmt.Sprint(version) != "2" {
+		return fmt.Errorf("unsupported containerd config version %v in %s, expected 2", version, path)
+	}
+	config["version"] = 2
+
+	plugins := tomlTable(config, "plugins")
+	if _, ok := plugins["io.containerd.snapshotter.v1.devmapper"]; !ok {
+		log.Warnf("No devmapper snapshotter configured in %s, microVMs need one", path)
+	}
+
+	runtimes := tomlTable(plugins, "io.containerd.grpc.v1.cri", "containerd", "runtimes")
+	runtimes[name] = map[string]interface{}{
+		"runtime_type":    shimRuntimeType,
+		"runtime_path":    shimPath,
+		"snapshotter":     "devmapper",
+		"pod_annotations": []string{"hypercore.io/*"},
+	}
+
+	encoded, err := toml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode containerd config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, encoded, defaults.DataFilePerm); err != nil {
+		return fmt.Errorf("failed to write containerd config %s: %w", path, err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// tomlTable returns the nested table at keys, creating the missing ones
+func tomlTable(table map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		next, ok := table[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			table[key] = next
+		}
+
+		table = next
+	}
+
+	return table
+}
+
+// reloadContainerd restarts the containerd systemd unit, or sends SIGHUP
+// to the containerd process if it isn't managed by systemd
+func reloadContainerd(unit string) error {
+	if unit != "" {
+		if _, err := exec.LookPath("systemctl"); err == nil {
+			if err := exec.Command("systemctl", "is-active", "--quiet", unit).Run(); err == nil {
+				log.Infof("Restarting %s", unit)
+
+				if output, err := exec.Command("systemctl", "restart", unit).CombinedOutput(); err != nil {
+					return fmt.Errorf("failed to restart %s: %w: %s", unit, err, output)
+				}
+
+				return nil
+			}
+		}
+	}
+
+	pids, err := processesNamed("containerd")
+	if err != nil {
+		return err
+	}
+
+	if len(pids) == 0 {
+		log.Warn("containerd isn't running, start it to use the runtime")
+
+		return nil
+	}
+
+	for _, pid := range pids {
+		log.Infof("Sending SIGHUP to containerd (pid %d)", pid)
+
+		if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+			return fmt.Errorf("failed to signal containerd (pid %d): %w", pid, err)
+		}
+	}
+
+	return nil
+}
+
+func processesNamed(name string) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var pids []int
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(comm)) == name {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// verifyRuntime runs the verification image as a microVM with the VM
+// defaults of hac.toml and returns its exit status
+func verifyRuntime(ctx context.Context, cfg *Config) (uint32, error) {
+	hacContents, err := os.ReadFile(cfg.HACFile)
+	if err != nil {
+		return 0, err
+	}
+
+	hacConfig := HacConfig{}
+	if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+		return 0, err
+	}
+
+	repo, err := containerd.NewMicroVMRepository(containerdConfig(cfg, containerd.OwnerMicroVM))
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	// containerd may still be starting after the restart
+	var id string
+
+	for start := time.Now(); ; {
+		id, err = repo.CreateContainer(ctx, containerd.CreateContainerOpts{
+			ImageRef:    cfg.InstallRuntime.VerifyImage,
+			Snapshotter: "devmapper",
+			Runtime: struct {
+				Name    string
+				Options interface{}
+			}{
+				Name: "hypercore.example",
+				Options: &models.MicroVMSpec{
+					Provider:   cfg.DefaultVMProvider,
+					VCPU:       1,
+					MemoryInMb: 512,
+					HostNetDev: hacConfig.Hardware.Interface,
+					Kernel:     hacConfig.Hardware.Kernel,
+					RootfsPath: hacConfig.Hardware.Drive,
+					Arch:       hacConfig.Hardware.Arch,
+				},
+			},
+			CioCreator: containerd.BufferedIO(int(cfg.StdioBufferSize)),
+		})
+		if err == nil || time.Since(start) > containerdRestartTimeout {
+			break
+		}
+
+		log.WithError(err).Info("Waiting for containerd")
+		time.Sleep(time.Second)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		task, err := repo.GetTask(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+
+		if task.GetStatus() == ctask.Status_STOPPED {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			if _, err := repo.DeleteContainer(context.Background(), id, 0); err != nil {
+				log.WithError(err).Errorf("failed to delete verification microVM %s", id)
+			}
+
+			return 0, fmt.Errorf("microVM %s still running: %w", id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return repo.DeleteContainer(context.Background(), id, 0)
+}
@@ -0,0 +1,231 @@
+package hypercore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+	"vistara-node/pkg/defaults"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Validity of the CA created by issue-cert when there is none
+	clusterCAValidity = time.Hour * 24 * 365 * 10
+	// Margin for clock skew between the nodes
+	certBackdate = time.Minute * 5
+)
+
+func ClusterIssueCertCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "issue-cert NAME",
+		Short: "Issue a short-lived node certificate from the cluster CA",
+		Long: `Issues a certificate for the node NAME signed by the cluster CA, which is
+created if it doesn't exist yet. The certificate carries the SPIFFE ID
+spiffe://<trust domain>/node/NAME if a trust domain is set. The agent reloads
+its certificates when their files change, so re-issuing them before they
+expire (e.g. from a systemd timer) rotates them without a restart`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			caCert, caKey, err := loadOrCreateCA(cfg.IssueCert.CACert, cfg.IssueCert.CAKey)
+			if err != nil {
+				return err
+			}
+
+			certPEM, keyPEM, err := issueCert(caCert, caKey, args[0], cfg.SpiffeTrustDomain, cfg.IssueCert.SANs, cfg.IssueCert.TTL)
+			if err != nil {
+				return err
+			}
+
+			if err := writeFileAtomic(cfg.IssueCert.OutKey, keyPEM, 0o600); err != nil {
+				return err
+			}
+
+			if err := writeFileAtomic(cfg.IssueCert.OutCert, certPEM, defaults.DataFilePerm); err != nil {
+				return err
+			}
+
+			log.Infof("Issued certificate %s for %s, valid for %s", cfg.IssueCert.OutCert, args[0], cfg.IssueCert.TTL)
+
+			return nil
+		},
+	}
+
+	AddClusterIssueCertFlags(cmd, cfg)
+
+	return cmd
+}
+
+// loadOrCreateCA loads the cluster CA, generating a self-signed one if
+// neither of its files exist
+func loadOrCreateCA(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		if err := createCA(certPath, keyPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load CA %s/%s: %w", certPath, keyPath, err)
+	}
+
+	caCert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA %s: %w", certPath, err)
+	}
+
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported CA key %s", keyPath)
+	}
+
+	return caCert, signer, nil
+}
+
+func createCA(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "hypercore cluster CA"},
+		NotBefore:             now.Add(-certBackdate),
+		NotAfter:              now.Add(clusterCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create CA: %w", err)
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(keyPath, keyPEM, 0o600); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), defaults.DataFilePerm); err != nil {
+		return err
+	}
+
+	log.Infof("Created cluster CA %s", certPath)
+
+	return nil
+}
+
+// issueCert returns a certificate for a node, usable both to serve and
+// to connect to other nodes, and its key, PEM encoded
+func issueCert(caCert *x509.Certificate, caKey crypto.Signer, name, trustDomain string, sans []string, ttl time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    now.Add(-certBackdate),
+		NotAfter:     now.Add(ttl),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if template.NotAfter.After(caCert.NotAfter) {
+		return nil, nil, fmt.Errorf("certificate would outlive the CA, which expires at %s", caCert.NotAfter)
+	}
+
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	if trustDomain != "" {
+		template.URIs = []*url.URL{{Scheme: "spiffe", Host: trustDomain, Path: "/node/" + name}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// writeFileAtomic replaces a file so readers never see it partially
+// written, the agent may reload it at any time
+func writeFileAtomic(path string, contents []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -23,10 +23,8 @@ func Run() {
 	}
 
 	cmd.AddCommand(ClusterCommand(cfg))
-	cmd.AddCommand(AttachCommand(cfg))
-	cmd.AddCommand(ListCommand(cfg))
-	cmd.AddCommand(SpawnCommand(cfg))
-	cmd.AddCommand(StopCommand(cfg))
+	cmd.AddCommand(DebugCommand(cfg))
+	cmd.AddCommand(nodeCommands(cfg)...)
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
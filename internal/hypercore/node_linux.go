@@ -0,0 +1,55 @@
+//go:build linux
+
+package hypercore
+
+import "github.com/spf13/cobra"
+
+// nodeCommands returns the commands managing the local node
+func nodeCommands(cfg *Config) []*cobra.Command {
+	return []*cobra.Command{
+		AttachCommand(cfg),
+		ListCommand(cfg),
+		SpawnCommand(cfg),
+		StopCommand(cfg),
+		ServeCommand(cfg),
+		RuntimeClassCommand(cfg),
+		InstallRuntimeCommand(cfg),
+		PoolCommand(cfg),
+		CheckRootlessCommand(cfg),
+		PreflightCommand(cfg),
+		DevCommand(cfg),
+		ImageCommand(cfg),
+		DiskCommand(cfg),
+		ConsoleCommand(cfg),
+		BenchCommand(cfg),
+	}
+}
+
+// clusterNodeCommands returns the cluster commands acting on the agent of
+// the local node rather than through its API
+func clusterNodeCommands(cfg *Config) []*cobra.Command {
+	return []*cobra.Command{
+		ClusterDaemonCommand(cfg),
+		ClusterBackupCommand(cfg),
+		ClusterRestoreCommand(cfg),
+	}
+}
+
+// debugNodeCommands returns the debug commands inspecting the shims of the
+// local node
+func debugNodeCommands(cfg *Config) []*cobra.Command {
+	return []*cobra.Command{
+		DebugShimCommand(cfg),
+		DebugAttestCommand(cfg),
+		DebugDescribeVMCommand(cfg),
+		DebugProfileCommand(cfg),
+	}
+}
+
+// addClusterAgentFlags adds the flags of the cluster agent run by the
+// cluster command
+func addClusterAgentFlags(cmd *cobra.Command, cfg *Config) {
+	// TODO remove hac/vmm flags
+	AddCommonFlags(cmd, cfg)
+	AddClusterFlags(cmd, cfg)
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package hypercore
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// The cluster agent, the VMs and the containers only run on Linux, hosts
+// of other systems only get the commands driving a cluster through its API
+
+func nodeCommands(*Config) []*cobra.Command {
+	return nil
+}
+
+func clusterNodeCommands(*Config) []*cobra.Command {
+	return nil
+}
+
+func debugNodeCommands(*Config) []*cobra.Command {
+	return nil
+}
+
+func addClusterAgentFlags(*cobra.Command, *Config) {}
+
+func runClusterAgent(*Config, []string) error {
+	return errors.New("the cluster agent only runs on Linux, use the cluster subcommands to drive a cluster from this host")
+}
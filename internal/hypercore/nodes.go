@@ -0,0 +1,212 @@
+package hypercore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func ClusterNodesCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "list the nodes of a cluster and their status",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Nodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			for _, node := range resp.GetNodes() {
+				maintenance := ""
+				if node.GetMaintenanceStartUnixTime() != 0 {
+					maintenance = fmt.Sprintf(", maintenance: %s to %s",
+						time.Unix(node.GetMaintenanceStartUnixTime(), 0).Format(time.RFC3339), time.Unix(node.GetMaintenanceEndUnixTime(), 0).Format(time.RFC3339))
+				}
+
+				log.Infof("Node %s (%s): %s, capabilities: %s%s", node.GetId(), node.GetIp(), nodeStatusName(node.GetStatus()), strings.Join(node.GetCapabilities(), ","), maintenance)
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+
+	return cmd
+}
+
+func nodeStatusName(status pb.NodeStatus) string {
+	return strings.ToLower(strings.TrimPrefix(status.String(), "NODE_"))
+}
+
+func ClusterJoinCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "join ADDRESS",
+		Short: "make a running node join the cluster of the node at the serf address",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			node, err := c.Join(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Node %s is %s", node.GetId(), nodeStatusName(node.GetStatus()))
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterDrainCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drain [NODE]",
+		Short: "stop placing workloads on a node and move its workloads to the other nodes",
+		Long: `Marks the node (the one the client is connected to if none is given) as
+draining, workloads are no longer placed on it. Each of its workloads is
+replaced by a healthy replica on another node before it is stopped, so it
+keeps serving requests until then. Follow the progress with cluster events`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			node, err := c.Drain(cmd.Context(), firstArg(args))
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Node %s is %s", node.GetId(), nodeStatusName(node.GetStatus()))
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterLeaveCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "leave [NODE]",
+		Short: "make a node leave the cluster",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			node, err := c.Leave(cmd.Context(), firstArg(args), cfg.ClusterLeave.Force)
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Node %s is %s", node.GetId(), nodeStatusName(node.GetStatus()))
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterLeaveFlags(cmd, cfg)
+
+	return cmd
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
+
+func ClusterAdoptCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "adopt CONTAINER-ID",
+		Short: "bring a container created outside of hypercore under the management of the cluster",
+		Long: `Makes a running container of the containerd namespace of a node a workload
+of the cluster, monitored, listed and respawned like spawned ones. Its spec
+is synthesized from the container (image, CPU and memory limits, environment)
+and the flags set override it. Only runc containers can be adopted`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Adopt(cmd.Context(), cfg.ClusterAdopt.Node, args[0], &pb.VmSpawnRequest{
+				Tenant: cfg.ClusterAdopt.Tenant,
+				Name:   cfg.ClusterAdopt.Name,
+				Cores:  uint32(cfg.ClusterAdopt.CPU),
+				Memory: cfg.ClusterAdopt.Memory,
+			})
+			if err != nil {
+				return err
+			}
+
+			spec := resp.GetSpec()
+			log.Infof("Container %s on node %s is now a workload of image %s, %d cores, %d MB", resp.GetId(), resp.GetNode(), spec.GetImageRef(), spec.GetCores(), spec.GetMemory())
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterAdoptFlags(cmd, cfg)
+
+	return cmd
+}
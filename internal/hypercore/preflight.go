@@ -0,0 +1,56 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"vistara-node/pkg/cgroup"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+func PreflightCommand(_ *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check whether this host can run microVM workloads",
+		Long: `Checks the read-write /dev/kvm access microVMs need, and probes the
+virtualization features of the host VMs can opt into, such as nested
+virtualization, also advertised in the capabilities of the node, and the
+cgroup mode workload limits are enforced with`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := shared.CheckNestedVirt(); err != nil {
+				fmt.Fprintf(os.Stdout, "warning: %s, VMs can't enable nested_virt\n", err)
+			} else {
+				fmt.Fprintf(os.Stdout, "Nested virtualization is supported\n")
+			}
+
+			fmt.Fprintf(os.Stdout, "Workload limits are enforced with cgroup %s\n", cgroup.DetectMode())
+
+			if device, err := cgroup.BlockDevice(defaults.DataRootDir); err != nil {
+				fmt.Fprintf(os.Stdout, "warning: %s, workloads can't have disk limits\n", err)
+			} else {
+				fmt.Fprintf(os.Stdout, "Disk limits apply to block device %s\n", device)
+			}
+
+			if err := unix.Access("/dev/kvm", unix.R_OK|unix.W_OK); err != nil {
+				return errors.New("no read-write access to /dev/kvm, microVM workloads can't run, load the kvm module or add the user to the kvm group")
+			}
+
+			fmt.Fprintf(os.Stdout, "MicroVM workloads can run\n")
+
+			return nil
+		},
+	}
+
+	return cmd
+}
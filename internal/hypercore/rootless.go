@@ -0,0 +1,46 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"fmt"
+	"os"
+	"vistara-node/pkg/containerd"
+
+	"github.com/spf13/cobra"
+)
+
+func CheckRootlessCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-rootless",
+		Short: "Check whether this host can run workloads without root",
+		Long: `Checks the capabilities --rootless needs: user namespaces with subordinate
+IDs for the user and the user-mode network stack, as well as the cgroup
+delegation resource limits need and the /dev/kvm access microVMs need`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			support := containerd.CheckRootless(cfg.RootlessNetwork)
+
+			for _, warning := range support.Warnings {
+				fmt.Fprintf(os.Stdout, "warning: %s\n", warning)
+			}
+
+			if err := support.Err(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stdout, "Rootless workloads can run with %s networking\n", cfg.RootlessNetwork)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&cfg.RootlessNetwork, rootlessNetworkFlag, containerd.RootlessNetworkSlirp4netns,
+		fmt.Sprintf("User-mode network stack of rootless workloads, %s or %s", containerd.RootlessNetworkSlirp4netns, containerd.RootlessNetworkPasta))
+
+	return cmd
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package hypercore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+
+	"github.com/spf13/cobra"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// Name under which containerd resolves the shim binary
+const shimRuntimeType = "io.containerd.hypercore.example"
+
+const runtimeClassTemplate = `# Add to the containerd config (version 2), then restart containerd
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.%[1]s]
+  runtime_type = %[2]q
+  snapshotter = "devmapper"
+  pod_annotations = ["hypercore.io/*"]
+  [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.%[1]s.options]
+    ConfigPath = %[3]q
+
+---
+# kubectl apply -f
+apiVersion: node.k8s.io/v1
+kind: RuntimeClass
+metadata:
+  name: %[1]s
+handler: %[1]s
+`
+
+func RuntimeClassCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runtimeclass",
+		Short: "Set up this node to run Kubernetes pods as microVMs through a RuntimeClass",
+		Long: `Writes the VM defaults of this node (kernel, guest rootfs, provider and host
+interface, taken from hac.toml) to the runtime config read by the shim, and
+prints the containerd CRI runtime and the RuntimeClass to register`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			hacContents, err := os.ReadFile(cfg.HACFile)
+			if err != nil {
+				return err
+			}
+
+			hacConfig := HacConfig{}
+			if err := toml.Unmarshal(hacContents, &hacConfig); err != nil {
+				return err
+			}
+
+			// vCPUs and memory are left out so the VMs are sized
+			// from the pod resources or annotations
+			runtimeConfig, err := json.MarshalIndent(&models.MicroVMSpec{
+				Provider:   cfg.DefaultVMProvider,
+				Kernel:     hacConfig.Hardware.Kernel,
+				RootfsPath: hacConfig.Hardware.Drive,
+				Arch:       hacConfig.Hardware.Arch,
+				HostNetDev: hacConfig.Hardware.Interface,
+			}, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(filepath.Dir(cfg.RuntimeClass.ConfigPath), defaults.DataDirPerm); err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(cfg.RuntimeClass.ConfigPath, runtimeConfig, defaults.DataFilePerm); err != nil {
+				return fmt.Errorf("failed to write runtime config: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), runtimeClassTemplate, cfg.RuntimeClass.Name, shimRuntimeType, cfg.RuntimeClass.ConfigPath)
+
+			return nil
+		},
+	}
+
+	AddCommonFlags(cmd, cfg)
+	AddRuntimeClassFlags(cmd, cfg)
+
+	return cmd
+}
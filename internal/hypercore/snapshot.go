@@ -0,0 +1,264 @@
+package hypercore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/snapshot"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// snapshotStore returns the object store configured by the snapshot flags
+func snapshotStore(cfg *Config) (*snapshot.Store, error) {
+	if cfg.Snapshots.Bucket == "" {
+		return nil, fmt.Errorf("no snapshot store configured, set --%s and --%s", snapshotEndpointFlag, snapshotBucketFlag)
+	}
+
+	s3, err := snapshot.NewS3(snapshot.S3Config{
+		Endpoint: cfg.Snapshots.Endpoint,
+		Region:   cfg.Snapshots.Region,
+		Bucket:   cfg.Snapshots.Bucket,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.NewStore(s3, cfg.Snapshots.Prefix), nil
+}
+
+func snapshotRetention(cfg *Config) snapshot.Retention {
+	return snapshot.Retention{KeepLast: cfg.Snapshots.KeepLast, MaxAge: cfg.Snapshots.MaxAge}
+}
+
+func logSnapshot(s *pb.VolumeSnapshot) {
+	log.Infof("%s: %d bytes, %d uploaded, taken %s", s.GetRef(), s.GetSizeBytes(), s.GetUploadedBytes(),
+		time.Unix(s.GetCreatedUnixTime(), 0).Format(time.RFC3339))
+}
+
+func ClusterSnapshotCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot VOLUME",
+		Short: "snapshot a persistent volume to the object store of the node holding it",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.SnapshotVolume(cmd.Context(), &pb.SnapshotVolumeRequest{
+				Volume: args[0],
+				Tenant: cfg.ClusterSnapshot.Tenant,
+			})
+			if err != nil {
+				return err
+			}
+
+			logSnapshot(resp)
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterSnapshotFlags(cmd, cfg)
+
+	return cmd
+}
+
+func ClusterSnapshotsCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "list the snapshots of the persistent volumes",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.ListSnapshots(cmd.Context(), &pb.ListSnapshotsRequest{
+				Volume: cfg.ClusterSnapshots.Volume,
+				Tenant: cfg.ClusterSnapshots.Tenant,
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(resp.GetSnapshots()) == 0 {
+				log.Info("No snapshots")
+
+				return nil
+			}
+
+			for _, s := range resp.GetSnapshots() {
+				logSnapshot(s)
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterSnapshotsFlags(cmd, cfg)
+
+	return cmd
+}
+
+func DiskCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk",
+		Short: "snapshot the disk images of the microVMs to an object store and restore them",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return c.Help()
+		},
+	}
+
+	cmd.AddCommand(DiskSnapshotCommand(cfg))
+	cmd.AddCommand(DiskRestoreCommand(cfg))
+	cmd.AddCommand(DiskSnapshotsCommand(cfg))
+
+	return cmd
+}
+
+func DiskSnapshotCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot PATH NAME",
+		Short: "snapshot a disk image, or a directory, under a name",
+		Long: `Uploads the blocks of the disk image that changed since the latest snapshot
+of the same name, then prunes the snapshots of the name past the retention.
+Blocks of zeroes aren't uploaded and are restored as holes. Stop the microVM
+using the disk first, the image isn't frozen while it is read`,
+		Args: cobra.ExactArgs(2),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := snapshotStore(cfg)
+			if err != nil {
+				return err
+			}
+
+			manifest, err := store.Create(cmd.Context(), args[1], "", args[0])
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Snapshotted %s as %s, uploaded %d of %d bytes", args[0], manifest.Ref(), manifest.Uploaded, manifest.Size)
+
+			pruned, err := store.Prune(cmd.Context(), args[1], snapshotRetention(cfg), time.Now())
+			for _, manifest := range pruned {
+				log.Infof("Pruned snapshot %s", manifest.Ref())
+			}
+
+			return err
+		},
+	}
+
+	AddSnapshotStoreFlags(cmd, cfg)
+
+	return cmd
+}
+
+func DiskRestoreCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore SNAPSHOT PATH",
+		Short: "restore a snapshot, NAME for the latest one or NAME@ID, to a path that doesn't exist",
+		Args:  cobra.ExactArgs(2),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Lstat(args[1]); !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%s already exists", args[1])
+			}
+
+			store, err := snapshotStore(cfg)
+			if err != nil {
+				return err
+			}
+
+			manifest, err := store.Find(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if err := store.Restore(cmd.Context(), manifest, args[1]); err != nil {
+				return err
+			}
+
+			log.Infof("Restored snapshot %s to %s", manifest.Ref(), args[1])
+
+			return nil
+		},
+	}
+
+	AddSnapshotStoreFlags(cmd, cfg)
+
+	return cmd
+}
+
+func DiskSnapshotsCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshots NAME",
+		Short: "list the snapshots of a disk image",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := snapshot.ValidateName(args[0]); err != nil {
+				return err
+			}
+
+			store, err := snapshotStore(cfg)
+			if err != nil {
+				return err
+			}
+
+			manifests, err := store.List(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(manifests) == 0 {
+				log.Infof("No snapshots of %s", args[0])
+
+				return nil
+			}
+
+			for _, manifest := range manifests {
+				log.Infof("%s: %d bytes, %d uploaded, taken %s", manifest.Ref(), manifest.Size, manifest.Uploaded,
+					manifest.Created.Format(time.RFC3339))
+			}
+
+			return nil
+		},
+	}
+
+	AddSnapshotStoreFlags(cmd, cfg)
+
+	return cmd
+}
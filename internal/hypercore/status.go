@@ -0,0 +1,85 @@
+package hypercore
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func ClusterStatusCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show a summary of the health of the cluster",
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+			fmt.Fprintf(w, "Node\t%s\n", resp.GetNode())
+
+			for _, status := range slices.Sorted(maps.Keys(resp.GetNodes())) {
+				fmt.Fprintf(w, "Nodes %s\t%d\n", status, resp.GetNodes()[status])
+			}
+
+			fmt.Fprintf(w, "vCPUs committed\t%d/%d\n", resp.GetCpusCommitted(), resp.GetCpus())
+			fmt.Fprintf(w, "Memory committed\t%d/%d MB\n", resp.GetMemoryCommitted(), resp.GetMemory())
+
+			for _, state := range []string{"ready", "unready", "queued"} {
+				fmt.Fprintf(w, "Workloads %s\t%d\n", state, resp.GetWorkloads()[state])
+			}
+
+			queues := resp.GetSerfQueues()
+			fmt.Fprintf(w, "Serf queues\tevent %d, query %d, intent %d\n", queues.GetEvent(), queues.GetQuery(), queues.GetIntent())
+			fmt.Fprintf(w, "Last reconcile\t%s\n", sinceUnix(resp.GetLastReconcileUnixTime()))
+
+			if len(resp.GetServices()) > 0 {
+				fmt.Fprintf(w, "\nSERVICE\tBACKENDS\tREADY\tUNREADY\n")
+
+				for _, service := range resp.GetServices() {
+					fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", service.GetServiceId(), service.GetBackends(), service.GetReady(), service.GetUnready())
+				}
+			}
+
+			if len(resp.GetNodeUpdates()) > 0 {
+				fmt.Fprintf(w, "\nNODE\tLAST STATE\n")
+
+				for _, update := range resp.GetNodeUpdates() {
+					fmt.Fprintf(w, "%s\t%s\n", update.GetNode(), sinceUnix(update.GetUnixTime()))
+				}
+			}
+
+			return w.Flush()
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+
+	return cmd
+}
+
+// sinceUnix formats the time elapsed since a unix time, never for 0
+func sinceUnix(unixTime int64) string {
+	if unixTime == 0 {
+		return "never"
+	}
+
+	return time.Since(time.Unix(unixTime, 0)).Round(time.Second).String() + " ago"
+}
@@ -0,0 +1,139 @@
+package hypercore
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vistara-node/pkg/client"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/spf13/cobra"
+)
+
+// Points of the usage history shown by the sparklines, consecutive points
+// are averaged for longer histories
+const sparklineWidth = 60
+
+var sparklineBars = []rune("▁▂▃▄▅▆▇█")
+
+func ClusterTopCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "top ID|NAME",
+		Short: "show the resource usage of a workload in a cluster over the last hour",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := clusterClient(cfg, client.WithTenant(cfg.ClusterTop.Tenant))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Usage(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			points := resp.GetPoints()
+			period := time.Duration(resp.GetPeriodSeconds()) * time.Second
+			out := cmd.OutOrStdout()
+
+			fmt.Fprintf(out, "Workload %s on node %s, last %s at %s resolution\n", resp.GetId(), resp.GetNode(), period*time.Duration(len(points)), period)
+
+			if len(points) == 0 {
+				fmt.Fprintln(out, "No usage recorded yet")
+
+				return nil
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+			series := []struct {
+				name   string
+				value  func(*pb.UsagePoint) float64
+				format func(float64) string
+			}{
+				{"CPU", (*pb.UsagePoint).GetCpus, func(v float64) string { return fmt.Sprintf("%.2f vCPUs", v) }},
+				{"Memory", func(p *pb.UsagePoint) float64 { return float64(p.GetMemoryBytes()) }, formatBytes},
+				{"Net rx", (*pb.UsagePoint).GetRxBytesPerSecond, func(v float64) string { return formatBytes(v) + "/s" }},
+				{"Net tx", (*pb.UsagePoint).GetTxBytesPerSecond, func(v float64) string { return formatBytes(v) + "/s" }},
+			}
+
+			for _, s := range series {
+				values := make([]float64, len(points))
+				for i, point := range points {
+					values[i] = s.value(point)
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s (max %s)\n", s.name, sparkline(values), s.format(values[len(values)-1]), s.format(maxValue(values)))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterTopFlags(cmd, cfg)
+
+	return cmd
+}
+
+// sparkline draws the values scaled to their maximum, averaging them down
+// to sparklineWidth bars
+func sparkline(values []float64) string {
+	buckets := min(len(values), sparklineWidth)
+	averages := make([]float64, buckets)
+
+	for i := range buckets {
+		start, end := i*len(values)/buckets, (i+1)*len(values)/buckets
+
+		sum := 0.0
+		for _, value := range values[start:end] {
+			sum += value
+		}
+
+		averages[i] = sum / float64(end-start)
+	}
+
+	highest := maxValue(averages)
+
+	var line strings.Builder
+
+	for _, value := range averages {
+		bar := 0
+		if highest > 0 {
+			bar = int(value / highest * float64(len(sparklineBars)-1))
+		}
+
+		line.WriteRune(sparklineBars[bar])
+	}
+
+	return line.String()
+}
+
+func maxValue(values []float64) float64 {
+	highest := 0.0
+	for _, value := range values {
+		highest = max(highest, value)
+	}
+
+	return highest
+}
+
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
@@ -0,0 +1,162 @@
+package hypercore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vistara-node/pkg/client"
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// How often the state of an upgraded node is checked
+const upgradePollInterval = time.Second * 2
+
+func ClusterUpgradeCommand(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade [NODE...]",
+		Short: "upgrade the hypercore binary of the cluster nodes one at a time",
+		Long: `Upgrades the given nodes, all the nodes of the cluster if none is given, one
+after the other. Each node stops accepting new workloads, downloads the new
+binary and checks its digest, then restarts its agent in place while its
+workloads keep running. The next node is only upgraded once the node is
+ready again and reports all of its workloads`,
+		PreRunE: func(c *cobra.Command, _ []string) error {
+			BindCommandToViper(c)
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.ClusterUpgrade.BinaryURL == "" || cfg.ClusterUpgrade.SHA256 == "" {
+				return fmt.Errorf("--%s and --%s are required", binaryURLFlag, sha256Flag)
+			}
+
+			c, err := clusterClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			nodes := args
+			if len(nodes) == 0 {
+				resp, err := c.Nodes(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				for _, node := range resp.GetNodes() {
+					nodes = append(nodes, node.GetId())
+				}
+
+				sort.Strings(nodes)
+			}
+
+			for _, node := range nodes {
+				// Start times are in seconds, an agent restarting
+				// within the same second still counts as restarted
+				requestedAt := time.Now().Unix()
+
+				resp, err := c.Upgrade(cmd.Context(), node, cfg.ClusterUpgrade.BinaryURL, cfg.ClusterUpgrade.SHA256)
+				if err != nil {
+					return fmt.Errorf("failed to upgrade node %s: %w", node, err)
+				}
+
+				log.Infof("Node %s is restarting with the new binary, waiting for its %d workloads", node, len(resp.GetWorkloads()))
+
+				if err := waitUpgraded(cmd.Context(), c, node, requestedAt, resp.GetWorkloads(), cfg.ClusterUpgrade.Timeout); err != nil {
+					return err
+				}
+
+				log.Infof("Node %s is upgraded", node)
+			}
+
+			return nil
+		},
+	}
+
+	AddClusterClientFlags(cmd, cfg)
+	AddClusterUpgradeFlags(cmd, cfg)
+
+	return cmd
+}
+
+// waitUpgraded waits until the agent of an upgraded node restarted, is
+// ready and reports all the workloads it ran before the upgrade. The
+// client may be connected to the node, failing requests are retried
+func waitUpgraded(ctx context.Context, c *client.Client, node string, startedAfter int64, workloads []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(upgradePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %s didn't come back with its workloads within %s", node, timeout)
+		case <-ticker.C:
+		}
+
+		reconciled, err := upgradeReconciled(ctx, c, node, startedAfter, workloads)
+		if err != nil {
+			log.Debugf("Node %s isn't reachable yet: %s", node, err)
+
+			continue
+		}
+
+		if reconciled {
+			return nil
+		}
+	}
+}
+
+func upgradeReconciled(ctx context.Context, c *client.Client, node string, startedAfter int64, workloads []string) (bool, error) {
+	nodes, err := c.Nodes(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	restarted := false
+
+	for _, member := range nodes.GetNodes() {
+		if member.GetId() == node {
+			restarted = member.GetStatus() == pb.NodeStatus_NODE_READY && member.GetStartedUnixTime() >= startedAfter
+		}
+	}
+
+	if !restarted {
+		return false, nil
+	}
+
+	running := make(map[string]struct{})
+	req := &pb.VmQueryRequest{Node: node}
+
+	for {
+		resp, err := c.List(ctx, req)
+		if err != nil {
+			return false, err
+		}
+
+		for id := range resp.GetVms() {
+			running[id] = struct{}{}
+		}
+
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+
+		req.PageToken = resp.GetNextPageToken()
+	}
+
+	for _, id := range workloads {
+		if _, ok := running[id]; !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
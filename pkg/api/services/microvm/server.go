@@ -0,0 +1,257 @@
+package microvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	"vistara-node/pkg/hypervisor/cloudhypervisor"
+	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/models"
+	pb "vistara-node/pkg/proto/microvm"
+
+	"github.com/containerd/containerd"
+	ctask "github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// SpecLabel holds the spec a microVM was created with, it also
+	// marks the containers managed by the VMService
+	SpecLabel = "hypercore-microvm-spec"
+
+	RuntimeName = "hypercore.example"
+	Snapshotter = "devmapper"
+
+	minMemoryMb = 1024
+	maxMemoryMb = 32768
+	maxVCPU     = 64
+)
+
+type server struct {
+	pb.UnimplementedVMServiceServer
+	logger *log.Logger
+	repo   *vcontainerd.Repo
+}
+
+func (s *server) Create(ctx context.Context, req *pb.CreateMicroVMRequest) (*pb.CreateMicroVMResponse, error) {
+	spec := req.GetSpec()
+	if err := validateSpec(spec); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	encodedSpec, err := protojson.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Creating microVM with spec %v", spec)
+
+	id, err := s.repo.CreateContainer(ctx, vcontainerd.CreateContainerOpts{
+		ImageRef:    spec.GetImageRef(),
+		Snapshotter: Snapshotter,
+		Runtime: struct {
+			Name    string
+			Options interface{}
+		}{
+			Name: RuntimeName,
+			Options: &models.MicroVMSpec{
+				Provider:   spec.GetProvider(),
+				VCPU:       spec.GetVcpu(),
+				MemoryInMb: spec.GetMemoryMb(),
+				HostNetDev: spec.GetHostNetDev(),
+				Kernel:     spec.GetKernel(),
+				RootfsPath: spec.GetRootfsPath(),
+				GuestMAC:   spec.GetGuestMac(),
+				Arch:       spec.GetArch(),
+			},
+		},
+		Labels:     map[string]string{SpecLabel: string(encodedSpec)},
+		CioCreator: cio.NullIO,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create microVM: %s", err)
+	}
+
+	vm, err := s.describe(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateMicroVMResponse{Microvm: vm}, nil
+}
+
+func (s *server) Delete(ctx context.Context, req *pb.DeleteMicroVMRequest) (*pb.DeleteMicroVMResponse, error) {
+	// Make sure the container is a microVM managed by this service
+	if _, err := s.describe(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Deleting microVM %s", req.GetId())
+
+	code, err := s.repo.DeleteContainer(ctx, req.GetId(), 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete microVM %s: %s", req.GetId(), err)
+	}
+
+	return &pb.DeleteMicroVMResponse{ExitStatus: code}, nil
+}
+
+func (s *server) Get(ctx context.Context, req *pb.GetMicroVMRequest) (*pb.GetMicroVMResponse, error) {
+	vm, err := s.describe(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetMicroVMResponse{Microvm: vm}, nil
+}
+
+func (s *server) List(ctx context.Context, _ *pb.ListMicroVMsRequest) (*pb.ListMicroVMsResponse, error) {
+	containers, err := s.repo.ListContainers(ctx, fmt.Sprintf("labels.%q", SpecLabel))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list containers: %s", err)
+	}
+
+	resp := &pb.ListMicroVMsResponse{}
+
+	for _, container := range containers {
+		vm, err := s.describe(ctx, container.ID())
+		if err != nil {
+			// Deleted in the meantime
+			if status.Code(err) == codes.NotFound {
+				continue
+			}
+
+			return nil, err
+		}
+
+		resp.Microvms = append(resp.Microvms, vm)
+	}
+
+	return resp, nil
+}
+
+// describe returns the spec and live state of a microVM
+func (s *server) describe(ctx context.Context, id string) (*pb.MicroVM, error) {
+	container, err := s.repo.GetContainer(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "microVM %s not found", id)
+		}
+
+		return nil, status.Errorf(codes.Internal, "failed to load container %s: %s", id, err)
+	}
+
+	vm, err := specFromContainer(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.repo.GetTask(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(errdefs.FromGRPC(err)) {
+			// The container exists but its task is gone
+			vm.State = pb.MicroVMState_STOPPED
+
+			return vm, nil
+		}
+
+		return nil, status.Errorf(codes.Internal, "failed to get task of microVM %s: %s", id, err)
+	}
+
+	vm.State = taskState(task.GetStatus())
+	vm.Pid = task.GetPid()
+	vm.ExitStatus = task.GetExitStatus()
+
+	if vm.GetState() == pb.MicroVMState_RUNNING {
+		if ip, err := s.repo.GetContainerPrimaryIP(ctx, id); err == nil {
+			vm.Ip = ip
+		} else {
+			s.logger.WithError(err).Warnf("failed to get IP of microVM %s", id)
+		}
+	}
+
+	return vm, nil
+}
+
+func specFromContainer(ctx context.Context, container containerd.Container) (*pb.MicroVM, error) {
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get labels of container %s: %s", container.ID(), err)
+	}
+
+	encodedSpec, ok := labels[SpecLabel]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "microVM %s not found", container.ID())
+	}
+
+	var spec pb.MicroVMSpec
+	if err := protojson.Unmarshal([]byte(encodedSpec), &spec); err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid spec label on container %s: %s", container.ID(), err)
+	}
+
+	return &pb.MicroVM{Id: container.ID(), Spec: &spec}, nil
+}
+
+func taskState(taskStatus ctask.Status) pb.MicroVMState {
+	switch taskStatus {
+	case ctask.Status_CREATED:
+		return pb.MicroVMState_CREATED
+	case ctask.Status_RUNNING:
+		return pb.MicroVMState_RUNNING
+	case ctask.Status_STOPPED:
+		return pb.MicroVMState_STOPPED
+	case ctask.Status_PAUSED:
+		return pb.MicroVMState_PAUSED
+	case ctask.Status_PAUSING:
+		return pb.MicroVMState_PAUSING
+	case ctask.Status_UNKNOWN:
+		fallthrough
+	default:
+		return pb.MicroVMState_UNKNOWN
+	}
+}
+
+// validateSpec mirrors the constraints of models.MicroVMSpec
+func validateSpec(spec *pb.MicroVMSpec) error {
+	switch spec.GetProvider() {
+	case firecracker.HypervisorName, cloudhypervisor.HypervisorName:
+	default:
+		return fmt.Errorf("unsupported provider %q", spec.GetProvider())
+	}
+
+	if spec.GetImageRef() == "" {
+		return errors.New("image_ref is required")
+	}
+
+	if spec.GetVcpu() < 1 || spec.GetVcpu() > maxVCPU {
+		return fmt.Errorf("vcpu must be between 1 and %d", maxVCPU)
+	}
+
+	if spec.GetMemoryMb() < minMemoryMb || spec.GetMemoryMb() > maxMemoryMb {
+		return fmt.Errorf("memory_mb must be between %d and %d", minMemoryMb, maxMemoryMb)
+	}
+
+	return nil
+}
+
+// NewServer creates a gRPC server exposing the VMService, with server
+// reflection enabled so it can be used with tools like grpcurl
+func NewServer(logger *log.Logger, repo *vcontainerd.Repo) *grpc.Server {
+	grpcServer := grpc.NewServer()
+	pb.RegisterVMServiceServer(grpcServer, &server{
+		logger: logger,
+		repo:   repo,
+	})
+	reflection.Register(grpcServer)
+
+	return grpcServer
+}
@@ -0,0 +1,306 @@
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups/v3"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/oci"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// Mode is the cgroup hierarchy resource limits are enforced with
+type Mode int
+
+const (
+	// Controllers each in their own hierarchy, including the hybrid
+	// hosts mounting the unified hierarchy without controllers along
+	ModeV1 Mode = iota
+	// The unified hierarchy
+	ModeV2
+)
+
+func (m Mode) String() string {
+	if m == ModeV2 {
+		return "v2"
+	}
+
+	return "v1"
+}
+
+// Root is where the cgroup hierarchies of the host are mounted
+const Root = "/sys/fs/cgroup"
+
+// CPUPeriod is the period (in µs) the CPU quota of the workloads is
+// enforced over
+// https://docs.docker.com/engine/containers/resource_constraints/#configure-the-default-cfs-scheduler
+const CPUPeriod = 100000
+
+// DetectMode returns the cgroup mode of the host
+func DetectMode() Mode {
+	if cgroups.Mode() == cgroups.Unified {
+		return ModeV2
+	}
+
+	return ModeV1
+}
+
+// Limits are the resources a workload can use
+type Limits struct {
+	// Share of the CPUs of the host
+	CPUFraction float64
+	// 0 for no limit
+	MemoryBytes uint64
+	// Usage above which the workload is throttled and its memory
+	// reclaimed, 0 for none. With cgroup v1 it is the soft limit, only
+	// enforced when the host is short of memory
+	MemoryHighBytes uint64
+	// Limits of the disk holding the workloads, nil for none
+	IO *IOLimits
+}
+
+// IOLimits are the bandwidth and operations per second a workload can
+// use on a disk, 0 for no limit
+type IOLimits struct {
+	ReadBPS   uint64
+	WriteBPS  uint64
+	ReadIOPS  uint64
+	WriteIOPS uint64
+}
+
+// Device is a block device, by number
+type Device struct {
+	Major int64
+	Minor int64
+}
+
+func (d Device) String() string {
+	return fmt.Sprintf("%d:%d", d.Major, d.Minor)
+}
+
+// BlockDevice returns the disk holding path, rather than its partition,
+// which is what the io controller limits
+func BlockDevice(path string) (Device, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return Device{}, err
+	}
+
+	device := Device{Major: int64(unix.Major(stat.Dev)), Minor: int64(unix.Minor(stat.Dev))}
+
+	sysPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%s", device))
+	if err != nil {
+		return Device{}, fmt.Errorf("%s isn't on a block device: %w", path, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err != nil {
+		return device, nil
+	}
+
+	// The parent of a partition in sysfs is its disk
+	contents, err := os.ReadFile(filepath.Join(sysPath, "..", "dev"))
+	if err != nil {
+		return Device{}, err
+	}
+
+	major, minor, ok := strings.Cut(strings.TrimSpace(string(contents)), ":")
+	if !ok {
+		return Device{}, fmt.Errorf("invalid device number %q", contents)
+	}
+
+	if device.Major, err = strconv.ParseInt(major, 10, 64); err != nil {
+		return Device{}, err
+	}
+
+	if device.Minor, err = strconv.ParseInt(minor, 10, 64); err != nil {
+		return Device{}, err
+	}
+
+	return device, nil
+}
+
+// apply sets the limits on the resources of an OCI spec. With cgroup v2
+// they are set as the unified values runc writes as is (cpu.max,
+// memory.max, memory.high and io.max), rather than converted from their
+// cgroup v1 equivalents. The IO limits apply to ioDevice
+func (l *Limits) apply(mode Mode, ioDevice *Device, resources *specs.LinuxResources) error {
+	quota := int64(l.CPUFraction * CPUPeriod)
+	memory := int64(l.MemoryBytes)
+	memoryHigh := int64(l.MemoryHighBytes)
+
+	if l.IO != nil && ioDevice == nil {
+		return errors.New("disk limits aren't supported on this node")
+	}
+
+	if mode == ModeV1 {
+		resources.CPU = &specs.LinuxCPU{Quota: &quota, Period: ptr(uint64(CPUPeriod))}
+
+		if memory > 0 || memoryHigh > 0 {
+			resources.Memory = &specs.LinuxMemory{}
+		}
+
+		if memory > 0 {
+			resources.Memory.Limit = &memory
+		}
+
+		if memoryHigh > 0 {
+			resources.Memory.Reservation = &memoryHigh
+		}
+
+		if l.IO != nil {
+			resources.BlockIO = &specs.LinuxBlockIO{
+				ThrottleReadBpsDevice:   throttleDevices(*ioDevice, l.IO.ReadBPS),
+				ThrottleWriteBpsDevice:  throttleDevices(*ioDevice, l.IO.WriteBPS),
+				ThrottleReadIOPSDevice:  throttleDevices(*ioDevice, l.IO.ReadIOPS),
+				ThrottleWriteIOPSDevice: throttleDevices(*ioDevice, l.IO.WriteIOPS),
+			}
+		}
+
+		return nil
+	}
+
+	if resources.Unified == nil {
+		resources.Unified = make(map[string]string)
+	}
+
+	if quota > 0 {
+		resources.Unified["cpu.max"] = fmt.Sprintf("%d %d", quota, CPUPeriod)
+	}
+
+	if memory > 0 {
+		resources.Unified["memory.max"] = strconv.FormatInt(memory, 10)
+	}
+
+	if memoryHigh > 0 {
+		resources.Unified["memory.high"] = strconv.FormatInt(memoryHigh, 10)
+	}
+
+	if l.IO != nil {
+		if ioMax := l.IO.ioMax(); ioMax != "" {
+			resources.Unified["io.max"] = ioDevice.String() + " " + ioMax
+		}
+	}
+
+	return nil
+}
+
+// ioMax returns the limits as the keys of io.max, the unset ones being
+// left to their default of max
+func (l *IOLimits) ioMax() string {
+	var keys []string
+
+	for _, limit := range []struct {
+		key   string
+		value uint64
+	}{{"rbps", l.ReadBPS}, {"wbps", l.WriteBPS}, {"riops", l.ReadIOPS}, {"wiops", l.WriteIOPS}} {
+		if limit.value > 0 {
+			keys = append(keys, fmt.Sprintf("%s=%d", limit.key, limit.value))
+		}
+	}
+
+	return strings.Join(keys, " ")
+}
+
+func throttleDevices(device Device, rate uint64) []specs.LinuxThrottleDevice {
+	if rate == 0 {
+		return nil
+	}
+
+	throttle := specs.LinuxThrottleDevice{Rate: rate}
+	throttle.Major = device.Major
+	throttle.Minor = device.Minor
+
+	return []specs.LinuxThrottleDevice{throttle}
+}
+
+// Resources returns the resources of an OCI spec setting the limits, for
+// runc to update those of a running container
+func (l *Limits) Resources(mode Mode, ioDevice *Device) (*specs.LinuxResources, error) {
+	resources := &specs.LinuxResources{}
+	if err := l.apply(mode, ioDevice, resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// WithLimits sets the limits of a container, for the cgroup mode of the
+// host
+func WithLimits(mode Mode, ioDevice *Device, limits *Limits) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Linux == nil {
+			s.Linux = &specs.Linux{}
+		}
+
+		if s.Linux.Resources == nil {
+			s.Linux.Resources = &specs.LinuxResources{}
+		}
+
+		return limits.apply(mode, ioDevice, s.Linux.Resources)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// Portable returns resources with the unified values of cpu.max,
+// memory.max and memory.high replaced by the fields runc converts for
+// either cgroup mode, for the guests of the VMs whose mode may differ
+// from the host's. The disk limits are left out, the disks of the guests
+// being others
+func Portable(resources *specs.LinuxResources) *specs.LinuxResources {
+	if resources == nil {
+		return nil
+	}
+
+	portable := *resources
+	portable.BlockIO = nil
+	portable.Unified = nil
+
+	if quota, period, ok := strings.Cut(resources.Unified["cpu.max"], " "); ok {
+		q, qErr := strconv.ParseInt(quota, 10, 64)
+		p, pErr := strconv.ParseUint(period, 10, 64)
+
+		if qErr == nil && pErr == nil {
+			portable.CPU = &specs.LinuxCPU{Quota: &q, Period: &p}
+		}
+	}
+
+	if limit, err := strconv.ParseInt(resources.Unified["memory.max"], 10, 64); err == nil {
+		portable.Memory = &specs.LinuxMemory{Limit: &limit}
+	}
+
+	if high, err := strconv.ParseInt(resources.Unified["memory.high"], 10, 64); err == nil {
+		memory := specs.LinuxMemory{}
+		if portable.Memory != nil {
+			memory = *portable.Memory
+		}
+
+		memory.Reservation = &high
+		portable.Memory = &memory
+	}
+
+	return &portable
+}
+
+// MemoryHigh returns the memory high limit (in bytes) of resources, 0 if
+// they set none
+func MemoryHigh(resources *specs.LinuxResources) uint64 {
+	if high, err := strconv.ParseUint(resources.Unified["memory.high"], 10, 64); err == nil {
+		return high
+	}
+
+	if resources.Memory != nil && resources.Memory.Reservation != nil {
+		return uint64(*resources.Memory.Reservation)
+	}
+
+	return 0
+}
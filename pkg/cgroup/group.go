@@ -0,0 +1,179 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// HypervisorGroup is the cgroup, relative to the root of the hierarchies,
+// the hypervisor of the VM of a task runs in
+func HypervisorGroup(taskID string) string {
+	return "/hypercore/" + taskID
+}
+
+// Group is a cgroup of processes runc doesn't manage, such as the
+// hypervisors of the VMs. With cgroup v1 it spans the cpu, memory and
+// blkio hierarchies
+type Group struct {
+	// directories of the group, one per hierarchy with cgroup v1
+	dirs []string
+}
+
+// NewGroup creates the cgroup at path with the CPU, memory and block IO
+// limits of resources, as set by WithLimits for the cgroup mode of the
+// host. Devices aren't restricted
+func NewGroup(path string, resources *specs.LinuxResources) (*Group, error) {
+	hierarchies := []string{""}
+	if DetectMode() == ModeV1 {
+		hierarchies = v1Hierarchies
+	}
+
+	group := &Group{}
+
+	for _, hierarchy := range hierarchies {
+		dir := filepath.Join(Root, hierarchy, path)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			_ = group.Delete()
+
+			return nil, fmt.Errorf("failed to create cgroup %s: %w", dir, err)
+		}
+
+		group.dirs = append(group.dirs, dir)
+	}
+
+	if DetectMode() == ModeV2 {
+		if err := enableControllers(path, resources.Unified); err != nil {
+			_ = group.Delete()
+
+			return nil, err
+		}
+	}
+
+	if err := group.Update(resources); err != nil {
+		_ = group.Delete()
+
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// Update sets the limits of resources on the group, leaving those they
+// don't set as they are
+func (g *Group) Update(resources *specs.LinuxResources) error {
+	files := v1Files(resources)
+	if DetectMode() == ModeV2 {
+		files = map[string]map[string]string{"": resources.Unified}
+	}
+
+	for _, dir := range g.dirs {
+		hierarchy, _, _ := strings.Cut(strings.TrimPrefix(dir, Root+"/"), "/")
+		if DetectMode() == ModeV2 {
+			hierarchy = ""
+		}
+
+		for file, value := range files[hierarchy] {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0); err != nil {
+				return fmt.Errorf("failed to set %s of cgroup %s: %w", file, dir, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Hierarchies the groups span with cgroup v1, the cpu and memory ones
+// accounting for their usage
+var v1Hierarchies = []string{"cpu", "memory", "blkio"}
+
+// v1Files returns the files of the cgroup v1 hierarchies the resources
+// are written to, by hierarchy
+func v1Files(resources *specs.LinuxResources) map[string]map[string]string {
+	files := map[string]map[string]string{"cpu": {}, "memory": {}, "blkio": {}}
+
+	if cpu := resources.CPU; cpu != nil && cpu.Quota != nil && cpu.Period != nil {
+		files["cpu"]["cpu.cfs_period_us"] = strconv.FormatUint(*cpu.Period, 10)
+		files["cpu"]["cpu.cfs_quota_us"] = strconv.FormatInt(*cpu.Quota, 10)
+	}
+
+	if memory := resources.Memory; memory != nil {
+		if memory.Limit != nil {
+			files["memory"]["memory.limit_in_bytes"] = strconv.FormatInt(*memory.Limit, 10)
+		}
+
+		if memory.Reservation != nil {
+			files["memory"]["memory.soft_limit_in_bytes"] = strconv.FormatInt(*memory.Reservation, 10)
+		}
+	}
+
+	if blockIO := resources.BlockIO; blockIO != nil {
+		for file, devices := range map[string][]specs.LinuxThrottleDevice{
+			"blkio.throttle.read_bps_device":   blockIO.ThrottleReadBpsDevice,
+			"blkio.throttle.write_bps_device":  blockIO.ThrottleWriteBpsDevice,
+			"blkio.throttle.read_iops_device":  blockIO.ThrottleReadIOPSDevice,
+			"blkio.throttle.write_iops_device": blockIO.ThrottleWriteIOPSDevice,
+		} {
+			for _, device := range devices {
+				files["blkio"][file] = fmt.Sprintf("%d:%d %d", device.Major, device.Minor, device.Rate)
+			}
+		}
+	}
+
+	return files
+}
+
+// enableControllers delegates the controllers of the unified values to
+// the cgroup at path from the root down, the cpu and memory ones always
+func enableControllers(path string, values map[string]string) error {
+	controllers := []string{"+cpu", "+memory"}
+
+	for key := range values {
+		if controller, _, _ := strings.Cut(key, "."); controller == "io" {
+			controllers = append(controllers, "+io")
+
+			break
+		}
+	}
+
+	parent := Root
+
+	for _, name := range strings.Split(strings.Trim(filepath.Dir(path), "/"), "/") {
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0); err != nil {
+			return fmt.Errorf("failed to enable the controllers of %s: %w", parent, err)
+		}
+
+		parent = filepath.Join(parent, name)
+	}
+
+	return os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0)
+}
+
+// Add moves a process into the group
+func (g *Group) Add(pid int) error {
+	for _, dir := range g.dirs {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0); err != nil {
+			return fmt.Errorf("failed to add process %d to cgroup %s: %w", pid, dir, err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the group, once its processes exited
+func (g *Group) Delete() error {
+	var errs []error
+
+	for _, dir := range g.dirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
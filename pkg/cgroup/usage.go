@@ -0,0 +1,128 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Usage returns the cumulative CPU usage (in ns) and the current memory
+// usage (in bytes) of the cgroup the given process belongs to
+func Usage(pid uint32) (uint64, uint64, error) {
+	contents, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Hybrid hosts list the unified hierarchy as well, without the
+	// controllers accounting for the usage
+	if DetectMode() == ModeV2 {
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			// 0::/system.slice/containerd.service
+			split := strings.SplitN(line, ":", 3)
+			if len(split) == 3 && split[0] == "0" && split[1] == "" {
+				return v2Usage(Root + split[2])
+			}
+		}
+
+		return 0, 0, fmt.Errorf("could not find cgroup for pid %d", pid)
+	}
+
+	var cpuPath, memPath string
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		// 4:cpu,cpuacct:/default/<id>
+		split := strings.SplitN(line, ":", 3)
+		if len(split) != 3 {
+			continue
+		}
+
+		for _, controller := range strings.Split(split[1], ",") {
+			switch controller {
+			case "cpuacct":
+				cpuPath = fmt.Sprintf("%s/%s%s/cpuacct.usage", Root, split[1], split[2])
+			case "memory":
+				memPath = fmt.Sprintf("%s/memory%s/memory.usage_in_bytes", Root, split[2])
+			}
+		}
+	}
+
+	if cpuPath == "" || memPath == "" {
+		return 0, 0, fmt.Errorf("could not find cgroup for pid %d", pid)
+	}
+
+	return v1Usage(cpuPath, memPath)
+}
+
+// GroupUsage returns the usage of the cgroup at path, like Usage
+func GroupUsage(path string) (uint64, uint64, error) {
+	if DetectMode() == ModeV2 {
+		return v2Usage(Root + path)
+	}
+
+	return v1Usage(Root+"/cpuacct"+path+"/cpuacct.usage", Root+"/memory"+path+"/memory.usage_in_bytes")
+}
+
+func v1Usage(cpuPath, memPath string) (uint64, uint64, error) {
+	cpuUsage, err := readUintFile(cpuPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	memUsage, err := readUintFile(memPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return cpuUsage, memUsage, nil
+}
+
+func v2Usage(cgroupPath string) (uint64, uint64, error) {
+	file, err := os.Open(cgroupPath + "/cpu.stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var cpuUsage uint64
+	found := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// usage_usec 1234
+		split := strings.Fields(scanner.Text())
+		if len(split) == 2 && split[0] == "usage_usec" {
+			usec, err := strconv.ParseUint(split[1], 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			cpuUsage = usec * 1000
+			found = true
+
+			break
+		}
+	}
+
+	if !found {
+		return 0, 0, errors.New("could not find usage_usec in cpu.stat")
+	}
+
+	memUsage, err := readUintFile(cgroupPath + "/memory.current")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return cpuUsage, memUsage, nil
+}
+
+func readUintFile(path string) (uint64, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+}
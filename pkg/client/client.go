@@ -0,0 +1,733 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"vistara-node/pkg/compression"
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// APIVersion is the ClusterService API version this client speaks,
+	// servers reject clients built against a different version
+	APIVersion = pb.APIVersion
+
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+)
+
+// Client talks to the ClusterService of a hypercore cluster node
+type Client struct {
+	conn    *grpc.ClientConn
+	cluster pb.ClusterServiceClient
+	debug   pb.DebugServiceClient
+	retries int
+	backoff time.Duration
+	tenant  string
+}
+
+type config struct {
+	dialOpts       []grpc.DialOption
+	tlsConfig      *tls.Config
+	retries        int
+	backoff        time.Duration
+	tenant         string
+	compression    string
+	maxMessageSize int
+}
+
+type Option func(cfg *config) error
+
+// WithToken authenticates every request with the given bearer token
+func WithToken(token string) Option {
+	return func(cfg *config) error {
+		cfg.dialOpts = append(cfg.dialOpts, grpc.WithPerRPCCredentials(tokenCredentials(token)))
+
+		return nil
+	}
+}
+
+// WithTLS connects to the node over TLS
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(cfg *config) error {
+		cfg.tlsConfig = tlsConfig
+
+		return nil
+	}
+}
+
+// WithMTLS connects to the node over TLS, presenting the given client
+// certificate and verifying the node against caFile
+func WithMTLS(certFile, keyFile, caFile string) Option {
+	return func(cfg *config) error {
+		if certFile == "" || keyFile == "" || caFile == "" {
+			return errors.New("a certificate, key and CA are required for mutual TLS")
+		}
+
+		return WithTLSFiles(certFile, keyFile, caFile)(cfg)
+	}
+}
+
+// WithTLSFiles connects to the node over TLS, presenting the client
+// certificate if certFile and keyFile are set and verifying the node
+// against caFile if it is set, the roots of the system otherwise
+func WithTLSFiles(certFile, keyFile, caFile string) Option {
+	return func(cfg *config) error {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if certFile != "" || keyFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return fmt.Errorf("failed to load key pair %s/%s: %w", certFile, keyFile, err)
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return fmt.Errorf("failed to read CA %s: %w", caFile, err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in CA %s", caFile)
+			}
+
+			tlsConfig.RootCAs = pool
+		}
+
+		cfg.tlsConfig = tlsConfig
+
+		return nil
+	}
+}
+
+// WithRetries sets how many times a request is retried when the node is
+// unavailable, waiting backoff (doubled on every attempt) in between
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(cfg *config) error {
+		if retries < 0 {
+			return fmt.Errorf("invalid retry count %d", retries)
+		}
+
+		cfg.retries = retries
+		cfg.backoff = backoff
+
+		return nil
+	}
+}
+
+// WithCompression compresses requests, and have the server compress its
+// responses, with compression.Gzip or compression.Zstd
+func WithCompression(name string) Option {
+	return func(cfg *config) error {
+		if err := compression.Validate(name); err != nil {
+			return err
+		}
+
+		cfg.compression = name
+
+		return nil
+	}
+}
+
+// WithMaxMessageSize sets the size limit of the received and sent
+// messages, defaults.GrpcMaxMessageSize by default
+func WithMaxMessageSize(size int) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid max message size %d", size)
+		}
+
+		cfg.maxMessageSize = size
+
+		return nil
+	}
+}
+
+// WithTenant resolves the workload names given to Stop and Logs among
+// the workloads of tenant
+func WithTenant(tenant string) Option {
+	return func(cfg *config) error {
+		cfg.tenant = tenant
+
+		return nil
+	}
+}
+
+// WithDialOptions passes additional options to the underlying gRPC client
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(cfg *config) error {
+		cfg.dialOpts = append(cfg.dialOpts, opts...)
+
+		return nil
+	}
+}
+
+func New(addr string, opts ...Option) (*Client, error) {
+	cfg := &config{
+		retries:        DefaultRetries,
+		backoff:        DefaultBackoff,
+		maxMessageSize: defaults.GrpcMaxMessageSize,
+	}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, fmt.Errorf("creating hypercore client: %w", err)
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.tlsConfig != nil {
+		creds = credentials.NewTLS(cfg.tlsConfig)
+	}
+
+	callOpts := []grpc.CallOption{
+		grpc.MaxCallRecvMsgSize(cfg.maxMessageSize),
+		grpc.MaxCallSendMsgSize(cfg.maxMessageSize),
+	}
+	if cfg.compression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(cfg.compression))
+	}
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(callOpts...),
+		grpc.WithChainUnaryInterceptor(versionUnaryInterceptor),
+		grpc.WithChainStreamInterceptor(versionStreamInterceptor),
+	}, cfg.dialOpts...)
+
+	conn, err := grpc.NewClient(addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client for %s: %w", addr, err)
+	}
+
+	return &Client{
+		conn:    conn,
+		cluster: pb.NewClusterServiceClient(conn),
+		debug:   pb.NewDebugServiceClient(conn),
+		retries: cfg.retries,
+		backoff: cfg.backoff,
+		tenant:  cfg.tenant,
+	}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Spawn schedules a workload on the cluster, requests without an
+// idempotency key get one so retries don't spawn it twice
+func (c *Client) Spawn(ctx context.Context, req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error) {
+	if req.GetIdempotencyKey() == "" {
+		req = proto.Clone(req).(*pb.VmSpawnRequest)
+		req.IdempotencyKey = uuid.NewString()
+	}
+
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.VmSpawnResponse, error) {
+		return c.cluster.Spawn(ctx, req)
+	})
+}
+
+// Stop stops a workload, or all replicas of a replica group, by ID or by
+// name
+func (c *Client) Stop(ctx context.Context, id string) (*pb.VmStopResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.VmStopResponse, error) {
+		return c.cluster.Stop(ctx, &pb.VmStopRequest{Id: id, Tenant: c.tenant})
+	})
+}
+
+// StopSelected stops every workload of the tenant of the client, or of
+// all tenants if it has none, matching the label selector
+func (c *Client) StopSelected(ctx context.Context, selector string) (*pb.VmStopResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.VmStopResponse, error) {
+		return c.cluster.Stop(ctx, &pb.VmStopRequest{LabelSelector: selector, Tenant: c.tenant})
+	})
+}
+
+// StopMany stops the workloads matching the label selector of req or
+// given by its IDs, with the tenant of the client unless req has one. The
+// result of each workload is streamed as soon as it was stopped, then a
+// summary, the error channel receives nil once all were received
+func (c *Client) StopMany(ctx context.Context, req *pb.BulkRequest) (<-chan *pb.BulkProgress, <-chan error) {
+	req = c.bulkRequest(req)
+
+	return bulkProgress(ctx, func() (grpc.ServerStreamingClient[pb.BulkProgress], error) {
+		return withRetries(ctx, c, func(ctx context.Context) (grpc.ServerStreamingClient[pb.BulkProgress], error) {
+			return c.cluster.StopMany(ctx, req)
+		})
+	})
+}
+
+// RestartMany restarts workloads like StopMany stops them, it isn't
+// retried like Restart
+func (c *Client) RestartMany(ctx context.Context, req *pb.BulkRequest) (<-chan *pb.BulkProgress, <-chan error) {
+	req = c.bulkRequest(req)
+
+	return bulkProgress(ctx, func() (grpc.ServerStreamingClient[pb.BulkProgress], error) {
+		return c.cluster.RestartMany(ctx, req)
+	})
+}
+
+func (c *Client) bulkRequest(req *pb.BulkRequest) *pb.BulkRequest {
+	if req.GetTenant() != "" || c.tenant == "" {
+		return req
+	}
+
+	req = proto.Clone(req).(*pb.BulkRequest)
+	req.Tenant = c.tenant
+
+	return req
+}
+
+// bulkProgress forwards the messages of a bulk request stream
+func bulkProgress(ctx context.Context, open func() (grpc.ServerStreamingClient[pb.BulkProgress], error)) (<-chan *pb.BulkProgress, <-chan error) {
+	progressCh := make(chan *pb.BulkProgress)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(progressCh)
+		defer close(errCh)
+
+		stream, err := open()
+		if err != nil {
+			errCh <- err
+
+			return
+		}
+
+		for {
+			progress, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+
+				errCh <- err
+
+				return
+			}
+
+			select {
+			case progressCh <- progress:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+
+				return
+			}
+		}
+	}()
+
+	return progressCh, errCh
+}
+
+// Restart stops a workload and creates it again with the same ID on the
+// node running it, it isn't retried as the workload may have restarted
+func (c *Client) Restart(ctx context.Context, id string) (*pb.RestartResponse, error) {
+	return c.cluster.Restart(ctx, &pb.RestartRequest{Id: id, Tenant: c.tenant})
+}
+
+// List returns a page of the workloads running across the cluster that
+// match the filters of req, the next page is requested with the
+// next_page_token of the response
+func (c *Client) List(ctx context.Context, req *pb.VmQueryRequest) (*pb.VmQueryResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.VmQueryResponse, error) {
+		return c.cluster.List(ctx, req)
+	})
+}
+
+// Logs returns up to tailBytes from the end of the logs of a workload,
+// given by ID or by name, 0 returns the server default
+func (c *Client) Logs(ctx context.Context, id string, tailBytes uint32) ([]byte, error) {
+	resp, err := withRetries(ctx, c, func(ctx context.Context) (*pb.VmLogsResponse, error) {
+		return c.cluster.Logs(ctx, &pb.VmLogsRequest{Id: id, TailBytes: tailBytes, Tenant: c.tenant})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetLogs(), nil
+}
+
+// SelectedLogs returns up to tailBytes from the end of the logs of every
+// workload matching the label selector, by ID, like StopSelected
+func (c *Client) SelectedLogs(ctx context.Context, selector string, tailBytes uint32) (map[string][]byte, error) {
+	resp, err := withRetries(ctx, c, func(ctx context.Context) (*pb.VmLogsResponse, error) {
+		return c.cluster.Logs(ctx, &pb.VmLogsRequest{LabelSelector: selector, TailBytes: tailBytes, Tenant: c.tenant})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetWorkloadLogs(), nil
+}
+
+// Apply creates or updates the named workload so it matches spec,
+// retries with the same idempotency key return the original result
+func (c *Client) Apply(ctx context.Context, name string, spec *pb.VmSpawnRequest, idempotencyKey string) (*pb.WorkloadDescription, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.WorkloadDescription, error) {
+		return c.cluster.Apply(ctx, &pb.ApplyRequest{Name: name, Spec: spec, IdempotencyKey: idempotencyKey})
+	})
+}
+
+// Get returns the normalized spec and replicas of a named workload
+func (c *Client) Get(ctx context.Context, name string) (*pb.WorkloadDescription, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.WorkloadDescription, error) {
+		return c.cluster.Get(ctx, &pb.GetRequest{Name: name})
+	})
+}
+
+// SpawnBatch schedules all the requests at once, packing them on as few
+// nodes as possible unless spread is set. The result of each request is
+// streamed as soon as it completes, the error channel receives nil once
+// all results were received
+func (c *Client) SpawnBatch(ctx context.Context, requests []*pb.VmSpawnRequest, spread bool) (<-chan *pb.SpawnBatchResponse, <-chan error) {
+	resultCh := make(chan *pb.SpawnBatchResponse)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(resultCh)
+		defer close(errCh)
+
+		stream, err := withRetries(ctx, c, func(ctx context.Context) (grpc.ServerStreamingClient[pb.SpawnBatchResponse], error) {
+			return c.cluster.SpawnBatch(ctx, &pb.SpawnBatchRequest{Requests: requests, Spread: spread})
+		})
+		if err != nil {
+			errCh <- err
+
+			return
+		}
+
+		for {
+			result, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+
+				errCh <- err
+
+				return
+			}
+
+			select {
+			case resultCh <- result:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+
+				return
+			}
+		}
+	}()
+
+	return resultCh, errCh
+}
+
+// Metrics returns the capacity and usage of the cluster nodes and the
+// traffic served by the proxies, along with their history since the
+// given time unless it is zero
+func (c *Client) Metrics(ctx context.Context, since time.Time) (*pb.MetricsResponse, error) {
+	req := &pb.MetricsRequest{}
+	if !since.IsZero() {
+		req.SinceUnixTime = since.Unix()
+	}
+
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.MetricsResponse, error) {
+		return c.cluster.Metrics(ctx, req)
+	})
+}
+
+// PlanSpawn returns the nodes the request and its replicas would be
+// placed on, and the capacity the cluster would have left
+func (c *Client) PlanSpawn(ctx context.Context, req *pb.VmSpawnRequest) (*pb.PlanSpawnResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.PlanSpawnResponse, error) {
+		return c.cluster.PlanSpawn(ctx, req)
+	})
+}
+
+// CacheStatus returns the images the nodes pulled ahead of the
+// placements they are likely to get, optionally only those of a node or
+// only an image
+func (c *Client) CacheStatus(ctx context.Context, req *pb.CacheStatusRequest) (*pb.CacheStatusResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.CacheStatusResponse, error) {
+		return c.cluster.CacheStatus(ctx, req)
+	})
+}
+
+func (c *Client) SnapshotVolume(ctx context.Context, req *pb.SnapshotVolumeRequest) (*pb.VolumeSnapshot, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.VolumeSnapshot, error) {
+		return c.cluster.SnapshotVolume(ctx, req)
+	})
+}
+
+func (c *Client) ListSnapshots(ctx context.Context, req *pb.ListSnapshotsRequest) (*pb.ListSnapshotsResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.ListSnapshotsResponse, error) {
+		return c.cluster.ListSnapshots(ctx, req)
+	})
+}
+
+// UpdateWorkload replaces the replicas of a workload with replicas running
+// the updated spec, one at a time
+func (c *Client) UpdateWorkload(ctx context.Context, req *pb.UpdateWorkloadRequest) (*pb.UpdateWorkloadResponse, error) {
+	return c.cluster.UpdateWorkload(ctx, req)
+}
+
+// Rollback rolls a workload back to a previous revision, 0 for the one
+// before the current revision
+func (c *Client) Rollback(ctx context.Context, id string, revision uint32) (*pb.UpdateWorkloadResponse, error) {
+	return c.cluster.Rollback(ctx, &pb.RollbackRequest{Id: id, Revision: revision})
+}
+
+// Canary spawns replicas of the updated spec of a workload and sends them
+// a share of its traffic
+func (c *Client) Canary(ctx context.Context, req *pb.CanaryRequest) (*pb.UpdateWorkloadResponse, error) {
+	return c.cluster.Canary(ctx, req)
+}
+
+// SetTrafficSplit sets how the traffic of a workload is split between
+// its revisions
+func (c *Client) SetTrafficSplit(ctx context.Context, split *pb.TrafficSplit) (*pb.TrafficSplit, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.TrafficSplit, error) {
+		return c.cluster.SetTrafficSplit(ctx, split)
+	})
+}
+
+// ListRevisions returns the revision history of a workload
+func (c *Client) ListRevisions(ctx context.Context, id string) (*pb.ListRevisionsResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.ListRevisionsResponse, error) {
+		return c.cluster.ListRevisions(ctx, &pb.ListRevisionsRequest{Id: id})
+	})
+}
+
+// Explain returns the nodes the request would be placed on, and why the
+// other nodes wouldn't
+func (c *Client) Explain(ctx context.Context, req *pb.VmSpawnRequest) (*pb.ExplainResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.ExplainResponse, error) {
+		return c.cluster.Explain(ctx, req)
+	})
+}
+
+// Topology returns the connections between the workloads across the
+// cluster, only those of the tenant if set
+func (c *Client) Topology(ctx context.Context, tenant string) (*pb.TopologyResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.TopologyResponse, error) {
+		return c.cluster.Topology(ctx, &pb.TopologyRequest{Tenant: tenant})
+	})
+}
+
+// Billing returns the billing records of the workloads across the
+// cluster, only those of tenant if it is set
+func (c *Client) Billing(ctx context.Context, tenant string) (*pb.BillingResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.BillingResponse, error) {
+		return c.cluster.Billing(ctx, &pb.BillingRequest{Tenant: tenant})
+	})
+}
+
+// Config returns the effective tuning of the node's cluster agent
+func (c *Client) Config(ctx context.Context) (*pb.ConfigResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.ConfigResponse, error) {
+		return c.cluster.Config(ctx, &pb.ConfigRequest{})
+	})
+}
+
+// Nodes returns the lifecycle status of the nodes of the cluster
+func (c *Client) Nodes(ctx context.Context) (*pb.NodesResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.NodesResponse, error) {
+		return c.cluster.Nodes(ctx, &pb.NodesRequest{})
+	})
+}
+
+// Status returns a summary of the health of the cluster
+func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.StatusResponse, error) {
+		return c.cluster.Status(ctx, &pb.StatusRequest{})
+	})
+}
+
+// Join makes the node join the cluster of the node at the serf address
+func (c *Client) Join(ctx context.Context, address string) (*pb.Node, error) {
+	return c.cluster.Join(ctx, &pb.JoinRequest{Address: address})
+}
+
+// Drain stops placing workloads on a node, empty for the node the client
+// is connected to, and moves its workloads to the other nodes
+func (c *Client) Drain(ctx context.Context, node string) (*pb.Node, error) {
+	return c.cluster.Drain(ctx, &pb.DrainRequest{Node: node})
+}
+
+// Leave makes a node leave the cluster, empty for the node the client is
+// connected to. Unless forced, nodes running workloads must be drained
+// first
+func (c *Client) Leave(ctx context.Context, node string, force bool) (*pb.Node, error) {
+	return c.cluster.Leave(ctx, &pb.LeaveRequest{Node: node, Force: force})
+}
+
+// Adopt brings a container of a node, empty for the node the client is
+// connected to, under the management of the cluster. The fields set in
+// spec override the ones synthesized from the container
+func (c *Client) Adopt(ctx context.Context, node, containerID string, spec *pb.VmSpawnRequest) (*pb.AdoptResponse, error) {
+	return c.cluster.Adopt(ctx, &pb.AdoptRequest{Node: node, ContainerId: containerID, Spec: spec})
+}
+
+// Upgrade replaces the hypercore binary of a node, empty for the node the
+// client is connected to, with the one at url once its SHA-256 digest is
+// checked, and restarts its agent
+func (c *Client) Upgrade(ctx context.Context, node, url, digest string) (*pb.UpgradeResponse, error) {
+	return c.cluster.Upgrade(ctx, &pb.UpgradeRequest{Node: node, BinaryUrl: url, Sha256: digest})
+}
+
+// CollectCrash returns the crash bundles written for a workload across
+// the cluster
+func (c *Client) CollectCrash(ctx context.Context, id string) (*pb.CollectCrashResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.CollectCrashResponse, error) {
+		return c.cluster.CollectCrash(ctx, &pb.CollectCrashRequest{Id: id, Tenant: c.tenant})
+	})
+}
+
+// DescribeWorkload returns the spec of a workload and the firewall
+// ruleset active on the node running it
+func (c *Client) DescribeWorkload(ctx context.Context, id string) (*pb.DescribeWorkloadResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.DescribeWorkloadResponse, error) {
+		return c.cluster.DescribeWorkload(ctx, &pb.DescribeWorkloadRequest{Id: id, Tenant: c.tenant})
+	})
+}
+
+// Usage returns the recent resource usage of a workload, identified by
+// its ID or its name within the tenant of the client
+func (c *Client) Usage(ctx context.Context, id string) (*pb.UsageResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*pb.UsageResponse, error) {
+		return c.cluster.Usage(ctx, &pb.UsageRequest{Id: id, Tenant: c.tenant})
+	})
+}
+
+// SetFaults sets the faults injected by the node, only served by nodes
+// built with the chaos tag
+func (c *Client) SetFaults(ctx context.Context, faults *pb.FaultConfig) (*pb.FaultConfig, error) {
+	return c.debug.SetFaults(ctx, faults)
+}
+
+// GetFaults returns the faults injected by the node, only served by nodes
+// built with the chaos tag
+func (c *Client) GetFaults(ctx context.Context) (*pb.FaultConfig, error) {
+	return c.debug.GetFaults(ctx, &pb.GetFaultsRequest{})
+}
+
+// WatchEvents streams the cluster events observed by the node, optionally
+// limited to a workload or replica group, until ctx is done or the stream
+// fails. The error channel receives nil if the stream ended cleanly
+func (c *Client) WatchEvents(ctx context.Context, id string) (<-chan *pb.WatchEventsResponse, <-chan error) {
+	eventCh := make(chan *pb.WatchEventsResponse)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(eventCh)
+		defer close(errCh)
+
+		stream, err := withRetries(ctx, c, func(ctx context.Context) (grpc.ServerStreamingClient[pb.WatchEventsResponse], error) {
+			return c.cluster.WatchEvents(ctx, &pb.WatchEventsRequest{Id: id})
+		})
+		if err != nil {
+			errCh <- err
+
+			return
+		}
+
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+					err = nil
+				}
+
+				errCh <- err
+
+				return
+			}
+
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				errCh <- nil
+
+				return
+			}
+		}
+	}()
+
+	return eventCh, errCh
+}
+
+// withRetries retries fn while the node is unavailable, requests that
+// reached the node are never retried
+// ErrorCode returns the cluster error code of an error returned by the
+// client, UNKNOWN_ERROR if the server didn't attach one
+func ErrorCode(err error) pb.ErrorCode {
+	st, ok := status.FromError(err)
+	if !ok {
+		return pb.ErrorCode_UNKNOWN_ERROR
+	}
+
+	for _, detail := range st.Details() {
+		if resp, ok := detail.(*pb.ErrorResponse); ok {
+			return resp.GetCode()
+		}
+	}
+
+	return pb.ErrorCode_UNKNOWN_ERROR
+}
+
+func withRetries[T any](ctx context.Context, c *Client, fn func(context.Context) (T, error)) (T, error) {
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := fn(ctx)
+		if err == nil || attempt >= c.retries || status.Code(err) != codes.Unavailable {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func versionUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(metadata.AppendToOutgoingContext(ctx, pb.APIVersionMetadataKey, APIVersion), method, req, reply, cc, opts...)
+}
+
+func versionStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(metadata.AppendToOutgoingContext(ctx, pb.APIVersionMetadataKey, APIVersion), desc, cc, method, opts...)
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
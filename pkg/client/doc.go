@@ -0,0 +1,41 @@
+// Package client is the Go SDK for the hypercore cluster API, it wraps the
+// ClusterService gRPC API exposed by every cluster node.
+//
+// A client connects to a single node, which forwards requests to the rest of
+// the cluster as needed:
+//
+//	c, err := client.New("10.0.0.1:8000",
+//		client.WithToken(os.Getenv("HYPERCORE_TOKEN")),
+//		client.WithMTLS("client.crt", "client.key", "ca.crt"),
+//		client.WithRetries(5, time.Second),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	resp, err := c.Spawn(ctx, &pb.VmSpawnRequest{
+//		ImageRef: "docker.io/library/nginx:latest",
+//		Cores:    1,
+//		Memory:   512,
+//		Ports:    map[uint32]uint32{443: 80},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	logs, err := c.Logs(ctx, resp.GetId(), 0)
+//
+// Cluster events can be followed until the context is cancelled:
+//
+//	events, errCh := c.WatchEvents(ctx, resp.GetId())
+//	for event := range events {
+//		fmt.Println(event.GetEvent(), event.GetId(), event.GetMessage())
+//	}
+//	if err := <-errCh; err != nil {
+//		return err
+//	}
+//
+// Requests are retried with exponential backoff while the node is
+// unavailable, requests that reached the node are never retried.
+package client
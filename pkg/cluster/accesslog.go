@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Upper bounds (in seconds) of the buckets of the latency histograms of
+// the requests of the services
+var proxyLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// latencyHistogram counts the requests per latency bucket, the last count
+// being the requests slower than all the bounds
+type latencyHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// serviceHistograms are the latencies of the requests of a service per
+// status class (2xx, 5xx...) and the bytes of their responses, kept
+// until the service has no backends left
+type serviceHistograms struct {
+	latency map[string]*latencyHistogram
+	bytes   uint64
+}
+
+// SetAccessLog sets whether the requests routed to a backend are logged
+func (s *ServiceProxy) SetAccessLog(backendID string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enabled {
+		s.accessLogs[backendID] = struct{}{}
+	} else {
+		delete(s.accessLogs, backendID)
+	}
+}
+
+// observe adds a request of a service to its histograms
+func (s *ServiceProxy) observe(serviceID string, status int, latency time.Duration, bytes uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histograms, ok := s.histograms[serviceID]
+	if !ok {
+		histograms = &serviceHistograms{latency: make(map[string]*latencyHistogram)}
+		s.histograms[serviceID] = histograms
+	}
+
+	class := strconv.Itoa(status/100) + "xx"
+
+	histogram, ok := histograms.latency[class]
+	if !ok {
+		histogram = &latencyHistogram{counts: make([]uint64, len(proxyLatencyBuckets)+1)}
+		histograms.latency[class] = histogram
+	}
+
+	seconds := latency.Seconds()
+	bucket, _ := slices.BinarySearch(proxyLatencyBuckets, seconds)
+
+	histogram.counts[bucket]++
+	histogram.sum += seconds
+	histogram.count++
+	histograms.bytes += bytes
+}
+
+// logAccess logs a request routed to a backend with access logging on
+func (s *ServiceProxy) logAccess(r *http.Request, serviceID string, backend proxyBackend, recorder *statusRecorder, latency time.Duration) {
+	s.logger.WithFields(log.Fields{
+		"service":     serviceID,
+		"method":      r.Method,
+		"host":        r.Host,
+		"path":        r.URL.Path,
+		"remote":      r.RemoteAddr,
+		"backend":     backend.id,
+		"backend_url": backend.url,
+		"status":      recorder.status,
+		"latency_ms":  latency.Milliseconds(),
+		"bytes":       recorder.bytes,
+	}).Info("proxied request")
+}
+
+// writeMetrics writes the histograms of the services in the Prometheus
+// text format
+func (s *ServiceProxy) writeMetrics(w io.Writer, node string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	const latencyName = "hypercore_service_request_duration_seconds"
+	const bytesName = "hypercore_service_response_bytes_total"
+
+	fmt.Fprintf(w, "# HELP %s Latency of the requests proxied to the services, by status class\n# TYPE %s histogram\n", latencyName, latencyName)
+
+	for _, serviceID := range slices.Sorted(maps.Keys(s.histograms)) {
+		histograms := s.histograms[serviceID]
+
+		for _, class := range slices.Sorted(maps.Keys(histograms.latency)) {
+			histogram := histograms.latency[class]
+			labels := fmt.Sprintf("node=%q,service=%q,code=%q", node, serviceID, class)
+
+			var cumulative uint64
+			for i, bound := range proxyLatencyBuckets {
+				cumulative += histogram.counts[i]
+				fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", latencyName, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+			}
+
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", latencyName, labels, histogram.count)
+			fmt.Fprintf(w, "%s_sum{%s} %g\n", latencyName, labels, histogram.sum)
+			fmt.Fprintf(w, "%s_count{%s} %d\n", latencyName, labels, histogram.count)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP %s Bytes of the responses proxied from the services\n# TYPE %s counter\n", bytesName, bytesName)
+
+	for _, serviceID := range slices.Sorted(maps.Keys(s.histograms)) {
+		fmt.Fprintf(w, "%s{node=%q,service=%q} %d\n", bytesName, node, serviceID, s.histograms[serviceID].bytes)
+	}
+}
@@ -0,0 +1,167 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	DefaultMaxConcurrentCreates = 4
+	DefaultMaxQueuedSpawns      = 64
+
+	// Buckets of idle tenants are dropped past this many tenants
+	maxTenantBuckets = 1024
+)
+
+var errAdmissionQueueFull = newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, nil, "admission queue is full")
+
+type queuedSpawn struct {
+	cores  int
+	memory int
+	run    func()
+}
+
+// admissionQueue bounds the container creations running at once on this
+// node, spawns over the limit wait in a FIFO queue instead of blocking the
+// spawn query until they can run
+type admissionQueue struct {
+	mu       sync.Mutex
+	limit    int
+	maxQueue int
+	running  int
+	queue    []queuedSpawn
+}
+
+func newAdmissionQueue(limit, maxQueue int) *admissionQueue {
+	return &admissionQueue{limit: max(limit, 1), maxQueue: maxQueue}
+}
+
+// admit returns 0 if the spawn can run right away, in which case the
+// caller runs it and calls release once done. Otherwise run is queued and
+// its 1-based position in the queue is returned
+func (q *admissionQueue) admit(cores, memory int, run func()) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.running < q.limit {
+		q.running++
+
+		return 0, nil
+	}
+
+	if len(q.queue) >= q.maxQueue {
+		return 0, errAdmissionQueueFull
+	}
+
+	q.queue = append(q.queue, queuedSpawn{cores: cores, memory: memory, run: run})
+
+	return len(q.queue), nil
+}
+
+// release frees the slot of a finished spawn, handing it to the next
+// queued one if any
+func (q *admissionQueue) release() {
+	q.mu.Lock()
+
+	if len(q.queue) == 0 {
+		q.running--
+		q.mu.Unlock()
+
+		return
+	}
+
+	next := q.queue[0]
+	q.queue = q.queue[1:]
+	q.mu.Unlock()
+
+	go func() {
+		defer q.release()
+		next.run()
+	}()
+}
+
+// queued returns the number of spawns waiting for a creation slot
+func (q *admissionQueue) queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.queue)
+}
+
+// reserved returns the vCPUs and memory (in MB) of the queued spawns,
+// which are not accounted for by the containers of the node yet
+func (q *admissionQueue) reserved() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cores, memory := 0, 0
+	for _, spawn := range q.queue {
+		cores += spawn.cores
+		memory += spawn.memory
+	}
+
+	return cores, memory
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// tenantLimiter rate limits the spawn requests of each tenant with a token
+// bucket refilled at rate tokens per second, up to burst tokens
+type tenantLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newTenantLimiter(rate float64, burst int) *tenantLimiter {
+	return &tenantLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket of tenant, a zero rate disables
+// rate limiting
+func (l *tenantLimiter) allow(tenant string) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if len(l.buckets) >= maxTenantBuckets {
+		for name, bucket := range l.buckets {
+			if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, name)
+			}
+		}
+	}
+
+	bucket, ok := l.buckets[tenant]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[tenant] = bucket
+	}
+
+	bucket.tokens = min(bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate, l.burst)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return newClusterError(pb.ErrorCode_RATE_LIMITED, map[string]string{"tenant": tenant},
+			"spawn rate limit of %.2f/s exceeded for tenant %q", l.rate, tenant)
+	}
+
+	bucket.tokens--
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"google.golang.org/protobuf/proto"
+)
+
+// Runtime of the workloads, containers of other runtimes can't be adopted
+const workloadRuntime = "io.containerd.runc.v2"
+
+// AdoptRequest brings a container of the node under the management of the
+// cluster, forwarding the request to the node running it if it isn't this
+// one. The workload is then monitored, exposed and respawned like spawned
+// ones
+func (a *Agent) AdoptRequest(ctx context.Context, req *pb.AdoptRequest) (*pb.AdoptResponse, error) {
+	if req.GetNode() != "" && req.GetNode() != a.cfg.NodeName {
+		client, closeConn, err := a.nodeClient(req.GetNode())
+		if err != nil {
+			return nil, err
+		}
+		defer closeConn()
+
+		return client.Adopt(forwardedContext(ctx), req)
+	}
+
+	if req.GetContainerId() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "ID of the container is required")
+	}
+
+	ctrCtx := a.ctrRepo.GetContext(ctx)
+
+	container, err := a.ctrRepo.GetContainer(ctrCtx, req.GetContainerId())
+	if errdefs.IsNotFound(err) {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no container %s on node %s", req.GetContainerId(), a.cfg.NodeName)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get container %s: %w", req.GetContainerId(), err)
+	}
+
+	spec, err := synthesizeSpawnRequest(ctrCtx, container)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSpec() != nil {
+		proto.Merge(spec, req.GetSpec())
+	}
+
+	if err := validateSpawnRequest(spec); err != nil {
+		return nil, err
+	}
+
+	// Adopted workloads are admitted like spawned ones, the container
+	// only counts towards the capacity of the node once labelled
+	if err := a.checkImagePolicy(spec); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkCapacity(ctrCtx, spec); err != nil {
+		return nil, err
+	}
+
+	encodedSpec, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.claimName(spec); err != nil {
+		return nil, err
+	}
+
+	err = a.ctrRepo.AdoptContainer(ctrCtx, container.ID(), map[string]string{SpawnRequestLabel: string(encodedSpec)})
+	if err != nil {
+		a.releaseName(spec)
+	}
+
+	if errors.Is(err, vcontainerd.ErrOwned) {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "%s", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	a.logger.Infof("Adopted container %s as a workload of image %s", container.ID(), spec.GetImageRef())
+
+	return &pb.AdoptResponse{Id: container.ID(), Node: a.cfg.NodeName, Spec: spec}, nil
+}
+
+// synthesizeSpawnRequest returns the spawn request respawning a container
+// like it runs: its image, resource limits and environment
+func synthesizeSpawnRequest(ctx context.Context, container containerd.Container) (*pb.VmSpawnRequest, error) {
+	info, err := container.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container %s: %w", container.ID(), err)
+	}
+
+	if info.Runtime.Name != workloadRuntime {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "container %s runs with %s, only %s containers can be adopted", container.ID(), info.Runtime.Name, workloadRuntime)
+	}
+
+	// Containers without a task are invisible to monitorWorkloads
+	if _, err := container.Task(ctx, nil); err != nil {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "container %s has no task, start it before adopting it", container.ID())
+	}
+
+	spec, err := container.Spec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spec of container %s: %w", container.ID(), err)
+	}
+
+	req := &pb.VmSpawnRequest{ImageRef: info.Image}
+
+	if spec.Process != nil && len(spec.Process.Env) > 0 {
+		req.Env = make(map[string]string, len(spec.Process.Env))
+
+		for _, variable := range spec.Process.Env {
+			key, value, _ := strings.Cut(variable, "=")
+			req.Env[key] = value
+		}
+	}
+
+	if spec.Linux == nil || spec.Linux.Resources == nil {
+		return req, nil
+	}
+
+	if memory := spec.Linux.Resources.Memory; memory != nil && memory.Limit != nil && *memory.Limit > 0 {
+		req.Memory = uint32(*memory.Limit / 1024 / 1024)
+	}
+
+	// The quota is the share of the node the cores are, like the limits of
+	// spawned workloads
+	if cpu := spec.Linux.Resources.CPU; cpu != nil && cpu.Quota != nil && cpu.Period != nil && *cpu.Quota > 0 && *cpu.Period > 0 {
+		req.Cores = uint32(math.Ceil(float64(*cpu.Quota) / float64(*cpu.Period) * float64(runtime.NumCPU())))
+	}
+
+	return req, nil
+}
@@ -0,0 +1,134 @@
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"vistara-node/pkg/cluster"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// addUnmanaged adds a container hypercore didn't spawn to the node
+func addUnmanaged(node *simNode, id, image string) {
+	node.repo.addContainer(id, image, &oci.Spec{Process: &specs.Process{Env: []string{"PATH=/bin"}}})
+}
+
+func adopt(node *simNode, id string, spec *pb.VmSpawnRequest) (*pb.AdoptResponse, error) {
+	return node.agent.AdoptRequest(context.Background(), &pb.AdoptRequest{ContainerId: id, Spec: spec})
+}
+
+func expectErrorCode(t *testing.T, err error, code pb.ErrorCode) {
+	t.Helper()
+
+	var clusterErr *cluster.ClusterError
+	if !errors.As(err, &clusterErr) || clusterErr.Code != code {
+		t.Fatalf("expected a %s error, got %v", code, err)
+	}
+}
+
+// expectUnmanaged fails if the container was labelled as a workload
+func expectUnmanaged(t *testing.T, node *simNode, id string) {
+	t.Helper()
+
+	if _, ok := node.repo.workloads()[id]; ok {
+		t.Fatalf("expected container %s to be left unmanaged", id)
+	}
+}
+
+func TestAdoptDeniedImage(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "image-policy.json")
+	if err := os.WriteFile(policy, []byte(`{"deny": ["docker.io/library/redis"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	imagePolicy, err := cluster.LoadImagePolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := startCluster(t, 1, func(_ int, cfg *cluster.AgentConfig) {
+		cfg.ImagePolicy = imagePolicy
+	})
+
+	addUnmanaged(nodes[0], "redis", "docker.io/library/redis:7")
+
+	_, err = adopt(nodes[0], "redis", nil)
+	expectErrorCode(t, err, pb.ErrorCode_POLICY_DENIED)
+	expectUnmanaged(t, nodes[0], "redis")
+
+	addUnmanaged(nodes[0], "nginx", simImage)
+
+	if _, err := adopt(nodes[0], "nginx", nil); err != nil {
+		t.Fatalf("expected an allowed image to be adopted, got %v", err)
+	}
+}
+
+func TestAdoptNameConflict(t *testing.T) {
+	nodes := startCluster(t, 2, nil)
+
+	resp, err := nodes[0].agent.SpawnRequest(&pb.VmSpawnRequest{ImageRef: simImage, Memory: cluster.MinWorkloadMemory, Name: "db"})
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+
+	waitConverged(t, nodes)
+
+	addUnmanaged(nodes[0], "other-db", simImage)
+
+	_, err = adopt(nodes[0], "other-db", &pb.VmSpawnRequest{Name: "db"})
+	expectErrorCode(t, err, pb.ErrorCode_ALREADY_EXISTS)
+	expectUnmanaged(t, nodes[0], "other-db")
+
+	// The name is only taken for the tenant of the workload
+	if _, err := adopt(nodes[0], "other-db", &pb.VmSpawnRequest{Name: "db", Tenant: "other"}); err != nil {
+		t.Fatalf("expected the name to be free for another tenant than the one of %s, got %v", resp.GetId(), err)
+	}
+}
+
+func TestAdoptCapacity(t *testing.T) {
+	cores := runtime.NumCPU()
+	if cores > cluster.MaxWorkloadCores {
+		t.Skipf("%d CPUs is more than a workload can have", cores)
+	}
+
+	nodes := startCluster(t, 1, nil)
+
+	addUnmanaged(nodes[0], "first", simImage)
+	addUnmanaged(nodes[0], "second", simImage)
+
+	if _, err := adopt(nodes[0], "first", &pb.VmSpawnRequest{Cores: uint32(cores)}); err != nil {
+		t.Fatalf("expected a container using the vCPUs of the node to be adopted, got %v", err)
+	}
+
+	// The adopted workload counts towards the capacity of the node
+	_, err := adopt(nodes[0], "second", &pb.VmSpawnRequest{Cores: 1})
+	expectErrorCode(t, err, pb.ErrorCode_CAPACITY_EXCEEDED)
+	expectUnmanaged(t, nodes[0], "second")
+}
+
+func TestAdoptReleasesNameOnFailure(t *testing.T) {
+	nodes := startCluster(t, 1, nil)
+
+	addUnmanaged(nodes[0], "web", simImage)
+
+	nodes[0].repo.setAdoptErr(errors.New("containerd is unavailable"))
+
+	if _, err := adopt(nodes[0], "web", &pb.VmSpawnRequest{Name: "web"}); err == nil {
+		t.Fatal("expected the adoption to fail")
+	}
+
+	nodes[0].repo.setAdoptErr(nil)
+
+	addUnmanaged(nodes[0], "web-2", simImage)
+
+	if _, err := adopt(nodes[0], "web-2", &pb.VmSpawnRequest{Name: "web"}); err != nil {
+		t.Fatalf("expected the name of the failed adoption to be released, got %v", err)
+	}
+}
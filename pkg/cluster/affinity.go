@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// Prefix of the name of the cookies pinning the clients of a service
+	// to a backend, followed by the service ID
+	AffinityCookiePrefix = "hypercore-affinity-"
+
+	// Period at which a draining backend is checked for requests left
+	proxyDrainPollPeriod = time.Millisecond * 100
+)
+
+// SetAffinity sets how the clients of a service are pinned to its
+// backends
+func (s *ServiceProxy) SetAffinity(serviceID string, affinity pb.SessionAffinity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if affinity == pb.SessionAffinity_AFFINITY_NONE {
+		delete(s.affinities, serviceID)
+	} else {
+		s.affinities[serviceID] = affinity
+	}
+}
+
+// cookieBackend returns the backend the affinity cookie of the service
+// pins the client to, if it has one
+func cookieBackend(r *http.Request, serviceID string) (string, bool) {
+	cookie, err := r.Cookie(AffinityCookiePrefix + serviceID)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+func affinityCookie(serviceID, backendID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     AffinityCookiePrefix + serviceID,
+		Value:    backendID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// sourceIP returns the address of the client without its port
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// sourceRoll maps a client to the same share (0-99) of a traffic split
+// on every request
+func sourceRoll(source string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(source))
+
+	return h.Sum32() % 100
+}
+
+// rendezvousBackend picks the backend with the highest hash for the
+// client, so removing a backend only moves the clients it was serving
+func rendezvousBackend(source string, backendIDs []string) string {
+	var picked string
+	var highest uint64
+
+	for _, backendID := range backendIDs {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(source + "/" + backendID))
+
+		if sum := h.Sum64(); picked == "" || sum > highest {
+			picked, highest = backendID, sum
+		}
+	}
+
+	return picked
+}
+
+func (s *ServiceProxy) startRequest(backendID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inflight[backendID]++
+}
+
+func (s *ServiceProxy) finishRequest(backendID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inflight[backendID]--; s.inflight[backendID] <= 0 {
+		delete(s.inflight, backendID)
+	}
+}
+
+// Drain waits for the requests being proxied to a deregistered backend
+// to complete, returning the number left if ctx is done first
+func (s *ServiceProxy) Drain(ctx context.Context, backendID string) int {
+	ticker := time.NewTicker(proxyDrainPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		left := s.inflight[backendID]
+		s.mu.Unlock()
+
+		if left == 0 {
+			return 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return left
+		case <-ticker.C:
+		}
+	}
+}
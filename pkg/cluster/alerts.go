@@ -0,0 +1,284 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of the alerts sent to the webhooks
+const (
+	AlertNodeFailed      = "node_failed"
+	AlertCrashLoop       = "crash_loop"
+	AlertRespawnStorm    = "respawn_storm"
+	AlertQueueOverload   = "queue_overload"
+	AlertPolicyViolation = "policy_violation"
+)
+
+const (
+	DefaultAlertDedupWindow = time.Minute * 10
+	DefaultAlertRateLimit   = 10
+
+	// Respawns of a workload within the window making it crash-looping
+	crashLoopRespawns = 5
+	crashLoopWindow   = time.Minute * 10
+	// Respawns on a node within the window making a respawn storm
+	respawnStormRespawns = 20
+	respawnStormWindow   = time.Minute
+
+	alertQueueSize   = 64
+	alertSendTimeout = time.Second * 10
+	alertSendRetries = 3
+)
+
+// AlertConfig is where and how often the agent sends alerts
+type AlertConfig struct {
+	// Endpoints receiving the alerts as JSON
+	Webhooks []string
+	// Slack incoming webhooks receiving the alerts as messages
+	SlackWebhooks []string
+	// Alerts of the same kind about the same subject are only sent once
+	// per window, DefaultAlertDedupWindow if zero
+	DedupWindow time.Duration
+	// Alerts sent per minute at most, DefaultAlertRateLimit if zero
+	RateLimit int
+}
+
+// Alert is the payload posted to the JSON webhooks
+type Alert struct {
+	Kind string `json:"kind"`
+	// Node reporting the alert
+	Node string `json:"node"`
+	// Node, workload or tenant the alert is about
+	Subject string    `json:"subject"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	// Alerts dropped since the previous one was sent
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// alerter sends alerts to the webhooks in the background, deduplicating
+// them per kind and subject and rate limiting them
+type alerter struct {
+	cfg    *AlertConfig
+	node   string
+	logger *log.Logger
+	client *http.Client
+	queue  chan Alert
+	// A single bucket, shared by all the alerts
+	limiter *tenantLimiter
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time
+	suppressed int
+	// Respawn times of each workload, and of all the workloads of the node
+	respawns   map[string][]time.Time
+	allRespawn []time.Time
+}
+
+func newAlerter(logger *log.Logger, cfg *AlertConfig, node string) *alerter {
+	if cfg.DedupWindow == 0 {
+		cfg.DedupWindow = DefaultAlertDedupWindow
+	}
+
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = DefaultAlertRateLimit
+	}
+
+	alerter := &alerter{
+		cfg:      cfg,
+		node:     node,
+		logger:   logger,
+		client:   &http.Client{Timeout: alertSendTimeout},
+		queue:    make(chan Alert, alertQueueSize),
+		limiter:  newTenantLimiter(float64(cfg.RateLimit)/60, cfg.RateLimit),
+		lastSent: make(map[string]time.Time),
+		respawns: make(map[string][]time.Time),
+	}
+
+	go alerter.run()
+
+	return alerter
+}
+
+// alert queues an alert unless one of the same kind about the same subject
+// was sent within the dedup window or the rate limit is exceeded. Alerts
+// are dropped for an agent without webhooks
+func (a *Agent) alert(kind, subject, format string, args ...any) {
+	if a.alerts == nil {
+		return
+	}
+
+	a.alerts.send(Alert{Kind: kind, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+func (al *alerter) send(alert Alert) {
+	alert.Node = al.node
+	alert.Time = time.Now()
+
+	key := alert.Kind + "/" + alert.Subject
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if last, ok := al.lastSent[key]; ok && alert.Time.Sub(last) < al.cfg.DedupWindow {
+		return
+	}
+
+	for key, last := range al.lastSent {
+		if alert.Time.Sub(last) >= al.cfg.DedupWindow {
+			delete(al.lastSent, key)
+		}
+	}
+
+	if al.limiter.allow("") != nil {
+		al.suppressed++
+		al.logger.Warnf("alert rate limit exceeded, dropping %s alert about %s", alert.Kind, alert.Subject)
+
+		return
+	}
+
+	alert.Suppressed = al.suppressed
+
+	select {
+	case al.queue <- alert:
+		al.lastSent[key] = alert.Time
+		al.suppressed = 0
+	default:
+		al.suppressed++
+		al.logger.Warnf("alert queue is full, dropping %s alert about %s", alert.Kind, alert.Subject)
+	}
+}
+
+// recordRespawn counts a respawn of the workload, alerting when it crash
+// loops or when the respawns of the node turn into a storm
+func (a *Agent) recordRespawn(workload string) {
+	if a.alerts == nil {
+		return
+	}
+
+	now := time.Now()
+
+	a.alerts.mu.Lock()
+	respawns := append(recentTimes(a.alerts.respawns[workload], now, crashLoopWindow), now)
+	a.alerts.respawns[workload] = respawns
+	a.alerts.allRespawn = append(recentTimes(a.alerts.allRespawn, now, respawnStormWindow), now)
+	storm := len(a.alerts.allRespawn)
+
+	for id, times := range a.alerts.respawns {
+		if len(recentTimes(times, now, crashLoopWindow)) == 0 {
+			delete(a.alerts.respawns, id)
+		}
+	}
+	a.alerts.mu.Unlock()
+
+	if len(respawns) >= crashLoopRespawns {
+		a.alert(AlertCrashLoop, workload, "workload was respawned %d times in the last %s", len(respawns), crashLoopWindow)
+	}
+
+	if storm >= respawnStormRespawns {
+		a.alert(AlertRespawnStorm, a.cfg.NodeName, "%d workloads were respawned on the node in the last %s", storm, respawnStormWindow)
+	}
+}
+
+// respawnedWorkload names a respawned workload across its incarnations,
+// which get new IDs, by its name or replica group if it has one
+func respawnedWorkload(spec *pb.VmSpawnRequest, id string) string {
+	if spec.GetName() != "" {
+		return spec.GetTenant() + "/" + spec.GetName()
+	}
+
+	if spec.GetReplicaGroup() != "" {
+		return spec.GetReplicaGroup()
+	}
+
+	return id
+}
+
+// recentTimes drops the times older than the window
+func recentTimes(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	for len(times) > 0 && now.Sub(times[0]) > window {
+		times = times[1:]
+	}
+
+	return times
+}
+
+func (al *alerter) run() {
+	for alert := range al.queue {
+		for _, url := range al.cfg.Webhooks {
+			al.post(url, alert)
+		}
+
+		text := fmt.Sprintf(":rotating_light: *%s* on node %s about %s: %s", alert.Kind, alert.Node, alert.Subject, alert.Message)
+		if alert.Suppressed > 0 {
+			text += fmt.Sprintf(" (%d more alerts suppressed)", alert.Suppressed)
+		}
+
+		for _, url := range al.cfg.SlackWebhooks {
+			al.post(url, map[string]string{"text": text})
+		}
+	}
+}
+
+// post sends the payload to a webhook, retrying failed attempts
+func (al *alerter) post(url string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		al.logger.WithError(err).Error("failed to marshal alert")
+
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = al.postOnce(url, body)
+		if err == nil {
+			return
+		}
+
+		if attempt == alertSendRetries {
+			al.logger.WithError(err).Errorf("failed to send alert to webhook %s", url)
+
+			return
+		}
+
+		time.Sleep(time.Second * time.Duration(attempt))
+	}
+}
+
+func (al *alerter) postOnce(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), alertSendTimeout)
+	defer cancel()
+
+	return postJSON(ctx, al.client, url, body)
+}
+
+// postJSON posts a JSON body, failing unless it gets a 2xx status
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("got status %s", resp.Status)
+	}
+
+	return nil
+}
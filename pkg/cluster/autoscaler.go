@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	ctask "github.com/containerd/containerd/api/types/task"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	VerticalScalePeriod = time.Second * 15
+	// Number of consecutive samples above/below the thresholds
+	// before a workload gets resized
+	VerticalScaleSamples = 4
+
+	verticalScaleUpThreshold   = 0.8
+	verticalScaleDownThreshold = 0.3
+)
+
+type usageSample struct {
+	cpuUsage  uint64
+	sampledAt time.Time
+	cpuHigh   int
+	cpuLow    int
+	memHigh   int
+	memLow    int
+}
+
+// verticalAutoscaler periodically samples the usage of workloads that
+// were spawned with a vertical scaling policy, and resizes them within
+// the bounds of the policy when usage is sustained above/below thresholds
+func (a *Agent) verticalAutoscaler() {
+	samples := make(map[string]*usageSample)
+
+	ticker := time.NewTicker(VerticalScalePeriod)
+	for range ticker.C {
+		ctx := a.ctrRepo.GetContext(context.Background())
+
+		tasks, err := a.ctrRepo.GetTasks(ctx)
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get tasks")
+
+			continue
+		}
+
+		seen := make(map[string]struct{})
+
+		for _, task := range tasks {
+			if task.GetStatus() != ctask.Status_RUNNING {
+				continue
+			}
+
+			container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+			if err != nil {
+				continue
+			}
+
+			info, err := container.Info(ctx)
+			if err != nil {
+				continue
+			}
+
+			// Only the cgroup limits of containers are updated, the
+			// hypervisors can't resize the VMs of other runtimes
+			if info.Runtime.Name != workloadRuntime {
+				continue
+			}
+
+			labels := info.Labels
+
+			var labelPayload pb.VmSpawnRequest
+			if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
+				continue
+			}
+
+			policy := labelPayload.GetVerticalScaling()
+			if policy == nil {
+				continue
+			}
+
+			seen[task.GetID()] = struct{}{}
+
+			cpuUsage, memUsage, err := workloadUsage(task.GetID(), task.GetPid())
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to get usage for container %s", task.GetID())
+
+				continue
+			}
+
+			sample, ok := samples[task.GetID()]
+			if !ok {
+				samples[task.GetID()] = &usageSample{cpuUsage: cpuUsage, sampledAt: time.Now()}
+
+				continue
+			}
+
+			elapsed := time.Since(sample.sampledAt)
+			cpuUtil := float64(cpuUsage-sample.cpuUsage) / float64(elapsed.Nanoseconds()) / float64(labelPayload.GetCores())
+			memUtil := float64(memUsage) / float64(megabytes(labelPayload.GetMemory()))
+
+			sample.cpuUsage = cpuUsage
+			sample.sampledAt = time.Now()
+			sample.cpuHigh, sample.cpuLow = countThreshold(cpuUtil, sample.cpuHigh, sample.cpuLow)
+			sample.memHigh, sample.memLow = countThreshold(memUtil, sample.memHigh, sample.memLow)
+
+			resized := a.resizeForPolicy(&labelPayload, policy, sample)
+			if resized == nil {
+				continue
+			}
+
+			if err := a.applyResize(ctx, task.GetID(), labels, resized); err != nil {
+				a.logger.WithError(err).Errorf("failed to resize container %s", task.GetID())
+
+				continue
+			}
+
+			// Start sampling afresh against the new allocation
+			samples[task.GetID()] = &usageSample{cpuUsage: cpuUsage, sampledAt: time.Now()}
+		}
+
+		for id := range samples {
+			if _, ok := seen[id]; !ok {
+				delete(samples, id)
+			}
+		}
+	}
+}
+
+func countThreshold(util float64, high, low int) (int, int) {
+	switch {
+	case util >= verticalScaleUpThreshold:
+		return high + 1, 0
+	case util <= verticalScaleDownThreshold:
+		return 0, low + 1
+	default:
+		return 0, 0
+	}
+}
+
+// resizeForPolicy returns the new allocation for the workload, or nil if
+// it should be left as is
+func (a *Agent) resizeForPolicy(payload *pb.VmSpawnRequest, policy *pb.VerticalScalingPolicy, sample *usageSample) *pb.VmSpawnRequest {
+	cores := payload.GetCores()
+	memory := payload.GetMemory()
+
+	// Resources with equal bounds are left as spawned
+	if policy.GetMaxCores() > policy.GetMinCores() {
+		switch {
+		case sample.cpuHigh >= VerticalScaleSamples && cores < policy.GetMaxCores():
+			cores++
+		case sample.cpuLow >= VerticalScaleSamples && cores > max(policy.GetMinCores(), 1):
+			cores--
+		}
+	}
+
+	if policy.GetMaxMemory() > policy.GetMinMemory() {
+		switch {
+		case sample.memHigh >= VerticalScaleSamples && memory < policy.GetMaxMemory():
+			memory = min(memory*3/2, policy.GetMaxMemory())
+		case sample.memLow >= VerticalScaleSamples && memory > policy.GetMinMemory():
+			memory = max(memory*3/4, policy.GetMinMemory())
+		}
+	}
+
+	if cores == payload.GetCores() && memory == payload.GetMemory() {
+		return nil
+	}
+
+	resized := proto.Clone(payload).(*pb.VmSpawnRequest)
+	resized.Cores = cores
+	resized.Memory = memory
+
+	return resized
+}
+
+func (a *Agent) applyResize(ctx context.Context, containerID string, labels map[string]string, resized *pb.VmSpawnRequest) error {
+	var current pb.VmSpawnRequest
+	if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &current); err != nil {
+		return err
+	}
+
+	vcpuUsed, memUsed, err := a.usedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	if vcpuUsed-int(current.GetCores())+int(resized.GetCores()) > runtime.NumCPU() {
+		return fmt.Errorf("not enough vCPUs to scale to %d", resized.GetCores())
+	}
+
+	availableMem, err := getAvailableMem()
+	if err != nil {
+		return err
+	}
+	availableMem /= 1024
+
+	if memUsed-int(current.GetMemory())+int(resized.GetMemory()) > int(availableMem) {
+		return fmt.Errorf("not enough memory to scale to %s", megabytes(resized.GetMemory()))
+	}
+
+	a.logger.Infof("Resizing container %s from %d vCPUs/%s to %d vCPUs/%s", containerID, current.GetCores(), megabytes(current.GetMemory()), resized.GetCores(), megabytes(resized.GetMemory()))
+
+	if err := a.ctrRepo.UpdateContainerResources(
+		ctx,
+		containerID,
+		float64(resized.GetCores())/float64(runtime.NumCPU()),
+		uint64(megabytes(resized.GetMemory())),
+	); err != nil {
+		return err
+	}
+
+	encodedPayload, err := json.Marshal(resized)
+	if err != nil {
+		return err
+	}
+
+	container, err := a.ctrRepo.GetContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	// Keep the label in sync so capacity checks and state
+	// broadcasts see the new allocation
+	if _, err := container.SetLabels(ctx, map[string]string{SpawnRequestLabel: string(encodedPayload)}); err != nil {
+		return fmt.Errorf("failed to update labels: %w", err)
+	}
+
+	return nil
+}
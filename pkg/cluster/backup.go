@@ -0,0 +1,245 @@
+package cluster
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	billingFileName   = "billing.json"
+	revisionsFileName = "revisions.json"
+
+	// BackupWorkloadsFile is the entry of backup archives holding the
+	// specs of the workloads of the node, as a spawn batch file
+	BackupWorkloadsFile = "workloads.json"
+	// Workloads of a restored backup, spawned by the agent on startup
+	restoreWorkloadsFileName = "restore-workloads.json"
+)
+
+// Files of the data directory of the agent a backup holds
+var backupStateFiles = []string{billingFileName, revisionsFileName}
+
+// WriteBackup archives the persistent state of the agent in dataDir, its
+// billing records and revision history, along with the specs of the
+// workloads of the node, returning the number of workloads. The agent
+// can keep running, its files are replaced atomically
+func WriteBackup(ctx context.Context, repo ContainerRepo, dataDir string, w io.Writer) (int, error) {
+	gzipWriter := gzip.NewWriter(w)
+	archive := tar.NewWriter(gzipWriter)
+
+	for _, name := range backupStateFiles {
+		contents, err := os.ReadFile(filepath.Join(dataDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		if err := writeBackupEntry(archive, name, contents); err != nil {
+			return 0, err
+		}
+	}
+
+	workloads, err := workloadSpecs(ctx, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	contents, err := protojson.MarshalOptions{Multiline: true}.Marshal(&pb.SpawnBatchRequest{Requests: workloads})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeBackupEntry(archive, BackupWorkloadsFile, contents); err != nil {
+		return 0, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+
+	return len(workloads), gzipWriter.Close()
+}
+
+func writeBackupEntry(archive *tar.Writer, name string, contents []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    defaults.DataFilePerm,
+		Size:    int64(len(contents)),
+		ModTime: time.Now(),
+	}
+
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+
+	if _, err := archive.Write(contents); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// workloadSpecs returns the spawn requests of the workloads of the node,
+// leaving out their pod and init step containers
+func workloadSpecs(ctx context.Context, repo ContainerRepo) ([]*pb.VmSpawnRequest, error) {
+	containers, err := repo.ListContainers(ctx, fmt.Sprintf("labels.%q", SpawnRequestLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workloads: %w", err)
+	}
+
+	namespaceCtx := repo.GetContext(ctx)
+	specs := make([]*pb.VmSpawnRequest, 0, len(containers))
+
+	for _, container := range containers {
+		labels, err := container.Labels(namespaceCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get labels for container %s: %w", container.ID(), err)
+		}
+
+		var spec pb.VmSpawnRequest
+		if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse spec of container %s: %w", container.ID(), err)
+		}
+
+		specs = append(specs, &spec)
+	}
+
+	return specs, nil
+}
+
+// RestoreBackup extracts a backup archive into dataDir, failing if the
+// agent state it holds already exists unless force is set. The agent must
+// not run, it spawns the workloads of the backup the next time it starts.
+// It returns the number of workloads to spawn
+func RestoreBackup(r io.Reader, dataDir string, force bool) (int, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer gzipReader.Close()
+
+	entries := make(map[string][]byte)
+	archive := tar.NewReader(gzipReader)
+
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read backup: %w", err)
+		}
+
+		// Entry names end up in dataDir, only the known ones are
+		// extracted
+		if header.Name != BackupWorkloadsFile && !slices.Contains(backupStateFiles, header.Name) {
+			return 0, fmt.Errorf("unexpected entry %s in backup", header.Name)
+		}
+
+		if entries[header.Name], err = io.ReadAll(archive); err != nil {
+			return 0, fmt.Errorf("failed to read %s from backup: %w", header.Name, err)
+		}
+	}
+
+	var batch pb.SpawnBatchRequest
+	if err := protojson.Unmarshal(entries[BackupWorkloadsFile], &batch); err != nil {
+		return 0, fmt.Errorf("failed to parse workloads of backup: %w", err)
+	}
+
+	if !force {
+		for _, name := range append(slices.Clone(backupStateFiles), restoreWorkloadsFileName) {
+			if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+				return 0, fmt.Errorf("%s already exists, the node has state of its own", filepath.Join(dataDir, name))
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dataDir, defaults.DataDirPerm); err != nil {
+		return 0, err
+	}
+
+	for _, name := range backupStateFiles {
+		if contents, ok := entries[name]; ok {
+			if err := writeFileAtomic(filepath.Join(dataDir, name), contents); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(dataDir, restoreWorkloadsFileName), entries[BackupWorkloadsFile]); err != nil {
+		return 0, err
+	}
+
+	return len(batch.GetRequests()), nil
+}
+
+func writeFileAtomic(path string, contents []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, defaults.DataFilePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// restoreWorkloads spawns on this node the workloads of a restored backup,
+// if any. The file is removed first, so a restart of the agent doesn't
+// spawn them twice
+func (a *Agent) restoreWorkloads(dataDir string) {
+	path := filepath.Join(dataDir, restoreWorkloadsFileName)
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	} else if err != nil {
+		a.logger.WithError(err).Error("failed to read restored workloads")
+
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		a.logger.WithError(err).Error("failed to remove restored workloads, not spawning them")
+
+		return
+	}
+
+	var batch pb.SpawnBatchRequest
+	if err := protojson.Unmarshal(contents, &batch); err != nil {
+		a.logger.WithError(err).Error("failed to parse restored workloads")
+
+		return
+	}
+
+	for _, spec := range batch.GetRequests() {
+		payload, err := a.handleSpawnRequest(spec)
+		if err != nil {
+			a.logger.WithError(err).Errorf("failed to restore workload of image %s", spec.GetImageRef())
+
+			continue
+		}
+
+		var resp pb.ClusterMessage
+		if err := proto.Unmarshal(payload, &resp); err != nil || resp.GetEvent() == pb.ClusterEvent_ERROR {
+			a.logger.Errorf("failed to restore workload of image %s", spec.GetImageRef())
+
+			continue
+		}
+
+		a.logger.Infof("Restored workload of image %s", spec.GetImageRef())
+	}
+}
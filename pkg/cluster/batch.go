@@ -0,0 +1,348 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Maximum number of spawn queries in flight for a single batch
+const SpawnBatchConcurrency = 16
+
+// batchUnit is a single workload to place, horizontally scaled requests
+// are expanded to one unit per replica
+type batchUnit struct {
+	index int
+	req   *pb.VmSpawnRequest
+	node  string
+	// node holding the volumes of the request, if they exist
+	volumeNode string
+}
+
+type batchResult struct {
+	unit *batchUnit
+	resp *pb.VmSpawnResponse
+	err  error
+}
+
+// nodeCapacity is the capacity left on a node while placing a batch
+type nodeCapacity struct {
+	id     string
+	cpus   int
+	memory int
+	placed int
+	// see hostPressure
+	pressure float64
+}
+
+// SpawnBatchRequest spawns all the requests of the batch, calling send
+// with the result of each request as soon as it completes. Requests over
+// the rate limit of their tenant fail without being placed
+func (a *Agent) SpawnBatchRequest(req *pb.SpawnBatchRequest, send func(*pb.SpawnBatchResponse) error) error {
+	var allowed []*pb.VmSpawnRequest
+
+	// index in the batch of each allowed request
+	var indexes []uint32
+
+	for i, spawnReq := range req.GetRequests() {
+		if err := a.rateLimits.allow(spawnReq.GetTenant()); err != nil {
+			if err := send(&pb.SpawnBatchResponse{Index: uint32(i), Error: err.Error(), ErrorCode: errorCode(err)}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		allowed = append(allowed, spawnReq)
+		indexes = append(indexes, uint32(i))
+	}
+
+	return a.spawnBatch(allowed, req.GetSpread(), func(resp *pb.SpawnBatchResponse) error {
+		resp.Index = indexes[resp.GetIndex()]
+
+		return send(resp)
+	})
+}
+
+// spawnBatch places the requests against the last known capacity of the
+// nodes in a single pass, then sends each replica to its node concurrently
+// instead of querying the cluster for each of them in turn. Nodes still
+// check their actual capacity, so a placement based on a stale state
+// fails instead of overcommitting the node
+//
+//nolint:gocognit
+func (a *Agent) spawnBatch(requests []*pb.VmSpawnRequest, spread bool, send func(*pb.SpawnBatchResponse) error) error {
+	var units []*batchUnit
+
+	// replicas left to spawn and first failure of each request
+	pending := make([]int, len(requests))
+	failures := make([]error, len(requests))
+	responses := make([]*pb.VmSpawnResponse, len(requests))
+	groups := make([]string, len(requests))
+
+	for i, req := range requests {
+		if err := validateSpawnRequest(req); err != nil {
+			failures[i] = err
+
+			continue
+		}
+
+		if err := a.checkImagePolicy(req); err != nil {
+			failures[i] = err
+
+			continue
+		}
+
+		volumeNode, err := a.volumeNode(req)
+		if err != nil {
+			failures[i] = err
+
+			continue
+		}
+
+		req = proto.Clone(req).(*pb.VmSpawnRequest)
+		req.DryRun = false
+
+		policy := req.GetHorizontalScaling()
+		if policy != nil && req.GetReplicaGroup() == "" {
+			req.ReplicaGroup = keyedReplicaGroup(req)
+			groups[i] = req.GetReplicaGroup()
+		}
+
+		if err := a.claimName(req); err != nil {
+			failures[i] = err
+
+			continue
+		}
+
+		if groups[i] == "" {
+			pending[i] = 1
+			units = append(units, &batchUnit{index: i, req: req, volumeNode: volumeNode})
+
+			continue
+		}
+
+		responses[i] = &pb.VmSpawnResponse{Id: groups[i], Url: groups[i] + "." + a.baseURL}
+
+		pending[i] = int(max(policy.GetMinReplicas(), 1))
+		for replica := range pending[i] {
+			replicaReq := proto.Clone(req).(*pb.VmSpawnRequest)
+			replicaReq.IdempotencyKey = replicaKey(req.GetIdempotencyKey(), replica)
+			units = append(units, &batchUnit{index: i, req: replicaReq, volumeNode: volumeNode})
+		}
+	}
+
+	// Requests that failed validation are reported right away
+	for i, err := range failures {
+		if err != nil {
+			if sendErr := send(&pb.SpawnBatchResponse{Index: uint32(i), Error: err.Error(), ErrorCode: errorCode(err)}); sendErr != nil {
+				return sendErr
+			}
+		}
+	}
+
+	results := make(chan batchResult, len(units))
+	slots := make(chan struct{}, SpawnBatchConcurrency)
+
+	var unplaced []*batchUnit
+
+	// Workloads already spawned with the same idempotency key, as far as
+	// the state broadcasts tell, are not placed again. Otherwise the node
+	// they're placed on returns the workload if it spawned it
+	for _, unit := range units {
+		if key := unit.req.GetIdempotencyKey(); key != "" {
+			if existing := a.knownSpawn(key); existing != nil {
+				results <- batchResult{unit: unit, resp: existing}
+
+				continue
+			}
+		}
+
+		unplaced = append(unplaced, unit)
+	}
+
+	for _, unit := range a.placeBatch(unplaced, spread) {
+		if unit.node == "" {
+			results <- batchResult{unit: unit, err: newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, nil, "no node has capacity for %d vCPUs and %s", unit.req.GetCores(), megabytes(unit.req.GetMemory()))}
+
+			continue
+		}
+
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			resp, err := a.spawnOnNode(unit.req, unit.node)
+			results <- batchResult{unit: unit, resp: resp, err: err}
+		}()
+	}
+
+	for range units {
+		result := <-results
+		i := result.unit.index
+
+		pending[i]--
+
+		if result.err != nil && failures[i] == nil {
+			failures[i] = result.err
+		}
+
+		if responses[i] == nil {
+			responses[i] = result.resp
+		}
+
+		if pending[i] > 0 {
+			continue
+		}
+
+		resp := &pb.SpawnBatchResponse{Index: uint32(i)}
+		if groups[i] == "" {
+			resp.Node = result.unit.node
+		}
+
+		if failures[i] != nil {
+			resp.ErrorCode = errorCode(failures[i])
+			a.releaseName(requests[i])
+		}
+
+		switch {
+		case failures[i] != nil && groups[i] != "":
+			resp.Error = fmt.Sprintf("failed to spawn replica of group %s: %s", groups[i], failures[i])
+		case failures[i] != nil:
+			resp.Error = failures[i].Error()
+		default:
+			resp.Response = responses[i]
+		}
+
+		if err := send(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// placeBatch assigns a node to each unit, leaving it empty if no node has
+// enough capacity left. Units are placed largest first, on the node with
+// the least capacity left they fit on, or when spreading on the node with
+// the fewest units of the batch, ties going to the least loaded host
+func (a *Agent) placeBatch(units []*batchUnit, spread bool) []*batchUnit {
+	nodes := a.nodeCapacities()
+
+	placed := make([]*batchUnit, len(units))
+	copy(placed, units)
+
+	sort.SliceStable(placed, func(i, j int) bool {
+		if placed[i].req.GetCores() != placed[j].req.GetCores() {
+			return placed[i].req.GetCores() > placed[j].req.GetCores()
+		}
+
+		return placed[i].req.GetMemory() > placed[j].req.GetMemory()
+	})
+
+	for _, unit := range placed {
+		cores, memory := int(unit.req.GetCores()), int(unit.req.GetMemory())
+
+		var best *nodeCapacity
+
+		for _, node := range nodes {
+			if node.cpus < cores || node.memory < memory || len(a.missingCapabilities(node.id, unit.req)) > 0 {
+				continue
+			}
+
+			if unit.volumeNode != "" && node.id != unit.volumeNode {
+				continue
+			}
+
+			if best == nil || betterPlacement(node, best, spread) {
+				best = node
+			}
+		}
+
+		if best == nil {
+			continue
+		}
+
+		best.cpus -= cores
+		best.memory -= memory
+		best.placed++
+		unit.node = best.id
+	}
+
+	return placed
+}
+
+func betterPlacement(node, current *nodeCapacity, spread bool) bool {
+	if spread {
+		if node.placed != current.placed {
+			return node.placed < current.placed
+		}
+
+		if node.pressure != current.pressure {
+			return node.pressure < current.pressure
+		}
+
+		if node.cpus != current.cpus {
+			return node.cpus > current.cpus
+		}
+
+		return node.memory > current.memory
+	}
+
+	if node.cpus != current.cpus {
+		return node.cpus < current.cpus
+	}
+
+	if node.memory != current.memory {
+		return node.memory < current.memory
+	}
+
+	return node.pressure < current.pressure
+}
+
+// nodeCapacities returns the capacity left on the other nodes according
+// to their last state broadcast, the local node is left out as the
+// queries it sends are ignored by its own handler, and so are nodes too
+// low on disk space to pull images
+func (a *Agent) nodeCapacities() []*nodeCapacity {
+	var nodes []*nodeCapacity
+
+	now := time.Now()
+
+	for _, state := range a.knownStates() {
+		if state.GetNode().GetId() == a.serf.LocalMember().Name || lowOnDisk(state) {
+			continue
+		}
+
+		if a.nodeStatus(state.GetNode().GetId()) != pb.NodeStatus_NODE_READY {
+			continue
+		}
+
+		if member := a.findMember(state.GetNode().GetId()); member != nil && a.enteringMaintenance(*member, now) {
+			continue
+		}
+
+		node := &nodeCapacity{
+			id:       state.GetNode().GetId(),
+			cpus:     int(state.GetCpus()),
+			memory:   int(state.GetMemory()),
+			pressure: hostPressure(state),
+		}
+
+		for _, workload := range state.GetWorkloads() {
+			node.cpus -= int(workload.GetSourceRequest().GetCores())
+			node.memory -= int(workload.GetSourceRequest().GetMemory())
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	// Keep placements deterministic for equally loaded nodes
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].id < nodes[j].id })
+
+	return nodes
+}
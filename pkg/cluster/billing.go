@@ -0,0 +1,317 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	ctask "github.com/containerd/containerd/api/types/task"
+	"github.com/hashicorp/serf/serf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	UsageMeterPeriod = time.Second * 15
+	// Records of workloads gone for longer are dropped
+	BillingRetention = time.Hour * 24 * 30
+
+	// Timeout of the requests fetching the records of other nodes
+	billingNodeTimeout = time.Second * 10
+)
+
+type meterSample struct {
+	cpuUsage  uint64
+	egress    uint64
+	sampledAt time.Time
+}
+
+// billingLedger holds the billing records of the workloads that ran on
+// this node, persisted to path after every metering pass
+type billingLedger struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*pb.BillingRecord
+	samples map[string]*meterSample
+	// bytes sent by the workloads of each tenant since the agent
+	// started, exported as counters
+	tenantEgress map[string]uint64
+}
+
+func newBillingLedger(path string) (*billingLedger, error) {
+	ledger := &billingLedger{
+		path:         path,
+		records:      make(map[string]*pb.BillingRecord),
+		samples:      make(map[string]*meterSample),
+		tenantEgress: make(map[string]uint64),
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read billing records %s: %w", path, err)
+	}
+
+	var records []*pb.BillingRecord
+	if err := json.Unmarshal(contents, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal billing records %s: %w", path, err)
+	}
+
+	for _, record := range records {
+		ledger.records[record.GetId()] = record
+	}
+
+	return ledger, nil
+}
+
+// persist atomically replaces the records file, dropping the records of
+// workloads gone for longer than BillingRetention
+func (l *billingLedger) persist() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-BillingRetention).Unix()
+	records := make([]*pb.BillingRecord, 0, len(l.records))
+
+	for id, record := range l.records {
+		if record.GetEndUnixTime() < cutoff {
+			delete(l.records, id)
+
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	contents, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	tmpPath := l.path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, defaults.DataFilePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, l.path)
+}
+
+// list returns a copy of the records of tenant, or of all tenants if
+// it is empty
+func (l *billingLedger) list(tenant string) []*pb.BillingRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []*pb.BillingRecord
+
+	for _, record := range l.records {
+		if tenant == "" || record.GetTenant() == tenant {
+			records = append(records, proto.Clone(record).(*pb.BillingRecord))
+		}
+	}
+
+	return records
+}
+
+// meter adds the usage of a workload since its last sample to its record,
+// billed at prices. Counters are cumulative, the first sample only sets
+// the baseline
+func (l *billingLedger) meter(node, id, tenant string, memory uint32, cpuUsage, egress uint64, prices *pb.NodePrices) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	record, ok := l.records[id]
+	if !ok {
+		record = &pb.BillingRecord{Id: id, Tenant: tenant, Node: &pb.Node{Id: node}, StartUnixTime: now.Unix()}
+		l.records[id] = record
+	}
+
+	record.EndUnixTime = now.Unix()
+
+	sample, ok := l.samples[id]
+	l.samples[id] = &meterSample{cpuUsage: cpuUsage, egress: egress, sampledAt: now}
+
+	if !ok {
+		return
+	}
+
+	// Counters reset when the workload is restarted in place
+	cpuDelta, egressDelta := cpuUsage, egress
+	if cpuUsage >= sample.cpuUsage {
+		cpuDelta = cpuUsage - sample.cpuUsage
+	}
+
+	if egress >= sample.egress {
+		egressDelta = egress - sample.egress
+	}
+
+	cpuSeconds := float64(cpuDelta) / float64(time.Second)
+	gbHours := float64(memory) / 1024 * now.Sub(sample.sampledAt).Hours()
+	egressGB := float64(egressDelta) / (1024 * 1024 * 1024)
+
+	record.CpuSeconds += cpuSeconds
+	record.GbHours += gbHours
+	record.EgressGb += egressGB
+	record.EgressBytes += egressDelta
+	l.tenantEgress[tenant] += egressDelta
+	record.Cost += cpuSeconds/3600*prices.GetCpuHour() + gbHours*prices.GetGbHour() + egressGB*prices.GetGbEgress()
+}
+
+// egressByTenant returns a copy of the bytes sent by the workloads of
+// each tenant since the agent started
+func (l *billingLedger) egressByTenant() map[string]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return maps.Clone(l.tenantEgress)
+}
+
+// forget drops the samples of the workloads that aren't running anymore,
+// their records are kept
+func (l *billingLedger) forget(running map[string]struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id := range l.samples {
+		if _, ok := running[id]; !ok {
+			delete(l.samples, id)
+		}
+	}
+}
+
+// meterUsage periodically meters the CPU time, memory allocation and
+// egress of the workloads running on this node
+func (a *Agent) meterUsage() {
+	ticker := time.NewTicker(UsageMeterPeriod)
+	for range ticker.C {
+		ctx := a.ctrRepo.GetContext(context.Background())
+
+		tasks, err := a.ctrRepo.GetTasks(ctx)
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get tasks")
+
+			continue
+		}
+
+		running := make(map[string]struct{})
+
+		for _, task := range tasks {
+			if task.GetStatus() != ctask.Status_RUNNING {
+				continue
+			}
+
+			container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+			if err != nil {
+				continue
+			}
+
+			labels, err := container.Labels(ctx)
+			if err != nil {
+				continue
+			}
+
+			var labelPayload pb.VmSpawnRequest
+			if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
+				continue
+			}
+
+			cpuUsage, memUsage, err := workloadUsage(task.GetID(), task.GetPid())
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to get usage for container %s", task.GetID())
+
+				continue
+			}
+
+			net, err := a.workloadNetCounters(ctx, task.GetID(), labelPayload.GetTenant(), task.GetPid())
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to get egress for container %s", task.GetID())
+			}
+
+			running[task.GetID()] = struct{}{}
+			a.billing.meter(a.cfg.NodeName, task.GetID(), labelPayload.GetTenant(), labelPayload.GetMemory(), cpuUsage, net.tx, a.prices)
+			a.usage.record(task.GetID(), cpuUsage, memUsage, net)
+		}
+
+		a.billing.forget(running)
+		a.usage.forget(running)
+
+		if a.traffic != nil {
+			a.traffic.forget(running)
+		}
+
+		if err := a.billing.persist(); err != nil {
+			a.logger.WithError(err).Error("failed to persist billing records")
+		}
+	}
+}
+
+// BillingRequest returns the billing records of this node, along with the
+// records of the other nodes unless the request is local
+func (a *Agent) BillingRequest(ctx context.Context, req *pb.BillingRequest) (*pb.BillingResponse, error) {
+	resp := &pb.BillingResponse{Records: a.billing.list(req.GetTenant())}
+
+	if !req.GetLocal() {
+		for _, member := range a.serf.Members() {
+			if member.Name == a.cfg.NodeName || member.Status != serf.StatusAlive || member.Tags[GrpcPortTag] == "" {
+				continue
+			}
+
+			records, err := a.nodeBilling(ctx, &member, req.GetTenant())
+			if err != nil {
+				a.logger.WithError(err).Warnf("failed to get billing records of node %s", member.Name)
+				resp.UnreachableNodes = append(resp.UnreachableNodes, member.Name)
+
+				continue
+			}
+
+			resp.Records = append(resp.Records, records...)
+		}
+	}
+
+	sort.Slice(resp.GetRecords(), func(i, j int) bool {
+		return resp.GetRecords()[i].GetStartUnixTime() < resp.GetRecords()[j].GetStartUnixTime()
+	})
+
+	resp.TenantEgressBytes = make(map[string]uint64)
+
+	for _, record := range resp.GetRecords() {
+		resp.TotalCost += record.GetCost()
+		resp.TenantEgressBytes[record.GetTenant()] += record.GetEgressBytes()
+	}
+
+	return resp, nil
+}
+
+func (a *Agent) nodeBilling(ctx context.Context, member *serf.Member, tenant string) ([]*pb.BillingRecord, error) {
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(forwardedContext(ctx), billingNodeTimeout)
+	defer cancel()
+
+	resp, err := pb.NewClusterServiceClient(conn).Billing(ctx, &pb.BillingRequest{Tenant: tenant, Local: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetRecords(), nil
+}
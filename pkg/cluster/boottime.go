@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/pool"
+)
+
+// writeBootMetrics writes the histograms of the boot times of the VMs of
+// this node, as recorded by their shims, in the Prometheus text format
+func writeBootMetrics(w io.Writer, node string) error {
+	stats, err := pool.ReadStats(defaults.PoolDir)
+	if err != nil {
+		return err
+	}
+
+	const name = "hypercore_vm_boot_seconds"
+
+	fmt.Fprintf(w, "# HELP %s Time from the creation of the task of a VM to each stage of its boot, by provider\n# TYPE %s histogram\n", name, name)
+
+	histograms := slices.Clone(stats.Boots)
+	slices.SortFunc(histograms, func(a, b *pool.BootHistogram) int {
+		return strings.Compare(a.Provider+"/"+a.Stage+"/"+strconv.FormatBool(a.Restored), b.Provider+"/"+b.Stage+"/"+strconv.FormatBool(b.Restored))
+	})
+
+	for _, histogram := range histograms {
+		if len(histogram.Counts) != len(pool.BootBuckets)+1 {
+			continue
+		}
+
+		labels := fmt.Sprintf("node=%q,provider=%q,stage=%q,restored=%q", node, histogram.Provider, histogram.Stage, strconv.FormatBool(histogram.Restored))
+
+		var cumulative uint64
+		for i, bound := range pool.BootBuckets {
+			cumulative += histogram.Counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, histogram.Count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, histogram.Seconds)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, histogram.Count)
+	}
+
+	return nil
+}
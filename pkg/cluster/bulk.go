@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// Workloads handled at once by a bulk request that sets no parallelism
+	DefaultBulkParallelism = defaults.BulkParallelism
+	MaxBulkParallelism     = defaults.MaxBulkParallelism
+)
+
+// bulkTarget is a workload a bulk request acts on
+type bulkTarget struct {
+	id   string
+	node string
+	// set if the requested workload couldn't be resolved
+	err error
+}
+
+// bulkTargets returns the workloads matching the selector of the request,
+// or those it gives by ID or name
+func (a *Agent) bulkTargets(req *pb.BulkRequest) ([]bulkTarget, error) {
+	if (req.GetLabelSelector() == "") == (len(req.GetIds()) == 0) {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "either a label selector or IDs are required, not both")
+	}
+
+	var targets []bulkTarget
+
+	if req.GetLabelSelector() != "" {
+		selector, err := parseRequiredSelector(req.GetLabelSelector())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range a.selectedWorkloads(req.GetTenant(), selector) {
+			targets = append(targets, bulkTarget{id: id, node: a.workloadNode(id)})
+		}
+
+		if len(targets) == 0 {
+			return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload matches %s", req.GetLabelSelector())
+		}
+
+		return targets, nil
+	}
+
+	seen := make(map[string]struct{})
+
+	for _, ref := range req.GetIds() {
+		id, err := a.resolveWorkload(req.GetTenant(), ref, true)
+		if err != nil {
+			targets = append(targets, bulkTarget{id: ref, err: err})
+
+			continue
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		targets = append(targets, bulkTarget{id: id, node: a.workloadNode(id)})
+	}
+
+	return targets, nil
+}
+
+// StopManyRequest stops the workloads of the request like a stop request
+// for each of them, which returns once they are drained in the background
+func (a *Agent) StopManyRequest(ctx context.Context, req *pb.BulkRequest, send func(*pb.BulkProgress) error) error {
+	return a.bulkRequest(ctx, req, send, func(_ context.Context, id string) error {
+		a.forgetApplied(id)
+
+		_, err := a.StopRequest(&pb.VmStopRequest{Id: id, WorkloadOnly: true})
+
+		return err
+	})
+}
+
+// RestartManyRequest restarts the workloads of the request, each restart
+// holding its slots until the workload was created again
+func (a *Agent) RestartManyRequest(ctx context.Context, req *pb.BulkRequest, send func(*pb.BulkProgress) error) error {
+	return a.bulkRequest(ctx, req, send, func(ctx context.Context, id string) error {
+		_, err := a.RestartRequest(ctx, &pb.RestartRequest{Id: id})
+
+		return err
+	})
+}
+
+// bulkRequest runs op on each workload of the request, at most parallelism
+// at once and max_per_node at once on a node, calling send with the result
+// of each workload as soon as it completes and then with a summary.
+// Workloads not started by the time ctx is done fail with its error
+func (a *Agent) bulkRequest(ctx context.Context, req *pb.BulkRequest, send func(*pb.BulkProgress) error, op func(ctx context.Context, id string) error) error {
+	targets, err := a.bulkTargets(req)
+	if err != nil {
+		return err
+	}
+
+	parallelism := int(req.GetParallelism())
+	if parallelism == 0 {
+		parallelism = DefaultBulkParallelism
+	}
+	parallelism = min(parallelism, MaxBulkParallelism)
+
+	slots := make(chan struct{}, parallelism)
+
+	nodeSlots := make(map[string]chan struct{})
+	if req.GetMaxPerNode() > 0 {
+		for _, target := range targets {
+			if _, ok := nodeSlots[target.node]; !ok {
+				nodeSlots[target.node] = make(chan struct{}, req.GetMaxPerNode())
+			}
+		}
+	}
+
+	results := make(chan *pb.BulkItemResult, len(targets))
+
+	for _, target := range targets {
+		if target.err != nil {
+			results <- bulkItemResult(target, target.err)
+
+			continue
+		}
+
+		go func() {
+			results <- bulkItemResult(target, runBulkOp(ctx, slots, nodeSlots[target.node], func() error {
+				return op(ctx, target.id)
+			}))
+		}()
+	}
+
+	summary := &pb.BulkSummary{Total: uint32(len(targets))}
+
+	for range targets {
+		item := <-results
+
+		if item.GetError() != "" {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+
+		if err := send(&pb.BulkProgress{Item: item}); err != nil {
+			return err
+		}
+	}
+
+	a.logger.Infof("Handled %d workloads in bulk, %d failed", summary.GetTotal(), summary.GetFailed())
+
+	return send(&pb.BulkProgress{Summary: summary})
+}
+
+// runBulkOp runs op once it has a slot of its node, if limited, and one of
+// the whole request. The slot of the node is taken first so workloads of
+// busy nodes don't hold the slots of the others
+func runBulkOp(ctx context.Context, slots, nodeSlots chan struct{}, op func() error) error {
+	if nodeSlots != nil {
+		if err := acquireSlot(ctx, nodeSlots); err != nil {
+			return err
+		}
+		defer func() { <-nodeSlots }()
+	}
+
+	if err := acquireSlot(ctx, slots); err != nil {
+		return err
+	}
+	defer func() { <-slots }()
+
+	return op()
+}
+
+func acquireSlot(ctx context.Context, slots chan struct{}) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func bulkItemResult(target bulkTarget, err error) *pb.BulkItemResult {
+	result := &pb.BulkItemResult{Id: target.id, Node: target.node}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorCode = errorCode(err)
+	}
+
+	return result
+}
@@ -0,0 +1,353 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	TrafficSplitEvent = "hypercore_traffic_split"
+
+	DefaultCanaryAnalysis = defaults.CanaryAnalysis
+)
+
+// canaryRun is a canary being analyzed by this node
+type canaryRun struct {
+	id           string
+	spec         *pb.VmSpawnRequest
+	canaryIDs    []string
+	stable       []*pb.WorkloadState
+	maxErrorRate float64
+	minRequests  uint64
+	analysis     time.Duration
+}
+
+// CanaryRequest spawns replicas of the updated spec of a workload and
+// sends them a share of its traffic. If a maximum error rate is set they
+// are analyzed in the background, and either replace the other replicas
+// or are stopped once the analysis completes
+func (a *Agent) CanaryRequest(req *pb.CanaryRequest) (*pb.UpdateWorkloadResponse, error) {
+	id := req.GetUpdate().GetId()
+
+	if req.GetWeight() > 100 {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "weight must be between 0 and 100, got %d", req.GetWeight())
+	}
+
+	if req.GetMaxErrorRate() < 0 || req.GetMaxErrorRate() > 1 {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "max error rate must be between 0 and 1, got %f", req.GetMaxErrorRate())
+	}
+
+	if !a.updates.start(id) {
+		return nil, status.Errorf(codes.Aborted, "an update of %s is already in progress", id)
+	}
+
+	run, resp, err := a.startCanary(req)
+	if err != nil || run == nil {
+		a.updates.done(id)
+
+		return resp, err
+	}
+
+	go a.analyzeCanary(run)
+
+	return resp, nil
+}
+
+// startCanary spawns the canary replicas and splits the traffic, it
+// returns the run to analyze if the request has a maximum error rate
+func (a *Agent) startCanary(req *pb.CanaryRequest) (*canaryRun, *pb.UpdateWorkloadResponse, error) {
+	id := req.GetUpdate().GetId()
+
+	workloads := a.updateTargets(id)
+	if len(workloads) == 0 {
+		return nil, nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", id)
+	}
+
+	spec, err := a.nextRevision(id, workloads, applyUpdate(req.GetUpdate()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replicas := max(req.GetReplicas(), 1)
+
+	a.logger.Infof("Starting canary of workload %s revision %d with %d replicas and %d%% of the traffic", id, spec.GetRevision(), replicas, req.GetWeight())
+
+	resp := &pb.UpdateWorkloadResponse{
+		Url:      id + "." + a.baseURL,
+		Revision: &pb.WorkloadRevision{Id: id, Revision: spec.GetRevision(), Spec: spec, UnixTime: time.Now().Unix()},
+	}
+
+	for range replicas {
+		spawned, err := a.spawnHealthy(spec)
+		if err != nil {
+			a.stopCanaryReplicas(resp.GetIds())
+
+			return nil, nil, fmt.Errorf("failed to spawn canary of %s: %w", id, err)
+		}
+
+		resp.Ids = append(resp.Ids, spawned)
+	}
+
+	if err := a.setTrafficSplit(&pb.TrafficSplit{Id: id, Revision: spec.GetRevision(), Weight: req.GetWeight()}); err != nil {
+		a.stopCanaryReplicas(resp.GetIds())
+
+		return nil, nil, err
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_UPDATE,
+		Id:           id,
+		ReplicaGroup: id,
+		Message:      fmt.Sprintf("started canary of revision %d with %d%% of the traffic", spec.GetRevision(), req.GetWeight()),
+	})
+
+	if req.GetMaxErrorRate() == 0 {
+		return nil, resp, nil
+	}
+
+	analysis := time.Duration(req.GetAnalysisSeconds()) * time.Second
+	if analysis == 0 {
+		analysis = DefaultCanaryAnalysis
+	}
+
+	return &canaryRun{
+		id:           id,
+		spec:         spec,
+		canaryIDs:    resp.GetIds(),
+		stable:       workloads,
+		maxErrorRate: req.GetMaxErrorRate(),
+		minRequests:  req.GetMinRequests(),
+		analysis:     analysis,
+	}, resp, nil
+}
+
+// analyzeCanary samples the error rate of the canary from the state
+// broadcasts, rolling it back as soon as it exceeds the maximum error
+// rate and promoting it if it doesn't by the end of the analysis
+func (a *Agent) analyzeCanary(run *canaryRun) {
+	defer a.updates.done(run.id)
+
+	ticker := time.NewTicker(a.broadcastPeriod)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(run.analysis)
+	defer deadline.Stop()
+
+	// States already seen, so each broadcast period is only counted once
+	seen := make(map[string]*pb.NodeStateResponse)
+	revisionMetrics(a.knownStates(), seen, run.id, run.spec.GetRevision())
+
+	var requests, errors uint64
+
+	for {
+		select {
+		case <-ticker.C:
+			newRequests, newErrors := revisionMetrics(a.knownStates(), seen, run.id, run.spec.GetRevision())
+			requests += newRequests
+			errors += newErrors
+
+			if requests > 0 && requests >= run.minRequests && errorRate(requests, errors) > run.maxErrorRate {
+				a.rollbackCanary(run, fmt.Sprintf("error rate %.3f above %.3f", errorRate(requests, errors), run.maxErrorRate))
+
+				return
+			}
+		case <-deadline.C:
+			switch {
+			case requests == 0 || requests < run.minRequests:
+				a.rollbackCanary(run, fmt.Sprintf("only %d requests served during the analysis", requests))
+			case errorRate(requests, errors) > run.maxErrorRate:
+				a.rollbackCanary(run, fmt.Sprintf("error rate %.3f above %.3f", errorRate(requests, errors), run.maxErrorRate))
+			default:
+				a.promoteCanary(run, errorRate(requests, errors))
+			}
+
+			return
+		}
+	}
+}
+
+// revisionMetrics sums the requests served by a revision of a service in
+// the states that weren't seen yet
+func revisionMetrics(states []*pb.NodeStateResponse, seen map[string]*pb.NodeStateResponse, serviceID string, revision uint32) (uint64, uint64) {
+	var requests, errors uint64
+
+	for _, state := range states {
+		if seen[state.GetNode().GetId()] == state {
+			continue
+		}
+		seen[state.GetNode().GetId()] = state
+
+		for _, metrics := range state.GetServiceMetrics() {
+			if metrics.GetServiceId() != serviceID {
+				continue
+			}
+
+			for _, revisionMetrics := range metrics.GetRevisions() {
+				if revisionMetrics.GetRevision() == revision {
+					requests += revisionMetrics.GetRequests()
+					errors += revisionMetrics.GetErrors()
+				}
+			}
+		}
+	}
+
+	return requests, errors
+}
+
+func errorRate(requests, errors uint64) float64 {
+	if requests == 0 {
+		return 0
+	}
+
+	return float64(errors) / float64(requests)
+}
+
+// promoteCanary sends all the traffic to the canary and replaces the
+// other replicas with replicas of its revision, keeping the replica count
+func (a *Agent) promoteCanary(run *canaryRun, rate float64) {
+	for _, canaryID := range run.canaryIDs {
+		if workload, _ := a.findWorkload(canaryID); workload == nil {
+			a.rollbackCanary(run, fmt.Sprintf("canary replica %s is gone", canaryID))
+
+			return
+		}
+	}
+
+	a.logger.Infof("Promoting canary of workload %s revision %d with error rate %.3f", run.id, run.spec.GetRevision(), rate)
+
+	if err := a.setTrafficSplit(&pb.TrafficSplit{Id: run.id, Revision: run.spec.GetRevision(), Weight: 100}); err != nil {
+		a.logger.WithError(err).Errorf("failed to send the traffic of %s to its canary", run.id)
+	}
+
+	for i, old := range run.stable {
+		if i < len(run.canaryIDs) {
+			if _, err := a.StopRequest(&pb.VmStopRequest{Id: old.GetId(), WorkloadOnly: true}); err != nil {
+				a.logger.WithError(err).Errorf("failed to stop replaced workload %s", old.GetId())
+			}
+
+			continue
+		}
+
+		if _, err := a.replaceReplica(run.id, old, run.spec); err != nil {
+			a.logger.WithError(err).Errorf("failed to promote canary of %s", run.id)
+			a.publishEvent(&pb.WatchEventsResponse{
+				Event:        pb.ClusterEvent_ERROR,
+				Id:           run.id,
+				ReplicaGroup: run.id,
+				Message:      fmt.Sprintf("failed to promote canary of revision %d: %s", run.spec.GetRevision(), err),
+			})
+
+			return
+		}
+	}
+
+	revision := &pb.WorkloadRevision{Id: run.id, Revision: run.spec.GetRevision(), Spec: run.spec, UnixTime: time.Now().Unix()}
+	if err := a.recordRevision(revision); err != nil {
+		a.logger.WithError(err).Errorf("failed to record revision %d of %s", revision.GetRevision(), run.id)
+	}
+
+	if err := a.setTrafficSplit(&pb.TrafficSplit{Id: run.id, Clear: true}); err != nil {
+		a.logger.WithError(err).Errorf("failed to clear the traffic split of %s", run.id)
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_UPDATE,
+		Id:           run.id,
+		ReplicaGroup: run.id,
+		Message:      fmt.Sprintf("promoted canary of revision %d with error rate %.3f", run.spec.GetRevision(), rate),
+	})
+}
+
+// rollbackCanary sends all the traffic to the other replicas and stops
+// the canary
+func (a *Agent) rollbackCanary(run *canaryRun, reason string) {
+	a.logger.Warnf("Rolling back canary of workload %s revision %d: %s", run.id, run.spec.GetRevision(), reason)
+
+	if err := a.setTrafficSplit(&pb.TrafficSplit{Id: run.id, Revision: run.spec.GetRevision(), Weight: 0}); err != nil {
+		a.logger.WithError(err).Errorf("failed to send the traffic of %s away from its canary", run.id)
+	}
+
+	a.stopCanaryReplicas(run.canaryIDs)
+
+	if err := a.setTrafficSplit(&pb.TrafficSplit{Id: run.id, Clear: true}); err != nil {
+		a.logger.WithError(err).Errorf("failed to clear the traffic split of %s", run.id)
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_UPDATE,
+		Id:           run.id,
+		ReplicaGroup: run.id,
+		Message:      fmt.Sprintf("rolled back canary of revision %d: %s", run.spec.GetRevision(), reason),
+	})
+}
+
+func (a *Agent) stopCanaryReplicas(ids []string) {
+	for _, id := range ids {
+		if _, err := a.StopRequest(&pb.VmStopRequest{Id: id, WorkloadOnly: true}); err != nil {
+			a.logger.WithError(err).Errorf("failed to stop canary replica %s", id)
+		}
+	}
+}
+
+// SetTrafficSplitRequest sets how the traffic of a workload is split
+// between its revisions across the cluster
+func (a *Agent) SetTrafficSplitRequest(req *pb.TrafficSplit) (*pb.TrafficSplit, error) {
+	if req.GetWeight() > 100 {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "weight must be between 0 and 100, got %d", req.GetWeight())
+	}
+
+	if len(a.updateTargets(req.GetId())) == 0 {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
+
+	if err := a.setTrafficSplit(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// setTrafficSplit applies a traffic split to the proxy of this node and
+// broadcasts it to the other nodes
+func (a *Agent) setTrafficSplit(split *pb.TrafficSplit) error {
+	a.applyTrafficSplit(split)
+
+	payload, err := proto.Marshal(split)
+	if err != nil {
+		return err
+	}
+
+	if err := a.serf.UserEvent(TrafficSplitEvent, payload, false); err != nil {
+		return fmt.Errorf("failed to broadcast traffic split of %s: %w", split.GetId(), err)
+	}
+
+	return nil
+}
+
+func (a *Agent) applyTrafficSplit(split *pb.TrafficSplit) {
+	if split.GetClear() {
+		a.serviceProxy.ClearSplit(split.GetId())
+
+		return
+	}
+
+	a.serviceProxy.SetSplit(split.GetId(), TrafficSplit{Revision: split.GetRevision(), Weight: split.GetWeight()})
+}
+
+func (a *Agent) handleTrafficSplitEvent(payload []byte) {
+	var split pb.TrafficSplit
+	if err := proto.Unmarshal(payload, &split); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal traffic split event")
+
+		return
+	}
+
+	a.applyTrafficSplit(&split)
+}
@@ -0,0 +1,369 @@
+package cluster
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// How often the certificate files are checked for changes
+	CertReloadPeriod = time.Second * 30
+
+	spiffeScheme = "spiffe"
+)
+
+var (
+	errNoPeerCertificate = errors.New("no certificate presented")
+	errNoClusterCA       = errors.New("no cluster CA to authenticate the other nodes with")
+)
+
+// CertSource holds a certificate and the CA its peers must be signed by,
+// reloaded whenever their files change so they can be rotated without
+// restarting the agent. Nodes reach each other by address, so peers are
+// authenticated by the CA that issued their certificate rather than by
+// name, and by their SPIFFE ID (spiffe://<trust domain>/...) if a trust
+// domain is set
+type CertSource struct {
+	logger      *log.Logger
+	certFile    string
+	keyFile     string
+	caFile      string
+	trustDomain string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+func NewCertSource(logger *log.Logger, certFile, keyFile, caFile, trustDomain string) (*CertSource, error) {
+	source := &CertSource{
+		logger:      logger,
+		certFile:    certFile,
+		keyFile:     keyFile,
+		caFile:      caFile,
+		trustDomain: trustDomain,
+	}
+
+	if _, err := source.reload(); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// latestModTime returns the most recent modification time of the files
+func (c *CertSource) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, path := range []string{c.certFile, c.keyFile, c.caFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// reload loads the files if they changed since they were last loaded,
+// returning whether they did
+func (c *CertSource) reload() (bool, error) {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat certificate files: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := !c.modTime.IsZero() && modTime.Equal(c.modTime)
+	c.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+
+	if c.certFile != "" || c.keyFile != "" {
+		loaded, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to load key pair %s/%s: %w", c.certFile, c.keyFile, err)
+		}
+
+		cert = &loaded
+	}
+
+	var pool *x509.CertPool
+
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read CA %s: %w", c.caFile, err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in CA %s", c.caFile)
+		}
+	}
+
+	c.mu.Lock()
+	c.cert = cert
+	c.pool = pool
+	c.modTime = modTime
+	c.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch reloads the files when they change until ctx is done, the
+// previous certificate is kept if they can't be loaded, e.g. while only
+// some of them were replaced
+func (c *CertSource) Watch(ctx context.Context) {
+	ticker := time.NewTicker(CertReloadPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := c.reload()
+		if err != nil {
+			c.logger.WithError(err).Warn("failed to reload certificates, keeping the current ones")
+
+			continue
+		}
+
+		if reloaded {
+			c.logger.Infof("Reloaded certificate %s", c.certFile)
+		}
+	}
+}
+
+func (c *CertSource) certificate() (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cert == nil {
+		return nil, errors.New("no certificate configured")
+	}
+
+	return c.cert, nil
+}
+
+// HasCA returns whether peers are verified against a cluster CA
+func (c *CertSource) HasCA() bool {
+	return c.caFile != ""
+}
+
+// ServerTLSConfig presents the current certificate, and requires clients
+// to present a certificate issued by the CA if one is set
+func (c *CertSource) ServerTLSConfig() *tls.Config {
+	tlsConfig := c.ListenerTLSConfig()
+
+	if c.HasCA() {
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			_, err := c.VerifyPeer(state.PeerCertificates, x509.ExtKeyUsageClientAuth)
+
+			return err
+		}
+	}
+
+	return tlsConfig
+}
+
+// ListenerTLSConfig presents the current certificate without verifying
+// clients
+func (c *CertSource) ListenerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.certificate()
+		},
+	}
+}
+
+// ClientTLSConfig presents the current certificate if there is one, and
+// verifies servers against the CA if one is set
+func (c *CertSource) ClientTLSConfig() *tls.Config {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			if c.cert == nil {
+				return &tls.Certificate{}, nil
+			}
+
+			return c.cert, nil
+		},
+	}
+
+	if c.HasCA() {
+		// Verified against the current CA rather than one fixed here
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			_, err := c.VerifyPeer(state.PeerCertificates, x509.ExtKeyUsageServerAuth)
+
+			return err
+		}
+	}
+
+	return tlsConfig
+}
+
+// VerifyPeer verifies that a peer certificate chain was issued by the CA,
+// and that it carries a SPIFFE ID of the trust domain if one is set. It
+// returns the SPIFFE ID of the peer
+func (c *CertSource) VerifyPeer(chain []*x509.Certificate, usage x509.ExtKeyUsage) (string, error) {
+	if len(chain) == 0 {
+		return "", errNoPeerCertificate
+	}
+
+	c.mu.RLock()
+	pool := c.pool
+	c.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+
+	for _, intermediate := range chain[1:] {
+		opts.Intermediates.AddCert(intermediate)
+	}
+
+	if _, err := chain[0].Verify(opts); err != nil {
+		return "", fmt.Errorf("peer certificate not issued by the cluster CA: %w", err)
+	}
+
+	if c.trustDomain == "" {
+		return "", nil
+	}
+
+	for _, uri := range chain[0].URIs {
+		if uri.Scheme == spiffeScheme && uri.Host == c.trustDomain {
+			return uri.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("peer certificate has no SPIFFE ID in trust domain %s", c.trustDomain)
+}
+
+// signatureAlgorithm returns the algorithm the messages signed with the
+// key of the certificate are verified with
+func signatureAlgorithm(public crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch public.(type) {
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA256, nil
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported certificate key %T", public)
+	}
+}
+
+// Sign signs the message with the key of the current certificate, returning
+// the certificate chain it is verified with by VerifySigned
+func (c *CertSource) Sign(message []byte) ([][]byte, []byte, error) {
+	cert, err := c.certificate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	algorithm, err := signatureAlgorithm(leaf.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("certificate key %T can't sign", cert.PrivateKey)
+	}
+
+	var signature []byte
+
+	if algorithm == x509.PureEd25519 {
+		signature, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign with the certificate key: %w", err)
+	}
+
+	return cert.Certificate, signature, nil
+}
+
+// VerifySigned checks that the message was signed by Sign on a peer whose
+// certificate chain was issued by the CA, returning the SPIFFE ID of the
+// peer like VerifyPeer
+func (c *CertSource) VerifySigned(chain [][]byte, message, signature []byte) (string, error) {
+	if !c.HasCA() {
+		return "", errNoClusterCA
+	}
+
+	certs := make([]*x509.Certificate, 0, len(chain))
+
+	for _, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse peer certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	id, err := c.VerifyPeer(certs, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return "", err
+	}
+
+	algorithm, err := signatureAlgorithm(certs[0].PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := certs[0].CheckSignature(algorithm, message, signature); err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return id, nil
+}
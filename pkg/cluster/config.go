@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vistara-node/pkg/compression"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+// Shortest broadcast period accepted, nodes are considered failed after
+// missing three broadcasts so it must leave room for gossip to converge
+const minBroadcastPeriod = time.Second
+
+// validate rejects settings the agent can't run with, zero values are
+// replaced by defaults and are always valid
+func (c *AgentConfig) validate() error {
+	if c.BroadcastPeriod != 0 && c.BroadcastPeriod < minBroadcastPeriod {
+		return fmt.Errorf("broadcast period %s is shorter than %s", c.BroadcastPeriod, minBroadcastPeriod)
+	}
+
+	if c.GossipInterval < 0 || c.ProbeInterval < 0 {
+		return errors.New("gossip and probe intervals can't be negative")
+	}
+
+	if err := compression.Validate(c.GrpcCompression); err != nil {
+		return err
+	}
+
+	broadcastPeriod := c.BroadcastPeriod
+	if broadcastPeriod == 0 {
+		broadcastPeriod = DefaultWorkloadBroadcastPeriod
+	}
+
+	if c.GossipInterval >= broadcastPeriod || c.ProbeInterval >= broadcastPeriod {
+		return fmt.Errorf("gossip and probe intervals must be shorter than the broadcast period %s", broadcastPeriod)
+	}
+
+	if c.UserEventSizeLimit < 0 || c.UserEventSizeLimit > serf.UserEventSizeLimit {
+		return fmt.Errorf("user event size limit must be between 0 and %d bytes", serf.UserEventSizeLimit)
+	}
+
+	if c.QueueDepthWarning < 0 || c.MaxQueueDepth < 0 {
+		return errors.New("queue depths can't be negative")
+	}
+
+	if c.QueueDepthWarning != 0 && c.MaxQueueDepth != 0 && c.QueueDepthWarning > c.MaxQueueDepth {
+		return fmt.Errorf("queue depth warning %d is above the max queue depth %d", c.QueueDepthWarning, c.MaxQueueDepth)
+	}
+
+	if c.MaxConcurrentCreates < 0 || c.MaxQueuedSpawns < 0 {
+		return errors.New("admission limits can't be negative")
+	}
+
+	if c.SpawnRateLimit < 0 || c.SpawnRateBurst < 0 {
+		return errors.New("spawn rate limits can't be negative")
+	}
+
+	if c.MetadataTokenTTL < 0 || c.SigningKeyRotation < 0 {
+		return errors.New("metadata token TTL and signing key rotation can't be negative")
+	}
+
+	if c.Prices.GetCpuHour() < 0 || c.Prices.GetGbHour() < 0 || c.Prices.GetGbEgress() < 0 {
+		return errors.New("prices can't be negative")
+	}
+
+	if c.ClusterDNS != nil {
+		if err := c.ClusterDNS.Validate(); err != nil {
+			return fmt.Errorf("invalid cluster DNS: %w", err)
+		}
+	}
+
+	if c.Maintenance.enabled() {
+		if _, err := c.Maintenance.schedules(); err != nil {
+			return fmt.Errorf("invalid maintenance windows: %w", err)
+		}
+	}
+
+	if c.MaintenanceLead < 0 {
+		return errors.New("maintenance lead can't be negative")
+	}
+
+	if c.Alerts != nil && (c.Alerts.RateLimit < 0 || c.Alerts.DedupWindow < 0) {
+		return errors.New("alert rate limit and dedup window can't be negative")
+	}
+
+	if c.MetricsPush != nil {
+		if err := c.MetricsPush.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applySerfTuning overrides the serf defaults with the non-zero settings
+func (c *AgentConfig) applySerfTuning(cfg *serf.Config) {
+	if c.GossipInterval != 0 {
+		cfg.MemberlistConfig.GossipInterval = c.GossipInterval
+	}
+
+	if c.ProbeInterval != 0 {
+		cfg.MemberlistConfig.ProbeInterval = c.ProbeInterval
+		// The probe timeout must stay below the interval
+		cfg.MemberlistConfig.ProbeTimeout = min(cfg.MemberlistConfig.ProbeTimeout, c.ProbeInterval/2)
+	}
+
+	if c.UserEventSizeLimit != 0 {
+		cfg.UserEventSizeLimit = c.UserEventSizeLimit
+	}
+
+	if c.QueueDepthWarning != 0 {
+		cfg.QueueDepthWarning = c.QueueDepthWarning
+	}
+
+	if c.MaxQueueDepth != 0 {
+		cfg.MaxQueueDepth = c.MaxQueueDepth
+	}
+}
+
+// Config returns the effective tuning of the agent
+func (a *Agent) Config() *pb.ConfigResponse {
+	return &pb.ConfigResponse{
+		Node:                 &pb.Node{Id: a.cfg.NodeName},
+		GossipIntervalMs:     uint32(a.cfg.MemberlistConfig.GossipInterval.Milliseconds()),
+		ProbeIntervalMs:      uint32(a.cfg.MemberlistConfig.ProbeInterval.Milliseconds()),
+		BroadcastPeriodMs:    uint32(a.broadcastPeriod.Milliseconds()),
+		UserEventSizeLimit:   uint32(a.cfg.UserEventSizeLimit),
+		QueueDepthWarning:    uint32(a.cfg.QueueDepthWarning),
+		MaxQueueDepth:        uint32(a.cfg.MaxQueueDepth),
+		MaxConcurrentCreates: uint32(a.admission.limit),
+		MaxQueuedSpawns:      uint32(a.admission.maxQueue),
+		SpawnRateLimit:       a.rateLimits.rate,
+		SpawnRateBurst:       uint32(a.rateLimits.burst),
+	}
+}
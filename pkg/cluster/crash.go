@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+const crashNodeTimeout = time.Second * 10
+
+// CollectCrashRequest returns the crash bundles of a workload written on
+// this node, along with the bundles of the other nodes unless the request
+// is local, a crashed workload is no longer part of the cluster state
+func (a *Agent) CollectCrashRequest(ctx context.Context, req *pb.CollectCrashRequest) (*pb.CollectCrashResponse, error) {
+	if req.GetId() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "ID of the workload is required")
+	}
+
+	bundles, err := a.localCrashBundles(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.CollectCrashResponse{Bundles: bundles}
+
+	if !req.GetLocal() {
+		for _, member := range a.serf.Members() {
+			if member.Name == a.cfg.NodeName || member.Status != serf.StatusAlive || member.Tags[GrpcPortTag] == "" {
+				continue
+			}
+
+			bundles, err := a.nodeCrashBundles(ctx, &member, req.GetId())
+			if err != nil {
+				a.logger.WithError(err).Warnf("failed to get crash bundles of node %s", member.Name)
+				resp.UnreachableNodes = append(resp.UnreachableNodes, member.Name)
+
+				continue
+			}
+
+			resp.Bundles = append(resp.Bundles, bundles...)
+		}
+	}
+
+	sort.Slice(resp.GetBundles(), func(i, j int) bool {
+		return resp.GetBundles()[i].GetExitedUnixTime() < resp.GetBundles()[j].GetExitedUnixTime()
+	})
+
+	return resp, nil
+}
+
+// localCrashBundles reads the bundles the shim of the workload wrote, one
+// directory per crash named after the time the VM exited
+func (a *Agent) localCrashBundles(id string) ([]*pb.CrashBundle, error) {
+	dir := filepath.Join(defaults.CrashDir, filepath.Base(id))
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list crash bundles of %s: %w", id, err)
+	}
+
+	bundles := make([]*pb.CrashBundle, 0, len(entries))
+
+	for _, entry := range entries {
+		exitedAt, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if !entry.IsDir() || err != nil {
+			continue
+		}
+
+		bundle := &pb.CrashBundle{
+			Node:           a.cfg.NodeName,
+			Path:           filepath.Join(dir, entry.Name()),
+			ExitedUnixTime: exitedAt,
+		}
+
+		files, err := os.ReadDir(bundle.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list crash bundle %s: %w", bundle.GetPath(), err)
+		}
+
+		for _, file := range files {
+			content, err := os.ReadFile(filepath.Join(bundle.GetPath(), file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read crash bundle %s: %w", bundle.GetPath(), err)
+			}
+
+			bundle.Files = append(bundle.Files, &pb.CrashFile{Name: file.Name(), Content: content})
+		}
+
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, nil
+}
+
+func (a *Agent) nodeCrashBundles(ctx context.Context, member *serf.Member, id string) ([]*pb.CrashBundle, error) {
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(forwardedContext(ctx), crashNodeTimeout)
+	defer cancel()
+
+	resp, err := pb.NewClusterServiceClient(conn).CollectCrash(ctx, &pb.CollectCrashRequest{Id: id, Local: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetBundles(), nil
+}
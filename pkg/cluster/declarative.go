@@ -0,0 +1,319 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DefaultWorkloadCores  = 1
+	DefaultWorkloadMemory = 512
+
+	// How long apply results are kept for idempotent retries
+	IdempotencyKeyTTL = time.Hour * 24
+)
+
+// Workload names end up as the first label of the workload URL
+var workloadNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+type appliedWorkload struct {
+	desc      *pb.WorkloadDescription
+	appliedAt time.Time
+}
+
+type idempotentApply struct {
+	specHash  string
+	desc      *pb.WorkloadDescription
+	appliedAt time.Time
+}
+
+// applyState tracks the apply requests handled by this node
+type applyState struct {
+	mu sync.Mutex
+	// apply results by idempotency key
+	keys map[string]idempotentApply
+	// last apply result by workload name, used until the
+	// spawned workloads show up in the state broadcasts
+	applied map[string]appliedWorkload
+	// names of the workloads whose replicas are being spawned
+	inProgress map[string]struct{}
+}
+
+func newApplyState() *applyState {
+	return &applyState{
+		keys:       make(map[string]idempotentApply),
+		applied:    make(map[string]appliedWorkload),
+		inProgress: make(map[string]struct{}),
+	}
+}
+
+func (s *applyState) done(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inProgress, name)
+}
+
+// normalizeSpec fills in defaults and clears the fields assigned by the
+// cluster, so equivalent specs have the same hash
+func normalizeSpec(spec *pb.VmSpawnRequest) *pb.VmSpawnRequest {
+	normalized := proto.Clone(spec).(*pb.VmSpawnRequest)
+	if normalized == nil {
+		normalized = &pb.VmSpawnRequest{}
+	}
+
+	normalized.DryRun = false
+	normalized.ReplicaGroup = ""
+	normalized.SpecHash = ""
+	normalized.IdempotencyKey = ""
+	normalized.Revision = 0
+
+	if normalized.GetCores() == 0 {
+		normalized.Cores = DefaultWorkloadCores
+	}
+
+	if normalized.GetMemory() == 0 {
+		normalized.Memory = DefaultWorkloadMemory
+	}
+
+	return normalized
+}
+
+func specHash(normalized *pb.VmSpawnRequest) (string, error) {
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ApplyRequest creates the workload if it doesn't exist, or replaces its
+// replicas if they were applied with a different spec
+func (a *Agent) ApplyRequest(req *pb.ApplyRequest) (*pb.WorkloadDescription, error) {
+	if !workloadNameRegexp.MatchString(req.GetName()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid workload name %q, must be a lowercase DNS label", req.GetName())
+	}
+
+	spec := normalizeSpec(req.GetSpec())
+
+	hash, err := specHash(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash spec: %w", err)
+	}
+
+	desc, replicas, err := a.planApply(req, spec, hash)
+	if err != nil || replicas == 0 {
+		return desc, err
+	}
+	defer a.applies.done(req.GetName())
+
+	a.logger.Infof("Applying workload %s with spec %s, replacing %d replicas with %d", req.GetName(), hash, len(desc.GetIds()), replicas)
+
+	existing := desc.GetIds()
+	desc.Ids = nil
+
+	replica := proto.Clone(spec).(*pb.VmSpawnRequest)
+	replica.ReplicaGroup = req.GetName()
+	replica.SpecHash = hash
+
+	batch := make([]*pb.VmSpawnRequest, replicas)
+	for i := range batch {
+		batch[i] = replica
+	}
+
+	// Replicas are spread across nodes so a node failure
+	// doesn't take the whole workload down
+	var failures []string
+	failureCode := pb.ErrorCode_UNKNOWN_ERROR
+	err = a.spawnBatch(batch, true, func(resp *pb.SpawnBatchResponse) error {
+		if resp.GetError() != "" {
+			if len(failures) == 0 {
+				failureCode = resp.GetErrorCode()
+			}
+
+			failures = append(failures, resp.GetError())
+		} else {
+			desc.Ids = append(desc.Ids, resp.GetResponse().GetId())
+		}
+
+		return nil
+	})
+	if err != nil || len(failures) > 0 {
+		// The previous replicas keep serving, the new ones would be
+		// left running outside of any apply
+		a.stopReplicas(req.GetName(), desc.GetIds(), "partially applied")
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to spawn replicas of %s: %w", req.GetName(), err)
+		}
+
+		return nil, newClusterError(failureCode, nil, "failed to spawn %d of %d replicas of %s: %s", len(failures), replicas, req.GetName(), strings.Join(failures, "; "))
+	}
+
+	// Only remove the previous replicas once the new ones are running
+	a.stopReplicas(req.GetName(), existing, "outdated")
+
+	a.applies.mu.Lock()
+	defer a.applies.mu.Unlock()
+
+	a.applies.applied[req.GetName()] = appliedWorkload{desc: desc, appliedAt: time.Now()}
+
+	if key := req.GetIdempotencyKey(); key != "" {
+		a.applies.keys[key] = idempotentApply{specHash: hash, desc: desc, appliedAt: time.Now()}
+	}
+
+	return desc, nil
+}
+
+// planApply returns the workload as it is if it is up to date with the
+// spec, or as returned to the previous request with the idempotency key.
+// Otherwise it returns it with Changed set, along with the number of
+// replicas to spawn, and marks the apply as in progress until
+// applies.done is called. Spawning happens outside of the lock so applies
+// of other workloads aren't held up
+func (a *Agent) planApply(req *pb.ApplyRequest, spec *pb.VmSpawnRequest, hash string) (*pb.WorkloadDescription, int, error) {
+	a.applies.mu.Lock()
+	defer a.applies.mu.Unlock()
+
+	if key := req.GetIdempotencyKey(); key != "" {
+		for key, previous := range a.applies.keys {
+			if time.Since(previous.appliedAt) > IdempotencyKeyTTL {
+				delete(a.applies.keys, key)
+			}
+		}
+
+		if previous, ok := a.applies.keys[key]; ok {
+			if previous.specHash != hash {
+				return nil, 0, status.Errorf(codes.FailedPrecondition, "idempotency key %s was used with a different spec", key)
+			}
+
+			return previous.desc, 0, nil
+		}
+	}
+
+	if _, ok := a.applies.inProgress[req.GetName()]; ok {
+		return nil, 0, status.Errorf(codes.Aborted, "an apply of %s is already in progress", req.GetName())
+	}
+
+	existing := a.groupWorkloads(req.GetName())
+
+	// Workloads spawned by a recent apply may not have been broadcast yet
+	if applied, ok := a.applies.applied[req.GetName()]; ok && len(existing) == 0 && time.Since(applied.appliedAt) <= a.broadcastPeriod*3 {
+		for _, id := range applied.desc.GetIds() {
+			existing = append(existing, &pb.WorkloadState{Id: id, SourceRequest: &pb.VmSpawnRequest{SpecHash: applied.desc.GetSpecHash()}})
+		}
+	}
+
+	desc := &pb.WorkloadDescription{
+		Name:     req.GetName(),
+		Spec:     spec,
+		SpecHash: hash,
+		Url:      req.GetName() + "." + a.baseURL,
+	}
+
+	upToDate := len(existing) > 0
+	for _, workload := range existing {
+		if workload.GetSourceRequest().GetSpecHash() != hash {
+			upToDate = false
+		}
+
+		desc.Ids = append(desc.Ids, workload.GetId())
+	}
+
+	if upToDate {
+		if key := req.GetIdempotencyKey(); key != "" {
+			a.applies.keys[key] = idempotentApply{specHash: hash, desc: desc, appliedAt: time.Now()}
+		}
+
+		return desc, 0, nil
+	}
+
+	replicas := max(len(existing), int(spec.GetHorizontalScaling().GetMinReplicas()), 1)
+	if maxReplicas := int(spec.GetHorizontalScaling().GetMaxReplicas()); maxReplicas > 0 {
+		replicas = min(replicas, maxReplicas)
+	}
+
+	desc.Changed = true
+	a.applies.inProgress[req.GetName()] = struct{}{}
+
+	return desc, replicas, nil
+}
+
+// stopReplicas stops replicas of a workload, logging the ones that fail
+// to stop
+func (a *Agent) stopReplicas(name string, ids []string, reason string) {
+	for _, id := range ids {
+		if _, err := a.StopRequest(&pb.VmStopRequest{Id: id}); err != nil {
+			a.logger.WithError(err).Errorf("failed to stop %s replica %s of %s", reason, id, name)
+		}
+	}
+}
+
+// GetRequest returns the normalized spec and replicas of a named workload,
+// the spec reflects any resizing done by the autoscalers while spec_hash
+// stays the one it was applied with
+func (a *Agent) GetRequest(req *pb.GetRequest) (*pb.WorkloadDescription, error) {
+	workloads := a.groupWorkloads(req.GetName())
+
+	if len(workloads) == 0 {
+		a.applies.mu.Lock()
+		defer a.applies.mu.Unlock()
+
+		applied, ok := a.applies.applied[req.GetName()]
+		if ok && time.Since(applied.appliedAt) <= a.broadcastPeriod*3 {
+			return applied.desc, nil
+		}
+
+		return nil, status.Errorf(codes.NotFound, "workload %s not found", req.GetName())
+	}
+
+	desc := &pb.WorkloadDescription{
+		Name:     req.GetName(),
+		Spec:     normalizeSpec(workloads[0].GetSourceRequest()),
+		SpecHash: workloads[0].GetSourceRequest().GetSpecHash(),
+		Url:      req.GetName() + "." + a.baseURL,
+	}
+
+	for _, workload := range workloads {
+		desc.Ids = append(desc.Ids, workload.GetId())
+	}
+
+	return desc, nil
+}
+
+// forgetApplied drops the last apply result of a deleted workload
+func (a *Agent) forgetApplied(name string) {
+	a.applies.mu.Lock()
+	defer a.applies.mu.Unlock()
+
+	delete(a.applies.applied, name)
+}
+
+// groupWorkloads returns the known workloads of a replica group
+func (a *Agent) groupWorkloads(group string) []*pb.WorkloadState {
+	var workloads []*pb.WorkloadState
+
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if workload.GetSourceRequest().GetReplicaGroup() == group {
+				workloads = append(workloads, workload)
+			}
+		}
+	}
+
+	return workloads
+}
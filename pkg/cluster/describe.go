@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"vistara-node/pkg/models"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+)
+
+// DescribeWorkloadRequest returns the spec and the active firewall ruleset
+// of a workload, forwarding the request to the node running it
+func (a *Agent) DescribeWorkloadRequest(ctx context.Context, req *pb.DescribeWorkloadRequest) (*pb.DescribeWorkloadResponse, error) {
+	if req.GetId() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "ID of the workload is required")
+	}
+
+	ctrCtx := a.ctrRepo.GetContext(ctx)
+
+	container, err := a.ctrRepo.GetContainer(ctrCtx, req.GetId())
+	if err == nil {
+		return a.describeLocalWorkload(ctrCtx, container)
+	}
+
+	if !errdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get container %s: %w", req.GetId(), err)
+	}
+
+	nodeName := a.workloadNode(req.GetId())
+	if nodeName == "" {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
+
+	member := a.findMember(nodeName)
+	if member == nil || member.Tags[GrpcPortTag] == "" {
+		return nil, fmt.Errorf("node %s running workload %s is not reachable", nodeName, req.GetId())
+	}
+
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return pb.NewClusterServiceClient(conn).DescribeWorkload(forwardedContext(ctx), req)
+}
+
+func (a *Agent) describeLocalWorkload(ctx context.Context, container containerd.Container) (*pb.DescribeWorkloadResponse, error) {
+	id := container.ID()
+
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels for container %s: %w", id, err)
+	}
+
+	var spec pb.VmSpawnRequest
+	if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &spec); err != nil {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "container %s is not a cluster workload", id)
+	}
+
+	ruleset, err := a.ctrRepo.IngressRuleset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.DescribeWorkloadResponse{
+		Id:      id,
+		Node:    a.cfg.NodeName,
+		Spec:    &spec,
+		Ruleset: ruleset,
+	}, nil
+}
+
+// ingressRules converts the ingress rules of a spawn request to the ones
+// of the container
+func ingressRules(spec *pb.VmSpawnRequest) []models.IngressRule {
+	rules := make([]models.IngressRule, 0, len(spec.GetIngressRules()))
+	for _, rule := range spec.GetIngressRules() {
+		rules = append(rules, models.IngressRule{SourceCIDRs: rule.GetSourceCidrs(), Ports: rule.GetPorts()})
+	}
+
+	return rules
+}
+
+func validateIngressRules(rules []*pb.IngressRule) error {
+	for _, rule := range rules {
+		for _, cidr := range rule.GetSourceCidrs() {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "invalid ingress source CIDR %q", cidr)
+			}
+		}
+
+		for _, port := range rule.GetPorts() {
+			if port == 0 || port > 0xffff {
+				return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "got invalid ingress port %d", port)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package cluster
+
+import (
+	"vistara-node/pkg/models"
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// workloadDNS returns the resolver of a workload, the cluster DNS of the
+// node if its spawn request has none
+func (a *Agent) workloadDNS(spec *pb.VmSpawnRequest) *models.DNSConfig {
+	if dns := spec.GetDns(); dns != nil {
+		return dnsConfig(dns)
+	}
+
+	return a.clusterDNS
+}
+
+func dnsConfig(dns *pb.DNSConfig) *models.DNSConfig {
+	return &models.DNSConfig{
+		Nameservers: dns.GetNameservers(),
+		Searches:    dns.GetSearches(),
+		Ndots:       dns.GetNdots(),
+	}
+}
+
+func extraHosts(spec *pb.VmSpawnRequest) []models.HostEntry {
+	entries := make([]models.HostEntry, 0, len(spec.GetExtraHosts()))
+	for _, entry := range spec.GetExtraHosts() {
+		entries = append(entries, models.HostEntry{IP: entry.GetIp(), Hostnames: entry.GetHostnames()})
+	}
+
+	return entries
+}
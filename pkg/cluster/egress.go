@@ -0,0 +1,454 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
+	pb "vistara-node/pkg/proto/cluster"
+
+	ctask "github.com/containerd/containerd/api/types/task"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultEgressPolicyKey holds the policy of the tenants without
+	// one of their own in the egress policy file
+	DefaultEgressPolicyKey = "*"
+
+	// Time the workloads have to send the request or TLS client hello
+	// naming the host they connect to through the egress proxy
+	egressSniffTimeout = time.Second * 10
+	egressDialTimeout  = time.Second * 10
+	// Shortest interval between two refreshes of the addresses of the
+	// local workloads, done when a connection comes from an unknown one
+	workloadSourcesRefreshInterval = time.Second
+)
+
+var errClientHelloRead = errors.New("client hello read")
+
+// LoadEgressPolicies reads the egress policies of the tenants, a JSON
+// object keyed by tenant with DefaultEgressPolicyKey for the others
+func LoadEgressPolicies(path string) (map[string]*models.EgressPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read egress policies %s: %w", path, err)
+	}
+
+	var policies map[string]*models.EgressPolicy
+	if err := json.Unmarshal(contents, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse egress policies %s: %w", path, err)
+	}
+
+	for tenant, policy := range policies {
+		for _, rule := range policy.Allow {
+			for _, cidr := range rule.DestinationCIDRs {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return nil, fmt.Errorf("invalid destination CIDR %q in the egress policy of %q: %w", cidr, tenant, err)
+				}
+			}
+
+			for _, port := range rule.Ports {
+				if port == 0 || port > 0xffff {
+					return nil, fmt.Errorf("invalid port %d in the egress policy of %q", port, tenant)
+				}
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+// egressPolicy returns the egress policy of the tenant, nil if it is
+// unrestricted
+func (a *Agent) egressPolicy(tenant string) *models.EgressPolicy {
+	if policy, ok := a.egressPolicies[tenant]; ok {
+		return policy
+	}
+
+	return a.egressPolicies[DefaultEgressPolicyKey]
+}
+
+// workloadSource is a local workload connecting to the egress proxy or
+// the metadata service of the node
+type workloadSource struct {
+	id     string
+	tenant string
+	spec   *pb.VmSpawnRequest
+	// labels of its container
+	labels map[string]string
+}
+
+// workloadSourceCache tells the local workloads apart by the address
+// their connections come from
+type workloadSourceCache struct {
+	agent       *Agent
+	mu          sync.Mutex
+	sources     map[string]workloadSource
+	refreshedAt time.Time
+}
+
+func newWorkloadSourceCache(agent *Agent) *workloadSourceCache {
+	return &workloadSourceCache{agent: agent, sources: make(map[string]workloadSource)}
+}
+
+// egressCounters are the per-tenant counters of the egress proxy
+type egressCounters struct {
+	mu       sync.Mutex
+	proxied  map[string]uint64
+	rejected map[string]uint64
+}
+
+// egressProxy transparently proxies the HTTP and HTTPS connections of the
+// workloads whose egress policy redirects them, logging each connection
+// along with the host it was made to. The destination is taken from the
+// Host header or the TLS server name, and checked against the policy
+type egressProxy struct {
+	agent    *Agent
+	logger   *log.Logger
+	listener net.Listener
+	port     uint16
+
+	sources  *workloadSourceCache
+	counters egressCounters
+}
+
+func newEgressProxy(agent *Agent, addr string) (*egressProxy, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for egress proxy on %s: %w", addr, err)
+	}
+
+	return &egressProxy{
+		agent:    agent,
+		logger:   agent.logger,
+		listener: listener,
+		port:     uint16(listener.Addr().(*net.TCPAddr).Port),
+		sources:  newWorkloadSourceCache(agent),
+		counters: egressCounters{proxied: make(map[string]uint64), rejected: make(map[string]uint64)},
+	}, nil
+}
+
+func (p *egressProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			p.logger.WithError(err).Error("egress proxy stopped accepting connections")
+
+			return
+		}
+
+		go p.handle(conn)
+	}
+}
+
+func (p *egressProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	sourceIP := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+
+	source, ok := p.sources.source(sourceIP)
+	if !ok {
+		p.logger.Warnf("egress proxy rejected connection from %s, not a local workload", sourceIP)
+
+		return
+	}
+
+	logger := p.logger.WithFields(log.Fields{"workload": source.id, "tenant": source.tenant})
+
+	// Everything read while looking for the host is replayed upstream
+	var sniffed bytes.Buffer
+	reader := bufio.NewReader(io.TeeReader(conn, &sniffed))
+
+	_ = conn.SetReadDeadline(time.Now().Add(egressSniffTimeout))
+
+	host, port, err := sniffHost(reader)
+	if err != nil {
+		logger.WithError(err).Warn("egress proxy failed to read the destination of a connection")
+
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	logger = logger.WithField("host", host)
+
+	addr, err := p.resolve(host, port, p.agent.egressPolicy(source.tenant))
+	if err != nil {
+		p.counters.mu.Lock()
+		p.counters.rejected[source.tenant]++
+		p.counters.mu.Unlock()
+
+		logger.WithError(err).Warn("egress proxy rejected connection")
+		p.agent.alert(AlertPolicyViolation, source.id, "workload %s of tenant %q was denied egress: %s", source.id, source.tenant, err)
+
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", addr, egressDialTimeout)
+	if err != nil {
+		logger.WithError(err).Warnf("egress proxy failed to connect to %s", addr)
+
+		return
+	}
+	defer upstream.Close()
+
+	start := time.Now()
+	received := make(chan int64, 1)
+
+	go func() {
+		n, _ := io.Copy(conn, upstream)
+		_ = conn.(*net.TCPConn).CloseWrite()
+		received <- n
+	}()
+
+	sent, err := io.Copy(upstream, io.MultiReader(&sniffed, conn))
+	if err != nil {
+		logger.WithError(err).Debug("egress proxy connection failed")
+	}
+	_ = upstream.(*net.TCPConn).CloseWrite()
+
+	receivedBytes := <-received
+
+	p.counters.mu.Lock()
+	p.counters.proxied[source.tenant] += uint64(sent)
+	p.counters.mu.Unlock()
+
+	logger.WithFields(log.Fields{
+		"addr":     addr,
+		"sent":     sent,
+		"received": receivedBytes,
+		"duration": time.Since(start).Round(time.Millisecond),
+	}).Info("egress connection")
+}
+
+// sniffHost reads the host a connection is made to from its TLS client
+// hello or HTTP request, along with the port it was made to
+func sniffHost(reader *bufio.Reader) (string, string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", "", err
+	}
+
+	// TLS handshake record
+	if first[0] == 0x16 {
+		var serverName string
+
+		err := tls.Server(sniffConn{reader: reader}, &tls.Config{
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				serverName = hello.ServerName
+
+				return nil, errClientHelloRead
+			},
+		}).Handshake()
+		if serverName == "" {
+			return "", "", fmt.Errorf("no server name in TLS client hello: %w", err)
+		}
+
+		return serverName, "443", nil
+	}
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read HTTP request: %w", err)
+	}
+
+	host := req.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	if host == "" {
+		return "", "", errors.New("no host in HTTP request")
+	}
+
+	return host, "80", nil
+}
+
+// sniffConn feeds the TLS client hello to a TLS server, which never gets
+// to write anything back
+type sniffConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c sniffConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c sniffConn) Write(_ []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// resolve returns the first address of the host the policy allows
+// connecting to
+func (p *egressProxy) resolve(host, port string, policy *models.EgressPolicy) (string, error) {
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), egressDialTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		if network.EgressAllowed(policy, addr.IP, uint32(portNumber)) {
+			return net.JoinHostPort(addr.IP.String(), port), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s:%s is not allowed by the egress policy", host, port)
+}
+
+// source returns the local workload with the address, refreshing the
+// addresses of the local workloads if it isn't known yet
+func (c *workloadSourceCache) source(ip string) (workloadSource, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if source, ok := c.sources[ip]; ok {
+		return source, true
+	}
+
+	if time.Since(c.refreshedAt) < workloadSourcesRefreshInterval {
+		return workloadSource{}, false
+	}
+
+	c.refreshedAt = time.Now()
+
+	sources, err := c.agent.workloadSources()
+	if err != nil {
+		c.agent.logger.WithError(err).Error("failed to get the addresses of the local workloads")
+
+		return workloadSource{}, false
+	}
+
+	c.sources = sources
+	source, ok := c.sources[ip]
+
+	return source, ok
+}
+
+// workloadSources returns the local workloads keyed by address
+func (a *Agent) workloadSources() (map[string]workloadSource, error) {
+	ctx := a.ctrRepo.GetContext(context.Background())
+
+	tasks, err := a.ctrRepo.GetTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	sources := make(map[string]workloadSource)
+
+	for _, task := range tasks {
+		if task.GetStatus() != ctask.Status_RUNNING {
+			continue
+		}
+
+		container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+		if err != nil {
+			continue
+		}
+
+		labels, err := container.Labels(ctx)
+		if err != nil {
+			continue
+		}
+
+		var labelPayload pb.VmSpawnRequest
+		if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
+			continue
+		}
+
+		ip, err := a.ctrRepo.GetContainerPrimaryIP(ctx, task.GetID())
+		if err != nil {
+			continue
+		}
+
+		sources[ip] = workloadSource{id: task.GetID(), tenant: labelPayload.GetTenant(), spec: &labelPayload, labels: labels}
+	}
+
+	return sources, nil
+}
+
+type tenantCounter struct {
+	name   string
+	help   string
+	values map[string]uint64
+}
+
+// tenantCounters returns the per-tenant egress counters of this node
+func (a *Agent) tenantCounters() []tenantCounter {
+	counters := []tenantCounter{
+		{"hypercore_tenant_egress_bytes_total", "Bytes sent by the workloads of the tenant on this node", a.billing.egressByTenant()},
+	}
+
+	if a.egressProxy != nil {
+		a.egressProxy.counters.mu.Lock()
+		proxied := maps.Clone(a.egressProxy.counters.proxied)
+		rejected := maps.Clone(a.egressProxy.counters.rejected)
+		a.egressProxy.counters.mu.Unlock()
+
+		counters = append(counters,
+			tenantCounter{"hypercore_tenant_egress_proxied_bytes_total", "Bytes sent by the workloads of the tenant through the egress proxy of this node", proxied},
+			tenantCounter{"hypercore_tenant_egress_rejected_total", "Connections of the workloads of the tenant rejected by the egress proxy of this node", rejected},
+		)
+	}
+
+	return counters
+}
+
+// WritePrometheusMetrics writes the per-tenant egress counters of this
+// node in the Prometheus text format, followed by the metrics of the
+// local workloads if they are scraped
+func (a *Agent) WritePrometheusMetrics(ctx context.Context, w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	for _, counter := range a.tenantCounters() {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+
+		for _, tenant := range slices.Sorted(maps.Keys(counter.values)) {
+			fmt.Fprintf(buf, "%s{node=%q,tenant=%q} %d\n", counter.name, a.cfg.NodeName, tenant, counter.values[tenant])
+		}
+	}
+
+	a.serviceProxy.writeMetrics(buf, a.cfg.NodeName)
+
+	if a.traffic != nil {
+		a.traffic.write(buf, a.cfg.NodeName)
+	}
+
+	if err := writeBootMetrics(buf, a.cfg.NodeName); err != nil {
+		a.logger.WithError(err).Warn("failed to read the boot times of the VMs")
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	if a.workloadMetrics != nil {
+		return a.workloadMetrics.write(ctx, w, a.logger)
+	}
+
+	return nil
+}
@@ -0,0 +1,397 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultEndpointZone is the DNS zone of the zone file if none is set
+	DefaultEndpointZone = "hypercore.local"
+
+	// Registrations of a reconcile are published together
+	endpointPublishDelay = time.Second
+	// Failed publications are retried after the delay
+	endpointPublishRetry = time.Second * 10
+	endpointZoneTTL      = 5
+	haproxyTimeout       = time.Second * 5
+)
+
+// EndpointPublishConfig is where the agent publishes the backends of the
+// services whenever they change, so load balancers outside the cluster
+// can route to them
+type EndpointPublishConfig struct {
+	// Zone file written with the records of the backends, for the file
+	// plugin of CoreDNS, none if empty
+	ZoneFile string
+	// Origin of the zone file, DefaultEndpointZone if empty
+	Zone string
+	// Address of the HAProxy runtime API, a host:port or the path of a
+	// unix socket, none if empty
+	HAProxyRuntimeAPI string
+	// Endpoints receiving the backends as JSON
+	Webhooks []string
+}
+
+func (c *EndpointPublishConfig) enabled() bool {
+	return c != nil && (c.ZoneFile != "" || c.HAProxyRuntimeAPI != "" || len(c.Webhooks) > 0)
+}
+
+// ServiceEndpoints are the backends of a service at one of its host ports
+type ServiceEndpoints struct {
+	Service  string     `json:"service"`
+	Port     uint32     `json:"port"`
+	Backends []Endpoint `json:"backends"`
+}
+
+// Endpoint is a ready backend of a service, a workload of this node at
+// its own address or one of another node at the address of that node
+type Endpoint struct {
+	Backend string `json:"backend"`
+	Host    string `json:"host"`
+	Port    uint32 `json:"port"`
+}
+
+// EndpointsUpdate is the payload posted to the webhooks, with all the
+// endpoints known to the node
+type EndpointsUpdate struct {
+	Node      string             `json:"node"`
+	Time      time.Time          `json:"time"`
+	Endpoints []ServiceEndpoints `json:"endpoints"`
+}
+
+func (s *ServiceProxy) notifyChange() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Endpoints returns the backends of the services, sorted by service,
+// port and backend
+func (s *ServiceProxy) Endpoints() []ServiceEndpoints {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var endpoints []ServiceEndpoints
+
+	for serviceID, ports := range s.serviceIDPortMaps {
+		for hostPort, backends := range ports {
+			service := ServiceEndpoints{Service: serviceID, Port: hostPort}
+
+			for backendID, backendURL := range backends {
+				parsed, err := url.Parse(backendURL)
+				if err != nil {
+					continue
+				}
+
+				port, err := strconv.ParseUint(parsed.Port(), 10, 16)
+				if err != nil {
+					continue
+				}
+
+				service.Backends = append(service.Backends, Endpoint{Backend: backendID, Host: parsed.Hostname(), Port: uint32(port)})
+			}
+
+			slices.SortFunc(service.Backends, func(a, b Endpoint) int { return strings.Compare(a.Backend, b.Backend) })
+			endpoints = append(endpoints, service)
+		}
+	}
+
+	slices.SortFunc(endpoints, func(a, b ServiceEndpoints) int {
+		if c := strings.Compare(a.Service, b.Service); c != 0 {
+			return c
+		}
+
+		return int(a.Port) - int(b.Port)
+	})
+
+	return endpoints
+}
+
+// endpointPublisher publishes the endpoints of the proxy in the
+// background each time its registrations change
+type endpointPublisher struct {
+	cfg    *EndpointPublishConfig
+	node   string
+	logger *log.Logger
+	proxy  *ServiceProxy
+	client *http.Client
+
+	last   []ServiceEndpoints
+	serial uint32
+}
+
+func newEndpointPublisher(logger *log.Logger, cfg *EndpointPublishConfig, node string, proxy *ServiceProxy) *endpointPublisher {
+	if cfg.Zone == "" {
+		cfg.Zone = DefaultEndpointZone
+	}
+
+	publisher := &endpointPublisher{
+		cfg:    cfg,
+		node:   node,
+		logger: logger,
+		proxy:  proxy,
+		client: &http.Client{Timeout: alertSendTimeout},
+	}
+
+	// The zone file is written even if no workload is registered
+	proxy.notifyChange()
+
+	go publisher.run()
+
+	return publisher
+}
+
+func (p *endpointPublisher) run() {
+	for range p.proxy.changed {
+		time.Sleep(endpointPublishDelay)
+
+		endpoints := p.proxy.Endpoints()
+		if p.last != nil && reflect.DeepEqual(endpoints, p.last) {
+			continue
+		}
+
+		if err := p.publish(endpoints); err != nil {
+			p.logger.WithError(err).Errorf("failed to publish endpoints, retrying in %s", endpointPublishRetry)
+			time.AfterFunc(endpointPublishRetry, p.proxy.notifyChange)
+
+			continue
+		}
+
+		p.last = endpoints
+	}
+}
+
+// publish sends the endpoints to all the configured systems, returning
+// the errors of those that failed
+func (p *endpointPublisher) publish(endpoints []ServiceEndpoints) error {
+	var errs []error
+
+	if p.cfg.ZoneFile != "" {
+		if err := p.writeZoneFile(endpoints); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write zone file %s: %w", p.cfg.ZoneFile, err))
+		}
+	}
+
+	if p.cfg.HAProxyRuntimeAPI != "" {
+		if err := p.updateHAProxy(endpoints); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update HAProxy at %s: %w", p.cfg.HAProxyRuntimeAPI, err))
+		}
+	}
+
+	if len(p.cfg.Webhooks) > 0 {
+		body, err := json.Marshal(EndpointsUpdate{Node: p.node, Time: time.Now(), Endpoints: endpoints})
+		if err != nil {
+			return fmt.Errorf("failed to marshal endpoints: %w", err)
+		}
+
+		for _, webhook := range p.cfg.Webhooks {
+			ctx, cancel := context.WithTimeout(context.Background(), alertSendTimeout)
+			err := postJSON(ctx, p.client, webhook, body)
+			cancel()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to post endpoints to webhook %s: %w", webhook, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeZoneFile replaces the zone file with the records of the endpoints:
+// an A/AAAA record per backend at BACKEND.SERVICE, the addresses of all
+// the backends of a service at SERVICE, and a SRV record per backend at
+// _PORT._tcp.SERVICE for each host port of the service
+func (p *endpointPublisher) writeZoneFile(endpoints []ServiceEndpoints) error {
+	origin := strings.TrimSuffix(p.cfg.Zone, ".") + "."
+
+	// CoreDNS reloads the zone when its serial increases
+	p.serial = max(p.serial+1, uint32(time.Now().Unix()))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "$ORIGIN %s\n$TTL %d\n", origin, endpointZoneTTL)
+	fmt.Fprintf(&b, "@ IN SOA ns.%s hostmaster.%s %d 60 60 3600 %d\n", origin, origin, p.serial, endpointZoneTTL)
+
+	addresses := make(map[string]struct{})
+
+	for _, service := range endpoints {
+		name := strings.ToLower(service.Service)
+
+		for _, backend := range service.Backends {
+			backendName := strings.ToLower(backend.Backend) + "." + name
+
+			if _, ok := addresses[backendName]; !ok {
+				addresses[backendName] = struct{}{}
+				fmt.Fprintf(&b, "%s IN %s %s\n", backendName, addressRecordType(backend.Host), backend.Host)
+			}
+
+			if _, ok := addresses[name+"/"+backend.Host]; !ok {
+				addresses[name+"/"+backend.Host] = struct{}{}
+				fmt.Fprintf(&b, "%s IN %s %s\n", name, addressRecordType(backend.Host), backend.Host)
+			}
+
+			fmt.Fprintf(&b, "_%d._tcp.%s IN SRV 10 10 %d %s\n", service.Port, name, backend.Port, backendName)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.cfg.ZoneFile), filepath.Base(p.cfg.ZoneFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p.cfg.ZoneFile)
+}
+
+func addressRecordType(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
+
+// updateHAProxy points the servers of the HAProxy backend named
+// SERVICE_PORT, e.g. declared with server-template, at the endpoints of
+// the service, putting the servers left in maintenance. Services without
+// a backend in HAProxy are skipped
+func (p *endpointPublisher) updateHAProxy(endpoints []ServiceEndpoints) error {
+	var lastErr error
+
+	// All the servers of the services removed since the last
+	// publication go in maintenance
+	for _, previous := range p.last {
+		if !slices.ContainsFunc(endpoints, func(service ServiceEndpoints) bool {
+			return service.Service == previous.Service && service.Port == previous.Port
+		}) {
+			endpoints = append(endpoints, ServiceEndpoints{Service: previous.Service, Port: previous.Port})
+		}
+	}
+
+	for _, service := range endpoints {
+		backend := fmt.Sprintf("%s_%d", service.Service, service.Port)
+
+		servers, err := p.haproxyServers(backend)
+		if err != nil {
+			p.logger.WithError(err).Debugf("skipping HAProxy backend %s", backend)
+
+			continue
+		}
+
+		if len(servers) < len(service.Backends) {
+			p.logger.Warnf("HAProxy backend %s has %d servers for the %d endpoints of service %s", backend, len(servers), len(service.Backends), service.Service)
+		}
+
+		for i, server := range servers {
+			commands := []string{fmt.Sprintf("set server %s/%s state maint", backend, server)}
+			if i < len(service.Backends) {
+				endpoint := service.Backends[i]
+				commands = []string{
+					fmt.Sprintf("set server %s/%s addr %s port %d", backend, server, endpoint.Host, endpoint.Port),
+					fmt.Sprintf("set server %s/%s state ready", backend, server),
+				}
+			}
+
+			for _, command := range commands {
+				if _, err := p.haproxyCommand(command); err != nil {
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// haproxyServers returns the servers of a HAProxy backend in the order
+// they were declared
+func (p *endpointPublisher) haproxyServers(backend string) ([]string, error) {
+	output, err := p.haproxyCommand("show servers state " + backend)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []string
+
+	// A version line, a header line and a line per server:
+	// be_id be_name srv_id srv_name srv_addr ...
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") || fields[1] != backend {
+			continue
+		}
+
+		servers = append(servers, fields[3])
+	}
+
+	return servers, nil
+}
+
+// haproxyCommand runs a command of the HAProxy runtime API, over a
+// connection of its own as HAProxy closes it after answering
+func (p *endpointPublisher) haproxyCommand(command string) (string, error) {
+	network := "tcp"
+	if strings.HasPrefix(p.cfg.HAProxyRuntimeAPI, "/") {
+		network = "unix"
+	}
+
+	conn, err := net.DialTimeout(network, p.cfg.HAProxyRuntimeAPI, haproxyTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(haproxyTimeout))
+
+	if _, err := io.WriteString(conn, command+"\n"); err != nil {
+		return "", err
+	}
+
+	output, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+
+	response := strings.TrimSpace(string(output))
+	for _, failure := range []string{"No such", "Can't find", "Unknown command", "Require"} {
+		if strings.Contains(response, failure) {
+			return "", fmt.Errorf("command %q failed: %s", command, response)
+		}
+	}
+
+	return response, nil
+}
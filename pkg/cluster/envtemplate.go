@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// WorkloadEnvData is what the Go templates in the environment values of
+// a spawn request are expanded with by the node creating the workload,
+// e.g. ADVERTISED_URL=https://{{.WorkloadURL}}
+type WorkloadEnvData struct {
+	WorkloadID   string
+	WorkloadURL  string
+	Name         string
+	ReplicaGroup string
+	Tenant       string
+	Revision     uint32
+	NodeName     string
+	NodeIP       string
+	// Domain the workload URLs are under
+	ClusterBaseURL string
+}
+
+func (a *Agent) workloadEnvData(id string, req *pb.VmSpawnRequest) WorkloadEnvData {
+	return WorkloadEnvData{
+		WorkloadID:     id,
+		WorkloadURL:    id + "." + a.baseURL,
+		Name:           req.GetName(),
+		ReplicaGroup:   req.GetReplicaGroup(),
+		Tenant:         req.GetTenant(),
+		Revision:       req.GetRevision(),
+		NodeName:       a.cfg.NodeName,
+		NodeIP:         a.serf.LocalMember().Addr.String(),
+		ClusterBaseURL: a.baseURL,
+	}
+}
+
+// parseEnvTemplate parses an environment value, nil if it has no
+// template actions
+func parseEnvTemplate(name, value string) (*template.Template, error) {
+	if !strings.Contains(value, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown fields are only reported when executing the template
+	if err := tmpl.Execute(io.Discard, WorkloadEnvData{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// expandWorkloadEnv returns the environment of a workload with the
+// templates of its values expanded
+func expandWorkloadEnv(env map[string]string, data WorkloadEnvData) (map[string]string, error) {
+	expanded := make(map[string]string, len(env))
+
+	for name, value := range env {
+		tmpl, err := parseEnvTemplate(name, value)
+		if err != nil {
+			return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"env[" + name + "]": err.Error()},
+				"invalid template in environment variable %s: %v", name, err)
+		}
+
+		if tmpl == nil {
+			expanded[name] = value
+
+			continue
+		}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to expand environment variable %s: %w", name, err)
+		}
+
+		expanded[name] = b.String()
+	}
+
+	return expanded, nil
+}
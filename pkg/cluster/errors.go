@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// ClusterError is an error whose code and details are kept when it is
+// sent between nodes in an ErrorResponse, and mapped to a gRPC status
+//
+//nolint:revive
+type ClusterError struct {
+	Code    pb.ErrorCode
+	Message string
+	Details map[string]string
+}
+
+func newClusterError(code pb.ErrorCode, details map[string]string, format string, args ...interface{}) *ClusterError {
+	return &ClusterError{Code: code, Message: fmt.Sprintf(format, args...), Details: details}
+}
+
+func (e *ClusterError) Error() string {
+	return e.Message
+}
+
+// GRPCStatus returns the status the server responds with, the error is
+// attached as an ErrorResponse detail. The details of invalid requests
+// are the invalid fields, also attached as BadRequest field violations
+func (e *ClusterError) GRPCStatus() *status.Status {
+	st := status.New(grpcCode(e.Code), e.Message)
+
+	details := []protoadapt.MessageV1{e.response()}
+
+	if e.Code == pb.ErrorCode_INVALID_REQUEST && len(e.Details) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range slices.Sorted(maps.Keys(e.Details)) {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: e.Details[field],
+			})
+		}
+
+		details = append(details, badRequest)
+	}
+
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+
+	return st
+}
+
+func (e *ClusterError) response() *pb.ErrorResponse {
+	return &pb.ErrorResponse{Error: e.Message, Code: e.Code, Details: e.Details}
+}
+
+// grpcCode maps a cluster error code to the gRPC status code
+func grpcCode(code pb.ErrorCode) codes.Code {
+	switch code {
+	case pb.ErrorCode_CAPACITY_EXCEEDED, pb.ErrorCode_RATE_LIMITED:
+		return codes.ResourceExhausted
+	case pb.ErrorCode_IMAGE_PULL_FAILED:
+		return codes.FailedPrecondition
+	case pb.ErrorCode_POLICY_DENIED:
+		return codes.PermissionDenied
+	case pb.ErrorCode_NOT_FOUND:
+		return codes.NotFound
+	case pb.ErrorCode_TIMEOUT:
+		return codes.DeadlineExceeded
+	case pb.ErrorCode_INVALID_REQUEST:
+		return codes.InvalidArgument
+	case pb.ErrorCode_ALREADY_EXISTS:
+		return codes.AlreadyExists
+	case pb.ErrorCode_VOLUME_UNAVAILABLE:
+		return codes.Unavailable
+	case pb.ErrorCode_UNKNOWN_ERROR:
+		fallthrough
+	default:
+		return codes.Unknown
+	}
+}
+
+// errorCode returns the code of the cluster error wrapped by err, image
+// pull failures of the containerd repo are reported as such
+func errorCode(err error) pb.ErrorCode {
+	var clusterErr *ClusterError
+	if errors.As(err, &clusterErr) {
+		return clusterErr.Code
+	}
+
+	var pullErr *vcontainerd.ImagePullError
+	if errors.As(err, &pullErr) {
+		return pb.ErrorCode_IMAGE_PULL_FAILED
+	}
+
+	return pb.ErrorCode_UNKNOWN_ERROR
+}
+
+// errorResponse converts err to the response sent to other nodes
+func errorResponse(err error) *pb.ErrorResponse {
+	resp := &pb.ErrorResponse{Error: err.Error(), Code: errorCode(err)}
+
+	var clusterErr *ClusterError
+	if errors.As(err, &clusterErr) {
+		resp.Details = clusterErr.Details
+	}
+
+	return resp
+}
+
+// capacityDetails describes a resource a node doesn't have enough of
+func capacityDetails(resource string, capacity, used, requested int) map[string]string {
+	return map[string]string{
+		"resource":  resource,
+		"capacity":  strconv.Itoa(capacity),
+		"used":      strconv.Itoa(used),
+		"requested": strconv.Itoa(requested),
+	}
+}
+
+// nodeError converts the error response of a node back to an error
+func nodeError(node string, resp *pb.ErrorResponse) *ClusterError {
+	return newClusterError(resp.GetCode(), resp.GetDetails(), "node %s returned failure response: %s", node, resp.GetError())
+}
@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// Number of events buffered per subscriber, events are dropped
+// for subscribers that fall further behind
+const eventBufferSize = 64
+
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan *pb.WatchEventsResponse]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan *pb.WatchEventsResponse]struct{}),
+	}
+}
+
+// SubscribeEvents returns the cluster events observed by this node
+// until the returned cancel function is called
+func (a *Agent) SubscribeEvents() (<-chan *pb.WatchEventsResponse, func()) {
+	ch := make(chan *pb.WatchEventsResponse, eventBufferSize)
+
+	a.events.mu.Lock()
+	a.events.subscribers[ch] = struct{}{}
+	a.events.mu.Unlock()
+
+	return ch, func() {
+		a.events.mu.Lock()
+		defer a.events.mu.Unlock()
+
+		if _, ok := a.events.subscribers[ch]; ok {
+			delete(a.events.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (a *Agent) publishEvent(event *pb.WatchEventsResponse) {
+	event.UnixTime = time.Now().Unix()
+
+	a.events.mu.Lock()
+	defer a.events.mu.Unlock()
+
+	for ch := range a.events.subscribers {
+		select {
+		case ch <- event:
+		default:
+			a.logger.Warnf("dropping %s event for slow subscriber", event.GetEvent())
+		}
+	}
+}
+
+// publishWorkloadChanges emits spawn/stop events for the differences
+// between two consecutive state updates of a node
+func (a *Agent) publishWorkloadChanges(previous, current *pb.NodeStateResponse) {
+	before := make(map[string]struct{})
+	for _, workload := range previous.GetWorkloads() {
+		before[workload.GetId()] = struct{}{}
+	}
+
+	after := make(map[string]struct{})
+	for _, workload := range current.GetWorkloads() {
+		after[workload.GetId()] = struct{}{}
+
+		if _, ok := before[workload.GetId()]; !ok {
+			a.publishEvent(workloadEvent(pb.ClusterEvent_SPAWN, current.GetNode(), workload, "workload is running"))
+		}
+	}
+
+	for _, workload := range previous.GetWorkloads() {
+		if _, ok := after[workload.GetId()]; !ok {
+			a.publishEvent(workloadEvent(pb.ClusterEvent_STOP, current.GetNode(), workload, "workload is no longer running"))
+		}
+	}
+}
+
+func workloadEvent(event pb.ClusterEvent, node *pb.Node, workload *pb.WorkloadState, message string) *pb.WatchEventsResponse {
+	return &pb.WatchEventsResponse{
+		Event:        event,
+		Node:         node,
+		Id:           workload.GetId(),
+		Message:      message,
+		ReplicaGroup: workload.GetSourceRequest().GetReplicaGroup(),
+	}
+}
+
+// reportPullProgress tells the watchers of a workload about the progress
+// of the pull of its image while it is being spawned
+func (a *Agent) reportPullProgress(id, replicaGroup string, progress vcontainerd.PullProgress) {
+	message := fmt.Sprintf("pulling image %s: %.1f MiB downloaded in %s", progress.Ref, float64(progress.Bytes)/(1024*1024), progress.Elapsed.Round(time.Second))
+	if progress.Waiters > 1 {
+		message += fmt.Sprintf(", shared with %d other spawns", progress.Waiters-1)
+	}
+
+	a.logger.Infof("Workload %s is %s", id, message)
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_IMAGE_PULL,
+		Node:         &pb.Node{Id: a.cfg.NodeName},
+		Id:           id,
+		Message:      message,
+		ReplicaGroup: replicaGroup,
+	})
+}
+
+// eventMatches reports whether an event concerns the given
+// workload or replica group, an empty ID matches all events
+func eventMatches(event *pb.WatchEventsResponse, id string) bool {
+	return id == "" || event.GetId() == id || event.GetReplicaGroup() == id
+}
@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+// evaluateNode checks the request against the last state broadcast by the
+// node, a nil state is assumed to fit as the node may still have capacity
+func evaluateNode(node string, state *pb.NodeStateResponse, req *pb.VmSpawnRequest) *pb.CandidateNode {
+	candidate := &pb.CandidateNode{Node: &pb.Node{Id: node}, Eligible: true}
+
+	check := func(name string, passed bool, format string, args ...interface{}) {
+		candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{Name: name, Passed: passed, Message: fmt.Sprintf(format, args...)})
+		candidate.Eligible = candidate.Eligible && passed
+	}
+
+	if state == nil {
+		check("state", true, "no recent state broadcast, capacity unknown")
+	} else {
+		cpusUsed, memoryUsed := 0, 0
+		for _, workload := range state.GetWorkloads() {
+			cpusUsed += int(workload.GetSourceRequest().GetCores())
+			memoryUsed += int(workload.GetSourceRequest().GetMemory())
+		}
+
+		check("vcpu", cpusUsed+int(req.GetCores()) <= int(state.GetCpus()),
+			"%d/%d vCPUs in use, requested %d", cpusUsed, state.GetCpus(), req.GetCores())
+		check("memory", memoryUsed+int(req.GetMemory()) <= int(state.GetMemory()),
+			"%d/%d MB in use, requested %d", memoryUsed, state.GetMemory(), req.GetMemory())
+		check("disk", !lowOnDisk(state), "%d MB free, at least %d MB needed to pull images", state.GetHost().GetDiskFree(), minDiskFree)
+	}
+
+	pressure, breakdown := hostPressureBreakdown(state)
+	candidate.Score = 1 - pressure
+	candidate.ScoreBreakdown = breakdown
+
+	return candidate
+}
+
+// candidateLess orders eligible nodes first, from the least to the most
+// loaded
+func candidateLess(left, right *pb.CandidateNode) bool {
+	if left.GetEligible() != right.GetEligible() {
+		return left.GetEligible()
+	}
+
+	return left.GetScore() > right.GetScore()
+}
+
+// rankNodes sorts the nodes that answered the dry run of a request in the
+// order the spawn is tried on them
+func (a *Agent) rankNodes(nodes []string, req *pb.VmSpawnRequest) {
+	states := make(map[string]*pb.NodeStateResponse)
+	for _, state := range a.knownStates() {
+		states[state.GetNode().GetId()] = state
+	}
+
+	candidates := make(map[string]*pb.CandidateNode, len(nodes))
+	for _, node := range nodes {
+		candidates[node] = evaluateNode(node, states[node], req)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return candidateLess(candidates[nodes[i]], candidates[nodes[j]])
+	})
+}
+
+// ExplainRequest runs node selection for the request against the last
+// state broadcast by each node, without sending any query. Spawns queued
+// on a node since its last broadcast aren't accounted for
+func (a *Agent) ExplainRequest(req *pb.VmSpawnRequest) (*pb.ExplainResponse, error) {
+	if err := validateSpawnRequest(req); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ExplainResponse{}
+
+	if key := req.GetIdempotencyKey(); key != "" {
+		resp.Existing = a.knownSpawn(key)
+	}
+
+	states := make(map[string]*pb.NodeStateResponse)
+	for _, state := range a.knownStates() {
+		states[state.GetNode().GetId()] = state
+	}
+
+	volumeNode, err := a.volumeNode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, member := range a.serf.Members() {
+		if member.Status != serf.StatusAlive {
+			continue
+		}
+
+		candidate := evaluateNode(member.Name, states[member.Name], req)
+
+		if status := memberNodeStatus(member); status != pb.NodeStatus_NODE_READY {
+			candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{
+				Name:    "status",
+				Message: fmt.Sprintf("node is %s, workloads are only placed on ready nodes", status),
+			})
+			candidate.Eligible = false
+		} else if a.enteringMaintenance(member, now) {
+			start, end := memberMaintenance(member)
+			candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{
+				Name:    "maintenance",
+				Message: fmt.Sprintf("node is in maintenance from %s to %s", time.Unix(start, 0).UTC().Format(time.RFC3339), time.Unix(end, 0).UTC().Format(time.RFC3339)),
+			})
+			candidate.Eligible = false
+		}
+
+		if missing := a.missingCapabilities(member.Name, req); len(missing) > 0 {
+			candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{
+				Name:    "capabilities",
+				Message: "node doesn't support " + strings.Join(missing, ", "),
+			})
+			candidate.Eligible = false
+		}
+
+		if volumeNode != "" && member.Name != volumeNode {
+			candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{
+				Name:    "volume",
+				Message: fmt.Sprintf("the volumes of the workload are on node %s", volumeNode),
+			})
+			candidate.Eligible = false
+		}
+
+		if member.Name == a.cfg.NodeName {
+			candidate.Constraints = append(candidate.Constraints, &pb.ConstraintResult{
+				Name:    "remote",
+				Message: "spawns received by a node are placed on the other nodes",
+			})
+			candidate.Eligible = false
+		}
+
+		resp.Candidates = append(resp.Candidates, candidate)
+	}
+
+	sort.Slice(resp.GetCandidates(), func(i, j int) bool {
+		left, right := resp.GetCandidates()[i], resp.GetCandidates()[j]
+		if candidateLess(left, right) || candidateLess(right, left) {
+			return candidateLess(left, right)
+		}
+
+		return left.GetNode().GetId() < right.GetNode().GetId()
+	})
+
+	for i, candidate := range resp.GetCandidates() {
+		if !candidate.GetEligible() {
+			break
+		}
+
+		candidate.Rank = uint32(i + 1)
+	}
+
+	if len(resp.GetCandidates()) > 0 && resp.GetCandidates()[0].GetEligible() {
+		resp.SelectedNode = resp.GetCandidates()[0].GetNode().GetId()
+	}
+
+	return resp, nil
+}
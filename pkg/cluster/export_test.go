@@ -0,0 +1,31 @@
+package cluster
+
+import (
+	"net"
+	"strconv"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// NodeName returns the name the agent joined the cluster under
+func (a *Agent) NodeName() string {
+	return a.cfg.NodeName
+}
+
+// GossipAddr returns the address the other agents join this one at
+func (a *Agent) GossipAddr() string {
+	member := a.serf.LocalMember()
+
+	return net.JoinHostPort(member.Addr.String(), strconv.Itoa(int(member.Port)))
+}
+
+// Crash stops gossiping without leaving the cluster, like an agent whose
+// host went down
+func (a *Agent) Crash() error {
+	return a.serf.Shutdown()
+}
+
+// KnownStates returns the states of the nodes as seen by the agent
+func (a *Agent) KnownStates() []*pb.NodeStateResponse {
+	return a.knownStates()
+}
@@ -0,0 +1,332 @@
+package cluster_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"vistara-node/pkg/cluster"
+	vcontainerd "vistara-node/pkg/containerd"
+	"vistara-node/pkg/network"
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/proto/shimdebug"
+
+	"github.com/containerd/containerd"
+	ctask "github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/filters"
+	"github.com/containerd/containerd/oci"
+)
+
+// fakeRepo keeps the containers of a simulated node in memory, their
+// tasks running until they are deleted
+type fakeRepo struct {
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	nextIP     int
+	// returned by AdoptContainer when set
+	adoptErr error
+}
+
+var _ cluster.ContainerRepo = (*fakeRepo)(nil)
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{containers: make(map[string]*fakeContainer)}
+}
+
+// workloads returns the spawn requests of the containers, by ID
+func (r *fakeRepo) workloads() map[string]*pb.VmSpawnRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workloads := make(map[string]*pb.VmSpawnRequest, len(r.containers))
+
+	for id, container := range r.containers {
+		var req pb.VmSpawnRequest
+		if err := json.Unmarshal([]byte(container.labels[cluster.SpawnRequestLabel]), &req); err == nil {
+			workloads[id] = &req
+		}
+	}
+
+	return workloads
+}
+
+// addContainer adds a running container hypercore didn't create, with
+// the image and OCI spec it runs with
+func (r *fakeRepo) addContainer(id, image string, spec *oci.Spec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextIP++
+	r.containers[id] = &fakeContainer{
+		repo:   r,
+		id:     id,
+		image:  image,
+		spec:   spec,
+		labels: make(map[string]string),
+		ip:     fmt.Sprintf("10.0.%d.%d", r.nextIP/256, r.nextIP%256),
+	}
+}
+
+func (r *fakeRepo) setAdoptErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.adoptErr = err
+}
+
+// ids returns the sorted IDs of the workloads, leaving out the
+// containers the agent doesn't manage
+func (r *fakeRepo) ids() []string {
+	return slices.Sorted(maps.Keys(r.workloads()))
+}
+
+func (r *fakeRepo) GetContext(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (r *fakeRepo) GetTasks(_ context.Context) ([]*ctask.Process, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tasks := make([]*ctask.Process, 0, len(r.containers))
+	for _, id := range slices.Sorted(maps.Keys(r.containers)) {
+		tasks = append(tasks, &ctask.Process{ID: id, Status: ctask.Status_RUNNING})
+	}
+
+	return tasks, nil
+}
+
+func (r *fakeRepo) GetContainer(_ context.Context, id string) (containerd.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	container, ok := r.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("container %s: %w", id, errdefs.ErrNotFound)
+	}
+
+	return container, nil
+}
+
+func (r *fakeRepo) ListContainers(_ context.Context, filterStrings ...string) ([]containerd.Container, error) {
+	filter, err := filters.ParseAll(filterStrings...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matching []containerd.Container
+
+	for _, id := range slices.Sorted(maps.Keys(r.containers)) {
+		container := r.containers[id]
+
+		if filter.Match(filters.AdapterFunc(container.field)) {
+			matching = append(matching, container)
+		}
+	}
+
+	return matching, nil
+}
+
+func (r *fakeRepo) GetContainerPrimaryIP(_ context.Context, containerID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	container, ok := r.containers[containerID]
+	if !ok {
+		return "", fmt.Errorf("container %s: %w", containerID, errdefs.ErrNotFound)
+	}
+
+	return container.ip, nil
+}
+
+func (r *fakeRepo) CreateContainer(_ context.Context, opts vcontainerd.CreateContainerOpts) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.containers[opts.ID]; ok {
+		return "", fmt.Errorf("container %s: %w", opts.ID, errdefs.ErrAlreadyExists)
+	}
+
+	r.nextIP++
+	r.containers[opts.ID] = &fakeContainer{
+		repo:   r,
+		id:     opts.ID,
+		image:  opts.ImageRef,
+		spec:   &oci.Spec{},
+		labels: maps.Clone(opts.Labels),
+		ip:     fmt.Sprintf("10.0.%d.%d", r.nextIP/256, r.nextIP%256),
+	}
+
+	return opts.ID, nil
+}
+
+func (r *fakeRepo) PullImage(context.Context, string) error {
+	return nil
+}
+
+func (r *fakeRepo) UpdateContainerResources(context.Context, string, float64, uint64) error {
+	return nil
+}
+
+func (r *fakeRepo) DeleteContainer(_ context.Context, containerID string, _ time.Duration) (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.containers[containerID]; !ok {
+		return 0, fmt.Errorf("container %s: %w", containerID, errdefs.ErrNotFound)
+	}
+
+	delete(r.containers, containerID)
+
+	return 0, nil
+}
+
+func (r *fakeRepo) ExecContainer(context.Context, string, []string) (uint32, error) {
+	return 0, nil
+}
+
+func (r *fakeRepo) KillContainer(context.Context, string, syscall.Signal) error {
+	return nil
+}
+
+// The subscriptions never deliver anything, simulated workloads aren't
+// OOM killed and don't run in VMs
+func (r *fakeRepo) SubscribeOOMEvents(ctx context.Context) (<-chan string, <-chan error) {
+	return make(chan string), make(chan error)
+}
+
+func (r *fakeRepo) SubscribeVMCrashedEvents(ctx context.Context) (<-chan *shimdebug.VMCrashed, <-chan error) {
+	return make(chan *shimdebug.VMCrashed), make(chan error)
+}
+
+func (r *fakeRepo) IngressRuleset(context.Context, string) (string, error) {
+	return "", nil
+}
+
+func (r *fakeRepo) ConnectionFlows(context.Context, string) ([]network.Flow, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) TrafficCounters(context.Context, string) (network.TrafficCounters, error) {
+	return network.TrafficCounters{}, errors.New("traffic isn't counted in simulations")
+}
+
+func (r *fakeRepo) AdoptContainers(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) AdoptContainer(_ context.Context, id string, labels map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.adoptErr != nil {
+		return r.adoptErr
+	}
+
+	container, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("container %s: %w", id, errdefs.ErrNotFound)
+	}
+
+	if _, ok := container.labels[vcontainerd.OwnerLabel]; ok {
+		return fmt.Errorf("%w: container %s", vcontainerd.ErrOwned, id)
+	}
+
+	maps.Copy(container.labels, labels)
+	container.labels[vcontainerd.OwnerLabel] = "simulation"
+
+	return nil
+}
+
+// fakeContainer implements the part of containerd.Container the agent
+// uses, the other methods panic
+type fakeContainer struct {
+	containerd.Container
+
+	repo   *fakeRepo
+	id     string
+	image  string
+	spec   *oci.Spec
+	labels map[string]string
+	ip     string
+}
+
+func (c *fakeContainer) ID() string {
+	return c.id
+}
+
+func (c *fakeContainer) Info(context.Context, ...containerd.InfoOpts) (containers.Container, error) {
+	c.repo.mu.Lock()
+	defer c.repo.mu.Unlock()
+
+	return containers.Container{
+		ID:     c.id,
+		Image:  c.image,
+		Labels: maps.Clone(c.labels),
+		Runtime: containers.RuntimeInfo{
+			Name: "io.containerd.runc.v2",
+		},
+	}, nil
+}
+
+// Task returns the task of the container, which runs until the container
+// is deleted
+func (c *fakeContainer) Task(context.Context, cio.Attach) (containerd.Task, error) {
+	return &fakeTask{id: c.id}, nil
+}
+
+func (c *fakeContainer) Spec(context.Context) (*oci.Spec, error) {
+	return c.spec, nil
+}
+
+func (c *fakeContainer) Labels(context.Context) (map[string]string, error) {
+	c.repo.mu.Lock()
+	defer c.repo.mu.Unlock()
+
+	return maps.Clone(c.labels), nil
+}
+
+func (c *fakeContainer) SetLabels(_ context.Context, labels map[string]string) (map[string]string, error) {
+	c.repo.mu.Lock()
+	defer c.repo.mu.Unlock()
+
+	maps.Copy(c.labels, labels)
+
+	return maps.Clone(c.labels), nil
+}
+
+// field resolves the fields of the containerd filters, only labels are
+// filtered on
+func (c *fakeContainer) field(fieldpath []string) (string, bool) {
+	if len(fieldpath) != 2 || fieldpath[0] != "labels" {
+		return "", false
+	}
+
+	value, ok := c.labels[fieldpath[1]]
+
+	return value, ok
+}
+
+// fakeTask implements the part of containerd.Task the agent uses, the
+// other methods panic
+type fakeTask struct {
+	containerd.Task
+
+	id string
+}
+
+func (t *fakeTask) ID() string {
+	return t.id
+}
@@ -0,0 +1,126 @@
+//go:build chaos
+
+package cluster
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// faultInjector degrades the agent of the node as configured through the
+// DebugService, so the respawn and anti-entropy logic can be exercised
+// under failures. Only built with the chaos tag
+type faultInjector struct {
+	mu     sync.Mutex
+	config *pb.FaultConfig
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{config: &pb.FaultConfig{}}
+}
+
+func (f *faultInjector) get() *pb.FaultConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return proto.Clone(f.config).(*pb.FaultConfig)
+}
+
+func (f *faultInjector) set(config *pb.FaultConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.config = proto.Clone(config).(*pb.FaultConfig)
+}
+
+// dropUserEvent reports whether the received user event must be dropped
+func (f *faultInjector) dropUserEvent() bool {
+	return rand.Float64()*100 < f.get().GetDropUserEventsPercent()
+}
+
+// delayQuery blocks for the configured query delay
+func (f *faultInjector) delayQuery() {
+	time.Sleep(time.Duration(f.get().GetQueryDelayMs()) * time.Millisecond)
+}
+
+// killWorkloads SIGKILLs a random share of the local workloads every
+// broadcast period, leaving them stopped for monitorWorkloads to respawn
+func (a *Agent) killWorkloads() {
+	ticker := time.NewTicker(a.broadcastPeriod)
+	for range ticker.C {
+		percent := a.faults.get().GetKillWorkloadsPercent()
+		if percent <= 0 {
+			continue
+		}
+
+		ctx := a.ctrRepo.GetContext(context.Background())
+
+		tasks, err := a.ctrRepo.GetTasks(ctx)
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get tasks")
+
+			continue
+		}
+
+		for _, task := range tasks {
+			if rand.Float64()*100 >= percent {
+				continue
+			}
+
+			container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to get container for task %s", task.GetID())
+
+				continue
+			}
+
+			ctrTask, err := container.Task(ctx, nil)
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to get task %s", task.GetID())
+
+				continue
+			}
+
+			a.logger.Warnf("fault injection: killing task %s", task.GetID())
+
+			if err := ctrTask.Kill(ctx, syscall.SIGKILL); err != nil {
+				a.logger.WithError(err).Errorf("failed to kill task %s", task.GetID())
+			}
+		}
+	}
+}
+
+type debugServer struct {
+	pb.UnimplementedDebugServiceServer
+	agent *Agent
+}
+
+func (s *debugServer) SetFaults(_ context.Context, req *pb.FaultConfig) (*pb.FaultConfig, error) {
+	if req.GetDropUserEventsPercent() < 0 || req.GetDropUserEventsPercent() > 100 ||
+		req.GetKillWorkloadsPercent() < 0 || req.GetKillWorkloadsPercent() > 100 {
+		return nil, status.Error(codes.InvalidArgument, "percentages must be between 0 and 100")
+	}
+
+	s.agent.logger.Warnf("fault injection: setting faults to %v", req)
+	s.agent.faults.set(req)
+
+	return s.agent.faults.get(), nil
+}
+
+func (s *debugServer) GetFaults(context.Context, *pb.GetFaultsRequest) (*pb.FaultConfig, error) {
+	return s.agent.faults.get(), nil
+}
+
+func registerDebugService(grpcServer *grpc.Server, agent *Agent) {
+	pb.RegisterDebugServiceServer(grpcServer, &debugServer{agent: agent})
+}
@@ -0,0 +1,26 @@
+//go:build !chaos
+
+package cluster
+
+import (
+	"google.golang.org/grpc"
+)
+
+// faultInjector is a no-op unless built with the chaos tag, see faults.go
+type faultInjector struct{}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{}
+}
+
+func (*faultInjector) dropUserEvent() bool {
+	return false
+}
+
+func (*faultInjector) delayQuery() {}
+
+func (*Agent) killWorkloads() {}
+
+// registerDebugService doesn't register the DebugService, calls to it
+// fail with Unimplemented
+func registerDebugService(*grpc.Server, *Agent) {}
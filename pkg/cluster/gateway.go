@@ -0,0 +1,472 @@
+package cluster
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// GatewayConfig holds the settings of the HTTP+JSON gateway
+type GatewayConfig struct {
+	// Token required from clients, empty to disable authentication
+	AuthToken string
+	// Origins allowed to make cross-origin requests, "*" allows any
+	CORSOrigins []string
+}
+
+type gateway struct {
+	logger *log.Logger
+	server *server
+	cfg    *GatewayConfig
+}
+
+// NewGateway returns an HTTP handler exposing the ClusterService API as
+// JSON over HTTP, along with its OpenAPI spec at /openapi.json
+func NewGateway(logger *log.Logger, agent *Agent, cfg *GatewayConfig) http.Handler {
+	g := &gateway{
+		logger: logger,
+		server: &server{logger: logger, agent: agent},
+		cfg:    cfg,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/workloads", g.spawn)
+	mux.HandleFunc("POST /v1/plan", g.planSpawn)
+	mux.HandleFunc("GET /v1/workloads", g.list)
+	mux.HandleFunc("DELETE /v1/workloads", g.stopSelected)
+	mux.HandleFunc("PUT /v1/workloads/{id}", g.apply)
+	mux.HandleFunc("GET /v1/workloads/{id}", g.get)
+	mux.HandleFunc("DELETE /v1/workloads/{id}", g.stop)
+	mux.HandleFunc("POST /v1/workloads/{id}/restart", g.restart)
+	mux.HandleFunc("GET /v1/workloads/{id}/logs", g.logs)
+	mux.HandleFunc("GET /v1/logs", g.logs)
+	mux.HandleFunc("GET /v1/events", g.events)
+	mux.HandleFunc("GET /v1/metrics", g.metrics)
+	mux.HandleFunc("GET /v1/cache", g.cacheStatus)
+	mux.HandleFunc("GET /v1/topology", g.topology)
+	mux.HandleFunc("POST /v1/volumes/{name}/snapshots", g.snapshotVolume)
+	mux.HandleFunc("GET /v1/snapshots", g.listSnapshots)
+	mux.HandleFunc("GET /metrics", g.prometheusMetrics)
+	mux.HandleFunc("GET /openapi.json", g.openAPI)
+	mux.HandleFunc("GET "+JWKSPath, g.jwks)
+
+	return g.cors(g.authenticate(mux))
+}
+
+func (g *gateway) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && (slices.Contains(g.cfg.CORSOrigins, "*") || slices.Contains(g.cfg.CORSOrigins, origin)) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *gateway) authenticate(next http.Handler) http.Handler {
+	if g.cfg.AuthToken == "" {
+		return next
+	}
+
+	expected := []byte("Bearer " + g.cfg.AuthToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The spec is public so tooling can discover the API, and the keys
+		// so services can verify the tokens of the workloads
+		if r.URL.Path != "/openapi.json" && r.URL.Path != JWKSPath && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			g.writeError(w, status.Error(codes.Unauthenticated, "missing or invalid token"))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *gateway) spawn(w http.ResponseWriter, r *http.Request) {
+	var req pb.VmSpawnRequest
+	if err := g.readMessage(r, &req); err != nil {
+		g.writeError(w, err)
+
+		return
+	}
+
+	resp, err := g.server.Spawn(r.Context(), &req)
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) planSpawn(w http.ResponseWriter, r *http.Request) {
+	var req pb.VmSpawnRequest
+	if err := g.readMessage(r, &req); err != nil {
+		g.writeError(w, err)
+
+		return
+	}
+
+	resp, err := g.server.PlanSpawn(r.Context(), &req)
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &pb.VmQueryRequest{
+		Node:          query.Get("node"),
+		Tenant:        query.Get("tenant"),
+		LabelSelector: query.Get("label_selector"),
+		PageToken:     query.Get("page_token"),
+	}
+
+	if workloadStatus := query.Get("status"); workloadStatus != "" {
+		value, ok := pb.WorkloadStatus_value[strings.ToUpper(workloadStatus)]
+		if !ok {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid status %s", workloadStatus))
+
+			return
+		}
+
+		req.Status = pb.WorkloadStatus(value)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseUint(limit, 10, 32)
+		if err != nil {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid limit %s", limit))
+
+			return
+		}
+
+		req.Limit = uint32(parsed)
+	}
+
+	resp, err := g.server.List(r.Context(), req)
+	g.writeResponse(w, resp, err)
+}
+
+// apply creates or updates the named workload, the body is the spec
+func (g *gateway) apply(w http.ResponseWriter, r *http.Request) {
+	var spec pb.VmSpawnRequest
+	if err := g.readMessage(r, &spec); err != nil {
+		g.writeError(w, err)
+
+		return
+	}
+
+	resp, err := g.server.Apply(r.Context(), &pb.ApplyRequest{
+		Name:           r.PathValue("id"),
+		Spec:           &spec,
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+	})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) get(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.server.Get(r.Context(), &pb.GetRequest{Name: r.PathValue("id")})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) stop(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.server.Stop(r.Context(), &pb.VmStopRequest{Id: r.PathValue("id"), Tenant: r.URL.Query().Get("tenant")})
+	g.writeResponse(w, resp, err)
+}
+
+// stopSelected stops every workload matching the label selector
+func (g *gateway) stopSelected(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("label_selector") == "" {
+		g.writeError(w, status.Error(codes.InvalidArgument, "label_selector is required"))
+
+		return
+	}
+
+	resp, err := g.server.Stop(r.Context(), &pb.VmStopRequest{LabelSelector: query.Get("label_selector"), Tenant: query.Get("tenant")})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) restart(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.server.Restart(r.Context(), &pb.RestartRequest{Id: r.PathValue("id"), Tenant: r.URL.Query().Get("tenant")})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) logs(w http.ResponseWriter, r *http.Request) {
+	// Without an ID in the path, the logs of the workloads matching the
+	// label selector are returned
+	req := &pb.VmLogsRequest{
+		Id:            r.PathValue("id"),
+		Tenant:        r.URL.Query().Get("tenant"),
+		LabelSelector: r.URL.Query().Get("label_selector"),
+	}
+
+	if req.GetId() == "" && req.GetLabelSelector() == "" {
+		g.writeError(w, status.Error(codes.InvalidArgument, "label_selector is required"))
+
+		return
+	}
+
+	if tail := r.URL.Query().Get("tail_bytes"); tail != "" {
+		tailBytes, err := strconv.ParseUint(tail, 10, 32)
+		if err != nil {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid tail_bytes %s", tail))
+
+			return
+		}
+
+		req.TailBytes = uint32(tailBytes)
+	}
+
+	resp, err := g.server.Logs(r.Context(), req)
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) metrics(w http.ResponseWriter, r *http.Request) {
+	req := &pb.MetricsRequest{}
+
+	if since := r.URL.Query().Get("since_unix_time"); since != "" {
+		sinceUnixTime, err := strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid since_unix_time %s", since))
+
+			return
+		}
+
+		req.SinceUnixTime = sinceUnixTime
+	}
+
+	resp, err := g.server.Metrics(r.Context(), req)
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) cacheStatus(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	resp, err := g.server.CacheStatus(r.Context(), &pb.CacheStatusRequest{
+		Node:     query.Get("node"),
+		ImageRef: query.Get("image_ref"),
+	})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) topology(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.server.Topology(r.Context(), &pb.TopologyRequest{Tenant: r.URL.Query().Get("tenant")})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) snapshotVolume(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.server.SnapshotVolume(r.Context(), &pb.SnapshotVolumeRequest{
+		Volume: r.PathValue("name"),
+		Tenant: r.URL.Query().Get("tenant"),
+	})
+	g.writeResponse(w, resp, err)
+}
+
+func (g *gateway) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	resp, err := g.server.ListSnapshots(r.Context(), &pb.ListSnapshotsRequest{
+		Volume: query.Get("volume"),
+		Tenant: query.Get("tenant"),
+	})
+	g.writeResponse(w, resp, err)
+}
+
+// prometheusMetrics serves the counters of this node in the Prometheus
+// text format
+func (g *gateway) prometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if err := g.server.agent.WritePrometheusMetrics(r.Context(), w); err != nil {
+		g.logger.WithError(err).Warn("failed to write prometheus metrics")
+	}
+}
+
+// events streams cluster events as server-sent events
+func (g *gateway) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		g.writeError(w, status.Error(codes.Unimplemented, "streaming not supported"))
+
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+
+	events, cancel := g.server.agent.SubscribeEvents()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if !eventMatches(event, id) {
+				continue
+			}
+
+			data, err := protojson.Marshal(event)
+			if err != nil {
+				g.logger.WithError(err).Error("failed to marshal event")
+
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.GetEvent(), data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (g *gateway) openAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(OpenAPISpec()); err != nil {
+		g.logger.WithError(err).Error("failed to write OpenAPI spec")
+	}
+}
+
+// jwks serves the public keys the identity tokens of the workloads are
+// signed with
+func (g *gateway) jwks(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(g.server.agent.JWKS()); err != nil {
+		g.logger.WithError(err).Error("failed to write JWKS")
+	}
+}
+
+func (g *gateway) readMessage(r *http.Request, message proto.Message) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to read body: %s", err)
+	}
+
+	if err := protojson.Unmarshal(body, message); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request: %s", err)
+	}
+
+	return nil
+}
+
+func (g *gateway) writeResponse(w http.ResponseWriter, message proto.Message, err error) {
+	if err != nil {
+		g.writeError(w, err)
+
+		return
+	}
+
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		g.writeError(w, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func (g *gateway) writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	body := map[string]interface{}{"error": err.Error()}
+
+	if st, ok := status.FromError(err); ok {
+		code = httpStatus(st.Code())
+		body["error"] = st.Message()
+
+		for _, detail := range st.Details() {
+			if resp, ok := detail.(*pb.ErrorResponse); ok {
+				body["code"] = resp.GetCode().String()
+				if len(resp.GetDetails()) > 0 {
+					body["details"] = resp.GetDetails()
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled, codes.Unknown, codes.Internal, codes.DataLoss:
+		fallthrough
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ServeGateway serves the gateway on addr until ctx is done
+func ServeGateway(ctx context.Context, addr string, handler http.Handler, certs *CertSource) error {
+	httpServer := &http.Server{Addr: addr, Handler: handler} //nolint:gosec
+
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Close()
+	}()
+
+	var err error
+	if certs != nil {
+		httpServer.TLSConfig = certs.ListenerTLSConfig()
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve gateway at %s: %w", addr, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package cluster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// Nodes with less free disk space aren't placed on, as they
+	// couldn't pull new images
+	minDiskFree = 1024
+
+	// Pressure assumed for nodes whose host metrics aren't known yet
+	unknownHostPressure = 0.5
+)
+
+type netCounters struct {
+	rx uint64
+	tx uint64
+}
+
+// hostSampler reads the usage of the host from /proc and /sys, network
+// utilization is computed against the counters of the previous sample
+type hostSampler struct {
+	dataDir    string
+	lastNet    map[string]netCounters
+	lastSample time.Time
+}
+
+func newHostSampler(dataDir string) *hostSampler {
+	return &hostSampler{dataDir: dataDir}
+}
+
+// sample returns the host metrics it managed to read along with the
+// errors of the others
+func (s *hostSampler) sample() (*pb.HostMetrics, error) {
+	var errs []error
+
+	metrics := &pb.HostMetrics{}
+
+	load1, err := getLoadAverage()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to read load average: %w", err))
+	}
+	metrics.Load1 = load1
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(s.dataDir, &stat); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stat %s: %w", s.dataDir, err))
+	} else {
+		metrics.DiskFree = stat.Bavail * uint64(stat.Bsize) / (1024 * 1024)
+	}
+
+	utilization, err := s.networkUtilization()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to compute network utilization: %w", err))
+	}
+	metrics.NetworkUtilization = utilization
+
+	return metrics, errors.Join(errs...)
+}
+
+// networkUtilization returns the highest receive or transmit throughput of
+// the interfaces with a known link speed since the last call, as a fraction
+// of that speed
+func (s *hostSampler) networkUtilization() (float64, error) {
+	counters, err := getNetCounters()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.lastSample).Seconds()
+	last := s.lastNet
+
+	s.lastNet = counters
+	s.lastSample = now
+
+	if last == nil || elapsed <= 0 {
+		return 0, nil
+	}
+
+	utilization := 0.0
+
+	for iface, current := range counters {
+		previous, ok := last[iface]
+		if !ok || current.rx < previous.rx || current.tx < previous.tx {
+			continue
+		}
+
+		// Virtual interfaces report no speed
+		speed, err := getLinkSpeed(iface)
+		if err != nil || speed <= 0 {
+			continue
+		}
+
+		bitsPerSec := float64(max(current.rx-previous.rx, current.tx-previous.tx)) * 8 / elapsed
+		utilization = max(utilization, bitsPerSec/(float64(speed)*1e6))
+	}
+
+	return min(utilization, 1), nil
+}
+
+// Returns the 1 minute load average
+func getLoadAverage() (float64, error) {
+	contents, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+
+	// 0.42 0.35 0.30 1/123 4567
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 {
+		return 0, errors.New("empty loadavg")
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// Returns the bytes received and sent by the workload of the given
+// process, through the eth0 interface of its network namespace
+func getWorkloadNetCounters(pid uint32) (netCounters, error) {
+	counters, err := readNetCounters(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return netCounters{}, err
+	}
+
+	return counters["eth0"], nil
+}
+
+// Returns the TCP segments retransmitted in the network namespace of the
+// given process
+func getWorkloadRetransmits(pid uint32) (uint64, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/net/snmp", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var header []string
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// Tcp: RtoAlgorithm RtoMin ... RetransSegs ...
+		// Tcp: 1 200 ... 12 ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+
+		if header == nil {
+			header = fields
+
+			continue
+		}
+
+		for i, name := range header {
+			if name == "RetransSegs" && i < len(fields) {
+				return strconv.ParseUint(fields[i], 10, 64)
+			}
+		}
+
+		break
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, errors.New("could not find RetransSegs in net/snmp")
+}
+
+// Returns the received and transmitted bytes of the non loopback interfaces
+func getNetCounters() (map[string]netCounters, error) {
+	return readNetCounters("/proc/net/dev")
+}
+
+func readNetCounters(path string) (map[string]netCounters, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counters := make(map[string]netCounters)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// eth0: 1234 10 0 0 0 0 0 0 5678 20 0 0 0 0 0 0
+		iface, stats, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		iface = strings.TrimSpace(iface)
+		fields := strings.Fields(stats)
+
+		if iface == "lo" || len(fields) < 9 {
+			continue
+		}
+
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		counters[iface] = netCounters{rx: rx, tx: tx}
+	}
+
+	return counters, scanner.Err()
+}
+
+// Returns the link speed (in Mbps) of the interface
+func getLinkSpeed(iface string) (int, error) {
+	contents, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/speed", iface))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(contents)))
+}
+
+// hostPressure scores how loaded a node actually is, from 0 for an idle
+// node to 1 for a node whose CPUs or network are saturated
+func hostPressure(state *pb.NodeStateResponse) float64 {
+	pressure, _ := hostPressureBreakdown(state)
+
+	return pressure
+}
+
+// hostPressureBreakdown returns the host pressure of the node along with
+// the metrics it was derived from
+func hostPressureBreakdown(state *pb.NodeStateResponse) (float64, map[string]float64) {
+	host := state.GetHost()
+	if host == nil {
+		return unknownHostPressure, map[string]float64{"unknown": unknownHostPressure}
+	}
+
+	breakdown := map[string]float64{"network_utilization": host.GetNetworkUtilization()}
+
+	pressure := host.GetNetworkUtilization()
+	if state.GetCpus() > 0 {
+		breakdown["load_per_cpu"] = host.GetLoad1() / float64(state.GetCpus())
+		pressure = max(pressure, breakdown["load_per_cpu"])
+	}
+
+	return min(pressure, 1), breakdown
+}
+
+// lowOnDisk reports whether the node has too little disk space left to
+// pull new images, nodes not reporting their disk space aren't
+func lowOnDisk(state *pb.NodeStateResponse) bool {
+	diskFree := state.GetHost().GetDiskFree()
+
+	return diskFree > 0 && diskFree < minDiskFree
+}
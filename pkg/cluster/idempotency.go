@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// spawnKeys tracks the idempotency keys of the spawns in progress on this
+// node, spawned workloads are found through their IdempotencyKeyLabel
+type spawnKeys struct {
+	mu sync.Mutex
+	// container ID by idempotency key
+	inFlight map[string]string
+}
+
+// claimSpawnKey returns the workload spawned or being spawned on this node
+// with key, or records the key as being spawned as id if there is none
+func (a *Agent) claimSpawnKey(ctx context.Context, key, id string) (*pb.VmSpawnResponse, error) {
+	a.spawnKeys.mu.Lock()
+	defer a.spawnKeys.mu.Unlock()
+
+	existing, err := a.existingSpawnLocked(ctx, key)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	a.spawnKeys.inFlight[key] = id
+
+	return nil, nil
+}
+
+// releaseSpawnKey forgets a key once its spawn is done, the container
+// label takes over if it succeeded
+func (a *Agent) releaseSpawnKey(key string) {
+	a.spawnKeys.mu.Lock()
+	defer a.spawnKeys.mu.Unlock()
+
+	delete(a.spawnKeys.inFlight, key)
+}
+
+// existingSpawn returns the workload spawned or being spawned on this
+// node with key, nil if there is none
+func (a *Agent) existingSpawn(ctx context.Context, key string) (*pb.VmSpawnResponse, error) {
+	a.spawnKeys.mu.Lock()
+	defer a.spawnKeys.mu.Unlock()
+
+	return a.existingSpawnLocked(ctx, key)
+}
+
+func (a *Agent) existingSpawnLocked(ctx context.Context, key string) (*pb.VmSpawnResponse, error) {
+	if id, ok := a.spawnKeys.inFlight[key]; ok {
+		return &pb.VmSpawnResponse{Id: id, Url: id + "." + a.baseURL, Pending: true}, nil
+	}
+
+	containers, err := a.ctrRepo.ListContainers(ctx, fmt.Sprintf("labels.%q==%q", IdempotencyKeyLabel, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up idempotency key %s: %w", key, err)
+	}
+
+	if len(containers) == 0 {
+		return nil, nil
+	}
+
+	id := containers[0].ID()
+
+	return &pb.VmSpawnResponse{Id: id, Url: id + "." + a.baseURL}, nil
+}
+
+// knownSpawn returns the workload spawned with key according to the
+// state broadcasts, nil if there is none
+func (a *Agent) knownSpawn(key string) *pb.VmSpawnResponse {
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if workload.GetSourceRequest().GetIdempotencyKey() == key {
+				return &pb.VmSpawnResponse{Id: workload.GetId(), Url: workload.GetId() + "." + a.baseURL}
+			}
+		}
+	}
+
+	return nil
+}
+
+// dryRunExisting returns the workload a node answered a dry run with,
+// nil if it didn't spawn the request yet
+func dryRunExisting(payload []byte) *pb.VmSpawnResponse {
+	var message pb.ClusterMessage
+	if err := proto.Unmarshal(payload, &message); err != nil || message.GetEvent() != pb.ClusterEvent_SPAWN {
+		return nil
+	}
+
+	var resp pb.VmSpawnResponse
+	if err := message.GetWrappedMessage().UnmarshalTo(&resp); err != nil || resp.GetId() == "" {
+		return nil
+	}
+
+	return &resp
+}
+
+// keyedReplicaGroup returns the replica group of a horizontally scaled
+// request, derived from its idempotency key if it has one so retries
+// target the same group
+func keyedReplicaGroup(req *pb.VmSpawnRequest) string {
+	if req.GetIdempotencyKey() == "" {
+		return uuid.NewString()
+	}
+
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(req.GetIdempotencyKey())).String()
+}
+
+// replicaKey returns the idempotency key of a replica of a horizontally
+// scaled request, empty if the request has none
+func replicaKey(key string, replica int) string {
+	if key == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%d", key, replica)
+}
@@ -0,0 +1,502 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Lifetime of the identity tokens of the workloads if the metadata
+	// service is configured without one
+	DefaultMetadataTokenTTL = 15 * time.Minute
+	// Age of the key of a node it is replaced by a new one past, if the
+	// metadata service is configured without one
+	DefaultSigningKeyRotation = 24 * time.Hour
+	// Issuer of the identity tokens of the workloads
+	WorkloadTokenIssuer = "hypercore"
+	// Path of the gateway serving the public keys of the cluster
+	JWKSPath = "/.well-known/jwks.json"
+
+	signingKeysFileName = "signing-keys.json"
+	// Broadcasts a new key is published for before it signs tokens, so
+	// the other nodes know it by then
+	signingKeyActivationBroadcasts = 3
+	// Size of the P-256 coordinates and of the halves of the signatures
+	p256Size = 32
+)
+
+var (
+	tokenEncoding = base64.RawURLEncoding
+
+	errInvalidWorkloadToken = errors.New("invalid workload token")
+)
+
+// WorkloadClaims are the claims of the identity tokens of the workloads
+type WorkloadClaims struct {
+	Issuer string `json:"iss"`
+	// SPIFFE ID of the workload if the cluster has a trust domain, its
+	// ID otherwise
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	Workload  string `json:"workload"`
+	Tenant    string `json:"tenant,omitempty"`
+	Node      string `json:"node"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// JSONWebKey is the public key of a node in the JWK format
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JSONWebKeySet holds the public keys of the nodes of a cluster, which
+// the identity tokens of its workloads are verified with
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// signingKey is a key of this node signing the tokens of its workloads
+type signingKey struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	// PKCS #8 DER
+	Key []byte `json:"key"`
+
+	private *ecdsa.PrivateKey
+}
+
+// signingKeys are the keys of this node, persisted so the tokens issued
+// before a restart stay valid. A new key is published along with the
+// state of the node before it replaces the current one, which is then
+// published until the tokens it signed expired
+type signingKeys struct {
+	mu         sync.Mutex
+	path       string
+	keys       []*signingKey
+	rotation   time.Duration
+	tokenTTL   time.Duration
+	activation time.Duration
+}
+
+func newSigningKeys(path string, rotation, tokenTTL, broadcastPeriod time.Duration) (*signingKeys, error) {
+	keys := &signingKeys{
+		path:       path,
+		rotation:   rotation,
+		tokenTTL:   tokenTTL,
+		activation: broadcastPeriod * signingKeyActivationBroadcasts,
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read signing keys %s: %w", path, err)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(contents, &keys.keys); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal signing keys %s: %w", path, err)
+		}
+
+		for _, key := range keys.keys {
+			parsed, err := x509.ParsePKCS8PrivateKey(key.Key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse signing key %s: %w", key.ID, err)
+			}
+
+			var ok bool
+			if key.private, ok = parsed.(*ecdsa.PrivateKey); !ok {
+				return nil, fmt.Errorf("signing key %s is not an ECDSA key", key.ID)
+			}
+		}
+	}
+
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	if err := keys.rotateLocked(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// rotateLocked adds a new key once the newest one is older than the
+// rotation, and drops the keys the tokens signed with expired
+func (k *signingKeys) rotateLocked(now time.Time) error {
+	changed := false
+
+	if len(k.keys) == 0 || now.Sub(k.keys[len(k.keys)-1].Created) >= k.rotation {
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate signing key: %w", err)
+		}
+
+		encoded, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return fmt.Errorf("failed to encode signing key: %w", err)
+		}
+
+		k.keys = append(k.keys, &signingKey{ID: uuid.NewString(), Created: now, Key: encoded, private: private})
+		changed = true
+	}
+
+	// Once a key replaced the oldest one, the tokens the oldest signed
+	// expire within the TTL
+	for len(k.keys) > 1 && now.Sub(k.keys[1].Created) >= k.activation+k.tokenTTL {
+		k.keys = k.keys[1:]
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	contents, err := json.Marshal(k.keys)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.path), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	tmpPath := k.path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, k.path)
+}
+
+// signer returns the newest key published for long enough, or the
+// oldest one
+func (k *signingKeys) signer(now time.Time) *signingKey {
+	for _, key := range slices.Backward(k.keys) {
+		if now.Sub(key.Created) >= k.activation {
+			return key
+		}
+	}
+
+	return k.keys[0]
+}
+
+// sign returns the token of the claims, signed with the current key
+func (k *signingKeys) sign(claims WorkloadClaims) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Unix(claims.IssuedAt, 0)
+	if err := k.rotateLocked(now); err != nil {
+		return "", err
+	}
+
+	key := k.signer(now)
+
+	header, err := json.Marshal(tokenHeader{Algorithm: "ES256", Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode workload claims: %w", err)
+	}
+
+	signed := tokenEncoding.EncodeToString(header) + "." + tokenEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign workload token: %w", err)
+	}
+
+	signature := make([]byte, 2*p256Size)
+	r.FillBytes(signature[:p256Size])
+	s.FillBytes(signature[p256Size:])
+
+	return signed + "." + tokenEncoding.EncodeToString(signature), nil
+}
+
+// published returns the public keys of this node, rotating them first
+func (k *signingKeys) published(now time.Time) ([]*pb.SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.rotateLocked(now); err != nil {
+		return nil, err
+	}
+
+	published := make([]*pb.SigningKey, 0, len(k.keys))
+
+	for _, key := range k.keys {
+		public, err := x509.MarshalPKIXPublicKey(&key.private.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		published = append(published, &pb.SigningKey{Id: key.ID, PublicKey: public})
+	}
+
+	return published, nil
+}
+
+// signingKeyMessage returns what the certificate of a node signs to
+// endorse one of its signing keys
+func signingKeyMessage(key *pb.SigningKey) []byte {
+	return slices.Concat([]byte("hypercore-signing-key\x00"+key.GetId()+"\x00"), key.GetPublicKey())
+}
+
+// publishedSigningKeys returns the public keys of this node, signed with
+// its cluster certificate if it has one so the other nodes can tell
+// they were published by a member of the cluster
+func (a *Agent) publishedSigningKeys(now time.Time) ([]*pb.SigningKey, error) {
+	keys, err := a.signingKeys.published(now)
+	if err != nil || a.clusterCerts == nil {
+		return keys, err
+	}
+
+	for _, key := range keys {
+		if key.Certificates, key.Signature, err = a.clusterCerts.Sign(signingKeyMessage(key)); err != nil {
+			return nil, fmt.Errorf("failed to sign signing key %s: %w", key.GetId(), err)
+		}
+	}
+
+	return keys, nil
+}
+
+// verifySigningKey checks that a key broadcast by another node was signed
+// with a certificate issued by the cluster CA. Gossip isn't authenticated,
+// so without a CA the keys of the other nodes can't be trusted
+func (a *Agent) verifySigningKey(key *pb.SigningKey) error {
+	if a.clusterCerts == nil {
+		return errNoClusterCA
+	}
+
+	_, err := a.clusterCerts.VerifySigned(key.GetCertificates(), signingKeyMessage(key), key.GetSignature())
+
+	return err
+}
+
+// JWKS returns the public keys of this node, along with those the other
+// nodes of the cluster published with a certificate of the cluster CA.
+// Keys are told apart by ID, so an ID published with different public
+// keys is left out, unless it is one of this node
+func (a *Agent) JWKS() *JSONWebKeySet {
+	var keys []*pb.SigningKey
+
+	if a.signingKeys != nil {
+		local, err := a.signingKeys.published(time.Now())
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get the signing keys of the node")
+		}
+
+		keys = append(keys, local...)
+	}
+
+	localKeys := len(keys)
+
+	for _, state := range a.knownStates() {
+		if state.GetNode().GetId() == a.cfg.NodeName {
+			continue
+		}
+
+		for _, key := range state.GetSigningKeys() {
+			if err := a.verifySigningKey(key); err != nil {
+				a.logger.WithError(err).Debugf("Ignoring signing key %s of node %s", key.GetId(), state.GetNode().GetId())
+
+				continue
+			}
+
+			keys = append(keys, key)
+		}
+	}
+
+	publicKeys := make(map[string][]byte, len(keys))
+	conflicting := make(map[string]struct{})
+
+	for i, key := range keys {
+		public, ok := publicKeys[key.GetId()]
+		if !ok {
+			publicKeys[key.GetId()] = key.GetPublicKey()
+
+			continue
+		}
+
+		if !bytes.Equal(public, key.GetPublicKey()) {
+			a.logger.Warnf("Signing key %s is published with different public keys", key.GetId())
+
+			if i >= localKeys {
+				conflicting[key.GetId()] = struct{}{}
+			}
+		}
+	}
+
+	// The keys of this node are never left out
+	for _, key := range keys[:localKeys] {
+		delete(conflicting, key.GetId())
+	}
+
+	keySet := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	served := make(map[string]struct{})
+
+	for _, key := range keys {
+		if _, ok := conflicting[key.GetId()]; ok {
+			continue
+		}
+
+		if _, ok := served[key.GetId()]; ok || !bytes.Equal(publicKeys[key.GetId()], key.GetPublicKey()) {
+			continue
+		}
+
+		public, err := x509.ParsePKIXPublicKey(key.GetPublicKey())
+		if err != nil {
+			continue
+		}
+
+		ecKey, ok := public.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			continue
+		}
+
+		served[key.GetId()] = struct{}{}
+
+		keySet.Keys = append(keySet.Keys, JSONWebKey{
+			KeyType:   "EC",
+			Curve:     "P-256",
+			X:         tokenEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, p256Size))),
+			Y:         tokenEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, p256Size))),
+			KeyID:     key.GetId(),
+			Use:       "sig",
+			Algorithm: "ES256",
+		})
+	}
+
+	return keySet
+}
+
+// workloadSubject returns the subject of the tokens of a workload, its
+// SPIFFE ID if the cluster has a trust domain
+func (a *Agent) workloadSubject(id, tenant string) string {
+	if a.trustDomain == "" {
+		return id
+	}
+
+	if tenant == "" {
+		return fmt.Sprintf("%s://%s/workload/%s", spiffeScheme, a.trustDomain, id)
+	}
+
+	return fmt.Sprintf("%s://%s/tenant/%s/workload/%s", spiffeScheme, a.trustDomain, tenant, id)
+}
+
+// VerifyWorkloadToken returns the claims of an identity token signed by
+// one of the keys of the set, if it is valid for the audience at now
+func VerifyWorkloadToken(keySet *JSONWebKeySet, token, audience string, now time.Time) (*WorkloadClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidWorkloadToken
+	}
+
+	var header tokenHeader
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Algorithm != "ES256" {
+		return nil, errInvalidWorkloadToken
+	}
+
+	index := slices.IndexFunc(keySet.Keys, func(key JSONWebKey) bool { return key.KeyID == header.KeyID })
+	if index < 0 {
+		return nil, fmt.Errorf("%w: unknown key %q", errInvalidWorkloadToken, header.KeyID)
+	}
+
+	public, err := keySet.Keys[index].publicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := tokenEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 2*p256Size {
+		return nil, errInvalidWorkloadToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:p256Size])
+	s := new(big.Int).SetBytes(signature[p256Size:])
+
+	if !ecdsa.Verify(public, digest[:], r, s) {
+		return nil, errInvalidWorkloadToken
+	}
+
+	var claims WorkloadClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil || claims.Issuer != WorkloadTokenIssuer {
+		return nil, errInvalidWorkloadToken
+	}
+
+	if claims.Audience != audience {
+		return nil, fmt.Errorf("workload token of %s is for audience %q", claims.Subject, claims.Audience)
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("workload token of %s expired at %s", claims.Subject, time.Unix(claims.ExpiresAt, 0).UTC())
+	}
+
+	return &claims, nil
+}
+
+func decodeTokenPart(part string, value any) error {
+	decoded, err := tokenEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(decoded, value)
+}
+
+func (k JSONWebKey) publicKey() (*ecdsa.PublicKey, error) {
+	if k.KeyType != "EC" || k.Curve != "P-256" {
+		return nil, fmt.Errorf("unsupported key %s of type %s", k.KeyID, k.KeyType)
+	}
+
+	x, errX := tokenEncoding.DecodeString(k.X)
+	y, errY := tokenEncoding.DecodeString(k.Y)
+
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("invalid coordinates of key %s", k.KeyID)
+	}
+
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if _, err := public.ECDH(); err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", k.KeyID, err)
+	}
+
+	return public, nil
+}
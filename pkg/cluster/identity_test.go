@@ -0,0 +1,444 @@
+package cluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	log "github.com/sirupsen/logrus"
+)
+
+const testAudience = "https://vault.example.com"
+
+// testCA is a CA issuing the cluster certificates of the nodes of a test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	path string
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cluster CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, path, "CERTIFICATE", der)
+
+	return &testCA{cert: cert, key: key, path: path}
+}
+
+// issue returns the cluster certificate of a node, trusting the CA at
+// caPath
+func (ca *testCA) issue(t *testing.T, node, caPath string) *CertSource {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: node},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "PRIVATE KEY", encodedKey)
+
+	source, err := NewCertSource(log.New(), certPath, keyPath, caPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return source
+}
+
+func testSigningKeys(t *testing.T) *signingKeys {
+	t.Helper()
+
+	keys, err := newSigningKeys(filepath.Join(t.TempDir(), signingKeysFileName), time.Hour, time.Minute, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+// testIdentityAgent returns an agent only serving the signing keys of the
+// node, with its cluster certificate if certs is set
+func testIdentityAgent(t *testing.T, node string, certs *CertSource) *Agent {
+	t.Helper()
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	return &Agent{
+		cfg:          &serf.Config{NodeName: node},
+		logger:       logger,
+		states:       newStateStore(time.Hour),
+		signingKeys:  testSigningKeys(t),
+		clusterCerts: certs,
+	}
+}
+
+// publish records the signing keys of the node in the states known by
+// the agent, as its broadcasts would
+func publish(t *testing.T, agent *Agent, node string, keys ...*pb.SigningKey) {
+	t.Helper()
+
+	agent.states.record(node, &pb.NodeStateResponse{Node: &pb.Node{Id: node}, SigningKeys: keys})
+}
+
+func publishedKeys(t *testing.T, agent *Agent) []*pb.SigningKey {
+	t.Helper()
+
+	keys, err := agent.publishedSigningKeys(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func keyIDs(keySet *JSONWebKeySet) []string {
+	ids := make([]string, 0, len(keySet.Keys))
+	for _, key := range keySet.Keys {
+		ids = append(ids, key.KeyID)
+	}
+
+	return ids
+}
+
+func testClaims(now time.Time) WorkloadClaims {
+	return WorkloadClaims{
+		Issuer:    WorkloadTokenIssuer,
+		Subject:   "workload-1",
+		Audience:  testAudience,
+		Workload:  "workload-1",
+		Node:      "node-1",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+}
+
+// replacePart returns the token with one of its parts replaced
+func replacePart(token string, i int, part string) string {
+	parts := strings.Split(token, ".")
+	parts[i] = part
+
+	return strings.Join(parts, ".")
+}
+
+func TestVerifyWorkloadToken(t *testing.T) {
+	agent := testIdentityAgent(t, "node-1", nil)
+	keySet := agent.JWKS()
+	now := time.Now()
+
+	token, err := agent.signingKeys.sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPayload := testClaims(now)
+	otherPayload.Workload = "workload-2"
+
+	forged, err := testIdentityAgent(t, "node-1", nil).signingKeys.sign(otherPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		keySet   *JSONWebKeySet
+		token    string
+		audience string
+		now      time.Time
+		err      string
+	}{
+		{name: "valid", keySet: keySet, token: token, audience: testAudience, now: now},
+		{name: "wrong audience", keySet: keySet, token: token, audience: "https://other.example.com", now: now, err: "for audience"},
+		{name: "expired", keySet: keySet, token: token, audience: testAudience, now: now.Add(time.Minute), err: "expired"},
+		{name: "unknown key", keySet: &JSONWebKeySet{}, token: token, audience: testAudience, now: now, err: "unknown key"},
+		{name: "tampered payload", keySet: keySet, token: replacePart(token, 1, strings.Split(forged, ".")[1]), audience: testAudience, now: now, err: "invalid workload token"},
+		{name: "tampered signature", keySet: keySet, token: replacePart(token, 2, strings.Split(forged, ".")[2]), audience: testAudience, now: now, err: "invalid workload token"},
+		{name: "truncated", keySet: keySet, token: strings.Join(strings.Split(token, ".")[:2], "."), audience: testAudience, now: now, err: "invalid workload token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := VerifyWorkloadToken(tt.keySet, tt.token, tt.audience, tt.now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if claims.Workload != "workload-1" {
+				t.Fatalf("expected the claims of workload-1, got %+v", claims)
+			}
+		})
+	}
+}
+
+// A new key only signs once it was published for long enough for the
+// other nodes to know it, tokens are signed with the previous one until
+// then
+func TestSigningKeyActivation(t *testing.T) {
+	agent := testIdentityAgent(t, "node-1", nil)
+	keys := agent.signingKeys
+	previous := keys.keys[0]
+
+	// Rotate without the other nodes having seen the new key yet
+	rotatedAt := previous.Created.Add(keys.rotation + time.Second).Truncate(time.Second)
+
+	before, err := keys.published(rotatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(before) != 2 {
+		t.Fatalf("expected the previous and the new key to be published, got %d keys", len(before))
+	}
+
+	current := keys.keys[1]
+	keySet := agent.JWKS()
+
+	for _, tt := range []struct {
+		name   string
+		now    time.Time
+		signer string
+	}{
+		{name: "before activation", now: rotatedAt, signer: previous.ID},
+		{name: "just before activation", now: rotatedAt.Add(keys.activation - time.Second), signer: previous.ID},
+		{name: "after activation", now: rotatedAt.Add(keys.activation), signer: current.ID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := keys.sign(testClaims(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var header tokenHeader
+			if err := decodeTokenPart(strings.Split(token, ".")[0], &header); err != nil {
+				t.Fatal(err)
+			}
+
+			if header.KeyID != tt.signer {
+				t.Fatalf("expected the token to be signed with key %s, got %s", tt.signer, header.KeyID)
+			}
+
+			if _, err := VerifyWorkloadToken(keySet, token, testAudience, tt.now); err != nil {
+				t.Fatalf("expected the token to verify with the keys published at the rotation: %v", err)
+			}
+		})
+	}
+
+	// A node that only knew the previous key still verifies the tokens
+	// signed before the activation
+	stale := &JSONWebKeySet{Keys: slices.DeleteFunc(slices.Clone(keySet.Keys), func(key JSONWebKey) bool {
+		return key.KeyID == current.ID
+	})}
+
+	token, err := keys.sign(testClaims(rotatedAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyWorkloadToken(stale, token, testAudience, rotatedAt); err != nil {
+		t.Fatalf("expected a token signed before the activation to verify with the previous key: %v", err)
+	}
+}
+
+func TestJWKSOnlyServesLocalKeysWithoutCA(t *testing.T) {
+	ca := newTestCA(t)
+	agent := testIdentityAgent(t, "node-1", nil)
+	remote := testIdentityAgent(t, "node-2", ca.issue(t, "node-2", ca.path))
+
+	publish(t, agent, "node-2", publishedKeys(t, remote)...)
+
+	local := publishedKeys(t, agent)
+	if ids := keyIDs(agent.JWKS()); len(ids) != 1 || ids[0] != local[0].GetId() {
+		t.Fatalf("expected only the local key %s without a cluster CA, got %v", local[0].GetId(), ids)
+	}
+}
+
+func TestJWKSTrust(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	agent := testIdentityAgent(t, "node-1", ca.issue(t, "node-1", ca.path))
+	local := publishedKeys(t, agent)[0]
+
+	member := publishedKeys(t, testIdentityAgent(t, "node-2", ca.issue(t, "node-2", ca.path)))[0]
+	unsigned := publishedKeys(t, testIdentityAgent(t, "node-3", nil))[0]
+	foreign := publishedKeys(t, testIdentityAgent(t, "node-4", otherCA.issue(t, "node-4", otherCA.path)))[0]
+
+	// A member endorsing the key of another node under its own ID
+	impostor := publishedKeys(t, testIdentityAgent(t, "node-5", ca.issue(t, "node-5", ca.path)))[0]
+	swapped := &pb.SigningKey{Id: impostor.GetId(), PublicKey: unsigned.GetPublicKey(), Certificates: impostor.GetCertificates(), Signature: impostor.GetSignature()}
+
+	endorse := func(t *testing.T, node string, key *pb.SigningKey) *pb.SigningKey {
+		t.Helper()
+
+		endorsed := &pb.SigningKey{Id: key.GetId(), PublicKey: key.GetPublicKey()}
+
+		var err error
+		if endorsed.Certificates, endorsed.Signature, err = ca.issue(t, node, ca.path).Sign(signingKeyMessage(endorsed)); err != nil {
+			t.Fatal(err)
+		}
+
+		return endorsed
+	}
+
+	// Members publishing the ID of another key with their own key
+	shadowing := endorse(t, "node-6", &pb.SigningKey{Id: local.GetId(), PublicKey: member.GetPublicKey()})
+	conflicting := []*pb.SigningKey{
+		endorse(t, "node-7", &pb.SigningKey{Id: "conflicting", PublicKey: member.GetPublicKey()}),
+		endorse(t, "node-8", &pb.SigningKey{Id: "conflicting", PublicKey: local.GetPublicKey()}),
+	}
+
+	tests := []struct {
+		name  string
+		nodes map[string][]*pb.SigningKey
+		want  []string
+	}{
+		{name: "member", nodes: map[string][]*pb.SigningKey{"node-2": {member}}, want: []string{local.GetId(), member.GetId()}},
+		{name: "unsigned", nodes: map[string][]*pb.SigningKey{"node-3": {unsigned}}, want: []string{local.GetId()}},
+		{name: "other CA", nodes: map[string][]*pb.SigningKey{"node-4": {foreign}}, want: []string{local.GetId()}},
+		{name: "swapped public key", nodes: map[string][]*pb.SigningKey{"node-5": {swapped}}, want: []string{local.GetId()}},
+		{name: "republished by another member", nodes: map[string][]*pb.SigningKey{"node-2": {member}, "node-6": {endorse(t, "node-6", member)}}, want: []string{local.GetId(), member.GetId()}},
+		{name: "shadowing a local key", nodes: map[string][]*pb.SigningKey{"node-6": {shadowing}}, want: []string{local.GetId()}},
+		{name: "conflicting remote keys", nodes: map[string][]*pb.SigningKey{"node-7": conflicting[:1], "node-8": conflicting[1:]}, want: []string{local.GetId()}},
+		// The state of this node as gossiped back isn't trusted over its keys
+		{name: "own state", nodes: map[string][]*pb.SigningKey{"node-1": {member}}, want: []string{local.GetId()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent.states = newStateStore(time.Hour)
+
+			for node, keys := range tt.nodes {
+				publish(t, agent, node, keys...)
+			}
+
+			keySet := agent.JWKS()
+			if ids := keyIDs(keySet); !slices.Equal(ids, tt.want) {
+				t.Fatalf("expected keys %v, got %v", tt.want, ids)
+			}
+
+			// The local key is served as it was generated
+			public, err := keySet.Keys[0].publicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded, err := x509.MarshalPKIXPublicKey(public)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(encoded, local.GetPublicKey()) {
+				t.Fatal("expected the local key to be served with its own public key")
+			}
+		})
+	}
+}
+
+func TestVerifySigned(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.issue(t, "node-1", ca.path)
+	verifier := ca.issue(t, "node-2", ca.path)
+	message := []byte("message")
+
+	chain, signature, err := signer.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifier.VerifySigned(chain, message, signature); err != nil {
+		t.Fatalf("expected the signature to verify: %v", err)
+	}
+
+	if _, err := verifier.VerifySigned(chain, []byte("other message"), signature); err == nil {
+		t.Fatal("expected the signature of another message to be rejected")
+	}
+
+	otherCA := newTestCA(t)
+	if _, err := otherCA.issue(t, "node-3", otherCA.path).VerifySigned(chain, message, signature); err == nil {
+		t.Fatal("expected a certificate of another CA to be rejected")
+	}
+
+	if _, err := verifier.VerifySigned(nil, message, signature); !errors.Is(err, errNoPeerCertificate) {
+		t.Fatalf("expected a missing certificate to be rejected, got %v", err)
+	}
+}
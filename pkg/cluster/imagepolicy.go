@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/distribution/reference"
+)
+
+// RegexImagePatternPrefix marks the image policy patterns that are regular
+// expressions rather than globs
+const RegexImagePatternPrefix = "regex:"
+
+// ImagePolicy restricts the images the workloads can run. The patterns
+// match the normalized repository of the image, e.g.
+// docker.io/library/nginx for nginx:latest, either as globs where *
+// matches any characters or as regular expressions prefixed with
+// RegexImagePatternPrefix
+type ImagePolicy struct {
+	// Images must match one of the patterns, any image is allowed if empty
+	Allow []string `json:"allow"`
+	// Images matching any of the patterns are denied, even if allowed
+	Deny []string `json:"deny"`
+
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// LoadImagePolicy reads the image policy of the cluster, a JSON object
+// with the allow and deny patterns
+func LoadImagePolicy(path string) (*ImagePolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image policy %s: %w", path, err)
+	}
+
+	var policy ImagePolicy
+	if err := json.Unmarshal(contents, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse image policy %s: %w", path, err)
+	}
+
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid image policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+func (p *ImagePolicy) compile() error {
+	var err error
+
+	if p.allow, err = compileImagePatterns(p.Allow); err != nil {
+		return err
+	}
+
+	p.deny, err = compileImagePatterns(p.Deny)
+
+	return err
+}
+
+func compileImagePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		expr, ok := strings.CutPrefix(pattern, RegexImagePatternPrefix)
+		if !ok {
+			expr = "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image pattern %q: %w", pattern, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// check returns a POLICY_DENIED error if the image isn't allowed, with
+// the repository and the deny pattern it matches or the allow patterns
+// it doesn't as details
+func (p *ImagePolicy) check(imageRef string) error {
+	named, err := reference.ParseDockerRef(imageRef)
+	if err != nil {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"image_ref": err.Error()}, "invalid image reference %q: %v", imageRef, err)
+	}
+
+	repository := named.Name()
+	details := map[string]string{"image": imageRef, "repository": repository}
+
+	for i, re := range p.deny {
+		if re.MatchString(repository) {
+			details["deny"] = p.Deny[i]
+
+			return newClusterError(pb.ErrorCode_POLICY_DENIED, details, "image %s is denied by the image policy pattern %q", imageRef, p.Deny[i])
+		}
+	}
+
+	if len(p.allow) == 0 {
+		return nil
+	}
+
+	for _, re := range p.allow {
+		if re.MatchString(repository) {
+			return nil
+		}
+	}
+
+	details["allow"] = strings.Join(p.Allow, ",")
+
+	return newClusterError(pb.ErrorCode_POLICY_DENIED, details, "image %s matches none of the allowed image policy patterns", imageRef)
+}
+
+// checkImagePolicy checks the images of the spawn request, of its init
+// steps and of its pod containers against the image policy of the node,
+// recording violations as POLICY_VIOLATION events and alerts
+func (a *Agent) checkImagePolicy(req *pb.VmSpawnRequest) error {
+	if a.imagePolicy == nil {
+		return nil
+	}
+
+	images := []string{req.GetImageRef()}
+	for _, step := range req.GetInitSteps() {
+		if step.GetImageRef() != "" {
+			images = append(images, step.GetImageRef())
+		}
+	}
+
+	for _, container := range req.GetContainers() {
+		if container.GetImageRef() != "" {
+			images = append(images, container.GetImageRef())
+		}
+	}
+
+	for _, image := range images {
+		err := a.imagePolicy.check(image)
+		if err == nil {
+			continue
+		}
+
+		if errorCode(err) != pb.ErrorCode_POLICY_DENIED {
+			return err
+		}
+
+		a.logger.Warnf("Denying spawn of tenant %q: %v", req.GetTenant(), err)
+
+		a.publishEvent(&pb.WatchEventsResponse{
+			Event:        pb.ClusterEvent_POLICY_VIOLATION,
+			Node:         &pb.Node{Id: a.cfg.NodeName},
+			Id:           image,
+			Message:      err.Error(),
+			ReplicaGroup: req.GetReplicaGroup(),
+		})
+
+		a.alert(AlertPolicyViolation, image, "spawn of tenant %q denied: %v", req.GetTenant(), err)
+
+		return err
+	}
+
+	return nil
+}
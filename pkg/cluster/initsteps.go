@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// initTracker holds the progress of the init steps of the local workloads
+// until they completed, or until a workload whose steps failed is deleted
+type initTracker struct {
+	mu       sync.Mutex
+	statuses map[string]*pb.InitStatus
+}
+
+func newInitTracker() *initTracker {
+	return &initTracker{statuses: make(map[string]*pb.InitStatus)}
+}
+
+// start tracks a workload about to be created with init steps
+func (t *initTracker) start(id string, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.statuses[id] = &pb.InitStatus{Total: uint32(total)}
+}
+
+func (t *initTracker) update(id string, progress vcontainerd.InitProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.statuses[id]
+	if !ok {
+		return
+	}
+
+	if progress.Done && progress.Err == nil {
+		delete(t.statuses, id)
+
+		return
+	}
+
+	status.Completed = uint32(progress.Completed)
+
+	if progress.Err != nil {
+		status.Failure = progress.Err.Error()
+		// Failures of steps that couldn't run count as a failure exit
+		status.ExitStatus = 1
+
+		var stepErr *vcontainerd.InitStepError
+		if errors.As(progress.Err, &stepErr) && stepErr.ExitStatus != 0 {
+			status.ExitStatus = stepErr.ExitStatus
+		}
+	}
+}
+
+// status returns the progress of the init steps of a workload, false if
+// they aren't tracked, i.e. completed or lost by an agent restart
+func (t *initTracker) status(id string) (*pb.InitStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.statuses[id]
+	if !ok {
+		return nil, false
+	}
+
+	return proto.Clone(status).(*pb.InitStatus), true
+}
+
+func (t *initTracker) done(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.statuses, id)
+}
+
+// initSteps converts the init steps of a spawn request, expanding the
+// templates of their environment like the workload's
+func initSteps(req *pb.VmSpawnRequest, data WorkloadEnvData) ([]vcontainerd.InitStep, error) {
+	steps := make([]vcontainerd.InitStep, 0, len(req.GetInitSteps()))
+
+	for _, step := range req.GetInitSteps() {
+		env, err := expandWorkloadEnv(step.GetEnv(), data)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, vcontainerd.InitStep{
+			ImageRef: step.GetImageRef(),
+			Command:  step.GetCommand(),
+			Env:      workloadEnv(env),
+			Timeout:  time.Duration(step.GetTimeoutSeconds()) * time.Second,
+		})
+	}
+
+	return steps, nil
+}
+
+// reportInitProgress records the progress of the init steps of a workload,
+// publishing an ERROR event if one failed
+func (a *Agent) reportInitProgress(id, replicaGroup string, progress vcontainerd.InitProgress) {
+	a.inits.update(id, progress)
+
+	if progress.Err == nil {
+		return
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_ERROR,
+		Node:         &pb.Node{Id: a.cfg.NodeName},
+		Id:           id,
+		Message:      fmt.Sprintf("init steps failed: %s", progress.Err),
+		ReplicaGroup: replicaGroup,
+	})
+}
+
+// failedInit returns the status of the init steps of a workload whose
+// task was created but not started if they failed, or if they were lost
+// by an agent restart, nil otherwise
+func (a *Agent) failedInit(id string, spec *pb.VmSpawnRequest, created bool) *pb.InitStatus {
+	if len(spec.GetInitSteps()) == 0 {
+		return nil
+	}
+
+	status, ok := a.inits.status(id)
+	if !ok {
+		if !created {
+			return nil
+		}
+
+		return &pb.InitStatus{Total: uint32(len(spec.GetInitSteps())), Failure: "init steps interrupted by an agent restart", ExitStatus: 1}
+	}
+
+	if status.GetFailure() == "" {
+		return nil
+	}
+
+	return status
+}
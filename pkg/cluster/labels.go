@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// Prefixes of the container labels holding the user labels and
+	// annotations of a workload, so other containerd clients see them
+	UserLabelPrefix  = "hypercore-label."
+	AnnotationPrefix = "hypercore-annotation."
+
+	// Annotations are kept in the spawn request label too, which
+	// containerd caps at 4KiB along with the rest of the request
+	MaxAnnotationsSize = 1024
+)
+
+// setWorkloadLabels stores the user labels and annotations of a workload
+// in the labels of its container
+func setWorkloadLabels(labels map[string]string, payload *pb.VmSpawnRequest) {
+	for key, value := range payload.GetLabels() {
+		labels[UserLabelPrefix+key] = value
+	}
+
+	for key, value := range payload.GetAnnotations() {
+		labels[AnnotationPrefix+key] = value
+	}
+}
+
+// storedLabels returns the labels of a container under the prefix, or the
+// fallback for containers created before they were stored
+func storedLabels(labels map[string]string, prefix string, fallback map[string]string) map[string]string {
+	stored := make(map[string]string)
+
+	for key, value := range labels {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			stored[name] = value
+		}
+	}
+
+	if len(stored) == 0 {
+		return maps.Clone(fallback)
+	}
+
+	return stored
+}
+
+// workloadLabels returns the labels workloads are selected by, nodes that
+// don't report them along the workload only have them in its spec
+func workloadLabels(workload *pb.WorkloadState) map[string]string {
+	if len(workload.GetLabels()) > 0 {
+		return workload.GetLabels()
+	}
+
+	return workload.GetSourceRequest().GetLabels()
+}
+
+// parseRequiredSelector parses the selector of a request acting on every
+// workload it matches, which must have requirements so it doesn't match
+// all of them
+func parseRequiredSelector(selector string) (labelSelector, error) {
+	parsed, err := parseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsed) == 0 {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"label_selector": selector},
+			"label selector %q has no requirements", selector)
+	}
+
+	return parsed, nil
+}
+
+// selectedWorkloads returns the IDs of the workloads across the cluster of
+// the tenant, if any, matching the selector
+func (a *Agent) selectedWorkloads(tenant string, selector labelSelector) []string {
+	var ids []string
+
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if tenant != "" && workload.GetSourceRequest().GetTenant() != tenant {
+				continue
+			}
+
+			if selector.matches(workloadLabels(workload)) {
+				ids = append(ids, workload.GetId())
+			}
+		}
+	}
+
+	slices.Sort(ids)
+
+	return slices.Compact(ids)
+}
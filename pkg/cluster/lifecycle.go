@@ -0,0 +1,296 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Serf tag advertising the lifecycle status of the node
+const NodeStatusTag = "status"
+
+var nodeStatusTags = map[pb.NodeStatus]string{
+	pb.NodeStatus_NODE_JOINING:   "joining",
+	pb.NodeStatus_NODE_READY:     "ready",
+	pb.NodeStatus_NODE_DRAINING:  "draining",
+	pb.NodeStatus_NODE_LEAVING:   "leaving",
+	pb.NodeStatus_NODE_UPGRADING: "upgrading",
+}
+
+// memberNodeStatus returns the status of a member, failure detection and
+// serf leaves take precedence over the status the node advertises
+func memberNodeStatus(member serf.Member) pb.NodeStatus {
+	switch member.Status {
+	case serf.StatusFailed:
+		return pb.NodeStatus_NODE_FAILED
+	case serf.StatusLeaving, serf.StatusLeft:
+		return pb.NodeStatus_NODE_LEAVING
+	case serf.StatusAlive, serf.StatusNone:
+	}
+
+	for status, tag := range nodeStatusTags {
+		if member.Tags[NodeStatusTag] == tag {
+			return status
+		}
+	}
+
+	// Nodes that predate lifecycle statuses are ready once alive
+	return pb.NodeStatus_NODE_READY
+}
+
+// nodeStatus returns the status of the node with the given name
+func (a *Agent) nodeStatus(name string) pb.NodeStatus {
+	member := a.findMember(name)
+	if member == nil {
+		return pb.NodeStatus_NODE_STATUS_UNKNOWN
+	}
+
+	return memberNodeStatus(*member)
+}
+
+func (a *Agent) localNodeStatus() pb.NodeStatus {
+	return memberNodeStatus(a.serf.LocalMember())
+}
+
+// setNodeStatus advertises the new status of this node to the cluster
+func (a *Agent) setNodeStatus(status pb.NodeStatus) error {
+	if err := a.setTags(map[string]string{NodeStatusTag: nodeStatusTags[status]}); err != nil {
+		return fmt.Errorf("failed to advertise node status %s: %w", status, err)
+	}
+
+	a.logger.Infof("Node is now %s", nodeStatusTags[status])
+	a.publishNodeStatus(a.serf.LocalMember())
+
+	return nil
+}
+
+func (a *Agent) publishNodeStatus(member serf.Member) {
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:   pb.ClusterEvent_NODE_STATUS,
+		Node:    memberNode(member),
+		Id:      member.Name,
+		Message: "node is " + memberNodeStatus(member).String(),
+	})
+}
+
+func memberNode(member serf.Member) *pb.Node {
+	maintenanceStart, maintenanceEnd := memberMaintenance(member)
+
+	return &pb.Node{
+		Id:                       member.Name,
+		Ip:                       member.Addr.String(),
+		Status:                   memberNodeStatus(member),
+		Capabilities:             memberCapabilities(member),
+		StartedUnixTime:          memberStartedAt(member),
+		MaintenanceStartUnixTime: maintenanceStart,
+		MaintenanceEndUnixTime:   maintenanceEnd,
+	}
+}
+
+// readyNodes leaves out the nodes workloads can't be placed on, along
+// with those entering maintenance
+func (a *Agent) readyNodes(nodes []string) []string {
+	now := time.Now()
+
+	return slices.DeleteFunc(nodes, func(node string) bool {
+		if status := a.nodeStatus(node); status != pb.NodeStatus_NODE_READY {
+			a.logger.Infof("Not placing workload on node %s, it is %s", node, status)
+
+			return true
+		}
+
+		if member := a.findMember(node); member != nil && a.enteringMaintenance(*member, now) {
+			a.logger.Infof("Not placing workload on node %s, it is entering maintenance", node)
+
+			return true
+		}
+
+		return false
+	})
+}
+
+// MarkReady makes a node that isn't joining an existing cluster ready
+func (a *Agent) MarkReady() error {
+	if a.localNodeStatus() != pb.NodeStatus_NODE_JOINING {
+		return nil
+	}
+
+	return a.setNodeStatus(pb.NodeStatus_NODE_READY)
+}
+
+func (a *Agent) Join(addr string) error {
+	if status := a.localNodeStatus(); status != pb.NodeStatus_NODE_JOINING && status != pb.NodeStatus_NODE_READY {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "node is %s, only joining and ready nodes can join a cluster", status)
+	}
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_JOINING); err != nil {
+		return err
+	}
+
+	if _, err := a.serf.Join([]string{addr}, true); err != nil {
+		return err
+	}
+
+	return a.setNodeStatus(pb.NodeStatus_NODE_READY)
+}
+
+// NodesRequest returns the members of the cluster with their status
+func (a *Agent) NodesRequest(_ *pb.NodesRequest) *pb.NodesResponse {
+	resp := &pb.NodesResponse{}
+
+	for _, member := range a.serf.Members() {
+		resp.Nodes = append(resp.Nodes, memberNode(member))
+	}
+
+	sort.Slice(resp.GetNodes(), func(i, j int) bool {
+		return resp.GetNodes()[i].GetId() < resp.GetNodes()[j].GetId()
+	})
+
+	return resp
+}
+
+// JoinRequest makes this node join the cluster of the node at the address
+func (a *Agent) JoinRequest(req *pb.JoinRequest) (*pb.Node, error) {
+	if req.GetAddress() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "address of a node to join is required")
+	}
+
+	if err := a.Join(req.GetAddress()); err != nil {
+		return nil, fmt.Errorf("failed to join %s: %w", req.GetAddress(), err)
+	}
+
+	return memberNode(a.serf.LocalMember()), nil
+}
+
+// DrainRequest stops placing workloads on the node and replaces each of
+// its workloads by a healthy replica on another node before stopping it,
+// forwarding the request to the node if it isn't this one
+func (a *Agent) DrainRequest(ctx context.Context, req *pb.DrainRequest) (*pb.Node, error) {
+	if req.GetNode() != "" && req.GetNode() != a.cfg.NodeName {
+		client, closeConn, err := a.nodeClient(req.GetNode())
+		if err != nil {
+			return nil, err
+		}
+		defer closeConn()
+
+		return client.Drain(forwardedContext(ctx), req)
+	}
+
+	switch a.localNodeStatus() {
+	case pb.NodeStatus_NODE_DRAINING:
+		return memberNode(a.serf.LocalMember()), nil
+	case pb.NodeStatus_NODE_READY:
+	default:
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "node is %s, only ready nodes can be drained", a.localNodeStatus())
+	}
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_DRAINING); err != nil {
+		return nil, err
+	}
+
+	go a.drainWorkloads()
+
+	return memberNode(a.serf.LocalMember()), nil
+}
+
+// drainWorkloads moves the local workloads to the other nodes, each keeps
+// serving requests until its replacement is healthy
+func (a *Agent) drainWorkloads() {
+	workloads := slices.Clone(a.states.localState().GetWorkloads())
+
+	a.logger.Infof("Draining %d workloads", len(workloads))
+
+	failed := 0
+
+	for _, workload := range workloads {
+		spec := proto.Clone(workload.GetSourceRequest()).(*pb.VmSpawnRequest)
+		spec.DryRun = false
+		spec.IdempotencyKey = ""
+
+		spawned, err := a.spawnHealthy(spec)
+		if err != nil {
+			a.logger.WithError(err).Errorf("failed to move workload %s off the draining node", workload.GetId())
+			failed++
+
+			continue
+		}
+
+		if _, err := a.StopRequest(&pb.VmStopRequest{Id: workload.GetId(), WorkloadOnly: true}); err != nil {
+			a.logger.WithError(err).Errorf("failed to stop drained workload %s", workload.GetId())
+		}
+
+		a.logger.Infof("Moved workload %s to %s", workload.GetId(), spawned)
+	}
+
+	message := "node is drained"
+	if failed > 0 {
+		message = fmt.Sprintf("node is drained, %d workloads couldn't be moved and keep running on it", failed)
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:   pb.ClusterEvent_NODE_STATUS,
+		Node:    memberNode(a.serf.LocalMember()),
+		Id:      a.cfg.NodeName,
+		Message: message,
+	})
+}
+
+// LeaveRequest makes the node leave the cluster, nodes still running
+// workloads must be drained first unless the request forces it
+func (a *Agent) LeaveRequest(ctx context.Context, req *pb.LeaveRequest) (*pb.Node, error) {
+	if req.GetNode() != "" && req.GetNode() != a.cfg.NodeName {
+		client, closeConn, err := a.nodeClient(req.GetNode())
+		if err != nil {
+			return nil, err
+		}
+		defer closeConn()
+
+		return client.Leave(forwardedContext(ctx), req)
+	}
+
+	running := len(a.states.localState().GetWorkloads())
+
+	if running > 0 && !req.GetForce() {
+		return nil, status.Errorf(codes.FailedPrecondition, "%d workloads still run on node %s, drain it first or force it to leave", running, a.cfg.NodeName)
+	}
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_LEAVING); err != nil {
+		return nil, err
+	}
+
+	node := memberNode(a.serf.LocalMember())
+
+	if err := a.serf.Leave(); err != nil {
+		return nil, fmt.Errorf("failed to leave the cluster: %w", err)
+	}
+
+	return node, nil
+}
+
+// nodeClient connects to the gRPC server of the node with the given name
+func (a *Agent) nodeClient(name string) (pb.ClusterServiceClient, func(), error) {
+	member := a.findMember(name)
+	if member == nil {
+		return nil, nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no node found for %s", name)
+	}
+
+	if member.Tags[GrpcPortTag] == "" {
+		return nil, nil, fmt.Errorf("node %s is not reachable", name)
+	}
+
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pb.NewClusterServiceClient(conn), func() { conn.Close() }, nil
+}
@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"regexp"
+	"slices"
+	"strings"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// Workloads returned per List page when the request sets no limit
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// Label keys, optionally prefixed like DNS subdomains (example.com/key)
+var labelKeyRegexp = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+type labelRequirement struct {
+	key string
+	// one of =, !=, exists or !exists
+	operator string
+	value    string
+}
+
+// labelSelector holds requirements that must all be met
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses comma separated requirements, each of them
+// key=value, key==value, key!=value, key or !key
+func parseLabelSelector(selector string) (labelSelector, error) {
+	var parsed labelSelector
+
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var requirement labelRequirement
+
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			requirement = labelRequirement{key: key, operator: "!=", value: value}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			requirement = labelRequirement{key: key, operator: "=", value: strings.TrimPrefix(value, "=")}
+		case strings.HasPrefix(term, "!"):
+			requirement = labelRequirement{key: term[1:], operator: "!exists"}
+		default:
+			requirement = labelRequirement{key: term, operator: "exists"}
+		}
+
+		requirement.key = strings.TrimSpace(requirement.key)
+		requirement.value = strings.TrimSpace(requirement.value)
+
+		if !labelKeyRegexp.MatchString(requirement.key) {
+			return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"label_selector": selector},
+				"invalid label key %q in selector", requirement.key)
+		}
+
+		parsed = append(parsed, requirement)
+	}
+
+	return parsed, nil
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		value, ok := labels[requirement.key]
+
+		var met bool
+
+		switch requirement.operator {
+		case "=":
+			met = ok && value == requirement.value
+		case "!=":
+			met = !ok || value != requirement.value
+		case "exists":
+			met = ok
+		case "!exists":
+			met = !ok
+		}
+
+		if !met {
+			return false
+		}
+	}
+
+	return true
+}
+
+func encodePageToken(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastID))
+}
+
+func decodePageToken(token string) (string, error) {
+	lastID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "invalid page token %q", token)
+	}
+
+	return string(lastID), nil
+}
+
+// ListRequest returns the workloads running across the cluster matching
+// the filters of req, a page at a time in the order of their IDs. Spawns
+// waiting for capacity are listed as pending along with them
+func (a *Agent) ListRequest(req *pb.VmQueryRequest) (*pb.VmQueryResponse, error) {
+	selector, err := parseLabelSelector(req.GetLabelSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+
+	matching := make(map[string]*pb.VmSpawnRequest)
+	pending := make(map[string]*pb.PendingSpawn)
+
+	for _, state := range a.knownStates() {
+		if req.GetNode() != "" && state.GetNode().GetId() != req.GetNode() {
+			continue
+		}
+
+		for _, workload := range state.GetWorkloads() {
+			spec := workload.GetSourceRequest()
+
+			if after != "" && workload.GetId() <= after {
+				continue
+			}
+
+			if req.GetTenant() != "" && spec.GetTenant() != req.GetTenant() {
+				continue
+			}
+
+			if !selector.matches(workloadLabels(workload)) {
+				continue
+			}
+
+			switch req.GetStatus() {
+			case pb.WorkloadStatus_READY:
+				if !workloadReady(workload) {
+					continue
+				}
+			case pb.WorkloadStatus_NOT_READY:
+				if workloadReady(workload) {
+					continue
+				}
+			case pb.WorkloadStatus_PENDING:
+				continue
+			case pb.WorkloadStatus_ANY_STATUS:
+			}
+
+			matching[workload.GetId()] = spec
+		}
+
+		if req.GetStatus() != pb.WorkloadStatus_ANY_STATUS && req.GetStatus() != pb.WorkloadStatus_PENDING {
+			continue
+		}
+
+		for _, spawn := range state.GetPendingSpawns() {
+			if after != "" && spawn.GetId() <= after {
+				continue
+			}
+
+			if req.GetTenant() != "" && spawn.GetSpec().GetTenant() != req.GetTenant() {
+				continue
+			}
+
+			if !selector.matches(spawn.GetSpec().GetLabels()) {
+				continue
+			}
+
+			pending[spawn.GetId()] = spawn
+		}
+	}
+
+	ids := make([]string, 0, len(matching)+len(pending))
+	for id := range matching {
+		ids = append(ids, id)
+	}
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	resp := &pb.VmQueryResponse{Vms: make(map[string]*pb.VmSpawnRequest), Pending: make(map[string]*pb.PendingSpawn)}
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+		resp.NextPageToken = encodePageToken(ids[limit-1])
+	}
+
+	for _, id := range ids {
+		if spec, ok := matching[id]; ok {
+			resp.Vms[id] = spec
+		} else {
+			resp.Pending[id] = pending[id]
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd/cio"
+	"github.com/hashicorp/serf/serf"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	WorkloadLogDir = defaults.StateRootDir + "/logs"
+	// Amount of logs returned when the request doesn't specify it
+	DefaultLogTailBytes = 64 * 1024
+	// Size the log files of the workloads are capped at by default, the
+	// log dir being on a tmpfs
+	DefaultMaxLogSize = 16 << 20
+	// Period the sizes of the workload logs are checked at
+	LogTrimPeriod = 10 * time.Second
+)
+
+// logFileCreator writes the stdout and stderr of each
+// container to <dir>/<container ID>.log
+func logFileCreator(dir string) cio.Creator {
+	return func(id string) (cio.IO, error) {
+		if err := os.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+			return nil, fmt.Errorf("failed to create log dir %s: %w", dir, err)
+		}
+
+		return cio.LogFile(workloadLogPath(dir, id))(id)
+	}
+}
+
+func workloadLogPath(dir, id string) string {
+	return filepath.Join(dir, filepath.Base(id)+".log")
+}
+
+func removeWorkloadLogs(dir, id string) {
+	_ = os.Remove(workloadLogPath(dir, id))
+}
+
+// trimWorkloadLogs periodically frees the oldest half of the workload logs
+// grown past the size cap
+func (a *Agent) trimWorkloadLogs() {
+	ticker := time.NewTicker(LogTrimPeriod)
+	for range ticker.C {
+		entries, err := os.ReadDir(a.logDir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				a.logger.WithError(err).Error("failed to list workload logs")
+			}
+
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".log" {
+				continue
+			}
+
+			if err := trimLog(filepath.Join(a.logDir, entry.Name()), a.maxLogSize); err != nil {
+				a.logger.WithError(err).Warnf("failed to trim workload log %s", entry.Name())
+			}
+		}
+	}
+}
+
+// trimLog frees the oldest half of a log grown past the size. The shims
+// append to the logs themselves, so rewriting the file would lose what
+// they write in the meantime: the oldest pages are punched out instead,
+// the file keeping its size and reading as a hole up to the kept lines
+func trimLog(path string, maxSize int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	start, end, err := logExtent(file)
+	if err != nil || end-start <= maxSize {
+		return err
+	}
+
+	// Holes are punched in whole pages, so the first kept line may be
+	// partial like the tail of a log usually is
+	page := int64(os.Getpagesize())
+	keepFrom := (end - maxSize/2) / page * page
+
+	if err := unix.Fallocate(int(file.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, 0, keepFrom); err != nil {
+		return fmt.Errorf("failed to punch the start of the log: %w", err)
+	}
+
+	return nil
+}
+
+// logExtent returns the offsets the data of a log starts and ends at, it
+// starts past the hole of a trimmed log
+func logExtent(file *os.File) (int64, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start, err := file.Seek(0, unix.SEEK_DATA)
+	if errors.Is(err, unix.ENXIO) {
+		// Nothing but a hole
+		return info.Size(), info.Size(), nil
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return start, info.Size(), nil
+}
+
+func readLogTail(path string, tailBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	start, end, err := logExtent(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(max(end-tailBytes, start), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(file)
+}
+
+// LogsRequest returns the logs of a workload, forwarding the request
+// to the gRPC server of the node running it if it isn't local
+func (a *Agent) LogsRequest(ctx context.Context, req *pb.VmLogsRequest) (*pb.VmLogsResponse, error) {
+	tailBytes := int64(req.GetTailBytes())
+	if tailBytes == 0 {
+		tailBytes = DefaultLogTailBytes
+	}
+
+	logs, err := readLogTail(workloadLogPath(a.logDir, req.GetId()), tailBytes)
+	if err == nil {
+		return &pb.VmLogsResponse{Logs: logs}, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read logs for %s: %w", req.GetId(), err)
+	}
+
+	nodeName := a.workloadNode(req.GetId())
+	if nodeName == "" {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
+
+	member := a.findMember(nodeName)
+	if member == nil || member.Tags[GrpcPortTag] == "" {
+		return nil, fmt.Errorf("node %s running workload %s is not reachable", nodeName, req.GetId())
+	}
+
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return pb.NewClusterServiceClient(conn).Logs(forwardedContext(ctx), req)
+}
+
+// SelectedLogsRequest returns the logs of every workload across the
+// cluster matching the label selector of req, skipping those stopped in
+// the meantime
+func (a *Agent) SelectedLogsRequest(ctx context.Context, req *pb.VmLogsRequest) (*pb.VmLogsResponse, error) {
+	selector, err := parseRequiredSelector(req.GetLabelSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := a.selectedWorkloads(req.GetTenant(), selector)
+	if len(ids) == 0 {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload matches %s", req.GetLabelSelector())
+	}
+
+	resp := &pb.VmLogsResponse{WorkloadLogs: make(map[string][]byte, len(ids))}
+
+	for _, id := range ids {
+		logs, err := a.LogsRequest(ctx, &pb.VmLogsRequest{Id: id, TailBytes: req.GetTailBytes()})
+		// Forwarded requests fail with the status of the cluster error
+		if errorCode(err) == pb.ErrorCode_NOT_FOUND || status.Code(err) == codes.NotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs for %s: %w", id, err)
+		}
+
+		resp.WorkloadLogs[id] = logs.GetLogs()
+	}
+
+	return resp, nil
+}
+
+// dialNode connects to the gRPC server of a remote node
+func (a *Agent) dialNode(member *serf.Member) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if a.grpcClientTLS != nil {
+		creds = credentials.NewTLS(a.grpcClientTLS)
+	}
+
+	callOpts := []grpc.CallOption{grpc.MaxCallRecvMsgSize(defaults.GrpcMaxMessageSize)}
+	if a.grpcCompression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(a.grpcCompression))
+	}
+
+	return grpc.NewClient(net.JoinHostPort(member.Addr.String(), member.Tags[GrpcPortTag]),
+		grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(callOpts...))
+}
+
+// forwardedContext forwards any credentials the request came in with
+func forwardedContext(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return metadata.NewOutgoingContext(ctx, md)
+	}
+
+	return ctx
+}
+
+// workloadNode returns the name of the remote node last
+// reported to be running the workload
+func (a *Agent) workloadNode(id string) string {
+	return a.states.workloadNode(id)
+}
@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// checkLogLines returns the number of the last line of a log, and an
+// error if lines are missing between its first full one and its last one
+func checkLogLines(logs []byte) (int, error) {
+	// The first line may be partial, and the last one still written
+	lines := bytes.Split(logs, []byte("\n"))
+	if len(lines) < 3 {
+		return -1, nil
+	}
+
+	last := -1
+
+	for _, line := range lines[1 : len(lines)-1] {
+		number, err := strconv.Atoi(string(line))
+		if err != nil {
+			return last, fmt.Errorf("corrupted log line %q", line)
+		}
+
+		if last != -1 && number != last+1 {
+			return last, fmt.Errorf("lines %d to %d were lost", last+1, number-1)
+		}
+
+		last = number
+	}
+
+	return last, nil
+}
+
+func TestTrimLog(t *testing.T) {
+	const maxSize = 64 * 1024
+
+	path := filepath.Join(t.TempDir(), "workload.log")
+
+	var written bytes.Buffer
+	for i := range 10000 {
+		fmt.Fprintf(&written, "%07d\n", i)
+	}
+
+	if err := os.WriteFile(path, written.Bytes()[:maxSize], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := trimLog(path, maxSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if logs, err := readLogTail(path, math.MaxInt64); err != nil || !bytes.Equal(logs, written.Bytes()[:maxSize]) {
+		t.Fatalf("expected a log within the size to be left as is, got %d bytes (%v)", len(logs), err)
+	}
+
+	if err := os.WriteFile(path, written.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := trimLog(path, maxSize); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := readLogTail(path, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) < maxSize/2 || len(logs) > maxSize/2+os.Getpagesize() {
+		t.Fatalf("expected the last half of the log to be kept, got %d bytes", len(logs))
+	}
+
+	if !bytes.HasSuffix(written.Bytes(), logs) {
+		t.Fatal("expected the kept part of the log to be its end")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The shims keep appending at the same offset
+	if info.Size() != int64(written.Len()) {
+		t.Fatalf("expected the log to keep its size of %d, got %d", written.Len(), info.Size())
+	}
+
+	if tail, err := readLogTail(path, 8); err != nil || string(tail) != "0009999\n" {
+		t.Fatalf("expected the last line of the trimmed log, got %q (%v)", tail, err)
+	}
+}
+
+// Lines appended while the log is trimmed aren't lost. The log is checked
+// before each trim, since the lines around those lost would be trimmed
+// eventually
+func TestTrimLogConcurrentWrites(t *testing.T) {
+	const (
+		maxSize = 64 * 1024
+		lines   = 200000
+	)
+
+	path := filepath.Join(t.TempDir(), "workload.log")
+
+	// Appending like the shims do
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			logs, err := readLogTail(path, math.MaxInt64)
+			if err == nil {
+				_, err = checkLogLines(logs)
+			}
+
+			if err == nil {
+				err = trimLog(path, maxSize)
+			}
+
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+		}
+	}()
+
+	for i := range lines {
+		if _, err := fmt.Fprintf(file, "%07d\n", i); err != nil {
+			t.Fatal(err)
+		}
+
+		// Give the trims the time to happen while writing
+		if i%1000 == 0 {
+			runtime.Gosched()
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	logs, err := readLogTail(path, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) > maxSize+os.Getpagesize() {
+		t.Fatalf("expected the log to be trimmed to about %d bytes, got %d", maxSize, len(logs))
+	}
+
+	last, err := checkLogLines(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last != lines-1 {
+		t.Fatalf("expected the log to end with line %d, got %d", lines-1, last)
+	}
+}
@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// Serf tag advertising the current or next maintenance window of the
+	// node, as START-END in unix seconds
+	MaintenanceTag = "maintenance"
+
+	// New workloads stop being placed on a node this long before its
+	// maintenance window starts
+	DefaultMaintenanceLead = time.Minute * 30
+)
+
+// MaintenanceConfig is the schedule of the maintenance windows of a node
+type MaintenanceConfig struct {
+	// Cron expressions (minute hour day-of-month month day-of-week, in
+	// the local time of the node) of the starts of the windows
+	Windows []string
+	// Length of each window
+	Duration time.Duration
+	// Drain the node when a window starts and make it ready again when
+	// it ends
+	Drain bool
+}
+
+func (c *MaintenanceConfig) enabled() bool {
+	return c != nil && len(c.Windows) > 0
+}
+
+func (c *MaintenanceConfig) schedules() ([]*cronSchedule, error) {
+	if c.Duration <= 0 {
+		return nil, errors.New("maintenance window duration must be positive")
+	}
+
+	schedules := make([]*cronSchedule, 0, len(c.Windows))
+
+	for _, window := range c.Windows {
+		schedule, err := parseCron(window)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// nextMaintenanceWindow returns the window in progress at now if any,
+// the next one otherwise, zero if none of the schedules ever matches
+func nextMaintenanceWindow(schedules []*cronSchedule, duration time.Duration, now time.Time) (time.Time, time.Time) {
+	var start time.Time
+
+	for _, schedule := range schedules {
+		// The window in progress started within the last duration
+		windowStart := schedule.next(now.Add(-duration))
+		if !windowStart.IsZero() && (start.IsZero() || windowStart.Before(start)) {
+			start = windowStart
+		}
+	}
+
+	if start.IsZero() {
+		return start, start
+	}
+
+	return start, start.Add(duration)
+}
+
+// memberMaintenance returns the maintenance window a member advertises,
+// zero if it has none
+func memberMaintenance(member serf.Member) (int64, int64) {
+	startTag, endTag, ok := strings.Cut(member.Tags[MaintenanceTag], "-")
+	if !ok {
+		return 0, 0
+	}
+
+	start, err := strconv.ParseInt(startTag, 10, 64)
+	if err != nil {
+		return 0, 0
+	}
+
+	end, err := strconv.ParseInt(endTag, 10, 64)
+	if err != nil {
+		return 0, 0
+	}
+
+	return start, end
+}
+
+// enteringMaintenance returns whether new workloads shouldn't be placed
+// on a member, its maintenance window being in progress or about to start
+func (a *Agent) enteringMaintenance(member serf.Member, now time.Time) bool {
+	start, end := memberMaintenance(member)
+	if start == 0 {
+		return false
+	}
+
+	return !now.Add(a.maintenanceLead).Before(time.Unix(start, 0)) && now.Before(time.Unix(end, 0))
+}
+
+// setTags advertises new values of the tags of this node, concurrent
+// updates of different tags all make it
+func (a *Agent) setTags(update map[string]string) error {
+	a.tagsMu.Lock()
+	defer a.tagsMu.Unlock()
+
+	tags := maps.Clone(a.serf.LocalMember().Tags)
+	maps.Copy(tags, update)
+
+	return a.serf.SetTags(tags)
+}
+
+// runMaintenanceWindows advertises the maintenance windows of the node
+// one after the other, draining it while they last if configured to.
+// Overlapping windows are a single one
+func (a *Agent) runMaintenanceWindows(cfg *MaintenanceConfig, schedules []*cronSchedule) {
+	drained := false
+
+	for {
+		start, end := nextMaintenanceWindow(schedules, cfg.Duration, time.Now())
+		if start.IsZero() {
+			a.logger.Warn("None of the maintenance windows is ever starting")
+
+			return
+		}
+
+		if err := a.setTags(map[string]string{MaintenanceTag: fmt.Sprintf("%d-%d", start.Unix(), end.Unix())}); err != nil {
+			a.logger.WithError(err).Error("failed to advertise the maintenance window")
+		}
+
+		a.logger.Infof("Next maintenance window from %s to %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+		time.Sleep(time.Until(start))
+
+		if cfg.Drain && !drained {
+			drained = a.drainForMaintenance()
+		}
+
+		time.Sleep(time.Until(end))
+
+		if next, _ := nextMaintenanceWindow(schedules, cfg.Duration, time.Now()); !next.IsZero() && !next.After(time.Now()) {
+			continue
+		}
+
+		a.publishEvent(&pb.WatchEventsResponse{
+			Event:   pb.ClusterEvent_NODE_STATUS,
+			Node:    memberNode(a.serf.LocalMember()),
+			Id:      a.cfg.NodeName,
+			Message: "maintenance window ended",
+		})
+
+		if drained && a.localNodeStatus() == pb.NodeStatus_NODE_DRAINING {
+			if err := a.setNodeStatus(pb.NodeStatus_NODE_READY); err != nil {
+				a.logger.WithError(err).Error("failed to mark node ready after its maintenance window")
+			}
+		}
+
+		drained = false
+	}
+}
+
+// drainForMaintenance drains the node at the start of a maintenance
+// window, returning whether it did. Nodes that aren't ready are left as
+// they are, e.g. those already drained by hand
+func (a *Agent) drainForMaintenance() bool {
+	if status := a.localNodeStatus(); status != pb.NodeStatus_NODE_READY {
+		a.logger.Infof("Not draining node for its maintenance window, it is %s", status)
+
+		return false
+	}
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_DRAINING); err != nil {
+		a.logger.WithError(err).Error("failed to drain node for its maintenance window")
+
+		return false
+	}
+
+	a.logger.Info("Draining node for its maintenance window")
+
+	go a.drainWorkloads()
+
+	return true
+}
@@ -0,0 +1,215 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/models"
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MetadataHeader must be set on the requests to the metadata service,
+	// so workloads can't be tricked into forwarding it a request
+	MetadataHeader = "Hypercore-Metadata"
+
+	metadataReadTimeout = 10 * time.Second
+)
+
+// workloadCredentials is the response of the credentials endpoint
+type workloadCredentials struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// metadataServer serves the local workloads their identity and short
+// lived credentials, the connections of their containers to
+// models.MetadataIP being redirected to it. Workloads are told apart by
+// the address of their connections like for the egress proxy
+type metadataServer struct {
+	agent    *Agent
+	logger   *log.Logger
+	listener net.Listener
+	port     uint16
+	sources  *workloadSourceCache
+	tokenTTL time.Duration
+
+	// Tokens are issued again once past half their lifetime, keyed by
+	// workload and audience
+	tokensMu sync.Mutex
+	tokens   map[credentialsKey]workloadCredentials
+}
+
+type credentialsKey struct {
+	id       string
+	audience string
+}
+
+func newMetadataServer(agent *Agent, addr string, tokenTTL time.Duration) (*metadataServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metadata service on %s: %w", addr, err)
+	}
+
+	return &metadataServer{
+		agent:    agent,
+		logger:   agent.logger,
+		listener: listener,
+		port:     uint16(listener.Addr().(*net.TCPAddr).Port),
+		sources:  newWorkloadSourceCache(agent),
+		tokenTTL: tokenTTL,
+		tokens:   make(map[credentialsKey]workloadCredentials),
+	}, nil
+}
+
+func (m *metadataServer) serve() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/workload", m.handleWorkload)
+	mux.HandleFunc("GET /v1/credentials", m.handleCredentials)
+	mux.HandleFunc("GET /v1/jwks", m.handleJWKS)
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: metadataReadTimeout}
+
+	if err := server.Serve(m.listener); err != nil {
+		m.logger.WithError(err).Error("metadata service stopped accepting connections")
+	}
+}
+
+// caller returns the local workload making the request, writing the
+// error response if there is none
+func (m *metadataServer) caller(w http.ResponseWriter, r *http.Request) (workloadSource, bool) {
+	if r.Header.Get(MetadataHeader) != "true" {
+		http.Error(w, fmt.Sprintf("the %s: true header is required", MetadataHeader), http.StatusForbidden)
+
+		return workloadSource{}, false
+	}
+
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return workloadSource{}, false
+	}
+
+	source, ok := m.sources.source(sourceIP)
+	if !ok {
+		m.logger.Warnf("metadata service rejected request from %s, not a local workload", sourceIP)
+		http.Error(w, "not a local workload", http.StatusForbidden)
+
+		return workloadSource{}, false
+	}
+
+	return source, true
+}
+
+func (m *metadataServer) handleWorkload(w http.ResponseWriter, r *http.Request) {
+	source, ok := m.caller(w, r)
+	if !ok {
+		return
+	}
+
+	writeMetadata(w, m.agent.workloadMetadata(source.id, source.spec, source.labels))
+}
+
+func (m *metadataServer) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	source, ok := m.caller(w, r)
+	if !ok {
+		return
+	}
+
+	audience := r.URL.Query().Get("audience")
+	if audience == "" {
+		http.Error(w, "the audience of the token is required", http.StatusBadRequest)
+
+		return
+	}
+
+	credentials, err := m.credentials(source, audience, time.Now())
+	if err != nil {
+		m.logger.WithError(err).Errorf("failed to issue credentials of workload %s", source.id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	writeMetadata(w, credentials)
+}
+
+// credentials returns the token of the workload for the audience, issuing
+// a new one once the previous one is past half its lifetime
+func (m *metadataServer) credentials(source workloadSource, audience string, now time.Time) (workloadCredentials, error) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+
+	key := credentialsKey{id: source.id, audience: audience}
+	if cached, ok := m.tokens[key]; ok && now.Before(cached.ExpiresAt.Add(-m.tokenTTL/2)) {
+		return cached, nil
+	}
+
+	claims := WorkloadClaims{
+		Issuer:    WorkloadTokenIssuer,
+		Subject:   m.agent.workloadSubject(source.id, source.tenant),
+		Audience:  audience,
+		Workload:  source.id,
+		Tenant:    source.tenant,
+		Node:      m.agent.cfg.NodeName,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(m.tokenTTL).Unix(),
+	}
+
+	token, err := m.agent.signingKeys.sign(claims)
+	if err != nil {
+		return workloadCredentials{}, err
+	}
+
+	// Drops the tokens of the workloads gone or not asking for them
+	// anymore along the way
+	for cachedKey, cached := range m.tokens {
+		if !now.Before(cached.ExpiresAt) {
+			delete(m.tokens, cachedKey)
+		}
+	}
+
+	credentials := workloadCredentials{Token: token, ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC()}
+	m.tokens[key] = credentials
+
+	m.logger.WithFields(log.Fields{"workload": source.id, "tenant": source.tenant, "audience": audience}).Debug("issued workload credentials")
+
+	return credentials, nil
+}
+
+// handleJWKS serves the keys of the cluster, so workloads can verify the
+// tokens of the others without reaching the gateway
+func (m *metadataServer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.caller(w, r); !ok {
+		return
+	}
+
+	writeMetadata(w, m.agent.JWKS())
+}
+
+func writeMetadata(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// workloadMetadata returns the identity of a workload spawned with the
+// spec, from the labels of its container if it has them
+func (a *Agent) workloadMetadata(id string, spec *pb.VmSpawnRequest, labels map[string]string) *models.WorkloadMetadata {
+	return &models.WorkloadMetadata{
+		ID:           id,
+		Name:         spec.GetName(),
+		Tenant:       spec.GetTenant(),
+		Node:         a.cfg.NodeName,
+		ReplicaGroup: spec.GetReplicaGroup(),
+		Labels:       storedLabels(labels, UserLabelPrefix, spec.GetLabels()),
+		Annotations:  storedLabels(labels, AnnotationPrefix, spec.GetAnnotations()),
+	}
+}
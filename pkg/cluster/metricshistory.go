@@ -0,0 +1,70 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+const (
+	// MetricsHistoryPeriod is how often each node records the metrics of
+	// the cluster as it sees them
+	MetricsHistoryPeriod = defaults.MetricsHistoryPeriod
+	// MetricsHistoryRetention is how far back the recorded metrics are
+	// kept, in memory
+	MetricsHistoryRetention = defaults.MetricsHistoryRetention
+)
+
+// metricsHistory is a ring buffer of the metrics recorded by this node,
+// the oldest bucket being overwritten once it is full
+type metricsHistory struct {
+	mu      sync.Mutex
+	buckets []*pb.MetricsBucket
+	next    int
+}
+
+func newMetricsHistory() *metricsHistory {
+	return &metricsHistory{buckets: make([]*pb.MetricsBucket, MetricsHistoryRetention/MetricsHistoryPeriod)}
+}
+
+func (h *metricsHistory) record(bucket *pb.MetricsBucket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets[h.next] = bucket
+	h.next = (h.next + 1) % len(h.buckets)
+}
+
+// since returns the buckets recorded from the given time, oldest first
+func (h *metricsHistory) since(from time.Time) []*pb.MetricsBucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var buckets []*pb.MetricsBucket
+
+	for i := range h.buckets {
+		bucket := h.buckets[(h.next+i)%len(h.buckets)]
+		if bucket != nil && bucket.GetUnixTime() >= from.Unix() {
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	return buckets
+}
+
+// recordMetrics periodically adds the current metrics of the cluster to
+// the history of the node
+func (a *Agent) recordMetrics() {
+	ticker := time.NewTicker(MetricsHistoryPeriod)
+	for now := range ticker.C {
+		current := a.currentMetrics()
+
+		a.metricsHistory.record(&pb.MetricsBucket{
+			UnixTime: now.Unix(),
+			Nodes:    current.GetNodes(),
+			Services: current.GetServices(),
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+type workloadName struct {
+	tenant string
+	name   string
+}
+
+type nameClaim struct {
+	// replica group the name was claimed for, empty for a single workload
+	replicaGroup   string
+	idempotencyKey string
+	claimedAt      time.Time
+}
+
+// workloadNames indexes the names of the spawns handled by this node until
+// the workloads show up in the state broadcasts, the names of the other
+// workloads are looked up in the broadcasts
+type workloadNames struct {
+	mu     sync.Mutex
+	claims map[workloadName]nameClaim
+}
+
+func newWorkloadNames() *workloadNames {
+	return &workloadNames{claims: make(map[workloadName]nameClaim)}
+}
+
+// namedWorkloads returns the known workloads of a tenant with the name
+func (a *Agent) namedWorkloads(tenant, name string) []*pb.WorkloadState {
+	var workloads []*pb.WorkloadState
+
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			spec := workload.GetSourceRequest()
+			if spec.GetName() == name && spec.GetTenant() == tenant {
+				workloads = append(workloads, workload)
+			}
+		}
+	}
+
+	return workloads
+}
+
+// sameWorkload returns whether a spawn request is for the workload an
+// existing name belongs to, i.e. another replica of its replica group or
+// a retry of the spawn that created it
+func sameWorkload(req *pb.VmSpawnRequest, replicaGroup, idempotencyKey string) bool {
+	if req.GetReplicaGroup() != "" && req.GetReplicaGroup() == replicaGroup {
+		return true
+	}
+
+	return req.GetIdempotencyKey() != "" && req.GetIdempotencyKey() == idempotencyKey
+}
+
+// claimName reserves the name of a spawn request for its tenant, failing
+// if another workload of the tenant already has it
+func (a *Agent) claimName(req *pb.VmSpawnRequest) error {
+	if req.GetName() == "" {
+		return nil
+	}
+
+	if !workloadNameRegexp.MatchString(req.GetName()) {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "invalid workload name %q, must be a lowercase DNS label", req.GetName())
+	}
+
+	key := workloadName{tenant: req.GetTenant(), name: req.GetName()}
+	conflict := func(id string) error {
+		return newClusterError(pb.ErrorCode_ALREADY_EXISTS, map[string]string{"tenant": key.tenant, "name": key.name, "id": id},
+			"workload name %s is already used by %s", key.name, id)
+	}
+
+	a.names.mu.Lock()
+	defer a.names.mu.Unlock()
+
+	for _, workload := range a.namedWorkloads(key.tenant, key.name) {
+		spec := workload.GetSourceRequest()
+		if !sameWorkload(req, spec.GetReplicaGroup(), spec.GetIdempotencyKey()) {
+			return conflict(workload.GetId())
+		}
+	}
+
+	claim, ok := a.names.claims[key]
+	if ok && time.Since(claim.claimedAt) <= a.broadcastPeriod*3 && !sameWorkload(req, claim.replicaGroup, claim.idempotencyKey) {
+		if claim.replicaGroup != "" {
+			return conflict(claim.replicaGroup)
+		}
+
+		return conflict("a workload being spawned")
+	}
+
+	a.names.claims[key] = nameClaim{
+		replicaGroup:   req.GetReplicaGroup(),
+		idempotencyKey: req.GetIdempotencyKey(),
+		claimedAt:      time.Now(),
+	}
+
+	return nil
+}
+
+// releaseName frees the name of a spawn request that failed
+func (a *Agent) releaseName(req *pb.VmSpawnRequest) {
+	if req.GetName() == "" {
+		return
+	}
+
+	a.names.mu.Lock()
+	defer a.names.mu.Unlock()
+
+	delete(a.names.claims, workloadName{tenant: req.GetTenant(), name: req.GetName()})
+}
+
+// resolveWorkload returns the workload ID or replica group ref designates,
+// ref is looked up in the workload names of the tenant if it isn't
+// already one. A name resolves to the replica group of a horizontally
+// scaled workload, or to its only replica if single is set
+func (a *Agent) resolveWorkload(tenant, ref string, single bool) (string, error) {
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if workload.GetId() == ref || workload.GetSourceRequest().GetReplicaGroup() == ref {
+				return ref, nil
+			}
+		}
+	}
+
+	workloads := a.namedWorkloads(tenant, ref)
+	if len(workloads) == 0 {
+		// Possibly spawned since the last broadcasts
+		return ref, nil
+	}
+
+	if group := workloads[0].GetSourceRequest().GetReplicaGroup(); group != "" && !single {
+		return group, nil
+	}
+
+	if len(workloads) > 1 {
+		ids := make([]string, 0, len(workloads))
+		for _, workload := range workloads {
+			ids = append(ids, workload.GetId())
+		}
+
+		return "", newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"ids": strings.Join(ids, ",")},
+			"workload name %s designates %d replicas, use one of their IDs", ref, len(workloads))
+	}
+
+	return workloads[0].GetId(), nil
+}
@@ -0,0 +1,325 @@
+package cluster
+
+import (
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type gatewayRoute struct {
+	method      string
+	path        string
+	summary     string
+	request     protoreflect.MessageDescriptor
+	response    protoreflect.MessageDescriptor
+	parameters  []map[string]interface{}
+	contentType string
+}
+
+func gatewayRoutes() []gatewayRoute {
+	idParam := map[string]interface{}{
+		"name": "id", "in": "path", "required": true,
+		"description": "workload name, container ID or replica group",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+	tenantParam := map[string]interface{}{
+		"name": "tenant", "in": "query",
+		"description": "tenant whose workload names id is looked up in",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+
+	selectorParam := map[string]interface{}{
+		"name": "label_selector", "in": "query", "required": true,
+		"description": "comma separated key=value, key!=value, key or !key requirements",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+	selectorTenantParam := map[string]interface{}{
+		"name": "tenant", "in": "query",
+		"description": "only select the workloads of this tenant",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+	tailParam := map[string]interface{}{
+		"name": "tail_bytes", "in": "query",
+		"description": "number of bytes from the end of the logs to return",
+		"schema":      map[string]interface{}{"type": "integer", "format": "uint32"},
+	}
+
+	listParams := []map[string]interface{}{
+		{"name": "node", "in": "query", "description": "only list the workloads of this node", "schema": map[string]interface{}{"type": "string"}},
+		{"name": "tenant", "in": "query", "description": "only list the workloads of this tenant", "schema": map[string]interface{}{"type": "string"}},
+		{"name": "label_selector", "in": "query", "description": "comma separated key=value, key!=value, key or !key requirements", "schema": map[string]interface{}{"type": "string"}},
+		{"name": "status", "in": "query", "description": "only list the workloads with this status", "schema": map[string]interface{}{"type": "string", "enum": []string{"ready", "not_ready", "pending"}}},
+		{"name": "limit", "in": "query", "description": "workloads returned per page", "schema": map[string]interface{}{"type": "integer", "format": "uint32"}},
+		{"name": "page_token", "in": "query", "description": "next_page_token of the previous page", "schema": map[string]interface{}{"type": "string"}},
+	}
+
+	return []gatewayRoute{
+		{
+			method: "post", path: "/v1/workloads", summary: "Spawn a workload",
+			request:  (&pb.VmSpawnRequest{}).ProtoReflect().Descriptor(),
+			response: (&pb.VmSpawnResponse{}).ProtoReflect().Descriptor(),
+		},
+		{
+			method: "post", path: "/v1/plan", summary: "Plan the placement of a workload without spawning it",
+			request:  (&pb.VmSpawnRequest{}).ProtoReflect().Descriptor(),
+			response: (&pb.PlanSpawnResponse{}).ProtoReflect().Descriptor(),
+		},
+		{
+			method: "get", path: "/v1/workloads", summary: "List the workloads running across the cluster",
+			response:   (&pb.VmQueryResponse{}).ProtoReflect().Descriptor(),
+			parameters: listParams,
+		},
+		{
+			method: "delete", path: "/v1/workloads", summary: "Stop every workload matching a label selector",
+			response:   (&pb.VmStopResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{selectorParam, selectorTenantParam},
+		},
+		{
+			method: "put", path: "/v1/workloads/{id}", summary: "Create or update a named workload",
+			request:  (&pb.VmSpawnRequest{}).ProtoReflect().Descriptor(),
+			response: (&pb.WorkloadDescription{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{idParam, {
+				"name": "Idempotency-Key", "in": "header",
+				"description": "retries with the same key return the original result",
+				"schema":      map[string]interface{}{"type": "string"},
+			}},
+		},
+		{
+			method: "get", path: "/v1/workloads/{id}", summary: "Get the normalized spec and replicas of a named workload",
+			response:   (&pb.WorkloadDescription{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{idParam},
+		},
+		{
+			method: "delete", path: "/v1/workloads/{id}", summary: "Stop a workload or all replicas of a replica group",
+			response:   (&pb.VmStopResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{idParam, tenantParam},
+		},
+		{
+			method: "post", path: "/v1/workloads/{id}/restart", summary: "Stop a workload and create it again on the same node with the same ID",
+			response:   (&pb.RestartResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{idParam, tenantParam},
+		},
+		{
+			method: "get", path: "/v1/workloads/{id}/logs", summary: "Get the logs of a workload",
+			response:   (&pb.VmLogsResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{idParam, tenantParam, tailParam},
+		},
+		{
+			method: "get", path: "/v1/logs", summary: "Get the logs of every workload matching a label selector",
+			response:   (&pb.VmLogsResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{selectorParam, selectorTenantParam, tailParam},
+		},
+		{
+			method: "get", path: "/v1/events", summary: "Stream cluster events as server-sent events",
+			response: (&pb.WatchEventsResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{{
+				"name": "id", "in": "query",
+				"description": "only return events about this workload or replica group",
+				"schema":      map[string]interface{}{"type": "string"},
+			}},
+			contentType: "text/event-stream",
+		},
+		{
+			method: "get", path: "/v1/metrics", summary: "Get the capacity and usage of the cluster nodes",
+			response: (&pb.MetricsResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{{
+				"name": "since_unix_time", "in": "query",
+				"description": "also return the history recorded by the node since then, in unix seconds",
+				"schema":      map[string]interface{}{"type": "integer", "format": "int64"},
+			}},
+		},
+		{
+			method: "get", path: "/v1/cache", summary: "Get the images the nodes pulled ahead of the placements they are likely to get",
+			response: (&pb.CacheStatusResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{{
+				"name": "node", "in": "query",
+				"description": "only return the images of this node",
+				"schema":      map[string]interface{}{"type": "string"},
+			}, {
+				"name": "image_ref", "in": "query",
+				"description": "only return this image",
+				"schema":      map[string]interface{}{"type": "string"},
+			}},
+		},
+		{
+			method: "get", path: "/v1/topology", summary: "Get the connections between the workloads observed within the window of the nodes",
+			response:   (&pb.TopologyResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{tenantParam},
+		},
+		{
+			method: "post", path: "/v1/volumes/{name}/snapshots", summary: "Snapshot a persistent volume to the object store",
+			response: (&pb.VolumeSnapshot{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{{
+				"name": "name", "in": "path", "required": true,
+				"description": "name of the volume",
+				"schema":      map[string]interface{}{"type": "string"},
+			}, tenantParam},
+		},
+		{
+			method: "get", path: "/v1/snapshots", summary: "List the snapshots of the persistent volumes",
+			response: (&pb.ListSnapshotsResponse{}).ProtoReflect().Descriptor(),
+			parameters: []map[string]interface{}{{
+				"name": "volume", "in": "query",
+				"description": "only return the snapshots of this volume",
+				"schema":      map[string]interface{}{"type": "string"},
+			}, tenantParam},
+		},
+	}
+}
+
+// OpenAPISpec returns the OpenAPI 3 spec of the gateway, schemas
+// are generated from the cluster proto definitions
+func OpenAPISpec() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	for _, route := range gatewayRoutes() {
+		contentType := route.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		operation := map[string]interface{}{
+			"summary": route.summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						contentType: map[string]interface{}{"schema": messageRef(route.response, schemas)},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+					},
+				},
+			},
+		}
+
+		if route.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": messageRef(route.request, schemas)},
+				},
+			}
+		}
+
+		if route.parameters != nil {
+			operation["parameters"] = route.parameters
+		}
+
+		if _, ok := paths[route.path]; !ok {
+			paths[route.path] = make(map[string]interface{})
+		}
+		paths[route.path].(map[string]interface{})[route.method] = operation
+	}
+
+	errorCodes := pb.ErrorCode_UNKNOWN_ERROR.Descriptor().Values()
+	codeNames := make([]string, 0, errorCodes.Len())
+	for i := range errorCodes.Len() {
+		codeNames = append(codeNames, string(errorCodes.Get(i).Name()))
+	}
+
+	schemas["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error":   map[string]interface{}{"type": "string"},
+			"code":    map[string]interface{}{"type": "string", "enum": codeNames},
+			"details": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "hypercore cluster API",
+			"version": pb.APIVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []map[string]interface{}{{"bearer": []string{}}},
+	}
+}
+
+// messageRef adds the schema of a message, and of the messages it
+// references, to schemas and returns a reference to it
+func messageRef(desc protoreflect.MessageDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	name := string(desc.Name())
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+
+	properties := make(map[string]interface{})
+	schemas[name] = map[string]interface{}{"type": "object", "properties": properties}
+
+	fields := desc.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+
+		switch {
+		case field.IsMap():
+			properties[field.JSONName()] = map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": fieldSchema(field.MapValue(), schemas),
+			}
+		case field.IsList():
+			properties[field.JSONName()] = map[string]interface{}{
+				"type":  "array",
+				"items": fieldSchema(field, schemas),
+			}
+		default:
+			properties[field.JSONName()] = fieldSchema(field, schemas)
+		}
+	}
+
+	return ref
+}
+
+// fieldSchema follows the protojson encoding of scalar types
+func fieldSchema(field protoreflect.FieldDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := range values.Len() {
+			names = append(names, string(values.Get(i).Name()))
+		}
+
+		return map[string]interface{}{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if field.Message().FullName() == "google.protobuf.Any" {
+			return map[string]interface{}{"type": "object"}
+		}
+
+		return messageRef(field.Message(), schemas)
+	case protoreflect.StringKind:
+		fallthrough
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
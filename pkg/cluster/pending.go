@@ -0,0 +1,239 @@
+package cluster
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxPendingTTL bounds how long a spawn request can wait for a node
+	// with capacity
+	MaxPendingTTL = time.Hour * 24
+	// MaxPendingSpawns bounds the spawn requests a node keeps pending,
+	// further requests fail when no node has capacity for them
+	MaxPendingSpawns = 256
+)
+
+// pendingQueue holds the spawn requests this node queued until a node
+// has capacity for them, placement is retried every broadcast period and
+// whenever a node joins
+type pendingQueue struct {
+	mu     sync.Mutex
+	spawns []*pb.PendingSpawn
+	kick   chan struct{}
+}
+
+func newPendingQueue() *pendingQueue {
+	return &pendingQueue{kick: make(chan struct{}, 1)}
+}
+
+// retrySoon wakes the retry loop up before the next broadcast period
+func (q *pendingQueue) retrySoon() {
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+// list returns copies of the pending spawns, oldest first
+func (q *pendingQueue) list() []*pb.PendingSpawn {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	spawns := make([]*pb.PendingSpawn, 0, len(q.spawns))
+	for _, pending := range q.spawns {
+		spawns = append(spawns, proto.Clone(pending).(*pb.PendingSpawn))
+	}
+
+	return spawns
+}
+
+// setReason records why a pending spawn couldn't be placed again
+func (q *pendingQueue) setReason(id, reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, pending := range q.spawns {
+		if pending.GetId() == id {
+			pending.Reason = reason
+		}
+	}
+}
+
+func (q *pendingQueue) remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := len(q.spawns)
+	q.spawns = slices.DeleteFunc(q.spawns, func(pending *pb.PendingSpawn) bool {
+		return pending.GetId() == id
+	})
+
+	return len(q.spawns) < count
+}
+
+// cancel removes the pending spawns with the given ID or replica group,
+// returning their IDs
+func (q *pendingQueue) cancel(id string, workloadOnly bool) []string {
+	return q.cancelMatching(func(pending *pb.PendingSpawn) bool {
+		return pending.GetId() == id || (!workloadOnly && pending.GetSpec().GetReplicaGroup() == id)
+	})
+}
+
+// cancelMatching removes the queued spawns match returns true for,
+// returning their pending IDs
+func (q *pendingQueue) cancelMatching(match func(pending *pb.PendingSpawn) bool) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var canceled []string
+
+	q.spawns = slices.DeleteFunc(q.spawns, func(pending *pb.PendingSpawn) bool {
+		if match(pending) {
+			canceled = append(canceled, pending.GetId())
+
+			return true
+		}
+
+		return false
+	})
+
+	return canceled
+}
+
+// shouldQueue returns whether a spawn failed for lack of a node with
+// capacity for it, as opposed to an invalid or denied request
+func shouldQueue(err error) bool {
+	return errorCode(err) == pb.ErrorCode_CAPACITY_EXCEEDED || errors.Is(err, errNoSpawnResponse)
+}
+
+// spawnOrQueue spawns a replica of the request, queueing it as pending if
+// no node has capacity for it and the request allows it
+func (a *Agent) spawnOrQueue(req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error) {
+	resp, err := a.spawnReplica(req)
+	if err == nil || req.GetPendingTtlSeconds() == 0 || !shouldQueue(err) {
+		return resp, err
+	}
+
+	a.pending.mu.Lock()
+	defer a.pending.mu.Unlock()
+
+	if key := req.GetIdempotencyKey(); key != "" {
+		for _, pending := range a.pending.spawns {
+			if pending.GetSpec().GetIdempotencyKey() == key {
+				return pendingResponse(pending, a.baseURL), nil
+			}
+		}
+	}
+
+	if len(a.pending.spawns) >= MaxPendingSpawns {
+		return nil, err
+	}
+
+	now := time.Now()
+	pending := &pb.PendingSpawn{
+		Id:              uuid.NewString(),
+		Spec:            proto.Clone(req).(*pb.VmSpawnRequest),
+		Reason:          err.Error(),
+		QueuedUnixTime:  now.Unix(),
+		ExpiresUnixTime: now.Add(time.Duration(req.GetPendingTtlSeconds()) * time.Second).Unix(),
+	}
+
+	a.pending.spawns = append(a.pending.spawns, pending)
+
+	a.logger.Infof("Queued spawn %s until a node has capacity for it: %s", pending.GetId(), err)
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_SPAWN,
+		Id:           pending.GetId(),
+		ReplicaGroup: pending.GetSpec().GetReplicaGroup(),
+		Message:      "spawn is pending: " + pending.GetReason(),
+	})
+
+	return pendingResponse(pending, a.baseURL), nil
+}
+
+func pendingResponse(pending *pb.PendingSpawn, baseURL string) *pb.VmSpawnResponse {
+	resp := &pb.VmSpawnResponse{Id: pending.GetId(), Pending: true}
+	if group := pending.GetSpec().GetReplicaGroup(); group != "" {
+		resp.Url = group + "." + baseURL
+	}
+
+	return resp
+}
+
+// retryPendingSpawns places the pending spawns as capacity frees up,
+// oldest first, dropping those that waited for longer than their TTL
+func (a *Agent) retryPendingSpawns() {
+	ticker := time.NewTicker(a.broadcastPeriod)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.pending.kick:
+		}
+
+		for _, pending := range a.pending.list() {
+			a.retryPendingSpawn(pending)
+		}
+	}
+}
+
+func (a *Agent) retryPendingSpawn(pending *pb.PendingSpawn) {
+	if time.Now().Unix() >= pending.GetExpiresUnixTime() {
+		if a.pending.remove(pending.GetId()) {
+			a.logger.Warnf("Dropping pending spawn %s, no node had capacity for it: %s", pending.GetId(), pending.GetReason())
+			a.publishEvent(&pb.WatchEventsResponse{
+				Event:        pb.ClusterEvent_ERROR,
+				Id:           pending.GetId(),
+				ReplicaGroup: pending.GetSpec().GetReplicaGroup(),
+				Message:      "pending spawn expired: " + pending.GetReason(),
+			})
+		}
+
+		return
+	}
+
+	resp, err := a.spawnReplica(pending.GetSpec())
+	if err != nil {
+		if !shouldQueue(err) {
+			a.pending.remove(pending.GetId())
+			a.logger.WithError(err).Errorf("failed to spawn pending spawn %s", pending.GetId())
+			a.publishEvent(&pb.WatchEventsResponse{
+				Event:        pb.ClusterEvent_ERROR,
+				Id:           pending.GetId(),
+				ReplicaGroup: pending.GetSpec().GetReplicaGroup(),
+				Message:      "pending spawn failed: " + err.Error(),
+			})
+
+			return
+		}
+
+		a.pending.setReason(pending.GetId(), err.Error())
+
+		return
+	}
+
+	// Canceled while being placed, the spawned workload is stopped
+	if !a.pending.remove(pending.GetId()) {
+		if _, err := a.StopRequest(&pb.VmStopRequest{Id: resp.GetId(), WorkloadOnly: true}); err != nil {
+			a.logger.WithError(err).Errorf("failed to stop workload %s of canceled pending spawn %s", resp.GetId(), pending.GetId())
+		}
+
+		return
+	}
+
+	a.logger.Infof("Placed pending spawn %s as %s", pending.GetId(), resp.GetId())
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_SPAWN,
+		Id:           pending.GetId(),
+		ReplicaGroup: pending.GetSpec().GetReplicaGroup(),
+		Message:      "pending spawn placed as " + resp.GetId(),
+	})
+}
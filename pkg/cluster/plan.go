@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// PlanSpawnRequest runs the request through the checks of a spawn and
+// places its replicas one after the other on the eligible nodes, against
+// the last state broadcast by each node, without creating anything.
+// Nodes whose capacity is unknown aren't planned on
+func (a *Agent) PlanSpawnRequest(req *pb.VmSpawnRequest) (*pb.PlanSpawnResponse, error) {
+	replicas := uint32(1)
+
+	if policy := req.GetHorizontalScaling(); policy != nil && req.GetReplicaGroup() == "" {
+		if policy.GetMaxReplicas() < policy.GetMinReplicas() {
+			return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "max replicas %d is lower than min replicas %d", policy.GetMaxReplicas(), policy.GetMinReplicas())
+		}
+
+		replicas = max(policy.GetMinReplicas(), 1)
+	}
+
+	placement, err := a.ExplainRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkImagePolicy(req); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.PlanSpawnResponse{Replicas: replicas, Placement: placement, ClusterHeadroom: &pb.Headroom{}}
+
+	states := make(map[string]*pb.NodeStateResponse)
+	for _, state := range a.knownStates() {
+		states[state.GetNode().GetId()] = state
+	}
+
+	// Candidates are ranked, eligible ones first
+	for _, candidate := range placement.GetCandidates() {
+		state := states[candidate.GetNode().GetId()]
+		if !candidate.GetEligible() || state == nil {
+			continue
+		}
+
+		headroom := &pb.Headroom{Node: candidate.GetNode().GetId(), Cpus: state.GetCpus(), Memory: state.GetMemory()}
+		for _, workload := range state.GetWorkloads() {
+			headroom.Cpus -= min(headroom.GetCpus(), workload.GetSourceRequest().GetCores())
+			headroom.Memory -= min(headroom.GetMemory(), uint64(workload.GetSourceRequest().GetMemory()))
+		}
+
+		resp.NodeHeadroom = append(resp.NodeHeadroom, headroom)
+	}
+
+	for range replicas {
+		placed := false
+
+		for _, headroom := range resp.GetNodeHeadroom() {
+			if headroom.GetCpus() < req.GetCores() || headroom.GetMemory() < uint64(req.GetMemory()) {
+				continue
+			}
+
+			headroom.Cpus -= req.GetCores()
+			headroom.Memory -= uint64(req.GetMemory())
+			resp.Nodes = append(resp.Nodes, headroom.GetNode())
+			placed = true
+
+			break
+		}
+
+		if !placed {
+			a.logger.Infof("Planned %d of the %d replicas, no node has capacity left for more", len(resp.GetNodes()), replicas)
+
+			break
+		}
+	}
+
+	for _, headroom := range resp.GetNodeHeadroom() {
+		resp.ClusterHeadroom.Cpus += headroom.GetCpus()
+		resp.ClusterHeadroom.Memory += headroom.GetMemory()
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+
+	ctask "github.com/containerd/containerd/api/types/task"
+)
+
+// podExit is a pod container found stopped
+type podExit struct {
+	name       string
+	exitStatus uint32
+}
+
+// podState is the state of the pod containers of the local workloads
+type podState struct {
+	// IDs of the pod containers, which aren't workloads of their own
+	containers map[string]struct{}
+	// a stopped pod container of each workload that has one
+	exited map[string]podExit
+}
+
+// podContainers converts the pod containers of a workload, expanding the
+// templates of their environment like the workload's
+func podContainers(specs []*pb.PodContainer, data WorkloadEnvData) ([]vcontainerd.PodContainer, error) {
+	containers := make([]vcontainerd.PodContainer, 0, len(specs))
+
+	for _, container := range specs {
+		env, err := expandWorkloadEnv(container.GetEnv(), data)
+		if err != nil {
+			return nil, err
+		}
+
+		containers = append(containers, vcontainerd.PodContainer{
+			Name:     container.GetName(),
+			ImageRef: container.GetImageRef(),
+			Command:  container.GetCommand(),
+			Env:      workloadEnv(env),
+		})
+	}
+
+	return containers, nil
+}
+
+// podState returns the pod containers among the local tasks, along with
+// the workloads one of whose pod containers stopped
+func (a *Agent) podState(ctx context.Context, tasks []*ctask.Process) (podState, error) {
+	state := podState{containers: make(map[string]struct{}), exited: make(map[string]podExit)}
+
+	containers, err := a.ctrRepo.ListContainers(ctx, fmt.Sprintf("labels.%q", vcontainerd.PodLabel))
+	if err != nil {
+		return state, fmt.Errorf("failed to list pod containers: %w", err)
+	}
+
+	pods := make(map[string]string)
+
+	for _, container := range containers {
+		labels, err := container.Labels(ctx)
+		if err != nil {
+			return state, fmt.Errorf("failed to get labels for container %s: %w", container.ID(), err)
+		}
+
+		pods[container.ID()] = labels[vcontainerd.PodLabel]
+		state.containers[container.ID()] = struct{}{}
+	}
+
+	for _, task := range tasks {
+		pod, ok := pods[task.GetID()]
+		if !ok || task.GetStatus() != ctask.Status_STOPPED {
+			continue
+		}
+
+		if _, ok := state.exited[pod]; !ok {
+			state.exited[pod] = podExit{name: strings.TrimPrefix(task.GetID(), pod+"-"), exitStatus: task.GetExitStatus()}
+		}
+	}
+
+	return state, nil
+}
@@ -0,0 +1,270 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DefaultProbePeriod           = time.Second * 10
+	DefaultProbeTimeout          = time.Second
+	DefaultProbeFailureThreshold = 3
+)
+
+var errNotReady = errors.New("readiness probe not passing")
+
+// probeManager runs the probes of the workloads of this node
+type probeManager struct {
+	mu        sync.Mutex
+	workloads map[string]*workloadProbes
+}
+
+type workloadProbes struct {
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	status *pb.ProbeStatus
+}
+
+func newProbeManager() *probeManager {
+	return &probeManager{workloads: make(map[string]*workloadProbes)}
+}
+
+func (p *workloadProbes) get() *pb.ProbeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return proto.Clone(p.status).(*pb.ProbeStatus)
+}
+
+func (p *workloadProbes) update(update func(status *pb.ProbeStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	update(p.status)
+}
+
+// probeStatus returns the probe status of a local workload, starting its
+// probes the first time it is seen
+func (a *Agent) probeStatus(id string, endpoint workloadEndpoint, spec *pb.VmSpawnRequest) *pb.ProbeStatus {
+	a.probes.mu.Lock()
+	defer a.probes.mu.Unlock()
+
+	probes, ok := a.probes.workloads[id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		probes = &workloadProbes{
+			cancel: cancel,
+			status: &pb.ProbeStatus{Ready: spec.GetReadinessProbe() == nil, Live: true},
+		}
+		a.probes.workloads[id] = probes
+
+		if readiness := spec.GetReadinessProbe(); readiness != nil {
+			go a.runProbe(ctx, id, endpoint, readiness, probes, func(passing bool) {
+				probes.update(func(status *pb.ProbeStatus) { status.Ready = passing })
+			})
+		}
+
+		if liveness := spec.GetLivenessProbe(); liveness != nil {
+			go a.runProbe(ctx, id, endpoint, liveness, probes, func(passing bool) {
+				if passing {
+					return
+				}
+
+				probes.update(func(status *pb.ProbeStatus) { status.Live = false })
+				a.killUnhealthy(id, probes.get().GetLastError())
+			})
+		}
+	}
+
+	return probes.get()
+}
+
+// retainProbes stops the probes of the workloads no longer running here
+func (a *Agent) retainProbes(running map[string]struct{}) {
+	a.probes.mu.Lock()
+	defer a.probes.mu.Unlock()
+
+	for id, probes := range a.probes.workloads {
+		if _, ok := running[id]; !ok {
+			probes.cancel()
+			delete(a.probes.workloads, id)
+		}
+	}
+}
+
+// runProbe runs a probe every period, calling transition when it starts
+// passing after success threshold successes or failing after failure
+// threshold failures
+func (a *Agent) runProbe(ctx context.Context, id string, endpoint workloadEndpoint, probe *pb.Probe, probes *workloadProbes, transition func(passing bool)) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Duration(probe.GetInitialDelaySeconds()) * time.Second):
+	}
+
+	period := time.Duration(probe.GetPeriodSeconds()) * time.Second
+	if period == 0 {
+		period = DefaultProbePeriod
+	}
+
+	failureThreshold := probe.GetFailureThreshold()
+	if failureThreshold == 0 {
+		failureThreshold = DefaultProbeFailureThreshold
+	}
+
+	successThreshold := max(probe.GetSuccessThreshold(), 1)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	var successes, failures uint32
+
+	// Probes start out failing until they first succeed
+	passing := false
+	first := true
+
+	for {
+		if err := a.checkProbe(ctx, id, endpoint, probe); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			a.logger.WithError(err).Warnf("probe of workload %s failed", id)
+			probes.update(func(status *pb.ProbeStatus) { status.LastError = err.Error() })
+
+			successes = 0
+			failures++
+
+			if (passing || first) && failures >= failureThreshold {
+				passing, first = false, false
+				transition(false)
+			}
+		} else {
+			failures = 0
+			successes++
+
+			if !passing && successes >= successThreshold {
+				passing, first = true, false
+				transition(true)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Agent) checkProbe(ctx context.Context, id string, endpoint workloadEndpoint, probe *pb.Probe) error {
+	timeout := time.Duration(probe.GetTimeoutSeconds()) * time.Second
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := endpoint.addr(probe.GetPort())
+
+	switch {
+	case len(probe.GetCommand()) > 0:
+		code, err := a.ctrRepo.ExecContainer(ctx, id, probe.GetCommand())
+		if err != nil {
+			return err
+		}
+
+		if code != 0 {
+			return fmt.Errorf("command %v exited with status %d", probe.GetCommand(), code)
+		}
+	case probe.GetHttpPath() != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+probe.GetHttpPath(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("GET %s returned status %d", probe.GetHttpPath(), resp.StatusCode)
+		}
+	default:
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+
+	return nil
+}
+
+// killUnhealthy kills a workload whose liveness probe failed, it is then
+// respawned by monitorWorkloads according to its restart policy
+func (a *Agent) killUnhealthy(id, reason string) {
+	a.logger.Warnf("Liveness probe of workload %s failed, killing it: %s", id, reason)
+
+	a.serviceProxy.Deregister(id)
+
+	if err := a.ctrRepo.KillContainer(a.ctrRepo.GetContext(context.Background()), id, syscall.SIGKILL); err != nil {
+		a.logger.WithError(err).Errorf("failed to kill unhealthy workload %s", id)
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:   pb.ClusterEvent_ERROR,
+		Id:      id,
+		Message: "liveness probe failed: " + reason,
+	})
+}
+
+// shouldRespawn applies the restart policy of a stopped workload
+func shouldRespawn(spec *pb.VmSpawnRequest, exitStatus uint32) bool {
+	switch spec.GetRestartPolicy() {
+	case pb.RestartPolicy_NEVER:
+		return false
+	case pb.RestartPolicy_ON_FAILURE:
+		return exitStatus != 0
+	default:
+		return true
+	}
+}
+
+// workloadReady returns whether a workload should receive traffic
+func workloadReady(workload *pb.WorkloadState) bool {
+	if workload.GetInit() != nil {
+		return false
+	}
+
+	return workload.GetProbes() == nil || workload.GetProbes().GetReady()
+}
+
+func validateProbe(name string, probe *pb.Probe) error {
+	if probe == nil {
+		return nil
+	}
+
+	if len(probe.GetCommand()) > 0 && probe.GetHttpPath() != "" {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "%s probe can't have both an HTTP path and a command", name)
+	}
+
+	if len(probe.GetCommand()) == 0 && (probe.GetPort() == 0 || probe.GetPort() > 0xffff) {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "%s probe needs a command or a valid port, got port %d", name, probe.GetPort())
+	}
+
+	return nil
+}
@@ -1,41 +1,119 @@
 package cluster
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// BackendHeader is set when forwarding a request to another node's
+// proxy, so it is routed to the chosen backend instead of being
+// load balanced again. It is only honoured on requests authenticated
+// with a certificate of the cluster CA
+const BackendHeader = "X-Hypercore-Backend"
+
 type ServiceProxy struct {
-	mu                *sync.Mutex
-	logger            *log.Logger
-	tlsConfig         *TLSConfig
-	proxiedPortMap    map[uint32]struct{}
-	serviceIDPortMaps map[string]map[uint32]string
+	mu     *sync.Mutex
+	logger *log.Logger
+	certs  *CertSource
+	// Transport of the requests forwarded to other nodes' proxies
+	remoteTransport http.RoundTripper
+	proxiedPortMap  map[uint32]struct{}
+	// service ID -> host port -> backend ID -> URL
+	serviceIDPortMaps map[string]map[uint32]map[string]string
+	// backend ID -> revision of the workload
+	backendRevisions map[string]uint32
+	// backends running on other nodes
+	remoteBackends map[string]struct{}
+	// backends whose requests are logged
+	accessLogs map[string]struct{}
+	// services whose clients are pinned to a backend
+	affinities map[string]pb.SessionAffinity
+	// backend ID -> requests being proxied to it
+	inflight    map[string]int
+	splits      map[string]TrafficSplit
+	stats       map[string]*ServiceStats
+	histograms  map[string]*serviceHistograms
+	nextBackend int
+	// signalled when backends are registered or deregistered
+	changed chan struct{}
+	// serves the requests of all the host ports
+	mux *http.ServeMux
+}
+
+// proxyBackend is the backend a request is routed to
+type proxyBackend struct {
+	id        string
+	url       string
+	revision  uint32
+	remote    bool
+	accessLog bool
+	// set on the response to pin the client to the backend
+	cookie *http.Cookie
+}
+
+// TrafficSplit sends a share of the requests of a service to the backends
+// of one revision, the rest go to the backends of the other revisions
+type TrafficSplit struct {
+	Revision uint32
+	// percentage (0-100) of the requests sent to Revision
+	Weight uint32
+}
+
+type ServiceStats struct {
+	Requests     uint64
+	TotalLatency time.Duration
+	// requests answered with a 5xx status or that couldn't be proxied
+	Errors    uint64
+	Revisions map[uint32]*RevisionStats
 }
 
-type TLSConfig struct {
-	CertFile string
-	KeyFile  string
+type RevisionStats struct {
+	Requests uint64
+	Errors   uint64
 }
 
-func NewServiceProxy(logger *log.Logger, tlsConfig *TLSConfig) (*ServiceProxy, error) {
+func NewServiceProxy(logger *log.Logger, certs *CertSource) (*ServiceProxy, error) {
 	s := &ServiceProxy{
 		logger:            logger,
-		tlsConfig:         tlsConfig,
+		certs:             certs,
+		remoteTransport:   http.DefaultTransport,
 		mu:                &sync.Mutex{},
 		proxiedPortMap:    make(map[uint32]struct{}),
-		serviceIDPortMaps: make(map[string]map[uint32]string),
+		serviceIDPortMaps: make(map[string]map[uint32]map[string]string),
+		backendRevisions:  make(map[string]uint32),
+		remoteBackends:    make(map[string]struct{}),
+		accessLogs:        make(map[string]struct{}),
+		affinities:        make(map[string]pb.SessionAffinity),
+		inflight:          make(map[string]int),
+		splits:            make(map[string]TrafficSplit),
+		stats:             make(map[string]*ServiceStats),
+		histograms:        make(map[string]*serviceHistograms),
+		changed:           make(chan struct{}, 1),
+		mux:               http.NewServeMux(),
+	}
+
+	if certs != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = certs.ClientTLSConfig()
+		s.remoteTransport = transport
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		addr := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
 		port, err := strconv.Atoi(strings.Split(addr.String(), ":")[1])
 		if err != nil {
@@ -49,41 +127,320 @@ func NewServiceProxy(logger *log.Logger, tlsConfig *TLSConfig) (*ServiceProxy, e
 			return
 		}
 		host := splitHost[0]
-		s.logger.Infof("Got request for host %s port %d", host, port)
-		//nolint:nestif
-		if portMap, ok := s.serviceIDPortMaps[host]; ok {
-			if containerAddr, ok := portMap[uint32(port)]; ok {
-				s.logger.Infof("got address %s for service at host %s", containerAddr, r.Host)
-
-				proxiedURL, err := url.Parse("http://" + containerAddr)
-				if err != nil {
-					// this should not happen
-					panic(fmt.Errorf("failed to parse container address %s: %w", containerAddr, err))
-				}
-
-				// TODO construct once per URL
-				httputil.NewSingleHostReverseProxy(proxiedURL).ServeHTTP(w, r)
+
+		client := r
+
+		// The header of requests not forwarded by another node is
+		// dropped, they are routed and accounted like any other
+		forwarded := false
+		if forwardedBackend := r.Header.Get(BackendHeader); forwardedBackend != "" {
+			r.Header.Del(BackendHeader)
+
+			if err := s.verifyForwarded(r); err != nil {
+				s.logger.WithError(err).Debugf("ignoring %s header of request from %s", BackendHeader, r.RemoteAddr)
 			} else {
-				s.logger.Warnf("no port mapped for %d for service %s", port, host)
+				// The proxy that received the request already applied
+				// the session affinity of the service
+				forwarded = true
+				host = forwardedBackend
+				client = nil
+			}
+		}
+
+		s.logger.Infof("Got request for host %s port %d", host, port)
+
+		backend, ok := s.pickBackend(host, uint32(port), client)
+		if !ok {
+			s.logger.Warnf("no backend found for service %s port %d", host, port)
+
+			return
+		}
+
+		s.logger.Infof("got address %s for service at host %s", backend.url, r.Host)
+
+		proxiedURL, err := url.Parse(backend.url)
+		if err != nil {
+			// this should not happen
+			panic(fmt.Errorf("failed to parse backend URL %s: %w", backend.url, err))
+		}
+
+		r.Header.Set(BackendHeader, backend.id)
+
+		// Without a cluster CA the proxy of the other node ignores the
+		// header, the request reaches the backend by the service every
+		// workload is exposed under instead. That proxy accounts it, so
+		// it is only accounted here if the service has other backends
+		unauthenticated := backend.remote && !s.authenticatesForwards()
+		if unauthenticated {
+			splitHost[0] = backend.id
+			r.Host = strings.Join(splitHost, ".")
+		}
+
+		if backend.cookie != nil {
+			http.SetCookie(w, backend.cookie)
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		start := time.Now()
+		// TODO construct once per URL
+		reverseProxy := httputil.NewSingleHostReverseProxy(proxiedURL)
+		if proxiedURL.Scheme == "https" {
+			reverseProxy.Transport = s.remoteTransport
+		}
+		s.startRequest(backend.id)
+		reverseProxy.ServeHTTP(recorder, r)
+		s.finishRequest(backend.id)
+
+		// Only account requests at the node that received them
+		if !forwarded && !(unauthenticated && host == backend.id) {
+			latency := time.Since(start)
+
+			s.recordRequest(host, backend.revision, latency, recorder.status >= http.StatusInternalServerError)
+			s.observe(host, recorder.status, latency, recorder.bytes)
+
+			if backend.accessLog {
+				s.logAccess(r, host, backend, recorder, latency)
 			}
-		} else {
-			s.logger.Warnf("no service found for identifier: %s", host)
 		}
 	})
 
 	return s, nil
 }
 
-func (s *ServiceProxy) Register(hostPort uint32, containerID, containerAddr string) error {
+// authenticatesForwards reports whether the proxies of the cluster
+// authenticate the requests they forward to each other
+func (s *ServiceProxy) authenticatesForwards() bool {
+	return s.certs != nil && s.certs.HasCA()
+}
+
+// verifyForwarded checks that a request naming its backend was forwarded
+// by another node, i.e. over TLS with a certificate issued by the cluster
+// CA. Without a CA they can't be told apart from the requests of clients
+func (s *ServiceProxy) verifyForwarded(r *http.Request) error {
+	if !s.authenticatesForwards() {
+		return errNoClusterCA
+	}
+
+	if r.TLS == nil {
+		return errNoPeerCertificate
+	}
+
+	_, err := s.certs.VerifyPeer(r.TLS.PeerCertificates, x509.ExtKeyUsageClientAuth)
+
+	return err
+}
+
+// pickBackend chooses one of the backends registered for a service in
+// a round-robin fashion, among the backends of the revision picked
+// according to the traffic split of the service if it has one. The
+// session affinity of the service, if any, pins the client to a backend
+// instead; it is ignored if client is nil
+func (s *ServiceProxy) pickBackend(serviceID string, hostPort uint32, client *http.Request) (proxyBackend, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backends := s.serviceIDPortMaps[serviceID][hostPort]
+	if len(backends) == 0 {
+		return proxyBackend{}, false
+	}
+
+	affinity := pb.SessionAffinity_AFFINITY_NONE
+	if client != nil {
+		affinity = s.affinities[serviceID]
+	}
+
+	if affinity == pb.SessionAffinity_AFFINITY_COOKIE {
+		if backendID, ok := cookieBackend(client, serviceID); ok {
+			if _, ok := backends[backendID]; ok {
+				return s.backend(backendID, backends[backendID]), true
+			}
+		}
+	}
+
+	backendIDs := make([]string, 0, len(backends))
+	for backendID := range backends {
+		backendIDs = append(backendIDs, backendID)
+	}
+	slices.Sort(backendIDs)
+
+	var backendID string
+
+	if affinity == pb.SessionAffinity_AFFINITY_SOURCE_IP {
+		source := sourceIP(client)
+
+		if split, ok := s.splits[serviceID]; ok {
+			backendIDs = s.splitBackends(backendIDs, split, sourceRoll(source))
+		}
+
+		backendID = rendezvousBackend(source, backendIDs)
+	} else {
+		if split, ok := s.splits[serviceID]; ok {
+			backendIDs = s.splitBackends(backendIDs, split, uint32(rand.Intn(100)))
+		}
+
+		s.nextBackend++
+		backendID = backendIDs[s.nextBackend%len(backendIDs)]
+	}
+
+	backend := s.backend(backendID, backends[backendID])
+	if affinity == pb.SessionAffinity_AFFINITY_COOKIE {
+		backend.cookie = affinityCookie(serviceID, backendID)
+	}
+
+	return backend, true
+}
+
+func (s *ServiceProxy) backend(backendID, backendURL string) proxyBackend {
+	_, accessLog := s.accessLogs[backendID]
+
+	_, remote := s.remoteBackends[backendID]
+
+	return proxyBackend{
+		id:        backendID,
+		url:       backendURL,
+		revision:  s.backendRevisions[backendID],
+		remote:    remote,
+		accessLog: accessLog,
+	}
+}
+
+// splitBackends returns the backends of the split revision if roll
+// (0-99) is below Weight and the other backends otherwise, all of them if
+// either side has no backends
+func (s *ServiceProxy) splitBackends(backendIDs []string, split TrafficSplit, roll uint32) []string {
+	var selected, others []string
+
+	for _, backendID := range backendIDs {
+		if s.backendRevisions[backendID] == split.Revision {
+			selected = append(selected, backendID)
+		} else {
+			others = append(others, backendID)
+		}
+	}
+
+	if len(selected) == 0 || len(others) == 0 {
+		return backendIDs
+	}
+
+	if roll < split.Weight {
+		return selected
+	}
+
+	return others
+}
+
+func (s *ServiceProxy) recordRequest(serviceID string, revision uint32, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[serviceID]
+	if !ok {
+		stats = &ServiceStats{Revisions: make(map[uint32]*RevisionStats)}
+		s.stats[serviceID] = stats
+	}
+
+	revisionStats, ok := stats.Revisions[revision]
+	if !ok {
+		revisionStats = &RevisionStats{}
+		stats.Revisions[revision] = revisionStats
+	}
+
+	stats.Requests++
+	stats.TotalLatency += latency
+	revisionStats.Requests++
+
+	if failed {
+		stats.Errors++
+		revisionStats.Errors++
+	}
+}
+
+// SetSplit sets the traffic split of a service
+func (s *ServiceProxy) SetSplit(serviceID string, split TrafficSplit) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.serviceIDPortMaps[containerID]; !ok {
-		s.serviceIDPortMaps[containerID] = make(map[uint32]string)
+	s.splits[serviceID] = split
+
+	s.logger.Infof("Sending %d%% of the requests of service %s to revision %d", split.Weight, serviceID, split.Revision)
+}
+
+// ClearSplit load balances the requests of a service across all its
+// backends again
+func (s *ServiceProxy) ClearSplit(serviceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.splits, serviceID)
+}
+
+// Stats returns the requests served per service since the last call
+func (s *ServiceProxy) Stats() map[string]ServiceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]ServiceStats, len(s.stats))
+	for serviceID, serviceStats := range s.stats {
+		stats[serviceID] = *serviceStats
 	}
-	s.serviceIDPortMaps[containerID][hostPort] = containerAddr
+	s.stats = make(map[string]*ServiceStats)
+
+	return stats
+}
+
+// BackendCounts returns the number of backends registered per service
+func (s *ServiceProxy) BackendCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.logger.Infof("Exposed container ID %s Address %s at host port %d", containerID, containerAddr, hostPort)
+	counts := make(map[string]int, len(s.serviceIDPortMaps))
+	for serviceID, ports := range s.serviceIDPortMaps {
+		backends := make(map[string]struct{})
+		for _, portBackends := range ports {
+			for backendID := range portBackends {
+				backends[backendID] = struct{}{}
+			}
+		}
+
+		counts[serviceID] = len(backends)
+	}
+
+	return counts
+}
+
+// Register exposes a backend of a service at the given host port, a service
+// is either a single container (serviceID == backendID) or a replica group.
+// The address of a remote backend is the proxy of the node running it
+func (s *ServiceProxy) Register(hostPort uint32, serviceID, backendID, containerAddr string, revision uint32, remote bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Other nodes' proxies serve TLS if this one does
+	backendURL := "http://" + containerAddr
+	if remote && s.certs != nil {
+		backendURL = "https://" + containerAddr
+	}
+
+	s.backendRevisions[backendID] = revision
+	if remote {
+		s.remoteBackends[backendID] = struct{}{}
+	} else {
+		delete(s.remoteBackends, backendID)
+	}
+
+	if _, ok := s.serviceIDPortMaps[serviceID]; !ok {
+		s.serviceIDPortMaps[serviceID] = make(map[uint32]map[string]string)
+	}
+	if _, ok := s.serviceIDPortMaps[serviceID][hostPort]; !ok {
+		s.serviceIDPortMaps[serviceID][hostPort] = make(map[string]string)
+	}
+	if s.serviceIDPortMaps[serviceID][hostPort][backendID] != backendURL {
+		s.serviceIDPortMaps[serviceID][hostPort][backendID] = backendURL
+		s.notifyChange()
+	}
+
+	s.logger.Infof("Exposed service ID %s backend %s Address %s at host port %d", serviceID, backendID, backendURL, hostPort)
 
 	if _, ok := s.proxiedPortMap[hostPort]; ok {
 		return nil
@@ -105,12 +462,16 @@ func (s *ServiceProxy) Register(hostPort uint32, containerID, containerAddr stri
 			s.mu.Unlock()
 		}()
 
-		if s.tlsConfig != nil {
-			if err := http.ServeTLS(listener, nil, s.tlsConfig.CertFile, s.tlsConfig.KeyFile); err != nil {
+		if s.certs != nil {
+			tlsConfig := s.certs.ListenerTLSConfig()
+			// Verified by verifyForwarded for forwarded requests only
+			tlsConfig.ClientAuth = tls.RequestClientCert
+
+			if err := http.Serve(tls.NewListener(listener, tlsConfig), s.mux); err != nil {
 				s.logger.WithError(err).Errorf("failed to serve HTTP TLS at port %d", hostPort)
 			}
 		} else {
-			if err := http.Serve(listener, nil); err != nil {
+			if err := http.Serve(listener, s.mux); err != nil {
 				s.logger.WithError(err).Errorf("failed to serve HTTP at port %d", hostPort)
 			}
 		}
@@ -118,3 +479,61 @@ func (s *ServiceProxy) Register(hostPort uint32, containerID, containerAddr stri
 
 	return nil
 }
+
+// Deregister removes a backend from all the services it was exposed under
+func (s *ServiceProxy) Deregister(backendID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.backendRevisions, backendID)
+	delete(s.remoteBackends, backendID)
+	delete(s.accessLogs, backendID)
+
+	for serviceID, portMap := range s.serviceIDPortMaps {
+		for hostPort, backends := range portMap {
+			if _, ok := backends[backendID]; ok {
+				delete(backends, backendID)
+				s.notifyChange()
+			}
+
+			if len(backends) == 0 {
+				delete(portMap, hostPort)
+			}
+		}
+
+		if len(portMap) == 0 {
+			delete(s.serviceIDPortMaps, serviceID)
+			delete(s.affinities, serviceID)
+			delete(s.histograms, serviceID)
+		}
+	}
+
+	s.logger.Infof("Removed backend %s from proxy", backendID)
+}
+
+// statusRecorder keeps the status code written by the proxied backend,
+// the reverse proxy answers 502 itself if the backend can't be reached,
+// and the size of the response body
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  uint64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += uint64(n)
+
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
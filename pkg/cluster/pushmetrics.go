@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/pushmetrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// setupMetricsPush starts pushing the metrics of the node and shares the
+// config with the shims, which find no config if they aren't pushed
+func (a *Agent) setupMetricsPush(cfg *pushmetrics.Config) error {
+	if cfg == nil {
+		if err := os.Remove(defaults.MetricsPushConfigFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			a.logger.WithError(err).Warn("failed to remove metrics push config, shims may keep pushing metrics")
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(defaults.MetricsPushConfigFile), defaults.DataDirPerm); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// The shims don't know the name of the node
+	shared := *cfg
+	shared.Labels = maps.Clone(cfg.Labels)
+	if shared.Labels == nil {
+		shared.Labels = make(map[string]string, 1)
+	}
+
+	if _, ok := shared.Labels[cfg.NodeLabel]; !ok {
+		shared.Labels[cfg.NodeLabel] = a.cfg.NodeName
+	}
+
+	if err := pushmetrics.WriteConfig(defaults.MetricsPushConfigFile, &shared); err != nil {
+		return err
+	}
+
+	a.metricsPusher = pushmetrics.NewPusher(log.NewEntry(a.logger), &shared, a.pushSamples)
+	go a.metricsPusher.Run(context.Background())
+
+	return nil
+}
+
+// pushSamples returns the host metrics of the last state broadcast, the
+// running workloads and the egress counters of each tenant
+func (a *Agent) pushSamples() []pushmetrics.Sample {
+	now := time.Now()
+
+	state := a.states.localState()
+
+	var samples []pushmetrics.Sample
+
+	if state != nil {
+		host := state.GetHost()
+		samples = append(samples,
+			pushmetrics.Sample{Name: "hypercore_node_load1", Value: host.GetLoad1(), Time: now},
+			pushmetrics.Sample{Name: "hypercore_node_disk_free_bytes", Value: float64(host.GetDiskFree()) * 1024 * 1024, Time: now},
+			pushmetrics.Sample{Name: "hypercore_node_network_utilization", Value: host.GetNetworkUtilization(), Time: now},
+			pushmetrics.Sample{Name: "hypercore_node_memory_available_bytes", Value: float64(state.GetMemory()) * 1024 * 1024, Time: now},
+		)
+
+		workloads := make(map[string]int)
+		for _, workload := range state.GetWorkloads() {
+			workloads[workload.GetSourceRequest().GetTenant()]++
+		}
+
+		for tenant, count := range workloads {
+			samples = append(samples, pushmetrics.Sample{Name: "hypercore_tenant_workloads", Tenant: tenant, Value: float64(count), Time: now})
+		}
+	}
+
+	for _, counter := range a.tenantCounters() {
+		for tenant, value := range counter.values {
+			samples = append(samples, pushmetrics.Sample{Name: counter.name, Tenant: tenant, Value: float64(value), Counter: true, Time: now})
+		}
+	}
+
+	return samples
+}
@@ -0,0 +1,337 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Horizontal scaling decisions are taken every this many
+	// broadcast periods
+	horizontalScaleBroadcasts = 2
+	// Minimum time between two scaling actions on the same replica group
+	HorizontalScaleCooldown = time.Minute
+	// Share of the target latency a replica is removed below, if the
+	// group has no target RPS. Well below the target, so the remaining
+	// replicas don't end up above it
+	horizontalScaleDownLatency = 0.5
+
+	maxScaleEvents = 100
+)
+
+// ScaleEvent records a change in the replica count of a replica group
+type ScaleEvent struct {
+	Group  string
+	From   int
+	To     int
+	Reason string
+	At     time.Time
+}
+
+type replicaGroup struct {
+	source   *pb.VmSpawnRequest
+	replicas []string
+	requests uint64
+	// sum of request latencies, used to compute the group average
+	latencyMs float64
+}
+
+// horizontalAutoscaler adjusts the replica count of replica groups based on
+// the traffic observed by the service proxies across the cluster, only the
+// leader node (lowest alive member name) takes scaling decisions
+func (a *Agent) horizontalAutoscaler() {
+	lastScaled := make(map[string]time.Time)
+
+	ticker := time.NewTicker(a.broadcastPeriod * horizontalScaleBroadcasts)
+	for range ticker.C {
+		if !a.isLeader() {
+			continue
+		}
+
+		// The replicas added to all groups are placed in a single batch
+		var scaleUps []*pb.VmSpawnRequest
+
+		for name, group := range a.replicaGroups() {
+			// A new group is left to the request spawning its replicas
+			// for the cooldown, which stops them if it fails
+			last, seen := lastScaled[name]
+			if !seen {
+				lastScaled[name] = time.Now()
+
+				continue
+			}
+
+			if time.Since(last) < HorizontalScaleCooldown {
+				continue
+			}
+
+			desired, reason, err := a.desiredReplicas(group)
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to compute desired replicas for group %s", name)
+
+				continue
+			}
+
+			current := len(group.replicas)
+			if desired == current {
+				continue
+			}
+
+			lastScaled[name] = time.Now()
+			a.recordScaleEvent(ScaleEvent{Group: name, From: current, To: desired, Reason: reason, At: time.Now()})
+
+			if desired > current {
+				for range desired - current {
+					scaleUps = append(scaleUps, proto.Clone(group.source).(*pb.VmSpawnRequest))
+				}
+
+				continue
+			}
+
+			slices.Sort(group.replicas)
+			for _, id := range group.replicas[desired:] {
+				go func() {
+					if _, err := a.StopRequest(&pb.VmStopRequest{Id: id}); err != nil {
+						a.logger.WithError(err).Errorf("failed to scale down group %s", name)
+					}
+				}()
+			}
+		}
+
+		if len(scaleUps) == 0 {
+			continue
+		}
+
+		go func() {
+			err := a.spawnBatch(scaleUps, true, func(resp *pb.SpawnBatchResponse) error {
+				if resp.GetError() != "" {
+					a.logger.Errorf("failed to scale up group %s: %s", scaleUps[resp.GetIndex()].GetReplicaGroup(), resp.GetError())
+				}
+
+				return nil
+			})
+			if err != nil {
+				a.logger.WithError(err).Error("failed to scale up replica groups")
+			}
+		}()
+	}
+}
+
+func (a *Agent) isLeader() bool {
+	leader := ""
+
+	for _, member := range a.serf.Members() {
+		if member.Status != serf.StatusAlive {
+			continue
+		}
+
+		if leader == "" || member.Name < leader {
+			leader = member.Name
+		}
+	}
+
+	return leader == a.serf.LocalMember().Name
+}
+
+// replicaGroups aggregates the replicas and proxy metrics of every
+// horizontally scaled workload known to this node
+func (a *Agent) replicaGroups() map[string]*replicaGroup {
+	states := a.knownStates()
+	groups := make(map[string]*replicaGroup)
+
+	for _, state := range states {
+		for _, workload := range state.GetWorkloads() {
+			source := workload.GetSourceRequest()
+			if source.GetReplicaGroup() == "" || source.GetHorizontalScaling() == nil {
+				continue
+			}
+
+			group, ok := groups[source.GetReplicaGroup()]
+			if !ok {
+				group = &replicaGroup{source: source}
+				groups[source.GetReplicaGroup()] = group
+			}
+
+			group.replicas = append(group.replicas, workload.GetId())
+		}
+	}
+
+	for _, state := range states {
+		for _, metrics := range state.GetServiceMetrics() {
+			if group, ok := groups[metrics.GetServiceId()]; ok {
+				group.requests += metrics.GetRequests()
+				group.latencyMs += metrics.GetAvgLatencyMs() * float64(metrics.GetRequests())
+			}
+		}
+	}
+
+	return groups
+}
+
+func (a *Agent) desiredReplicas(group *replicaGroup) (int, string, error) {
+	policy := group.source.GetHorizontalScaling()
+	current := len(group.replicas)
+	desired := current
+	reason := ""
+
+	load := float64(group.requests) / a.broadcastPeriod.Seconds()
+	loadSource := "proxy RPS"
+
+	if policy.GetPrometheusQuery() != "" && a.prometheusURL != "" {
+		value, err := queryPrometheus(a.prometheusURL, policy.GetPrometheusQuery())
+		if err != nil {
+			return 0, "", err
+		}
+
+		load = value
+		loadSource = "prometheus query"
+	}
+
+	if policy.GetTargetRps() > 0 {
+		desired = int(math.Ceil(load / policy.GetTargetRps()))
+		reason = fmt.Sprintf("%s %.2f with target %.2f per replica", loadSource, load, policy.GetTargetRps())
+	}
+
+	if target := float64(policy.GetTargetLatencyMs()); target > 0 {
+		// A group without requests has no latency, it is idle
+		avgLatency := 0.0
+		if group.requests > 0 {
+			avgLatency = group.latencyMs / float64(group.requests)
+		}
+
+		switch {
+		case avgLatency > target && desired <= current:
+			desired = current + 1
+			reason = fmt.Sprintf("average latency %.2fms above target %dms", avgLatency, policy.GetTargetLatencyMs())
+		case policy.GetTargetRps() == 0 && avgLatency < target*horizontalScaleDownLatency:
+			desired = current - 1
+			reason = fmt.Sprintf("average latency %.2fms below %.0f%% of target %dms", avgLatency, horizontalScaleDownLatency*100, policy.GetTargetLatencyMs())
+		}
+	}
+
+	desired = max(desired, int(max(policy.GetMinReplicas(), 1)))
+	desired = min(desired, int(max(policy.GetMaxReplicas(), policy.GetMinReplicas(), 1)))
+
+	return desired, reason, nil
+}
+
+func (a *Agent) recordScaleEvent(event ScaleEvent) {
+	a.logger.Infof("Scaling group %s from %d to %d replicas: %s", event.Group, event.From, event.To, event.Reason)
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_SCALE,
+		Node:         &pb.Node{Id: a.serf.LocalMember().Name},
+		Id:           event.Group,
+		Message:      fmt.Sprintf("scaling from %d to %d replicas: %s", event.From, event.To, event.Reason),
+		ReplicaGroup: event.Group,
+	})
+
+	a.scaleEventsMu.Lock()
+	defer a.scaleEventsMu.Unlock()
+
+	a.scaleEvents = append(a.scaleEvents, event)
+	if len(a.scaleEvents) > maxScaleEvents {
+		a.scaleEvents = a.scaleEvents[len(a.scaleEvents)-maxScaleEvents:]
+	}
+}
+
+// ScaleEvents returns the most recent scaling actions taken by this node
+func (a *Agent) ScaleEvents() []ScaleEvent {
+	a.scaleEventsMu.Lock()
+	defer a.scaleEventsMu.Unlock()
+
+	return slices.Clone(a.scaleEvents)
+}
+
+// queryPrometheus evaluates an instant query, summing up the
+// values in case the result is a vector
+func queryPrometheus(baseURL, query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode prometheus response: %w", err)
+	}
+
+	if result.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", result.Error)
+	}
+
+	switch result.Data.ResultType {
+	case "scalar":
+		var sample []interface{}
+		if err := json.Unmarshal(result.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+
+		return parsePrometheusValue(sample)
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(result.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+
+		total := 0.0
+		for _, sample := range vector {
+			value, err := parsePrometheusValue(sample.Value)
+			if err != nil {
+				return 0, err
+			}
+
+			total += value
+		}
+
+		return total, nil
+	}
+
+	return 0, fmt.Errorf("unsupported prometheus result type: %s", result.Data.ResultType)
+}
+
+// Samples are encoded as [<unix time>, "<value>"]
+func parsePrometheusValue(sample []interface{}) (float64, error) {
+	if len(sample) != 2 {
+		return 0, errors.New("malformed prometheus sample")
+	}
+
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, errors.New("malformed prometheus sample value")
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
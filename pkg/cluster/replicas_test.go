@@ -0,0 +1,70 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// testGroup returns a group of current replicas that served requests
+// with the average latency over a broadcast period
+func testGroup(policy *pb.HorizontalScalingPolicy, current int, requests uint64, avgLatencyMs float64) *replicaGroup {
+	group := &replicaGroup{
+		source:    &pb.VmSpawnRequest{ReplicaGroup: "web", HorizontalScaling: policy},
+		requests:  requests,
+		latencyMs: avgLatencyMs * float64(requests),
+	}
+
+	for i := range current {
+		group.replicas = append(group.replicas, fmt.Sprintf("replica-%d", i))
+	}
+
+	return group
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	rps := &pb.HorizontalScalingPolicy{MinReplicas: 1, MaxReplicas: 10, TargetRps: 10}
+	latency := &pb.HorizontalScalingPolicy{MinReplicas: 2, MaxReplicas: 5, TargetLatencyMs: 100}
+	both := &pb.HorizontalScalingPolicy{MinReplicas: 1, MaxReplicas: 10, TargetRps: 10, TargetLatencyMs: 100}
+
+	tests := []struct {
+		name     string
+		group    *replicaGroup
+		expected int
+	}{
+		{name: "RPS scale up", group: testGroup(rps, 2, 50, 10), expected: 5},
+		{name: "RPS scale down", group: testGroup(rps, 4, 15, 10), expected: 2},
+		{name: "RPS at target", group: testGroup(rps, 3, 30, 10), expected: 3},
+		{name: "RPS max clamp", group: testGroup(rps, 4, 1000, 10), expected: 10},
+		{name: "RPS min clamp", group: testGroup(rps, 3, 0, 0), expected: 1},
+
+		{name: "latency scale up", group: testGroup(latency, 3, 100, 150), expected: 4},
+		{name: "latency scale down", group: testGroup(latency, 4, 100, 20), expected: 3},
+		{name: "latency below target", group: testGroup(latency, 3, 100, 80), expected: 3},
+		{name: "idle latency group", group: testGroup(latency, 4, 0, 0), expected: 3},
+		{name: "latency max clamp", group: testGroup(latency, 5, 100, 150), expected: 5},
+		{name: "latency min clamp", group: testGroup(latency, 2, 100, 20), expected: 2},
+		{name: "latency below min replicas", group: testGroup(latency, 1, 100, 80), expected: 2},
+
+		{name: "latency adds to RPS", group: testGroup(both, 3, 30, 150), expected: 4},
+		// The RPS decides how many replicas are removed
+		{name: "low latency with RPS", group: testGroup(both, 3, 30, 20), expected: 3},
+	}
+
+	agent := &Agent{broadcastPeriod: time.Second}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired, reason, err := agent.desiredReplicas(tt.group)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if desired != tt.expected {
+				t.Fatalf("expected %d replicas, got %d (%s)", tt.expected, desired, reason)
+			}
+		})
+	}
+}
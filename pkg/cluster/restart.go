@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+)
+
+// RestartRequest stops a workload gracefully and creates it again with the
+// same ID on the node running it, forwarding the request to that node
+func (a *Agent) RestartRequest(ctx context.Context, req *pb.RestartRequest) (*pb.RestartResponse, error) {
+	if req.GetId() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "ID of the workload is required")
+	}
+
+	ctrCtx := a.ctrRepo.GetContext(ctx)
+
+	container, err := a.ctrRepo.GetContainer(ctrCtx, req.GetId())
+	if err == nil {
+		return a.restartLocalWorkload(ctrCtx, container)
+	}
+
+	if !errdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get container %s: %w", req.GetId(), err)
+	}
+
+	nodeName := a.workloadNode(req.GetId())
+	if nodeName == "" {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
+
+	member := a.findMember(nodeName)
+	if member == nil || member.Tags[GrpcPortTag] == "" {
+		return nil, fmt.Errorf("node %s running workload %s is not reachable", nodeName, req.GetId())
+	}
+
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return pb.NewClusterServiceClient(conn).Restart(forwardedContext(ctx), req)
+}
+
+// restartLocalWorkload drains and stops a workload like a stop request
+// does, then creates its container again from its labels. The workload
+// keeps its ID, so its URL, logs, volumes and host ports, and it is
+// registered with the proxies again once ready
+func (a *Agent) restartLocalWorkload(ctx context.Context, container containerd.Container) (*pb.RestartResponse, error) {
+	id := container.ID()
+
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels for container %s: %w", id, err)
+	}
+
+	var spec pb.VmSpawnRequest
+	if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &spec); err != nil {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "container %s is not a cluster workload", id)
+	}
+
+	// monitorWorkloads neither respawns nor broadcasts the workload while
+	// it is draining
+	if !a.draining.start(id) {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "workload %s is already being stopped", id)
+	}
+	defer a.draining.done(id)
+
+	a.logger.Infof("Restarting workload %s", id)
+
+	a.serviceProxy.Deregister(id)
+	a.broadcastDrain([]string{id})
+
+	if err := a.stopWorkload(id, &spec); err != nil {
+		return nil, fmt.Errorf("failed to stop workload %s: %w", id, err)
+	}
+
+	a.inits.done(id)
+
+	opts, err := a.workloadOpts(ctx, id, &spec, maps.Clone(labels))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.InitSteps) > 0 {
+		a.inits.start(id, len(opts.InitSteps))
+	}
+
+	// The resources of the workload were just released, so it isn't
+	// queued behind other spawns
+	if _, err := a.ctrRepo.CreateContainer(ctx, opts); err != nil {
+		a.inits.done(id)
+		a.publishEvent(&pb.WatchEventsResponse{
+			Event:        pb.ClusterEvent_ERROR,
+			Id:           id,
+			ReplicaGroup: spec.GetReplicaGroup(),
+			Message:      fmt.Sprintf("failed to restart: %s", err),
+		})
+
+		return nil, fmt.Errorf("failed to create workload %s again: %w", id, err)
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_SPAWN,
+		Node:         &pb.Node{Id: a.serf.LocalMember().Name},
+		Id:           id,
+		ReplicaGroup: spec.GetReplicaGroup(),
+		Message:      "restarted",
+	})
+
+	return &pb.RestartResponse{Id: id, Node: a.serf.LocalMember().Name}, nil
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	RevisionEvent = "hypercore_revision"
+
+	// Revisions kept per workload, older ones can't be rolled back to
+	MaxRevisions = 10
+)
+
+// revisionStore holds the revision history of the updated workloads. Nodes
+// recording a revision broadcast it, so every node keeps the history of
+// the whole cluster, persisted to path
+type revisionStore struct {
+	mu        sync.Mutex
+	path      string
+	revisions map[string][]*pb.WorkloadRevision
+}
+
+func newRevisionStore(path string) (*revisionStore, error) {
+	store := &revisionStore{
+		path:      path,
+		revisions: make(map[string][]*pb.WorkloadRevision),
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read revisions %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(contents, &store.revisions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revisions %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// record adds a revision to the history of its workload, returning false
+// if it was already known
+func (s *revisionStore) record(revision *pb.WorkloadRevision) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.revisions[revision.GetId()]
+	for _, known := range history {
+		if known.GetRevision() == revision.GetRevision() {
+			return false, nil
+		}
+	}
+
+	history = append(history, proto.Clone(revision).(*pb.WorkloadRevision))
+	sort.Slice(history, func(i, j int) bool { return history[i].GetRevision() < history[j].GetRevision() })
+
+	if len(history) > MaxRevisions {
+		history = history[len(history)-MaxRevisions:]
+	}
+
+	s.revisions[revision.GetId()] = history
+
+	return true, s.persistLocked()
+}
+
+func (s *revisionStore) persistLocked() error {
+	contents, err := json.Marshal(s.revisions)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, defaults.DataFilePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// history returns the revisions of a workload, oldest first
+func (s *revisionStore) history(id string) []*pb.WorkloadRevision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := make([]*pb.WorkloadRevision, 0, len(s.revisions[id]))
+	for _, revision := range s.revisions[id] {
+		history = append(history, proto.Clone(revision).(*pb.WorkloadRevision))
+	}
+
+	return history
+}
+
+// forget drops the history of a deleted workload
+func (s *revisionStore) forget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revisions[id]; !ok {
+		return nil
+	}
+
+	delete(s.revisions, id)
+
+	return s.persistLocked()
+}
+
+// recordRevision records a revision and broadcasts it to the other nodes
+func (a *Agent) recordRevision(revision *pb.WorkloadRevision) error {
+	if _, err := a.revisions.record(revision); err != nil {
+		return fmt.Errorf("failed to record revision %d of %s: %w", revision.GetRevision(), revision.GetId(), err)
+	}
+
+	payload, err := proto.Marshal(revision)
+	if err != nil {
+		return err
+	}
+
+	if err := a.serf.UserEvent(RevisionEvent, payload, false); err != nil {
+		return fmt.Errorf("failed to broadcast revision %d of %s: %w", revision.GetRevision(), revision.GetId(), err)
+	}
+
+	return nil
+}
+
+func (a *Agent) handleRevisionEvent(payload []byte) {
+	var revision pb.WorkloadRevision
+	if err := proto.Unmarshal(payload, &revision); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal revision event")
+
+		return
+	}
+
+	if _, err := a.revisions.record(&revision); err != nil {
+		a.logger.WithError(err).Errorf("failed to record revision %d of %s", revision.GetRevision(), revision.GetId())
+	}
+}
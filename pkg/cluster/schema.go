@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"vistara-node/pkg/cgroup"
+	vcontainerd "vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// SchemaVersion of the gossip payloads sent by this node. Version 1
+	// covers the nodes that predate versioning, from version 2 on spawn
+	// requests carry their stop grace period explicitly
+	SchemaVersion = 2
+	// Oldest schema version of the payloads this node still understands
+	MinSchemaVersion = 1
+
+	// Serf tags advertising the schema version and the capabilities of
+	// the node
+	SchemaVersionTag = "schema"
+	CapabilitiesTag  = "caps"
+
+	// Spawn request fields nodes lacking the capability silently drop
+	CapabilityNames       = "names"
+	CapabilityLabels      = "labels"
+	CapabilityAnnotations = "annotations"
+	CapabilityMemoryHigh  = "memory-high"
+	CapabilityVolumes     = "volumes"
+
+	// Host features advertised along, KVM lets the guests run VMs of
+	// their own
+	CapabilityNestedVirt = "nested-virt"
+	// The disk holding the workloads is a block device their IO can be
+	// limited on
+	CapabilityIOLimits = "io-limits"
+	// The node has an object store to restore volumes from snapshots
+	CapabilityVolumeSnapshots = "volume-snapshots"
+)
+
+// nodeCapabilities are advertised in the serf tags of this node
+var nodeCapabilities = []string{CapabilityNames, CapabilityLabels, CapabilityAnnotations, CapabilityMemoryHigh, CapabilityVolumes}
+
+// hostCapabilities probes the features of the host advertised along with
+// nodeCapabilities
+func hostCapabilities() []string {
+	var capabilities []string
+
+	if err := shared.CheckNestedVirt(); err == nil {
+		capabilities = append(capabilities, CapabilityNestedVirt)
+	}
+
+	if _, err := cgroup.BlockDevice(defaults.DataRootDir); err == nil {
+		capabilities = append(capabilities, CapabilityIOLimits)
+	}
+
+	return capabilities
+}
+
+// memberCapabilities returns the capabilities a member advertises
+func memberCapabilities(member serf.Member) []string {
+	if member.Tags[CapabilitiesTag] == "" {
+		return nil
+	}
+
+	return strings.Split(member.Tags[CapabilitiesTag], ",")
+}
+
+// payloadSchemaVersion returns the schema version of a received payload,
+// payloads without one were sent by nodes that predate versioning
+func payloadSchemaVersion(version uint32) uint32 {
+	return max(version, 1)
+}
+
+// memberSchemaVersion returns the schema version a member advertises
+func memberSchemaVersion(member serf.Member) uint32 {
+	version, err := strconv.ParseUint(member.Tags[SchemaVersionTag], 10, 32)
+	if err != nil {
+		return payloadSchemaVersion(0)
+	}
+
+	return payloadSchemaVersion(uint32(version))
+}
+
+// knownEvent returns whether this node knows the event, nodes running a
+// newer version may send events it doesn't
+func knownEvent(event pb.ClusterEvent) bool {
+	_, ok := pb.ClusterEvent_name[int32(event)]
+
+	return ok
+}
+
+// upgradeSpawnRequest translates a spawn request sent with an older
+// schema version to the current one
+func upgradeSpawnRequest(version uint32, req *pb.VmSpawnRequest) {
+	if req == nil {
+		return
+	}
+
+	// Version 1 nodes left the grace period unset for the default one
+	if version < 2 && req.GetStopGracePeriodSeconds() == 0 {
+		req.StopGracePeriodSeconds = uint32(vcontainerd.DefaultStopTimeout / time.Second)
+	}
+}
+
+// upgradeNodeState translates the state broadcast by a node running an
+// older schema version
+func upgradeNodeState(state *pb.NodeStateResponse) {
+	version := payloadSchemaVersion(state.GetSchemaVersion())
+
+	for _, workload := range state.GetWorkloads() {
+		upgradeSpawnRequest(version, workload.GetSourceRequest())
+	}
+}
+
+// requiredCapabilities returns the capabilities a node needs to spawn the
+// request without dropping some of its fields
+func requiredCapabilities(req *pb.VmSpawnRequest) []string {
+	var required []string
+
+	if req.GetName() != "" {
+		required = append(required, CapabilityNames)
+	}
+
+	if len(req.GetLabels()) > 0 {
+		required = append(required, CapabilityLabels)
+	}
+
+	if len(req.GetAnnotations()) > 0 {
+		required = append(required, CapabilityAnnotations)
+	}
+
+	if req.GetMemoryHigh() != 0 {
+		required = append(required, CapabilityMemoryHigh)
+	}
+
+	if req.GetIoLimits() != nil {
+		required = append(required, CapabilityIOLimits)
+	}
+
+	if len(req.GetVolumes()) > 0 {
+		required = append(required, CapabilityVolumes)
+	}
+
+	for _, volume := range req.GetVolumes() {
+		if volume.GetFromSnapshot() != "" {
+			required = append(required, CapabilityVolumeSnapshots)
+
+			break
+		}
+	}
+
+	return required
+}
+
+// missingCapabilities returns the capabilities required by the request
+// the node doesn't advertise
+func (a *Agent) missingCapabilities(node string, req *pb.VmSpawnRequest) []string {
+	required := requiredCapabilities(req)
+	if len(required) == 0 {
+		return nil
+	}
+
+	var advertised []string
+	if member := a.findMember(node); member != nil {
+		advertised = memberCapabilities(*member)
+	}
+
+	return slices.DeleteFunc(required, func(capability string) bool {
+		return slices.Contains(advertised, capability)
+	})
+}
+
+// capableNodes leaves out the nodes that would drop fields of the
+// request, failing if none is left
+func (a *Agent) capableNodes(nodes []string, req *pb.VmSpawnRequest) ([]string, error) {
+	if len(nodes) == 0 || len(requiredCapabilities(req)) == 0 {
+		return nodes, nil
+	}
+
+	var capable []string
+
+	for _, node := range nodes {
+		if missing := a.missingCapabilities(node, req); len(missing) > 0 {
+			a.logger.Infof("Not placing workload on node %s, it doesn't support %s", node, strings.Join(missing, ", "))
+
+			continue
+		}
+
+		capable = append(capable, node)
+	}
+
+	if len(capable) == 0 {
+		required := strings.Join(requiredCapabilities(req), ", ")
+
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"capabilities": required},
+			"no node supports %s, upgrade the nodes to spawn the workload", required)
+	}
+
+	return capable, nil
+}
+
+// checkMemberSchema logs the members whose payloads this node can't
+// fully understand, or that can't understand its own
+func (a *Agent) checkMemberSchema(member serf.Member) {
+	version := memberSchemaVersion(member)
+
+	switch {
+	case version < MinSchemaVersion:
+		a.logger.Warnf("Node %s speaks schema version %d, older than the oldest supported version %d, its payloads are ignored", member.Name, version, MinSchemaVersion)
+	case version > SchemaVersion:
+		a.logger.Infof("Node %s speaks newer schema version %d, the events it sends this node doesn't know are ignored", member.Name, version)
+	case version < SchemaVersion:
+		a.logger.Infof("Node %s speaks older schema version %d, its payloads are translated to version %d", member.Name, version, SchemaVersion)
+	}
+}
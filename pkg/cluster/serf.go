@@ -1,20 +1,33 @@
 package cluster
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"vistara-node/pkg/cgroup"
 	vcontainerd "vistara-node/pkg/containerd"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
 	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/proto/shimdebug"
+	"vistara-node/pkg/pushmetrics"
+	"vistara-node/pkg/resource"
 
+	"github.com/containerd/containerd"
 	ctask "github.com/containerd/containerd/api/types/task"
-	"github.com/containerd/containerd/cio"
 	"github.com/google/uuid"
 	"github.com/hashicorp/serf/serf"
 	log "github.com/sirupsen/logrus"
@@ -26,36 +39,241 @@ const (
 	QueryName           = "hypercore_query"
 	SpawnRequestLabel   = "hypercore-request-payload"
 	StateBroadcastEvent = "hypercore_state_broadcast"
-
-	WorkloadBroadcastPeriod = time.Second * 5
+	OOMEvent            = "hypercore_oom_event"
+	OOMCountLabel       = "hypercore-oom-count"
+	IdempotencyKeyLabel = "hypercore-idempotency-key"
+	// Serf tag advertising the port of the node's gRPC server
+	GrpcPortTag = "grpc_port"
+
+	DefaultWorkloadBroadcastPeriod = time.Second * 5
+	// Number of OOM kills after which a respawned workload
+	// gets its memory allocation bumped
+	OOMRespawnThreshold = 2
 )
 
-type SavedStatusUpdate struct {
-	update     *pb.NodeStateResponse
-	receivedAt time.Time
+// AgentConfig holds the node level settings of the cluster agent
+type AgentConfig struct {
+	BaseURL      string
+	BindAddr     string
+	GrpcBindAddr string
+	// Whether this node monitors other cluster nodes and re-schedules
+	// their workloads on failure
+	Respawn bool
+	// Upper bound (in MB) for memory bumps on repeated OOMs, 0 disables it
+	OOMMemoryCeiling uint32
+	// Prometheus server used to evaluate horizontal scaling queries
+	PrometheusURL string
+	// Certificate of the service proxy, nil to serve plain HTTP
+	ProxyCerts *CertSource
+	// Client TLS settings used to forward requests to
+	// the gRPC server of other nodes, nil for plaintext
+	GrpcClientTLS *tls.Config
+	// Compression of the requests forwarded to other nodes, empty for none
+	GrpcCompression string
+	// Container creations running at once on this node, further
+	// spawns wait in a queue of up to MaxQueuedSpawns
+	MaxConcurrentCreates int
+	MaxQueuedSpawns      int
+	// Spawn requests per second allowed for each tenant, 0 disables
+	// rate limiting, along with the burst allowed above that rate
+	SpawnRateLimit float64
+	SpawnRateBurst int
+	// Directory the workload logs are written to, WorkloadLogDir if empty
+	LogDir string
+	// Size the log file of a workload is capped at, its oldest half being
+	// dropped past it, 0 for no cap
+	MaxLogSize int64
+	// Directory holding the containerd state and snapshots, whose free
+	// space is broadcast, defaults.DataRootDir if empty
+	DataDir string
+	// Prices the workloads of this node are billed at, advertised
+	// along with the node state
+	Prices *pb.NodePrices
+	// File the billing records of this node are persisted to,
+	// billing.json in DataDir if empty
+	BillingFile string
+	// Egress policies of the tenants, keyed by tenant with
+	// DefaultEgressPolicyKey for the others, nil for no restrictions
+	EgressPolicies map[string]*models.EgressPolicy
+	// Address the egress proxy listens on, if any of the policies
+	// proxies HTTP(S) connections
+	EgressProxyAddr string
+	// Address the metadata service serving the workloads their identity
+	// listens on, disabled if empty
+	MetadataAddr string
+	// Track the connections of the workloads in their network namespace
+	// and sample them into the topology of the cluster
+	TrackConnections bool
+	// Count the traffic of the workloads with eBPF programs on the
+	// interface of their network namespace, for billing and Prometheus
+	EBPFNetworkMetrics bool
+	// Lifetime of the identity tokens of the workloads,
+	// DefaultMetadataTokenTTL if zero
+	MetadataTokenTTL time.Duration
+	// Age of the key of this node signing the identity tokens it is
+	// replaced past, DefaultSigningKeyRotation if zero
+	SigningKeyRotation time.Duration
+	// Trust domain of the SPIFFE IDs the identity tokens are issued to,
+	// the bare workload IDs if empty
+	TrustDomain string
+	// Registries and repositories the workloads can run images of, nil
+	// for no restrictions
+	ImagePolicy *ImagePolicy
+	// Sidecars injected into the pods of the matching workloads, nil for
+	// none
+	SidecarPolicy *SidecarPolicy
+	// Scrape the metrics endpoints the workloads declare and serve their
+	// metrics along with the node's own
+	ScrapeWorkloadMetrics bool
+	// Pull the images placed repeatedly ahead on the next best nodes, so
+	// their replicas are respawned fast on them, and pull those the
+	// other nodes ask this one to
+	WarmImages bool
+	// Object store the persistent volumes of this node are snapshotted
+	// to and restored from, nil to disable snapshots
+	VolumeSnapshots *VolumeSnapshotConfig
+	// Push export of the node metrics, shared with the shims of the
+	// node, nil to only serve them
+	MetricsPush *pushmetrics.Config
+	// Webhooks alerted of critical events, nil for none
+	Alerts *AlertConfig
+	// External systems the endpoints of the services are published to,
+	// nil for none
+	EndpointPublish *EndpointPublishConfig
+	// Resolver of the workloads spawned without a DNS config, e.g. the
+	// CoreDNS serving the endpoint zone, the one of the node if nil
+	ClusterDNS *models.DNSConfig
+	// Maintenance windows of this node, nil for none
+	Maintenance *MaintenanceConfig
+	// How long before the maintenance window of a node new workloads
+	// stop being placed on it, DefaultMaintenanceLead if zero
+	MaintenanceLead time.Duration
+	// Period of the workload state broadcasts, nodes missing three
+	// broadcasts are considered failed. DefaultWorkloadBroadcastPeriod
+	// if zero
+	BroadcastPeriod time.Duration
+	// Serf tuning, the serf defaults are kept for zero values
+	GossipInterval     time.Duration
+	ProbeInterval      time.Duration
+	UserEventSizeLimit int
+	QueueDepthWarning  int
+	MaxQueueDepth      int
+}
+
+// ContainerRepo is the part of the containerd repository the agent uses,
+// so several agents can run in a single process against fake repositories
+type ContainerRepo interface {
+	GetContext(ctx context.Context) context.Context
+	GetTasks(ctx context.Context) ([]*ctask.Process, error)
+	GetContainer(ctx context.Context, id string) (containerd.Container, error)
+	ListContainers(ctx context.Context, filters ...string) ([]containerd.Container, error)
+	GetContainerPrimaryIP(ctx context.Context, containerID string) (string, error)
+	CreateContainer(ctx context.Context, opts vcontainerd.CreateContainerOpts) (string, error)
+	PullImage(ctx context.Context, ref string) error
+	UpdateContainerResources(ctx context.Context, containerID string, cpuFraction float64, memoryBytes uint64) error
+	DeleteContainer(ctx context.Context, containerID string, stopTimeout time.Duration) (uint32, error)
+	ExecContainer(ctx context.Context, containerID string, args []string) (uint32, error)
+	KillContainer(ctx context.Context, containerID string, signal syscall.Signal) error
+	SubscribeOOMEvents(ctx context.Context) (<-chan string, <-chan error)
+	SubscribeVMCrashedEvents(ctx context.Context) (<-chan *shimdebug.VMCrashed, <-chan error)
+	IngressRuleset(ctx context.Context, id string) (string, error)
+	ConnectionFlows(ctx context.Context, id string) ([]network.Flow, error)
+	TrafficCounters(ctx context.Context, id string) (network.TrafficCounters, error)
+	AdoptContainers(ctx context.Context, filter string) ([]string, error)
+	AdoptContainer(ctx context.Context, id string, labels map[string]string) error
 }
 
+var _ ContainerRepo = (*vcontainerd.Repo)(nil)
+
 type Agent struct {
-	eventCh         chan serf.Event
-	serviceProxy    *ServiceProxy
-	ctrRepo         *vcontainerd.Repo
-	cfg             *serf.Config
-	serf            *serf.Serf
-	baseURL         string
-	logger          *log.Logger
-	lastStateMu     sync.Mutex
-	lastStateUpdate map[string]SavedStatusUpdate
-}
-
-func NewAgent(logger *log.Logger, baseURL, bindAddr string, respawn bool, repo *vcontainerd.Repo, tlsConfig *TLSConfig) (*Agent, error) {
+	eventCh      chan serf.Event
+	serviceProxy *ServiceProxy
+	ctrRepo      ContainerRepo
+	cfg          *serf.Config
+	serf         *serf.Serf
+	baseURL      string
+	logger       *log.Logger
+	// Workload states of this node and of the other nodes
+	states *stateStore
+	// Upper bound (in MB) for memory bumps on repeated OOMs, 0 disables it
+	oomMemoryCeiling uint32
+	oomMu            sync.Mutex
+	oomCounts        map[string]uint32
+	vmFailureMu      sync.Mutex
+	vmFailures       map[string]*shimdebug.VMCrashed
+	prometheusURL    string
+	scaleEventsMu    sync.Mutex
+	scaleEvents      []ScaleEvent
+	events           *eventBroker
+	grpcClientTLS    *tls.Config
+	grpcCompression  string
+	applies          *applyState
+	names            *workloadNames
+	admission        *admissionQueue
+	rateLimits       *tenantLimiter
+	spawnKeys        *spawnKeys
+	logDir           string
+	maxLogSize       int64
+	faults           *faultInjector
+	broadcastPeriod  time.Duration
+	hostSampler      *hostSampler
+	prices           *pb.NodePrices
+	billing          *billingLedger
+	usage            *usageHistory
+	metricsHistory   *metricsHistory
+	pending          *pendingQueue
+	imageCache       *imageCache
+	volumes          *volumeLocations
+	volumeDir        string
+	volumeSnapshots  *volumeSnapshotter
+	revisions        *revisionStore
+	updates          *updateState
+	probes           *probeManager
+	draining         *drainingSet
+	inits            *initTracker
+	egressPolicies   map[string]*models.EgressPolicy
+	egressProxy      *egressProxy
+	imagePolicy      *ImagePolicy
+	sidecarPolicy    *SidecarPolicy
+	clusterDNS       *models.DNSConfig
+	maintenanceLead  time.Duration
+	// Serializes the updates of the serf tags of this node
+	tagsMu sync.Mutex
+	// nil unless the metrics of the workloads are scraped
+	workloadMetrics *workloadMetrics
+	// nil unless the metrics of the node are pushed
+	metricsPusher *pushmetrics.Pusher
+	// nil unless alerts are sent
+	alerts *alerter
+	// nil unless images are pulled ahead of the placements
+	placements *placementTracker
+	// nil unless the connections of the workloads are tracked
+	topology *topologyTracker
+	// nil unless the traffic of the workloads is counted with eBPF
+	traffic *workloadTraffic
+	// nil unless the metadata service is enabled
+	metadata    *metadataServer
+	signingKeys *signingKeys
+	// Certificate of the node issued by the cluster CA its signing keys
+	// are published with, nil if it has none
+	clusterCerts *CertSource
+	// SPIFFE trust domain of the identity tokens, empty for none
+	trustDomain string
+}
+
+func NewAgent(logger *log.Logger, agentConfig *AgentConfig, repo ContainerRepo) (*Agent, error) {
+	if err := agentConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+
 	eventCh := make(chan serf.Event, 64)
 
-	serviceProxy, err := NewServiceProxy(logger, tlsConfig)
+	serviceProxy, err := NewServiceProxy(logger, agentConfig.ProxyCerts)
 	if err != nil {
 		return nil, err
 	}
 
-	addr, port, err := net.SplitHostPort(bindAddr)
+	addr, port, err := net.SplitHostPort(agentConfig.BindAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -71,43 +289,256 @@ func NewAgent(logger *log.Logger, baseURL, bindAddr string, respawn bool, repo *
 	cfg.MemberlistConfig.BindAddr = addr
 	cfg.MemberlistConfig.BindPort = bindPort
 	cfg.MemberlistConfig.AdvertisePort = bindPort
+	agentConfig.applySerfTuning(cfg)
 	cfg.Init()
 
+	if agentConfig.GrpcBindAddr != "" {
+		_, grpcPort, err := net.SplitHostPort(agentConfig.GrpcBindAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Tags[GrpcPortTag] = grpcPort
+	}
+
+	cfg.Tags[SchemaVersionTag] = strconv.Itoa(SchemaVersion)
+	capabilities := append(slices.Clone(nodeCapabilities), hostCapabilities()...)
+	if agentConfig.VolumeSnapshots != nil {
+		capabilities = append(capabilities, CapabilityVolumeSnapshots)
+	}
+
+	cfg.Tags[CapabilitiesTag] = strings.Join(capabilities, ",")
+	cfg.Tags[NodeStatusTag] = nodeStatusTags[pb.NodeStatus_NODE_JOINING]
+	cfg.Tags[StartedTag] = strconv.FormatInt(time.Now().Unix(), 10)
+
 	serf, err := serf.Create(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	agent := &Agent{
-		eventCh:         eventCh,
-		cfg:             cfg,
-		baseURL:         baseURL,
-		serviceProxy:    serviceProxy,
-		serf:            serf,
-		logger:          logger,
-		ctrRepo:         repo,
-		lastStateUpdate: make(map[string]SavedStatusUpdate),
+		eventCh:      eventCh,
+		cfg:          cfg,
+		baseURL:      agentConfig.BaseURL,
+		serviceProxy: serviceProxy,
+		clusterCerts: agentConfig.ProxyCerts,
+		serf:         serf,
+		logger:       logger,
+		ctrRepo:      repo,
+
+		oomMemoryCeiling: agentConfig.OOMMemoryCeiling,
+		oomCounts:        make(map[string]uint32),
+		vmFailures:       make(map[string]*shimdebug.VMCrashed),
+		prometheusURL:    agentConfig.PrometheusURL,
+		events:           newEventBroker(),
+		grpcClientTLS:    agentConfig.GrpcClientTLS,
+		grpcCompression:  agentConfig.GrpcCompression,
+		applies:          newApplyState(),
+		names:            newWorkloadNames(),
+		admission:        newAdmissionQueue(agentConfig.MaxConcurrentCreates, agentConfig.MaxQueuedSpawns),
+		rateLimits:       newTenantLimiter(agentConfig.SpawnRateLimit, agentConfig.SpawnRateBurst),
+		spawnKeys:        &spawnKeys{inFlight: make(map[string]string)},
+		logDir:           agentConfig.LogDir,
+		maxLogSize:       agentConfig.MaxLogSize,
+		faults:           newFaultInjector(),
+		inits:            newInitTracker(),
+		updates:          &updateState{inProgress: make(map[string]struct{})},
+		probes:           newProbeManager(),
+		draining:         &drainingSet{ids: make(map[string]struct{})},
+		broadcastPeriod:  agentConfig.BroadcastPeriod,
+		trustDomain:      agentConfig.TrustDomain,
+		egressPolicies:   agentConfig.EgressPolicies,
+		imagePolicy:      agentConfig.ImagePolicy,
+		sidecarPolicy:    agentConfig.SidecarPolicy,
+		clusterDNS:       agentConfig.ClusterDNS,
+		maintenanceLead:  agentConfig.MaintenanceLead,
+		usage:            newUsageHistory(),
+		metricsHistory:   newMetricsHistory(),
+		pending:          newPendingQueue(),
+		imageCache:       &imageCache{},
+		volumes:          newVolumeLocations(),
+	}
+
+	if agentConfig.WarmImages {
+		agent.placements = newPlacementTracker()
+	}
+
+	if agentConfig.VolumeSnapshots != nil {
+		agent.volumeSnapshots = &volumeSnapshotter{cfg: agentConfig.VolumeSnapshots}
+	}
+
+	if agent.logDir == "" {
+		agent.logDir = WorkloadLogDir
+	}
+
+	if agent.broadcastPeriod == 0 {
+		agent.broadcastPeriod = DefaultWorkloadBroadcastPeriod
+	}
+
+	if agent.maintenanceLead == 0 {
+		agent.maintenanceLead = DefaultMaintenanceLead
+	}
+
+	agent.states = newStateStore(agent.broadcastPeriod * 3)
+
+	dataDir := agentConfig.DataDir
+	if dataDir == "" {
+		dataDir = defaults.DataRootDir
+	}
+	agent.hostSampler = newHostSampler(dataDir)
+	agent.volumeDir = filepath.Join(dataDir, volumesDirName)
+
+	billingFile := agentConfig.BillingFile
+	if billingFile == "" {
+		billingFile = filepath.Join(dataDir, billingFileName)
+	}
+
+	agent.billing, err = newBillingLedger(billingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	agent.revisions, err = newRevisionStore(filepath.Join(dataDir, revisionsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	agent.prices = agentConfig.Prices
+	if agent.prices == nil {
+		agent.prices = &pb.NodePrices{}
+	}
+
+	if agentConfig.ScrapeWorkloadMetrics {
+		agent.workloadMetrics = newWorkloadMetrics()
+	}
+
+	for _, policy := range agent.egressPolicies {
+		if policy.Proxy {
+			agent.egressProxy, err = newEgressProxy(agent, agentConfig.EgressProxyAddr)
+			if err != nil {
+				return nil, err
+			}
+
+			go agent.egressProxy.serve()
+
+			break
+		}
+	}
+
+	if agentConfig.MetadataAddr != "" {
+		tokenTTL := cmp.Or(agentConfig.MetadataTokenTTL, DefaultMetadataTokenTTL)
+		rotation := cmp.Or(agentConfig.SigningKeyRotation, DefaultSigningKeyRotation)
+
+		agent.signingKeys, err = newSigningKeys(filepath.Join(dataDir, signingKeysFileName), rotation, tokenTTL, agent.broadcastPeriod)
+		if err != nil {
+			return nil, err
+		}
+
+		agent.metadata, err = newMetadataServer(agent, agentConfig.MetadataAddr, tokenTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		go agent.metadata.serve()
+	}
+
+	if alerts := agentConfig.Alerts; alerts != nil && len(alerts.Webhooks)+len(alerts.SlackWebhooks) > 0 {
+		agent.alerts = newAlerter(logger, alerts, cfg.NodeName)
+	}
+
+	if agentConfig.EndpointPublish.enabled() {
+		newEndpointPublisher(logger, agentConfig.EndpointPublish, cfg.NodeName, serviceProxy)
+	}
+
+	if err := agent.setupMetricsPush(agentConfig.MetricsPush); err != nil {
+		return nil, err
+	}
+
+	if agentConfig.TrackConnections {
+		agent.topology = newTopologyTracker()
 	}
+
+	if agentConfig.EBPFNetworkMetrics {
+		agent.traffic = newWorkloadTraffic()
+	}
+
+	agent.adoptWorkloads()
+
+	go agent.restoreWorkloads(dataDir)
+
 	go agent.monitorWorkloads()
+	go agent.monitorOOMEvents()
+	go agent.monitorVMCrashedEvents()
+	go agent.verticalAutoscaler()
+	go agent.horizontalAutoscaler()
+	go agent.killWorkloads()
+	go agent.meterUsage()
+
+	if agent.topology != nil {
+		go agent.sampleTopology()
+	}
+
+	go agent.recordMetrics()
+	go agent.retryPendingSpawns()
+
+	if agent.maxLogSize > 0 {
+		go agent.trimWorkloadLogs()
+	}
+
+	if agentConfig.VolumeSnapshots != nil && agentConfig.VolumeSnapshots.Interval > 0 {
+		go agent.snapshotVolumes()
+	}
 
-	if respawn {
+	if agentConfig.Respawn {
 		go agent.monitorStateUpdates()
 	}
 
+	if agentConfig.Maintenance.enabled() {
+		schedules, err := agentConfig.Maintenance.schedules()
+		if err != nil {
+			return nil, err
+		}
+
+		go agent.runMaintenanceWindows(agentConfig.Maintenance, schedules)
+	}
+
 	return agent, nil
 }
 
-func (a *Agent) handleSpawnRequest(payload *pb.VmSpawnRequest) (ret []byte, retErr error) {
+func (a *Agent) handleSpawnRequest(payload *pb.VmSpawnRequest) ([]byte, error) {
+	return a.handleSpawnRequestWithLabels(payload, nil)
+}
+
+func (a *Agent) handleSpawnRequestWithLabels(payload *pb.VmSpawnRequest, extraLabels map[string]string) (ret []byte, retErr error) {
 	ctx := a.ctrRepo.GetContext(context.Background())
 
-	for _, port := range payload.GetPorts() {
-		if port > 0xffff {
-			return nil, fmt.Errorf("got invalid port %d greater than %d", port, 0xffff)
+	if err := validateSpawnRequest(payload); err != nil {
+		return nil, err
+	}
+
+	// The node sending the request already checked it against its own
+	// policy, the one pulling the image enforces its own
+	if !payload.GetDryRun() {
+		if err := a.checkImagePolicy(payload); err != nil {
+			return nil, err
 		}
 	}
 
 	if payload.GetDryRun() {
-		response, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, &pb.VmSpawnResponse{})
+		// Nodes that already spawned the request answer with the workload
+		dryRunResp := &pb.VmSpawnResponse{}
+		if key := payload.GetIdempotencyKey(); key != "" {
+			existing, err := a.existingSpawn(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				dryRunResp = existing
+			}
+		}
+
+		response, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, dryRunResp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to wrap cluster message: %w", err)
 		}
@@ -118,84 +549,278 @@ func (a *Agent) handleSpawnRequest(payload *pb.VmSpawnRequest) (ret []byte, retE
 	defer func() {
 		if retErr != nil {
 			a.logger.WithError(retErr).Error("handleSpawnRequest failed")
-			ret, retErr = wrapClusterErrorMessage(retErr.Error())
+			ret, retErr = wrapClusterError(retErr)
 		}
 	}()
 
-	tasks, err := a.ctrRepo.GetTasks(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get existing tasks to check capacity: %w", err)
-	}
+	// The ID is assigned upfront so it can be returned
+	// before a queued spawn runs
+	id := uuid.NewString()
+	labels := make(map[string]string)
 
-	vcpuUsed := 0
-	memUsed := 0
-	for _, task := range tasks {
-		container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
-		if err != nil {
-			return nil, fmt.Errorf("failed to get container %s: %w", task.GetID(), err)
-		}
+	// Whether the spawn was queued, in which case the idempotency
+	// key is released once it ran
+	queued := false
 
-		labels, err := container.Labels(ctx)
+	if key := payload.GetIdempotencyKey(); key != "" {
+		existing, err := a.claimSpawnKey(ctx, key, id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get labels for container %s: %w", task.GetID(), err)
+			return nil, err
 		}
 
-		var labelPayload pb.VmSpawnRequest
-		if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
-			return nil, err
+		if existing != nil {
+			a.logger.Infof("Spawn with idempotency key %s already handled as %s", key, existing.GetId())
+
+			return wrapClusterMessage(pb.ClusterEvent_SPAWN, existing)
 		}
 
-		vcpuUsed += int(labelPayload.GetCores())
-		memUsed += int(labelPayload.GetMemory())
+		defer func() {
+			if !queued {
+				a.releaseSpawnKey(key)
+			}
+		}()
+
+		labels[IdempotencyKeyLabel] = key
 	}
 
-	if (vcpuUsed + int(payload.GetCores())) > runtime.NumCPU() {
-		return nil, fmt.Errorf("cannot spawn container: have capacity for %d vCPUs, already in use: %d, requested: %d", runtime.NumCPU(), vcpuUsed, payload.GetCores())
+	if err := a.checkCapacity(ctx, payload); err != nil {
+		return nil, err
 	}
 
-	availableMem, err := getAvailableMem()
+	// We store the request payload as part of the container labels
+	// so we can later check whether we have spare vCPUs left
+	encodedPayload, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	availableMem /= 1024
 
-	if (memUsed + int(payload.GetMemory())) > int(availableMem) {
-		return nil, fmt.Errorf("cannot spawn container: have capacity for %d MB, already in use: %d MB, requested: %d MB", availableMem, memUsed, payload.GetMemory())
+	labels[SpawnRequestLabel] = string(encodedPayload)
+	for key, value := range extraLabels {
+		labels[key] = value
 	}
 
-	// We store the request payload as part of the container labels
-	// so we can later check whether we have spare vCPUs left
-	encodedPayload, err := json.Marshal(payload)
+	opts, err := a.workloadOpts(ctx, id, payload, labels)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := a.ctrRepo.CreateContainer(ctx, vcontainerd.CreateContainerOpts{
+	// Tracked before the task is created, so monitorWorkloads doesn't
+	// take it for one whose steps were lost
+	if len(opts.InitSteps) > 0 {
+		a.inits.start(id, len(opts.InitSteps))
+	}
+
+	spawnResp := &pb.VmSpawnResponse{Id: opts.ID, Url: opts.ID + "." + a.baseURL}
+
+	position, err := a.admission.admit(int(payload.GetCores()), int(payload.GetMemory()), func() {
+		if key := payload.GetIdempotencyKey(); key != "" {
+			defer a.releaseSpawnKey(key)
+		}
+
+		if _, err := a.ctrRepo.CreateContainer(ctx, opts); err != nil {
+			a.inits.done(opts.ID)
+			a.logger.WithError(err).Errorf("failed to spawn queued container %s", opts.ID)
+			a.publishEvent(&pb.WatchEventsResponse{
+				Event:        pb.ClusterEvent_ERROR,
+				Node:         &pb.Node{Id: a.serf.LocalMember().Name},
+				Id:           opts.ID,
+				Message:      fmt.Sprintf("failed to spawn queued workload: %s", err),
+				ReplicaGroup: payload.GetReplicaGroup(),
+			})
+		}
+	})
+	if errors.Is(err, errAdmissionQueueFull) {
+		a.alert(AlertQueueOverload, a.cfg.NodeName, "admission queue is full, rejecting spawns of workload %s", opts.ID)
+	}
+
+	if err != nil {
+		a.inits.done(opts.ID)
+
+		return nil, fmt.Errorf("cannot spawn container: %w", err)
+	}
+
+	if position > 0 {
+		a.logger.Infof("Queued spawn of container %s at position %d", opts.ID, position)
+
+		queued = true
+		spawnResp.Pending = true
+		spawnResp.QueuePosition = uint32(position)
+	} else {
+		defer a.admission.release()
+
+		if _, err := a.ctrRepo.CreateContainer(ctx, opts); err != nil {
+			a.inits.done(opts.ID)
+
+			return nil, fmt.Errorf("failed to spawn container: %w", err)
+		}
+	}
+
+	response, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, spawnResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap cluster message: %w", err)
+	}
+
+	return response, nil
+}
+
+// workloadOpts returns the options of the container of a workload spawned
+// with the ID and labels
+func (a *Agent) workloadOpts(ctx context.Context, id string, payload *pb.VmSpawnRequest, labels map[string]string) (vcontainerd.CreateContainerOpts, error) {
+	// The spawn request keeps the templates, so the workload gets the
+	// metadata of the node it is respawned on
+	envData := a.workloadEnvData(id, payload)
+
+	env, err := expandWorkloadEnv(payload.GetEnv(), envData)
+	if err != nil {
+		return vcontainerd.CreateContainerOpts{}, err
+	}
+
+	steps, err := initSteps(payload, envData)
+	if err != nil {
+		return vcontainerd.CreateContainerOpts{}, err
+	}
+
+	// Sidecars are injected by the node spawning the workload, and not
+	// kept in the spawn request, so respawns get the current policy
+	containers, err := podContainers(append(slices.Clone(payload.GetContainers()), a.injectedSidecars(id, payload)...), envData)
+	if err != nil {
+		return vcontainerd.CreateContainerOpts{}, err
+	}
+
+	mounts, err := a.volumeMounts(ctx, payload)
+	if err != nil {
+		return vcontainerd.CreateContainerOpts{}, err
+	}
+
+	setWorkloadLabels(labels, payload)
+
+	opts := vcontainerd.CreateContainerOpts{
+		ID:          id,
 		ImageRef:    payload.GetImageRef(),
 		Snapshotter: "",
 		Runtime: struct {
 			Name    string
 			Options interface{}
 		}{
-			Name: "io.containerd.runc.v2",
+			Name: workloadRuntime,
 		},
-		Limits: &struct {
-			CPUFraction float64
-			MemoryBytes uint64
-		}{
-			CPUFraction: float64(payload.GetCores()) / float64(runtime.NumCPU()),
-			MemoryBytes: uint64(payload.GetMemory()) * 1024 * 1024,
+		Limits: &cgroup.Limits{
+			CPUFraction:     float64(payload.GetCores()) / float64(runtime.NumCPU()),
+			MemoryBytes:     uint64(megabytes(payload.GetMemory())),
+			MemoryHighBytes: uint64(megabytes(payload.GetMemoryHigh())),
+			IO:              ioLimits(payload.GetIoLimits()),
 		},
-		CioCreator: cio.NewCreator(cio.WithStdio),
-		Labels: map[string]string{
-			SpawnRequestLabel: string(encodedPayload),
+		CioCreator:   logFileCreator(a.logDir),
+		Labels:       labels,
+		Env:          workloadEnv(env),
+		Ports:        exposedPorts(payload),
+		IngressRules: ingressRules(payload),
+		EgressPolicy: a.egressPolicy(payload.GetTenant()),
+		PullProgress: func(progress vcontainerd.PullProgress) {
+			a.reportPullProgress(id, payload.GetReplicaGroup(), progress)
 		},
-	})
+		InitSteps: steps,
+		InitProgress: func(progress vcontainerd.InitProgress) {
+			a.reportInitProgress(id, payload.GetReplicaGroup(), progress)
+		},
+		PodContainers: containers,
+		DNS:           a.workloadDNS(payload),
+		ExtraHosts:    extraHosts(payload),
+		Mounts:        mounts,
+		Metadata:      a.workloadMetadata(id, payload, labels),
+	}
+
+	if opts.EgressPolicy != nil && opts.EgressPolicy.Proxy {
+		opts.EgressProxyPort = a.egressProxy.port
+	}
+
+	if a.metadata != nil {
+		opts.MetadataPort = a.metadata.port
+	}
+
+	opts.TrackConnections = a.topology != nil
+	opts.CountTraffic = a.traffic != nil
+
+	return opts, nil
+}
+
+// handleStopRequest stops the local workloads matching the requested
+// container ID or replica group, or label selector, returning a nil
+// response if none matched
+func (a *Agent) handleStopRequest(payload *pb.VmStopRequest) ([]byte, error) {
+	ctx := a.ctrRepo.GetContext(context.Background())
+
+	var selector labelSelector
+	if payload.GetLabelSelector() != "" {
+		var err error
+		if selector, err = parseRequiredSelector(payload.GetLabelSelector()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Workloads of other tenants are never stopped by a selector
+	selected := func(spec *pb.VmSpawnRequest, labels map[string]string) bool {
+		return (payload.GetTenant() == "" || spec.GetTenant() == payload.GetTenant()) && selector.matches(labels)
+	}
+
+	tasks, err := a.ctrRepo.GetTasks(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to spawn container: %w", err)
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	var stoppedIDs []string
+	if selector != nil {
+		stoppedIDs = a.pending.cancelMatching(func(pending *pb.PendingSpawn) bool {
+			return selected(pending.GetSpec(), pending.GetSpec().GetLabels())
+		})
+	} else {
+		stoppedIDs = a.pending.cancel(payload.GetId(), payload.GetWorkloadOnly())
+	}
+
+	for _, task := range tasks {
+		container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get container %s: %w", task.GetID(), err)
+		}
+
+		labels, err := container.Labels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get labels for container %s: %w", task.GetID(), err)
+		}
+
+		var labelPayload pb.VmSpawnRequest
+		if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
+			continue
+		}
+
+		if selector != nil {
+			if !selected(&labelPayload, storedLabels(labels, UserLabelPrefix, labelPayload.GetLabels())) {
+				continue
+			}
+		} else if task.GetID() != payload.GetId() && (payload.GetWorkloadOnly() || labelPayload.GetReplicaGroup() != payload.GetId()) {
+			continue
+		}
+
+		if !a.draining.start(task.GetID()) {
+			continue
+		}
+
+		// Stop sending new requests to the workload before it is
+		// signalled, it is stopped in the background
+		a.serviceProxy.Deregister(task.GetID())
+		stoppedIDs = append(stoppedIDs, task.GetID())
+
+		go a.stopGracefully(task.GetID(), &labelPayload)
+	}
+
+	if len(stoppedIDs) == 0 {
+		return nil, nil
 	}
 
-	response, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, &pb.VmSpawnResponse{Id: id, Url: id + "." + a.baseURL})
+	a.broadcastDrain(stoppedIDs)
+	a.pending.retrySoon()
+
+	response, err := wrapClusterMessage(pb.ClusterEvent_STOP, &pb.VmStopResponse{StoppedIds: stoppedIDs})
 	if err != nil {
 		return nil, fmt.Errorf("failed to wrap cluster message: %w", err)
 	}
@@ -203,198 +828,712 @@ func (a *Agent) handleSpawnRequest(payload *pb.VmSpawnRequest) (ret []byte, retE
 	return response, nil
 }
 
-//nolint:gocognit
-func (a *Agent) Handler() {
-	for event := range a.eventCh {
-		switch event.EventType() {
-		case serf.EventMemberJoin:
-			join := event.(serf.MemberEvent)
-			a.logger.Infof("Join event: %v", join)
-		case serf.EventQuery:
-			query := event.(*serf.Query)
-			a.logger.Infof("Query event: %v", query)
+// registerService exposes a workload, and its replica group if any, through the proxy
+func (a *Agent) registerService(hostPort uint32, workload *pb.WorkloadState, addr string, remote bool) error {
+	revision := workload.GetSourceRequest().GetRevision()
 
-			if query.SourceNode() == a.cfg.NodeName {
-				a.logger.Warn("Received event from self node, ignoring")
+	a.serviceProxy.SetAccessLog(workload.GetId(), workload.GetSourceRequest().GetAccessLog())
+	a.serviceProxy.SetAffinity(workload.GetId(), workload.GetSourceRequest().GetSessionAffinity())
+
+	if err := a.serviceProxy.Register(hostPort, workload.GetId(), workload.GetId(), addr, revision, remote); err != nil {
+		return err
+	}
+
+	if group := workload.GetSourceRequest().GetReplicaGroup(); group != "" {
+		a.serviceProxy.SetAffinity(group, workload.GetSourceRequest().GetSessionAffinity())
+
+		return a.serviceProxy.Register(hostPort, group, workload.GetId(), addr, revision, remote)
+	}
+
+	return nil
+}
+
+// workloadEndpoint is where the agent reaches a local workload
+type workloadEndpoint struct {
+	ip string
+	// loopback ports forwarded to the ports of rootless workloads
+	forwarded map[uint32]uint32
+}
+
+func (e workloadEndpoint) addr(port uint32) string {
+	if forwarded, ok := e.forwarded[port]; ok {
+		port = forwarded
+	}
+
+	return net.JoinHostPort(e.ip, strconv.Itoa(int(port)))
+}
+
+// exposedPorts returns the ports of a workload the agent connects to,
+// for its services and probes
+func exposedPorts(spec *pb.VmSpawnRequest) []uint32 {
+	var ports []uint32
+	for _, port := range spec.GetPorts() {
+		ports = append(ports, port)
+	}
+
+	for _, probe := range []*pb.Probe{spec.GetReadinessProbe(), spec.GetLivenessProbe()} {
+		if probe != nil && len(probe.GetCommand()) == 0 && probe.GetPort() != 0 {
+			ports = append(ports, probe.GetPort())
+		}
+	}
+
+	if metrics := spec.GetMetricsEndpoint(); metrics != nil {
+		ports = append(ports, metrics.GetPort())
+	}
+
+	return ports
+}
+
+// usedResources returns the vCPUs and memory (in MB) allocated
+// to the workloads running on this node
+// checkCapacity fails if the node doesn't have the vCPUs and memory of
+// the request left, counting the workloads running and queued on it
+func (a *Agent) checkCapacity(ctx context.Context, req *pb.VmSpawnRequest) error {
+	vcpuUsed, memUsed, err := a.usedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	vcpuQueued, memQueued := a.admission.reserved()
+	vcpuUsed += vcpuQueued
+	memUsed += memQueued
+
+	if (vcpuUsed + int(req.GetCores())) > runtime.NumCPU() {
+		return newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, capacityDetails("vcpu", runtime.NumCPU(), vcpuUsed, int(req.GetCores())),
+			"cannot spawn container: have capacity for %d vCPUs, already in use: %d, requested: %d", runtime.NumCPU(), vcpuUsed, req.GetCores())
+	}
+
+	availableMem, err := getAvailableMem()
+	if err != nil {
+		return err
+	}
+	availableMem /= 1024
+
+	if (memUsed + int(req.GetMemory())) > int(availableMem) {
+		return newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, capacityDetails("memory", int(availableMem), memUsed, int(req.GetMemory())),
+			"cannot spawn container: have capacity for %s, already in use: %s, requested: %s", resource.Bytes(availableMem)*resource.MiB, resource.Bytes(memUsed)*resource.MiB, megabytes(req.GetMemory()))
+	}
+
+	return nil
+}
+
+func (a *Agent) usedResources(ctx context.Context) (int, int, error) {
+	tasks, err := a.ctrRepo.GetTasks(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get existing tasks to check capacity: %w", err)
+	}
+
+	vcpuUsed := 0
+	memUsed := 0
+	for _, task := range tasks {
+		container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get container %s: %w", task.GetID(), err)
+		}
+
+		labels, err := container.Labels(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get labels for container %s: %w", task.GetID(), err)
+		}
+
+		// Pod and init step containers count as part of their workload
+		if _, ok := labels[SpawnRequestLabel]; !ok {
+			continue
+		}
+
+		var labelPayload pb.VmSpawnRequest
+		if err := json.Unmarshal([]byte(labels[SpawnRequestLabel]), &labelPayload); err != nil {
+			return 0, 0, err
+		}
+
+		vcpuUsed += int(labelPayload.GetCores())
+		memUsed += int(labelPayload.GetMemory())
+	}
+
+	return vcpuUsed, memUsed, nil
+}
+
+//nolint:gocognit
+func (a *Agent) Handler() {
+	for event := range a.eventCh {
+		switch event.EventType() {
+		case serf.EventMemberJoin:
+			join := event.(serf.MemberEvent)
+			a.logger.Infof("Join event: %v", join)
+
+			for _, member := range join.Members {
+				a.checkMemberSchema(member)
+				a.publishEvent(&pb.WatchEventsResponse{Event: pb.ClusterEvent_NODE_JOIN, Node: &pb.Node{Id: member.Name}, Id: member.Name, Message: "node joined"})
+			}
+
+			a.pending.retrySoon()
+		case serf.EventQuery:
+			query := event.(*serf.Query)
+			a.logger.Infof("Query event: %v", query)
+
+			if query.SourceNode() == a.cfg.NodeName {
+				a.logger.Warn("Received event from self node, ignoring")
+
+				continue
+			}
+
+			a.faults.delayQuery()
+
+			var baseMessage pb.ClusterMessage
+			if err := proto.Unmarshal(query.Payload, &baseMessage); err != nil {
+				a.logger.WithError(err).Error("failed to unmarshal base payload")
+
+				continue
+			}
+
+			version := payloadSchemaVersion(baseMessage.GetSchemaVersion())
+			if version < MinSchemaVersion {
+				a.logger.Warnf("Ignoring query from node %s with unsupported schema version %d", query.SourceNode(), version)
+
+				continue
+			}
+
+			if !knownEvent(baseMessage.GetEvent()) {
+				a.logger.Debugf("Ignoring unknown event %d from node %s with schema version %d", baseMessage.GetEvent(), query.SourceNode(), version)
+
+				continue
+			}
+
+			var response []byte
+			var err error
+
+			switch baseMessage.GetEvent() {
+			case pb.ClusterEvent_SPAWN:
+				var payload pb.VmSpawnRequest
+				if err := baseMessage.GetWrappedMessage().UnmarshalTo(&payload); err != nil {
+					a.logger.WithError(err).Error("failed to unmarshal payload")
+
+					continue
+				}
+
+				upgradeSpawnRequest(version, &payload)
+				response, err = a.handleSpawnRequest(&payload)
+			case pb.ClusterEvent_STOP:
+				var payload pb.VmStopRequest
+				if err := baseMessage.GetWrappedMessage().UnmarshalTo(&payload); err != nil {
+					a.logger.WithError(err).Error("failed to unmarshal payload")
+
+					continue
+				}
+
+				response, err = a.handleStopRequest(&payload)
+			case pb.ClusterEvent_OOM:
+				fallthrough
+			case pb.ClusterEvent_ERROR:
+				fallthrough
+			default:
+				a.logger.Errorf("got invalid event: %d", baseMessage.GetEvent())
+
+				continue
+			}
+
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to handle event: %d", baseMessage.GetEvent())
+
+				continue
+			}
+
+			if response == nil {
+				continue
+			}
+
+			if err := query.Respond(response); err != nil {
+				a.logger.WithError(err).Error("failed to respond to query")
+			}
+		case serf.EventUser:
+			userEvent := event.(serf.UserEvent)
+
+			if a.faults.dropUserEvent() {
+				a.logger.Warnf("fault injection: dropping user event %s", userEvent.Name)
+
+				continue
+			}
+
+			if userEvent.Name == OOMEvent {
+				a.handleOOMEvent(userEvent.Payload)
+
+				continue
+			}
+
+			if userEvent.Name == RevisionEvent {
+				a.handleRevisionEvent(userEvent.Payload)
+
+				continue
+			}
+
+			if userEvent.Name == TrafficSplitEvent {
+				a.handleTrafficSplitEvent(userEvent.Payload)
+
+				continue
+			}
+
+			if userEvent.Name == DrainEvent {
+				a.handleDrainEvent(userEvent.Payload)
+
+				continue
+			}
+
+			if userEvent.Name == WarmImageEvent {
+				a.handleWarmImageEvent(userEvent.Payload)
+
+				continue
+			}
+
+			if userEvent.Name != StateBroadcastEvent {
+				a.logger.Debugf("Ignoring unknown user event %s", userEvent.Name)
+
+				continue
+			}
+
+			var workloads pb.NodeStateResponse
+
+			if err := proto.Unmarshal(userEvent.Payload, &workloads); err != nil {
+				a.logger.WithError(err).Error("failed to unmarshal")
+
+				continue
+			}
+
+			if workloads.GetNode().GetId() == a.serf.LocalMember().Name {
+				continue
+			}
+
+			if version := payloadSchemaVersion(workloads.GetSchemaVersion()); version < MinSchemaVersion {
+				a.logger.Warnf("Ignoring state of node %s with unsupported schema version %d", workloads.GetNode().GetId(), version)
+
+				continue
+			}
+
+			upgradeNodeState(&workloads)
+
+			member := a.findMember(workloads.GetNode().GetId())
+			if member == nil {
+				a.logger.Warnf("member for node %s not found", workloads.GetNode().GetId())
+
+				continue
+			}
+
+			a.logger.Infof("Got workloads of node %s IP %v", workloads.GetNode().GetId(), member.Addr)
+			previous := a.states.record(member.Name, &workloads)
+			a.volumes.observe(member.Name, workloads.GetVolumes())
+
+			a.publishWorkloadChanges(previous, &workloads)
+
+			current := make(map[string]struct{})
+			for _, service := range workloads.GetWorkloads() {
+				current[service.GetId()] = struct{}{}
+			}
+
+			for _, service := range previous.GetWorkloads() {
+				if _, ok := current[service.GetId()]; !ok {
+					a.serviceProxy.Deregister(service.GetId())
+				}
+			}
+
+			for _, service := range workloads.GetWorkloads() {
+				if !workloadReady(service) {
+					a.serviceProxy.Deregister(service.GetId())
+
+					continue
+				}
+
+				for port := range service.GetSourceRequest().GetPorts() {
+					addr := fmt.Sprintf("%s:%d", member.Addr.String(), port)
+					if err := a.registerService(port, service, addr, true); err != nil {
+						a.logger.WithError(err).Errorf("failed to register node %s service %s addr %s with proxy", member.Name, service, addr)
+
+						continue
+					}
+				}
+			}
+		case serf.EventMemberLeave, serf.EventMemberFailed:
+			leave := event.(serf.MemberEvent)
+			a.logger.Infof("Received event: %v", leave)
+
+			for _, member := range leave.Members {
+				a.publishEvent(&pb.WatchEventsResponse{Event: pb.ClusterEvent_NODE_LEAVE, Node: &pb.Node{Id: member.Name}, Id: member.Name, Message: leave.String()})
+
+				// Every node sees the failure, the leader alone reports it
+				if event.EventType() == serf.EventMemberFailed && a.isLeader() {
+					a.alert(AlertNodeFailed, member.Name, "node %s (%s) failed", member.Name, member.Addr)
+				}
+			}
+		case serf.EventMemberUpdate:
+			update := event.(serf.MemberEvent)
+			a.logger.Infof("Received event: %v", update)
+
+			for _, member := range update.Members {
+				a.publishNodeStatus(member)
+			}
+		case serf.EventMemberReap:
+			fallthrough
+		default:
+			a.logger.Infof("Received event: %v", event)
+		}
+	}
+}
+
+func wrapClusterMessage(event pb.ClusterEvent, message proto.Message) ([]byte, error) {
+	var anyPayload anypb.Any
+	if err := anypb.MarshalFrom(&anyPayload, message, proto.MarshalOptions{}); err != nil {
+		return nil, err
+	}
+
+	payload, err := proto.Marshal(&pb.ClusterMessage{
+		Event:          event,
+		WrappedMessage: &anyPayload,
+		SchemaVersion:  SchemaVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func wrapClusterError(err error) ([]byte, error) {
+	return wrapClusterMessage(pb.ClusterEvent_ERROR, errorResponse(err))
+}
+
+// Request another node to spawn a VM, horizontally scaled workloads get
+// their minimum number of replicas spawned under a new replica group
+func (a *Agent) SpawnRequest(req *pb.VmSpawnRequest) (resp *pb.VmSpawnResponse, err error) {
+	policy := req.GetHorizontalScaling()
+	scaled := policy != nil && req.GetReplicaGroup() == ""
+
+	if scaled {
+		if policy.GetMaxReplicas() < policy.GetMinReplicas() {
+			return nil, fmt.Errorf("max replicas %d is lower than min replicas %d", policy.GetMaxReplicas(), policy.GetMinReplicas())
+		}
+
+		req.ReplicaGroup = keyedReplicaGroup(req)
+	}
+
+	if err := a.checkImagePolicy(req); err != nil {
+		return nil, err
+	}
+
+	if err := a.claimName(req); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			a.releaseName(req)
+		}
+	}()
+
+	if !scaled {
+		return a.spawnOrQueue(req)
+	}
+
+	key := req.GetIdempotencyKey()
+
+	for i := range int(max(policy.GetMinReplicas(), 1)) {
+		req.IdempotencyKey = replicaKey(key, i)
+
+		if _, err := a.spawnOrQueue(req); err != nil {
+			// The name is released, so the replicas already spawned
+			// are stopped rather than left running under no name
+			if _, stopErr := a.StopRequest(&pb.VmStopRequest{Id: req.GetReplicaGroup()}); stopErr != nil {
+				a.logger.WithError(stopErr).Errorf("failed to stop the replicas of group %s", req.GetReplicaGroup())
+			}
+
+			return nil, fmt.Errorf("failed to spawn replica of group %s: %w", req.GetReplicaGroup(), err)
+		}
+	}
+
+	return &pb.VmSpawnResponse{Id: req.GetReplicaGroup(), Url: req.GetReplicaGroup() + "." + a.baseURL}, nil
+}
+
+// Request the cluster to stop a workload or all replicas of a replica group,
+// or every workload matching a label selector
+func (a *Agent) StopRequest(req *pb.VmStopRequest) (*pb.VmStopResponse, error) {
+	stopped := &pb.VmStopResponse{}
+
+	// Queries from the local node are ignored by the handler,
+	// so stop any matching local workloads directly
+	localResp, err := a.handleStopRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if localResp != nil {
+		var resp pb.ClusterMessage
+		if err := proto.Unmarshal(localResp, &resp); err != nil {
+			return nil, err
+		}
+
+		if err := resp.GetWrappedMessage().UnmarshalTo(stopped); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := wrapClusterMessage(pb.ClusterEvent_STOP, req)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := a.serf.Query(QueryName, payload, a.serf.DefaultQueryParams())
+	if err != nil {
+		return nil, err
+	}
+
+	for response := range query.ResponseCh() {
+		var resp pb.ClusterMessage
+		if err := proto.Unmarshal(response.Payload, &resp); err != nil {
+			return nil, err
+		}
+
+		if resp.GetEvent() == pb.ClusterEvent_ERROR {
+			var errorResp pb.ErrorResponse
+			if err := resp.GetWrappedMessage().UnmarshalTo(&errorResp); err != nil {
+				return nil, err
+			}
+
+			return nil, nodeError(response.From, &errorResp)
+		}
+
+		var wrappedResp pb.VmStopResponse
+		if err := resp.GetWrappedMessage().UnmarshalTo(&wrappedResp); err != nil {
+			return nil, err
+		}
 
-				continue
-			}
+		a.logger.Infof("Node %s stopped workloads: %v", response.From, wrappedResp.GetStoppedIds())
+		stopped.StoppedIds = append(stopped.StoppedIds, wrappedResp.GetStoppedIds()...)
+	}
 
-			var baseMessage pb.ClusterMessage
-			if err := proto.Unmarshal(query.Payload, &baseMessage); err != nil {
-				a.logger.WithError(err).Error("failed to unmarshal base payload")
+	if len(stopped.GetStoppedIds()) == 0 {
+		if req.GetLabelSelector() != "" {
+			return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload matches %s", req.GetLabelSelector())
+		}
 
-				continue
-			}
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
 
-			var response []byte
-			var err error
+	return stopped, nil
+}
 
-			switch baseMessage.GetEvent() {
-			case pb.ClusterEvent_SPAWN:
-				var payload pb.VmSpawnRequest
-				if err := baseMessage.GetWrappedMessage().UnmarshalTo(&payload); err != nil {
-					a.logger.WithError(err).Error("failed to unmarshal payload")
+// MetricsRequest returns the capacity and usage of the nodes along with
+// the traffic served by the proxies, as last reported by each node, and
+// the history this node recorded since the requested time
+func (a *Agent) MetricsRequest(req *pb.MetricsRequest) (*pb.MetricsResponse, error) {
+	resp := a.currentMetrics()
 
-					continue
-				}
+	if req.GetSinceUnixTime() != 0 {
+		resp.History = a.metricsHistory.since(time.Unix(req.GetSinceUnixTime(), 0))
+		resp.HistoryPeriodSeconds = uint32(MetricsHistoryPeriod.Seconds())
+	}
 
-				response, err = a.handleSpawnRequest(&payload)
-			case pb.ClusterEvent_ERROR:
-				fallthrough
-			default:
-				a.logger.Errorf("got invalid event: %d", baseMessage.GetEvent())
+	return resp, nil
+}
 
-				continue
-			}
+func (a *Agent) currentMetrics() *pb.MetricsResponse {
+	resp := &pb.MetricsResponse{}
+	services := make(map[string]*pb.ServiceMetrics)
+
+	for _, state := range a.knownStates() {
+		node := &pb.NodeMetrics{
+			Node:      state.GetNode(),
+			Cpus:      state.GetCpus(),
+			Memory:    state.GetMemory(),
+			Workloads: uint32(len(state.GetWorkloads())),
+			Host:      state.GetHost(),
+		}
 
-			if err != nil {
-				a.logger.WithError(err).Errorf("failed to handle event: %d", baseMessage.GetEvent())
+		for _, workload := range state.GetWorkloads() {
+			node.CpusUsed += workload.GetSourceRequest().GetCores()
+			node.MemoryUsed += uint64(workload.GetSourceRequest().GetMemory())
+		}
 
-				continue
+		resp.Nodes = append(resp.Nodes, node)
+
+		for _, metrics := range state.GetServiceMetrics() {
+			service, ok := services[metrics.GetServiceId()]
+			if !ok {
+				service = &pb.ServiceMetrics{ServiceId: metrics.GetServiceId()}
+				services[metrics.GetServiceId()] = service
+				resp.Services = append(resp.Services, service)
 			}
 
-			if err := query.Respond(response); err != nil {
-				a.logger.WithError(err).Error("failed to respond to query")
+			total := service.GetAvgLatencyMs()*float64(service.GetRequests()) + metrics.GetAvgLatencyMs()*float64(metrics.GetRequests())
+			service.Requests += metrics.GetRequests()
+			service.Errors += metrics.GetErrors()
+			if service.GetRequests() > 0 {
+				service.AvgLatencyMs = total / float64(service.GetRequests())
 			}
-		case serf.EventUser:
-			userEvent := event.(serf.UserEvent)
 
-			var workloads pb.NodeStateResponse
+			mergeRevisionMetrics(service, metrics.GetRevisions())
+		}
+	}
 
-			if err := proto.Unmarshal(userEvent.Payload, &workloads); err != nil {
-				a.logger.WithError(err).Error("failed to unmarshal")
+	return resp
+}
 
-				continue
-			}
+func mergeRevisionMetrics(service *pb.ServiceMetrics, revisions []*pb.RevisionMetrics) {
+	for _, metrics := range revisions {
+		var merged *pb.RevisionMetrics
 
-			if workloads.GetNode().GetId() == a.serf.LocalMember().Name {
-				continue
+		for _, known := range service.GetRevisions() {
+			if known.GetRevision() == metrics.GetRevision() {
+				merged = known
 			}
+		}
 
-			member := a.findMember(workloads.GetNode().GetId())
-			if member == nil {
-				a.logger.Warnf("member for node %s not found", workloads.GetNode().GetId())
+		if merged == nil {
+			merged = &pb.RevisionMetrics{Revision: metrics.GetRevision()}
+			service.Revisions = append(service.Revisions, merged)
+		}
 
-				continue
-			}
+		merged.Requests += metrics.GetRequests()
+		merged.Errors += metrics.GetErrors()
+	}
+}
 
-			a.logger.Infof("Got workloads of node %s IP %v", workloads.GetNode().GetId(), member.Addr)
-			a.lastStateMu.Lock()
-			a.lastStateUpdate[member.Name] = SavedStatusUpdate{
-				update:     &workloads,
-				receivedAt: time.Now(),
-			}
-			a.lastStateMu.Unlock()
+// knownStates returns the state of this node along with the
+// recently received states of the other nodes
+func (a *Agent) knownStates() []*pb.NodeStateResponse {
+	return a.states.known()
+}
 
-			for _, service := range workloads.GetWorkloads() {
-				for port := range service.GetSourceRequest().GetPorts() {
-					addr := fmt.Sprintf("%s:%d", member.Addr.String(), port)
-					if err := a.serviceProxy.Register(port, service.GetId(), addr); err != nil {
-						a.logger.WithError(err).Errorf("failed to register node %s service %s addr %s with proxy", member.Name, service, addr)
+var errNoSpawnResponse = newClusterError(pb.ErrorCode_TIMEOUT, nil, "no response received from nodes")
 
-						continue
-					}
-				}
+func (a *Agent) spawnReplica(req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error) {
+	req.DryRun = true
+	payload, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, req)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := a.serf.Query(QueryName, payload, a.serf.DefaultQueryParams())
+	if err != nil {
+		return nil, err
+	}
+
+	req.DryRun = false
+
+	// The request is only spawned once every node answered, so the least
+	// loaded node is picked and, for requests with an idempotency key, the
+	// one that already spawned it isn't missed
+	var candidates []string
+
+	for response := range query.ResponseCh() {
+		a.logger.Infof("Successful response from node: %s", response.From)
+
+		if req.GetIdempotencyKey() != "" {
+			if existing := dryRunExisting(response.Payload); existing != nil {
+				a.logger.Infof("Node %s already spawned idempotency key %s as %s", response.From, req.GetIdempotencyKey(), existing.GetId())
+
+				return existing, nil
 			}
-		case serf.EventMemberLeave:
-			fallthrough
-		case serf.EventMemberFailed:
-			fallthrough
-		case serf.EventMemberUpdate:
-			fallthrough
-		case serf.EventMemberReap:
-			fallthrough
-		default:
-			a.logger.Infof("Received event: %v", event)
 		}
+
+		candidates = append(candidates, response.From)
 	}
-}
 
-func wrapClusterMessage(event pb.ClusterEvent, message proto.Message) ([]byte, error) {
-	var anyPayload anypb.Any
-	if err := anypb.MarshalFrom(&anyPayload, message, proto.MarshalOptions{}); err != nil {
+	if answered := len(candidates); answered > 0 {
+		if candidates = a.readyNodes(candidates); len(candidates) == 0 {
+			return nil, newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, nil, "none of the %d nodes that answered is ready", answered)
+		}
+	}
+
+	candidates, err = a.capableNodes(candidates, req)
+	if err != nil {
 		return nil, err
 	}
 
-	payload, err := proto.Marshal(&pb.ClusterMessage{
-		Event:          event,
-		WrappedMessage: &anyPayload,
-	})
+	candidates, err = a.volumeNodes(candidates, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return payload, nil
-}
+	a.rankNodes(candidates, req)
 
-func wrapClusterErrorMessage(errorMessage string) ([]byte, error) {
-	return wrapClusterMessage(pb.ClusterEvent_ERROR, &pb.ErrorResponse{
-		Error: errorMessage,
-	})
+	for _, node := range candidates {
+		resp, err := a.spawnOnNode(req, node)
+		if errors.Is(err, errNoSpawnResponse) {
+			continue
+		}
+
+		if err == nil {
+			a.recordPlacement(req, candidates, node)
+		}
+
+		return resp, err
+	}
+
+	return nil, errNoSpawnResponse
 }
 
-// Request another node to spawn a VM
-func (a *Agent) SpawnRequest(req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error) {
-	req.DryRun = true
-	payload, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, req)
-	if err != nil {
-		return nil, err
+// spawnOnNode sends the spawn request to a single node and waits for it
+// to pull the image and spawn the VM
+func (a *Agent) spawnOnNode(req *pb.VmSpawnRequest, node string) (*pb.VmSpawnResponse, error) {
+	if req.GetStopGracePeriodSeconds() == 0 {
+		req.StopGracePeriodSeconds = uint32(vcontainerd.DefaultStopTimeout / time.Second)
 	}
 
-	query, err := a.serf.Query(QueryName, payload, a.serf.DefaultQueryParams())
+	payload, err := wrapClusterMessage(pb.ClusterEvent_SPAWN, req)
 	if err != nil {
 		return nil, err
 	}
 
-	req.DryRun = false
-	payload, err = wrapClusterMessage(pb.ClusterEvent_SPAWN, req)
+	params := a.serf.DefaultQueryParams()
+	// Give 90 seconds to the node to pull the image from the network
+	// and spawn the VM
+	params.Timeout = time.Second * 90
+	// Only send the query to the node the request was placed on
+	params.FilterNodes = []string{node}
+
+	query, err := a.serf.Query(QueryName, payload, params)
 	if err != nil {
 		return nil, err
 	}
 
 	for response := range query.ResponseCh() {
-		a.logger.Infof("Successful response from node: %s", response.From)
+		a.logger.Infof("Successfully spawned VM on node: %s", response.From)
 
-		params := a.serf.DefaultQueryParams()
-		// Give 90 seconds to the node to pull the image from the network
-		// and spawn the VM
-		params.Timeout = time.Second * 90
-		// Only send the query to the node that sent the response
-		params.FilterNodes = []string{response.From}
-
-		query, err = a.serf.Query(QueryName, payload, params)
-		if err != nil {
+		var resp pb.ClusterMessage
+		if err := proto.Unmarshal(response.Payload, &resp); err != nil {
 			return nil, err
 		}
 
-		for response := range query.ResponseCh() {
-			a.logger.Infof("Successfully spawned VM on node: %s", response.From)
-
-			var resp pb.ClusterMessage
-			if err := proto.Unmarshal(response.Payload, &resp); err != nil {
+		if resp.GetEvent() == pb.ClusterEvent_ERROR {
+			var errorResp pb.ErrorResponse
+			if err := resp.GetWrappedMessage().UnmarshalTo(&errorResp); err != nil {
 				return nil, err
 			}
 
-			if resp.GetEvent() == pb.ClusterEvent_ERROR {
-				var errorResp pb.ErrorResponse
-				if err := resp.GetWrappedMessage().UnmarshalTo(&errorResp); err != nil {
-					return nil, err
-				}
+			return nil, nodeError(response.From, &errorResp)
+		}
 
-				return nil, fmt.Errorf("node returned failure response: %s", errorResp.GetError())
-			}
+		var wrappedResp pb.VmSpawnResponse
+		if err := resp.GetWrappedMessage().UnmarshalTo(&wrappedResp); err != nil {
+			return nil, err
+		}
 
-			var wrappedResp pb.VmSpawnResponse
-			if err := resp.GetWrappedMessage().UnmarshalTo(&wrappedResp); err != nil {
-				return nil, err
-			}
+		return &wrappedResp, nil
+	}
+
+	return nil, errNoSpawnResponse
+}
+
+// adoptWorkloads takes over the workloads and pod containers created by
+// releases predating the owner label, which are ignored otherwise
+func (a *Agent) adoptWorkloads() {
+	for _, label := range []string{SpawnRequestLabel, vcontainerd.PodLabel} {
+		adopted, err := a.ctrRepo.AdoptContainers(context.Background(), fmt.Sprintf("labels.%q", label))
+		if err != nil {
+			a.logger.WithError(err).Error("failed to adopt containers")
+		}
 
-			return &wrappedResp, nil
+		for _, id := range adopted {
+			a.logger.Infof("Adopted container %s created by an older release", id)
 		}
 	}
-
-	return nil, errors.New("no response received from nodes")
 }
 
 // broadcast the workloads running on the node every 30 seconds
@@ -404,7 +1543,7 @@ func (a *Agent) SpawnRequest(req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error
 //
 //nolint:gocognit
 func (a *Agent) monitorWorkloads() {
-	ticker := time.NewTicker(WorkloadBroadcastPeriod)
+	ticker := time.NewTicker(a.broadcastPeriod)
 	for range ticker.C {
 		ctx := a.ctrRepo.GetContext(context.Background())
 
@@ -417,13 +1556,44 @@ func (a *Agent) monitorWorkloads() {
 
 		resp := pb.NodeStateResponse{
 			Node: &pb.Node{
-				Id: a.serf.LocalMember().Name,
+				Id:     a.serf.LocalMember().Name,
+				Status: a.localNodeStatus(),
 			},
+			Cpus:          uint32(runtime.NumCPU()),
+			SchemaVersion: SchemaVersion,
+			PendingSpawns: a.pending.list(),
+			ImageCache:    a.imageCache.list(),
+		}
+
+		if availableMem, err := getAvailableMem(); err == nil {
+			resp.Memory = availableMem / 1024
+		}
+
+		host, err := a.hostSampler.sample()
+		if err != nil {
+			a.logger.WithError(err).Warn("failed to sample some host metrics")
+		}
+		resp.Host = host
+		resp.Prices = a.prices
+
+		pods, err := a.podState(ctx, tasks)
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get pod containers")
+
+			continue
 		}
 
+		running := make(map[string]struct{})
+
 		for _, task := range tasks {
 			a.logger.Infof("Got task %s, state: %s", task.GetID(), task.GetStatus())
 
+			// Stopping workloads are neither respawned nor broadcast, and
+			// pod containers are handled along with their workload
+			if _, ok := pods.containers[task.GetID()]; ok || a.draining.has(task.GetID()) {
+				continue
+			}
+
 			container, err := a.ctrRepo.GetContainer(ctx, task.GetID())
 			if err != nil {
 				a.logger.WithError(err).Errorf("failed to get container for task %s", task.GetID())
@@ -445,15 +1615,90 @@ func (a *Agent) monitorWorkloads() {
 				continue
 			}
 
-			if task.GetStatus() == ctask.Status_STOPPED {
-				a.logger.Infof("task %s is stopped, deleting container and respawning", task.GetID())
+			oomKills := a.oomKills(task.GetID(), labels)
+			exitStatus := task.GetExitStatus()
+
+			// A workload whose init steps failed is handled like it exited
+			// with the status of the failed step, once broadcast with it
+			initFailure := a.failedInit(task.GetID(), &labelPayload, task.GetStatus() == ctask.Status_CREATED)
+			if initFailure != nil {
+				exitStatus = initFailure.GetExitStatus()
+				resp.Workloads = append(resp.Workloads, &pb.WorkloadState{
+					Id:            task.GetID(),
+					SourceRequest: &labelPayload,
+					OomKills:      oomKills,
+					Init:          initFailure,
+					Labels:        storedLabels(labels, UserLabelPrefix, labelPayload.GetLabels()),
+					Annotations:   storedLabels(labels, AnnotationPrefix, labelPayload.GetAnnotations()),
+				})
+			}
+
+			// The pod of a workload is handled like it exited once one of
+			// its containers did
+			podExit, podExited := pods.exited[task.GetID()]
+			if podExited && initFailure == nil && task.GetStatus() != ctask.Status_STOPPED {
+				exitStatus = podExit.exitStatus
+			} else {
+				podExited = false
+			}
+
+			if task.GetStatus() == ctask.Status_STOPPED || initFailure != nil || podExited {
+				// Failures of the VM aren't the workload's, it is respawned
+				// whatever its restart policy like on node failures
+				failure := a.takeVMFailure(task.GetID())
+				respawn := failure != nil || shouldRespawn(&labelPayload, exitStatus)
+
+				if failure != nil {
+					a.logger.Infof("task %s is stopped after a VM failure (%s), deleting container and respawning", task.GetID(), failure.GetReason())
+				} else if initFailure != nil && respawn {
+					a.logger.Infof("init steps of task %s failed (%s), deleting container and respawning", task.GetID(), initFailure.GetFailure())
+				} else if podExited && respawn {
+					a.logger.Infof("pod container %s of task %s exited with status %d, deleting pod and respawning", podExit.name, task.GetID(), exitStatus)
+				} else if respawn {
+					a.logger.Infof("task %s is stopped, deleting container and respawning", task.GetID())
+				} else {
+					a.logger.Infof("task %s exited with status %d, deleting container per restart policy %s", task.GetID(), exitStatus, labelPayload.GetRestartPolicy())
+				}
 
-				if _, err := a.ctrRepo.DeleteContainer(ctx, task.GetID()); err != nil {
+				if _, err := a.ctrRepo.DeleteContainer(ctx, task.GetID(), 0); err != nil {
 					a.logger.Errorf("failed to stop task %s: %s", task.GetID(), err)
 				}
 
+				a.inits.done(task.GetID())
+				a.serviceProxy.Deregister(task.GetID())
+				removeWorkloadLogs(a.logDir, task.GetID())
+
+				if !respawn {
+					a.oomMu.Lock()
+					delete(a.oomCounts, task.GetID())
+					a.oomMu.Unlock()
+
+					a.publishEvent(&pb.WatchEventsResponse{
+						Event:        pb.ClusterEvent_STOP,
+						Id:           task.GetID(),
+						ReplicaGroup: labelPayload.GetReplicaGroup(),
+						Message:      fmt.Sprintf("exited with status %d, not respawned per restart policy %s", exitStatus, labelPayload.GetRestartPolicy()),
+					})
+
+					continue
+				}
+
+				var extraLabels map[string]string
+				respawnPayload := &labelPayload
+
+				if oomKills > 0 {
+					respawnPayload = a.adjustForOOM(&labelPayload, oomKills)
+					extraLabels = map[string]string{OOMCountLabel: strconv.FormatUint(uint64(oomKills), 10)}
+				}
+
+				a.oomMu.Lock()
+				delete(a.oomCounts, task.GetID())
+				a.oomMu.Unlock()
+
+				a.recordRespawn(respawnedWorkload(&labelPayload, task.GetID()))
+
 				go func() {
-					if _, err := a.handleSpawnRequest(&labelPayload); err != nil {
+					if _, err := a.handleSpawnRequestWithLabels(respawnPayload, extraLabels); err != nil {
 						a.logger.Errorf("failed to respawn container %s: %s", task.GetID(), err)
 					}
 				}()
@@ -468,16 +1713,80 @@ func (a *Agent) monitorWorkloads() {
 				continue
 			}
 
-			for hostPort, containerPort := range labelPayload.GetPorts() {
-				addr := fmt.Sprintf("%s:%d", ip, containerPort)
-				if err := a.serviceProxy.Register(hostPort, container.ID(), addr); err != nil {
-					a.logger.Errorf("failed to register container %s addr %s with proxy: %s", container.ID(), addr, err)
+			endpoint := workloadEndpoint{ip: ip, forwarded: vcontainerd.ForwardedPorts(labels)}
+			workload := &pb.WorkloadState{
+				Id:            container.ID(),
+				SourceRequest: &labelPayload,
+				OomKills:      oomKills,
+				Labels:        storedLabels(labels, UserLabelPrefix, labelPayload.GetLabels()),
+				Annotations:   storedLabels(labels, AnnotationPrefix, labelPayload.GetAnnotations()),
+			}
+
+			// The workload isn't probed before it started
+			if status, ok := a.inits.status(container.ID()); ok {
+				workload.Init = status
+			} else {
+				workload.Probes = a.probeStatus(container.ID(), endpoint, &labelPayload)
+			}
+			running[container.ID()] = struct{}{}
+
+			if a.workloadMetrics != nil {
+				a.workloadMetrics.track(container.ID(), endpoint, &labelPayload)
+			}
+
+			if !workloadReady(workload) {
+				a.serviceProxy.Deregister(container.ID())
+			} else {
+				for hostPort, containerPort := range labelPayload.GetPorts() {
+					addr := endpoint.addr(containerPort)
+					if err := a.registerService(hostPort, workload, addr, false); err != nil {
+						a.logger.Errorf("failed to register container %s addr %s with proxy: %s", container.ID(), addr, err)
+					}
 				}
 			}
 
-			resp.Workloads = append(resp.Workloads, &pb.WorkloadState{Id: container.ID(), SourceRequest: &labelPayload})
+			resp.Workloads = append(resp.Workloads, workload)
+		}
+
+		a.retainProbes(running)
+
+		if a.workloadMetrics != nil {
+			a.workloadMetrics.retain(running)
+		}
+
+		for serviceID, stats := range a.serviceProxy.Stats() {
+			metrics := &pb.ServiceMetrics{ServiceId: serviceID, Requests: stats.Requests, Errors: stats.Errors}
+			if stats.Requests > 0 {
+				metrics.AvgLatencyMs = float64(stats.TotalLatency.Milliseconds()) / float64(stats.Requests)
+			}
+
+			for revision, revisionStats := range stats.Revisions {
+				metrics.Revisions = append(metrics.Revisions, &pb.RevisionMetrics{
+					Revision: revision,
+					Requests: revisionStats.Requests,
+					Errors:   revisionStats.Errors,
+				})
+			}
+
+			resp.ServiceMetrics = append(resp.ServiceMetrics, metrics)
+		}
+
+		if resp.Volumes, err = localVolumes(a.volumeDir); err != nil {
+			a.logger.WithError(err).Error("failed to list volumes")
+		}
+
+		a.volumes.observe(resp.GetNode().GetId(), resp.GetVolumes())
+
+		if a.signingKeys != nil {
+			if resp.SigningKeys, err = a.publishedSigningKeys(time.Now()); err != nil {
+				a.logger.WithError(err).Error("failed to publish the signing keys")
+			}
 		}
 
+		previous := a.states.setLocal(&resp)
+
+		a.publishWorkloadChanges(previous, &resp)
+
 		marshaled, err := proto.Marshal(&resp)
 		if err != nil {
 			a.logger.WithError(err).Error("failed to marshal")
@@ -492,39 +1801,158 @@ func (a *Agent) monitorWorkloads() {
 }
 
 func (a *Agent) monitorStateUpdates() {
-	ticker := time.NewTicker(WorkloadBroadcastPeriod)
+	ticker := time.NewTicker(a.broadcastPeriod)
 	for range ticker.C {
-		a.lastStateMu.Lock()
-		for node, update := range a.lastStateUpdate {
-			if time.Since(update.receivedAt) > (WorkloadBroadcastPeriod * 3) {
-				a.logger.Warnf("Update from node %s last received at %v, re-scheduling workloads", node, update.receivedAt)
-				for _, service := range update.update.GetWorkloads() {
-					go func() {
-						if resp, err := a.SpawnRequest(service.GetSourceRequest()); err != nil {
-							a.logger.WithError(err).Errorf("failed to respawn service %s", service.GetId())
-						} else {
-							a.logger.Infof("successfully respawned service %s: %+v", service.GetId(), resp)
-						}
-					}()
-				}
+		for node, update := range a.states.takeStale(a.upgrading) {
+			a.logger.Warnf("Update from node %s last received at %v, re-scheduling workloads", node, update.receivedAt)
+			for _, service := range update.update.GetWorkloads() {
+				// The node is gone, its workloads are registered again if it
+				// comes back
+				a.serviceProxy.Deregister(service.GetId())
+				a.recordRespawn(respawnedWorkload(service.GetSourceRequest(), service.GetId()))
+
+				go func() {
+					if resp, err := a.SpawnRequest(service.GetSourceRequest()); err != nil {
+						a.logger.WithError(err).Errorf("failed to respawn service %s", service.GetId())
+					} else {
+						a.logger.Infof("successfully respawned service %s: %+v", service.GetId(), resp)
+					}
+				}()
 			}
 		}
-		a.lastStateMu.Unlock()
 	}
 }
 
-func (a *Agent) findMember(name string) *serf.Member {
-	for _, member := range a.serf.Members() {
-		if member.Name == name {
-			return &member
+// monitorOOMEvents tracks OOM kills of local workloads and notifies
+// the rest of the cluster about them
+func (a *Agent) monitorOOMEvents() {
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		oomCh, errCh := a.ctrRepo.SubscribeOOMEvents(ctx)
+
+		for containerID := range oomCh {
+			a.recordOOM(ctx, containerID)
 		}
+
+		if err := <-errCh; err != nil {
+			a.logger.WithError(err).Error("OOM event subscription failed, resubscribing")
+		}
+
+		cancel()
+		time.Sleep(a.broadcastPeriod)
+	}
+}
+
+func (a *Agent) recordOOM(ctx context.Context, containerID string) {
+	container, err := a.ctrRepo.GetContainer(ctx, containerID)
+	if err != nil {
+		a.logger.WithError(err).Errorf("failed to get OOM killed container %s", containerID)
+
+		return
 	}
 
-	return nil
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		a.logger.WithError(err).Errorf("failed to get labels for container %s", containerID)
+
+		return
+	}
+
+	// Not a workload managed by the cluster
+	if _, ok := labels[SpawnRequestLabel]; !ok {
+		return
+	}
+
+	oomKills := a.oomKills(containerID, labels) + 1
+
+	a.oomMu.Lock()
+	a.oomCounts[containerID] = oomKills
+	a.oomMu.Unlock()
+
+	a.logger.Warnf("container %s was OOM killed, total OOM kills: %d", containerID, oomKills)
+
+	payload, err := wrapClusterMessage(pb.ClusterEvent_OOM, &pb.WorkloadOOMEvent{
+		Node:     &pb.Node{Id: a.serf.LocalMember().Name},
+		Id:       containerID,
+		OomKills: oomKills,
+	})
+	if err != nil {
+		a.logger.WithError(err).Error("failed to wrap OOM event")
+
+		return
+	}
+
+	if err := a.serf.UserEvent(OOMEvent, payload, false); err != nil {
+		a.logger.WithError(err).Error("failed to broadcast OOM event")
+	}
+}
+
+func (a *Agent) handleOOMEvent(payload []byte) {
+	var baseMessage pb.ClusterMessage
+	if err := proto.Unmarshal(payload, &baseMessage); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal OOM event")
+
+		return
+	}
+
+	var event pb.WorkloadOOMEvent
+	if err := baseMessage.GetWrappedMessage().UnmarshalTo(&event); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal OOM event payload")
+
+		return
+	}
+
+	a.logger.Warnf("Workload %s on node %s was OOM killed, total OOM kills: %d", event.GetId(), event.GetNode().GetId(), event.GetOomKills())
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:   pb.ClusterEvent_OOM,
+		Node:    event.GetNode(),
+		Id:      event.GetId(),
+		Message: fmt.Sprintf("workload was OOM killed, total OOM kills: %d", event.GetOomKills()),
+	})
+}
+
+// oomKills returns the OOM kills seen for a container, including the
+// ones carried over from previous incarnations via the container labels
+func (a *Agent) oomKills(containerID string, labels map[string]string) uint32 {
+	a.oomMu.Lock()
+	defer a.oomMu.Unlock()
+
+	if oomKills, ok := a.oomCounts[containerID]; ok {
+		return oomKills
+	}
+
+	oomKills, err := strconv.ParseUint(labels[OOMCountLabel], 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	return uint32(oomKills)
+}
+
+// adjustForOOM bumps the memory allocation of a workload that is being
+// repeatedly OOM killed, bounded by the configured ceiling
+func (a *Agent) adjustForOOM(payload *pb.VmSpawnRequest, oomKills uint32) *pb.VmSpawnRequest {
+	if a.oomMemoryCeiling == 0 || oomKills < OOMRespawnThreshold || payload.GetMemory() >= a.oomMemoryCeiling {
+		return payload
+	}
+
+	adjusted := proto.Clone(payload).(*pb.VmSpawnRequest)
+	adjusted.Memory = min(payload.GetMemory()*2, a.oomMemoryCeiling)
+
+	a.logger.Infof("Workload OOM killed %d times, bumping memory from %d MB to %d MB", oomKills, payload.GetMemory(), adjusted.GetMemory())
+
+	return adjusted
 }
 
-func (a *Agent) Join(addr string) error {
-	_, err := a.serf.Join([]string{addr}, true)
+func (a *Agent) findMember(name string) *serf.Member {
+	// Members returns a copy, the member isn't shared with serf
+	members := a.serf.Members()
+	for i := range members {
+		if members[i].Name == name {
+			return &members[i]
+		}
+	}
 
-	return err
+	return nil
 }
@@ -2,12 +2,38 @@ package cluster
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"vistara-node/pkg/defaults"
 	pb "vistara-node/pkg/proto/cluster"
 
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// ServerConfig holds the settings of the cluster gRPC server
+type ServerConfig struct {
+	// Token required from clients, empty to disable authentication
+	AuthToken string
+	// Enables TLS on the gRPC server, clients must present a certificate
+	// issued by its CA if it has one (mTLS)
+	TLS *CertSource
+	// Size limit of received and sent messages, 0 for the default
+	MaxMessageSize int
+	// Delay added before handling each request, simulating clients
+	// reaching the node over a WAN on development clusters
+	SimulatedLatency time.Duration
+}
+
 type server struct {
 	pb.UnimplementedClusterServiceServer
 	logger *log.Logger
@@ -17,15 +43,415 @@ type server struct {
 func (s *server) Spawn(_ context.Context, req *pb.VmSpawnRequest) (*pb.VmSpawnResponse, error) {
 	s.logger.Infof("Received spawn request: %v", req)
 
+	// Reject invalid requests before they use up the rate limit or get
+	// sent to the nodes
+	if err := validateSpawnRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.agent.rateLimits.allow(req.GetTenant()); err != nil {
+		return nil, err
+	}
+
 	return s.agent.SpawnRequest(req)
 }
 
-func NewServer(logger *log.Logger, agent *Agent) *grpc.Server {
-	grpcServer := grpc.NewServer()
+func (s *server) Stop(_ context.Context, req *pb.VmStopRequest) (*pb.VmStopResponse, error) {
+	s.logger.Infof("Received stop request: %v", req)
+
+	if req.GetLabelSelector() != "" {
+		if req.GetId() != "" {
+			return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "either an ID or a label selector is required, not both")
+		}
+
+		if _, err := parseRequiredSelector(req.GetLabelSelector()); err != nil {
+			return nil, err
+		}
+
+		// Nodes that don't know selectors take the request for the
+		// workload with an empty ID, which matches none
+		req.WorkloadOnly = true
+
+		return s.agent.StopRequest(req)
+	}
+
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), req.GetWorkloadOnly())
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	s.agent.forgetApplied(req.GetId())
+
+	if !req.GetWorkloadOnly() {
+		if err := s.agent.revisions.forget(req.GetId()); err != nil {
+			s.logger.WithError(err).Errorf("failed to forget the revisions of %s", req.GetId())
+		}
+	}
+
+	return s.agent.StopRequest(req)
+}
+
+func (s *server) Restart(ctx context.Context, req *pb.RestartRequest) (*pb.RestartResponse, error) {
+	s.logger.Infof("Received restart request: %v", req)
+
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	return s.agent.RestartRequest(ctx, req)
+}
+
+func (s *server) Config(context.Context, *pb.ConfigRequest) (*pb.ConfigResponse, error) {
+	return s.agent.Config(), nil
+}
+
+func (s *server) Billing(ctx context.Context, req *pb.BillingRequest) (*pb.BillingResponse, error) {
+	return s.agent.BillingRequest(ctx, req)
+}
+
+func (s *server) Topology(ctx context.Context, req *pb.TopologyRequest) (*pb.TopologyResponse, error) {
+	return s.agent.TopologyRequest(ctx, req)
+}
+
+func (s *server) Explain(_ context.Context, req *pb.VmSpawnRequest) (*pb.ExplainResponse, error) {
+	return s.agent.ExplainRequest(req)
+}
+
+func (s *server) PlanSpawn(_ context.Context, req *pb.VmSpawnRequest) (*pb.PlanSpawnResponse, error) {
+	return s.agent.PlanSpawnRequest(req)
+}
+
+func (s *server) CacheStatus(_ context.Context, req *pb.CacheStatusRequest) (*pb.CacheStatusResponse, error) {
+	return s.agent.CacheStatusRequest(req), nil
+}
+
+func (s *server) SnapshotVolume(ctx context.Context, req *pb.SnapshotVolumeRequest) (*pb.VolumeSnapshot, error) {
+	s.logger.Infof("Received snapshot request: %v", req)
+
+	return s.agent.SnapshotVolumeRequest(ctx, req)
+}
+
+func (s *server) ListSnapshots(ctx context.Context, req *pb.ListSnapshotsRequest) (*pb.ListSnapshotsResponse, error) {
+	return s.agent.ListSnapshotsRequest(ctx, req)
+}
+
+func (s *server) UpdateWorkload(_ context.Context, req *pb.UpdateWorkloadRequest) (*pb.UpdateWorkloadResponse, error) {
+	s.logger.Infof("Received update request: %v", req)
+
+	return s.agent.UpdateWorkloadRequest(req)
+}
+
+func (s *server) Rollback(_ context.Context, req *pb.RollbackRequest) (*pb.UpdateWorkloadResponse, error) {
+	s.logger.Infof("Received rollback request: %v", req)
+
+	return s.agent.RollbackRequest(req)
+}
+
+func (s *server) Canary(_ context.Context, req *pb.CanaryRequest) (*pb.UpdateWorkloadResponse, error) {
+	s.logger.Infof("Received canary request: %v", req)
+
+	return s.agent.CanaryRequest(req)
+}
+
+func (s *server) SetTrafficSplit(_ context.Context, req *pb.TrafficSplit) (*pb.TrafficSplit, error) {
+	s.logger.Infof("Received traffic split request: %v", req)
+
+	return s.agent.SetTrafficSplitRequest(req)
+}
+
+func (s *server) ListRevisions(_ context.Context, req *pb.ListRevisionsRequest) (*pb.ListRevisionsResponse, error) {
+	return s.agent.ListRevisionsRequest(req)
+}
+
+func (s *server) Nodes(_ context.Context, req *pb.NodesRequest) (*pb.NodesResponse, error) {
+	return s.agent.NodesRequest(req), nil
+}
+
+func (s *server) Join(_ context.Context, req *pb.JoinRequest) (*pb.Node, error) {
+	s.logger.Infof("Received join request: %v", req)
+
+	return s.agent.JoinRequest(req)
+}
+
+func (s *server) Drain(ctx context.Context, req *pb.DrainRequest) (*pb.Node, error) {
+	s.logger.Infof("Received drain request: %v", req)
+
+	return s.agent.DrainRequest(ctx, req)
+}
+
+func (s *server) Adopt(ctx context.Context, req *pb.AdoptRequest) (*pb.AdoptResponse, error) {
+	s.logger.Infof("Received adopt request: %v", req)
+
+	return s.agent.AdoptRequest(ctx, req)
+}
+
+func (s *server) Upgrade(ctx context.Context, req *pb.UpgradeRequest) (*pb.UpgradeResponse, error) {
+	s.logger.Infof("Received upgrade request: %v", req)
+
+	return s.agent.UpgradeRequest(ctx, req)
+}
+
+func (s *server) Leave(ctx context.Context, req *pb.LeaveRequest) (*pb.Node, error) {
+	s.logger.Infof("Received leave request: %v", req)
+
+	return s.agent.LeaveRequest(ctx, req)
+}
+
+func (s *server) CollectCrash(ctx context.Context, req *pb.CollectCrashRequest) (*pb.CollectCrashResponse, error) {
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	return s.agent.CollectCrashRequest(ctx, req)
+}
+
+func (s *server) DescribeWorkload(ctx context.Context, req *pb.DescribeWorkloadRequest) (*pb.DescribeWorkloadResponse, error) {
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	return s.agent.DescribeWorkloadRequest(ctx, req)
+}
+
+func (s *server) Usage(ctx context.Context, req *pb.UsageRequest) (*pb.UsageResponse, error) {
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	return s.agent.UsageRequest(ctx, req)
+}
+
+func (s *server) Status(_ context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	return s.agent.StatusRequest(req), nil
+}
+
+func (s *server) List(_ context.Context, req *pb.VmQueryRequest) (*pb.VmQueryResponse, error) {
+	return s.agent.ListRequest(req)
+}
+
+func (s *server) Logs(ctx context.Context, req *pb.VmLogsRequest) (*pb.VmLogsResponse, error) {
+	if req.GetLabelSelector() != "" {
+		if req.GetId() != "" {
+			return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "either an ID or a label selector is required, not both")
+		}
+
+		return s.agent.SelectedLogsRequest(ctx, req)
+	}
+
+	id, err := s.agent.resolveWorkload(req.GetTenant(), req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+	req.Id = id
+
+	return s.agent.LogsRequest(ctx, req)
+}
+
+func (s *server) WatchEvents(req *pb.WatchEventsRequest, stream grpc.ServerStreamingServer[pb.WatchEventsResponse]) error {
+	events, cancel := s.agent.SubscribeEvents()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if !eventMatches(event, req.GetId()) {
+				continue
+			}
+
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) Metrics(_ context.Context, req *pb.MetricsRequest) (*pb.MetricsResponse, error) {
+	return s.agent.MetricsRequest(req)
+}
+
+func (s *server) Apply(_ context.Context, req *pb.ApplyRequest) (*pb.WorkloadDescription, error) {
+	s.logger.Infof("Received apply request: %v", req)
+
+	return s.agent.ApplyRequest(req)
+}
+
+func (s *server) Get(_ context.Context, req *pb.GetRequest) (*pb.WorkloadDescription, error) {
+	return s.agent.GetRequest(req)
+}
+
+func (s *server) SpawnBatch(req *pb.SpawnBatchRequest, stream grpc.ServerStreamingServer[pb.SpawnBatchResponse]) error {
+	s.logger.Infof("Received spawn batch of %d requests", len(req.GetRequests()))
+
+	return s.agent.SpawnBatchRequest(req, stream.Send)
+}
+
+func (s *server) StopMany(req *pb.BulkRequest, stream grpc.ServerStreamingServer[pb.BulkProgress]) error {
+	s.logger.Infof("Received stop request for many workloads: %v", req)
+
+	return s.agent.StopManyRequest(stream.Context(), req, stream.Send)
+}
+
+func (s *server) RestartMany(req *pb.BulkRequest, stream grpc.ServerStreamingServer[pb.BulkProgress]) error {
+	s.logger.Infof("Received restart request for many workloads: %v", req)
+
+	return s.agent.RestartManyRequest(stream.Context(), req, stream.Send)
+}
+
+// authorize checks that the request carries the expected
+// token as "authorization: Bearer <token>" metadata
+func authorize(ctx context.Context, expected []byte) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), expected) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// checkAPIVersion rejects clients built against a different API
+// version, clients that don't send one are assumed to be compatible
+func checkAPIVersion(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, version := range md.Get(pb.APIVersionMetadataKey) {
+		if version != pb.APIVersion {
+			return status.Errorf(codes.FailedPrecondition, "unsupported API version %s, server supports %s", version, pb.APIVersion)
+		}
+	}
+
+	return nil
+}
+
+func requestChecks(cfg *ServerConfig) []func(context.Context) error {
+	checks := []func(context.Context) error{checkAPIVersion}
+
+	if cfg.SimulatedLatency > 0 {
+		checks = append(checks, func(ctx context.Context) error {
+			select {
+			case <-time.After(cfg.SimulatedLatency):
+				return nil
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		})
+	}
+
+	if cfg.AuthToken != "" {
+		expected := []byte("Bearer " + cfg.AuthToken)
+		checks = append(checks, func(ctx context.Context) error {
+			return authorize(ctx, expected)
+		})
+	}
+
+	return checks
+}
+
+func unaryChecksInterceptor(checks []func(context.Context) error) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for _, check := range checks {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func streamChecksInterceptor(checks []func(context.Context) error) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for _, check := range checks {
+			if err := check(stream.Context()); err != nil {
+				return err
+			}
+		}
+
+		return handler(srv, stream)
+	}
+}
+
+// LoadTLSConfig builds the TLS configuration of a gRPC server
+// or client, verifying peers against caFile if it is set
+func LoadTLSConfig(certFile, keyFile, caFile string, server bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key pair %s/%s: %w", certFile, keyFile, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA %s: %w", caFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA %s", caFile)
+		}
+
+		if server {
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			tlsConfig.RootCAs = pool
+		}
+	}
+
+	if server && len(tlsConfig.Certificates) == 0 {
+		return nil, errors.New("a certificate and key are required to serve TLS")
+	}
+
+	return tlsConfig, nil
+}
+
+// NewServer creates the cluster gRPC server
+func NewServer(logger *log.Logger, agent *Agent, cfg *ServerConfig) (*grpc.Server, error) {
+	checks := requestChecks(cfg)
+
+	maxMessageSize := cfg.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = defaults.GrpcMaxMessageSize
+	}
+
+	// Responses are compressed like the requests, with any of the
+	// compressors registered by the compression package
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryChecksInterceptor(checks)),
+		grpc.ChainStreamInterceptor(streamChecksInterceptor(checks)),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+	}
+
+	if cfg.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLS.ServerTLSConfig())))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterClusterServiceServer(grpcServer, &server{
 		logger: logger,
 		agent:  agent,
 	})
+	registerDebugService(grpcServer, agent)
 
-	return grpcServer
+	return grpcServer, nil
 }
@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/distribution/reference"
+)
+
+// SidecarPolicy lists the sidecars injected into the pods of the matching
+// workloads when they are spawned on the node, e.g. an OTel collector or a
+// log forwarder
+type SidecarPolicy struct {
+	Sidecars []*Sidecar `json:"sidecars"`
+}
+
+// Sidecar is a pod container injected into the matching workloads, unless
+// they already have a container of the same name
+type Sidecar struct {
+	// Name of the pod container
+	Name string `json:"name"`
+	// Image of the container, the image of the workload if empty
+	ImageRef string `json:"image_ref"`
+	// Command of the container, the entrypoint of its image if empty
+	Command []string `json:"command"`
+	// Set on top of the environment of the workload, expanded like it
+	Env map[string]string `json:"env"`
+	// Label selector of the workloads the sidecar is injected into, like
+	// the one of List, all workloads if empty
+	Selector string `json:"selector"`
+	// Tenants that opted out of the sidecar
+	ExcludeTenants []string `json:"exclude_tenants"`
+
+	selector labelSelector
+}
+
+// LoadSidecarPolicy reads the sidecar policy of the cluster, a JSON object
+// with the list of sidecars
+func LoadSidecarPolicy(path string) (*SidecarPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar policy %s: %w", path, err)
+	}
+
+	var policy SidecarPolicy
+	if err := json.Unmarshal(contents, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse sidecar policy %s: %w", path, err)
+	}
+
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid sidecar policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+func (p *SidecarPolicy) compile() error {
+	names := make(map[string]struct{})
+
+	for _, sidecar := range p.Sidecars {
+		if !workloadNameRegexp.MatchString(sidecar.Name) {
+			return fmt.Errorf("invalid sidecar name %q, must be a lowercase DNS label", sidecar.Name)
+		}
+
+		if _, ok := names[sidecar.Name]; ok {
+			return fmt.Errorf("duplicate sidecar name %q", sidecar.Name)
+		}
+
+		names[sidecar.Name] = struct{}{}
+
+		if sidecar.ImageRef != "" {
+			if _, err := reference.ParseDockerRef(sidecar.ImageRef); err != nil {
+				return fmt.Errorf("invalid image reference %q of sidecar %s: %w", sidecar.ImageRef, sidecar.Name, err)
+			}
+		}
+
+		violations := make(fieldViolations)
+		validateEnv(violations, "env", sidecar.Env)
+
+		if err := violations.err("sidecar " + sidecar.Name); err != nil {
+			return err
+		}
+
+		var err error
+		if sidecar.selector, err = parseLabelSelector(sidecar.Selector); err != nil {
+			return fmt.Errorf("invalid selector of sidecar %s: %w", sidecar.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// inject returns the pod containers of the sidecars the workload matches,
+// leaving out the ones it already has a container named like
+func (p *SidecarPolicy) inject(req *pb.VmSpawnRequest) []*pb.PodContainer {
+	var containers []*pb.PodContainer
+
+	for _, sidecar := range p.Sidecars {
+		if slices.Contains(sidecar.ExcludeTenants, req.GetTenant()) || !sidecar.selector.matches(req.GetLabels()) {
+			continue
+		}
+
+		if slices.ContainsFunc(req.GetContainers(), func(container *pb.PodContainer) bool {
+			return container.GetName() == sidecar.Name
+		}) {
+			continue
+		}
+
+		containers = append(containers, &pb.PodContainer{
+			Name:     sidecar.Name,
+			ImageRef: sidecar.ImageRef,
+			Command:  sidecar.Command,
+			Env:      sidecar.Env,
+		})
+	}
+
+	return containers
+}
+
+// injectedSidecars returns the sidecars of the sidecar policy of the node
+// to inject into a workload, nil without a policy
+func (a *Agent) injectedSidecars(id string, req *pb.VmSpawnRequest) []*pb.PodContainer {
+	if a.sidecarPolicy == nil {
+		return nil
+	}
+
+	sidecars := a.sidecarPolicy.inject(req)
+	for _, sidecar := range sidecars {
+		a.logger.Infof("Injecting sidecar %s into workload %s", sidecar.GetName(), id)
+	}
+
+	return sidecars
+}
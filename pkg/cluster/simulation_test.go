@@ -0,0 +1,396 @@
+package cluster_test
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"vistara-node/pkg/cluster"
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	simImage           = "docker.io/library/nginx:latest"
+	simBroadcastPeriod = time.Second
+	// Long enough for the state of a node to reach the others, or for
+	// a crashed node to go stale and its workloads to be respawned
+	simTimeout = simBroadcastPeriod * 20
+)
+
+// simNode is an agent of a simulated cluster, along with the containers
+// of its node
+type simNode struct {
+	agent   *cluster.Agent
+	repo    *fakeRepo
+	crashed bool
+}
+
+// startCluster starts the agents of a cluster in this process, on
+// loopback ports and their own state directories, all joining the first
+// one. configure, if set, adjusts the configuration of each agent
+func startCluster(t *testing.T, size int, configure func(i int, cfg *cluster.AgentConfig)) []*simNode {
+	t.Helper()
+
+	nodes := make([]*simNode, size)
+
+	for i := range nodes {
+		logger := log.New()
+		logger.SetOutput(io.Discard)
+
+		repo := newFakeRepo()
+
+		cfg := &cluster.AgentConfig{
+			BaseURL:         "sim.local",
+			BindAddr:        "127.0.0.1:0",
+			LogDir:          t.TempDir(),
+			DataDir:         t.TempDir(),
+			BroadcastPeriod: simBroadcastPeriod,
+			GossipInterval:  time.Millisecond * 50,
+			ProbeInterval:   time.Millisecond * 200,
+		}
+		if configure != nil {
+			configure(i, cfg)
+		}
+
+		agent, err := cluster.NewAgent(logger, cfg, repo)
+		if err != nil {
+			t.Fatalf("failed to start agent %d: %v", i, err)
+		}
+
+		go agent.Handler()
+
+		if i == 0 {
+			err = agent.MarkReady()
+		} else {
+			err = agent.Join(nodes[0].agent.GossipAddr())
+		}
+
+		if err != nil {
+			t.Fatalf("agent %d failed to join the cluster: %v", i, err)
+		}
+
+		nodes[i] = &simNode{agent: agent, repo: repo}
+	}
+
+	t.Cleanup(func() {
+		for _, node := range nodes {
+			if !node.crashed {
+				_ = node.agent.Crash()
+			}
+		}
+	})
+
+	waitConverged(t, nodes)
+
+	return nodes
+}
+
+// waitFor polls cond until it holds, failing the test past simTimeout
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(simTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
+// converged reports whether every running agent knows the workloads of
+// every running node, as they are in the containers of the node
+func converged(nodes []*simNode) bool {
+	for _, observer := range nodes {
+		if observer.crashed {
+			continue
+		}
+
+		known := make(map[string][]string)
+		for _, state := range observer.agent.KnownStates() {
+			ids := make([]string, 0, len(state.GetWorkloads()))
+			for _, workload := range state.GetWorkloads() {
+				ids = append(ids, workload.GetId())
+			}
+
+			slices.Sort(ids)
+			known[state.GetNode().GetId()] = ids
+		}
+
+		for _, node := range nodes {
+			if node.crashed {
+				continue
+			}
+
+			ids, ok := known[node.agent.NodeName()]
+			if !ok || !slices.Equal(ids, node.repo.ids()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func waitConverged(t *testing.T, nodes []*simNode) {
+	t.Helper()
+
+	waitFor(t, "the states of the nodes to converge", func() bool {
+		return converged(nodes)
+	})
+}
+
+// workloadCounts returns the number of workloads of each node
+func workloadCounts(nodes []*simNode) []int {
+	counts := make([]int, len(nodes))
+	for i, node := range nodes {
+		counts[i] = len(node.repo.ids())
+	}
+
+	return counts
+}
+
+// nodeIndex returns the index of the node with the name, -1 if none
+func nodeIndex(nodes []*simNode, name string) int {
+	return slices.IndexFunc(nodes, func(node *simNode) bool {
+		return node.agent.NodeName() == name
+	})
+}
+
+// Spawns are placed on the other nodes with capacity left, once a node is
+// full according to its state it isn't picked anymore, and spawns fail
+// once all of them are
+func TestSimulationPlacement(t *testing.T) {
+	cores := runtime.NumCPU()
+	if cores > cluster.MaxWorkloadCores {
+		t.Skipf("%d CPUs is more than a workload can have", cores)
+	}
+
+	nodes := startCluster(t, 4, nil)
+
+	for i := range len(nodes) - 1 {
+		resp, err := nodes[0].agent.SpawnRequest(&pb.VmSpawnRequest{ImageRef: simImage, Cores: uint32(cores), Memory: cluster.MinWorkloadMemory})
+		if err != nil {
+			t.Fatalf("spawn %d failed: %v", i, err)
+		}
+
+		if resp.GetPending() {
+			t.Fatalf("spawn %d was queued", i)
+		}
+
+		waitConverged(t, nodes)
+	}
+
+	// The requesting node doesn't answer its own queries
+	if counts := workloadCounts(nodes); !slices.Equal(counts, []int{0, 1, 1, 1}) {
+		t.Fatalf("expected one workload on each of the other nodes, got %v", counts)
+	}
+
+	_, err := nodes[0].agent.SpawnRequest(&pb.VmSpawnRequest{ImageRef: simImage, Cores: uint32(cores), Memory: cluster.MinWorkloadMemory})
+
+	var clusterErr *cluster.ClusterError
+	if !errors.As(err, &clusterErr) || clusterErr.Code != pb.ErrorCode_CAPACITY_EXCEEDED {
+		t.Fatalf("expected a capacity error once every node is full, got %v", err)
+	}
+}
+
+// Every agent assembles the same view of the cluster from the states
+// gossiped by the others, whichever node the workloads were spawned
+// through
+func TestSimulationGossipConvergence(t *testing.T) {
+	nodes := startCluster(t, 10, nil)
+
+	spawned := make(map[string]struct{})
+
+	for i, node := range nodes {
+		resp, err := node.agent.SpawnRequest(&pb.VmSpawnRequest{ImageRef: simImage, Memory: cluster.MinWorkloadMemory})
+		if err != nil {
+			t.Fatalf("spawn through node %d failed: %v", i, err)
+		}
+
+		spawned[resp.GetId()] = struct{}{}
+	}
+
+	waitConverged(t, nodes)
+
+	var ids []string
+	for _, node := range nodes {
+		ids = append(ids, node.repo.ids()...)
+	}
+
+	if !slices.Equal(slices.Sorted(slices.Values(ids)), slices.Sorted(maps.Keys(spawned))) {
+		t.Fatalf("expected the nodes to run the %d spawned workloads, got %v", len(spawned), ids)
+	}
+
+	for i, node := range nodes {
+		if states := node.agent.KnownStates(); len(states) != len(nodes) {
+			t.Fatalf("node %d knows the state of %d nodes, expected %d", i, len(states), len(nodes))
+		}
+	}
+}
+
+// The workloads of a node that stops broadcasting its state are
+// respawned once on another node
+func TestSimulationNodeFailureRespawn(t *testing.T) {
+	nodes := startCluster(t, 4, func(i int, cfg *cluster.AgentConfig) {
+		cfg.Respawn = i == 0
+	})
+
+	req := &pb.VmSpawnRequest{ImageRef: simImage, Memory: cluster.MinWorkloadMemory, Labels: map[string]string{"app": "db"}}
+
+	resp, err := nodes[0].agent.SpawnRequest(req)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+
+	waitConverged(t, nodes)
+
+	failed := slices.IndexFunc(nodes, func(node *simNode) bool {
+		_, ok := node.repo.workloads()[resp.GetId()]
+
+		return ok
+	})
+	if failed <= 0 {
+		t.Fatalf("expected workload %s on one of the other nodes, found on node %d", resp.GetId(), failed)
+	}
+
+	if err := nodes[failed].agent.Crash(); err != nil {
+		t.Fatalf("failed to crash node %d: %v", failed, err)
+	}
+
+	nodes[failed].crashed = true
+
+	respawned := func() []string {
+		var ids []string
+
+		for i, node := range nodes {
+			if i == failed {
+				continue
+			}
+
+			for id, workload := range node.repo.workloads() {
+				if workload.GetLabels()["app"] == "db" {
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		return ids
+	}
+
+	waitFor(t, "the workload of the failed node to be respawned", func() bool {
+		return len(respawned()) > 0
+	})
+
+	// Give a second respawn the time to happen
+	time.Sleep(simBroadcastPeriod * 4)
+
+	if ids := respawned(); len(ids) != 1 {
+		t.Fatalf("expected the workload to be respawned once, got %v", ids)
+	}
+
+	if len(nodes[0].repo.ids()) != 0 {
+		t.Fatal("expected the workload to be respawned on another node than the one respawning it")
+	}
+}
+
+// Batches are spread across the nodes, one workload per node, or packed
+// on as few nodes as possible
+func TestSimulationPlacementPolicy(t *testing.T) {
+	nodes := startCluster(t, 4, nil)
+
+	spawnBatch := func(spread bool) []int {
+		t.Helper()
+
+		batch := &pb.SpawnBatchRequest{Spread: spread}
+		for range len(nodes) - 1 {
+			batch.Requests = append(batch.Requests, &pb.VmSpawnRequest{ImageRef: simImage, Memory: cluster.MinWorkloadMemory})
+		}
+
+		placed := make([]int, len(nodes))
+
+		err := nodes[0].agent.SpawnBatchRequest(batch, func(resp *pb.SpawnBatchResponse) error {
+			if resp.GetError() != "" {
+				t.Errorf("request %d of the batch failed: %s", resp.GetIndex(), resp.GetError())
+
+				return nil
+			}
+
+			if i := nodeIndex(nodes, resp.GetNode()); i >= 0 {
+				placed[i]++
+			} else {
+				t.Errorf("request %d of the batch was placed on unknown node %q", resp.GetIndex(), resp.GetNode())
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("batch failed: %v", err)
+		}
+
+		waitConverged(t, nodes)
+
+		return placed
+	}
+
+	if placed := spawnBatch(true); !slices.Equal(placed, []int{0, 1, 1, 1}) {
+		t.Fatalf("expected the spread batch on every other node, got %v", placed)
+	}
+
+	placed := spawnBatch(false)
+	if slices.Max(placed) != len(nodes)-1 {
+		t.Fatalf("expected the packed batch on a single node, got %v", placed)
+	}
+
+	if counts := workloadCounts(nodes); slices.Max(counts) != len(nodes) || counts[0] != 0 {
+		t.Fatalf("expected the packed batch on a node holding a workload of the spread batch, got %v", counts)
+	}
+}
+
+// A replica group failing to spawn all of its replicas doesn't leave the
+// ones already spawned running, and its name can be used again
+func TestSimulationScaledSpawnFailure(t *testing.T) {
+	cores := runtime.NumCPU()
+	if cores > cluster.MaxWorkloadCores {
+		t.Skipf("%d CPUs is more than a workload can have", cores)
+	}
+
+	nodes := startCluster(t, 3, nil)
+
+	// The requesting node doesn't answer its own queries, so only two of
+	// the replicas find a node
+	req := &pb.VmSpawnRequest{
+		ImageRef:          simImage,
+		Cores:             uint32(cores),
+		Memory:            cluster.MinWorkloadMemory,
+		Name:              "web",
+		HorizontalScaling: &pb.HorizontalScalingPolicy{MinReplicas: 3, MaxReplicas: 3},
+	}
+
+	_, err := nodes[0].agent.SpawnRequest(req)
+
+	var clusterErr *cluster.ClusterError
+	if !errors.As(err, &clusterErr) || clusterErr.Code != pb.ErrorCode_CAPACITY_EXCEEDED {
+		t.Fatalf("expected a capacity error for the last replica, got %v", err)
+	}
+
+	waitFor(t, "the spawned replicas to be stopped", func() bool {
+		return slices.Max(workloadCounts(nodes)) == 0
+	})
+
+	resp, err := nodes[0].agent.SpawnRequest(&pb.VmSpawnRequest{ImageRef: simImage, Memory: cluster.MinWorkloadMemory, Name: "web"})
+	if err != nil {
+		t.Fatalf("expected the name of the failed group to be released, got %v", err)
+	}
+
+	if resp.GetPending() {
+		t.Fatal("expected the spawn to find a node once the replicas are stopped")
+	}
+}
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+type SavedStatusUpdate struct {
+	update     *pb.NodeStateResponse
+	receivedAt time.Time
+}
+
+// stateStore keeps the last state broadcast by this node and the last
+// ones received from the other nodes. It is shared by the serf event
+// handler, the monitors and the gRPC handlers, the states it returns
+// must not be modified
+type stateStore struct {
+	// States received longer ago are stale, their node is considered
+	// failed
+	staleAfter time.Duration
+
+	mu           sync.Mutex
+	local        *pb.NodeStateResponse
+	reconciledAt time.Time
+	remote       map[string]SavedStatusUpdate
+}
+
+func newStateStore(staleAfter time.Duration) *stateStore {
+	return &stateStore{staleAfter: staleAfter, remote: make(map[string]SavedStatusUpdate)}
+}
+
+// setLocal replaces the state of this node, returning the previous one
+func (s *stateStore) setLocal(state *pb.NodeStateResponse) *pb.NodeStateResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.local
+	s.local = state
+	s.reconciledAt = time.Now()
+
+	return previous
+}
+
+// localState returns the last state broadcast by this node, nil until
+// the first broadcast
+func (s *stateStore) localState() *pb.NodeStateResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.local
+}
+
+// lastReconcile returns when the local state was last set, zero if it
+// never was
+func (s *stateStore) lastReconcile() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reconciledAt
+}
+
+// record saves the state received from a node, returning the previous
+// one, nil if there was none
+func (s *stateStore) record(node string, state *pb.NodeStateResponse) *pb.NodeStateResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.remote[node]
+	s.remote[node] = SavedStatusUpdate{update: state, receivedAt: time.Now()}
+
+	return previous.update
+}
+
+// known returns the state of this node along with the states of the
+// other nodes that aren't stale
+func (s *stateStore) known() []*pb.NodeStateResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]*pb.NodeStateResponse, 0, len(s.remote)+1)
+	if s.local != nil {
+		states = append(states, s.local)
+	}
+
+	for _, saved := range s.remote {
+		if time.Since(saved.receivedAt) <= s.staleAfter {
+			states = append(states, saved.update)
+		}
+	}
+
+	return states
+}
+
+// takeStale removes and returns the stale states, so the workloads of a
+// failed node are only rescheduled once. A node coming back is recorded
+// again with its next broadcast
+func (s *stateStore) takeStale(upgrading func(node string) bool) map[string]SavedStatusUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stale := make(map[string]SavedStatusUpdate)
+
+	for node, saved := range s.remote {
+		staleAfter := s.staleAfter
+		if upgrading(node) {
+			staleAfter = max(staleAfter, UpgradeGracePeriod)
+		}
+
+		if time.Since(saved.receivedAt) > staleAfter {
+			stale[node] = saved
+			delete(s.remote, node)
+		}
+	}
+
+	return stale
+}
+
+// receivedAt returns when the state of each other node was last received
+func (s *stateStore) receivedAt() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := make(map[string]time.Time, len(s.remote))
+	for node, saved := range s.remote {
+		times[node] = saved.receivedAt
+	}
+
+	return times
+}
+
+// workloadNode returns the name of the other node last reported to be
+// running the workload, empty if there is none
+func (s *stateStore) workloadNode(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for node, saved := range s.remote {
+		for _, workload := range saved.update.GetWorkloads() {
+			if workload.GetId() == id {
+				return node
+			}
+		}
+	}
+
+	return ""
+}
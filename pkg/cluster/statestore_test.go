@@ -0,0 +1,238 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+func testState(node string, workloads ...string) *pb.NodeStateResponse {
+	state := &pb.NodeStateResponse{Node: &pb.Node{Id: node}}
+	for _, id := range workloads {
+		state.Workloads = append(state.Workloads, &pb.WorkloadState{Id: id})
+	}
+
+	return state
+}
+
+// recordStale saves a state received longer ago than the store keeps
+// states for
+func recordStale(store *stateStore, node string, state *pb.NodeStateResponse) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.remote[node] = SavedStatusUpdate{update: state, receivedAt: time.Now().Add(-store.staleAfter * 2)}
+}
+
+func notUpgrading(string) bool {
+	return false
+}
+
+func TestStateStoreRecord(t *testing.T) {
+	store := newStateStore(time.Hour)
+
+	if previous := store.record("node-1", testState("node-1", "a")); previous != nil {
+		t.Fatalf("expected no previous state, got %v", previous)
+	}
+
+	if local := store.localState(); local != nil {
+		t.Fatalf("expected no local state before the first broadcast, got %v", local)
+	}
+
+	if previous := store.record("node-1", testState("node-1", "b")); previous.GetWorkloads()[0].GetId() != "a" {
+		t.Fatalf("expected the previous state to be returned, got %v", previous)
+	}
+
+	if node := store.workloadNode("b"); node != "node-1" {
+		t.Fatalf("expected workload b on node-1, got %q", node)
+	}
+
+	if node := store.workloadNode("a"); node != "" {
+		t.Fatalf("expected workload a to be gone with the previous state, got %q", node)
+	}
+
+	if !store.lastReconcile().IsZero() {
+		t.Fatal("expected no reconcile before the local state is set")
+	}
+
+	store.setLocal(testState("local", "c"))
+
+	if store.lastReconcile().IsZero() {
+		t.Fatal("expected setting the local state to count as a reconcile")
+	}
+
+	// Workloads of the local node aren't looked up
+	if node := store.workloadNode("c"); node != "" {
+		t.Fatalf("expected the local workload to be ignored, got %q", node)
+	}
+
+	if states := store.known(); len(states) != 2 {
+		t.Fatalf("expected the local and remote states, got %d", len(states))
+	}
+}
+
+func TestStateStoreTakeStale(t *testing.T) {
+	store := newStateStore(time.Minute)
+
+	store.setLocal(testState("local"))
+	recordStale(store, "failed", testState("failed", "a"))
+	recordStale(store, "upgrading", testState("upgrading", "b"))
+	store.record("alive", testState("alive", "c"))
+
+	known := store.known()
+	if len(known) != 2 {
+		t.Fatalf("expected the local state and the state of alive, got %d states", len(known))
+	}
+
+	stale := store.takeStale(func(node string) bool { return node == "upgrading" })
+	if len(stale) != 1 || stale["failed"].update.GetNode().GetId() != "failed" {
+		t.Fatalf("expected only failed to be stale, got %v", stale)
+	}
+
+	if stale := store.takeStale(notUpgrading); len(stale) != 1 || stale["upgrading"].update == nil {
+		t.Fatalf("expected upgrading to go stale once it isn't upgrading, got %v", stale)
+	}
+
+	if stale := store.takeStale(notUpgrading); len(stale) != 0 {
+		t.Fatalf("expected stale states to be taken once, got %v", stale)
+	}
+
+	if _, ok := store.receivedAt()["failed"]; ok {
+		t.Fatal("expected the taken state to be removed")
+	}
+
+	// A node coming back is recorded again with its next broadcast
+	if previous := store.record("failed", testState("failed", "a")); previous != nil {
+		t.Fatalf("expected no previous state for a node that was taken, got %v", previous)
+	}
+}
+
+// Readers, writers and stale state collection run concurrently, run with
+// -race
+func TestStateStoreConcurrentAccess(t *testing.T) {
+	const (
+		nodes   = 8
+		updates = 200
+	)
+
+	store := newStateStore(time.Hour)
+
+	var wg sync.WaitGroup
+
+	for i := range nodes {
+		node := fmt.Sprintf("node-%d", i)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range updates {
+				store.record(node, testState(node, fmt.Sprintf("%s-%d", node, j)))
+			}
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for j := range updates {
+			store.setLocal(testState("local", fmt.Sprintf("local-%d", j)))
+		}
+	}()
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range updates {
+				for _, state := range store.known() {
+					_ = state.GetWorkloads()
+				}
+
+				_ = store.localState()
+				_ = store.lastReconcile()
+				_ = store.receivedAt()
+				_ = store.workloadNode("node-0-0")
+				_ = store.takeStale(notUpgrading)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	known := store.known()
+	if len(known) != nodes+1 {
+		t.Fatalf("expected the states of %d nodes, got %d", nodes+1, len(known))
+	}
+
+	for _, state := range known {
+		node := state.GetNode().GetId()
+		if want := fmt.Sprintf("%s-%d", node, updates-1); state.GetWorkloads()[0].GetId() != want {
+			t.Fatalf("expected the last state of %s to be kept, got workload %s", node, state.GetWorkloads()[0].GetId())
+		}
+	}
+}
+
+// Concurrent collections of stale states return each of them once, so
+// the workloads of a failed node aren't rescheduled twice
+func TestStateStoreConcurrentTakeStale(t *testing.T) {
+	const (
+		nodes     = 100
+		collector = 8
+	)
+
+	store := newStateStore(time.Minute)
+
+	for i := range nodes {
+		recordStale(store, fmt.Sprintf("node-%d", i), testState(fmt.Sprintf("node-%d", i)))
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken = make(map[string]int)
+	)
+
+	for range collector {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for node := range store.takeStale(notUpgrading) {
+				mu.Lock()
+				taken[node]++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Nodes recorded while the states are collected aren't stale
+	for i := range nodes {
+		store.record(fmt.Sprintf("fresh-%d", i), testState(fmt.Sprintf("fresh-%d", i)))
+	}
+
+	wg.Wait()
+
+	if len(taken) != nodes {
+		t.Fatalf("expected %d stale states, got %d", nodes, len(taken))
+	}
+
+	for node, count := range taken {
+		if count != 1 {
+			t.Fatalf("expected the state of %s to be taken once, got %d", node, count)
+		}
+	}
+
+	if received := store.receivedAt(); len(received) != nodes {
+		t.Fatalf("expected the %d fresh states to be kept, got %d", nodes, len(received))
+	}
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "vistara-node/pkg/proto/cluster"
+)
+
+// StatusRequest summarizes the health of the cluster from the states
+// broadcast by the nodes and the proxy and serf queues of this node
+func (a *Agent) StatusRequest(_ *pb.StatusRequest) *pb.StatusResponse {
+	resp := &pb.StatusResponse{
+		Node:      a.cfg.NodeName,
+		Nodes:     make(map[string]uint32),
+		Workloads: map[string]uint32{"ready": 0, "unready": 0, "queued": uint32(a.admission.queued())},
+	}
+
+	for _, member := range a.serf.Members() {
+		resp.Nodes[strings.ToLower(strings.TrimPrefix(memberNodeStatus(member).String(), "NODE_"))]++
+	}
+
+	backends := a.serviceProxy.BackendCounts()
+	services := make(map[string]*pb.ServiceHealth)
+
+	for _, state := range a.knownStates() {
+		resp.Cpus += state.GetCpus()
+		resp.Memory += state.GetMemory()
+
+		for _, workload := range state.GetWorkloads() {
+			spec := workload.GetSourceRequest()
+			resp.CpusCommitted += spec.GetCores()
+			resp.MemoryCommitted += uint64(spec.GetMemory())
+
+			ready := workloadReady(workload)
+			if ready {
+				resp.Workloads["ready"]++
+			} else {
+				resp.Workloads["unready"]++
+			}
+
+			if len(spec.GetPorts()) == 0 {
+				continue
+			}
+
+			serviceID := spec.GetReplicaGroup()
+			if serviceID == "" {
+				serviceID = workload.GetId()
+			}
+
+			service, ok := services[serviceID]
+			if !ok {
+				service = &pb.ServiceHealth{ServiceId: serviceID, Backends: uint32(backends[serviceID])}
+				services[serviceID] = service
+				resp.Services = append(resp.Services, service)
+			}
+
+			if ready {
+				service.Ready++
+			} else {
+				service.Unready++
+			}
+		}
+	}
+
+	sort.Slice(resp.GetServices(), func(i, j int) bool {
+		return resp.GetServices()[i].GetServiceId() < resp.GetServices()[j].GetServiceId()
+	})
+
+	stats := a.serf.Stats()
+	resp.SerfQueues = &pb.SerfQueueDepths{
+		Event:  parseSerfStat(stats["event_queue"]),
+		Query:  parseSerfStat(stats["query_queue"]),
+		Intent: parseSerfStat(stats["intent_queue"]),
+	}
+
+	if reconciledAt := a.states.lastReconcile(); !reconciledAt.IsZero() {
+		resp.LastReconcileUnixTime = reconciledAt.Unix()
+	}
+
+	for node, receivedAt := range a.states.receivedAt() {
+		resp.NodeUpdates = append(resp.NodeUpdates, &pb.NodeReconcile{Node: node, UnixTime: receivedAt.Unix()})
+	}
+
+	sort.Slice(resp.GetNodeUpdates(), func(i, j int) bool {
+		return resp.GetNodeUpdates()[i].GetNode() < resp.GetNodeUpdates()[j].GetNode()
+	})
+
+	return resp
+}
+
+func parseSerfStat(value string) uint32 {
+	parsed, _ := strconv.ParseUint(value, 10, 32)
+
+	return uint32(parsed)
+}
@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	vcontainerd "vistara-node/pkg/containerd"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DrainEvent = "hypercore_drain"
+
+	// How long a stopping workload keeps running after it was removed
+	// from the proxies, so the other nodes deregister it, bounded by its
+	// stop grace period
+	StopDrainDelay = time.Second * 2
+)
+
+// drainingSet holds the local workloads being stopped
+type drainingSet struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+// start marks a workload as stopping, returning false if it already was
+func (d *drainingSet) start(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.ids[id]; ok {
+		return false
+	}
+
+	d.ids[id] = struct{}{}
+
+	return true
+}
+
+func (d *drainingSet) has(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.ids[id]
+
+	return ok
+}
+
+func (d *drainingSet) done(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.ids, id)
+}
+
+// stopGracefully stops a workload being drained, removing its logs once it
+// is deleted
+func (a *Agent) stopGracefully(id string, spec *pb.VmSpawnRequest) {
+	defer a.draining.done(id)
+
+	if err := a.stopWorkload(id, spec); err != nil {
+		a.logger.WithError(err).Errorf("failed to stop container %s", id)
+		a.publishEvent(&pb.WatchEventsResponse{
+			Event:        pb.ClusterEvent_ERROR,
+			Id:           id,
+			ReplicaGroup: spec.GetReplicaGroup(),
+			Message:      fmt.Sprintf("failed to stop: %s", err),
+		})
+
+		return
+	}
+
+	removeWorkloadLogs(a.logDir, id)
+}
+
+// stopWorkload runs the pre-stop command of a workload and waits for the
+// drain delay and for the requests the proxy of this node is still serving
+// from it before sending it SIGTERM, it is killed if it is still running at
+// the end of its grace period
+func (a *Agent) stopWorkload(id string, spec *pb.VmSpawnRequest) error {
+	gracePeriod := time.Duration(spec.GetStopGracePeriodSeconds()) * time.Second
+	if gracePeriod == 0 {
+		gracePeriod = vcontainerd.DefaultStopTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctrRepo.GetContext(context.Background()), gracePeriod)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+
+	if command := spec.GetPreStopCommand(); len(command) > 0 {
+		a.logger.Infof("Running pre-stop command %v of workload %s", command, id)
+
+		if code, err := a.ctrRepo.ExecContainer(ctx, id, command); err != nil {
+			a.logger.WithError(err).Warnf("failed to run pre-stop command of workload %s", id)
+		} else if code != 0 {
+			a.logger.Warnf("pre-stop command of workload %s exited with status %d", id, code)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(StopDrainDelay):
+	}
+
+	if left := a.serviceProxy.Drain(ctx, id); left > 0 {
+		a.logger.Warnf("Stopping workload %s with %d requests still in flight", id, left)
+	}
+
+	// Whatever is left of the grace period once drained, the container
+	// is killed right away if none is
+	stopTimeout := max(time.Until(deadline), time.Millisecond)
+
+	_, err := a.ctrRepo.DeleteContainer(context.Background(), id, stopTimeout)
+
+	return err
+}
+
+// broadcastDrain tells the other nodes to stop sending requests to
+// workloads being stopped, without waiting for the next state broadcast
+func (a *Agent) broadcastDrain(ids []string) {
+	payload, err := proto.Marshal(&pb.VmStopResponse{StoppedIds: ids})
+	if err != nil {
+		a.logger.WithError(err).Error("failed to marshal drain event")
+
+		return
+	}
+
+	if err := a.serf.UserEvent(DrainEvent, payload, false); err != nil {
+		a.logger.WithError(err).Errorf("failed to broadcast drain of %v", ids)
+	}
+}
+
+func (a *Agent) handleDrainEvent(payload []byte) {
+	var drained pb.VmStopResponse
+	if err := proto.Unmarshal(payload, &drained); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal drain event")
+
+		return
+	}
+
+	for _, id := range drained.GetStoppedIds() {
+		a.serviceProxy.Deregister(id)
+	}
+}
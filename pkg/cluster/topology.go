@@ -0,0 +1,310 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vistara-node/pkg/network"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Period the connections of the local workloads are sampled at
+	TopologySamplePeriod = 15 * time.Second
+	// Edges not observed for longer are dropped
+	TopologyWindow = 10 * time.Minute
+)
+
+// edgeKey identifies an edge of the topology
+type edgeKey struct {
+	sourceID, sourceAddress           string
+	destinationID, destinationAddress string
+	port                              uint32
+	protocol                          string
+	workload                          string
+}
+
+// flowKey identifies a connection across samples, in the network
+// namespace of a workload
+type flowKey struct {
+	workload string
+	protocol string
+	src, dst string
+}
+
+// flowCounters are the byte counters of a connection at its last sample
+type flowCounters struct {
+	sent     uint64
+	received uint64
+}
+
+// topologyTracker accumulates the connections of the local workloads
+// sampled from conntrack into edges
+type topologyTracker struct {
+	mu    sync.Mutex
+	edges map[edgeKey]*pb.TopologyEdge
+	flows map[flowKey]flowCounters
+}
+
+func newTopologyTracker() *topologyTracker {
+	return &topologyTracker{
+		edges: make(map[edgeKey]*pb.TopologyEdge),
+		flows: make(map[flowKey]flowCounters),
+	}
+}
+
+// observedFlow is a connection sampled from the network namespace of a
+// workload, with the edge it belongs to
+type observedFlow struct {
+	key     flowKey
+	edge    edgeKey
+	flow    network.Flow
+	tenant  string
+	sampled time.Time
+}
+
+// record adds the connections of a sample to their edge, counting the
+// bytes they moved since the previous one. Connections gone since are
+// forgotten, along with the edges not observed within the window
+func (t *topologyTracker) record(node string, observed []observedFlow, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	flows := make(map[flowKey]flowCounters, len(observed))
+
+	for _, sample := range observed {
+		edge, ok := t.edges[sample.edge]
+		if !ok {
+			edge = &pb.TopologyEdge{
+				SourceId:           sample.edge.sourceID,
+				SourceAddress:      sample.edge.sourceAddress,
+				DestinationId:      sample.edge.destinationID,
+				DestinationAddress: sample.edge.destinationAddress,
+				Port:               sample.edge.port,
+				Protocol:           sample.edge.protocol,
+				Node:               node,
+				Tenant:             sample.tenant,
+			}
+			t.edges[sample.edge] = edge
+		}
+
+		previous, known := t.flows[sample.key]
+		if !known {
+			edge.Connections++
+		}
+
+		// Counters only grow for a connection, a smaller one is a new
+		// connection between the same ports
+		if sample.flow.SentBytes >= previous.sent && sample.flow.ReceivedBytes >= previous.received {
+			edge.SentBytes += sample.flow.SentBytes - previous.sent
+			edge.ReceivedBytes += sample.flow.ReceivedBytes - previous.received
+		} else {
+			edge.Connections++
+			edge.SentBytes += sample.flow.SentBytes
+			edge.ReceivedBytes += sample.flow.ReceivedBytes
+		}
+
+		edge.LastSeenUnixTime = sample.sampled.Unix()
+		flows[sample.key] = flowCounters{sent: sample.flow.SentBytes, received: sample.flow.ReceivedBytes}
+	}
+
+	t.flows = flows
+
+	for key, edge := range t.edges {
+		if now.Sub(time.Unix(edge.GetLastSeenUnixTime(), 0)) > TopologyWindow {
+			delete(t.edges, key)
+		}
+	}
+}
+
+// list returns the edges of the workloads of the tenant, of all of them
+// if empty
+func (t *topologyTracker) list(tenant string) []*pb.TopologyEdge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	edges := make([]*pb.TopologyEdge, 0, len(t.edges))
+
+	for _, edge := range t.edges {
+		if tenant == "" || edge.GetTenant() == tenant {
+			edges = append(edges, proto.Clone(edge).(*pb.TopologyEdge))
+		}
+	}
+
+	return edges
+}
+
+// sampleTopology periodically samples the connections tracked in the
+// network namespaces of the local workloads
+func (a *Agent) sampleTopology() {
+	ticker := time.NewTicker(TopologySamplePeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sources, err := a.workloadSources()
+		if err != nil {
+			a.logger.WithError(err).Error("failed to get the addresses of the local workloads")
+
+			continue
+		}
+
+		ctx := a.ctrRepo.GetContext(context.Background())
+		now := time.Now()
+		peers := a.topologyPeers()
+
+		var observed []observedFlow
+
+		for ip, source := range sources {
+			flows, err := a.ctrRepo.ConnectionFlows(ctx, source.id)
+			if err != nil {
+				a.logger.WithError(err).Debugf("failed to sample the connections of workload %s", source.id)
+
+				continue
+			}
+
+			for _, flow := range flows {
+				edge, ok := topologyEdgeKey(source.id, ip, flow, sources, peers)
+				if !ok {
+					continue
+				}
+
+				observed = append(observed, observedFlow{
+					key: flowKey{
+						workload: source.id,
+						protocol: flow.Protocol,
+						src:      net.JoinHostPort(flow.Src.String(), strconv.Itoa(int(flow.SrcPort))),
+						dst:      net.JoinHostPort(flow.Dst.String(), strconv.Itoa(int(flow.DstPort))),
+					},
+					edge:    edge,
+					flow:    flow,
+					tenant:  source.tenant,
+					sampled: now,
+				})
+			}
+		}
+
+		a.topology.record(a.cfg.NodeName, observed, now)
+	}
+}
+
+// topologyPeers maps the host ports of the workloads of the other nodes,
+// by address and port of their node, to the workloads
+func (a *Agent) topologyPeers() map[string]string {
+	addrs := make(map[string]string)
+
+	for _, member := range a.serf.Members() {
+		if member.Name != a.cfg.NodeName && member.Status == serf.StatusAlive {
+			addrs[member.Name] = member.Addr.String()
+		}
+	}
+
+	peers := make(map[string]string)
+
+	for _, state := range a.knownStates() {
+		addr, ok := addrs[state.GetNode().GetId()]
+		if !ok {
+			continue
+		}
+
+		for _, workload := range state.GetWorkloads() {
+			for hostPort := range workload.GetSourceRequest().GetPorts() {
+				peers[net.JoinHostPort(addr, strconv.Itoa(int(hostPort)))] = workload.GetId()
+			}
+		}
+	}
+
+	return peers
+}
+
+// topologyEdgeKey returns the edge of a connection observed in the
+// network namespace of the workload with the address. Connections from
+// the other local workloads are left to their own namespace
+func topologyEdgeKey(id, ip string, flow network.Flow, sources map[string]workloadSource, peers map[string]string) (edgeKey, bool) {
+	edge := edgeKey{
+		destinationAddress: flow.Dst.String(),
+		port:               uint32(flow.DstPort),
+		protocol:           flow.Protocol,
+		workload:           id,
+	}
+
+	switch {
+	case flow.Src.String() == ip:
+		edge.sourceID = id
+
+		if destination, ok := sources[edge.destinationAddress]; ok {
+			edge.destinationID = destination.id
+		} else {
+			edge.destinationID = peers[net.JoinHostPort(edge.destinationAddress, strconv.Itoa(int(flow.DstPort)))]
+		}
+	case edge.destinationAddress == ip:
+		if _, ok := sources[flow.Src.String()]; ok {
+			return edgeKey{}, false
+		}
+
+		edge.sourceAddress = flow.Src.String()
+		edge.destinationID = id
+	default:
+		return edgeKey{}, false
+	}
+
+	return edge, true
+}
+
+// TopologyRequest returns the edges observed by this node, along with
+// those of the other nodes unless the request is local. Nodes that don't
+// track the connections of their workloads have none
+func (a *Agent) TopologyRequest(ctx context.Context, req *pb.TopologyRequest) (*pb.TopologyResponse, error) {
+	resp := &pb.TopologyResponse{WindowSeconds: uint32(TopologyWindow.Seconds())}
+
+	if a.topology != nil {
+		resp.Edges = a.topology.list(req.GetTenant())
+	}
+
+	if !req.GetLocal() {
+		for _, member := range a.serf.Members() {
+			if member.Name == a.cfg.NodeName || member.Status != serf.StatusAlive || member.Tags[GrpcPortTag] == "" {
+				continue
+			}
+
+			edges, err := a.nodeTopology(ctx, &member, req.GetTenant())
+			if err != nil {
+				a.logger.WithError(err).Warnf("failed to get the connections of node %s", member.Name)
+				resp.UnreachableNodes = append(resp.UnreachableNodes, member.Name)
+
+				continue
+			}
+
+			resp.Edges = append(resp.Edges, edges...)
+		}
+	}
+
+	sort.Slice(resp.GetEdges(), func(i, j int) bool {
+		return resp.GetEdges()[i].GetSentBytes()+resp.GetEdges()[i].GetReceivedBytes() >
+			resp.GetEdges()[j].GetSentBytes()+resp.GetEdges()[j].GetReceivedBytes()
+	})
+
+	return resp, nil
+}
+
+func (a *Agent) nodeTopology(ctx context.Context, member *serf.Member, tenant string) ([]*pb.TopologyEdge, error) {
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewClusterServiceClient(conn).Topology(forwardedContext(ctx), &pb.TopologyRequest{Tenant: tenant, Local: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connections: %w", err)
+	}
+
+	return resp.GetEdges(), nil
+}
@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+
+	"vistara-node/pkg/network"
+)
+
+// trafficSample is the traffic of a workload at its last sample
+type trafficSample struct {
+	tenant      string
+	counters    network.TrafficCounters
+	retransmits uint64
+}
+
+// workloadTraffic holds the traffic of the local workloads counted with
+// eBPF, served as Prometheus metrics
+type workloadTraffic struct {
+	mu      sync.Mutex
+	samples map[string]trafficSample
+}
+
+func newWorkloadTraffic() *workloadTraffic {
+	return &workloadTraffic{samples: make(map[string]trafficSample)}
+}
+
+func (t *workloadTraffic) record(id string, sample trafficSample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[id] = sample
+}
+
+func (t *workloadTraffic) forget(running map[string]struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id := range t.samples {
+		if _, ok := running[id]; !ok {
+			delete(t.samples, id)
+		}
+	}
+}
+
+// write writes the counters of the workloads in the Prometheus text format
+func (t *workloadTraffic) write(w io.Writer, node string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := slices.Sorted(maps.Keys(t.samples))
+
+	counters := []struct {
+		name, help string
+		value      func(trafficSample) uint64
+	}{
+		{"hypercore_workload_receive_bytes_total", "Bytes received by the workload", func(s trafficSample) uint64 { return s.counters.RxBytes }},
+		{"hypercore_workload_receive_packets_total", "Packets received by the workload", func(s trafficSample) uint64 { return s.counters.RxPackets }},
+		{"hypercore_workload_transmit_bytes_total", "Bytes sent by the workload", func(s trafficSample) uint64 { return s.counters.TxBytes }},
+		{"hypercore_workload_transmit_packets_total", "Packets sent by the workload", func(s trafficSample) uint64 { return s.counters.TxPackets }},
+		{"hypercore_workload_tcp_retransmits_total", "TCP segments retransmitted by the workload", func(s trafficSample) uint64 { return s.retransmits }},
+	}
+
+	for _, counter := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+
+		for _, id := range ids {
+			sample := t.samples[id]
+			fmt.Fprintf(w, "%s{node=%q,workload=%q,tenant=%q} %d\n", counter.name, node, id, sample.tenant, counter.value(sample))
+		}
+	}
+}
+
+// workloadNetCounters returns the bytes received and sent by a workload,
+// counted with eBPF if it is, or read from the interface of its network
+// namespace otherwise
+func (a *Agent) workloadNetCounters(ctx context.Context, id, tenant string, pid uint32) (netCounters, error) {
+	if a.traffic != nil {
+		counters, err := a.ctrRepo.TrafficCounters(ctx, id)
+		if err == nil {
+			retransmits, err := getWorkloadRetransmits(pid)
+			if err != nil {
+				a.logger.WithError(err).Debugf("failed to get the retransmits of container %s", id)
+			}
+
+			a.traffic.record(id, trafficSample{tenant: tenant, counters: counters, retransmits: retransmits})
+
+			return netCounters{rx: counters.RxBytes, tx: counters.TxBytes}, nil
+		}
+
+		a.logger.WithError(err).Debugf("traffic of container %s isn't counted with eBPF", id)
+	}
+
+	return getWorkloadNetCounters(pid)
+}
@@ -0,0 +1,355 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// How long a replacement replica has to show up in the state
+	// broadcasts and accept connections on its ports
+	UpdateHealthTimeout = time.Minute * 2
+
+	updateHealthPollPeriod = time.Second
+	updateDialTimeout      = time.Second * 2
+)
+
+// updateState tracks the workloads being updated by this node
+type updateState struct {
+	mu         sync.Mutex
+	inProgress map[string]struct{}
+}
+
+func (u *updateState) start(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.inProgress[id]; ok {
+		return false
+	}
+
+	u.inProgress[id] = struct{}{}
+
+	return true
+}
+
+func (u *updateState) done(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.inProgress, id)
+}
+
+// updateTargets returns the workload with the given ID, or the workloads
+// of the replica group
+func (a *Agent) updateTargets(id string) []*pb.WorkloadState {
+	workloads := a.groupWorkloads(id)
+
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if workload.GetId() == id {
+				workloads = append(workloads, workload)
+			}
+		}
+	}
+
+	return workloads
+}
+
+// currentSpec returns the spec of the most recent revision among the
+// workloads
+func currentSpec(workloads []*pb.WorkloadState) *pb.VmSpawnRequest {
+	current := workloads[0].GetSourceRequest()
+	for _, workload := range workloads {
+		if workload.GetSourceRequest().GetRevision() > current.GetRevision() {
+			current = workload.GetSourceRequest()
+		}
+	}
+
+	spec := proto.Clone(current).(*pb.VmSpawnRequest)
+	spec.DryRun = false
+	spec.IdempotencyKey = ""
+
+	return spec
+}
+
+// UpdateWorkloadRequest replaces the replicas of a workload with replicas
+// running the updated spec
+func (a *Agent) UpdateWorkloadRequest(req *pb.UpdateWorkloadRequest) (*pb.UpdateWorkloadResponse, error) {
+	return a.rollOut(req.GetId(), applyUpdate(req))
+}
+
+// applyUpdate returns a function setting the fields of the update
+// request on a copy of the current spec
+func applyUpdate(req *pb.UpdateWorkloadRequest) func(current *pb.VmSpawnRequest) (*pb.VmSpawnRequest, error) {
+	return func(current *pb.VmSpawnRequest) (*pb.VmSpawnRequest, error) {
+		spec := proto.Clone(current).(*pb.VmSpawnRequest)
+
+		if req.GetImageRef() != "" {
+			spec.ImageRef = req.GetImageRef()
+		}
+
+		if len(req.GetEnv()) > 0 {
+			spec.Env = req.GetEnv()
+		}
+
+		if req.GetCores() > 0 {
+			spec.Cores = req.GetCores()
+		}
+
+		if req.GetMemory() > 0 {
+			spec.Memory = req.GetMemory()
+		}
+
+		return spec, nil
+	}
+}
+
+// RollbackRequest replaces the replicas of a workload with replicas running
+// the spec of a previous revision, recorded as a new revision
+func (a *Agent) RollbackRequest(req *pb.RollbackRequest) (*pb.UpdateWorkloadResponse, error) {
+	return a.rollOut(req.GetId(), func(current *pb.VmSpawnRequest) (*pb.VmSpawnRequest, error) {
+		var target *pb.WorkloadRevision
+
+		for _, revision := range a.revisions.history(req.GetId()) {
+			if req.GetRevision() == 0 && revision.GetRevision() < current.GetRevision() || revision.GetRevision() == req.GetRevision() {
+				target = revision
+			}
+		}
+
+		if target == nil {
+			return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no revision to roll %s back to", req.GetId())
+		}
+
+		a.logger.Infof("Rolling back workload %s from revision %d to revision %d", req.GetId(), current.GetRevision(), target.GetRevision())
+
+		return target.GetSpec(), nil
+	})
+}
+
+// ListRevisionsRequest returns the revision history of a workload
+func (a *Agent) ListRevisionsRequest(req *pb.ListRevisionsRequest) (*pb.ListRevisionsResponse, error) {
+	revisions := a.revisions.history(req.GetId())
+	if len(revisions) == 0 {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no revisions found for %s", req.GetId())
+	}
+
+	return &pb.ListRevisionsResponse{Revisions: revisions}, nil
+}
+
+// rollOut replaces the replicas of a workload one at a time: the new
+// replica is spawned and must be healthy before the old one is stopped,
+// the update stops at the first replica that fails
+func (a *Agent) rollOut(id string, update func(current *pb.VmSpawnRequest) (*pb.VmSpawnRequest, error)) (*pb.UpdateWorkloadResponse, error) {
+	if !a.updates.start(id) {
+		return nil, status.Errorf(codes.Aborted, "an update of %s is already in progress", id)
+	}
+	defer a.updates.done(id)
+
+	workloads := a.updateTargets(id)
+	if len(workloads) == 0 {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", id)
+	}
+
+	spec, err := a.nextRevision(id, workloads, update)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.Infof("Updating workload %s to revision %d, replacing %d replicas", id, spec.GetRevision(), len(workloads))
+
+	resp := &pb.UpdateWorkloadResponse{Url: id + "." + a.baseURL}
+
+	for _, old := range workloads {
+		spawned, err := a.replaceReplica(id, old, spec)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Ids = append(resp.Ids, spawned)
+	}
+
+	resp.Revision = &pb.WorkloadRevision{Id: id, Revision: spec.GetRevision(), Spec: spec, UnixTime: time.Now().Unix()}
+	if err := a.recordRevision(resp.GetRevision()); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// nextRevision returns the spec of the next revision of a workload, with
+// update applied to the spec of its current revision
+func (a *Agent) nextRevision(id string, workloads []*pb.WorkloadState, update func(current *pb.VmSpawnRequest) (*pb.VmSpawnRequest, error)) (*pb.VmSpawnRequest, error) {
+	current := currentSpec(workloads)
+
+	history := a.revisions.history(id)
+	if len(history) == 0 {
+		// Keep the spec the workload was spawned with so it
+		// can be rolled back to
+		current.Revision = max(current.GetRevision(), 1)
+		if err := a.recordRevision(&pb.WorkloadRevision{Id: id, Revision: current.GetRevision(), Spec: current, UnixTime: time.Now().Unix()}); err != nil {
+			return nil, err
+		}
+	}
+
+	spec, err := update(current)
+	if err != nil {
+		return nil, err
+	}
+
+	spec = proto.Clone(spec).(*pb.VmSpawnRequest)
+	// Standalone workloads become the replica group of their
+	// replacements, so their URL keeps working
+	spec.ReplicaGroup = id
+	spec.Revision = max(current.GetRevision(), latestRevision(a.revisions.history(id))) + 1
+
+	if err := validateSpawnRequest(spec); err != nil {
+		return nil, err
+	}
+
+	if spec.GetSpecHash() != "" {
+		if spec.SpecHash, err = specHash(normalizeSpec(spec)); err != nil {
+			return nil, fmt.Errorf("failed to hash spec: %w", err)
+		}
+	}
+
+	return spec, nil
+}
+
+// spawnHealthy spawns a replica of spec and waits for it to be healthy,
+// stopping it if it isn't
+func (a *Agent) spawnHealthy(spec *pb.VmSpawnRequest) (string, error) {
+	spawned, err := a.spawnReplica(proto.Clone(spec).(*pb.VmSpawnRequest))
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.waitHealthy(spawned.GetId()); err != nil {
+		if _, stopErr := a.StopRequest(&pb.VmStopRequest{Id: spawned.GetId(), WorkloadOnly: true}); stopErr != nil {
+			a.logger.WithError(stopErr).Errorf("failed to stop unhealthy replica %s", spawned.GetId())
+		}
+
+		return "", fmt.Errorf("replica %s is unhealthy: %w", spawned.GetId(), err)
+	}
+
+	return spawned.GetId(), nil
+}
+
+// replaceReplica replaces a replica of a workload with a healthy replica
+// of spec, returning the ID of the new replica
+func (a *Agent) replaceReplica(id string, old *pb.WorkloadState, spec *pb.VmSpawnRequest) (string, error) {
+	spawned, err := a.spawnHealthy(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to replace %s, stopping the update: %w", old.GetId(), err)
+	}
+
+	if _, err := a.StopRequest(&pb.VmStopRequest{Id: old.GetId(), WorkloadOnly: true}); err != nil {
+		a.logger.WithError(err).Errorf("failed to stop replaced workload %s", old.GetId())
+	}
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:        pb.ClusterEvent_UPDATE,
+		Id:           spawned,
+		ReplicaGroup: id,
+		Message:      fmt.Sprintf("replaced %s with revision %d", old.GetId(), spec.GetRevision()),
+	})
+
+	return spawned, nil
+}
+
+func latestRevision(history []*pb.WorkloadRevision) uint32 {
+	if len(history) == 0 {
+		return 0
+	}
+
+	return history[len(history)-1].GetRevision()
+}
+
+// waitHealthy waits for a workload to show up in the state broadcasts as
+// ready and accept connections on its ports, it is then registered with the proxy of
+// this node so it serves traffic before the workload it replaces is stopped
+func (a *Agent) waitHealthy(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), UpdateHealthTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(updateHealthPollPeriod)
+	defer ticker.Stop()
+
+	var lastErr error
+
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = fmt.Errorf("workload %s wasn't broadcast", id)
+			}
+
+			return newClusterError(pb.ErrorCode_TIMEOUT, nil, "workload %s not healthy after %s: %s", id, UpdateHealthTimeout, lastErr)
+		case <-ticker.C:
+		}
+
+		workload, member := a.findWorkload(id)
+		if workload == nil {
+			continue
+		}
+
+		if member == nil {
+			lastErr = fmt.Errorf("node running workload %s not found", id)
+
+			continue
+		}
+
+		if !workloadReady(workload) {
+			lastErr = errNotReady
+
+			continue
+		}
+
+		lastErr = nil
+
+		for port := range workload.GetSourceRequest().GetPorts() {
+			addr := net.JoinHostPort(member.Addr.String(), strconv.Itoa(int(port)))
+
+			conn, err := net.DialTimeout("tcp", addr, updateDialTimeout)
+			if err != nil {
+				lastErr = err
+
+				break
+			}
+			conn.Close()
+
+			if err := a.registerService(port, workload, addr, member.Name != a.serf.LocalMember().Name); err != nil {
+				a.logger.WithError(err).Errorf("failed to register workload %s addr %s with proxy", id, addr)
+			}
+		}
+
+		if lastErr == nil {
+			return nil
+		}
+	}
+}
+
+// findWorkload returns the workload with the given ID according to the
+// state broadcasts, along with the member running it
+func (a *Agent) findWorkload(id string) (*pb.WorkloadState, *serf.Member) {
+	for _, state := range a.knownStates() {
+		for _, workload := range state.GetWorkloads() {
+			if workload.GetId() == id {
+				return workload, a.findMember(state.GetNode().GetId())
+			}
+		}
+	}
+
+	return nil, nil
+}
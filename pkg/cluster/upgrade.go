@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// Serf tag advertising when the agent of the node started, in unix
+	// seconds
+	StartedTag = "started"
+
+	// UpgradeRejoinEnv holds the serf address of a node the agent joins
+	// after restarting for an upgrade, when it isn't given one
+	UpgradeRejoinEnv = "HYPERCORE_UPGRADE_REJOIN"
+
+	// The workloads of an upgrading node aren't respawned elsewhere
+	// until its state is this old, in case the new binary doesn't start
+	UpgradeGracePeriod = time.Minute * 5
+
+	// Time given to the response of the upgrade request to reach the
+	// client before the agent restarts
+	upgradeRestartDelay = time.Second
+)
+
+// UpgradeRequest replaces the hypercore binary of the node with the one
+// of the request and restarts the agent in place, forwarding the request
+// to the node if it isn't this one. The workloads are containerd tasks
+// that keep running, the restarted agent picks them up again
+func (a *Agent) UpgradeRequest(ctx context.Context, req *pb.UpgradeRequest) (*pb.UpgradeResponse, error) {
+	if req.GetNode() != "" && req.GetNode() != a.cfg.NodeName {
+		client, closeConn, err := a.nodeClient(req.GetNode())
+		if err != nil {
+			return nil, err
+		}
+		defer closeConn()
+
+		return client.Upgrade(forwardedContext(ctx), req)
+	}
+
+	if req.GetBinaryUrl() == "" || req.GetSha256() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "URL and SHA-256 digest of the new binary are required")
+	}
+
+	if status := a.localNodeStatus(); status != pb.NodeStatus_NODE_READY {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "node is %s, only ready nodes can be upgraded", status)
+	}
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_UPGRADING); err != nil {
+		return nil, err
+	}
+
+	executable, err := installBinary(ctx, req.GetBinaryUrl(), req.GetSha256())
+	if err != nil {
+		if err := a.setNodeStatus(pb.NodeStatus_NODE_READY); err != nil {
+			a.logger.WithError(err).Error("failed to mark node ready after a failed upgrade")
+		}
+
+		return nil, err
+	}
+
+	workloads := a.states.localState().GetWorkloads()
+	ids := make([]string, 0, len(workloads))
+
+	for _, workload := range workloads {
+		ids = append(ids, workload.GetId())
+	}
+
+	go a.restart(executable)
+
+	return &pb.UpgradeResponse{Node: memberNode(a.serf.LocalMember()), Workloads: ids}, nil
+}
+
+// installBinary downloads the binary at url over the executable of the
+// agent once its digest is checked, returning the path of the executable
+func installBinary(ctx context.Context, url, digest string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "invalid binary URL %s: %s", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	// Written next to the executable so the rename replacing it is atomic
+	tmpPath := executable + ".upgrade"
+
+	binary, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpPath)
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(io.MultiWriter(binary, hash), resp.Body); err != nil {
+		binary.Close()
+
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	if err := binary.Close(); err != nil {
+		return "", err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, digest) {
+		return "", newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "binary at %s has digest %s, expected %s", url, sum, digest)
+	}
+
+	if err := os.Rename(tmpPath, executable); err != nil {
+		return "", fmt.Errorf("failed to replace %s: %w", executable, err)
+	}
+
+	return executable, nil
+}
+
+// restart replaces the agent process with the upgraded executable, with
+// the same arguments and PID so service managers don't notice. Without a
+// node to join in its arguments, the new agent rejoins another member
+func (a *Agent) restart(executable string) {
+	time.Sleep(upgradeRestartDelay)
+
+	env := os.Environ()
+
+	for _, member := range a.serf.Members() {
+		if member.Name != a.cfg.NodeName && memberNodeStatus(member) == pb.NodeStatus_NODE_READY {
+			env = append(env, UpgradeRejoinEnv+"="+net.JoinHostPort(member.Addr.String(), strconv.Itoa(int(member.Port))))
+
+			break
+		}
+	}
+
+	a.logger.Infof("Restarting the agent with the upgraded binary %s", executable)
+
+	//nolint:gosec // the executable was just verified against the digest of the request
+	err := syscall.Exec(executable, os.Args, env)
+
+	a.logger.WithError(err).Error("failed to restart the agent with the upgraded binary")
+
+	if err := a.setNodeStatus(pb.NodeStatus_NODE_READY); err != nil {
+		a.logger.WithError(err).Error("failed to mark node ready after a failed upgrade")
+	}
+}
+
+// upgrading returns whether a node advertised it was upgrading when last
+// heard of
+func (a *Agent) upgrading(node string) bool {
+	member := a.findMember(node)
+
+	return member != nil && member.Tags[NodeStatusTag] == nodeStatusTags[pb.NodeStatus_NODE_UPGRADING]
+}
+
+// memberStartedAt returns when the agent of a member started, zero for
+// the members that don't advertise it
+func memberStartedAt(member serf.Member) int64 {
+	started, err := strconv.ParseInt(member.Tags[StartedTag], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return started
+}
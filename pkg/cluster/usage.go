@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/containerd/containerd/errdefs"
+)
+
+// UsageHistoryRetention is how far back the usage of each workload is kept,
+// at UsageMeterPeriod resolution
+const UsageHistoryRetention = time.Hour
+
+// usageSeries is the usage history of a workload, along with the counters
+// of the last sample the next point is computed against
+type usageSeries struct {
+	points    []*pb.UsagePoint
+	cpuUsage  uint64
+	net       netCounters
+	sampledAt time.Time
+}
+
+// usageHistory keeps the recent usage of the local workloads in memory
+type usageHistory struct {
+	mu     sync.Mutex
+	series map[string]*usageSeries
+}
+
+func newUsageHistory() *usageHistory {
+	return &usageHistory{series: make(map[string]*usageSeries)}
+}
+
+// record adds a point computed from the counters of a workload, the first
+// sample only sets the counters
+func (h *usageHistory) record(id string, cpuUsage, memUsage uint64, net netCounters) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+
+	series, ok := h.series[id]
+	if !ok {
+		h.series[id] = &usageSeries{cpuUsage: cpuUsage, net: net, sampledAt: now}
+
+		return
+	}
+
+	elapsed := now.Sub(series.sampledAt).Seconds()
+
+	// Counters reset when the workload is restarted in place
+	delta := func(current, previous uint64) float64 {
+		if current < previous {
+			return float64(current)
+		}
+
+		return float64(current - previous)
+	}
+
+	series.points = append(series.points, &pb.UsagePoint{
+		UnixTime:         now.Unix(),
+		Cpus:             delta(cpuUsage, series.cpuUsage) / float64(time.Second) / elapsed,
+		MemoryBytes:      memUsage,
+		RxBytesPerSecond: delta(net.rx, series.net.rx) / elapsed,
+		TxBytesPerSecond: delta(net.tx, series.net.tx) / elapsed,
+	})
+
+	if maxPoints := int(UsageHistoryRetention / UsageMeterPeriod); len(series.points) > maxPoints {
+		series.points = series.points[len(series.points)-maxPoints:]
+	}
+
+	series.cpuUsage = cpuUsage
+	series.net = net
+	series.sampledAt = now
+}
+
+// forget drops the history of the workloads that aren't running anymore
+func (h *usageHistory) forget(running map[string]struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id := range h.series {
+		if _, ok := running[id]; !ok {
+			delete(h.series, id)
+		}
+	}
+}
+
+func (h *usageHistory) points(id string) []*pb.UsagePoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if series, ok := h.series[id]; ok {
+		return append([]*pb.UsagePoint(nil), series.points...)
+	}
+
+	return nil
+}
+
+// UsageRequest returns the usage history of a workload, forwarding the
+// request to the node running it
+func (a *Agent) UsageRequest(ctx context.Context, req *pb.UsageRequest) (*pb.UsageResponse, error) {
+	if req.GetId() == "" {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, nil, "ID of the workload is required")
+	}
+
+	ctrCtx := a.ctrRepo.GetContext(ctx)
+
+	_, err := a.ctrRepo.GetContainer(ctrCtx, req.GetId())
+	if err == nil {
+		return &pb.UsageResponse{
+			Id:            req.GetId(),
+			Node:          a.cfg.NodeName,
+			PeriodSeconds: uint32(UsageMeterPeriod.Seconds()),
+			Points:        a.usage.points(req.GetId()),
+		}, nil
+	}
+
+	if !errdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get container %s: %w", req.GetId(), err)
+	}
+
+	nodeName := a.workloadNode(req.GetId())
+	if nodeName == "" {
+		return nil, newClusterError(pb.ErrorCode_NOT_FOUND, nil, "no workload found for %s", req.GetId())
+	}
+
+	member := a.findMember(nodeName)
+	if member == nil || member.Tags[GrpcPortTag] == "" {
+		return nil, fmt.Errorf("node %s running workload %s is not reachable", nodeName, req.GetId())
+	}
+
+	conn, err := a.dialNode(member)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return pb.NewClusterServiceClient(conn).Usage(forwardedContext(ctx), req)
+}
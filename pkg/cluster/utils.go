@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"errors"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+
+	"vistara-node/pkg/cgroup"
+	pb "vistara-node/pkg/proto/cluster"
 )
 
 // Returns available memory (in kB)
@@ -28,3 +32,39 @@ func getAvailableMem() (uint64, error) {
 
 	return 0, errors.New("could not find MemAvailable section")
 }
+
+// workloadUsage returns the cumulative CPU usage (in ns) and the current
+// memory usage (in bytes) of a workload, that of its hypervisor for VMs,
+// whose task runs in the guest
+func workloadUsage(id string, pid uint32) (uint64, uint64, error) {
+	if cpuUsage, memUsage, err := cgroup.GroupUsage(cgroup.HypervisorGroup(id)); err == nil {
+		return cpuUsage, memUsage, nil
+	}
+
+	return cgroup.Usage(pid)
+}
+
+func ioLimits(limits *pb.IOLimits) *cgroup.IOLimits {
+	if limits == nil {
+		return nil
+	}
+
+	return &cgroup.IOLimits{
+		ReadBPS:   limits.GetReadBytesPerSecond(),
+		WriteBPS:  limits.GetWriteBytesPerSecond(),
+		ReadIOPS:  limits.GetReadIops(),
+		WriteIOPS: limits.GetWriteIops(),
+	}
+}
+
+// workloadEnv converts the environment of a spawn request to KEY=value
+// entries, sorted so the container spec is stable
+func workloadEnv(env map[string]string) []string {
+	entries := make([]string, 0, len(env))
+	for key, value := range env {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+
+	return entries
+}
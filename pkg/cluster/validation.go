@@ -0,0 +1,298 @@
+package cluster
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"vistara-node/pkg/models"
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/resource"
+	"vistara-node/pkg/snapshot"
+
+	"github.com/distribution/reference"
+)
+
+// Bounds of the resources of a workload, in vCPUs and MB. Zero cores or
+// memory leave the workload unlimited
+const (
+	MaxWorkloadCores  = 128
+	MinWorkloadMemory = 64
+	MaxWorkloadMemory = 1024 * 1024
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fieldViolations are the invalid fields of a request, along with why
+// they are invalid
+type fieldViolations map[string]string
+
+func (v fieldViolations) add(field, format string, args ...interface{}) {
+	if _, ok := v[field]; !ok {
+		v[field] = fmt.Sprintf(format, args...)
+	}
+}
+
+// err returns an INVALID_REQUEST error listing all the violations, with
+// the fields as details, nil if there are none
+func (v fieldViolations) err(request string) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(v))
+	for _, field := range slices.Sorted(maps.Keys(v)) {
+		messages = append(messages, field+": "+v[field])
+	}
+
+	return newClusterError(pb.ErrorCode_INVALID_REQUEST, v, "invalid %s: %s", request, strings.Join(messages, "; "))
+}
+
+// validateSpawnRequest checks the request fields that don't depend on
+// the node it is spawned on, reporting all the invalid ones at once
+func validateSpawnRequest(req *pb.VmSpawnRequest) error {
+	violations := make(fieldViolations)
+
+	if req.GetImageRef() == "" {
+		violations.add("image_ref", "image reference is required")
+	} else if _, err := reference.ParseDockerRef(req.GetImageRef()); err != nil {
+		violations.add("image_ref", "invalid image reference %q: %v", req.GetImageRef(), err)
+	}
+
+	if req.GetName() != "" && !workloadNameRegexp.MatchString(req.GetName()) {
+		violations.add("name", "invalid workload name %q, must be a lowercase DNS label", req.GetName())
+	}
+
+	validateResources(violations, "cores", "memory", req.GetCores(), req.GetMemory())
+
+	if req.GetMemoryHigh() != 0 && req.GetMemory() != 0 && req.GetMemoryHigh() >= req.GetMemory() {
+		violations.add("memory_high", "memory high %s isn't below memory %s", megabytes(req.GetMemoryHigh()), megabytes(req.GetMemory()))
+	}
+
+	if policy := req.GetVerticalScaling(); policy != nil {
+		validateResources(violations, "vertical_scaling.min_cores", "vertical_scaling.min_memory", policy.GetMinCores(), policy.GetMinMemory())
+		validateResources(violations, "vertical_scaling.max_cores", "vertical_scaling.max_memory", policy.GetMaxCores(), policy.GetMaxMemory())
+
+		if policy.GetMaxCores() < policy.GetMinCores() {
+			violations.add("vertical_scaling.max_cores", "max cores %d is lower than min cores %d", policy.GetMaxCores(), policy.GetMinCores())
+		}
+
+		if policy.GetMaxMemory() < policy.GetMinMemory() {
+			violations.add("vertical_scaling.max_memory", "max memory %s is lower than min memory %s",
+				megabytes(policy.GetMaxMemory()), megabytes(policy.GetMinMemory()))
+		}
+
+		// The autoscaler steps from the allocation of the workload, which
+		// must be within the bounds it steps between
+		if policy.GetMaxCores() == policy.GetMinCores() && policy.GetMaxMemory() == policy.GetMinMemory() {
+			violations.add("vertical_scaling", "min and max are equal, the workload can't be resized")
+		}
+
+		if policy.GetMaxCores() > policy.GetMinCores() {
+			if policy.GetMinCores() == 0 {
+				violations.add("vertical_scaling.min_cores", "min cores must be at least 1 when cores are scaled")
+			}
+
+			if req.GetCores() < policy.GetMinCores() || req.GetCores() > policy.GetMaxCores() {
+				violations.add("cores", "%d cores isn't within the vertical scaling bounds %d-%d", req.GetCores(), policy.GetMinCores(), policy.GetMaxCores())
+			}
+		}
+
+		if policy.GetMaxMemory() > policy.GetMinMemory() {
+			if policy.GetMinMemory() == 0 {
+				violations.add("vertical_scaling.min_memory", "min memory is required when memory is scaled")
+			}
+
+			if req.GetMemory() < policy.GetMinMemory() || req.GetMemory() > policy.GetMaxMemory() {
+				violations.add("memory", "memory %s isn't within the vertical scaling bounds %s-%s",
+					megabytes(req.GetMemory()), megabytes(policy.GetMinMemory()), megabytes(policy.GetMaxMemory()))
+			}
+		}
+	}
+
+	for hostPort, containerPort := range req.GetPorts() {
+		field := fmt.Sprintf("ports[%d]", hostPort)
+
+		if hostPort == 0 || hostPort > 0xffff {
+			violations.add(field, "got invalid host port %d", hostPort)
+		} else if containerPort == 0 || containerPort > 0xffff {
+			violations.add(field, "got invalid container port %d", containerPort)
+		}
+	}
+
+	if policy := req.GetHorizontalScaling(); policy != nil && policy.GetMaxReplicas() < policy.GetMinReplicas() {
+		violations.add("horizontal_scaling.max_replicas", "max replicas %d is lower than min replicas %d", policy.GetMaxReplicas(), policy.GetMinReplicas())
+	}
+
+	validateEnv(violations, "env", req.GetEnv())
+
+	for i, step := range req.GetInitSteps() {
+		field := fmt.Sprintf("init_steps[%d]", i)
+
+		if step.GetImageRef() != "" {
+			if _, err := reference.ParseDockerRef(step.GetImageRef()); err != nil {
+				violations.add(field+".image_ref", "invalid image reference %q: %v", step.GetImageRef(), err)
+			}
+		}
+
+		validateEnv(violations, field+".env", step.GetEnv())
+	}
+
+	names := make(map[string]struct{})
+
+	for i, container := range req.GetContainers() {
+		field := fmt.Sprintf("containers[%d]", i)
+
+		if !workloadNameRegexp.MatchString(container.GetName()) {
+			violations.add(field+".name", "invalid container name %q, must be a lowercase DNS label", container.GetName())
+		} else if _, ok := names[container.GetName()]; ok {
+			violations.add(field+".name", "duplicate container name %q", container.GetName())
+		}
+
+		names[container.GetName()] = struct{}{}
+
+		if container.GetImageRef() != "" {
+			if _, err := reference.ParseDockerRef(container.GetImageRef()); err != nil {
+				violations.add(field+".image_ref", "invalid image reference %q: %v", container.GetImageRef(), err)
+			}
+		}
+
+		validateEnv(violations, field+".env", container.GetEnv())
+	}
+
+	if err := validateProbe("readiness", req.GetReadinessProbe()); err != nil {
+		violations.add("readiness_probe", "%v", err)
+	}
+
+	if err := validateProbe("liveness", req.GetLivenessProbe()); err != nil {
+		violations.add("liveness_probe", "%v", err)
+	}
+
+	if err := validateIngressRules(req.GetIngressRules()); err != nil {
+		violations.add("ingress_rules", "%v", err)
+	}
+
+	if metrics := req.GetMetricsEndpoint(); metrics != nil {
+		if metrics.GetPort() == 0 || metrics.GetPort() > 0xffff {
+			violations.add("metrics_endpoint.port", "got invalid metrics port %d", metrics.GetPort())
+		}
+
+		if metrics.GetPath() != "" && !strings.HasPrefix(metrics.GetPath(), "/") {
+			violations.add("metrics_endpoint.path", "metrics path %q must start with /", metrics.GetPath())
+		}
+	}
+
+	if dns := req.GetDns(); dns != nil {
+		if err := dnsConfig(dns).Validate(); err != nil {
+			violations.add("dns", "%v", err)
+		}
+	}
+
+	if err := models.ValidateHostEntries(extraHosts(req)); err != nil {
+		violations.add("extra_hosts", "%v", err)
+	}
+
+	validateVolumes(violations, req.GetVolumes())
+
+	if ttl := time.Duration(req.GetPendingTtlSeconds()) * time.Second; ttl > MaxPendingTTL {
+		violations.add("pending_ttl_seconds", "pending TTL %s is more than the maximum of %s", ttl, MaxPendingTTL)
+	}
+
+	for key := range req.GetLabels() {
+		if !labelKeyRegexp.MatchString(key) {
+			violations.add(fmt.Sprintf("labels[%s]", key), "invalid label key %q", key)
+		}
+	}
+
+	annotationsSize := 0
+
+	for key, value := range req.GetAnnotations() {
+		if !labelKeyRegexp.MatchString(key) {
+			violations.add(fmt.Sprintf("annotations[%s]", key), "invalid annotation key %q", key)
+		}
+
+		annotationsSize += len(key) + len(value)
+	}
+
+	if annotationsSize > MaxAnnotationsSize {
+		violations.add("annotations", "annotations of %d bytes, at most %d are supported", annotationsSize, MaxAnnotationsSize)
+	}
+
+	return violations.err("spawn request")
+}
+
+// validateVolumes checks the names and mount paths of the volumes of a
+// workload
+func validateVolumes(violations fieldViolations, volumes []*pb.VolumeMount) {
+	if len(volumes) > MaxWorkloadVolumes {
+		violations.add("volumes", "%d volumes, at most %d are supported", len(volumes), MaxWorkloadVolumes)
+	}
+
+	names := make(map[string]struct{})
+	paths := make(map[string]struct{})
+
+	for i, volume := range volumes {
+		field := fmt.Sprintf("volumes[%d]", i)
+
+		if !workloadNameRegexp.MatchString(volume.GetName()) {
+			violations.add(field+".name", "invalid volume name %q, must be a lowercase DNS label", volume.GetName())
+		} else if _, ok := names[volume.GetName()]; ok {
+			violations.add(field+".name", "duplicate volume %q", volume.GetName())
+		}
+
+		names[volume.GetName()] = struct{}{}
+
+		mountPath := path.Clean(volume.GetMountPath())
+		if !path.IsAbs(volume.GetMountPath()) || mountPath == "/" {
+			violations.add(field+".mount_path", "mount path %q must be an absolute path other than /", volume.GetMountPath())
+		} else if _, ok := paths[mountPath]; ok {
+			violations.add(field+".mount_path", "mount path %q is used by another volume", volume.GetMountPath())
+		}
+
+		paths[mountPath] = struct{}{}
+
+		if volume.GetFromSnapshot() != "" {
+			if _, _, err := snapshot.ParseRef(volume.GetFromSnapshot()); err != nil {
+				violations.add(field+".from_snapshot", "%v", err)
+			}
+		}
+	}
+}
+
+// validateEnv checks the names and values of environment variables,
+// reported as field[NAME]
+func validateEnv(violations fieldViolations, field string, env map[string]string) {
+	for name, value := range env {
+		key := fmt.Sprintf("%s[%s]", field, name)
+
+		if !envNameRegexp.MatchString(name) {
+			violations.add(key, "invalid environment variable name %q", name)
+		} else if strings.ContainsRune(value, 0) {
+			violations.add(key, "environment variable value can't contain NUL bytes")
+		} else if _, err := parseEnvTemplate(name, value); err != nil {
+			violations.add(key, "invalid template: %v", err)
+		}
+	}
+}
+
+// validateResources checks that cores and memory, if set, are within the
+// bounds of a workload
+func validateResources(violations fieldViolations, coresField, memoryField string, cores, memory uint32) {
+	if cores > MaxWorkloadCores {
+		violations.add(coresField, "%d cores is more than the maximum of %d", cores, MaxWorkloadCores)
+	}
+
+	if memory != 0 && memory < MinWorkloadMemory {
+		violations.add(memoryField, "memory %s is below the minimum of %s", megabytes(memory), megabytes(MinWorkloadMemory))
+	} else if memory > MaxWorkloadMemory {
+		violations.add(memoryField, "memory %s is more than the maximum of %s", megabytes(memory), megabytes(MaxWorkloadMemory))
+	}
+}
+
+func megabytes(mb uint32) resource.Bytes {
+	return resource.Bytes(mb) * resource.MiB
+}
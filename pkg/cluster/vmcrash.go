@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/proto/shimdebug"
+)
+
+// monitorVMCrashedEvents tracks the failures of the guest kernels of the
+// local workloads, so their exits aren't taken for the workload's
+func (a *Agent) monitorVMCrashedEvents() {
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		crashCh, errCh := a.ctrRepo.SubscribeVMCrashedEvents(ctx)
+
+		for crashed := range crashCh {
+			a.recordVMCrash(ctx, crashed)
+		}
+
+		if err := <-errCh; err != nil {
+			a.logger.WithError(err).Error("VM crashed event subscription failed, resubscribing")
+		}
+
+		cancel()
+		time.Sleep(a.broadcastPeriod)
+	}
+}
+
+func (a *Agent) recordVMCrash(ctx context.Context, crashed *shimdebug.VMCrashed) {
+	container, err := a.ctrRepo.GetContainer(ctx, crashed.GetContainerId())
+	if err != nil {
+		a.logger.WithError(err).Errorf("failed to get crashed container %s", crashed.GetContainerId())
+
+		return
+	}
+
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		a.logger.WithError(err).Errorf("failed to get labels for container %s", crashed.GetContainerId())
+
+		return
+	}
+
+	// Not a workload managed by the cluster
+	if _, ok := labels[SpawnRequestLabel]; !ok {
+		return
+	}
+
+	a.vmFailureMu.Lock()
+	// A kernel panic following an OOM kill is what brought the VM down
+	if previous := a.vmFailures[crashed.GetContainerId()]; previous.GetReason() != shimdebug.VMFailureReason_KERNEL_PANIC {
+		a.vmFailures[crashed.GetContainerId()] = crashed
+	}
+	a.vmFailureMu.Unlock()
+
+	a.logger.Warnf("VM of container %s failed: %s", crashed.GetContainerId(), crashed.GetMessage())
+
+	a.publishEvent(&pb.WatchEventsResponse{
+		Event:   pb.ClusterEvent_VM_CRASHED,
+		Node:    &pb.Node{Id: a.cfg.NodeName},
+		Id:      crashed.GetContainerId(),
+		Message: fmt.Sprintf("%s: %s", crashed.GetReason(), crashed.GetMessage()),
+	})
+}
+
+// takeVMFailure returns the failure of the VM of a stopped workload, if its
+// exit was caused by one, and forgets it
+func (a *Agent) takeVMFailure(id string) *shimdebug.VMCrashed {
+	a.vmFailureMu.Lock()
+	defer a.vmFailureMu.Unlock()
+
+	failure := a.vmFailures[id]
+	delete(a.vmFailures, id)
+
+	return failure
+}
@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/hashicorp/serf/serf"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// Directory of the data directory holding the persistent volumes of
+	// the node, one directory per volume
+	volumesDirName = "volumes"
+	// File of a volume directory holding the tenant the volume belongs
+	// to, next to the data directory mounted into the workloads
+	volumeTenantFile = "tenant"
+	volumeDataDir    = "data"
+
+	// Persistent volumes a workload can mount
+	MaxWorkloadVolumes = 16
+)
+
+// volumeLocation is the node a volume was last reported on
+type volumeLocation struct {
+	node   string
+	tenant string
+}
+
+// volumeLocations tracks the node holding each persistent volume from
+// the state broadcasts. Volumes of the nodes that stopped broadcasting are
+// remembered, so spawns mounting them fail rather than create them again
+// elsewhere
+type volumeLocations struct {
+	mu        sync.Mutex
+	locations map[string]volumeLocation
+}
+
+func newVolumeLocations() *volumeLocations {
+	return &volumeLocations{locations: make(map[string]volumeLocation)}
+}
+
+// observe records the volumes a node holds, forgetting those it no
+// longer does
+func (l *volumeLocations) observe(node string, volumes []*pb.Volume) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name, location := range l.locations {
+		if location.node == node {
+			delete(l.locations, name)
+		}
+	}
+
+	for _, volume := range volumes {
+		l.locations[volume.GetName()] = volumeLocation{node: node, tenant: volume.GetTenant()}
+	}
+}
+
+// locate returns the node holding a volume, false if no node reported it
+func (l *volumeLocations) locate(name string) (volumeLocation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	location, ok := l.locations[name]
+
+	return location, ok
+}
+
+// localVolumes lists the volumes held by this node
+func localVolumes(dir string) ([]*pb.Volume, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var volumes []*pb.Volume
+
+	for _, entry := range entries {
+		if !entry.IsDir() || restoringVolume(entry.Name()) {
+			continue
+		}
+
+		tenant, err := os.ReadFile(filepath.Join(dir, entry.Name(), volumeTenantFile))
+		if err != nil {
+			continue
+		}
+
+		volumes = append(volumes, &pb.Volume{Name: entry.Name(), Tenant: string(tenant)})
+	}
+
+	return volumes, nil
+}
+
+// volumeMounts creates the volumes of the request that don't exist on
+// this node yet, restoring those with a snapshot to start from, returning
+// the bind mounts of all of them
+func (a *Agent) volumeMounts(ctx context.Context, req *pb.VmSpawnRequest) ([]specs.Mount, error) {
+	mounts := make([]specs.Mount, 0, len(req.GetVolumes()))
+
+	for _, volume := range req.GetVolumes() {
+		dir := filepath.Join(a.volumeDir, volume.GetName())
+
+		tenant, err := os.ReadFile(filepath.Join(dir, volumeTenantFile))
+		switch {
+		case errors.Is(err, os.ErrNotExist) && volume.GetFromSnapshot() != "":
+			if err := a.restoreVolume(ctx, volume, req.GetTenant()); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, os.ErrNotExist):
+			if err := os.MkdirAll(filepath.Join(dir, volumeDataDir), 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create volume %s: %w", volume.GetName(), err)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, volumeTenantFile), []byte(req.GetTenant()), 0o644); err != nil {
+				return nil, fmt.Errorf("failed to create volume %s: %w", volume.GetName(), err)
+			}
+
+			a.logger.Infof("Created volume %s for tenant %q", volume.GetName(), req.GetTenant())
+		case err != nil:
+			return nil, fmt.Errorf("failed to read volume %s: %w", volume.GetName(), err)
+		case string(tenant) != req.GetTenant():
+			return nil, newClusterError(pb.ErrorCode_POLICY_DENIED, map[string]string{"volume": volume.GetName()},
+				"volume %s belongs to another tenant", volume.GetName())
+		}
+
+		options := []string{"rbind", "rw"}
+		if volume.GetReadOnly() {
+			options = []string{"rbind", "ro"}
+		}
+
+		mounts = append(mounts, specs.Mount{
+			Destination: volume.GetMountPath(),
+			Type:        "bind",
+			Source:      filepath.Join(dir, volumeDataDir),
+			Options:     options,
+		})
+	}
+
+	return mounts, nil
+}
+
+// volumeNode returns the node the request must be placed on to mount its
+// volumes, empty if none of them exists yet. It fails if the volumes are
+// on different nodes, belong to another tenant or if their node is down
+func (a *Agent) volumeNode(req *pb.VmSpawnRequest) (string, error) {
+	var owner, ownerVolume string
+
+	for _, volume := range req.GetVolumes() {
+		location, ok := a.volumes.locate(volume.GetName())
+		if !ok {
+			continue
+		}
+
+		details := map[string]string{"volume": volume.GetName(), "node": location.node}
+
+		if location.tenant != req.GetTenant() {
+			return "", newClusterError(pb.ErrorCode_POLICY_DENIED, details, "volume %s belongs to another tenant", volume.GetName())
+		}
+
+		if owner != "" && location.node != owner {
+			return "", newClusterError(pb.ErrorCode_INVALID_REQUEST, details,
+				"volumes %s and %s are on different nodes, %s and %s", ownerVolume, volume.GetName(), owner, location.node)
+		}
+
+		owner, ownerVolume = location.node, volume.GetName()
+	}
+
+	if owner == "" {
+		return "", nil
+	}
+
+	details := map[string]string{"volume": ownerVolume, "node": owner}
+
+	if owner == a.cfg.NodeName {
+		return "", newClusterError(pb.ErrorCode_VOLUME_UNAVAILABLE, details,
+			"volume %s is on node %s, which received the request, send it to another node to place the workload there", ownerVolume, owner)
+	}
+
+	if !a.nodeUp(owner) {
+		return "", newClusterError(pb.ErrorCode_VOLUME_UNAVAILABLE, details,
+			"volume %s is on node %s, which is down, the workload can't be placed until it is back", ownerVolume, owner)
+	}
+
+	return owner, nil
+}
+
+// nodeUp returns whether a node is alive and broadcasting its state
+func (a *Agent) nodeUp(node string) bool {
+	member := a.findMember(node)
+	if member == nil || member.Status != serf.StatusAlive {
+		return false
+	}
+
+	for _, state := range a.knownStates() {
+		if state.GetNode().GetId() == node {
+			return true
+		}
+	}
+
+	return false
+}
+
+// volumeNodes restricts the nodes that answered the dry run of a request
+// to the node holding its volumes, if they exist
+func (a *Agent) volumeNodes(nodes []string, req *pb.VmSpawnRequest) ([]string, error) {
+	owner, err := a.volumeNode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner == "" {
+		return nodes, nil
+	}
+
+	for _, node := range nodes {
+		if node == owner {
+			return []string{owner}, nil
+		}
+	}
+
+	return nil, newClusterError(pb.ErrorCode_CAPACITY_EXCEEDED, map[string]string{"node": owner},
+		"node %s holding the volumes of the workload isn't ready or has no capacity for it", owner)
+}
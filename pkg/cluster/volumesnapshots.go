@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+	"vistara-node/pkg/snapshot"
+)
+
+// Suffix of the directories volumes are restored into before being moved
+// into place, hidden from localVolumes
+const volumeRestoringSuffix = ".restoring"
+
+// VolumeSnapshotConfig is the object store the persistent volumes of the
+// node are snapshotted to
+type VolumeSnapshotConfig struct {
+	Store *snapshot.Store
+	// Period of the snapshots of all the volumes of the node, 0 to only
+	// snapshot them on request
+	Interval time.Duration
+	// Snapshots kept per volume, applied after each periodic snapshot
+	Retention snapshot.Retention
+}
+
+// volumeSnapshotter takes the snapshots of the volumes of this node one
+// at a time, so each one only uploads the blocks changed since the
+// previous one
+type volumeSnapshotter struct {
+	cfg *VolumeSnapshotConfig
+	mu  sync.Mutex
+}
+
+func volumeSnapshot(manifest *snapshot.Manifest) *pb.VolumeSnapshot {
+	return &pb.VolumeSnapshot{
+		Ref:             manifest.Ref(),
+		Volume:          manifest.Name,
+		Tenant:          manifest.Tenant,
+		CreatedUnixTime: manifest.Created.Unix(),
+		SizeBytes:       manifest.Size,
+		UploadedBytes:   manifest.Uploaded,
+	}
+}
+
+func (a *Agent) snapshotsEnabled() error {
+	if a.volumeSnapshots == nil {
+		return newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"node": a.cfg.NodeName},
+			"node %s has no snapshot store configured", a.cfg.NodeName)
+	}
+
+	return nil
+}
+
+// SnapshotVolumeRequest snapshots a volume of this node, forwarding the
+// request to the node holding it if it isn't this one
+func (a *Agent) SnapshotVolumeRequest(ctx context.Context, req *pb.SnapshotVolumeRequest) (*pb.VolumeSnapshot, error) {
+	details := map[string]string{"volume": req.GetVolume()}
+
+	if !workloadNameRegexp.MatchString(req.GetVolume()) {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, details, "invalid volume name %q", req.GetVolume())
+	}
+
+	tenant, err := os.ReadFile(filepath.Join(a.volumeDir, req.GetVolume(), volumeTenantFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		location, ok := a.volumes.locate(req.GetVolume())
+		if !ok || location.node == a.cfg.NodeName {
+			return nil, newClusterError(pb.ErrorCode_NOT_FOUND, details, "no volume %s", req.GetVolume())
+		}
+
+		if location.tenant != req.GetTenant() {
+			return nil, newClusterError(pb.ErrorCode_POLICY_DENIED, details, "volume %s belongs to another tenant", req.GetVolume())
+		}
+
+		if !a.nodeUp(location.node) {
+			details["node"] = location.node
+
+			return nil, newClusterError(pb.ErrorCode_VOLUME_UNAVAILABLE, details,
+				"volume %s is on node %s, which is down", req.GetVolume(), location.node)
+		}
+
+		client, closeConn, err := a.nodeClient(location.node)
+		if err != nil {
+			return nil, err
+		}
+		defer closeConn()
+
+		return client.SnapshotVolume(forwardedContext(ctx), req)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read volume %s: %w", req.GetVolume(), err)
+	case string(tenant) != req.GetTenant():
+		return nil, newClusterError(pb.ErrorCode_POLICY_DENIED, details, "volume %s belongs to another tenant", req.GetVolume())
+	}
+
+	if err := a.snapshotsEnabled(); err != nil {
+		return nil, err
+	}
+
+	return a.snapshotVolume(ctx, req.GetVolume(), string(tenant))
+}
+
+func (a *Agent) snapshotVolume(ctx context.Context, name, tenant string) (*pb.VolumeSnapshot, error) {
+	a.volumeSnapshots.mu.Lock()
+	defer a.volumeSnapshots.mu.Unlock()
+
+	start := time.Now()
+
+	manifest, err := a.volumeSnapshots.cfg.Store.Create(ctx, name, tenant, filepath.Join(a.volumeDir, name, volumeDataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot volume %s: %w", name, err)
+	}
+
+	a.logger.Infof("Snapshotted volume %s as %s in %s, uploaded %d of %d bytes",
+		name, manifest.Ref(), time.Since(start).Round(time.Millisecond), manifest.Uploaded, manifest.Size)
+
+	resp := volumeSnapshot(manifest)
+	resp.Node = a.cfg.NodeName
+
+	return resp, nil
+}
+
+// ListSnapshotsRequest lists the snapshots of the store of this node
+func (a *Agent) ListSnapshotsRequest(ctx context.Context, req *pb.ListSnapshotsRequest) (*pb.ListSnapshotsResponse, error) {
+	if err := a.snapshotsEnabled(); err != nil {
+		return nil, err
+	}
+
+	if req.GetVolume() != "" && !workloadNameRegexp.MatchString(req.GetVolume()) {
+		return nil, newClusterError(pb.ErrorCode_INVALID_REQUEST, map[string]string{"volume": req.GetVolume()},
+			"invalid volume name %q", req.GetVolume())
+	}
+
+	manifests, err := a.volumeSnapshots.cfg.Store.List(ctx, req.GetVolume())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	resp := &pb.ListSnapshotsResponse{}
+
+	for _, manifest := range manifests {
+		if req.GetTenant() != "" && manifest.Tenant != req.GetTenant() {
+			continue
+		}
+
+		resp.Snapshots = append(resp.Snapshots, volumeSnapshot(manifest))
+	}
+
+	return resp, nil
+}
+
+// snapshotVolumes periodically snapshots the volumes of this node, then
+// prunes the snapshots of each one past the retention
+func (a *Agent) snapshotVolumes() {
+	ticker := time.NewTicker(a.volumeSnapshots.cfg.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		volumes, err := localVolumes(a.volumeDir)
+		if err != nil {
+			a.logger.WithError(err).Error("failed to list the volumes to snapshot")
+
+			continue
+		}
+
+		for _, volume := range volumes {
+			ctx := context.Background()
+
+			if _, err := a.snapshotVolume(ctx, volume.GetName(), volume.GetTenant()); err != nil {
+				a.logger.WithError(err).Error("periodic volume snapshot failed")
+
+				continue
+			}
+
+			pruned, err := a.volumeSnapshots.cfg.Store.Prune(ctx, volume.GetName(), a.volumeSnapshots.cfg.Retention, time.Now())
+			if err != nil {
+				a.logger.WithError(err).Errorf("failed to prune the snapshots of volume %s", volume.GetName())
+			}
+
+			for _, manifest := range pruned {
+				a.logger.Infof("Pruned snapshot %s", manifest.Ref())
+			}
+		}
+	}
+}
+
+// restoreVolume creates a volume of this node from a snapshot, the volume
+// only showing up once all its files are restored
+func (a *Agent) restoreVolume(ctx context.Context, volume *pb.VolumeMount, tenant string) error {
+	details := map[string]string{"volume": volume.GetName(), "snapshot": volume.GetFromSnapshot()}
+
+	if err := a.snapshotsEnabled(); err != nil {
+		return err
+	}
+
+	manifest, err := a.volumeSnapshots.cfg.Store.Find(ctx, volume.GetFromSnapshot())
+	if errors.Is(err, snapshot.ErrNotFound) {
+		return newClusterError(pb.ErrorCode_NOT_FOUND, details, "no snapshot %s", volume.GetFromSnapshot())
+	} else if err != nil {
+		return fmt.Errorf("failed to find snapshot %s: %w", volume.GetFromSnapshot(), err)
+	}
+
+	if manifest.Tenant != tenant {
+		return newClusterError(pb.ErrorCode_POLICY_DENIED, details, "snapshot %s belongs to another tenant", manifest.Ref())
+	}
+
+	tmp := filepath.Join(a.volumeDir, "."+volume.GetName()+volumeRestoringSuffix)
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	if err := a.volumeSnapshots.cfg.Store.Restore(ctx, manifest, filepath.Join(tmp, volumeDataDir)); err != nil {
+		_ = os.RemoveAll(tmp)
+
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(tmp, volumeTenantFile), []byte(tenant), 0o644); err != nil {
+		_ = os.RemoveAll(tmp)
+
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(a.volumeDir, volume.GetName())); err != nil {
+		_ = os.RemoveAll(tmp)
+
+		return err
+	}
+
+	a.logger.Infof("Restored volume %s from snapshot %s in %s", volume.GetName(), manifest.Ref(), time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+// restoringVolume returns whether a directory of the volume directory is
+// a volume being restored
+func restoringVolume(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, volumeRestoringSuffix)
+}
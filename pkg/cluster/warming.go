@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	"github.com/distribution/reference"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	WarmImageEvent = "hypercore_warm_image"
+
+	// The image of a class is pulled ahead on the next best nodes once
+	// this many replicas of it were placed within WarmImageWindow
+	WarmImagePlacements = 3
+	WarmImageWindow     = time.Hour
+	// Number of next best nodes the image is pulled ahead on, the ones
+	// failed over replicas are most likely respawned on
+	WarmImageNodes = 2
+	// How long a node has to pull an image ahead
+	WarmImageTimeout = time.Minute * 10
+	// Images a node keeps the cache status of, the oldest are forgotten
+	MaxImageCacheEntries = 64
+)
+
+// imageClass counts the recent placements of the replicas of an image
+// repository, whatever their tag, the ones of the latest image counting
+// towards warming it
+type imageClass struct {
+	imageRef   string
+	placements []time.Time
+	// when each node was last asked to pull the image ahead
+	warmed map[string]time.Time
+}
+
+// placementTracker records the placements of this node's spawn requests
+// to pull the images placed repeatedly ahead on the next best nodes
+type placementTracker struct {
+	mu      sync.Mutex
+	classes map[string]*imageClass
+}
+
+func newPlacementTracker() *placementTracker {
+	return &placementTracker{classes: make(map[string]*imageClass)}
+}
+
+// imageClassOf returns the normalized repository of an image, e.g.
+// docker.io/library/nginx for nginx:latest
+func imageClassOf(imageRef string) (string, error) {
+	named, err := reference.ParseDockerRef(imageRef)
+	if err != nil {
+		return "", err
+	}
+
+	return named.Name(), nil
+}
+
+// record counts a placement of the image on a node, returning the nodes
+// among the next best ones, in order, to pull it ahead on. Nodes asked
+// to within WarmImageWindow aren't asked again
+func (t *placementTracker) record(imageRef string, nextBest []string, now time.Time) []string {
+	class, err := imageClassOf(imageRef)
+	if err != nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, c := range t.classes {
+		c.placements = slices.DeleteFunc(c.placements, func(placed time.Time) bool {
+			return now.Sub(placed) > WarmImageWindow
+		})
+
+		if len(c.placements) == 0 && name != class {
+			delete(t.classes, name)
+		}
+	}
+
+	c, ok := t.classes[class]
+	if !ok {
+		c = &imageClass{warmed: make(map[string]time.Time)}
+		t.classes[class] = c
+	}
+
+	if c.imageRef != imageRef {
+		c.imageRef = imageRef
+		clear(c.warmed)
+	}
+
+	c.placements = append(c.placements, now)
+	if len(c.placements) < WarmImagePlacements {
+		return nil
+	}
+
+	var nodes []string
+
+	for _, node := range nextBest {
+		if len(nodes) == WarmImageNodes {
+			break
+		}
+
+		if asked, ok := c.warmed[node]; ok && now.Sub(asked) < WarmImageWindow {
+			continue
+		}
+
+		c.warmed[node] = now
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// imageCache holds the status of the images this node pulled ahead,
+// oldest first
+type imageCache struct {
+	mu      sync.Mutex
+	entries []*pb.ImageCacheEntry
+}
+
+// start records the image as being pulled ahead, returning false if it
+// already is or was pulled
+func (c *imageCache) start(node, imageRef, reason string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.entries {
+		if entry.GetImageRef() == imageRef && entry.GetStatus() != pb.ImageCacheStatus_IMAGE_WARM_FAILED {
+			return false
+		}
+	}
+
+	c.entries = slices.DeleteFunc(c.entries, func(entry *pb.ImageCacheEntry) bool {
+		return entry.GetImageRef() == imageRef
+	})
+
+	c.entries = append(c.entries, &pb.ImageCacheEntry{
+		Node:            node,
+		ImageRef:        imageRef,
+		Status:          pb.ImageCacheStatus_IMAGE_WARMING,
+		UpdatedUnixTime: time.Now().Unix(),
+		Reason:          reason,
+	})
+
+	if len(c.entries) > MaxImageCacheEntries {
+		c.entries = c.entries[len(c.entries)-MaxImageCacheEntries:]
+	}
+
+	return true
+}
+
+// done records the outcome of pulling an image ahead
+func (c *imageCache) done(imageRef string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.entries {
+		if entry.GetImageRef() != imageRef {
+			continue
+		}
+
+		entry.Status = pb.ImageCacheStatus_IMAGE_WARM
+		entry.UpdatedUnixTime = time.Now().Unix()
+
+		if err != nil {
+			entry.Status = pb.ImageCacheStatus_IMAGE_WARM_FAILED
+			entry.Error = err.Error()
+		}
+	}
+}
+
+// list returns copies of the entries, oldest first
+func (c *imageCache) list() []*pb.ImageCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]*pb.ImageCacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, proto.Clone(entry).(*pb.ImageCacheEntry))
+	}
+
+	return entries
+}
+
+// recordPlacement counts the placement of a replica on one of the ranked
+// candidates, asking the next best ones to pull its image ahead once the
+// image was placed repeatedly, so its replicas are respawned fast on
+// them if the nodes running them fail
+func (a *Agent) recordPlacement(req *pb.VmSpawnRequest, candidates []string, node string) {
+	if a.placements == nil {
+		return
+	}
+
+	i := slices.Index(candidates, node)
+	if i < 0 {
+		return
+	}
+
+	nodes := a.placements.record(req.GetImageRef(), candidates[i+1:], time.Now())
+	if len(nodes) == 0 {
+		return
+	}
+
+	payload, err := proto.Marshal(&pb.WarmImageRequest{
+		ImageRef: req.GetImageRef(),
+		Nodes:    nodes,
+		Reason:   fmt.Sprintf("placed %d times within %s", WarmImagePlacements, WarmImageWindow),
+	})
+	if err != nil {
+		a.logger.WithError(err).Error("failed to marshal warm image event")
+
+		return
+	}
+
+	a.logger.Infof("Asking nodes %v to pull image %s ahead", nodes, req.GetImageRef())
+
+	if err := a.serf.UserEvent(WarmImageEvent, payload, false); err != nil {
+		a.logger.WithError(err).Errorf("failed to broadcast warming of image %s", req.GetImageRef())
+	}
+}
+
+func (a *Agent) handleWarmImageEvent(payload []byte) {
+	var req pb.WarmImageRequest
+	if err := proto.Unmarshal(payload, &req); err != nil {
+		a.logger.WithError(err).Error("failed to unmarshal warm image event")
+
+		return
+	}
+
+	if a.placements == nil || !slices.Contains(req.GetNodes(), a.cfg.NodeName) {
+		return
+	}
+
+	if !a.imageCache.start(a.cfg.NodeName, req.GetImageRef(), req.GetReason()) {
+		return
+	}
+
+	go a.warmImage(req.GetImageRef())
+}
+
+// warmImage pulls an image ahead of the replicas likely to be placed on
+// this node
+func (a *Agent) warmImage(imageRef string) {
+	ctx, cancel := context.WithTimeout(a.ctrRepo.GetContext(context.Background()), WarmImageTimeout)
+	defer cancel()
+
+	a.logger.Infof("Pulling image %s ahead", imageRef)
+
+	err := a.ctrRepo.PullImage(ctx, imageRef)
+	if err != nil {
+		a.logger.WithError(err).Warnf("failed to pull image %s ahead", imageRef)
+	}
+
+	a.imageCache.done(imageRef, err)
+}
+
+// CacheStatusRequest returns the images the nodes pulled ahead, as of
+// their last state broadcasts
+func (a *Agent) CacheStatusRequest(req *pb.CacheStatusRequest) *pb.CacheStatusResponse {
+	resp := &pb.CacheStatusResponse{}
+
+	for _, state := range a.knownStates() {
+		if req.GetNode() != "" && state.GetNode().GetId() != req.GetNode() {
+			continue
+		}
+
+		for _, entry := range state.GetImageCache() {
+			if req.GetImageRef() != "" && entry.GetImageRef() != req.GetImageRef() {
+				continue
+			}
+
+			resp.Entries = append(resp.Entries, entry)
+		}
+	}
+
+	slices.SortStableFunc(resp.Entries, func(a, b *pb.ImageCacheEntry) int {
+		return cmp.Compare(a.GetNode(), b.GetNode())
+	})
+
+	return resp
+}
@@ -0,0 +1,232 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pb "vistara-node/pkg/proto/cluster"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultWorkloadMetricsPath = "/metrics"
+
+	workloadScrapeTimeout = time.Second * 5
+	// Larger responses are truncated
+	maxWorkloadMetricsBytes = 4 * 1024 * 1024
+)
+
+// metricsTarget is the metrics endpoint of a local workload
+type metricsTarget struct {
+	url    string
+	tenant string
+}
+
+// workloadMetrics holds the metrics endpoints of the local workloads,
+// refreshed with every state broadcast
+type workloadMetrics struct {
+	mu      sync.Mutex
+	targets map[string]metricsTarget
+	client  *http.Client
+}
+
+func newWorkloadMetrics() *workloadMetrics {
+	return &workloadMetrics{
+		targets: make(map[string]metricsTarget),
+		client:  &http.Client{Timeout: workloadScrapeTimeout},
+	}
+}
+
+// track records the metrics endpoint of a running workload, if it
+// declared one
+func (m *workloadMetrics) track(id string, endpoint workloadEndpoint, spec *pb.VmSpawnRequest) {
+	metrics := spec.GetMetricsEndpoint()
+	if metrics == nil {
+		return
+	}
+
+	path := metrics.GetPath()
+	if path == "" {
+		path = DefaultWorkloadMetricsPath
+	}
+
+	m.mu.Lock()
+	m.targets[id] = metricsTarget{url: "http://" + endpoint.addr(metrics.GetPort()) + path, tenant: spec.GetTenant()}
+	m.mu.Unlock()
+}
+
+// retain forgets the endpoints of the workloads that aren't running anymore
+func (m *workloadMetrics) retain(running map[string]struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.targets {
+		if _, ok := running[id]; !ok {
+			delete(m.targets, id)
+		}
+	}
+}
+
+// metricFamily holds the samples of a metric across the workloads, the
+// text format requires them to be grouped under a single HELP and TYPE
+type metricFamily struct {
+	help    string
+	typ     string
+	samples []string
+}
+
+// write scrapes the endpoints of the local workloads and writes their
+// metrics with workload and tenant labels added to every sample
+func (m *workloadMetrics) write(ctx context.Context, w io.Writer, logger *log.Logger) error {
+	m.mu.Lock()
+	targets := maps.Clone(m.targets)
+	m.mu.Unlock()
+
+	type scrapeResult struct {
+		id   string
+		body string
+		err  error
+	}
+
+	results := make(chan scrapeResult, len(targets))
+
+	for id, target := range targets {
+		go func() {
+			body, err := m.scrape(ctx, target.url)
+			results <- scrapeResult{id: id, body: body, err: err}
+		}()
+	}
+
+	families := make(map[string]*metricFamily)
+
+	for range targets {
+		result := <-results
+		if result.err != nil {
+			logger.Warnf("failed to scrape metrics of workload %s: %s", result.id, result.err)
+
+			continue
+		}
+
+		labels := fmt.Sprintf("workload=%q,tenant=%q", result.id, targets[result.id].tenant)
+		relabelMetrics(result.body, labels, families)
+	}
+
+	buf := bufio.NewWriter(w)
+
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+
+		for _, line := range []string{family.help, family.typ} {
+			if line != "" {
+				fmt.Fprintln(buf, line)
+			}
+		}
+
+		for _, line := range family.samples {
+			fmt.Fprintln(buf, line)
+		}
+	}
+
+	return buf.Flush()
+}
+
+func (m *workloadMetrics) scrape(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the text format is relabeled
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("got status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWorkloadMetricsBytes))
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// relabelMetrics adds the labels to the samples of the metrics in the text
+// format, grouping them by family. Samples belong to the family of the
+// last HELP or TYPE line if their name starts with it, like the _bucket,
+// _sum and _count samples of histograms
+func relabelMetrics(body, labels string, families map[string]*metricFamily) {
+	current := ""
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				continue
+			}
+
+			current = fields[2]
+			family := familyOf(families, current)
+
+			// The first workload exposing the family sets its header
+			if fields[1] == "HELP" && family.help == "" {
+				family.help = line
+			} else if fields[1] == "TYPE" && family.typ == "" {
+				family.typ = line
+			}
+
+			continue
+		}
+
+		name, rest, hasLabels := strings.Cut(line, "{")
+		if hasLabels {
+			separator := ","
+			if strings.HasPrefix(rest, "}") {
+				separator = ""
+			}
+
+			line = name + "{" + labels + separator + rest
+		} else {
+			name, rest, _ = strings.Cut(line, " ")
+			line = name + "{" + labels + "} " + rest
+		}
+
+		familyName := name
+		if current != "" && strings.HasPrefix(name, current+"_") {
+			familyName = current
+		}
+
+		family := familyOf(families, familyName)
+		family.samples = append(family.samples, line)
+	}
+}
+
+func familyOf(families map[string]*metricFamily, name string) *metricFamily {
+	family, ok := families[name]
+	if !ok {
+		family = &metricFamily{}
+		families[name] = family
+	}
+
+	return family
+}
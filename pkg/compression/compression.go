@@ -0,0 +1,112 @@
+// Package compression registers the gRPC compressors used between hypercore
+// clients and nodes, importing it is enough for a server to accept them
+package compression
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	Gzip = gzip.Name
+	Zstd = "zstd"
+)
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// Validate checks that name is a registered compressor, an empty name
+// disables compression
+func Validate(name string) error {
+	if name != "" && encoding.GetCompressor(name) == nil {
+		return fmt.Errorf("unknown compression %q, expected %s or %s", name, Gzip, Zstd)
+	}
+
+	return nil
+}
+
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string {
+	return Zstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	encoder, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+
+		encoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encoder.Reset(w)
+
+	return &zstdWriter{Encoder: encoder, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	decoder, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+
+		decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := decoder.Reset(r); err != nil {
+		c.decoders.Put(decoder)
+
+		return nil, err
+	}
+
+	return &zstdReader{decoder: decoder, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool once closed
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+
+	return err
+}
+
+// zstdReader returns its decoder to the pool once it read the whole
+// message, gRPC stops reading messages over the size limit early in
+// which case it is left to the garbage collector
+type zstdReader struct {
+	decoder *zstd.Decoder
+	pool    *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.decoder == nil {
+		return 0, io.EOF
+	}
+
+	n, err := r.decoder.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.decoder)
+		r.decoder = nil
+	}
+
+	return n, err
+}
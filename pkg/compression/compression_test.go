@@ -0,0 +1,168 @@
+package compression_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"vistara-node/pkg/compression"
+	pb "vistara-node/pkg/proto/cluster"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+)
+
+// encodings compared by the benchmarks, the uncompressed one is what
+// clients and nodes sent before compression was configurable
+var encodings = []string{"", compression.Gzip, compression.Zstd}
+
+func spawnRequest(i int) *pb.VmSpawnRequest {
+	return &pb.VmSpawnRequest{
+		Cores:        2,
+		Memory:       512,
+		ImageRef:     "docker.io/library/nginx:latest",
+		Ports:        map[uint32]uint32{uint32(8000 + i%1000): 80},
+		ReplicaGroup: fmt.Sprintf("web-%d", i/3),
+		SpecHash:     fmt.Sprintf("%064x", i/3),
+		Env:          map[string]string{"LOG_LEVEL": "info", "REPLICA": fmt.Sprint(i)},
+		Labels:       map[string]string{"app": "web", "tier": "frontend"},
+	}
+}
+
+// listResponse is the List response of a cluster running the number of
+// workloads
+func listResponse(workloads int) *pb.VmQueryResponse {
+	resp := &pb.VmQueryResponse{Vms: make(map[string]*pb.VmSpawnRequest, workloads)}
+	for i := range workloads {
+		resp.Vms[fmt.Sprintf("%032x", i)] = spawnRequest(i)
+	}
+
+	return resp
+}
+
+// nodeState is the state a node running the number of workloads broadcasts
+func nodeState(workloads int) *pb.NodeStateResponse {
+	state := &pb.NodeStateResponse{
+		Node:   &pb.Node{Id: "node-1", Ip: "10.0.0.1", Status: pb.NodeStatus_NODE_READY},
+		Cpus:   64,
+		Memory: 256 << 10,
+	}
+
+	for i := range workloads {
+		req := spawnRequest(i)
+		state.Workloads = append(state.Workloads, &pb.WorkloadState{
+			Id:            fmt.Sprintf("%032x", i),
+			SourceRequest: req,
+			Labels:        req.GetLabels(),
+		})
+	}
+
+	return state
+}
+
+// encode marshals msg and compresses it as gRPC would on the wire with
+// the compressor, uncompressed for an empty name
+func encode(msg proto.Message, name string) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil || name == "" {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+
+	w, err := encoding.GetCompressor(name).Compress(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte, name string) ([]byte, error) {
+	if name == "" {
+		return data, nil
+	}
+
+	r, err := encoding.GetCompressor(name).Decompress(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+// benchmarkSize encodes msg with each of the encodings, reporting the
+// size of the encoded message alongside the time it takes
+func benchmarkSize(b *testing.B, msg proto.Message) {
+	b.Helper()
+
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, name := range encodings {
+		label := name
+		if label == "" {
+			label = "none"
+		}
+
+		b.Run(label, func(b *testing.B) {
+			data, err := encode(msg, name)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			decoded, err := decode(data, name)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			roundTripped := msg.ProtoReflect().New().Interface()
+			if err := proto.Unmarshal(decoded, roundTripped); err != nil {
+				b.Fatal(err)
+			}
+
+			if !proto.Equal(roundTripped, msg) {
+				b.Fatal("message changed once decompressed")
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := encode(msg, name); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(len(data)), "bytes/msg")
+			b.ReportMetric(float64(len(raw))/float64(len(data)), "ratio")
+		})
+	}
+}
+
+func BenchmarkListResponseSize(b *testing.B) {
+	for _, workloads := range []int{100, 10000} {
+		b.Run(fmt.Sprint(workloads), func(b *testing.B) {
+			benchmarkSize(b, listResponse(workloads))
+		})
+	}
+}
+
+func BenchmarkNodeStateSize(b *testing.B) {
+	for _, workloads := range []int{10, 500} {
+		b.Run(fmt.Sprint(workloads), func(b *testing.B) {
+			benchmarkSize(b, nodeState(workloads))
+		})
+	}
+}
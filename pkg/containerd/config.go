@@ -3,4 +3,21 @@ package containerd
 type Config struct {
 	SocketPath         string
 	ContainerNamespace string
+	// Purpose the containers are created for, e.g. OwnerCluster, only the
+	// containers labelled with it are listed and changed
+	Owner string
+	// Maximum number of images pulled at once, 0 for no limit
+	MaxConcurrentPulls int
+	// Bytes per second the image pulls download at most, all together,
+	// 0 for no limit
+	MaxPullBandwidth int64
+	// Run workloads without root, in a user-mode network stack
+	// (RootlessNetworkSlirp4netns or RootlessNetworkPasta) instead of
+	// CNI networks
+	Rootless        bool
+	RootlessNetwork string
+	// Linux bridge the workloads are attached to, so they can reach
+	// each other, instead of a point-to-point link each. Empty for
+	// point-to-point links
+	Bridge string
 }
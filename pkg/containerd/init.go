@@ -0,0 +1,208 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/oci"
+	"github.com/google/uuid"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	log "github.com/sirupsen/logrus"
+
+	"vistara-node/pkg/cgroup"
+	"vistara-node/pkg/defaults"
+)
+
+// How long an init step can run before it is killed and fails
+const DefaultInitStepTimeout = defaults.InitStepTimeout
+
+// InitStep is a command run to completion before a container starts, in a
+// container of its own sharing the network namespace of the container
+type InitStep struct {
+	// Image of the step, the image of the container if empty
+	ImageRef string
+	// Command of the step, the entrypoint of its image if empty
+	Command []string
+	// Environment variables (KEY=value) set on top of the ones of the
+	// container
+	Env []string
+	// DefaultInitStepTimeout if zero
+	Timeout time.Duration
+}
+
+// InitStepError is returned when an init step of a container failed
+type InitStepError struct {
+	// Index of the step in the init steps of the container
+	Step int
+	// Exit status of the step, zero if it couldn't run
+	ExitStatus uint32
+	Err        error
+}
+
+func (e *InitStepError) Error() string {
+	return fmt.Sprintf("init step %d failed: %s", e.Step, e.Err)
+}
+
+func (e *InitStepError) Unwrap() error {
+	return e.Err
+}
+
+// InitProgress is the progress of the init steps of a container
+type InitProgress struct {
+	// Steps that completed successfully
+	Completed int
+	// Set once all the steps completed and the task of the container
+	// started, or a step failed
+	Done bool
+	// Why the steps or the start of the task failed, the task of the
+	// container is then left created but not started
+	Err error
+}
+
+// runInitSteps runs the init steps of a container one after the other and
+// starts its pod if all of them succeed
+func (r *Repo) runInitSteps(ctx context.Context, containerID string, task containerd.Task, networkNs specs.LinuxNamespace, opts CreateContainerOpts) {
+	report := func(progress InitProgress) {
+		if opts.InitProgress != nil {
+			opts.InitProgress(progress)
+		}
+	}
+
+	for i, step := range opts.InitSteps {
+		if err := r.runInitStep(ctx, containerID, i, step, networkNs, opts); err != nil {
+			log.WithError(err).Warnf("init step %d of container %s failed", i, containerID)
+			report(InitProgress{Completed: i, Done: true, Err: err})
+
+			return
+		}
+
+		report(InitProgress{Completed: i + 1})
+	}
+
+	if err := r.startPod(ctx, containerID, task, networkNs, opts); err != nil {
+		report(InitProgress{Completed: len(opts.InitSteps), Done: true, Err: err})
+
+		return
+	}
+
+	report(InitProgress{Completed: len(opts.InitSteps), Done: true})
+}
+
+func (r *Repo) runInitStep(ctx context.Context, containerID string, index int, step InitStep, networkNs specs.LinuxNamespace, opts CreateContainerOpts) error {
+	stepErr := func(code uint32, err error) error {
+		return &InitStepError{Step: index, ExitStatus: code, Err: err}
+	}
+
+	ref := step.ImageRef
+	if ref == "" {
+		ref = opts.ImageRef
+	}
+
+	image, err := r.pulls.pull(ctx, ref, opts.Snapshotter, nil)
+	if err != nil {
+		return stepErr(0, err)
+	}
+
+	specOpts := []oci.SpecOpts{
+		oci.WithImageConfig(image),
+		oci.WithLinuxNamespace(networkNs),
+	}
+	specOpts = append(specOpts, r.netFileOpts(containerID, opts)...)
+	if env := append(append([]string{}, opts.Env...), step.Env...); len(env) > 0 {
+		specOpts = append(specOpts, oci.WithEnv(env))
+	}
+	if len(step.Command) > 0 {
+		specOpts = append(specOpts, oci.WithProcessArgs(step.Command...))
+	}
+	if opts.Limits != nil && r.cgroupLimits {
+		specOpts = append(specOpts, cgroup.WithLimits(r.cgroupMode, r.ioDevice, opts.Limits))
+	}
+
+	stepID := fmt.Sprintf("%s-init-%d", containerID, index)
+
+	container, err := r.client.NewContainer(
+		ctx,
+		stepID,
+		containerd.WithImage(image),
+		containerd.WithSnapshotter(opts.Snapshotter),
+		containerd.WithNewSnapshot(uuid.NewString(), image),
+		containerd.WithRuntime(opts.Runtime.Name, nil),
+		containerd.WithContainerLabels(r.withOwner(nil)),
+		containerd.WithNewSpec(specOpts...),
+	)
+	if err != nil {
+		return stepErr(0, fmt.Errorf("failed to create container %s: %w", stepID, err))
+	}
+
+	defer func() {
+		if err := container.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
+			log.WithError(err).Warnf("failed to delete init container %s", stepID)
+		}
+	}()
+
+	// The output of the steps goes to the log of the container
+	ioCreator := cio.NullIO
+	if opts.CioCreator != nil {
+		ioCreator = func(string) (cio.IO, error) {
+			return opts.CioCreator(containerID)
+		}
+	}
+
+	task, err := container.NewTask(ctx, ioCreator)
+	if err != nil {
+		return stepErr(0, fmt.Errorf("failed to create task for container %s: %w", stepID, err))
+	}
+
+	defer func() {
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil {
+			log.WithError(err).Warnf("failed to delete task of init container %s", stepID)
+		}
+	}()
+
+	statusC, err := task.Wait(ctx)
+	if err != nil {
+		return stepErr(0, fmt.Errorf("failed to get exit status chan for container %s task: %w", stepID, err))
+	}
+
+	if err := task.Start(ctx); err != nil {
+		return stepErr(0, fmt.Errorf("failed to start task for container %s: %w", stepID, err))
+	}
+
+	timeout := step.Timeout
+	if timeout == 0 {
+		timeout = DefaultInitStepTimeout
+	}
+
+	var timedOut bool
+	var status containerd.ExitStatus
+
+	select {
+	case status = <-statusC:
+	case <-time.After(timeout):
+		timedOut = true
+
+		if err := task.Kill(ctx, syscall.SIGKILL); err != nil {
+			return stepErr(0, fmt.Errorf("failed to kill task: %w", err))
+		}
+
+		status = <-statusC
+	}
+
+	code, _, err := status.Result()
+	if err != nil {
+		return stepErr(0, fmt.Errorf("failed to get exit status: %w", err))
+	}
+
+	switch {
+	case timedOut:
+		return stepErr(code, fmt.Errorf("timed out after %s", timeout))
+	case code != 0:
+		return stepErr(code, fmt.Errorf("exited with status %d", code))
+	}
+
+	return nil
+}
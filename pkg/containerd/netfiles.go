@@ -0,0 +1,112 @@
+package containerd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
+
+	"github.com/containerd/containerd/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// hostResolvConf is the resolv.conf mounted in the containers without a
+// DNS config
+const hostResolvConf = "/etc/resolv.conf"
+
+// netFilesDir holds the resolv.conf and hosts files written for the
+// containers with a DNS config or extra hosts, a directory per container
+func (r *Repo) netFilesDir(containerID string) string {
+	if r.config.Rootless {
+		return filepath.Join(RootlessRuntimeDir(), "hypercore", "netfiles", containerID)
+	}
+
+	return filepath.Join(defaults.StateRootDir, "netfiles", containerID)
+}
+
+// writeNetFiles writes the resolv.conf and hosts files of a container,
+// shared by its init steps and the containers of its pod
+func (r *Repo) writeNetFiles(containerID string, opts CreateContainerOpts) error {
+	if opts.DNS == nil && len(opts.ExtraHosts) == 0 {
+		return nil
+	}
+
+	dir := r.netFilesDir(containerID)
+	if err := os.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	if opts.DNS != nil {
+		if err := opts.DNS.Validate(); err != nil {
+			return fmt.Errorf("invalid DNS config: %w", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "resolv.conf"), opts.DNS.ResolvConf(), defaults.DataFilePerm); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.ExtraHosts) > 0 {
+		if err := models.ValidateHostEntries(opts.ExtraHosts); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "hosts"), models.HostsFile(opts.ExtraHosts), defaults.DataFilePerm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repo) removeNetFiles(containerID string) {
+	if err := os.RemoveAll(r.netFilesDir(containerID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.WithError(err).Warnf("failed to remove the network files of container %s", containerID)
+	}
+}
+
+// netFileOpts mounts the files written for the container with ID
+// containerID, the resolv.conf of the host if it has no DNS config and the
+// hosts file of its image if it has no extra hosts
+func (r *Repo) netFileOpts(containerID string, opts CreateContainerOpts) []oci.SpecOpts {
+	specOpts := []oci.SpecOpts{oci.WithHostResolvconf}
+	if opts.DNS != nil {
+		specOpts[0] = r.withNetFile(containerID, "resolv.conf")
+	}
+
+	if len(opts.ExtraHosts) > 0 {
+		specOpts = append(specOpts, r.withNetFile(containerID, "hosts"))
+	}
+
+	return specOpts
+}
+
+func (r *Repo) withNetFile(containerID, name string) oci.SpecOpts {
+	return oci.WithMounts([]specs.Mount{{
+		Destination: "/etc/" + name,
+		Type:        "bind",
+		Source:      filepath.Join(r.netFilesDir(containerID), name),
+		Options:     []string{"rbind", "ro"},
+	}})
+}
+
+// containerResolvers returns the nameservers of the resolv.conf the
+// container gets, the one of the host if it has no DNS config
+func containerResolvers(dns *models.DNSConfig) ([]net.IP, error) {
+	if dns != nil {
+		return network.Nameservers(dns.ResolvConf()), nil
+	}
+
+	contents, err := os.ReadFile(hostResolvConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the resolvers of the host: %w", err)
+	}
+
+	return network.Nameservers(contents), nil
+}
@@ -0,0 +1,148 @@
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/types/task"
+)
+
+// OwnerLabel marks the containers hypercore created, holding the purpose
+// they were created for. The containers of the namespace without it, e.g.
+// created with ctr or nerdctl, are neither listed nor deleted
+const OwnerLabel = "hypercore-owner"
+
+// Purposes of the containers hypercore creates, each only seeing its own
+// when sharing a namespace
+const (
+	// Workloads of the cluster agent, along with their pod containers
+	// and init steps
+	OwnerCluster = "cluster"
+	// MicroVMs of the spawn command and the VMService API
+	OwnerMicroVM = "microvm"
+)
+
+var (
+	// ErrNotOwned is returned when changing a container hypercore didn't
+	// create, or created for another purpose
+	ErrNotOwned = errors.New("container not owned")
+	// ErrOwned is returned when adopting a container that already has an
+	// owner
+	ErrOwned = errors.New("container already owned")
+)
+
+// withOwner returns the labels of a new container along with the owner
+// label of the repo
+func (r *Repo) withOwner(labels map[string]string) map[string]string {
+	owned := map[string]string{OwnerLabel: r.config.Owner}
+	maps.Copy(owned, labels)
+
+	return owned
+}
+
+// ownerFilter returns the containerd filter of the containers owned by
+// the repo, ANDed with filter if not empty
+func (r *Repo) ownerFilter(filter string) string {
+	owned := fmt.Sprintf("labels.%q==%q", OwnerLabel, r.config.Owner)
+	if filter == "" {
+		return owned
+	}
+
+	return filter + "," + owned
+}
+
+// checkOwned fails with ErrNotOwned unless the repo owns the container
+func (r *Repo) checkOwned(ctx context.Context, container containerd.Container) error {
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get labels of container %s: %w", container.ID(), err)
+	}
+
+	switch owner := labels[OwnerLabel]; owner {
+	case r.config.Owner:
+		return nil
+	case "":
+		return fmt.Errorf("%w: container %s wasn't created by hypercore", ErrNotOwned, container.ID())
+	default:
+		return fmt.Errorf("%w: container %s belongs to hypercore %s, not %s", ErrNotOwned, container.ID(), owner, r.config.Owner)
+	}
+}
+
+// ownedTasks leaves out the tasks of the containers the repo doesn't own
+func (r *Repo) ownedTasks(ctx context.Context, tasks []*task.Process) ([]*task.Process, error) {
+	containers, err := r.client.Containers(ctx, r.ownerFilter(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list owned containers: %w", err)
+	}
+
+	owned := make(map[string]struct{}, len(containers))
+	for _, container := range containers {
+		owned[container.ID()] = struct{}{}
+	}
+
+	return slices.DeleteFunc(tasks, func(task *task.Process) bool {
+		_, ok := owned[task.GetID()]
+
+		return !ok
+	}), nil
+}
+
+// AdoptContainers labels the containers matching the containerd filter
+// as owned by the repo, unless they already have an owner. It takes over
+// the containers created before the owner label, returning their IDs
+func (r *Repo) AdoptContainers(ctx context.Context, filter string) ([]string, error) {
+	namespaceCtx := r.GetContext(ctx)
+
+	containers, err := r.client.Containers(namespaceCtx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers to adopt: %w", err)
+	}
+
+	var adopted []string
+
+	for _, container := range containers {
+		if err := r.adopt(namespaceCtx, container, nil); errors.Is(err, ErrOwned) {
+			continue
+		} else if err != nil {
+			return adopted, err
+		}
+
+		adopted = append(adopted, container.ID())
+	}
+
+	return adopted, nil
+}
+
+// AdoptContainer labels a container as owned by the repo along with the
+// given labels, failing with ErrOwned if it already has an owner
+func (r *Repo) AdoptContainer(ctx context.Context, id string, labels map[string]string) error {
+	namespaceCtx := r.GetContext(ctx)
+
+	container, err := r.client.LoadContainer(namespaceCtx, id)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %w", id, err)
+	}
+
+	return r.adopt(namespaceCtx, container, labels)
+}
+
+func (r *Repo) adopt(ctx context.Context, container containerd.Container, labels map[string]string) error {
+	current, err := container.Labels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get labels of container %s: %w", container.ID(), err)
+	}
+
+	if owner, ok := current[OwnerLabel]; ok {
+		return fmt.Errorf("%w: container %s belongs to hypercore %s", ErrOwned, container.ID(), owner)
+	}
+
+	if _, err := container.SetLabels(ctx, r.withOwner(labels)); err != nil {
+		return fmt.Errorf("failed to adopt container %s: %w", container.ID(), err)
+	}
+
+	return nil
+}
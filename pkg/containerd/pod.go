@@ -0,0 +1,152 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/oci"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	log "github.com/sirupsen/logrus"
+
+	"vistara-node/pkg/cgroup"
+)
+
+// Label of the containers of a pod besides the main one, holding the ID of
+// the main container
+const PodLabel = "hypercore-pod"
+
+// PodContainer is a container spawned along with a container, sharing its
+// network namespace and limits. The container and its pod containers are
+// started and deleted together
+type PodContainer struct {
+	// Unique within the pod, the ID of the container is the ID of the
+	// main container suffixed with it
+	Name string
+	// Image of the container, the image of the main container if empty
+	ImageRef string
+	// Command of the container, the entrypoint of its image if empty
+	Command []string
+	// Environment variables (KEY=value) set on top of the ones of the
+	// main container
+	Env []string
+}
+
+// PodContainerID returns the ID of a pod container of a container
+func PodContainerID(containerID, name string) string {
+	return containerID + "-" + name
+}
+
+// startPod starts the pod containers of a container, then its task
+func (r *Repo) startPod(ctx context.Context, containerID string, task containerd.Task, networkNs specs.LinuxNamespace, opts CreateContainerOpts) (retErr error) {
+	if len(opts.PodContainers) > 0 {
+		defer func() {
+			if retErr != nil {
+				if err := r.deletePodContainers(ctx, containerID); err != nil {
+					retErr = multierror.Append(retErr, err)
+				}
+			}
+		}()
+	}
+
+	for _, podContainer := range opts.PodContainers {
+		if err := r.startPodContainer(ctx, containerID, podContainer, networkNs, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := task.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start task for container %s: %w", containerID, err)
+	}
+
+	return nil
+}
+
+func (r *Repo) startPodContainer(ctx context.Context, containerID string, podContainer PodContainer, networkNs specs.LinuxNamespace, opts CreateContainerOpts) error {
+	ref := podContainer.ImageRef
+	if ref == "" {
+		ref = opts.ImageRef
+	}
+
+	image, err := r.pulls.pull(ctx, ref, opts.Snapshotter, nil)
+	if err != nil {
+		return err
+	}
+
+	specOpts := []oci.SpecOpts{
+		oci.WithImageConfig(image),
+		oci.WithLinuxNamespace(networkNs),
+	}
+	specOpts = append(specOpts, r.netFileOpts(containerID, opts)...)
+	if env := append(append([]string{}, opts.Env...), podContainer.Env...); len(env) > 0 {
+		specOpts = append(specOpts, oci.WithEnv(env))
+	}
+	if len(podContainer.Command) > 0 {
+		specOpts = append(specOpts, oci.WithProcessArgs(podContainer.Command...))
+	}
+	if opts.Limits != nil && r.cgroupLimits {
+		specOpts = append(specOpts, cgroup.WithLimits(r.cgroupMode, r.ioDevice, opts.Limits))
+	}
+
+	id := PodContainerID(containerID, podContainer.Name)
+
+	container, err := r.client.NewContainer(
+		ctx,
+		id,
+		containerd.WithImage(image),
+		containerd.WithSnapshotter(opts.Snapshotter),
+		containerd.WithNewSnapshot(uuid.NewString(), image),
+		containerd.WithRuntime(opts.Runtime.Name, nil),
+		containerd.WithContainerLabels(r.withOwner(map[string]string{PodLabel: containerID})),
+		containerd.WithNewSpec(specOpts...),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create container %s: %w", id, err)
+	}
+
+	// The output of the pod containers goes to the log of the container
+	ioCreator := cio.NullIO
+	if opts.CioCreator != nil {
+		ioCreator = func(string) (cio.IO, error) {
+			return opts.CioCreator(containerID)
+		}
+	}
+
+	task, err := container.NewTask(ctx, ioCreator)
+	if err != nil {
+		return fmt.Errorf("failed to create task for container %s: %w", id, err)
+	}
+
+	if err := task.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start task for container %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// deletePodContainers stops and deletes the pod containers of a container,
+// after the container itself so they outlive it, e.g. to ship its logs
+func (r *Repo) deletePodContainers(ctx context.Context, containerID string) error {
+	containers, err := r.client.Containers(ctx, r.ownerFilter(fmt.Sprintf("labels.%q==%s", PodLabel, containerID)))
+	if err != nil {
+		return fmt.Errorf("failed to list pod containers of container %s: %w", containerID, err)
+	}
+
+	var result error
+
+	for _, container := range containers {
+		if _, err := r.DeleteContainer(ctx, container.ID(), 0); err != nil {
+			log.WithError(err).Warnf("failed to delete pod container %s", container.ID())
+
+			// Containers whose task couldn't be created have none to stop
+			if err := container.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,237 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/remotes/docker"
+)
+
+const (
+	// Period at which the callers waiting on a pull get its progress
+	PullProgressPeriod = time.Second * 5
+
+	// Largest read of a pull charged to the bandwidth cap at once
+	pullReadChunk = 32 * 1024
+	// Pulls no caller waits on anymore still can't hold a slot forever
+	pullTimeout = time.Minute * 30
+)
+
+// PullProgress is the progress of an image pull
+type PullProgress struct {
+	Ref string
+	// Bytes downloaded so far
+	Bytes int64
+	// Callers waiting on the pull, including the one that started it
+	Waiters int
+	Elapsed time.Duration
+}
+
+// pullManager pulls the images of the repo: concurrent pulls of the same
+// image share a single pull, which waits for one of the pull slots, and
+// the downloads of all the pulls share the bandwidth cap
+type pullManager struct {
+	client *containerd.Client
+	// slots of the image pulls in progress, nil for no limit
+	slots chan struct{}
+	// nil for no bandwidth cap
+	limiter *bandwidthLimiter
+
+	mu       sync.Mutex
+	inflight map[string]*pullCall
+}
+
+// pullCall is a pull in progress, done is closed once it completed
+type pullCall struct {
+	ref     string
+	started time.Time
+	bytes   atomic.Int64
+	waiters atomic.Int32
+
+	done  chan struct{}
+	image containerd.Image
+	err   error
+}
+
+func newPullManager(client *containerd.Client, maxConcurrent int, bytesPerSecond int64) *pullManager {
+	manager := &pullManager{client: client, inflight: make(map[string]*pullCall)}
+
+	if maxConcurrent > 0 {
+		manager.slots = make(chan struct{}, maxConcurrent)
+	}
+
+	if bytesPerSecond > 0 {
+		manager.limiter = &bandwidthLimiter{bytesPerSecond: bytesPerSecond}
+	}
+
+	return manager
+}
+
+// pull pulls and unpacks the image, or waits for the pull of the same
+// image already in progress. The pull keeps going if the callers give up,
+// as the image is still wanted by later spawns. progress, if not nil, is
+// called every PullProgressPeriod while waiting
+func (m *pullManager) pull(ctx context.Context, ref, snapshotter string, progress func(PullProgress)) (containerd.Image, error) {
+	namespace, _ := namespaces.Namespace(ctx)
+	key := namespace + "/" + snapshotter + "/" + ref
+
+	m.mu.Lock()
+	call, ok := m.inflight[key]
+	if !ok {
+		call = &pullCall{ref: ref, started: time.Now(), done: make(chan struct{})}
+		m.inflight[key] = call
+
+		go m.run(ctx, key, call, snapshotter)
+	}
+	call.waiters.Add(1)
+	m.mu.Unlock()
+
+	defer call.waiters.Add(-1)
+
+	ticker := time.NewTicker(PullProgressPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-call.done:
+			return call.image, call.err
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting to pull image %s: %w", ref, ctx.Err())
+		case <-ticker.C:
+			if progress != nil {
+				progress(call.progress())
+			}
+		}
+	}
+}
+
+func (m *pullManager) run(ctx context.Context, key string, call *pullCall, snapshotter string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pullTimeout)
+	defer cancel()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.inflight, key)
+		m.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	if m.slots != nil {
+		select {
+		case m.slots <- struct{}{}:
+			defer func() { <-m.slots }()
+		case <-ctx.Done():
+			call.err = fmt.Errorf("waiting to pull image %s: %w", call.ref, ctx.Err())
+
+			return
+		}
+	}
+
+	// A resolver per pull, so the bytes it downloads are counted
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Client: &http.Client{Transport: &pullTransport{base: http.DefaultTransport, limiter: m.limiter, bytes: &call.bytes}},
+	})
+
+	image, err := m.client.Pull(ctx, call.ref,
+		containerd.WithPullUnpack, containerd.WithPullSnapshotter(snapshotter), containerd.WithResolver(resolver))
+	if err != nil {
+		call.err = &ImagePullError{Ref: call.ref, Err: err}
+
+		return
+	}
+
+	call.image = image
+}
+
+func (c *pullCall) progress() PullProgress {
+	return PullProgress{
+		Ref:     c.ref,
+		Bytes:   c.bytes.Load(),
+		Waiters: int(c.waiters.Load()),
+		Elapsed: time.Since(c.started),
+	}
+}
+
+// pullTransport counts the bytes of the responses of the registry,
+// reading them no faster than the bandwidth cap allows
+type pullTransport struct {
+	base    http.RoundTripper
+	limiter *bandwidthLimiter
+	bytes   *atomic.Int64
+}
+
+func (t *pullTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &pullBody{ReadCloser: resp.Body, ctx: req.Context(), transport: t}
+
+	return resp, nil
+}
+
+type pullBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	transport *pullTransport
+}
+
+func (b *pullBody) Read(p []byte) (int, error) {
+	if b.transport.limiter != nil && len(p) > pullReadChunk {
+		p = p[:pullReadChunk]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.transport.bytes.Add(int64(n))
+
+	if b.transport.limiter != nil && n > 0 {
+		if waitErr := b.transport.limiter.wait(b.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+// bandwidthLimiter caps the bytes read per second by all the readers
+// sharing it
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+
+	mu sync.Mutex
+	// Time until which the bytes already read use up the bandwidth
+	next time.Time
+}
+
+// wait charges n bytes read to the bandwidth, waiting until they fit
+// within the cap
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,47 +2,138 @@ package containerd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
 	"github.com/containerd/containerd/api/services/tasks/v1"
 	"github.com/containerd/containerd/api/types/task"
 	"github.com/containerd/containerd/cio"
 	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/oci"
 	"github.com/containerd/containerd/pkg/netns"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/typeurl/v2"
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"vistara-node/pkg/cgroup"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
+	"vistara-node/pkg/proto/shimdebug"
 )
 
+// How long a container has to exit after SIGTERM before it is killed
+const DefaultStopTimeout = defaults.StopTimeout
+
 type CreateContainerOpts struct {
+	// ID of the container, generated if empty
+	ID          string
 	ImageRef    string
 	Snapshotter string
 	Runtime     struct {
 		Name    string
 		Options interface{}
 	}
-	Limits *struct {
-		CPUFraction float64
-		MemoryBytes uint64
-	}
-	Labels     map[string]string
+	Limits *cgroup.Limits
+	Labels map[string]string
+	// Environment variables (KEY=value) set on top of the image ones
+	Env []string
+	// Container ports the host connects to, forwarded from loopback
+	// ports for rootless containers
+	Ports []uint32
+	// New connections to the container are only accepted if they match
+	// one of the rules, applied to the tap device of VMs
+	IngressRules []models.IngressRule
+	// Restricts the connections opened by containers, not supported
+	// for VMs
+	EgressPolicy *models.EgressPolicy
+	// Port of the egress proxy on the host, if the egress policy
+	// proxies HTTP(S) connections
+	EgressProxyPort uint16
+	// Port of the metadata service on the host the connections of the
+	// container to models.MetadataIP are redirected to, 0 for none. Not
+	// supported for VMs, which are served their metadata through MMDS
+	MetadataPort uint16
+	// Track the connections of the container in its network namespace,
+	// sampled by ConnectionFlows. Not supported for VMs
+	TrackConnections bool
+	// Count the traffic of the container with eBPF programs on the
+	// interface of its network namespace, read with TrafficCounters. Not
+	// supported for rootless containers
+	CountTraffic bool
+	// Identity of the workload served to the guest of VMs, with the ID
+	// of the container
+	Metadata   *models.WorkloadMetadata
 	CioCreator cio.Creator
+	// Called every PullProgressPeriod while the image is pulled, if set
+	PullProgress func(PullProgress)
+	// Run before the task of the container starts, in the background
+	// once the container is created. Not supported for VMs and rootless
+	// containers
+	InitSteps []InitStep
+	// Called as the init steps complete, if set
+	InitProgress func(InitProgress)
+	// Started along with the container, once its init steps completed.
+	// Not supported for VMs and rootless containers
+	PodContainers []PodContainer
+	// Resolver of the container, its init steps and pod, the one of the
+	// host if nil. Passed to the guest of VMs
+	DNS *models.DNSConfig
+	// Entries added to the /etc/hosts of the container, its init steps
+	// and pod, or to the one of the guest of VMs
+	ExtraHosts []models.HostEntry
+	// Bind mounts of host directories, such as persistent volumes. Not
+	// supported for VMs
+	Mounts []specs.Mount
+}
+
+// ImagePullError is returned when the image of a container can't be pulled
+type ImagePullError struct {
+	Ref string
+	Err error
+}
+
+func (e *ImagePullError) Error() string {
+	return fmt.Sprintf("failed to pull image %s: %s", e.Ref, e.Err)
+}
+
+func (e *ImagePullError) Unwrap() error {
+	return e.Err
 }
 
 type Repo struct {
 	client *containerd.Client
 	config *Config
+	pulls  *pullManager
+	// whether rootless containers get resource limits
+	cgroupLimits bool
+	cgroupMode   cgroup.Mode
+	// disk the IO limits of the workloads apply to, nil if they aren't
+	// supported
+	ioDevice *cgroup.Device
+	// user-mode network stacks of the rootless containers
+	networksMu sync.Mutex
+	networks   map[string]*exec.Cmd
 }
 
 type NetNS struct {
@@ -61,10 +152,40 @@ func NewMicroVMRepository(cfg *Config) (*Repo, error) {
 		return nil, fmt.Errorf("creating containerd client: %w", err)
 	}
 
-	return &Repo{
-		client: client,
-		config: cfg,
-	}, nil
+	repo := &Repo{
+		client:       client,
+		config:       cfg,
+		cgroupLimits: true,
+		cgroupMode:   cgroup.DetectMode(),
+		networks:     make(map[string]*exec.Cmd),
+		pulls:        newPullManager(client, cfg.MaxConcurrentPulls, cfg.MaxPullBandwidth),
+	}
+
+	if cfg.Rootless {
+		support := CheckRootless(cfg.RootlessNetwork)
+		if err := support.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, warning := range support.Warnings {
+			log.Warnf("rootless: %s", warning)
+		}
+
+		repo.cgroupLimits = support.CgroupLimits
+	}
+
+	log.Infof("Enforcing workload limits with cgroup %s", repo.cgroupMode)
+
+	// The controllers delegated to rootless users leave out io
+	if !cfg.Rootless {
+		if device, err := cgroup.BlockDevice(defaults.DataRootDir); err != nil {
+			log.WithError(err).Warn("workloads can't have disk limits")
+		} else {
+			repo.ioDevice = &device
+		}
+	}
+
+	return repo, nil
 }
 
 func (r *Repo) GetContext(ctx context.Context) context.Context {
@@ -97,6 +218,10 @@ func (r *Repo) Attach(ctx context.Context, containerID string) error {
 
 // Reference: https://github.com/containerd/nerdctl/blob/b6257f3a980b19b0a530ff48b273b527a2c65b34/pkg/containerinspector/containerinspector_linux.go#L30
 func (r *Repo) GetTaskNetNsInfo(_ context.Context, task *task.Process) (*NetNS, error) {
+	if r.config.Rootless {
+		return nil, errRootlessNetNs
+	}
+
 	netNs := &NetNS{Interfaces: make([]NetInterface, 0)}
 	if err := ns.WithNetNSPath(fmt.Sprintf("/proc/%d/ns/net", task.GetPid()), func(_ ns.NetNS) error {
 		interfaces, err := net.Interfaces()
@@ -123,7 +248,14 @@ func (r *Repo) GetTaskNetNsInfo(_ context.Context, task *task.Process) (*NetNS,
 	return netNs, nil
 }
 
+// GetContainerPrimaryIP returns the IP the host reaches the container at,
+// the loopback address for rootless containers whose ports are forwarded
+// to loopback ports (see ForwardedPorts)
 func (r *Repo) GetContainerPrimaryIP(ctx context.Context, containerID string) (string, error) {
+	if r.config.Rootless {
+		return "127.0.0.1", nil
+	}
+
 	task, err := r.GetTask(ctx, containerID)
 	if err != nil {
 		return "", err
@@ -166,7 +298,112 @@ func (r *Repo) GetTasks(ctx context.Context) ([]*task.Process, error) {
 		return nil, err
 	}
 
-	return resp.GetTasks(), nil
+	return r.ownedTasks(namespaceCtx, resp.GetTasks())
+}
+
+// SubscribeOOMEvents returns a channel of container IDs that were OOM killed,
+// this covers both runc tasks (cgroup OOM) and microVM tasks, for which
+// the shim publishes the same event on detecting an OOM in the guest
+func (r *Repo) SubscribeOOMEvents(ctx context.Context) (<-chan string, <-chan error) {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	oomCh := make(chan string)
+	errCh := make(chan error, 1)
+
+	envelopeCh, subErrCh := r.client.Subscribe(namespaceCtx, fmt.Sprintf(`topic=="%s"`, runtime.TaskOOMEventTopic))
+
+	go func() {
+		defer close(oomCh)
+
+		for {
+			select {
+			case envelope, ok := <-envelopeCh:
+				if !ok {
+					return
+				}
+
+				if envelope.Namespace != r.config.ContainerNamespace {
+					continue
+				}
+
+				event, err := typeurl.UnmarshalAny(envelope.Event)
+				if err != nil {
+					log.WithContext(ctx).WithError(err).Error("failed to unmarshal OOM event")
+
+					continue
+				}
+
+				oom, ok := event.(*apievents.TaskOOM)
+				if !ok {
+					continue
+				}
+
+				select {
+				case oomCh <- oom.GetContainerID():
+				case <-ctx.Done():
+					return
+				}
+			case err := <-subErrCh:
+				errCh <- err
+
+				return
+			}
+		}
+	}()
+
+	return oomCh, errCh
+}
+
+// SubscribeVMCrashedEvents returns a channel of the guest kernel failures
+// the shims detected on the console of the microVM tasks
+func (r *Repo) SubscribeVMCrashedEvents(ctx context.Context) (<-chan *shimdebug.VMCrashed, <-chan error) {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	crashCh := make(chan *shimdebug.VMCrashed)
+	errCh := make(chan error, 1)
+
+	envelopeCh, subErrCh := r.client.Subscribe(namespaceCtx, fmt.Sprintf(`topic=="%s"`, defaults.VMCrashedEventTopic))
+
+	go func() {
+		defer close(crashCh)
+
+		for {
+			select {
+			case envelope, ok := <-envelopeCh:
+				if !ok {
+					return
+				}
+
+				if envelope.Namespace != r.config.ContainerNamespace {
+					continue
+				}
+
+				event, err := typeurl.UnmarshalAny(envelope.Event)
+				if err != nil {
+					log.WithContext(ctx).WithError(err).Error("failed to unmarshal VM crashed event")
+
+					continue
+				}
+
+				crashed, ok := event.(*shimdebug.VMCrashed)
+				if !ok {
+					continue
+				}
+
+				select {
+				case crashCh <- crashed:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-subErrCh:
+				errCh <- err
+
+				return
+			}
+		}
+	}()
+
+	return crashCh, errCh
 }
 
 func (r *Repo) GetContainer(ctx context.Context, id string) (containerd.Container, error) {
@@ -175,38 +412,150 @@ func (r *Repo) GetContainer(ctx context.Context, id string) (containerd.Containe
 	return r.client.LoadContainer(namespaceCtx, id)
 }
 
+// ListContainers returns the containers of the repo matching any of the
+// given containerd filters, e.g. labels."key"
+func (r *Repo) ListContainers(ctx context.Context, filters ...string) ([]containerd.Container, error) {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	owned := []string{r.ownerFilter("")}
+	if len(filters) > 0 {
+		owned = make([]string, 0, len(filters))
+		for _, filter := range filters {
+			owned = append(owned, r.ownerFilter(filter))
+		}
+	}
+
+	return r.client.Containers(namespaceCtx, owned...)
+}
+
 func (r *Repo) CreateContainer(ctx context.Context, opts CreateContainerOpts) (_ string, retErr error) {
 	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
 
-	image, err := r.client.Pull(namespaceCtx, opts.ImageRef, containerd.WithPullUnpack, containerd.WithPullSnapshotter(opts.Snapshotter))
+	image, err := r.pulls.pull(namespaceCtx, opts.ImageRef, opts.Snapshotter, opts.PullProgress)
 	if err != nil {
-		return "", fmt.Errorf("failed to pull image %s: %w", opts.ImageRef, err)
+		return "", err
 	}
 
 	// We don't want the context stored internally to get cancelled
 	// when this request completes
 	namespaceCtx = namespaces.WithNamespace(context.Background(), r.config.ContainerNamespace)
 
-	containerID := uuid.NewString()
+	containerID := opts.ID
+	if containerID == "" {
+		containerID = uuid.NewString()
+	}
 
-	netNs, err := netns.NewNetNS("/run/netns")
-	if err != nil {
-		return "", fmt.Errorf("failed to create new net ns: %w", err)
+	networkNs := specs.LinuxNamespace{Type: "network"}
+
+	// The shim applies the rules of VMs once it created their tap device
+	vmSpec, isVM := opts.Runtime.Options.(*models.MicroVMSpec)
+	if isVM && len(opts.IngressRules) > 0 {
+		vmSpec.IngressRules = opts.IngressRules
+	}
+
+	if isVM && opts.DNS != nil {
+		vmSpec.DNS = opts.DNS
+	}
+
+	if isVM && len(opts.ExtraHosts) > 0 {
+		vmSpec.ExtraHosts = opts.ExtraHosts
+	}
+
+	if isVM {
+		vmSpec.Workload = &models.WorkloadMetadata{}
+		if opts.Metadata != nil {
+			*vmSpec.Workload = *opts.Metadata
+		}
+		vmSpec.Workload.ID = containerID
+	}
+
+	// VM specs are passed to the shim as typed runtime options
+	runtimeOptions := opts.Runtime.Options
+	if isVM {
+		runtimeOptions = vmSpec.Options()
+	}
+
+	if isVM && opts.EgressPolicy != nil {
+		return "", errors.New("egress policies are not supported for VMs")
+	}
+
+	if isVM && len(opts.Mounts) > 0 {
+		return "", errors.New("bind mounts are not supported for VMs")
+	}
+
+	if r.config.Rootless && (len(opts.IngressRules) > 0 || opts.EgressPolicy != nil) {
+		return "", errors.New("firewall rules are not supported for rootless containers")
+	}
+
+	// The steps can't join the network namespace of those
+	if (isVM || r.config.Rootless) && len(opts.InitSteps) > 0 {
+		return "", errors.New("init steps are not supported for VMs and rootless containers")
+	}
+
+	if (isVM || r.config.Rootless) && len(opts.PodContainers) > 0 {
+		return "", errors.New("pods are not supported for VMs and rootless containers")
+	}
+
+	var forwarded map[uint32]uint32
+
+	if r.config.Rootless {
+		// The runtime creates the network namespace of rootless
+		// containers, owned by the user namespace of containerd
+		if forwarded, err = allocatePorts(opts.Ports); err != nil {
+			return "", err
+		}
+
+		encodedPorts, err := json.Marshal(forwarded)
+		if err != nil {
+			return "", err
+		}
+
+		labels := map[string]string{ForwardedPortsLabel: string(encodedPorts)}
+		maps.Copy(labels, opts.Labels)
+		opts.Labels = labels
+	} else {
+		netNs, err := netns.NewNetNS("/run/netns")
+		if err != nil {
+			return "", fmt.Errorf("failed to create new net ns: %w", err)
+		}
+
+		networkNs.Path = netNs.GetPath()
 	}
 
 	specOpts := []oci.SpecOpts{
 		oci.WithImageConfig(image),
-		oci.WithHostResolvconf,
-		oci.WithLinuxNamespace(specs.LinuxNamespace{Type: "network", Path: netNs.GetPath()}),
+		oci.WithLinuxNamespace(networkNs),
 	}
-	if opts.Limits != nil {
-		specOpts = append(
-			specOpts,
-			oci.WithMemoryLimit(opts.Limits.MemoryBytes),
-			// Quota is valid for every 100ms
-			// https://docs.docker.com/engine/containers/resource_constraints/#configure-the-default-cfs-scheduler
-			oci.WithCPUCFS(int64(opts.Limits.CPUFraction*100000), 100000),
-		)
+
+	if isVM {
+		// The agent mounts the files of the guest, which are
+		// configured by the shim
+		specOpts = append(specOpts, oci.WithHostResolvconf)
+		if len(opts.ExtraHosts) > 0 {
+			specOpts = append(specOpts, oci.WithHostHostsFile)
+		}
+	} else {
+		if err := r.writeNetFiles(containerID, opts); err != nil {
+			return "", err
+		}
+
+		defer func() {
+			if retErr != nil {
+				r.removeNetFiles(containerID)
+			}
+		}()
+
+		specOpts = append(specOpts, r.netFileOpts(containerID, opts)...)
+	}
+
+	if len(opts.Env) > 0 {
+		specOpts = append(specOpts, oci.WithEnv(opts.Env))
+	}
+	if len(opts.Mounts) > 0 {
+		specOpts = append(specOpts, oci.WithMounts(opts.Mounts))
+	}
+	if opts.Limits != nil && r.cgroupLimits {
+		specOpts = append(specOpts, cgroup.WithLimits(r.cgroupMode, r.ioDevice, opts.Limits))
 	}
 
 	container, err := r.client.NewContainer(
@@ -215,8 +564,8 @@ func (r *Repo) CreateContainer(ctx context.Context, opts CreateContainerOpts) (_
 		containerd.WithImage(image),
 		containerd.WithSnapshotter(opts.Snapshotter),
 		containerd.WithNewSnapshot(uuid.NewString(), image),
-		containerd.WithRuntime(opts.Runtime.Name, opts.Runtime.Options),
-		containerd.WithContainerLabels(opts.Labels),
+		containerd.WithRuntime(opts.Runtime.Name, runtimeOptions),
+		containerd.WithContainerLabels(r.withOwner(opts.Labels)),
 		containerd.WithNewSpec(specOpts...),
 	)
 	if err != nil {
@@ -231,6 +580,82 @@ func (r *Repo) CreateContainer(ctx context.Context, opts CreateContainerOpts) (_
 		}
 	}()
 
+	if !r.config.Rootless {
+		if err := addCNINetwork(namespaceCtx, containerID, networkNs.Path, r.config.Bridge); err != nil {
+			return "", err
+		}
+
+		if !isVM && (len(opts.IngressRules) > 0 || opts.EgressPolicy != nil || opts.MetadataPort != 0 || opts.TrackConnections) {
+			firewall := network.ContainerFirewall{
+				Ingress:          opts.IngressRules,
+				Egress:           opts.EgressPolicy,
+				EgressProxyPort:  opts.EgressProxyPort,
+				MetadataPort:     opts.MetadataPort,
+				TrackConnections: opts.TrackConnections,
+			}
+
+			if opts.EgressPolicy != nil {
+				if firewall.Resolvers, err = containerResolvers(opts.DNS); err != nil {
+					return "", err
+				}
+			}
+
+			if err := applyFirewall(networkNs.Path, firewall); err != nil {
+				return "", err
+			}
+		}
+
+		if opts.CountTraffic {
+			err := ns.WithNetNSPath(networkNs.Path, func(_ ns.NetNS) error {
+				return network.AttachTrafficCounters("eth0", r.trafficPinPath(containerID))
+			})
+			if err != nil {
+				log.WithContext(ctx).WithError(err).Warnf("failed to count the traffic of container %s with eBPF, falling back to its interface counters", containerID)
+			}
+		}
+	}
+
+	task, err := container.NewTask(namespaceCtx, opts.CioCreator)
+	if err != nil {
+		return "", fmt.Errorf("failed to start task for container %s: %w", containerID, err)
+	}
+
+	defer func() {
+		if retErr != nil {
+			if _, err := task.Delete(namespaceCtx); err != nil {
+				retErr = multierror.Append(retErr, err)
+			}
+		}
+	}()
+
+	if r.config.Rootless {
+		if err := r.startNetwork(containerID, task.Pid(), forwarded); err != nil {
+			return "", err
+		}
+
+		defer func() {
+			if retErr != nil {
+				r.stopNetwork(containerID)
+			}
+		}()
+	}
+
+	if len(opts.InitSteps) > 0 {
+		go r.runInitSteps(namespaceCtx, containerID, task, networkNs, opts)
+
+		return containerID, nil
+	}
+
+	if err := r.startPod(namespaceCtx, containerID, task, networkNs, opts); err != nil {
+		return "", err
+	}
+
+	return containerID, nil
+}
+
+// addCNINetwork connects the network namespace of a container to the
+// host network, through a point-to-point link or the bridge if one is set
+func addCNINetwork(ctx context.Context, containerID, netNsPath, bridge string) error {
 	ptpConfig := `
       {
         "type": "ptp",
@@ -245,55 +670,354 @@ func (r *Repo) CreateContainer(ctx context.Context, opts CreateContainerOpts) (_
         }
       }
     `
+	// Workloads on the bridge reach each other directly, on a subnet
+	// of their own so both networks can be used on the same host
+	bridgeConfig := fmt.Sprintf(`
+      {
+        "type": "bridge",
+        "bridge": %q,
+        "isGateway": true,
+        "ipMasq": true,
+        "ipam": {
+          "type": "host-local",
+          "subnet": "192.168.128.0/24",
+          "resolvConf": "/etc/resolv.conf",
+          "routes": [
+            { "dst": "0.0.0.0/0" }
+          ]
+        }
+      }
+    `, bridge)
 	firewallConfig := `{"type": "firewall"}`
-	tapConfig := `{"type": "tc-redirect-tap"}`
 
 	cniPlugins := []*libcni.NetworkConfig{
 		{Network: &types.NetConf{Type: "ptp"}, Bytes: []byte(ptpConfig)},
 		{Network: &types.NetConf{Type: "firewall"}, Bytes: []byte(firewallConfig)},
 	}
+	networkName := "hypercore-cni"
 
-	if opts.Runtime.Name == "hypercore.example" {
-		cniPlugins = append(cniPlugins, &libcni.NetworkConfig{Network: &types.NetConf{Type: "tc-redirect-tap"}, Bytes: []byte(tapConfig)})
+	if bridge != "" {
+		cniPlugins[0] = &libcni.NetworkConfig{Network: &types.NetConf{Type: "bridge"}, Bytes: []byte(bridgeConfig)}
+		networkName = "hypercore-bridge"
 	}
 
-	_, err = libcni.NewCNIConfig([]string{"/opt/hypercore/bin", "/opt/cni/bin"}, nil).AddNetworkList(
-		namespaceCtx, &libcni.NetworkConfigList{
-			Name:       "hypercore-cni",
+	_, err := libcni.NewCNIConfig([]string{"/opt/hypercore/bin", "/opt/cni/bin"}, nil).AddNetworkList(
+		ctx, &libcni.NetworkConfigList{
+			Name:       networkName,
 			CNIVersion: "0.4.0",
 			Plugins:    cniPlugins,
 		}, &libcni.RuntimeConf{
 			ContainerID: containerID,
-			NetNS:       netNs.GetPath(),
+			NetNS:       netNsPath,
 			IfName:      "eth0",
 		},
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to add CNI network list: %w", err)
+		return fmt.Errorf("failed to add CNI network list: %w", err)
 	}
 
-	task, err := container.NewTask(namespaceCtx, opts.CioCreator)
+	return nil
+}
+
+// applyFirewall loads the firewall of a container in its network namespace
+func applyFirewall(netNsPath string, firewall network.ContainerFirewall) error {
+	return ns.WithNetNSPath(netNsPath, func(_ ns.NetNS) error {
+		var err error
+		if firewall.Gateway, err = network.DefaultGateway(); err != nil {
+			return err
+		}
+
+		ruleset, err := network.ContainerRuleset(firewall)
+		if err != nil || ruleset == "" {
+			return err
+		}
+
+		if firewall.TrackConnections {
+			if err := network.EnableConntrackAccounting(); err != nil {
+				return err
+			}
+		}
+
+		return network.ApplyRuleset(ruleset)
+	})
+}
+
+// IngressRuleset returns the nftables ruleset active in the network
+// namespace of a container
+func (r *Repo) IngressRuleset(ctx context.Context, id string) (string, error) {
+	netNsPath, err := r.containerNetNsPath(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("failed to start task for container %s: %w", containerID, err)
+		return "", err
 	}
 
-	defer func() {
-		if retErr != nil {
-			if _, err := task.Delete(namespaceCtx); err != nil {
-				retErr = multierror.Append(retErr, err)
+	var ruleset string
+
+	err = ns.WithNetNSPath(netNsPath, func(_ ns.NetNS) error {
+		ruleset, err = network.ListRuleset()
+
+		return err
+	})
+
+	return ruleset, err
+}
+
+// ConnectionFlows returns the connections tracked in the network namespace
+// of a container, which needs TrackConnections for them to be
+func (r *Repo) ConnectionFlows(ctx context.Context, id string) ([]network.Flow, error) {
+	netNsPath, err := r.containerNetNsPath(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []network.Flow
+
+	err = ns.WithNetNSPath(netNsPath, func(_ ns.NetNS) error {
+		flows, err = network.ListFlows()
+
+		return err
+	})
+
+	return flows, err
+}
+
+// TrafficCounters returns the traffic of a container counted with eBPF,
+// which needs CountTraffic for it to be
+func (r *Repo) TrafficCounters(_ context.Context, id string) (network.TrafficCounters, error) {
+	return network.ReadTrafficCounters(r.trafficPinPath(id))
+}
+
+// trafficPinPath returns where the traffic counters of a container are
+// pinned, so they outlive the agent
+func (r *Repo) trafficPinPath(containerID string) string {
+	return filepath.Join(network.BPFRoot, "hypercore", r.config.ContainerNamespace, containerID)
+}
+
+// containerNetNsPath returns the path of the network namespace of a
+// container from its spec
+func (r *Repo) containerNetNsPath(ctx context.Context, id string) (string, error) {
+	container, err := r.GetContainer(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get container %s: %w", id, err)
+	}
+
+	spec, err := container.Spec(namespaces.WithNamespace(ctx, r.config.ContainerNamespace))
+	if err != nil {
+		return "", fmt.Errorf("failed to get spec of container %s: %w", id, err)
+	}
+
+	netNsPath := ""
+	if spec.Linux != nil {
+		for _, namespace := range spec.Linux.Namespaces {
+			if namespace.Type == specs.NetworkNamespace {
+				netNsPath = namespace.Path
 			}
 		}
+	}
+
+	if netNsPath == "" {
+		return "", fmt.Errorf("container %s has no network namespace of its own", id)
+	}
+
+	return netNsPath, nil
+}
+
+// PullImage pulls and unpacks the image ahead of the containers using
+// it, with the default snapshotter
+func (r *Repo) PullImage(ctx context.Context, ref string) error {
+	_, err := r.pulls.pull(namespaces.WithNamespace(ctx, r.config.ContainerNamespace), ref, "", nil)
+
+	return err
+}
+
+// ExecContainer runs a command in the task of a container with the
+// environment of the task, returning its exit status
+func (r *Repo) ExecContainer(ctx context.Context, containerID string, args []string) (uint32, error) {
+	return r.exec(ctx, containerID, args, nil)
+}
+
+// ExecStreams runs a command like ExecContainer, its stdin read from stdin
+// until EOF and its stdout and stderr written to stdout and stderr. It
+// returns once the process exited and its output was copied
+func (r *Repo) ExecStreams(ctx context.Context, containerID string, args []string, stdin io.Reader, stdout, stderr io.Writer) (uint32, error) {
+	streams := &execStreams{stdin: stdin, stdout: stdout, stderr: stderr, stdinDone: make(chan struct{})}
+	if stdin != nil {
+		streams.stdin = &eofNotifier{Reader: stdin, done: streams.stdinDone}
+	}
+
+	return r.exec(ctx, containerID, args, streams)
+}
+
+type execStreams struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	// closed once stdin is drained, to close the stdin of the process
+	stdinDone chan struct{}
+}
+
+// eofNotifier closes done once the reader returned EOF
+type eofNotifier struct {
+	io.Reader
+	done chan struct{}
+	once sync.Once
+}
+
+func (e *eofNotifier) Read(p []byte) (int, error) {
+	n, err := e.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		e.once.Do(func() { close(e.done) })
+	}
+
+	return n, err
+}
+
+func (r *Repo) exec(ctx context.Context, containerID string, args []string, streams *execStreams) (uint32, error) {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	container, err := r.client.LoadContainer(namespaceCtx, containerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load container %s: %w", containerID, err)
+	}
+
+	if err := r.checkOwned(namespaceCtx, container); err != nil {
+		return 0, err
+	}
+
+	spec, err := container.Spec(namespaceCtx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get spec of container %s: %w", containerID, err)
+	}
+
+	task, err := container.Task(namespaceCtx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	pspec := *spec.Process
+	pspec.Args = args
+	pspec.Terminal = false
+
+	creator := cio.NullIO
+	if streams != nil {
+		creator = cio.NewCreator(cio.WithFIFODir(defaults.StateRootDir+"/fifo"), cio.WithStreams(streams.stdin, streams.stdout, streams.stderr))
+	}
+
+	process, err := task.Exec(namespaceCtx, uuid.NewString(), &pspec, creator)
+	if err != nil {
+		return 0, fmt.Errorf("failed to exec in container %s: %w", containerID, err)
+	}
+	defer func() {
+		// ctx may be done already
+		if _, err := process.Delete(namespaces.WithNamespace(context.Background(), r.config.ContainerNamespace), containerd.WithProcessKill); err != nil {
+			log.WithError(err).Warnf("failed to delete exec process %s of container %s", process.ID(), containerID)
+		}
 	}()
 
-	err = task.Start(namespaceCtx)
+	statusC, err := process.Wait(namespaceCtx)
 	if err != nil {
-		return "", fmt.Errorf("failed to start task for container %s: %w", containerID, err)
+		return 0, fmt.Errorf("failed to get exit status chan for exec process %s: %w", process.ID(), err)
 	}
 
-	return containerID, nil
+	if err := process.Start(namespaceCtx); err != nil {
+		return 0, fmt.Errorf("failed to start exec process %s: %w", process.ID(), err)
+	}
+
+	if streams != nil && streams.stdin != nil {
+		exited := make(chan struct{})
+		defer close(exited)
+
+		go func() {
+			select {
+			case <-streams.stdinDone:
+				if err := process.CloseIO(namespaceCtx, containerd.WithStdinCloser); err != nil {
+					log.WithError(err).Warnf("failed to close stdin of exec process %s", process.ID())
+				}
+			case <-exited:
+			}
+		}()
+	}
+
+	select {
+	case status := <-statusC:
+		code, _, err := status.Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get exit status: %w", err)
+		}
+
+		if streams != nil {
+			process.IO().Wait()
+		}
+
+		return code, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("exec process %s of container %s: %w", process.ID(), containerID, ctx.Err())
+	}
 }
 
-func (r *Repo) DeleteContainer(ctx context.Context, containerID string) (uint32, error) {
+// KillContainer sends a signal to the task of a container
+func (r *Repo) KillContainer(ctx context.Context, containerID string, signal syscall.Signal) error {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	container, err := r.client.LoadContainer(namespaceCtx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %w", containerID, err)
+	}
+
+	if err := r.checkOwned(namespaceCtx, container); err != nil {
+		return err
+	}
+
+	task, err := container.Task(namespaceCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	if err := task.Kill(namespaceCtx, signal); err != nil {
+		return fmt.Errorf("failed to kill task of container %s: %w", containerID, err)
+	}
+
+	return nil
+}
+
+// UpdateContainerResources updates the CPU and memory limits of a running container
+func (r *Repo) UpdateContainerResources(ctx context.Context, containerID string, cpuFraction float64, memoryBytes uint64) error {
+	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
+
+	container, err := r.client.LoadContainer(namespaceCtx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %w", containerID, err)
+	}
+
+	if err := r.checkOwned(namespaceCtx, container); err != nil {
+		return err
+	}
+
+	task, err := container.Task(namespaceCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	// runc rewrites the unified values the container was created with
+	// on updates, the new ones have to be unified values as well
+	resources, err := (&cgroup.Limits{CPUFraction: cpuFraction, MemoryBytes: memoryBytes}).Resources(r.cgroupMode, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := task.Update(namespaceCtx, containerd.WithResources(resources)); err != nil {
+		return fmt.Errorf("failed to update task resources for container %s: %w", containerID, err)
+	}
+
+	return nil
+}
+
+// DeleteContainer sends SIGTERM to the task of a container, then SIGKILL
+// if it is still running after stopTimeout (DefaultStopTimeout if 0),
+// and deletes the container along with its pod containers
+func (r *Repo) DeleteContainer(ctx context.Context, containerID string, stopTimeout time.Duration) (uint32, error) {
+	if stopTimeout == 0 {
+		stopTimeout = DefaultStopTimeout
+	}
+
 	namespaceCtx := namespaces.WithNamespace(ctx, r.config.ContainerNamespace)
 
 	container, err := r.client.LoadContainer(namespaceCtx, containerID)
@@ -301,6 +1025,10 @@ func (r *Repo) DeleteContainer(ctx context.Context, containerID string) (uint32,
 		return 0, fmt.Errorf("failed to load container %s: %w", containerID, err)
 	}
 
+	if err := r.checkOwned(namespaceCtx, container); err != nil {
+		return 0, err
+	}
+
 	task, err := container.Task(namespaceCtx, nil)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get task: %w", err)
@@ -324,7 +1052,7 @@ func (r *Repo) DeleteContainer(ctx context.Context, containerID string) (uint32,
 	select {
 	case status := <-statusC:
 		code, _, err = status.Result()
-	case <-time.After(time.Second * 5):
+	case <-time.After(stopTimeout):
 		if err := task.Kill(namespaceCtx, syscall.SIGKILL); err != nil {
 			return 0, fmt.Errorf("failed to kill task: %w", err)
 		}
@@ -346,5 +1074,16 @@ func (r *Repo) DeleteContainer(ctx context.Context, containerID string) (uint32,
 		return 0, fmt.Errorf("failed to delete container %s: %w", containerID, err)
 	}
 
+	r.stopNetwork(containerID)
+	r.removeNetFiles(containerID)
+
+	if err := os.Remove(r.trafficPinPath(containerID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.WithContext(ctx).WithError(err).Warnf("failed to remove the traffic counters of container %s", containerID)
+	}
+
+	if err := r.deletePodContainers(namespaceCtx, containerID); err != nil {
+		return 0, err
+	}
+
 	return code, nil
 }
@@ -0,0 +1,83 @@
+package containerd
+
+import (
+	"bytes"
+	"sync"
+
+	"vistara-node/pkg/defaults"
+
+	"github.com/containerd/containerd/cio"
+)
+
+// RingBuffer keeps the last bytes written to it up to its size, so the
+// output of chatty processes doesn't grow the memory of the client
+type RingBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	size int
+	// Offset of the oldest byte once the buffer wrapped
+	start   int
+	dropped int64
+}
+
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{size: max(size, 0)}
+}
+
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	written := len(p)
+
+	if len(r.data) < r.size {
+		take := min(r.size-len(r.data), len(p))
+		r.data = append(r.data, p[:take]...)
+		p = p[take:]
+	}
+
+	if r.size == 0 {
+		r.dropped += int64(len(p))
+
+		return written, nil
+	}
+
+	// Overwrites the oldest bytes, only the last size bytes of p matter
+	if len(p) > r.size {
+		r.dropped += int64(len(p) - r.size)
+		p = p[len(p)-r.size:]
+	}
+
+	for len(p) > 0 {
+		n := copy(r.data[r.start:], p)
+		r.dropped += int64(n)
+		r.start = (r.start + n) % r.size
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Bytes returns the bytes kept, oldest first
+func (r *RingBuffer) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append(bytes.Clone(r.data[r.start:]), r.data[:r.start]...)
+}
+
+// Dropped returns the number of bytes overwritten by newer ones
+func (r *RingBuffer) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.dropped
+}
+
+// BufferedIO creates the IO of tasks nothing reads the output of as it is
+// written, keeping the last bytes of their stdout and stderr in ring
+// buffers of the size and closing their stdin
+func BufferedIO(size int) cio.Creator {
+	return cio.NewCreator(cio.WithFIFODir(defaults.StateRootDir+"/fifo"),
+		cio.WithStreams(&bytes.Buffer{}, NewRingBuffer(size), NewRingBuffer(size)))
+}
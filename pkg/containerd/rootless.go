@@ -0,0 +1,351 @@
+package containerd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// User-mode network stacks rootless workloads can use
+const (
+	RootlessNetworkSlirp4netns = "slirp4netns"
+	RootlessNetworkPasta       = "pasta"
+)
+
+const (
+	// Label holding the loopback ports forwarded to the ports of a
+	// rootless workload
+	ForwardedPortsLabel = "hypercore-forwarded-ports"
+
+	// How long slirp4netns has to create its API socket
+	slirp4netnsStartTimeout = time.Second * 5
+)
+
+// RootlessSupport describes what the host lets rootless workloads do
+type RootlessSupport struct {
+	// Capability gaps preventing rootless workloads from running at all
+	Gaps []string
+	// Limitations rootless workloads run with
+	Warnings []string
+	// Whether the cpu and memory cgroup controllers are delegated to the
+	// user, workloads run without resource limits otherwise
+	CgroupLimits bool
+}
+
+// Err returns an error listing the capability gaps, nil if there are none
+func (s *RootlessSupport) Err() error {
+	if len(s.Gaps) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("cannot run rootless:\n  - %s", strings.Join(s.Gaps, "\n  - "))
+}
+
+// CheckRootless detects the capabilities needed to run workloads without
+// root: user namespaces with subordinate IDs, a user-mode network stack
+// and, for resource limits and microVMs, cgroup delegation and /dev/kvm
+func CheckRootless(network string) *RootlessSupport {
+	support := &RootlessSupport{}
+
+	if os.Geteuid() == 0 {
+		support.Warnings = append(support.Warnings, "running as root, rootless mode isn't needed")
+	}
+
+	if value, err := readSysctl("/proc/sys/user/max_user_namespaces"); err == nil && value == "0" {
+		support.Gaps = append(support.Gaps, "user namespaces are disabled, set the user.max_user_namespaces sysctl")
+	}
+
+	// Debian and Ubuntu kernels can restrict user namespaces to root
+	if value, err := readSysctl("/proc/sys/kernel/unprivileged_userns_clone"); err == nil && value == "0" {
+		support.Gaps = append(support.Gaps, "unprivileged user namespaces are disabled, set the kernel.unprivileged_userns_clone sysctl to 1")
+	}
+
+	if current, err := user.Current(); err != nil {
+		support.Gaps = append(support.Gaps, fmt.Sprintf("cannot look up the current user: %s", err))
+	} else {
+		for _, file := range []string{"/etc/subuid", "/etc/subgid"} {
+			if !hasSubordinateIDs(file, current) {
+				support.Gaps = append(support.Gaps, fmt.Sprintf("no subordinate IDs for %s in %s, add a range with usermod --add-subuids/--add-subgids", current.Username, file))
+			}
+		}
+	}
+
+	switch network {
+	case RootlessNetworkSlirp4netns, RootlessNetworkPasta:
+		if _, err := exec.LookPath(network); err != nil {
+			support.Gaps = append(support.Gaps, fmt.Sprintf("%s not found in PATH, install it or pick another rootless network", network))
+		}
+	default:
+		support.Gaps = append(support.Gaps, fmt.Sprintf("unknown rootless network %q, expected %s or %s", network, RootlessNetworkSlirp4netns, RootlessNetworkPasta))
+	}
+
+	support.CgroupLimits = delegatedControllers(os.Getuid())
+	if !support.CgroupLimits {
+		support.Warnings = append(support.Warnings, "the cpu and memory cgroup controllers aren't delegated to the user (cgroup v2 with systemd Delegate=yes), workloads run without resource limits")
+	}
+
+	if err := unix.Access("/dev/kvm", unix.R_OK|unix.W_OK); err != nil {
+		support.Warnings = append(support.Warnings, "no read-write access to /dev/kvm, microVM workloads can't run, add the user to the kvm group")
+	}
+
+	return support
+}
+
+func readSysctl(path string) (string, error) {
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(value)), nil
+}
+
+// hasSubordinateIDs returns whether file (/etc/subuid or /etc/subgid)
+// holds a range for the user, by name or ID
+func hasSubordinateIDs(file string, current *user.User) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 {
+			continue
+		}
+
+		if (fields[0] == current.Username || fields[0] == current.Uid) && fields[2] != "0" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// delegatedControllers returns whether systemd delegates the cpu and
+// memory controllers to the user manager of uid
+func delegatedControllers(uid int) bool {
+	path := fmt.Sprintf("/sys/fs/cgroup/user.slice/user-%d.slice/user@%d.service/cgroup.controllers", uid, uid)
+
+	controllers, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(controllers))
+
+	return containsAll(fields, "cpu", "memory")
+}
+
+func containsAll(values []string, wanted ...string) bool {
+	for _, want := range wanted {
+		found := false
+
+		for _, value := range values {
+			found = found || value == want
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ForwardedPorts returns the loopback ports forwarded to the ports of a
+// rootless workload from its container labels, nil for other workloads
+func ForwardedPorts(labels map[string]string) map[uint32]uint32 {
+	value, ok := labels[ForwardedPortsLabel]
+	if !ok {
+		return nil
+	}
+
+	var forwarded map[uint32]uint32
+	if err := json.Unmarshal([]byte(value), &forwarded); err != nil {
+		return nil
+	}
+
+	return forwarded
+}
+
+// allocatePorts picks a free loopback port for each of the container
+// ports
+func allocatePorts(ports []uint32) (map[uint32]uint32, error) {
+	forwarded := make(map[uint32]uint32, len(ports))
+
+	for _, port := range ports {
+		if _, ok := forwarded[port]; ok {
+			continue
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate a port to forward to %d: %w", port, err)
+		}
+
+		//nolint:forcetypeassert
+		forwarded[port] = uint32(listener.Addr().(*net.TCPAddr).Port)
+		listener.Close()
+	}
+
+	return forwarded, nil
+}
+
+// startNetwork connects the network namespace of a rootless task to the
+// host through the user-mode network stack, the stack is stopped along
+// with the agent
+func (r *Repo) startNetwork(containerID string, pid uint32, forwarded map[uint32]uint32) error {
+	userNs := fmt.Sprintf("/proc/%d/ns/user", pid)
+	netNs := fmt.Sprintf("/proc/%d/ns/net", pid)
+
+	var cmd *exec.Cmd
+
+	switch r.config.RootlessNetwork {
+	case RootlessNetworkPasta:
+		args := []string{"--foreground", "--quiet", "--config-net", "--userns", userNs, "--netns", netNs, "-u", "none", "-T", "none", "-U", "none"}
+		if len(forwarded) == 0 {
+			args = append(args, "-t", "none")
+		}
+
+		for port, hostPort := range forwarded {
+			args = append(args, "-t", fmt.Sprintf("127.0.0.1/%d:%d", hostPort, port))
+		}
+
+		cmd = exec.Command(RootlessNetworkPasta, args...)
+	default:
+		if err := os.MkdirAll(filepath.Dir(r.slirpSocketPath(containerID)), 0o700); err != nil {
+			return fmt.Errorf("failed to create slirp4netns socket dir: %w", err)
+		}
+
+		cmd = exec.Command(RootlessNetworkSlirp4netns, "--configure", "--mtu=65520", "--disable-host-loopback",
+			"--api-socket", r.slirpSocketPath(containerID), "--userns-path="+userNs, "--netns-type=path", netNs, "eth0")
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.WithError(err).Debugf("network of container %s exited", containerID)
+		}
+	}()
+
+	r.networksMu.Lock()
+	r.networks[containerID] = cmd
+	r.networksMu.Unlock()
+
+	if r.config.RootlessNetwork == RootlessNetworkPasta {
+		return nil
+	}
+
+	for port, hostPort := range forwarded {
+		if err := r.slirpForward(containerID, port, hostPort); err != nil {
+			r.stopNetwork(containerID)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopNetwork stops the user-mode network stack of a rootless container
+func (r *Repo) stopNetwork(containerID string) {
+	r.networksMu.Lock()
+	cmd, ok := r.networks[containerID]
+	delete(r.networks, containerID)
+	r.networksMu.Unlock()
+
+	if ok {
+		_ = cmd.Process.Kill()
+		_ = os.Remove(r.slirpSocketPath(containerID))
+	}
+}
+
+func (r *Repo) slirpSocketPath(containerID string) string {
+	return filepath.Join(RootlessRuntimeDir(), "hypercore", "slirp4netns-"+containerID+".sock")
+}
+
+// slirpForward forwards a loopback port to a port of the container
+// through the API socket of slirp4netns
+func (r *Repo) slirpForward(containerID string, port, hostPort uint32) error {
+	socketPath := r.slirpSocketPath(containerID)
+
+	var conn net.Conn
+
+	deadline := time.Now().Add(slirp4netnsStartTimeout)
+	for {
+		var err error
+		if conn, err = net.Dial("unix", socketPath); err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("failed to connect to slirp4netns of container %s: %w", containerID, err)
+		}
+
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	defer conn.Close()
+
+	request := map[string]any{
+		"execute": "add_hostfwd",
+		"arguments": map[string]any{
+			"proto":      "tcp",
+			"host_addr":  "127.0.0.1",
+			"host_port":  hostPort,
+			"guest_port": port,
+		},
+	}
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return fmt.Errorf("failed to forward port %d of container %s: %w", port, containerID, err)
+	}
+
+	var response struct {
+		Error *struct {
+			Desc string `json:"desc"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return fmt.Errorf("failed to forward port %d of container %s: %w", port, containerID, err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("failed to forward port %d of container %s: %s", port, containerID, response.Error.Desc)
+	}
+
+	return nil
+}
+
+// RootlessRuntimeDir returns the directory holding the runtime state of
+// the user, $XDG_RUNTIME_DIR
+func RootlessRuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(os.TempDir(), "hypercore-"+strconv.Itoa(os.Getuid()))
+}
+
+var errRootlessNetNs = errors.New("the network namespace of rootless workloads can't be entered")
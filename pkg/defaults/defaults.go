@@ -1,5 +1,7 @@
 package defaults
 
+import "time"
+
 const (
 	// ContainerdNamespace is the name of the namespace to use with containerd.
 	ContainerdNamespace = "vistara"
@@ -13,9 +15,62 @@ const (
 	// StateRootDir is the default directory to use for state information.
 	StateRootDir = "/run/hypercore"
 
+	// DataRootDir is the directory holding the containerd state and
+	// snapshots.
+	DataRootDir = "/var/lib/hypercore"
+
+	// PoolDir is the directory holding the snapshots of the warm pool.
+	PoolDir = "/var/lib/hypercore/pool"
+
+	// CrashDir is the directory the shims write the crash bundles of the
+	// VMs that exited unexpectedly to.
+	CrashDir = StateRootDir + "/shim/crash"
+
+	// MetricsPushConfigFile is the push export config of the node metrics
+	// written by the agent, the shims push their metrics along if it exists.
+	MetricsPushConfigFile = StateRootDir + "/metrics-push.json"
+
+	// CrashEventTopic is the containerd event topic the shims publish the
+	// crash bundle of a VM on, along with the TaskExit event of its task.
+	CrashEventTopic = "/hypercore/tasks/crash"
+
+	// VMCrashedEventTopic is the containerd event topic the shims publish
+	// the failures of the guest kernels detected on the console on.
+	VMCrashedEventTopic = "/hypercore/tasks/vm-crashed"
+
 	// DataDirPerm is the permissions to use for data folders.
 	DataDirPerm = 0o755
 
 	// DataFilePerm is the permissions to use for data files.
 	DataFilePerm = 0o644
+
+	// GrpcMaxMessageSize is the default size limit of the cluster gRPC
+	// messages, List responses of large clusters exceed the 4MB of gRPC.
+	GrpcMaxMessageSize = 64 << 20
+)
+
+// Defaults shared by the cluster agent and the commands driving it, which
+// are built for hosts the agent doesn't run on
+const (
+	// StopTimeout is how long a container has to exit after SIGTERM
+	// before it is killed.
+	StopTimeout = time.Second * 5
+
+	// InitStepTimeout is how long an init step can run before it is
+	// killed and fails.
+	InitStepTimeout = time.Minute * 5
+
+	// BulkParallelism is the number of workloads handled at once by a
+	// bulk request that sets no parallelism, up to MaxBulkParallelism.
+	BulkParallelism    = 4
+	MaxBulkParallelism = 64
+
+	// MetricsHistoryPeriod is how often each node records the metrics of
+	// the cluster as it sees them, kept for MetricsHistoryRetention.
+	MetricsHistoryPeriod    = time.Minute
+	MetricsHistoryRetention = time.Hour * 6
+
+	// CanaryAnalysis is how long a canary must stay healthy before it is
+	// promoted.
+	CanaryAnalysis = time.Minute * 5
 )
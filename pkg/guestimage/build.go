@@ -0,0 +1,240 @@
+// Package guestimage builds the guest images the microVMs boot from: a
+// base root filesystem along with the guest agent, runc, the kernel
+// modules of the guest kernel and the boot files rendered from templates
+package guestimage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"vistara-node/pkg/defaults"
+)
+
+// Formats of the guest images
+const (
+	// Read-only root filesystem the VMs boot from as /dev/vda, under the
+	// tmpfs overlay of /sbin/overlay-init
+	FormatSquashfs = "squashfs"
+	// gzipped newc cpio archive, for guest kernels booting from an
+	// initramfs such as one built in with CONFIG_INITRAMFS_SOURCE
+	FormatInitramfs = "initramfs"
+)
+
+// MksquashfsBin packs the squashfs images
+const MksquashfsBin = "mksquashfs"
+
+// Paths of the binaries in the guest
+const (
+	AgentPath = "/usr/local/bin/agent"
+	RuncPath  = "/usr/local/bin/runc"
+)
+
+// Directories the agent and the boot of the guest expect, the mount points
+// of the overlay and of the container rootfs among them
+var imageDirs = []string{
+	"/dev", "/proc", "/sys", "/tmp", "/run", "/var", "/mnt",
+	"/etc/systemd/system/firecracker.target.wants",
+	"/container/rootfs", "/agent", "/rom", "/overlay",
+	"/usr/local/bin",
+}
+
+// Config of a guest image build
+type Config struct {
+	// Tarball, optionally gzipped, of the root filesystem the image is
+	// built on, which must provide systemd as /usr/sbin/init, e.g. a
+	// debootstrap minbase with the systemd-sysv package
+	BaseRootfs string
+	// Guest agent, speaking the protocol of the shim
+	AgentBin  string
+	AgentArgs []string
+	// runc the agent runs the container with, statically linked unless
+	// the base provides its libraries
+	RuncBin string
+	// Modules of the guest kernel, /lib/modules/VERSION, copied to the
+	// same path in the image, empty for none
+	ModulesDir string
+	// Modules loaded on boot, from ModulesDir or the base
+	Modules []string
+	// Hostname of the guests
+	Hostname string
+	// Directory of templates rendered into the image along with the
+	// builtin ones, which they override. A .tmpl suffix is stripped
+	TemplateDir string
+	// Variables of the templates, as .Vars
+	Vars map[string]string
+	// FormatSquashfs or FormatInitramfs
+	Format string
+	Output string
+}
+
+func (c *Config) validate() error {
+	switch c.Format {
+	case FormatSquashfs, FormatInitramfs:
+	default:
+		return fmt.Errorf("unsupported image format %q, expected %s or %s", c.Format, FormatSquashfs, FormatInitramfs)
+	}
+
+	for name, path := range map[string]string{"base rootfs": c.BaseRootfs, "agent": c.AgentBin, "runc": c.RuncBin} {
+		if path == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	if c.Output == "" {
+		return errors.New("output is required")
+	}
+
+	return nil
+}
+
+// Build lays out the root filesystem of the guest image in a staging
+// directory and packs it into the output
+func Build(cfg *Config) error {
+	if cfg.Hostname == "" {
+		cfg.Hostname = "microvm"
+	}
+
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	root, err := os.MkdirTemp(filepath.Dir(cfg.Output), ".guestimage-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+
+	if err := extractRootfs(cfg.BaseRootfs, root); err != nil {
+		return fmt.Errorf("failed to extract base rootfs %s: %w", cfg.BaseRootfs, err)
+	}
+
+	if init, err := imagePath(root, "/usr/sbin/init"); err != nil {
+		return err
+	} else if _, err := os.Lstat(init); err != nil {
+		return fmt.Errorf("base rootfs %s has no /usr/sbin/init, it must provide systemd", cfg.BaseRootfs)
+	}
+
+	for _, dir := range imageDirs {
+		path, err := imagePath(root, dir)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(path, defaults.DataDirPerm); err != nil {
+			return err
+		}
+	}
+
+	for path, bin := range map[string]string{AgentPath: cfg.AgentBin, RuncPath: cfg.RuncBin} {
+		if err := copyFile(bin, root, path, 0o755); err != nil {
+			return fmt.Errorf("failed to install %s: %w", bin, err)
+		}
+	}
+
+	if cfg.ModulesDir != "" {
+		if err := copyTree(cfg.ModulesDir, root, path.Join("/lib/modules", filepath.Base(cfg.ModulesDir))); err != nil {
+			return fmt.Errorf("failed to install kernel modules: %w", err)
+		}
+	}
+
+	if err := renderTemplates(cfg, root); err != nil {
+		return err
+	}
+
+	switch cfg.Format {
+	case FormatInitramfs:
+		return writeInitramfs(root, cfg.Output)
+	default:
+		// The files are owned by root in the guest whoever builds
+		// the image
+		output, err := exec.Command(MksquashfsBin, root, cfg.Output, "-noappend", "-all-root").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to pack %s: %w: %s", cfg.Output, err, output)
+		}
+
+		return nil
+	}
+}
+
+// copyFile copies src to the path of the guest dst in the staged root
+func copyFile(src, root, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dst, err = imagePath(root, dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), defaults.DataDirPerm); err != nil {
+		return err
+	}
+
+	// The base may already provide the file, as a symlink among others
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+
+		return err
+	}
+
+	return out.Close()
+}
+
+// copyTree copies the regular files, directories and symlinks of src to
+// the directory of the guest dst in the staged root
+func copyTree(src, root, dst string) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+
+		guestPath := path.Join(dst, filepath.ToSlash(rel))
+
+		target, err := imagePath(root, guestPath)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(file, root, guestPath, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
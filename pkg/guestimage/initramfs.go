@@ -0,0 +1,183 @@
+package guestimage
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	cpioNewcMagic = "070701"
+	cpioTrailer   = "TRAILER!!!"
+)
+
+// writeInitramfs packs root into a gzipped newc cpio archive, the format
+// the kernel unpacks initramfs from. The files are owned by root in the
+// guest whoever builds the image, as with mksquashfs -all-root
+func writeInitramfs(root, output string) error {
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(f)
+	cpio := &cpioWriter{w: gzipWriter}
+
+	err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || file == root {
+			return err
+		}
+
+		name, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+
+		return cpio.writeFile(filepath.ToSlash(name), file, info)
+	})
+	if err == nil {
+		err = cpio.writeHeader(cpioTrailer, 0, 0, 0, 0)
+	}
+
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to pack %s: %w", output, err)
+	}
+
+	return nil
+}
+
+type cpioWriter struct {
+	w       io.Writer
+	written int64
+	// Inodes are numbered in the archive, hardlinks are stored as
+	// copies
+	ino uint32
+}
+
+func (c *cpioWriter) writeFile(name, file string, info os.FileInfo) error {
+	var rdev uint64
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		rdev = stat.Rdev
+	}
+
+	mode := uint32(info.Mode().Perm())
+	if info.Mode()&os.ModeSetuid != 0 {
+		mode |= unix.S_ISUID
+	}
+
+	if info.Mode()&os.ModeSetgid != 0 {
+		mode |= unix.S_ISGID
+	}
+
+	if info.Mode()&os.ModeSticky != 0 {
+		mode |= unix.S_ISVTX
+	}
+
+	var data []byte
+
+	switch {
+	case info.IsDir():
+		mode |= unix.S_IFDIR
+	case info.Mode()&os.ModeSymlink != 0:
+		mode |= unix.S_IFLNK
+
+		link, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+
+		data = []byte(link)
+	case info.Mode().IsRegular():
+		mode |= unix.S_IFREG
+	case info.Mode()&os.ModeCharDevice != 0:
+		mode |= unix.S_IFCHR
+	case info.Mode()&os.ModeDevice != 0:
+		mode |= unix.S_IFBLK
+	case info.Mode()&os.ModeNamedPipe != 0:
+		mode |= unix.S_IFIFO
+	default:
+		// Sockets aren't meaningful in an image
+		return nil
+	}
+
+	size := int64(len(data))
+	if info.Mode().IsRegular() {
+		size = info.Size()
+	}
+
+	if err := c.writeHeader(name, mode, size, info.ModTime().Unix(), rdev); err != nil {
+		return err
+	}
+
+	if info.Mode().IsRegular() {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		n, err := io.CopyN(c.w, f, size)
+		c.written += n
+
+		if err != nil {
+			return err
+		}
+	} else if err := c.write(data); err != nil {
+		return err
+	}
+
+	return c.pad()
+}
+
+func (c *cpioWriter) writeHeader(name string, mode uint32, size, mtime int64, rdev uint64) error {
+	nlink := 1
+	if mode&unix.S_IFMT == unix.S_IFDIR {
+		nlink = 2
+	}
+
+	var ino uint32
+	if name != cpioTrailer {
+		c.ino++
+		ino = c.ino
+	}
+
+	header := fmt.Sprintf("%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		cpioNewcMagic, ino, mode, 0, 0, nlink, mtime, size,
+		0, 0, unix.Major(rdev), unix.Minor(rdev), len(name)+1, 0)
+
+	if err := c.write(append([]byte(header+name), 0)); err != nil {
+		return err
+	}
+
+	return c.pad()
+}
+
+func (c *cpioWriter) write(b []byte) error {
+	n, err := c.w.Write(b)
+	c.written += int64(n)
+
+	return err
+}
+
+// pad aligns the archive on 4 bytes, after the name and the data of each
+// entry
+func (c *cpioWriter) pad() error {
+	if rem := c.written % 4; rem != 0 {
+		return c.write(make([]byte, 4-rem))
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package guestimage
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/containerd/containerd/archive"
+	"github.com/containerd/containerd/archive/compression"
+	"github.com/containerd/continuity/fs"
+)
+
+// extractRootfs unpacks the base tarball into root the way containerd
+// applies image layers, which keeps its links and ownership within root
+func extractRootfs(tarball, root string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := compression.DecompressStream(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = archive.Apply(context.Background(), root, r)
+
+	return err
+}
+
+// imagePath resolves a path of the guest in the staged root, following
+// the symlinks of its parents as the guest would rather than to the host,
+// e.g. /sbin being an absolute link to /usr/sbin. A symlink at the path
+// itself isn't followed, the files written there replace it
+func imagePath(root, guestPath string) (string, error) {
+	dir, err := fs.RootPath(root, path.Dir(guestPath))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, path.Base(guestPath)), nil
+}
@@ -0,0 +1,124 @@
+package guestimage
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"vistara-node/pkg/defaults"
+)
+
+// Builtin templates, those of rootfs are rendered into every image and
+// those of initramfs only into initramfs images
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+const templateSuffix = ".tmpl"
+
+// Rendered files run by the kernel and systemd, the others aren't
+// executable
+var executableTemplates = map[string]struct{}{
+	"sbin/overlay-init":                   {},
+	"usr/local/sbin/hypercore-resolvconf": {},
+	"init":                                {},
+}
+
+// templateData is what the templates are rendered with
+type templateData struct {
+	Hostname  string
+	AgentArgs []string
+	Modules   []string
+	Vars      map[string]string
+}
+
+// renderTemplates renders the builtin templates and those of the
+// template directory of the build into root
+func renderTemplates(cfg *Config, root string) error {
+	data := templateData{
+		Hostname:  cfg.Hostname,
+		AgentArgs: cfg.AgentArgs,
+		Modules:   cfg.Modules,
+		Vars:      cfg.Vars,
+	}
+
+	dirs := []string{"templates/rootfs"}
+	if cfg.Format == FormatInitramfs {
+		dirs = append(dirs, "templates/initramfs")
+	}
+
+	for _, dir := range dirs {
+		sub, err := fs.Sub(builtinTemplates, dir)
+		if err != nil {
+			return err
+		}
+
+		if err := renderTemplateFS(sub, root, data); err != nil {
+			return err
+		}
+	}
+
+	if cfg.TemplateDir == "" {
+		return nil
+	}
+
+	if err := renderTemplateFS(os.DirFS(cfg.TemplateDir), root, data); err != nil {
+		return fmt.Errorf("failed to render templates of %s: %w", cfg.TemplateDir, err)
+	}
+
+	return nil
+}
+
+func renderTemplateFS(templates fs.FS, root string, data templateData) error {
+	return fs.WalkDir(templates, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		contents, err := fs.ReadFile(templates, path)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(path).Option("missingkey=error").Parse(string(contents))
+		if err != nil {
+			return fmt.Errorf("invalid template %s: %w", path, err)
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			return fmt.Errorf("failed to render template %s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(path, templateSuffix)
+
+		perm := os.FileMode(defaults.DataFilePerm)
+		if _, ok := executableTemplates[name]; ok {
+			perm = 0o755
+		} else if info, err := entry.Info(); err == nil && info.Mode().Perm()&0o111 != 0 {
+			perm = 0o755
+		}
+
+		target, err := imagePath(root, "/"+name)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), defaults.DataDirPerm); err != nil {
+			return err
+		}
+
+		// The base may provide the file as a symlink, e.g. to a file
+		// outside of the image
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return os.WriteFile(target, rendered.Bytes(), perm)
+	})
+}
@@ -1,13 +1,13 @@
 package cloudhypervisor
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
 	"vistara-node/pkg/models"
 	"vistara-node/pkg/network"
 	"vistara-node/pkg/ports"
@@ -47,6 +47,18 @@ func (c *Service) Start(_ context.Context, vm *models.MicroVM, completionFn func
 		return errors.New("missing fields from model")
 	}
 
+	if vm.Spec.Confidential != "" {
+		if err := checkConfidentialHost(vm.Spec.Confidential); err != nil {
+			return err
+		}
+	}
+
+	if vm.Spec.NestedVirt {
+		if err := shared.CheckNestedVirt(); err != nil {
+			return err
+		}
+	}
+
 	vmState := NewState(vm.ID, c.config.StateRoot, c.fs)
 
 	if err := c.ensureState(vmState); err != nil {
@@ -82,31 +94,44 @@ func (c *Service) startMicroVM(vm *models.MicroVM, vmState *State, completionFn
 	ip[3] = 1
 	routeIP := ip.String()
 
-	kernelCmdLine.Set("ip", fmt.Sprintf("%s::%s:%s::eth0::%s", ifaceIP, routeIP, network.MaskToString(ip.DefaultMask()), "1.1.1.1"))
+	kernelCmdLine.SetGuestNetwork(ifaceIP, routeIP, network.MaskToString(ip.DefaultMask()), vm.Spec.DNS)
+	kernelCmdLine.SetExtraHosts(vm.Spec.ExtraHosts)
 
 	args := []string{
 		"--log-file",
 		vmState.LogPath(),
 		"-v",
+		// The API is only used to pause and resume the VM
+		"--api-socket", vmState.APISocketPath(),
 		"--serial", "tty",
 		"--console", "off",
 		"--cmdline", kernelCmdLine.String(),
 		// 3 is the first unreserved CID
 		"--vsock", fmt.Sprintf("cid=%d,socket=%s", 3, c.VSockPath(vm)),
 		"--kernel", vm.Spec.Kernel,
-		"--cpus", fmt.Sprintf("boot=%d", vm.Spec.VCPU),
+		"--cpus", cpusArg(vm),
 		"--memory", fmt.Sprintf("size=%dM", vm.Spec.MemoryInMb),
-		"--disk", fmt.Sprintf("path=%s,readonly=on", vm.Spec.RootfsPath), fmt.Sprintf("path=%s", vm.Spec.ImagePath),
 		"--net", fmt.Sprintf("tap=%s,mac=%s,ip=%s,mask=%s",
 			"tap0",
 			mac.String(),
 			ifaceIP,
 			network.MaskToString(ip.DefaultMask())),
+		"--disk", fmt.Sprintf("path=%s,readonly=on", vm.Spec.RootfsPath), fmt.Sprintf("path=%s", vm.Spec.ImagePath),
 	}
+	args = append(args, diskArgs(vm.Spec.Disks)...)
 
-	stdOutFile, err := c.fs.OpenFile(vmState.StdoutPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaults.DataFilePerm)
-	if err != nil {
-		return nil, fmt.Errorf("opening stdout file %s: %w", vmState.StdoutPath(), err)
+	if !vm.Spec.DisableEntropy {
+		args = append(args, "--rng", "src=/dev/urandom")
+	}
+
+	// The firmware, kernel and command line are measured by the CPU,
+	// recorded for the tenants to verify the attestation reports
+	if vm.Spec.Confidential != "" {
+		args = append(args, confidentialArgs(vm)...)
+
+		if err := c.measureLaunch(vm, vmState, kernelCmdLine.String()); err != nil {
+			return nil, err
+		}
 	}
 
 	stdErrFile, err := c.fs.OpenFile(vmState.StderrPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaults.DataFilePerm)
@@ -114,22 +139,62 @@ func (c *Service) startMicroVM(vm *models.MicroVM, vmState *State, completionFn
 		return nil, fmt.Errorf("opening sterr file %s: %w", vmState.StderrPath(), err)
 	}
 
+	// With --serial tty the serial device is the stdin and stdout of the
+	// process
+	console, err := shared.NewSerialConsole(c.fs, vmState.StdoutPath(), vmState.ConsoleSocketPath())
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command(c.config.CloudHypervisorBin, args...)
 
 	cmd.Stderr = stdErrFile
-	cmd.Stdout = stdOutFile
-	cmd.Stdin = &bytes.Buffer{}
+	cmd.Stdout = console
+	cmd.Stdin = console.Stdin()
 
 	if err = cmd.Start(); err != nil {
+		console.Close()
+
 		return nil, fmt.Errorf("starting cloudhypervisor process: %w", err)
 	}
 
 	// Reap the process
-	go func() { completionFn(cmd.Wait()) }()
+	go func() {
+		err := cmd.Wait()
+		console.Close()
+		completionFn(err)
+	}()
 
 	return cmd.Process, nil
 }
 
+func cpusArg(vm *models.MicroVM) string {
+	arg := fmt.Sprintf("boot=%d", vm.Spec.VCPU)
+	if vm.Spec.NestedVirt {
+		arg += ",nested=on"
+	}
+
+	return arg
+}
+
+// diskArgs returns the values of --disk attaching the additional disks of a
+// VM after the rootfs and the image. cloud-hypervisor detects qcow2 images
+// by itself and always performs the flushes requested by the guest
+func diskArgs(disks []models.Disk) []string {
+	args := make([]string, 0, len(disks))
+
+	for _, disk := range disks {
+		arg := "path=" + disk.Path
+		if disk.ReadOnly {
+			arg += ",readonly=on"
+		}
+
+		args = append(args, arg)
+	}
+
+	return args
+}
+
 func (c *Service) ensureState(vmState *State) error {
 	if err := c.fs.MkdirAll(vmState.Root(), defaults.DataDirPerm); err != nil {
 		return fmt.Errorf("creating state directory %s: %w", vmState.Root(), err)
@@ -174,3 +239,22 @@ func (c *Service) Pid(_ context.Context, vm *models.MicroVM) (int, error) {
 func (c *Service) VSockPath(vm *models.MicroVM) string {
 	return NewState(vm.ID, c.config.StateRoot, c.fs).VSockPath()
 }
+
+// The serial console is attached to the stdout of the VMM process
+func (c *Service) ConsolePath(vm *models.MicroVM) string {
+	return NewState(vm.ID, c.config.StateRoot, c.fs).StdoutPath()
+}
+
+func (c *Service) ConsoleSocketPath(vm *models.MicroVM) string {
+	return NewState(vm.ID, c.config.StateRoot, c.fs).ConsoleSocketPath()
+}
+
+func (c *Service) CrashFiles(vm *models.MicroVM) map[string]string {
+	vmState := NewState(vm.ID, c.config.StateRoot, c.fs)
+
+	return map[string]string{
+		"hypervisor.stderr":   vmState.StderrPath(),
+		"cloudhypervisor.log": vmState.LogPath(),
+		"runtime-state.json":  vmState.runtimeStatePath(),
+	}
+}
@@ -0,0 +1,124 @@
+package cloudhypervisor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
+	"vistara-node/pkg/models"
+
+	"github.com/spf13/afero"
+)
+
+// KVM module parameters telling whether the host can run confidential VMs
+var confidentialHostParams = map[string]string{
+	models.ConfidentialSEVSNP: "/sys/module/kvm_amd/parameters/sev_snp",
+	models.ConfidentialTDX:    "/sys/module/kvm_intel/parameters/tdx",
+}
+
+// LaunchMeasurement lists the inputs of the measured launch of a
+// confidential VM, from which the expected launch measurement of its
+// attestation reports is computed
+type LaunchMeasurement struct {
+	FirmwarePath   string `json:"firmware_path"`
+	FirmwareSHA256 string `json:"firmware_sha256"`
+	KernelPath     string `json:"kernel_path"`
+	KernelSHA256   string `json:"kernel_sha256"`
+	Cmdline        string `json:"cmdline"`
+	VCPU           int32  `json:"vcpu"`
+}
+
+// checkConfidentialHost makes sure KVM has the support of the technology
+// enabled
+func checkConfidentialHost(technology string) error {
+	param, ok := confidentialHostParams[technology]
+	if !ok {
+		return fmt.Errorf("unknown confidential computing technology %q", technology)
+	}
+
+	enabled, err := shared.KVMParamEnabled(param)
+	if err != nil {
+		return fmt.Errorf("host doesn't support %s VMs: %w", technology, err)
+	}
+
+	if !enabled {
+		return fmt.Errorf("host doesn't support %s VMs, %s is disabled", technology, param)
+	}
+
+	return nil
+}
+
+// confidentialArgs returns the arguments launching a confidential VM from
+// its firmware
+func confidentialArgs(vm *models.MicroVM) []string {
+	switch vm.Spec.Confidential {
+	case models.ConfidentialSEVSNP:
+		return []string{"--platform", "sev_snp=on", "--igvm", vm.Spec.Firmware}
+	case models.ConfidentialTDX:
+		return []string{"--platform", "tdx=on", "--firmware", vm.Spec.Firmware}
+	}
+
+	return nil
+}
+
+func (c *Service) measureLaunch(vm *models.MicroVM, vmState *State, cmdline string) error {
+	firmwareSum, err := c.fileSHA256(vm.Spec.Firmware)
+	if err != nil {
+		return err
+	}
+
+	kernelSum, err := c.fileSHA256(vm.Spec.Kernel)
+	if err != nil {
+		return err
+	}
+
+	measurement, err := json.Marshal(&LaunchMeasurement{
+		FirmwarePath:   vm.Spec.Firmware,
+		FirmwareSHA256: firmwareSum,
+		KernelPath:     vm.Spec.Kernel,
+		KernelSHA256:   kernelSum,
+		Cmdline:        cmdline,
+		VCPU:           vm.Spec.VCPU,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal launch measurement: %w", err)
+	}
+
+	return afero.WriteFile(c.fs, vmState.launchMeasurementPath(), measurement, defaults.DataFilePerm)
+}
+
+func (c *Service) fileSHA256(path string) (string, error) {
+	file, err := c.fs.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LaunchMeasurement returns the inputs of the measured launch of a
+// confidential VM
+func (c *Service) LaunchMeasurement(vm *models.MicroVM) (LaunchMeasurement, error) {
+	var measurement LaunchMeasurement
+
+	contents, err := afero.ReadFile(c.fs, NewState(vm.ID, c.config.StateRoot, c.fs).launchMeasurementPath())
+	if err != nil {
+		return measurement, fmt.Errorf("failed to read launch measurement: %w", err)
+	}
+
+	if err := json.Unmarshal(contents, &measurement); err != nil {
+		return measurement, fmt.Errorf("failed to parse launch measurement: %w", err)
+	}
+
+	return measurement, nil
+}
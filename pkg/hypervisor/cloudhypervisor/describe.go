@@ -0,0 +1,151 @@
+package cloudhypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vistara-node/pkg/models"
+)
+
+// vmInfo is the part of the response of vm.info describing the VM
+type vmInfo struct {
+	State  string `json:"state"`
+	Config struct {
+		CPUs struct {
+			BootVCPUs int64 `json:"boot_vcpus"`
+		} `json:"cpus"`
+		Memory struct {
+			Size int64 `json:"size"`
+		} `json:"memory"`
+		Disks []struct {
+			ID          string             `json:"id"`
+			Path        string             `json:"path"`
+			ReadOnly    bool               `json:"readonly"`
+			RateLimiter *rateLimiterConfig `json:"rate_limiter_config"`
+		} `json:"disks"`
+		Net []struct {
+			ID          string             `json:"id"`
+			Tap         string             `json:"tap"`
+			MAC         string             `json:"mac"`
+			RateLimiter *rateLimiterConfig `json:"rate_limiter_config"`
+		} `json:"net"`
+		Balloon *struct {
+			Size int64 `json:"size"`
+		} `json:"balloon"`
+		Vsock *struct {
+			ID     string `json:"id"`
+			Socket string `json:"socket"`
+		} `json:"vsock"`
+	} `json:"config"`
+}
+
+type rateLimiterConfig struct {
+	Bandwidth *tokenBucket `json:"bandwidth"`
+	Ops       *tokenBucket `json:"ops"`
+}
+
+type tokenBucket struct {
+	Size         int64 `json:"size"`
+	OneTimeBurst int64 `json:"one_time_burst"`
+	RefillTime   int64 `json:"refill_time"`
+}
+
+// Describe returns the configuration of the VM reported by vm.info, along
+// with the counters of its devices
+func (c *Service) Describe(ctx context.Context, vm *models.MicroVM) (*models.MicroVMDescription, error) {
+	body, err := c.apiCall(ctx, vm, http.MethodGet, "vm.info")
+	if err != nil {
+		return nil, err
+	}
+
+	var info vmInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("parsing vm.info: %w", err)
+	}
+
+	var raw struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("parsing vm.info: %w", err)
+	}
+
+	desc := &models.MicroVMDescription{
+		State:     info.State,
+		VCPU:      info.Config.CPUs.BootVCPUs,
+		MemoryMiB: info.Config.Memory.Size >> 20,
+		Config:    raw.Config,
+	}
+
+	if info.Config.Balloon != nil {
+		desc.BalloonMiB = info.Config.Balloon.Size >> 20
+	}
+
+	for _, disk := range info.Config.Disks {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind:     models.DeviceKindDrive,
+			ID:       disk.ID,
+			Path:     disk.Path,
+			ReadOnly: disk.ReadOnly,
+			Limits:   rateLimits(disk.RateLimiter),
+		})
+	}
+
+	for _, net := range info.Config.Net {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind:     models.DeviceKindNet,
+			ID:       net.ID,
+			Path:     net.Tap,
+			GuestMAC: net.MAC,
+			Limits:   rateLimits(net.RateLimiter),
+		})
+	}
+
+	if vsock := info.Config.Vsock; vsock != nil {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind: models.DeviceKindVsock,
+			ID:   vsock.ID,
+			Path: vsock.Socket,
+		})
+	}
+
+	counters, err := c.apiCall(ctx, vm, http.MethodGet, "vm.counters")
+	if err != nil {
+		return nil, err
+	}
+
+	if json.Valid(counters) {
+		desc.Metrics = counters
+	}
+
+	return desc, nil
+}
+
+// rateLimits returns the buckets of a rate limiter, which cloud-hypervisor
+// applies to both directions of the network devices
+func rateLimits(limiter *rateLimiterConfig) []models.RateLimit {
+	if limiter == nil {
+		return nil
+	}
+
+	var limits []models.RateLimit
+
+	for _, limit := range []struct {
+		kind   string
+		bucket *tokenBucket
+	}{{"bandwidth", limiter.Bandwidth}, {"ops", limiter.Ops}} {
+		if limit.bucket == nil {
+			continue
+		}
+
+		limits = append(limits, models.RateLimit{
+			Kind:         limit.kind,
+			Size:         limit.bucket.Size,
+			OneTimeBurst: limit.bucket.OneTimeBurst,
+			RefillTimeMs: limit.bucket.RefillTime,
+		})
+	}
+
+	return limits
+}
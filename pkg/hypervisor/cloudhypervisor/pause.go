@@ -0,0 +1,71 @@
+package cloudhypervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"vistara-node/pkg/models"
+)
+
+func (c *Service) Pause(ctx context.Context, vm *models.MicroVM) error {
+	if err := c.apiRequest(ctx, vm, "vm.pause"); err != nil {
+		return fmt.Errorf("pausing VM: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Service) Resume(ctx context.Context, vm *models.MicroVM) error {
+	if err := c.apiRequest(ctx, vm, "vm.resume"); err != nil {
+		return fmt.Errorf("resuming VM: %w", err)
+	}
+
+	return nil
+}
+
+// apiRequest calls an action of the REST API of cloud-hypervisor, served on
+// its API socket
+func (c *Service) apiRequest(ctx context.Context, vm *models.MicroVM, action string) error {
+	_, err := c.apiCall(ctx, vm, http.MethodPut, action)
+
+	return err
+}
+
+// apiCall sends a request to the REST API of cloud-hypervisor, returning
+// the body of the response
+func (c *Service) apiCall(ctx context.Context, vm *models.MicroVM, method, action string) ([]byte, error) {
+	socketPath := NewState(vm.ID, c.config.StateRoot, c.fs).APISocketPath()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost/api/v1/"+action, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+		return nil, fmt.Errorf("%s returned %s: %s", action, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -47,6 +47,14 @@ func (s *State) VSockPath() string {
 	return fmt.Sprintf("%s/cloudhypervisor.vsock", s.stateRoot)
 }
 
+func (s *State) APISocketPath() string {
+	return fmt.Sprintf("%s/cloudhypervisor.sock", s.stateRoot)
+}
+
+func (s *State) ConsoleSocketPath() string {
+	return fmt.Sprintf("%s/console.sock", s.stateRoot)
+}
+
 func (s *State) LogPath() string {
 	return fmt.Sprintf("%s/%s", s.stateRoot, "cloudhypervisor.log")
 }
@@ -63,6 +71,10 @@ func (s *State) SetPid(pid int) error {
 	return shared.PIDWriteToFile(pid, s.PIDPath(), s.fs)
 }
 
+func (s *State) launchMeasurementPath() string {
+	return fmt.Sprintf("%s/%s", s.stateRoot, "launch-measurement.json")
+}
+
 func (s *State) runtimeStatePath() string {
 	return fmt.Sprintf("%s/%s", s.stateRoot, "runtime-state.json")
 }
@@ -0,0 +1,180 @@
+package firecracker
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"vistara-node/pkg/hypervisor/shared"
+	"vistara-node/pkg/models"
+
+	"github.com/spf13/afero"
+)
+
+// Guest architectures, firecracker only runs guests of the architecture
+// of the host
+const (
+	ArchAMD64 = "x86_64"
+	ArchARM64 = "aarch64"
+)
+
+// Magic of the header of arm64 kernel Images, at offset 56
+var arm64ImageMagic = []byte("ARM\x64")
+
+// HostArch returns the guest architecture matching the host, empty if
+// firecracker doesn't run on it
+func HostArch() string {
+	return hostArch
+}
+
+// resolveArch returns the architecture of the guest, the one of the host
+// if arch is empty
+func resolveArch(arch string) (string, error) {
+	if hostArch == "" {
+		return "", fmt.Errorf("firecracker doesn't run on %s hosts", runtime.GOARCH)
+	}
+
+	switch arch {
+	case "":
+		return hostArch, nil
+	case ArchAMD64, ArchARM64:
+	default:
+		return "", fmt.Errorf("unknown guest architecture %q, expected %s or %s", arch, ArchAMD64, ArchARM64)
+	}
+
+	if arch != hostArch {
+		return "", fmt.Errorf("cannot run %s guest on %s host", arch, hostArch)
+	}
+
+	return arch, nil
+}
+
+// Static CPU templates of firecracker by guest architecture
+var cpuTemplates = map[string][]string{
+	ArchAMD64: {"C3", "T2", "T2S", "T2CL", "T2A"},
+	ArchARM64: {"V1N1"},
+}
+
+func machineConfig(vm *models.MicroVM, arch string) MachineConfig {
+	config := MachineConfig{
+		MemSizeMib: int64(vm.Spec.MemoryInMb),
+		VcpuCount:  int64(vm.Spec.VCPU),
+		// Only supported on x86_64
+		SMT: arch == ArchAMD64,
+	}
+
+	if vm.Spec.CPUTemplate != "" {
+		config.CPUTemplate = &vm.Spec.CPUTemplate
+	}
+
+	return config
+}
+
+// cpuConfig returns the custom CPU template applying the CPUID modifiers of
+// the VM, nil without any. Modifiers of the same leaf are grouped
+func cpuConfig(vm *models.MicroVM, arch string) (*CPUConfig, error) {
+	if vm.Spec.CPUTemplate != "" && !slices.Contains(cpuTemplates[arch], vm.Spec.CPUTemplate) {
+		return nil, fmt.Errorf("unknown CPU template %q for %s guests, expected one of %s", vm.Spec.CPUTemplate, arch, strings.Join(cpuTemplates[arch], ", "))
+	}
+
+	if len(vm.Spec.CPUIDModifiers) == 0 {
+		return nil, nil
+	}
+
+	if arch != ArchAMD64 {
+		return nil, fmt.Errorf("CPUID modifiers are not supported for %s guests", arch)
+	}
+
+	if vm.Spec.CPUTemplate != "" {
+		return nil, errors.New("CPUID modifiers can't be combined with a CPU template")
+	}
+
+	config := &CPUConfig{}
+	leaves := make(map[[3]uint32]int)
+
+	for _, modifier := range vm.Spec.CPUIDModifiers {
+		key := [3]uint32{modifier.Leaf, modifier.Subleaf, modifier.Flags}
+
+		i, ok := leaves[key]
+		if !ok {
+			i = len(config.CPUIDModifiers)
+			leaves[key] = i
+
+			config.CPUIDModifiers = append(config.CPUIDModifiers, CPUIDLeafModifier{
+				Leaf:    fmt.Sprintf("0x%x", modifier.Leaf),
+				Subleaf: fmt.Sprintf("0x%x", modifier.Subleaf),
+				Flags:   modifier.Flags,
+			})
+		}
+
+		config.CPUIDModifiers[i].Modifiers = append(config.CPUIDModifiers[i].Modifiers, CPUIDRegisterModifier{
+			Register: modifier.Register,
+			Bitmap:   "0b" + modifier.Bitmap,
+		})
+	}
+
+	return config, nil
+}
+
+// DefaultKernelCmdLine returns the kernel parameters of the guests. On
+// aarch64 the devices are described by the device tree firecracker
+// generates from the VM configuration, so the guest kernel must be built
+// with CONFIG_OF and there is no i8042 controller to configure
+func DefaultKernelCmdLine(arch string) shared.KernelCmdLine {
+	cmdLine := shared.KernelCmdLine{
+		"console":                             "ttyS0",
+		"reboot":                              "k",
+		"panic":                               "1",
+		"pci":                                 "off",
+		"systemd.journald.forward_to_console": "",
+		"systemd.unit":                        "firecracker.target",
+		"init":                                "/sbin/overlay-init",
+	}
+
+	switch arch {
+	case ArchARM64:
+		// Keep the early console of the device tree until ttyS0 is
+		// registered, so early boot failures show up in the logs
+		cmdLine.Set("keep_bootcon", "")
+	default:
+		for _, key := range []string{"i8042.noaux", "i8042.nomux", "i8042.nopnp", "i8042.dumbkbd"} {
+			cmdLine.Set(key, "")
+		}
+	}
+
+	return cmdLine
+}
+
+// checkKernel makes sure the kernel image is in the format firecracker
+// boots on arch: an uncompressed ELF vmlinux on x86_64 and an Image on
+// aarch64
+func checkKernel(fs afero.Fs, path, arch string) error {
+	file, err := fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening kernel %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	header := make([]byte, 64)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return fmt.Errorf("reading kernel %s: %w", path, err)
+	}
+
+	switch arch {
+	case ArchAMD64:
+		if !bytes.HasPrefix(header, []byte(elf.ELFMAG)) {
+			return fmt.Errorf("kernel %s is not an uncompressed vmlinux, required for %s guests", path, arch)
+		}
+	case ArchARM64:
+		if !bytes.Equal(header[56:60], arm64ImageMagic) {
+			return fmt.Errorf("kernel %s is not an arm64 Image, required for %s guests", path, arch)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+//go:build amd64
+
+package firecracker
+
+const hostArch = ArchAMD64
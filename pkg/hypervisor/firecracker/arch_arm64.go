@@ -0,0 +1,5 @@
+//go:build arm64
+
+package firecracker
+
+const hostArch = ArchARM64
@@ -2,8 +2,6 @@ package firecracker
 
 import (
 	"fmt"
-	"runtime"
-	"vistara-node/pkg/hypervisor/shared"
 	"vistara-node/pkg/models"
 	"vistara-node/pkg/network"
 )
@@ -24,15 +22,20 @@ func CreateConfig(opts ...ConfigOption) (*VmmConfig, error) {
 
 func WithMicroVM(vm *models.MicroVM, vsockPath string) ConfigOption {
 	return func(cfg *VmmConfig) error {
+		arch, err := resolveArch(vm.Spec.Arch)
+		if err != nil {
+			return err
+		}
+
 		mac, ip, err := network.GetLinkMacIP("eth0")
 		if err != nil {
 			return fmt.Errorf("failed to get link IP: %w", err)
 		}
 
-		cfg.MachineConfig = MachineConfig{
-			MemSizeMib: int64(vm.Spec.MemoryInMb),
-			VcpuCount:  int64(vm.Spec.VCPU),
-			SMT:        runtime.GOARCH == "amd64",
+		cfg.MachineConfig = machineConfig(vm, arch)
+
+		if cfg.CPUConfig, err = cpuConfig(vm, arch); err != nil {
+			return err
 		}
 
 		cfg.NetDevices = []NetworkInterfaceConfig{
@@ -65,6 +68,17 @@ func WithMicroVM(vm *models.MicroVM, vsockPath string) ConfigOption {
 			},
 		}
 
+		disks, err := diskConfigs(vm.Spec.Disks)
+		if err != nil {
+			return err
+		}
+
+		cfg.BlockDevices = append(cfg.BlockDevices, disks...)
+
+		if !vm.Spec.DisableEntropy {
+			cfg.Entropy = &EntropyDeviceConfig{}
+		}
+
 		cfg.VsockDevice = &VsockDeviceConfig{
 			GuestCID: 0,
 			UDSPath:  vsockPath,
@@ -75,8 +89,9 @@ func WithMicroVM(vm *models.MicroVM, vsockPath string) ConfigOption {
 		ip[3] = 1
 		routeIP := ip.String()
 
-		kernelCmdLine := DefaultKernelCmdLine()
-		kernelCmdLine.Set("ip", fmt.Sprintf("%s::%s:%s::eth0::%s", ifaceIP, routeIP, network.MaskToString(ip.DefaultMask()), "1.1.1.1"))
+		kernelCmdLine := DefaultKernelCmdLine(arch)
+		kernelCmdLine.SetGuestNetwork(ifaceIP, routeIP, network.MaskToString(ip.DefaultMask()), vm.Spec.DNS)
+		kernelCmdLine.SetExtraHosts(vm.Spec.ExtraHosts)
 		kernelArgs := kernelCmdLine.String()
 
 		bootSourceConfig := BootSourceConfig{
@@ -89,22 +104,95 @@ func WithMicroVM(vm *models.MicroVM, vsockPath string) ConfigOption {
 	}
 }
 
-func DefaultKernelCmdLine() shared.KernelCmdLine {
-	return shared.KernelCmdLine{
-		"console":                             "ttyS0",
-		"reboot":                              "k",
-		"panic":                               "1",
-		"pci":                                 "off",
-		"i8042.noaux":                         "",
-		"i8042.nomux":                         "",
-		"i8042.nopnp":                         "",
-		"i8042.dumbkbd":                       "",
-		"systemd.journald.forward_to_console": "",
-		"systemd.unit":                        "firecracker.target",
-		"init":                                "/sbin/overlay-init",
+// WithTemplate configures a VM booted to be snapshotted. The snapshot is
+// restored in other network namespaces, so the guest network is configured
+// through MMDS instead of the kernel command line, and the vsock socket path
+// is relative to the state directory of each restored VM
+func WithTemplate(vm *models.MicroVM, drivePath string) ConfigOption {
+	return func(cfg *VmmConfig) error {
+		arch, err := resolveArch(vm.Spec.Arch)
+		if err != nil {
+			return err
+		}
+
+		cfg.MachineConfig = machineConfig(vm, arch)
+
+		cfg.NetDevices = []NetworkInterfaceConfig{
+			{
+				IfaceID:     "eth0",
+				HostDevName: "tap0",
+				GuestMAC:    vm.Spec.GuestMAC,
+			},
+		}
+
+		cfg.Mmds = &MMDSConfig{
+			Version:           MMDSVersion1,
+			NetworkInterfaces: []string{cfg.NetDevices[0].IfaceID},
+		}
+
+		cfg.BlockDevices = []BlockDeviceConfig{
+			{
+				ID:           "rootfs",
+				IsReadOnly:   true,
+				IsRootDevice: true,
+				PathOnHost:   vm.Spec.RootfsPath,
+				CacheType:    CacheTypeUnsafe,
+			},
+			{
+				ID:           "image",
+				IsReadOnly:   false,
+				IsRootDevice: false,
+				PathOnHost:   drivePath,
+				CacheType:    CacheTypeUnsafe,
+			},
+		}
+
+		cfg.VsockDevice = &VsockDeviceConfig{
+			GuestCID: 0,
+			UDSPath:  templateVSockPath,
+		}
+
+		// Restored VMs keep the devices of the template
+		cfg.Entropy = &EntropyDeviceConfig{}
+
+		kernelCmdLine := DefaultKernelCmdLine(arch)
+		kernelArgs := kernelCmdLine.String()
+		cfg.BootSource = BootSourceConfig{
+			KernelImagePage: vm.Spec.Kernel,
+			BootArgs:        &kernelArgs,
+		}
+
+		return nil
 	}
 }
 
+// diskConfigs returns the drives of the additional disks of a VM, which
+// firecracker only supports in the raw format
+func diskConfigs(disks []models.Disk) ([]BlockDeviceConfig, error) {
+	configs := make([]BlockDeviceConfig, 0, len(disks))
+
+	for i, disk := range disks {
+		if disk.Format != "" && disk.Format != models.DiskFormatRaw {
+			return nil, fmt.Errorf("disk %s: %s images are not supported by firecracker", disk.Path, disk.Format)
+		}
+
+		cacheType := CacheTypeUnsafe
+		if disk.CacheMode == models.DiskCacheWriteBack {
+			cacheType = CacheTypeWriteBack
+		}
+
+		configs = append(configs, BlockDeviceConfig{
+			ID:           fmt.Sprintf("disk%d", i),
+			IsReadOnly:   disk.ReadOnly,
+			IsRootDevice: false,
+			PathOnHost:   disk.Path,
+			CacheType:    cacheType,
+		})
+	}
+
+	return configs, nil
+}
+
 func WithState(vmState *State) ConfigOption {
 	return func(cfg *VmmConfig) error {
 		cfg.Logger = &LoggerConfig{
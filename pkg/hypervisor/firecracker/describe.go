@@ -0,0 +1,158 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"vistara-node/pkg/models"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	fcmodels "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	ops "github.com/firecracker-microvm/firecracker-go-sdk/client/operations"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bytes read from the end of the metrics file for the latest metrics,
+// which firecracker appends as a JSON object per line
+const metricsTailSize = 64 * 1024
+
+// Describe returns the configuration firecracker exports for the VM, along
+// with the metrics it flushes on request
+func (f *Service) Describe(ctx context.Context, vm *models.MicroVM) (*models.MicroVMDescription, error) {
+	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
+	client := firecracker.NewClient(vmState.APISocketPath(), log.NewEntry(log.StandardLogger()), false)
+
+	info, err := client.GetInstanceInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("describing instance: %w", err)
+	}
+
+	exported, err := client.GetExportVMConfig(func(params *ops.GetExportVMConfigParams) { params.SetContext(ctx) })
+	if err != nil {
+		return nil, fmt.Errorf("exporting VM config: %w", err)
+	}
+
+	config := exported.Payload
+
+	desc := &models.MicroVMDescription{State: firecracker.StringValue(info.Payload.State)}
+
+	if desc.Config, err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+
+	if machine := config.MachineConfig; machine != nil {
+		desc.VCPU = firecracker.Int64Value(machine.VcpuCount)
+		desc.MemoryMiB = firecracker.Int64Value(machine.MemSizeMib)
+	}
+
+	if config.Balloon != nil {
+		desc.BalloonMiB = firecracker.Int64Value(config.Balloon.AmountMib)
+	}
+
+	for _, drive := range config.Drives {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind:     models.DeviceKindDrive,
+			ID:       firecracker.StringValue(drive.DriveID),
+			Path:     firecracker.StringValue(drive.PathOnHost),
+			ReadOnly: firecracker.BoolValue(drive.IsReadOnly),
+			Limits:   rateLimits("", drive.RateLimiter),
+		})
+	}
+
+	for _, iface := range config.NetworkInterfaces {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind:     models.DeviceKindNet,
+			ID:       firecracker.StringValue(iface.IfaceID),
+			Path:     firecracker.StringValue(iface.HostDevName),
+			GuestMAC: iface.GuestMac,
+			Limits:   append(rateLimits("rx", iface.RxRateLimiter), rateLimits("tx", iface.TxRateLimiter)...),
+		})
+	}
+
+	if vsock := config.Vsock; vsock != nil {
+		desc.Devices = append(desc.Devices, models.DeviceDescription{
+			Kind: models.DeviceKindVsock,
+			ID:   vsock.VsockID,
+			Path: firecracker.StringValue(vsock.UdsPath),
+		})
+	}
+
+	metricsPath := vmState.MetricsPath()
+	if config.Metrics != nil {
+		metricsPath = firecracker.StringValue(config.Metrics.MetricsPath)
+	}
+
+	if desc.Metrics, err = flushMetrics(ctx, client, metricsPath); err != nil {
+		return nil, err
+	}
+
+	return desc, nil
+}
+
+func rateLimits(direction string, limiter *fcmodels.RateLimiter) []models.RateLimit {
+	if limiter == nil {
+		return nil
+	}
+
+	var limits []models.RateLimit
+
+	for _, limit := range []struct {
+		kind   string
+		bucket *fcmodels.TokenBucket
+	}{{"bandwidth", limiter.Bandwidth}, {"ops", limiter.Ops}} {
+		bucket := limit.bucket
+		if bucket == nil {
+			continue
+		}
+
+		limits = append(limits, models.RateLimit{
+			Direction:    direction,
+			Kind:         limit.kind,
+			Size:         firecracker.Int64Value(bucket.Size),
+			OneTimeBurst: firecracker.Int64Value(bucket.OneTimeBurst),
+			RefillTimeMs: firecracker.Int64Value(bucket.RefillTime),
+		})
+	}
+
+	return limits
+}
+
+// flushMetrics has firecracker write its metrics to its metrics file,
+// returning the line it wrote
+func flushMetrics(ctx context.Context, client *firecracker.Client, path string) (json.RawMessage, error) {
+	action := &fcmodels.InstanceActionInfo{ActionType: firecracker.String(fcmodels.InstanceActionInfoActionTypeFlushMetrics)}
+
+	if _, err := client.CreateSyncAction(ctx, action); err != nil {
+		return nil, fmt.Errorf("flushing metrics: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(info.Size()-metricsTailSize, 0)
+
+	tail, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(tail), []byte("\n"))
+
+	last := lines[len(lines)-1]
+	if !json.Valid(last) {
+		return nil, fmt.Errorf("no metrics in %s", path)
+	}
+
+	return last, nil
+}
@@ -1,13 +1,13 @@
 package firecracker
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
 	"vistara-node/pkg/models"
 	"vistara-node/pkg/ports"
 
@@ -47,6 +47,10 @@ func (f *Service) Start(_ context.Context, vm *models.MicroVM, completionFn func
 		return errors.New("missing fields from model")
 	}
 
+	if err := f.checkKernel(vm); err != nil {
+		return err
+	}
+
 	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
 
 	if err := f.ensureState(vmState); err != nil {
@@ -62,12 +66,16 @@ func (f *Service) Start(_ context.Context, vm *models.MicroVM, completionFn func
 		return fmt.Errorf("saving firecracker config %w", err)
 	}
 	meta := &Metadata{}
+	if vm.Spec.DNS != nil || len(vm.Spec.ExtraHosts) > 0 || vm.Spec.Workload != nil {
+		meta.Hypercore = &GuestMetadata{DNS: vm.Spec.DNS, ExtraHosts: vm.Spec.ExtraHosts, Workload: vm.Spec.Workload}
+	}
 
 	if err = vmState.SetMetadata(meta); err != nil {
 		return fmt.Errorf("saving firecracker metadata %w", err)
 	}
 
-	args := []string{"--boot-timer", "--no-api"}
+	// The API is only used to pause and resume the VM
+	args := []string{"--boot-timer", "--api-sock", vmState.APISocketPath()}
 	args = append(args, "--config-file", vmState.ConfigPath())
 	args = append(args, "--metadata", vmState.MetadataPath())
 
@@ -89,27 +97,45 @@ func (f *Service) Start(_ context.Context, vm *models.MicroVM, completionFn func
 	return nil
 }
 
-func (f *Service) startMicroVM(cmd *exec.Cmd, vmState *State, completionFn func(error)) (*os.Process, error) {
-	stdOutFile, err := f.fs.OpenFile(vmState.StdoutPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaults.DataFilePerm)
+// checkKernel makes sure the kernel of the VM can boot a guest of its
+// architecture
+func (f *Service) checkKernel(vm *models.MicroVM) error {
+	arch, err := resolveArch(vm.Spec.Arch)
 	if err != nil {
-		return nil, fmt.Errorf("opening stdout file %s: %w", vmState.StdoutPath(), err)
+		return err
 	}
 
+	return checkKernel(f.fs, vm.Spec.Kernel, arch)
+}
+
+func (f *Service) startMicroVM(cmd *exec.Cmd, vmState *State, completionFn func(error)) (*os.Process, error) {
 	stdErrFile, err := f.fs.OpenFile(vmState.StderrPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaults.DataFilePerm)
 	if err != nil {
 		return nil, fmt.Errorf("opening sterr file %s: %w", vmState.StderrPath(), err)
 	}
 
+	// The serial device of firecracker is its stdin and stdout
+	console, err := shared.NewSerialConsole(f.fs, vmState.StdoutPath(), vmState.ConsoleSocketPath())
+	if err != nil {
+		return nil, err
+	}
+
 	cmd.Stderr = stdErrFile
-	cmd.Stdout = stdOutFile
-	cmd.Stdin = &bytes.Buffer{}
+	cmd.Stdout = console
+	cmd.Stdin = console.Stdin()
 
 	if err = cmd.Start(); err != nil {
+		console.Close()
+
 		return nil, fmt.Errorf("starting firecracker process: %w", err)
 	}
 
 	// Reap the process
-	go func() { completionFn(cmd.Wait()) }()
+	go func() {
+		err := cmd.Wait()
+		console.Close()
+		completionFn(err)
+	}()
 
 	return cmd.Process, nil
 }
@@ -153,6 +179,15 @@ func (f *Service) VSockPath(vm *models.MicroVM) string {
 	return NewState(vm.ID, f.config.StateRoot, f.fs).VSockPath()
 }
 
+// The serial console is attached to the stdout of the VMM process
+func (f *Service) ConsolePath(vm *models.MicroVM) string {
+	return NewState(vm.ID, f.config.StateRoot, f.fs).StdoutPath()
+}
+
+func (f *Service) ConsoleSocketPath(vm *models.MicroVM) string {
+	return NewState(vm.ID, f.config.StateRoot, f.fs).ConsoleSocketPath()
+}
+
 func (f *Service) Stop(_ context.Context, vm *models.MicroVM) error {
 	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
 
@@ -174,3 +209,24 @@ func (f *Service) Stop(_ context.Context, vm *models.MicroVM) error {
 
 	return retErr
 }
+
+// BalloonConfig returns the balloon device the VM was started with, nil if
+// it has none
+func (f *Service) BalloonConfig(vm *models.MicroVM) (*BalloonDeviceConfig, error) {
+	config, err := NewState(vm.ID, f.config.StateRoot, f.fs).Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Balloon, nil
+}
+
+func (f *Service) CrashFiles(vm *models.MicroVM) map[string]string {
+	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
+
+	return map[string]string{
+		"hypervisor.stderr": vmState.StderrPath(),
+		"firecracker.log":   vmState.LogPath(),
+		"firecracker.cfg":   vmState.ConfigPath(),
+	}
+}
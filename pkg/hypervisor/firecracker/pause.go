@@ -0,0 +1,40 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"vistara-node/pkg/models"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	fcmodels "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	log "github.com/sirupsen/logrus"
+)
+
+func (f *Service) Pause(ctx context.Context, vm *models.MicroVM) error {
+	client := firecracker.NewClient(NewState(vm.ID, f.config.StateRoot, f.fs).APISocketPath(), log.NewEntry(log.StandardLogger()), false)
+
+	if _, err := client.PatchVM(ctx, &fcmodels.VM{State: firecracker.String(fcmodels.VMStatePaused)}); err != nil {
+		return fmt.Errorf("pausing VM: %w", err)
+	}
+
+	return nil
+}
+
+// Resume publishes the time through MMDS before resuming the VM, like for
+// the restored VMs, since the guest clock stood still while it was paused
+func (f *Service) Resume(ctx context.Context, vm *models.MicroVM) error {
+	client := firecracker.NewClient(NewState(vm.ID, f.config.StateRoot, f.fs).APISocketPath(), log.NewEntry(log.StandardLogger()), false)
+
+	timeMetadata := TimeMetadata{UnixNano: time.Now().UnixNano(), PTPDevice: guestPTPDevice}
+
+	if _, err := client.PatchMmds(ctx, map[string]interface{}{"hypercore": map[string]interface{}{"time": timeMetadata}}); err != nil {
+		return fmt.Errorf("publishing guest metadata: %w", err)
+	}
+
+	if _, err := client.PatchVM(ctx, &fcmodels.VM{State: firecracker.String(fcmodels.VMStateResumed)}); err != nil {
+		return fmt.Errorf("resuming VM: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/shared"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	fcmodels "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	// Relative to the working directory of firecracker, which is the state
+	// directory of the VM
+	templateVSockPath = "firecracker.vsock"
+
+	// Size of the drive standing in for the image of restored VMs
+	placeholderDriveSize = 1 << 20
+
+	apiSocketTimeout      = time.Second * 5
+	apiSocketPollInterval = time.Millisecond * 5
+
+	// KVM PTP clock of the guests, CONFIG_PTP_1588_CLOCK_KVM
+	guestPTPDevice = "/dev/ptp0"
+)
+
+// NetworkMetadata is published through MMDS to the VMs restored from a
+// snapshot, which must apply it to eth0 once resumed
+type NetworkMetadata struct {
+	MAC        string `json:"mac"`
+	IP         string `json:"ip"`
+	Gateway    string `json:"gateway"`
+	Netmask    string `json:"netmask"`
+	Nameserver string `json:"nameserver"`
+}
+
+// TimeMetadata is published through MMDS to the VMs restored from a
+// snapshot, whose clock stood still since the template VM was paused. The
+// guest must step its clock to it once resumed, then keep it in sync with
+// the KVM PTP clock of the host
+type TimeMetadata struct {
+	// Wall clock of the host when the VM is resumed
+	UnixNano  int64  `json:"unix_nano"`
+	PTPDevice string `json:"ptp_device"`
+}
+
+func snapshotMemPath(dir string) string {
+	return filepath.Join(dir, "mem")
+}
+
+func snapshotStatePath(dir string) string {
+	return filepath.Join(dir, "vmstate")
+}
+
+func snapshotDrivePath(dir string) string {
+	return filepath.Join(dir, "image.placeholder")
+}
+
+// SnapshotExists returns whether a complete snapshot is present in dir
+func SnapshotExists(dir string) bool {
+	for _, path := range []string{snapshotMemPath(dir), snapshotStatePath(dir), snapshotDrivePath(dir)} {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CreateSnapshot boots a template VM, waits for ready to succeed (i.e. the
+// guest agent to listen), then pauses it and writes its snapshot to dir. The
+// snapshot files are replaced atomically, so VMs can be restored from a
+// previous snapshot in the meantime
+func (f *Service) CreateSnapshot(ctx context.Context, vm *models.MicroVM, dir string, ready func(ctx context.Context, vsockPath string) error) error {
+	if vm.Spec.Kernel == "" || vm.Spec.RootfsPath == "" || vm.Spec.GuestMAC == "" {
+		return errors.New("missing fields from model")
+	}
+
+	if err := f.checkKernel(vm); err != nil {
+		return err
+	}
+
+	if err := f.fs.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+		return fmt.Errorf("creating snapshot directory %s: %w", dir, err)
+	}
+
+	if err := f.ensurePlaceholderDrive(snapshotDrivePath(dir)); err != nil {
+		return err
+	}
+
+	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
+
+	if err := f.ensureState(vmState); err != nil {
+		return fmt.Errorf("ensuring state dir: %w", err)
+	}
+
+	defer func() {
+		if err := vmState.Delete(); err != nil {
+			log.WithError(err).Errorf("failed to delete state of template VM %s", vm.ID)
+		}
+	}()
+
+	config, err := CreateConfig(WithTemplate(vm, snapshotDrivePath(dir)), WithState(vmState))
+	if err != nil {
+		return fmt.Errorf("creating firecracker configuration: %w", err)
+	}
+
+	if err = vmState.SetConfig(config); err != nil {
+		return fmt.Errorf("saving firecracker config %w", err)
+	}
+
+	args := []string{"--api-sock", vmState.APISocketPath(), "--config-file", vmState.ConfigPath()}
+
+	cmd := firecracker.VMCommandBuilder{}.
+		WithBin(f.config.FirecrackerBin).
+		WithArgs(args).
+		Build(context.Background())
+	cmd.Dir = vmState.Root()
+
+	stopped := make(chan struct{})
+
+	proc, err := f.startMicroVM(cmd, vmState, func(error) { close(stopped) })
+	if err != nil {
+		return fmt.Errorf("starting firecracker process %w", err)
+	}
+
+	defer func() {
+		_ = proc.Kill()
+		<-stopped
+	}()
+
+	if err := ready(ctx, vmState.VSockPath()); err != nil {
+		return fmt.Errorf("waiting for template VM: %w", err)
+	}
+
+	client := firecracker.NewClient(vmState.APISocketPath(), log.NewEntry(log.StandardLogger()), false)
+
+	if _, err := client.PatchVM(ctx, &fcmodels.VM{State: firecracker.String(fcmodels.VMStatePaused)}); err != nil {
+		return fmt.Errorf("pausing template VM: %w", err)
+	}
+
+	memPath := snapshotMemPath(dir) + ".tmp"
+	statePath := snapshotStatePath(dir) + ".tmp"
+
+	if _, err := client.CreateSnapshot(ctx, &fcmodels.SnapshotCreateParams{
+		MemFilePath:  firecracker.String(memPath),
+		SnapshotPath: firecracker.String(statePath),
+		SnapshotType: fcmodels.SnapshotCreateParamsSnapshotTypeFull,
+	}); err != nil {
+		return fmt.Errorf("creating snapshot: %w", err)
+	}
+
+	// The state must not be loaded along with the memory of a previous snapshot
+	if err := f.fs.Remove(snapshotStatePath(dir)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing previous snapshot: %w", err)
+	}
+
+	if err := f.fs.Rename(memPath, snapshotMemPath(dir)); err != nil {
+		return fmt.Errorf("moving snapshot memory: %w", err)
+	}
+
+	if err := f.fs.Rename(statePath, snapshotStatePath(dir)); err != nil {
+		return fmt.Errorf("moving snapshot state: %w", err)
+	}
+
+	return nil
+}
+
+// Restore starts a VM from the snapshot in dir instead of booting it, the
+// image drive is swapped for the one of the VM and the network of the
+// current namespace and the time are published through MMDS before
+// resuming it. On failure the VM is stopped and its state removed,
+// without completionFn being called
+func (f *Service) Restore(ctx context.Context, vm *models.MicroVM, dir string, completionFn func(error)) (retErr error) {
+	if vm.Spec.ImagePath == "" {
+		return errors.New("missing fields from model")
+	}
+
+	networkMetadata, err := guestNetwork(vm.Spec.DNS)
+	if err != nil {
+		return err
+	}
+
+	vmState := NewState(vm.ID, f.config.StateRoot, f.fs)
+
+	if err := f.ensureState(vmState); err != nil {
+		return fmt.Errorf("ensuring state dir: %w", err)
+	}
+
+	cmd := firecracker.VMCommandBuilder{}.
+		WithBin(f.config.FirecrackerBin).
+		WithArgs([]string{"--api-sock", vmState.APISocketPath()}).
+		Build(context.Background())
+	cmd.Dir = vmState.Root()
+
+	// A failed restore is cleaned up here rather than reported as the
+	// completion of the VM, so the caller can fall back to booting it
+	var failed atomic.Bool
+	exited := make(chan struct{})
+
+	proc, err := f.startMicroVM(cmd, vmState, func(err error) {
+		if !failed.Load() {
+			completionFn(err)
+		}
+		close(exited)
+	})
+	if err != nil {
+		return fmt.Errorf("starting firecracker process %w", err)
+	}
+
+	defer func() {
+		if retErr != nil {
+			failed.Store(true)
+			_ = proc.Kill()
+			<-exited
+
+			if err := vmState.Delete(); err != nil {
+				log.WithError(err).Errorf("failed to delete state of VM %s", vm.ID)
+			}
+		}
+	}()
+
+	if err = vmState.SetPid(proc.Pid); err != nil {
+		return fmt.Errorf("saving pid %d to file: %w", proc.Pid, err)
+	}
+
+	if err := waitForSocket(ctx, vmState.APISocketPath()); err != nil {
+		return err
+	}
+
+	client := firecracker.NewClient(vmState.APISocketPath(), log.NewEntry(log.StandardLogger()), false)
+
+	if _, err := client.LoadSnapshot(ctx, &fcmodels.SnapshotLoadParams{
+		MemFilePath:  firecracker.String(snapshotMemPath(dir)),
+		SnapshotPath: firecracker.String(snapshotStatePath(dir)),
+	}); err != nil {
+		return fmt.Errorf("loading snapshot %s: %w", dir, err)
+	}
+
+	if _, err := client.PatchGuestDriveByID(ctx, "image", vm.Spec.ImagePath); err != nil {
+		return fmt.Errorf("attaching image drive: %w", err)
+	}
+
+	// Right before resuming the VM, for the guest clock to be stepped as
+	// close as possible to the one of the host
+	timeMetadata := TimeMetadata{UnixNano: time.Now().UnixNano(), PTPDevice: guestPTPDevice}
+
+	guestMetadata := map[string]interface{}{"network": networkMetadata, "time": timeMetadata}
+	if vm.Spec.DNS != nil {
+		guestMetadata["dns"] = vm.Spec.DNS
+	}
+
+	if len(vm.Spec.ExtraHosts) > 0 {
+		guestMetadata["extra_hosts"] = vm.Spec.ExtraHosts
+	}
+
+	if vm.Spec.Workload != nil {
+		guestMetadata["workload"] = vm.Spec.Workload
+	}
+
+	if _, err := client.PutMmds(ctx, map[string]interface{}{"hypercore": guestMetadata}); err != nil {
+		return fmt.Errorf("publishing guest metadata: %w", err)
+	}
+
+	if _, err := client.PatchVM(ctx, &fcmodels.VM{State: firecracker.String(fcmodels.VMStateResumed)}); err != nil {
+		return fmt.Errorf("resuming VM: %w", err)
+	}
+
+	return nil
+}
+
+func (f *Service) ensurePlaceholderDrive(path string) error {
+	if exists, err := afero.Exists(f.fs, path); err != nil || exists {
+		return err
+	}
+
+	file, err := f.fs.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating placeholder drive %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(placeholderDriveSize); err != nil {
+		return fmt.Errorf("resizing placeholder drive %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// guestNetwork returns the network the guest should use, mirroring the
+// kernel command line set by WithMicroVM
+func guestNetwork(dns *models.DNSConfig) (NetworkMetadata, error) {
+	mac, ip, err := network.GetLinkMacIP("eth0")
+	if err != nil {
+		return NetworkMetadata{}, fmt.Errorf("failed to get link IP: %w", err)
+	}
+
+	gateway := make(net.IP, len(ip))
+	copy(gateway, ip)
+	gateway[3] = 1
+
+	nameserver := shared.DefaultNameserver
+	if dns != nil && len(dns.Nameservers) > 0 {
+		nameserver = dns.Nameservers[0]
+	}
+
+	return NetworkMetadata{
+		MAC:        mac.String(),
+		IP:         ip.String(),
+		Gateway:    gateway.String(),
+		Netmask:    network.MaskToString(ip.DefaultMask()),
+		Nameserver: nameserver,
+	}, nil
+}
+
+func waitForSocket(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, apiSocketTimeout)
+	defer cancel()
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for API socket %s: %w", path, ctx.Err())
+		case <-time.After(apiSocketPollInterval):
+		}
+	}
+}
@@ -44,6 +44,14 @@ func (s *State) VSockPath() string {
 	return fmt.Sprintf("%s/firecracker.vsock", s.stateRoot)
 }
 
+func (s *State) APISocketPath() string {
+	return fmt.Sprintf("%s/firecracker.sock", s.stateRoot)
+}
+
+func (s *State) ConsoleSocketPath() string {
+	return fmt.Sprintf("%s/console.sock", s.stateRoot)
+}
+
 func (s *State) LogPath() string {
 	return fmt.Sprintf("%s/firecracker.log", s.stateRoot)
 }
@@ -90,7 +98,8 @@ func (s *State) SetConfig(cfg *VmmConfig) error {
 
 func (s *State) SetMetadata(meta *Metadata) error {
 	decoded := &Metadata{
-		Latest: map[string]string{},
+		Latest:    map[string]string{},
+		Hypercore: meta.Hypercore,
 	}
 
 	// Try to base64 decode values, if we can't decode, use them at they are,
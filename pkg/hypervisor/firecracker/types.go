@@ -1,5 +1,7 @@
 package firecracker
 
+import "vistara-node/pkg/models"
+
 // VmmConfig contains the configuration of the microvm.
 // Based on the rust structure from firecracker:
 // https://github.com/firecracker-microvm/firecracker/blob/0690010524001b606f67c1a65c67f3c27883183f/src/vmm/src/resources.rs#L51.
@@ -22,6 +24,10 @@ type VmmConfig struct {
 	NetDevices []NetworkInterfaceConfig `json:"network-interfaces"`
 	// VsockDevice is the configuration for the vsock device.
 	VsockDevice *VsockDeviceConfig `json:"vsock,omitempty"`
+	// Entropy is the configuration for the virtio-rng device.
+	Entropy *EntropyDeviceConfig `json:"entropy,omitempty"`
+	// CPUConfig is the custom CPU template of the microvm.
+	CPUConfig *CPUConfig `json:"cpu-config,omitempty"`
 }
 
 type MachineConfig struct {
@@ -159,4 +165,53 @@ type VsockDeviceConfig struct {
 // Metadata represents metadata in the MMDS.
 type Metadata struct {
 	Latest map[string]string `json:"latest"`
+	// Published to the guest under /hypercore, like the metadata of
+	// restored VMs
+	Hypercore *GuestMetadata `json:"hypercore,omitempty"`
+}
+
+// GuestMetadata is what the guest reads from MMDS when it boots
+type GuestMetadata struct {
+	// Resolver the guest writes its /etc/resolv.conf from, nil for the
+	// nameserver of the ip kernel argument
+	DNS *models.DNSConfig `json:"dns,omitempty"`
+	// Entries the guest adds to its /etc/hosts
+	ExtraHosts []models.HostEntry `json:"extra_hosts,omitempty"`
+	// Identity of the workload, read by its applications from
+	// /hypercore/workload
+	Workload *models.WorkloadMetadata `json:"workload,omitempty"`
+}
+
+// EntropyDeviceConfig contains the configuration for the virtio-rng device,
+// which feeds the guest with entropy from the host.
+type EntropyDeviceConfig struct {
+	// RateLimiter is the config for rate limiting the entropy requests.
+	// RateLimiter *RateLimiterConfig `json:"rate_limiter"`
+}
+
+// CPUConfig is a custom CPU template, modifying the CPUID of the vCPUs.
+type CPUConfig struct {
+	// CPUIDModifiers are the modifiers of the CPUID leaves.
+	CPUIDModifiers []CPUIDLeafModifier `json:"cpuid_modifiers"`
+}
+
+// CPUIDLeafModifier modifies the registers of a CPUID leaf.
+type CPUIDLeafModifier struct {
+	// Leaf is the CPUID leaf, in hexadecimal.
+	Leaf string `json:"leaf"`
+	// Subleaf is the CPUID subleaf, in hexadecimal.
+	Subleaf string `json:"subleaf"`
+	// Flags are the KVM CPUID entry flags.
+	Flags uint32 `json:"flags"`
+	// Modifiers are the modifiers of the registers of the leaf.
+	Modifiers []CPUIDRegisterModifier `json:"modifiers"`
+}
+
+// CPUIDRegisterModifier sets or clears bits of a CPUID register.
+type CPUIDRegisterModifier struct {
+	// Register is eax, ebx, ecx or edx.
+	Register string `json:"register"`
+	// Bitmap is 0b followed by a 0, 1 or x for each bit from the most
+	// significant one, x keeping the bit of the host.
+	Bitmap string `json:"bitmap"`
 }
@@ -2,7 +2,10 @@ package shared
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"vistara-node/pkg/models"
 )
 
 type KernelCmdLine map[string]string
@@ -24,3 +27,55 @@ func (k *KernelCmdLine) String() string {
 
 	return strings.Join(output, " ")
 }
+
+// DefaultNameserver is the resolver of the guests without a DNS config
+const DefaultNameserver = "1.1.1.1"
+
+// Kernel arguments the guest writes the search domains and the ndots
+// option of its resolv.conf from, the nameservers being in the ip one
+const (
+	DNSSearchArg = "hypercore.dns_search"
+	DNSNdotsArg  = "hypercore.dns_ndots"
+)
+
+// ExtraHostsArg is the kernel argument the guest adds the extra hosts of
+// its /etc/hosts from, as IP/HOSTNAME/HOSTNAME,IP/HOSTNAME
+const ExtraHostsArg = "hypercore.hosts"
+
+// SetGuestNetwork configures eth0 of the guest through the ip argument,
+// along with its resolver. The kernel only takes two nameservers, the
+// search domains are comma separated
+func (k *KernelCmdLine) SetGuestNetwork(ip, gateway, netmask string, dns *models.DNSConfig) {
+	nameservers := []string{DefaultNameserver}
+	if dns != nil && len(dns.Nameservers) > 0 {
+		nameservers = dns.Nameservers[:min(len(dns.Nameservers), 2)]
+	}
+
+	k.Set("ip", fmt.Sprintf("%s::%s:%s::eth0::%s", ip, gateway, netmask, strings.Join(nameservers, ":")))
+
+	if dns == nil {
+		return
+	}
+
+	if len(dns.Searches) > 0 {
+		k.Set(DNSSearchArg, strings.Join(dns.Searches, ","))
+	}
+
+	if dns.Ndots > 0 {
+		k.Set(DNSNdotsArg, strconv.FormatUint(uint64(dns.Ndots), 10))
+	}
+}
+
+// SetExtraHosts passes the extra entries of /etc/hosts to the guest
+func (k *KernelCmdLine) SetExtraHosts(entries []models.HostEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	hosts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		hosts = append(hosts, strings.Join(append([]string{entry.IP}, entry.Hostnames...), "/"))
+	}
+
+	k.Set(ExtraHostsArg, strings.Join(hosts, ","))
+}
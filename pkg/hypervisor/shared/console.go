@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"vistara-node/pkg/defaults"
+
+	"github.com/spf13/afero"
+)
+
+// A client not reading the console output for this long is disconnected
+// rather than holding the VM back
+const consoleWriteTimeout = time.Second
+
+// SerialConsole is wired to the serial device of a VM, it appends the
+// output to the console log and serves the console on a unix socket, the
+// input of the connected clients being written to the serial device
+type SerialConsole struct {
+	log      io.WriteCloser
+	stdinR   *os.File
+	stdinW   *os.File
+	listener net.Listener
+	path     string
+
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+}
+
+// NewSerialConsole creates the console of a VM, its Stdin and the console
+// itself are the stdin and stdout of the hypervisor process
+func NewSerialConsole(fs afero.Fs, logPath, socketPath string) (*SerialConsole, error) {
+	logFile, err := fs.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaults.DataFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("opening console log %s: %w", logPath, err)
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		logFile.Close()
+
+		return nil, fmt.Errorf("creating console input pipe: %w", err)
+	}
+
+	_ = os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		logFile.Close()
+		stdinR.Close()
+		stdinW.Close()
+
+		return nil, fmt.Errorf("listening on console socket %s: %w", socketPath, err)
+	}
+
+	c := &SerialConsole{
+		log:      logFile,
+		stdinR:   stdinR,
+		stdinW:   stdinW,
+		listener: listener,
+		path:     socketPath,
+		clients:  make(map[net.Conn]struct{}),
+	}
+
+	go c.serve()
+
+	return c, nil
+}
+
+func (c *SerialConsole) Stdin() *os.File {
+	return c.stdinR
+}
+
+func (c *SerialConsole) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.clients[conn] = struct{}{}
+		c.mu.Unlock()
+
+		go func() {
+			_, _ = io.Copy(c.stdinW, conn)
+			c.disconnect(conn)
+		}()
+	}
+}
+
+func (c *SerialConsole) disconnect(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.clients, conn)
+	conn.Close()
+}
+
+// Write appends the output of the serial device to the log and sends it to
+// the connected clients
+func (c *SerialConsole) Write(p []byte) (int, error) {
+	n, err := c.log.Write(p)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for conn := range c.clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(consoleWriteTimeout))
+
+		if _, err := conn.Write(p); err != nil {
+			delete(c.clients, conn)
+			conn.Close()
+		}
+	}
+
+	return n, err
+}
+
+// Close disconnects the clients and removes the socket, once the
+// hypervisor process exited
+func (c *SerialConsole) Close() error {
+	errs := []error{c.listener.Close()}
+
+	c.mu.Lock()
+	for conn := range c.clients {
+		delete(c.clients, conn)
+		conn.Close()
+	}
+	c.mu.Unlock()
+
+	errs = append(errs, c.stdinW.Close(), c.stdinR.Close(), c.log.Close())
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KVM module parameters telling whether the host lets its guests run VMs
+// of their own, depending on the vendor of the CPU
+var nestedVirtParams = []string{
+	"/sys/module/kvm_intel/parameters/nested",
+	"/sys/module/kvm_amd/parameters/nested",
+}
+
+// KVMParamEnabled returns whether a boolean parameter of a KVM module is
+// set, Y or 1
+func KVMParamEnabled(param string) (bool, error) {
+	value, err := os.ReadFile(param)
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.TrimSpace(string(value)) {
+	case "Y", "1":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// CheckNestedVirt makes sure KVM lets guests use the virtualization
+// extensions of the host CPU
+func CheckNestedVirt() error {
+	for _, param := range nestedVirtParams {
+		enabled, err := KVMParamEnabled(param)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("host doesn't support nested virtualization: %w", err)
+		}
+
+		if !enabled {
+			return fmt.Errorf("host doesn't support nested virtualization, %s is disabled", param)
+		}
+
+		return nil
+	}
+
+	return errors.New("host doesn't support nested virtualization, neither kvm_intel nor kvm_amd is loaded")
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"maps"
+
+	"vistara-node/pkg/proto/vmoptions"
+)
+
+// MetadataIP is the link-local address workloads reach the metadata
+// service on, MMDS for firecracker VMs
+const MetadataIP = "169.254.169.254"
+
+// WorkloadMetadata is the identity of a workload served by the metadata
+// service, so it doesn't need it injected in its environment
+type WorkloadMetadata struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name,omitempty"`
+	Tenant       string            `json:"tenant,omitempty"`
+	Node         string            `json:"node,omitempty"`
+	ReplicaGroup string            `json:"replica_group,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+func (m *WorkloadMetadata) options() *vmoptions.WorkloadMetadata {
+	return &vmoptions.WorkloadMetadata{
+		Id:           m.ID,
+		Name:         m.Name,
+		Tenant:       m.Tenant,
+		Node:         m.Node,
+		ReplicaGroup: m.ReplicaGroup,
+		Labels:       maps.Clone(m.Labels),
+		Annotations:  maps.Clone(m.Annotations),
+	}
+}
+
+func workloadMetadataFromOptions(options *vmoptions.WorkloadMetadata) *WorkloadMetadata {
+	return &WorkloadMetadata{
+		ID:           options.GetId(),
+		Name:         options.GetName(),
+		Tenant:       options.GetTenant(),
+		Node:         options.GetNode(),
+		ReplicaGroup: options.GetReplicaGroup(),
+		Labels:       maps.Clone(options.GetLabels()),
+		Annotations:  maps.Clone(options.GetAnnotations()),
+	}
+}
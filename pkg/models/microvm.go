@@ -1,5 +1,12 @@
 package models
 
+import (
+	"encoding/json"
+
+	"vistara-node/pkg/proto/vmoptions"
+	"vistara-node/pkg/resource"
+)
+
 type MicroVM struct {
 	ID   string      `json:"id"`
 	Spec MicroVMSpec `json:"spec"`
@@ -14,4 +21,288 @@ type MicroVMSpec struct {
 	RootfsPath string `json:"rootfs_path"  validate:"omitempty"`
 	ImagePath  string `json:"image_path"   validate:"omitempty"`
 	GuestMAC   string `json:"guest_mac"    validate:"omitempty"`
+	Arch       string `json:"arch"         validate:"omitempty,oneof=x86_64 aarch64"`
+	// New connections to the VM are only accepted if they match one
+	// of the rules, all are accepted if there are none
+	IngressRules []IngressRule `json:"ingress_rules,omitempty" validate:"omitempty,dive"`
+	// Block devices attached after the rootfs and the image
+	Disks []Disk `json:"disks,omitempty" validate:"omitempty,dive"`
+	// Leave out the virtio-rng device feeding the guest with entropy from
+	// the host, so workloads doing TLS don't stall at boot without it
+	DisableEntropy bool `json:"disable_entropy,omitempty"`
+	// Pausing the task pauses the whole VM through the hypervisor, so it
+	// stops consuming CPU, rather than freezing the processes of the
+	// container in the guest
+	PauseVM bool `json:"pause_vm,omitempty"`
+	// Static CPU template of firecracker masking the features of the host
+	// CPU, e.g. for the VMs to be migration-compatible across hosts
+	CPUTemplate string `json:"cpu_template,omitempty"`
+	// CPUID bits of the vCPUs set or cleared on top of the ones of the
+	// host, exclusive with CPUTemplate
+	CPUIDModifiers []CPUIDModifier `json:"cpuid_modifiers,omitempty" validate:"omitempty,dive"`
+	// Confidential computing technology encrypting the memory of the VM,
+	// ConfidentialSEVSNP or ConfidentialTDX, none if empty
+	Confidential string `json:"confidential,omitempty" validate:"omitempty,oneof=sev-snp tdx"`
+	// Firmware measured when launching a confidential VM, an IGVM file for
+	// SEV-SNP and TDVF for TDX
+	Firmware string `json:"firmware,omitempty"`
+	// Expose the virtualization extensions of the host CPU, for guests
+	// running VMs of their own
+	NestedVirt bool `json:"nested_virt,omitempty"`
+	// Resolver of the guest, 1.1.1.1 if nil
+	DNS *DNSConfig `json:"dns,omitempty"`
+	// Entries added to the /etc/hosts of the guest
+	ExtraHosts []HostEntry `json:"extra_hosts,omitempty" validate:"omitempty,dive"`
+	// Identity of the workload, served to the guest through MMDS
+	Workload *WorkloadMetadata `json:"workload,omitempty"`
+}
+
+// MicroVMDescription is the configuration of a running microvm as its
+// hypervisor reports it, to tell the spec it was requested with apart
+// from what it actually runs with
+type MicroVMDescription struct {
+	// e.g. Running or Paused
+	State     string
+	VCPU      int64
+	MemoryMiB int64
+	Devices   []DeviceDescription
+	// Size of the balloon, 0 if the VM has none
+	BalloonMiB int64
+	// Configuration and latest metrics as returned by the API of the
+	// hypervisor
+	Config  json.RawMessage
+	Metrics json.RawMessage
+}
+
+// Kinds of the devices of a microvm
+const (
+	DeviceKindDrive = "drive"
+	DeviceKindNet   = "net"
+	DeviceKindVsock = "vsock"
+)
+
+// DeviceDescription is a device of a microvm, with its rate limits
+type DeviceDescription struct {
+	Kind string
+	ID   string
+	// File of the drives, tap device of the network interfaces and unix
+	// socket of the vsock
+	Path     string
+	ReadOnly bool
+	GuestMAC string
+	Limits   []RateLimit
+}
+
+// RateLimit is a token bucket limiting the IO of a device
+type RateLimit struct {
+	// rx or tx for the network interfaces of firecracker, empty when it
+	// limits both
+	Direction string
+	// bandwidth (in bytes) or ops
+	Kind         string
+	Size         int64
+	OneTimeBurst int64
+	RefillTimeMs int64
+}
+
+// Confidential computing technologies of VMs
+const (
+	ConfidentialSEVSNP = "sev-snp"
+	ConfidentialTDX    = "tdx"
+)
+
+// CPUIDModifier sets or clears bits of a register of a CPUID leaf
+type CPUIDModifier struct {
+	Leaf    uint32 `json:"leaf"`
+	Subleaf uint32 `json:"subleaf"`
+	// KVM CPUID entry flags, 1 if the leaf is indexed by the subleaf
+	Flags    uint32 `json:"flags"`
+	Register string `json:"register" validate:"oneof=eax ebx ecx edx"`
+	// 32 characters from the most significant bit: 0 or 1 to clear or set
+	// the bit, x to keep the one of the host
+	Bitmap string `json:"bitmap"`
+}
+
+// Cache modes of the disks of a VM
+const (
+	// Flushes requested by the guest are ignored
+	DiskCacheUnsafe = "unsafe"
+	// Flushes requested by the guest are performed with fsync
+	DiskCacheWriteBack = "writeback"
+)
+
+// Formats of the disks of a VM
+const (
+	DiskFormatRaw   = "raw"
+	DiskFormatQcow2 = "qcow2"
+)
+
+// MaxDisks is the number of disks a VM can have besides its rootfs and
+// image
+const MaxDisks = 16
+
+// Disk is an additional block device of a VM, e.g. a scratch disk or a
+// read-only data volume
+type Disk struct {
+	// File or block device on the host
+	Path     string `json:"path" validate:"required"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	// DiskCacheUnsafe if empty
+	CacheMode string `json:"cache_mode,omitempty" validate:"omitempty,oneof=unsafe writeback"`
+	// DiskFormatRaw if empty, qcow2 images are only supported by
+	// cloudhypervisor
+	Format string `json:"format,omitempty" validate:"omitempty,oneof=raw qcow2"`
+	// Where the ext4 filesystem of the disk is mounted in the workload,
+	// left unmounted if empty
+	MountPath string `json:"mount_path,omitempty"`
+}
+
+// DiskDevice returns the device of the i-th disk of a VM in the guest, the
+// rootfs and the image are /dev/vda and /dev/vdb
+func DiskDevice(i int) string {
+	return "/dev/vd" + string(rune('c'+i))
+}
+
+// LegacyMicroVMSpecTypeURL is the type URL of the JSON encoded MicroVMSpec
+// runtime options of older releases, still accepted by the shim during
+// their deprecation in favor of vmoptions.MicroVMOptions
+const LegacyMicroVMSpecTypeURL = "models.MicroVMSpec"
+
+// Memory returns the memory of the VM. MemoryInMb stays in MiB, the unit
+// of the runtime options and of the JSON spec of older releases
+func (s *MicroVMSpec) Memory() resource.Bytes {
+	return resource.Bytes(s.MemoryInMb) * resource.MiB
+}
+
+// Options returns the runtime options of the containers running the VM
+func (s *MicroVMSpec) Options() *vmoptions.MicroVMOptions {
+	options := &vmoptions.MicroVMOptions{
+		Provider:       s.Provider,
+		Kernel:         s.Kernel,
+		Vcpu:           s.VCPU,
+		MemoryMb:       s.MemoryInMb,
+		HostNetDev:     s.HostNetDev,
+		RootfsPath:     s.RootfsPath,
+		ImagePath:      s.ImagePath,
+		GuestMac:       s.GuestMAC,
+		Arch:           s.Arch,
+		DisableEntropy: s.DisableEntropy,
+		PauseVm:        s.PauseVM,
+		CpuTemplate:    s.CPUTemplate,
+		Confidential:   s.Confidential,
+		Firmware:       s.Firmware,
+		NestedVirt:     s.NestedVirt,
+	}
+
+	if s.DNS != nil {
+		options.Dns = &vmoptions.DNSConfig{
+			Nameservers: s.DNS.Nameservers,
+			Searches:    s.DNS.Searches,
+			Ndots:       s.DNS.Ndots,
+		}
+	}
+
+	for _, entry := range s.ExtraHosts {
+		options.ExtraHosts = append(options.ExtraHosts, &vmoptions.HostEntry{Ip: entry.IP, Hostnames: entry.Hostnames})
+	}
+
+	if s.Workload != nil {
+		options.Workload = s.Workload.options()
+	}
+
+	for _, rule := range s.IngressRules {
+		options.IngressRules = append(options.IngressRules, &vmoptions.IngressRule{
+			SourceCidrs: rule.SourceCIDRs,
+			Ports:       rule.Ports,
+		})
+	}
+
+	for _, disk := range s.Disks {
+		options.Disks = append(options.Disks, &vmoptions.Disk{
+			Path:      disk.Path,
+			ReadOnly:  disk.ReadOnly,
+			CacheMode: disk.CacheMode,
+			Format:    disk.Format,
+			MountPath: disk.MountPath,
+		})
+	}
+
+	for _, modifier := range s.CPUIDModifiers {
+		options.CpuidModifiers = append(options.CpuidModifiers, &vmoptions.CpuidModifier{
+			Leaf:     modifier.Leaf,
+			Subleaf:  modifier.Subleaf,
+			Flags:    modifier.Flags,
+			Register: modifier.Register,
+			Bitmap:   modifier.Bitmap,
+		})
+	}
+
+	return options
+}
+
+// MicroVMSpecFromOptions returns the spec of a VM from the runtime options
+// of its container
+func MicroVMSpecFromOptions(options *vmoptions.MicroVMOptions) MicroVMSpec {
+	spec := MicroVMSpec{
+		Provider:       options.GetProvider(),
+		Kernel:         options.GetKernel(),
+		VCPU:           options.GetVcpu(),
+		MemoryInMb:     options.GetMemoryMb(),
+		HostNetDev:     options.GetHostNetDev(),
+		RootfsPath:     options.GetRootfsPath(),
+		ImagePath:      options.GetImagePath(),
+		GuestMAC:       options.GetGuestMac(),
+		Arch:           options.GetArch(),
+		DisableEntropy: options.GetDisableEntropy(),
+		PauseVM:        options.GetPauseVm(),
+		CPUTemplate:    options.GetCpuTemplate(),
+		Confidential:   options.GetConfidential(),
+		Firmware:       options.GetFirmware(),
+		NestedVirt:     options.GetNestedVirt(),
+	}
+
+	if dns := options.GetDns(); dns != nil {
+		spec.DNS = &DNSConfig{
+			Nameservers: dns.GetNameservers(),
+			Searches:    dns.GetSearches(),
+			Ndots:       dns.GetNdots(),
+		}
+	}
+
+	for _, entry := range options.GetExtraHosts() {
+		spec.ExtraHosts = append(spec.ExtraHosts, HostEntry{IP: entry.GetIp(), Hostnames: entry.GetHostnames()})
+	}
+
+	if workload := options.GetWorkload(); workload != nil {
+		spec.Workload = workloadMetadataFromOptions(workload)
+	}
+
+	for _, rule := range options.GetIngressRules() {
+		spec.IngressRules = append(spec.IngressRules, IngressRule{
+			SourceCIDRs: rule.GetSourceCidrs(),
+			Ports:       rule.GetPorts(),
+		})
+	}
+
+	for _, disk := range options.GetDisks() {
+		spec.Disks = append(spec.Disks, Disk{
+			Path:      disk.GetPath(),
+			ReadOnly:  disk.GetReadOnly(),
+			CacheMode: disk.GetCacheMode(),
+			Format:    disk.GetFormat(),
+			MountPath: disk.GetMountPath(),
+		})
+	}
+
+	for _, modifier := range options.GetCpuidModifiers() {
+		spec.CPUIDModifiers = append(spec.CPUIDModifiers, CPUIDModifier{
+			Leaf:     modifier.GetLeaf(),
+			Subleaf:  modifier.GetSubleaf(),
+			Flags:    modifier.GetFlags(),
+			Register: modifier.GetRegister(),
+			Bitmap:   modifier.GetBitmap(),
+		})
+	}
+
+	return spec
 }
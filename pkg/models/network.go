@@ -1,6 +1,10 @@
 package models
 
-import "net"
+import (
+	"fmt"
+	"net"
+	"strings"
+)
 
 type TapDetails struct {
 	VMIP  net.IP
@@ -26,3 +30,141 @@ type NetworkInterfaceStatus struct {
 	// TAP devicee details
 	TapDetails TapDetails
 }
+
+// IngressRule allows connections from the source CIDRs to the ports,
+// from any source or to any port if either is empty
+type IngressRule struct {
+	SourceCIDRs []string `json:"source_cidrs,omitempty" validate:"dive,cidr"`
+	Ports       []uint32 `json:"ports,omitempty"        validate:"dive,gte=1,lte=65535"`
+}
+
+// EgressPolicy restricts the connections a workload opens
+type EgressPolicy struct {
+	// Only the connections matching one of the Allow rules are
+	// accepted, along with DNS queries to the nameservers of the
+	// workload and connections to the node
+	DefaultDeny bool         `json:"default_deny"`
+	Allow       []EgressRule `json:"allow,omitempty" validate:"omitempty,dive"`
+	// HTTP and HTTPS connections are redirected to the egress proxy of
+	// the node, which logs them
+	Proxy bool `json:"proxy"`
+}
+
+// EgressRule allows connections to the destination CIDRs on the ports,
+// to any destination or on any port if either is empty
+type EgressRule struct {
+	DestinationCIDRs []string `json:"destination_cidrs,omitempty" validate:"dive,cidr"`
+	Ports            []uint32 `json:"ports,omitempty"             validate:"dive,gte=1,lte=65535"`
+}
+
+// Limits of the resolver, longer lists are truncated by the libc
+const (
+	MaxNameservers = 3
+	MaxNdots       = 15
+)
+
+// DNSConfig is the resolver configuration of a workload, written to its
+// /etc/resolv.conf instead of the one of the host
+type DNSConfig struct {
+	Nameservers []string `json:"nameservers,omitempty" validate:"max=3,dive,ip"`
+	Searches    []string `json:"searches,omitempty"`
+	// Dots a name needs to be looked up as is before the search domains,
+	// the resolver default of 1 if zero
+	Ndots uint32 `json:"ndots,omitempty" validate:"lte=15"`
+}
+
+// Validate returns why the config can't be written to a resolv.conf, nil
+// if it can
+func (c *DNSConfig) Validate() error {
+	if len(c.Nameservers) > MaxNameservers {
+		return fmt.Errorf("%d nameservers, at most %d are supported", len(c.Nameservers), MaxNameservers)
+	}
+
+	for _, server := range c.Nameservers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid nameserver %q, must be an IP address", server)
+		}
+	}
+
+	for _, domain := range c.Searches {
+		if domain == "" || strings.ContainsAny(domain, " \t\n") {
+			return fmt.Errorf("invalid search domain %q", domain)
+		}
+	}
+
+	if c.Ndots > MaxNdots {
+		return fmt.Errorf("ndots %d is more than the maximum of %d", c.Ndots, MaxNdots)
+	}
+
+	return nil
+}
+
+// ResolvConf returns the config in the resolv.conf format
+func (c *DNSConfig) ResolvConf() []byte {
+	var b strings.Builder
+
+	for _, server := range c.Nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", server)
+	}
+
+	if len(c.Searches) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(c.Searches, " "))
+	}
+
+	if c.Ndots > 0 {
+		fmt.Fprintf(&b, "options ndots:%d\n", c.Ndots)
+	}
+
+	return []byte(b.String())
+}
+
+// MaxExtraHosts bounds the extra hosts entries of a workload, passed to
+// the guest of VMs on the kernel command line
+const MaxExtraHosts = 32
+
+// HostEntry is an extra line of the /etc/hosts of a workload, resolving
+// the hostnames to the IP address without going through DNS
+type HostEntry struct {
+	IP        string   `json:"ip"        validate:"ip"`
+	Hostnames []string `json:"hostnames" validate:"min=1"`
+}
+
+// ValidateHostEntries returns why the entries can't be written to a
+// hosts file, nil if they can
+func ValidateHostEntries(entries []HostEntry) error {
+	if len(entries) > MaxExtraHosts {
+		return fmt.Errorf("%d extra hosts, at most %d are supported", len(entries), MaxExtraHosts)
+	}
+
+	for _, entry := range entries {
+		if net.ParseIP(entry.IP) == nil {
+			return fmt.Errorf("invalid extra host IP %q", entry.IP)
+		}
+
+		if len(entry.Hostnames) == 0 {
+			return fmt.Errorf("extra host %s has no hostname", entry.IP)
+		}
+
+		for _, hostname := range entry.Hostnames {
+			if hostname == "" || strings.ContainsAny(hostname, " \t\n#,/") {
+				return fmt.Errorf("invalid extra hostname %q", hostname)
+			}
+		}
+	}
+
+	return nil
+}
+
+// HostsFile returns the /etc/hosts of a workload, the loopback entries
+// followed by the extra ones
+func HostsFile(entries []HostEntry) []byte {
+	var b strings.Builder
+
+	b.WriteString("127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n")
+
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "%s\t%s\n", entry.IP, strings.Join(entry.Hostnames, " "))
+	}
+
+	return []byte(b.String())
+}
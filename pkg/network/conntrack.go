@@ -0,0 +1,73 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// conntrackAcctPath enables the byte counters of the connections tracked
+// in the network namespace of the calling thread
+const conntrackAcctPath = "/proc/sys/net/netfilter/nf_conntrack_acct"
+
+// Flow is a connection tracked in a network namespace
+type Flow struct {
+	// tcp or udp
+	Protocol string
+	Src      net.IP
+	SrcPort  uint16
+	Dst      net.IP
+	DstPort  uint16
+	// Bytes sent by the source and by the destination of the connection
+	SentBytes     uint64
+	ReceivedBytes uint64
+}
+
+// EnableConntrackAccounting counts the bytes of the connections tracked
+// in the current network namespace
+func EnableConntrackAccounting() error {
+	if err := os.WriteFile(conntrackAcctPath, []byte("1"), 0); err != nil {
+		return fmt.Errorf("failed to enable conntrack accounting: %w", err)
+	}
+
+	return nil
+}
+
+// ListFlows returns the TCP and UDP connections tracked in the current
+// network namespace
+func ListFlows() ([]Flow, error) {
+	entries, err := netlink.ConntrackTableList(netlink.ConntrackTable, unix.AF_INET)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conntrack table: %w", err)
+	}
+
+	flows := make([]Flow, 0, len(entries))
+
+	for _, entry := range entries {
+		var protocol string
+
+		switch entry.Forward.Protocol {
+		case unix.IPPROTO_TCP:
+			protocol = "tcp"
+		case unix.IPPROTO_UDP:
+			protocol = "udp"
+		default:
+			continue
+		}
+
+		flows = append(flows, Flow{
+			Protocol:      protocol,
+			Src:           entry.Forward.SrcIP,
+			SrcPort:       entry.Forward.SrcPort,
+			Dst:           entry.Forward.DstIP,
+			DstPort:       entry.Forward.DstPort,
+			SentBytes:     entry.Forward.Bytes,
+			ReceivedBytes: entry.Reverse.Bytes,
+		})
+	}
+
+	return flows, nil
+}
@@ -0,0 +1,326 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"vistara-node/pkg/models"
+)
+
+const (
+	// NftBin is the nftables binary the rulesets are applied with
+	NftBin = "nft"
+	// FirewallTable is the nftables table holding the firewall of a
+	// workload in its network namespace
+	FirewallTable = "hypercore"
+)
+
+// ContainerFirewall is the firewall of a container in its network namespace
+type ContainerFirewall struct {
+	// New connections to the container are only accepted if they match
+	// one of the rules, all are accepted if there are none
+	Ingress []models.IngressRule
+	// Restricts the connections the container opens, nil for none
+	Egress *models.EgressPolicy
+	// Port of the egress proxy the HTTP and HTTPS connections are
+	// redirected to on the gateway, if the egress policy proxies them
+	EgressProxyPort uint16
+	// Port of the metadata service the connections to models.MetadataIP
+	// are redirected to on the gateway, 0 for none
+	MetadataPort uint16
+	// Track the connections of the container, with their byte counters,
+	// so they can be sampled from its network namespace
+	TrackConnections bool
+	// Address of the node on the container network, whose connections
+	// are always accepted
+	Gateway net.IP
+	// Nameservers of the resolv.conf of the container, the only addresses
+	// besides the gateway DNS queries are accepted to under a default
+	// deny egress policy
+	Resolvers []net.IP
+}
+
+// filterRule matches connections to or from the CIDRs on the ports, any
+// address or port matching if either is empty
+type filterRule struct {
+	cidrs []string
+	ports []uint32
+}
+
+// ContainerRuleset returns the nftables ruleset of the firewall, empty
+// if it doesn't filter anything
+func ContainerRuleset(firewall ContainerFirewall) (string, error) {
+	var chains strings.Builder
+
+	if len(firewall.Ingress) > 0 {
+		rules := make([]filterRule, 0, len(firewall.Ingress))
+		for _, rule := range firewall.Ingress {
+			rules = append(rules, filterRule{cidrs: rule.SourceCIDRs, ports: rule.Ports})
+		}
+
+		chains.WriteString("\tchain ingress {\n")
+		chains.WriteString("\t\ttype filter hook input priority filter; policy accept;\n")
+		chains.WriteString("\t\tct state established,related accept\n")
+		chains.WriteString("\t\tiif \"lo\" accept\n")
+
+		if err := writeRules(&chains, rules, firewall.Gateway, "saddr", "meta l4proto { tcp, udp } th dport"); err != nil {
+			return "", err
+		}
+
+		chains.WriteString("\t\tmeta l4proto { tcp, udp } ct state new drop\n")
+		chains.WriteString("\t}\n")
+	}
+
+	if policy := firewall.Egress; policy != nil && policy.DefaultDeny {
+		rules := make([]filterRule, 0, len(policy.Allow)+1)
+		// Queries to other resolvers would let workloads tunnel through
+		// DNS, the gateway is accepted along with everything else to it
+		if len(firewall.Resolvers) > 0 {
+			cidrs := make([]string, 0, len(firewall.Resolvers))
+			for _, resolver := range firewall.Resolvers {
+				cidrs = append(cidrs, hostCIDR(resolver))
+			}
+
+			rules = append(rules, filterRule{cidrs: cidrs, ports: []uint32{53}})
+		}
+
+		for _, rule := range policy.Allow {
+			rules = append(rules, filterRule{cidrs: rule.DestinationCIDRs, ports: rule.Ports})
+		}
+
+		chains.WriteString("\tchain egress {\n")
+		chains.WriteString("\t\ttype filter hook output priority filter; policy accept;\n")
+		chains.WriteString("\t\tct state established,related accept\n")
+		chains.WriteString("\t\toif \"lo\" accept\n")
+
+		if err := writeRules(&chains, rules, firewall.Gateway, "daddr", "meta l4proto { tcp, udp } th dport"); err != nil {
+			return "", err
+		}
+
+		chains.WriteString("\t\tct state new drop\n")
+		chains.WriteString("\t}\n")
+	}
+
+	// Conntrack only hooks into the network namespaces with rules
+	// matching on it
+	if firewall.TrackConnections {
+		chains.WriteString("\tchain track {\n")
+		chains.WriteString("\t\ttype filter hook output priority filter; policy accept;\n")
+		chains.WriteString("\t\tct state new counter\n")
+		chains.WriteString("\t}\n")
+	}
+
+	var redirects []string
+
+	// Before the egress proxy, which would capture the connections to
+	// the metadata service on port 80
+	if firewall.MetadataPort != 0 {
+		if firewall.Gateway.To4() == nil {
+			return "", errors.New("the metadata service needs the IPv4 gateway of the container")
+		}
+
+		redirects = append(redirects, fmt.Sprintf("ip daddr %s tcp dport 80 dnat ip to %s:%d",
+			models.MetadataIP, firewall.Gateway, firewall.MetadataPort))
+	}
+
+	if policy := firewall.Egress; policy != nil && policy.Proxy {
+		if firewall.Gateway.To4() == nil || firewall.EgressProxyPort == 0 {
+			return "", errors.New("egress proxying needs the IPv4 gateway of the container and the port of the proxy")
+		}
+
+		redirects = append(redirects, fmt.Sprintf("ip daddr != %s tcp dport { 80, 443 } dnat ip to %s:%d",
+			firewall.Gateway, firewall.Gateway, firewall.EgressProxyPort))
+	}
+
+	if len(redirects) > 0 {
+		// Redirected before the egress filter, which accepts connections
+		// to the gateway
+		chains.WriteString("\tchain redirect {\n")
+		chains.WriteString("\t\ttype nat hook output priority dstnat; policy accept;\n")
+
+		for _, rule := range redirects {
+			chains.WriteString("\t\t" + rule + "\n")
+		}
+
+		chains.WriteString("\t}\n")
+	}
+
+	if chains.Len() == 0 {
+		return "", nil
+	}
+
+	return ruleset("inet", chains.String()), nil
+}
+
+// TapIngressRuleset returns the nftables ruleset only letting the new TCP
+// connections matching one of the rules out of the tap device to the VM.
+// The traffic is redirected to the tap device without going through
+// conntrack, so connections are told apart from replies by their SYN flag
+// and UDP isn't filtered
+func TapIngressRuleset(device string, ingress []models.IngressRule, gateway net.IP) (string, error) {
+	rules := make([]filterRule, 0, len(ingress))
+	for _, rule := range ingress {
+		rules = append(rules, filterRule{cidrs: rule.SourceCIDRs, ports: rule.Ports})
+	}
+
+	var chain strings.Builder
+	chain.WriteString("\tchain ingress {\n")
+	chain.WriteString(fmt.Sprintf("\t\ttype filter hook egress device %q priority filter; policy accept;\n", device))
+
+	if err := writeRules(&chain, rules, gateway, "saddr", "tcp dport"); err != nil {
+		return "", err
+	}
+
+	chain.WriteString("\t\ttcp flags & (syn | ack) == syn drop\n")
+	chain.WriteString("\t}\n")
+
+	return ruleset("netdev", chain.String()), nil
+}
+
+// writeRules writes a rule accepting the packets from or to (addrMatch)
+// the gateway, and one per rule and address family
+func writeRules(chain *strings.Builder, rules []filterRule, gateway net.IP, addrMatch, portMatch string) error {
+	if gateway != nil {
+		chain.WriteString(fmt.Sprintf("\t\t%s %s %s accept\n", addrFamily(gateway), addrMatch, gateway))
+	}
+
+	for _, rule := range rules {
+		ports := ""
+		if len(rule.ports) > 0 {
+			values := make([]string, 0, len(rule.ports))
+			for _, port := range rule.ports {
+				if port == 0 || port > 0xffff {
+					return fmt.Errorf("invalid port %d", port)
+				}
+
+				values = append(values, strconv.Itoa(int(port)))
+			}
+
+			ports = fmt.Sprintf(" %s { %s }", portMatch, strings.Join(values, ", "))
+		} else if portMatch == "tcp dport" {
+			ports = " meta l4proto tcp"
+		}
+
+		if len(rule.cidrs) == 0 {
+			chain.WriteString(fmt.Sprintf("\t\t%s accept\n", strings.TrimSpace(ports)))
+
+			continue
+		}
+
+		cidrs := map[string][]string{}
+		for _, cidr := range rule.cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+			}
+
+			family := addrFamily(network.IP)
+			cidrs[family] = append(cidrs[family], network.String())
+		}
+
+		for _, family := range []string{"ip", "ip6"} {
+			if len(cidrs[family]) > 0 {
+				chain.WriteString(fmt.Sprintf("\t\t%s %s { %s }%s accept\n", family, addrMatch, strings.Join(cidrs[family], ", "), ports))
+			}
+		}
+	}
+
+	return nil
+}
+
+// hostCIDR returns the CIDR only holding the address
+func hostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+
+	return ip.String() + "/128"
+}
+
+func addrFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ip"
+	}
+
+	return "ip6"
+}
+
+// ruleset replaces the firewall table of the family with one holding
+// the chains
+func ruleset(family, chains string) string {
+	return fmt.Sprintf("table %[1]s %[2]s\ndelete table %[1]s %[2]s\ntable %[1]s %[2]s {\n%[3]s}\n",
+		family, FirewallTable, chains)
+}
+
+// EgressAllowed returns whether the policy lets a workload connect to the
+// port of the address
+func EgressAllowed(policy *models.EgressPolicy, ip net.IP, port uint32) bool {
+	if policy == nil || !policy.DefaultDeny {
+		return true
+	}
+
+	for _, rule := range policy.Allow {
+		if len(rule.Ports) > 0 && !slices.Contains(rule.Ports, port) {
+			continue
+		}
+
+		if len(rule.DestinationCIDRs) == 0 {
+			return true
+		}
+
+		for _, cidr := range rule.DestinationCIDRs {
+			if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// DefaultGateway returns the gateway of the default route of the current
+// network namespace, nil if there is none
+func DefaultGateway() (net.IP, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	for _, route := range routes {
+		if route.Dst == nil && route.Gw != nil {
+			return route.Gw, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ApplyRuleset loads the nftables ruleset in the current network namespace
+func ApplyRuleset(ruleset string) error {
+	cmd := exec.Command(NftBin, "-f", "-")
+	cmd.Stdin = strings.NewReader(ruleset)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply nftables ruleset: %w: %s", err, bytes.TrimSpace(output))
+	}
+
+	return nil
+}
+
+// ListRuleset returns the nftables ruleset of the current network namespace
+func ListRuleset() (string, error) {
+	output, err := exec.Command(NftBin, "list", "ruleset").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to list nftables ruleset: %w: %s", err, bytes.TrimSpace(output))
+	}
+
+	return string(output), nil
+}
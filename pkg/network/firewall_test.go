@@ -0,0 +1,146 @@
+package network
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"vistara-node/pkg/models"
+)
+
+// chainRules returns the rules of a chain of the ruleset, one per line
+func chainRules(t *testing.T, ruleset, chain string) []string {
+	t.Helper()
+
+	_, body, ok := strings.Cut(ruleset, "\tchain "+chain+" {\n")
+	if !ok {
+		t.Fatalf("no %s chain in ruleset:\n%s", chain, ruleset)
+	}
+
+	body, _, _ = strings.Cut(body, "\t}\n")
+
+	var rules []string
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			rules = append(rules, line)
+		}
+	}
+
+	return rules
+}
+
+// setValues returns the values of the set following the prefix in the
+// rule, a single value if it isn't a set
+func setValues(rule, prefix string) ([]string, bool) {
+	_, rest, ok := strings.Cut(rule, prefix+" ")
+	if !ok {
+		return nil, false
+	}
+
+	if !strings.HasPrefix(rest, "{") {
+		value, _, _ := strings.Cut(rest, " ")
+
+		return []string{value}, true
+	}
+
+	set, _, _ := strings.Cut(strings.TrimPrefix(rest, "{ "), " }")
+
+	return strings.Split(set, ", "), true
+}
+
+// egressAccepted evaluates the egress chain of the ruleset for a new
+// connection to the port of the address, only knowing the rules
+// ContainerRuleset writes
+func egressAccepted(t *testing.T, ruleset string, ip net.IP, port uint32) bool {
+	t.Helper()
+
+	for _, rule := range chainRules(t, ruleset, "egress")[1:] {
+		verdict := rule[strings.LastIndex(rule, " ")+1:]
+
+		if strings.HasPrefix(rule, "ct state established") || strings.HasPrefix(rule, "oif ") {
+			continue
+		}
+
+		if addrs, ok := setValues(rule, addrFamily(ip)+" daddr"); ok {
+			if !slices.ContainsFunc(addrs, func(addr string) bool {
+				_, network, err := net.ParseCIDR(addr)
+				if err != nil {
+					return net.ParseIP(addr).Equal(ip)
+				}
+
+				return network.Contains(ip)
+			}) {
+				continue
+			}
+		} else if strings.Contains(rule, " daddr ") {
+			// A rule of the other address family
+			continue
+		}
+
+		if ports, ok := setValues(rule, "th dport"); ok && !slices.Contains(ports, strconv.Itoa(int(port))) {
+			continue
+		}
+
+		return verdict == "accept"
+	}
+
+	return true
+}
+
+func TestContainerRulesetDNS(t *testing.T) {
+	gateway := net.ParseIP("10.88.0.1")
+	policy := &models.EgressPolicy{
+		DefaultDeny: true,
+		Allow:       []models.EgressRule{{DestinationCIDRs: []string{"10.20.0.0/16"}, Ports: []uint32{5432}}},
+	}
+
+	tests := []struct {
+		name      string
+		resolvers []net.IP
+		ip        string
+		port      uint32
+		accepted  bool
+	}{
+		{name: "gateway resolver", ip: "10.88.0.1", port: 53, accepted: true},
+		{name: "configured resolver", resolvers: []net.IP{net.ParseIP("192.168.1.53")}, ip: "192.168.1.53", port: 53, accepted: true},
+		{name: "configured IPv6 resolver", resolvers: []net.IP{net.ParseIP("2001:db8::53")}, ip: "2001:db8::53", port: 53, accepted: true},
+		{name: "other resolver", resolvers: []net.IP{net.ParseIP("192.168.1.53")}, ip: "8.8.8.8", port: 53, accepted: false},
+		{name: "other resolver without configured ones", ip: "1.1.1.1", port: 53, accepted: false},
+		{name: "other port of the resolver", resolvers: []net.IP{net.ParseIP("192.168.1.53")}, ip: "192.168.1.53", port: 443, accepted: false},
+		{name: "allowed destination", ip: "10.20.3.4", port: 5432, accepted: true},
+		{name: "DNS to allowed destination", ip: "10.20.3.4", port: 53, accepted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset, err := ContainerRuleset(ContainerFirewall{Egress: policy, Gateway: gateway, Resolvers: tt.resolvers})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if accepted := egressAccepted(t, ruleset, net.ParseIP(tt.ip), tt.port); accepted != tt.accepted {
+				t.Fatalf("expected a connection to %s port %d to be accepted: %t, got %t with ruleset:\n%s", tt.ip, tt.port, tt.accepted, accepted, ruleset)
+			}
+		})
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	resolvConf := []byte(`# generated
+nameserver 10.0.0.2
+nameserver   fe80::1%eth0
+nameserver not-an-address
+search example.com
+options ndots:2
+nameserver 2001:db8::53
+`)
+
+	got := Nameservers(resolvConf)
+	want := []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("fe80::1"), net.ParseIP("2001:db8::53")}
+
+	if !slices.EqualFunc(got, want, net.IP.Equal) {
+		t.Fatalf("expected nameservers %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// TapName is the TAP device the VMs are attached to in their network
+// namespace
+const TapName = "tap0"
+
+// SetupTapRedirect creates the TAP device of the VM in the current network
+// namespace and redirects all the traffic between it and the link the CNI
+// plugins configured, like the tc-redirect-tap plugin does. An existing TAP
+// device, e.g. created by that plugin, is left as is
+func SetupTapRedirect(linkName string) error {
+	if _, err := netlink.LinkByName(TapName); err == nil {
+		return nil
+	} else if !errors.As(err, &netlink.LinkNotFoundError{}) {
+		return fmt.Errorf("failed to look up %s: %w", TapName, err)
+	}
+
+	link, err := netlink.LinkByName(linkName)
+	if err != nil {
+		return fmt.Errorf("failed to get link %s: %w", linkName, err)
+	}
+
+	tap := &netlink.Tuntap{
+		LinkAttrs: netlink.LinkAttrs{Name: TapName, MTU: link.Attrs().MTU},
+		Mode:      netlink.TUNTAP_MODE_TAP,
+	}
+	if err := netlink.LinkAdd(tap); err != nil {
+		return fmt.Errorf("failed to create tap device: %w", err)
+	}
+
+	// The device is persistent, the hypervisors open it by name
+	for _, fd := range tap.Fds {
+		fd.Close()
+	}
+
+	if err := netlink.LinkSetUp(tap); err != nil {
+		return fmt.Errorf("failed to set %s up: %w", TapName, err)
+	}
+
+	if err := redirectIngress(link, tap); err != nil {
+		return err
+	}
+
+	return redirectIngress(tap, link)
+}
+
+// redirectIngress sends every packet received on from out of to
+func redirectIngress(from, to netlink.Link) error {
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: from.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+	if err := netlink.QdiscAdd(ingress); err != nil {
+		return fmt.Errorf("failed to add ingress qdisc to %s: %w", from.Attrs().Name, err)
+	}
+
+	filter := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: from.Attrs().Index,
+			Parent:    ingress.Handle,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Actions: []netlink.Action{
+			&netlink.MirredAction{
+				ActionAttrs:  netlink.ActionAttrs{Action: netlink.TC_ACT_STOLEN},
+				MirredAction: netlink.TCA_EGRESS_REDIR,
+				Ifindex:      to.Attrs().Index,
+			},
+		},
+	}
+	if err := netlink.FilterAdd(filter); err != nil {
+		return fmt.Errorf("failed to redirect %s to %s: %w", from.Attrs().Name, to.Attrs().Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// BPFRoot is where the bpf filesystem the counters are pinned to is
+// mounted
+const BPFRoot = "/sys/fs/bpf"
+
+// Keys of the counters in the map shared by the programs of an interface
+const (
+	trafficKeyIngress = 0
+	trafficKeyEgress  = 1
+)
+
+// TrafficCounters are the bytes and packets received and sent by a
+// workload, counted by eBPF programs on the interface of its network
+// namespace
+type TrafficCounters struct {
+	RxBytes   uint64
+	RxPackets uint64
+	TxBytes   uint64
+	TxPackets uint64
+}
+
+// trafficValue is the value of the per-CPU map the programs count into
+type trafficValue struct {
+	packets uint64
+	bytes   uint64
+}
+
+// AttachTrafficCounters counts the traffic of the interface of the current
+// network namespace with eBPF programs on its tc hooks, adding to per-CPU
+// counters so they don't contend. The map of the counters is pinned at
+// pinPath, replacing the one already there, so it outlives the agent
+func AttachTrafficCounters(iface, pinPath string) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("failed to get interface %s: %w", iface, err)
+	}
+
+	if err := mountBPFFS(); err != nil {
+		return err
+	}
+
+	mapFd, err := bpfMapCreate(unix.BPF_MAP_TYPE_PERCPU_ARRAY, 4, uint32(unsafe.Sizeof(trafficValue{})), 2)
+	if err != nil {
+		return fmt.Errorf("failed to create traffic counters map: %w", err)
+	}
+	defer unix.Close(mapFd)
+
+	qdisc := &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_CLSACT,
+		},
+		QdiscType: "clsact",
+	}
+	if err := netlink.QdiscReplace(qdisc); err != nil {
+		return fmt.Errorf("failed to add clsact qdisc to %s: %w", iface, err)
+	}
+
+	hooks := []struct {
+		name   string
+		parent uint32
+		key    int32
+	}{
+		{"hypercore_rx", netlink.HANDLE_MIN_INGRESS, trafficKeyIngress},
+		{"hypercore_tx", netlink.HANDLE_MIN_EGRESS, trafficKeyEgress},
+	}
+
+	for _, hook := range hooks {
+		progFd, err := bpfProgLoad(unix.BPF_PROG_TYPE_SCHED_CLS, hook.name, trafficProgram(mapFd, hook.key))
+		if err != nil {
+			return fmt.Errorf("failed to load %s program: %w", hook.name, err)
+		}
+
+		filter := &netlink.BpfFilter{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: link.Attrs().Index,
+				Parent:    hook.parent,
+				Handle:    netlink.MakeHandle(0, 1),
+				Protocol:  unix.ETH_P_ALL,
+				Priority:  1,
+			},
+			Fd:           progFd,
+			Name:         hook.name,
+			DirectAction: true,
+		}
+
+		err = netlink.FilterReplace(filter)
+		unix.Close(progFd)
+
+		if err != nil {
+			return fmt.Errorf("failed to attach %s program to %s: %w", hook.name, iface, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pinPath), 0o700); err != nil {
+		return err
+	}
+
+	if err := os.Remove(pinPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := bpfObjPin(mapFd, pinPath); err != nil {
+		return fmt.Errorf("failed to pin traffic counters to %s: %w", pinPath, err)
+	}
+
+	return nil
+}
+
+// ReadTrafficCounters returns the counters pinned at pinPath, summed
+// across the CPUs
+func ReadTrafficCounters(pinPath string) (TrafficCounters, error) {
+	mapFd, err := bpfObjGet(pinPath)
+	if err != nil {
+		return TrafficCounters{}, fmt.Errorf("failed to open traffic counters %s: %w", pinPath, err)
+	}
+	defer unix.Close(mapFd)
+
+	cpus, err := possibleCPUs()
+	if err != nil {
+		return TrafficCounters{}, err
+	}
+
+	var counters TrafficCounters
+
+	for _, key := range []uint32{trafficKeyIngress, trafficKeyEgress} {
+		values := make([]trafficValue, cpus)
+		if err := bpfMapLookup(mapFd, unsafe.Pointer(&key), unsafe.Pointer(&values[0])); err != nil {
+			return TrafficCounters{}, fmt.Errorf("failed to read traffic counters %s: %w", pinPath, err)
+		}
+
+		var total trafficValue
+		for _, value := range values {
+			total.packets += value.packets
+			total.bytes += value.bytes
+		}
+
+		if key == trafficKeyIngress {
+			counters.RxPackets, counters.RxBytes = total.packets, total.bytes
+		} else {
+			counters.TxPackets, counters.TxBytes = total.packets, total.bytes
+		}
+	}
+
+	return counters, nil
+}
+
+// trafficProgram returns the instructions of a tc program adding the
+// packet to the counters at key of the map, letting it through
+func trafficProgram(mapFd int, key int32) []bpfInsn {
+	return []bpfInsn{
+		{code: 0xbf, regs: 0x16},                    // r6 = r1 (the __sk_buff)
+		{code: 0x61, regs: 0x67},                    // r7 = r6->len
+		{code: 0x62, regs: 0x0a, off: -4, imm: key}, // *(u32 *)(r10 - 4) = key
+		{code: 0xbf, regs: 0xa2},                    // r2 = r10
+		{code: 0x07, regs: 0x02, imm: -4},           // r2 += -4
+		{code: 0x18, regs: 0x11, imm: int32(mapFd)}, // r1 = map
+		{},
+		{code: 0x85, imm: 1},             // r0 = bpf_map_lookup_elem(r1, r2)
+		{code: 0x15, off: 6},             // if r0 == 0 goto out
+		{code: 0x79, regs: 0x01},         // r1 = value->packets
+		{code: 0x07, regs: 0x01, imm: 1}, // r1 += 1
+		{code: 0x7b, regs: 0x10},         // value->packets = r1
+		{code: 0x79, regs: 0x01, off: 8}, // r1 = value->bytes
+		{code: 0x0f, regs: 0x71},         // r1 += r7
+		{code: 0x7b, regs: 0x10, off: 8}, // value->bytes = r1
+		{code: 0xb7},                     // out: r0 = TC_ACT_OK
+		{code: 0x95},                     // exit
+	}
+}
+
+// bpfInsn is an eBPF instruction, regs holding the source register in
+// its high bits and the destination one in its low bits
+type bpfInsn struct {
+	code uint8
+	regs uint8
+	off  int16
+	imm  int32
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return int(fd), nil
+}
+
+func bpfMapCreate(mapType, keySize, valueSize, maxEntries uint32) (int, error) {
+	attr := struct {
+		mapType    uint32
+		keySize    uint32
+		valueSize  uint32
+		maxEntries uint32
+	}{mapType, keySize, valueSize, maxEntries}
+
+	return bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+func bpfProgLoad(progType uint32, name string, insns []bpfInsn) (int, error) {
+	license := []byte("GPL\x00")
+	logBuf := make([]byte, 64*1024)
+
+	attr := struct {
+		progType    uint32
+		insnCnt     uint32
+		insns       uint64
+		license     uint64
+		logLevel    uint32
+		logSize     uint32
+		logBuf      uint64
+		kernVersion uint32
+		progFlags   uint32
+		progName    [unix.BPF_OBJ_NAME_LEN]byte
+	}{
+		progType: progType,
+		insnCnt:  uint32(len(insns)),
+		insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+		logLevel: 1,
+		logSize:  uint32(len(logBuf)),
+		logBuf:   uint64(uintptr(unsafe.Pointer(&logBuf[0]))),
+	}
+	copy(attr.progName[:unix.BPF_OBJ_NAME_LEN-1], name)
+
+	fd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+
+	if err != nil {
+		if verifierLog := strings.TrimRight(string(logBuf), "\x00\n"); verifierLog != "" {
+			return 0, fmt.Errorf("%w: %s", err, verifierLog)
+		}
+
+		return 0, err
+	}
+
+	return fd, nil
+}
+
+func bpfObjPin(fd int, path string) error {
+	pathname, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	attr := struct {
+		pathname uint64
+		bpfFd    uint32
+		flags    uint32
+	}{pathname: uint64(uintptr(unsafe.Pointer(pathname))), bpfFd: uint32(fd)}
+
+	_, err = bpf(unix.BPF_OBJ_PIN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(pathname)
+
+	return err
+}
+
+func bpfObjGet(path string) (int, error) {
+	pathname, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	attr := struct {
+		pathname uint64
+		bpfFd    uint32
+		flags    uint32
+	}{pathname: uint64(uintptr(unsafe.Pointer(pathname)))}
+
+	fd, err := bpf(unix.BPF_OBJ_GET, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(pathname)
+
+	return fd, err
+}
+
+func bpfMapLookup(fd int, key, value unsafe.Pointer) error {
+	attr := struct {
+		mapFd uint32
+		_     uint32
+		key   uint64
+		value uint64
+		flags uint64
+	}{mapFd: uint32(fd), key: uint64(uintptr(key)), value: uint64(uintptr(value))}
+
+	_, err := bpf(unix.BPF_MAP_LOOKUP_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
+	return err
+}
+
+// mountBPFFS mounts the bpf filesystem unless it already is
+func mountBPFFS() error {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(BPFRoot, &stat); err == nil && stat.Type == unix.BPF_FS_MAGIC {
+		return nil
+	}
+
+	if err := unix.Mount("bpf", BPFRoot, "bpf", 0, ""); err != nil {
+		return fmt.Errorf("failed to mount bpf filesystem on %s: %w", BPFRoot, err)
+	}
+
+	return nil
+}
+
+// possibleCPUs returns the number of CPUs the values of per-CPU maps are
+// sized for, e.g. 8 for 0-7
+func possibleCPUs() (int, error) {
+	contents, err := os.ReadFile("/sys/devices/system/cpu/possible")
+	if err != nil {
+		return 0, err
+	}
+
+	// 0-3,6-7
+	ranges := strings.Split(strings.TrimSpace(string(contents)), ",")
+	_, last, _ := strings.Cut(ranges[len(ranges)-1], "-")
+	if last == "" {
+		last = ranges[len(ranges)-1]
+	}
+
+	highest, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, fmt.Errorf("invalid possible CPUs %q: %w", contents, err)
+	}
+
+	return highest + 1, nil
+}
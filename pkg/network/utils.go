@@ -39,3 +39,24 @@ func GetLinkMacIP(linkName string) (net.HardwareAddr, net.IP, error) {
 
 	return net.HardwareAddr{}, net.IP{}, fmt.Errorf("no ip addresses found for link %s", linkName)
 }
+
+// Nameservers returns the addresses of the nameserver lines of a
+// resolv.conf, skipping the ones that aren't IP addresses
+func Nameservers(resolvConf []byte) []net.IP {
+	var nameservers []net.IP
+
+	for _, line := range strings.Split(string(resolvConf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		// Scoped IPv6 addresses such as fe80::1%eth0
+		address, _, _ := strings.Cut(fields[1], "%")
+		if ip := net.ParseIP(address); ip != nil {
+			nameservers = append(nameservers, ip)
+		}
+	}
+
+	return nameservers
+}
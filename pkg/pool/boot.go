@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"slices"
+	"time"
+)
+
+// Stages of the boot of a VM, each measured from the time the shim was
+// asked to create its task
+const (
+	// The shim negotiated the protocol of the guest agent over vsock
+	BootStageAgent = "agent"
+	// The task was started for the first time
+	BootStageStart = "start"
+)
+
+// BootBuckets are the upper bounds (in seconds) of the buckets of the boot
+// time histograms
+var BootBuckets = []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 30}
+
+// BootHistogram counts the boots of the VMs of a provider reaching a stage
+// per duration bucket, the last count being the boots slower than all the
+// bounds
+type BootHistogram struct {
+	Provider string   `json:"provider"`
+	Stage    string   `json:"stage"`
+	Restored bool     `json:"restored"`
+	Counts   []uint64 `json:"counts"`
+	Seconds  float64  `json:"seconds"`
+	Count    uint64   `json:"count"`
+}
+
+func (h *BootHistogram) observe(elapsed time.Duration) {
+	// The buckets changed since the histogram was recorded
+	if len(h.Counts) != len(BootBuckets)+1 {
+		*h = BootHistogram{Provider: h.Provider, Stage: h.Stage, Restored: h.Restored}
+		h.Counts = make([]uint64, len(BootBuckets)+1)
+	}
+
+	bucket, _ := slices.BinarySearch(BootBuckets, elapsed.Seconds())
+
+	h.Counts[bucket]++
+	h.Seconds += elapsed.Seconds()
+	h.Count++
+}
+
+// RecordBoot records the time a VM of the provider took to reach a stage
+// of its boot
+func RecordBoot(root, provider, stage string, restored bool, elapsed time.Duration) error {
+	return updateStats(root, func(stats *Stats) {
+		index := slices.IndexFunc(stats.Boots, func(h *BootHistogram) bool {
+			return h.Provider == provider && h.Stage == stage && h.Restored == restored
+		})
+
+		if index == -1 {
+			stats.Boots = append(stats.Boots, &BootHistogram{Provider: provider, Stage: stage, Restored: restored})
+			index = len(stats.Boots) - 1
+		}
+
+		stats.Boots[index].observe(elapsed)
+	})
+}
@@ -0,0 +1,303 @@
+package pool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/ports"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/firecracker-microvm/firecracker-go-sdk/vsock"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const (
+	// Network namespace the template VMs are booted in, it only holds
+	// the tap device they are attached to
+	NetNSName = "hypercore-pool"
+
+	// MAC of the template VMs, restored VMs get theirs through MMDS
+	TemplateGuestMAC = "06:00:AC:10:00:02"
+
+	agentTimeout = time.Minute
+)
+
+// Shape is a VM size kept warm in the pool
+type Shape struct {
+	VCPU       int32
+	MemoryInMb int32
+}
+
+// ParseShape parses a shape formatted as VCPU:MEMORY_MB
+func ParseShape(value string) (Shape, error) {
+	vcpu, memory, ok := strings.Cut(value, ":")
+	if !ok {
+		return Shape{}, fmt.Errorf("invalid shape %q, expected VCPU:MEMORY_MB", value)
+	}
+
+	parsedVCPU, err := strconv.ParseInt(vcpu, 10, 32)
+	if err != nil {
+		return Shape{}, fmt.Errorf("invalid vcpu count in shape %q: %w", value, err)
+	}
+
+	parsedMemory, err := strconv.ParseInt(memory, 10, 32)
+	if err != nil {
+		return Shape{}, fmt.Errorf("invalid memory in shape %q: %w", value, err)
+	}
+
+	return Shape{VCPU: int32(parsedVCPU), MemoryInMb: int32(parsedMemory)}, nil
+}
+
+func (s Shape) String() string {
+	return fmt.Sprintf("%d:%d", s.VCPU, s.MemoryInMb)
+}
+
+type Config struct {
+	// Dir holds a snapshot directory per shape
+	Dir            string
+	StateRoot      string
+	FirecrackerBin string
+	// Kernel and rootfs (with the guest agent) of the template VMs
+	Kernel     string
+	RootfsPath string
+	Shapes     []Shape
+	// Vsock port the guest agent listens on
+	AgentPort uint32
+	// How often the snapshots are checked against the kernel and rootfs
+	RefreshPeriod time.Duration
+}
+
+// Key identifies the snapshot a VM spec can be restored from
+func Key(spec models.MicroVMSpec) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%d", spec.Provider, spec.Kernel, spec.RootfsPath, spec.VCPU, spec.MemoryInMb)))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// SnapshotDir returns the directory of the snapshot spec can be restored
+// from, ok is false if the pool doesn't have one ready
+func SnapshotDir(root string, spec models.MicroVMSpec) (dir string, ok bool) {
+	// The template VMs have no disks besides the rootfs and the image, an
+	// entropy device and the CPU features of the host
+	if spec.Provider != firecracker.HypervisorName || len(spec.Disks) > 0 || spec.DisableEntropy ||
+		spec.CPUTemplate != "" || len(spec.CPUIDModifiers) > 0 {
+		return "", false
+	}
+
+	dir = filepath.Join(root, Key(spec))
+
+	return dir, firecracker.SnapshotExists(dir)
+}
+
+// Manager keeps a snapshot of a paused, booted template VM for each
+// configured shape. Snapshots are restored copy-on-write, so a single one
+// serves any number of concurrent creates
+type Manager struct {
+	cfg    *Config
+	logger *log.Logger
+	vmSvc  ports.MicroVMSnapshotService
+}
+
+func NewManager(logger *log.Logger, cfg *Config) *Manager {
+	vmSvc := firecracker.New(&firecracker.Config{
+		FirecrackerBin: cfg.FirecrackerBin,
+		StateRoot:      cfg.StateRoot,
+	}, afero.NewOsFs())
+
+	return &Manager{
+		cfg:    cfg,
+		logger: logger,
+		//nolint:forcetypeassert
+		vmSvc: vmSvc.(ports.MicroVMSnapshotService),
+	}
+}
+
+func (m *Manager) spec(shape Shape) models.MicroVMSpec {
+	return models.MicroVMSpec{
+		Provider:   firecracker.HypervisorName,
+		Kernel:     m.cfg.Kernel,
+		RootfsPath: m.cfg.RootfsPath,
+		VCPU:       shape.VCPU,
+		MemoryInMb: shape.MemoryInMb,
+		GuestMAC:   TemplateGuestMAC,
+	}
+}
+
+// Run builds the missing or outdated snapshots until ctx is done
+func (m *Manager) Run(ctx context.Context) error {
+	netnsPath, err := ensureNetNS(NetNSName)
+	if err != nil {
+		return fmt.Errorf("failed to set up pool network namespace: %w", err)
+	}
+
+	ticker := time.NewTicker(m.cfg.RefreshPeriod)
+	defer ticker.Stop()
+
+	for {
+		m.refresh(ctx, netnsPath)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) refresh(ctx context.Context, netnsPath string) {
+	wanted := make(map[string]struct{})
+
+	for _, shape := range m.cfg.Shapes {
+		spec := m.spec(shape)
+		key := Key(spec)
+		wanted[key] = struct{}{}
+
+		dir := filepath.Join(m.cfg.Dir, key)
+		if !m.outdated(dir) {
+			continue
+		}
+
+		m.logger.Infof("Building pool snapshot %s for shape %s", key, shape)
+
+		start := time.Now()
+		err := ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
+			return m.vmSvc.CreateSnapshot(ctx, &models.MicroVM{ID: "pool-" + key, Spec: spec}, dir, m.waitForAgent)
+		})
+
+		if recordErr := RecordBuild(m.cfg.Dir, err == nil, time.Since(start)); recordErr != nil {
+			m.logger.WithError(recordErr).Warn("failed to record pool stats")
+		}
+
+		if err != nil {
+			m.logger.WithError(err).Errorf("failed to build pool snapshot for shape %s", shape)
+
+			continue
+		}
+
+		m.logger.Infof("Built pool snapshot %s in %s", key, time.Since(start))
+	}
+
+	entries, err := os.ReadDir(m.cfg.Dir)
+	if err != nil {
+		m.logger.WithError(err).Errorf("failed to list pool snapshots")
+
+		return
+	}
+
+	for _, entry := range entries {
+		if _, ok := wanted[entry.Name()]; ok || !entry.IsDir() {
+			continue
+		}
+
+		m.logger.Infof("Removing unused pool snapshot %s", entry.Name())
+
+		if err := os.RemoveAll(filepath.Join(m.cfg.Dir, entry.Name())); err != nil {
+			m.logger.WithError(err).Errorf("failed to remove pool snapshot %s", entry.Name())
+		}
+	}
+}
+
+// outdated returns whether the snapshot in dir is missing or older than
+// the kernel or rootfs of the template VMs
+func (m *Manager) outdated(dir string) bool {
+	if !firecracker.SnapshotExists(dir) {
+		return true
+	}
+
+	snapshot, err := os.Stat(dir)
+	if err != nil {
+		return true
+	}
+
+	for _, path := range []string{m.cfg.Kernel, m.cfg.RootfsPath} {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().After(snapshot.ModTime()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Manager) waitForAgent(ctx context.Context, vsockPath string) error {
+	conn, err := vsock.DialContext(ctx, vsockPath, m.cfg.AgentPort, vsock.WithRetryTimeout(agentTimeout), vsock.WithLogger(m.logger))
+	if err != nil {
+		return fmt.Errorf("failed to dial guest agent: %w", err)
+	}
+
+	return conn.Close()
+}
+
+// ensureNetNS creates the named network namespace with a tap0 device,
+// if it doesn't exist yet
+func ensureNetNS(name string) (string, error) {
+	path := filepath.Join("/var/run/netns", name)
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := createNamedNetNS(name); err != nil {
+			return "", err
+		}
+	}
+
+	err := ns.WithNetNSPath(path, func(_ ns.NetNS) error {
+		if _, err := netlink.LinkByName("tap0"); err == nil {
+			return nil
+		}
+
+		tap := &netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap0"}, Mode: netlink.TUNTAP_MODE_TAP}
+		if err := netlink.LinkAdd(tap); err != nil {
+			return fmt.Errorf("failed to create tap device: %w", err)
+		}
+
+		// The device is persistent, firecracker opens it by name
+		for _, fd := range tap.Fds {
+			fd.Close()
+		}
+
+		return netlink.LinkSetUp(tap)
+	})
+
+	return path, err
+}
+
+func createNamedNetNS(name string) error {
+	runtime.LockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer origin.Close()
+
+	handle, err := netns.NewNamed(name)
+	if err != nil {
+		runtime.UnlockOSThread()
+
+		return fmt.Errorf("failed to create network namespace %s: %w", name, err)
+	}
+	handle.Close()
+
+	// The thread is left locked, and thus discarded, if it can't be restored
+	if err := netns.Set(origin); err != nil {
+		return fmt.Errorf("failed to restore network namespace: %w", err)
+	}
+
+	runtime.UnlockOSThread()
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"vistara-node/pkg/defaults"
+
+	"golang.org/x/sys/unix"
+)
+
+// Stats are shared by the shims creating VMs and the pool manager, they
+// are kept in the pool directory
+type Stats struct {
+	// VMs restored from a snapshot, and the time it took until their task was created
+	Restores       uint64  `json:"restores"`
+	RestoreSeconds float64 `json:"restore_seconds"`
+	// VMs booted because no snapshot matched their spec
+	ColdBoots       uint64  `json:"cold_boots"`
+	ColdBootSeconds float64 `json:"cold_boot_seconds"`
+	// Snapshots built by the pool manager
+	Builds        uint64  `json:"builds"`
+	BuildFailures uint64  `json:"build_failures"`
+	BuildSeconds  float64 `json:"build_seconds"`
+	// Time spent in each phase of the task creations
+	Phases map[string]*PhaseStats `json:"phases,omitempty"`
+	// Time the VMs took to reach each stage of their boot, by provider
+	Boots []*BootHistogram `json:"boots,omitempty"`
+}
+
+type PhaseStats struct {
+	Count       uint64  `json:"count"`
+	Seconds     float64 `json:"seconds"`
+	MaxSeconds  float64 `json:"max_seconds"`
+	LastSeconds float64 `json:"last_seconds"`
+}
+
+// HitRatio returns the share of VMs that were restored from a snapshot
+func (s Stats) HitRatio() float64 {
+	if s.Restores+s.ColdBoots == 0 {
+		return 0
+	}
+
+	return float64(s.Restores) / float64(s.Restores+s.ColdBoots)
+}
+
+func statsPath(root string) string {
+	return filepath.Join(root, "stats.json")
+}
+
+// ReadStats returns the stats recorded in the pool directory
+func ReadStats(root string) (Stats, error) {
+	var stats Stats
+
+	err := withStatsLock(root, func() error {
+		var err error
+		stats, err = readStats(root)
+
+		return err
+	})
+
+	return stats, err
+}
+
+// RecordCreate records how long it took to create a VM and its task, along
+// with the time spent in each phase
+func RecordCreate(root string, restored bool, elapsed time.Duration, phases map[string]time.Duration) error {
+	return updateStats(root, func(stats *Stats) {
+		if stats.Phases == nil {
+			stats.Phases = make(map[string]*PhaseStats)
+		}
+
+		for phase, phaseElapsed := range phases {
+			phaseStats, ok := stats.Phases[phase]
+			if !ok {
+				phaseStats = &PhaseStats{}
+				stats.Phases[phase] = phaseStats
+			}
+
+			phaseStats.Count++
+			phaseStats.Seconds += phaseElapsed.Seconds()
+			phaseStats.MaxSeconds = max(phaseStats.MaxSeconds, phaseElapsed.Seconds())
+			phaseStats.LastSeconds = phaseElapsed.Seconds()
+		}
+
+		if restored {
+			stats.Restores++
+			stats.RestoreSeconds += elapsed.Seconds()
+		} else {
+			stats.ColdBoots++
+			stats.ColdBootSeconds += elapsed.Seconds()
+		}
+	})
+}
+
+// RecordBuild records the outcome of a snapshot build
+func RecordBuild(root string, succeeded bool, elapsed time.Duration) error {
+	return updateStats(root, func(stats *Stats) {
+		if succeeded {
+			stats.Builds++
+			stats.BuildSeconds += elapsed.Seconds()
+		} else {
+			stats.BuildFailures++
+		}
+	})
+}
+
+func updateStats(root string, update func(stats *Stats)) error {
+	return withStatsLock(root, func() error {
+		stats, err := readStats(root)
+		if err != nil {
+			return err
+		}
+
+		update(&stats)
+
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(statsPath(root), data, defaults.DataFilePerm)
+	})
+}
+
+func readStats(root string) (Stats, error) {
+	var stats Stats
+
+	data, err := os.ReadFile(statsPath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	} else if err != nil {
+		return stats, fmt.Errorf("failed to read pool stats: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("failed to parse pool stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func withStatsLock(root string, fn func() error) error {
+	if err := os.MkdirAll(root, defaults.DataDirPerm); err != nil {
+		return fmt.Errorf("failed to create pool directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(statsPath(root)+".lock", os.O_CREATE|os.O_RDWR, defaults.DataFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open pool stats lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock pool stats: %w", err)
+	}
+	defer func() { _ = unix.Flock(int(lock.Fd()), unix.LOCK_UN) }()
+
+	return fn()
+}
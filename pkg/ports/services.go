@@ -11,6 +11,44 @@ type MicroVMService interface {
 	Stop(ctx context.Context, vm *models.MicroVM) error
 	Pid(ctx context.Context, vm *models.MicroVM) (int, error)
 	VSockPath(vm *models.MicroVM) string
+	// ConsolePath returns the file the guest serial console is written to
+	ConsolePath(vm *models.MicroVM) string
+	// ConsoleSocketPath returns the unix socket serving the serial console,
+	// for as long as the VM runs
+	ConsoleSocketPath(vm *models.MicroVM) string
+}
+
+// MicroVMSnapshotService is implemented by the providers able to start
+// microvms from the snapshot of a booted template instead of booting them.
+type MicroVMSnapshotService interface {
+	// CreateSnapshot boots a template microvm and snapshots it once ready returns.
+	CreateSnapshot(ctx context.Context, vm *models.MicroVM, dir string, ready func(ctx context.Context, vsockPath string) error) error
+	// Restore starts the microvm from the snapshot in dir.
+	Restore(ctx context.Context, vm *models.MicroVM, dir string, completionFn func(error)) error
+}
+
+// MicroVMPauseService is implemented by the providers able to pause the
+// vCPUs of a running microvm.
+type MicroVMPauseService interface {
+	// Pause stops the vCPUs of the microvm, which keeps its memory.
+	Pause(ctx context.Context, vm *models.MicroVM) error
+	// Resume starts the vCPUs of a paused microvm again.
+	Resume(ctx context.Context, vm *models.MicroVM) error
+}
+
+// MicroVMCrashReporter is implemented by the providers able to list the
+// files describing the state of a microvm after it crashed.
+type MicroVMCrashReporter interface {
+	// CrashFiles returns the paths of the files to collect, by file name.
+	CrashFiles(vm *models.MicroVM) map[string]string
+}
+
+// MicroVMDescriber is implemented by the providers able to report the
+// configuration of a running microvm through the API of its hypervisor.
+type MicroVMDescriber interface {
+	// Describe returns the configuration the hypervisor runs the microvm
+	// with, along with its latest metrics.
+	Describe(ctx context.Context, vm *models.MicroVM) (*models.MicroVMDescription, error)
 }
 
 // NetworkService is a port for a service that interacts with the network
@@ -19,7 +19,41 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ClusterService_Spawn_FullMethodName = "/cluster.services.api.ClusterService/Spawn"
+	ClusterService_Spawn_FullMethodName            = "/cluster.services.api.ClusterService/Spawn"
+	ClusterService_Stop_FullMethodName             = "/cluster.services.api.ClusterService/Stop"
+	ClusterService_Restart_FullMethodName          = "/cluster.services.api.ClusterService/Restart"
+	ClusterService_List_FullMethodName             = "/cluster.services.api.ClusterService/List"
+	ClusterService_Logs_FullMethodName             = "/cluster.services.api.ClusterService/Logs"
+	ClusterService_WatchEvents_FullMethodName      = "/cluster.services.api.ClusterService/WatchEvents"
+	ClusterService_Metrics_FullMethodName          = "/cluster.services.api.ClusterService/Metrics"
+	ClusterService_Apply_FullMethodName            = "/cluster.services.api.ClusterService/Apply"
+	ClusterService_Get_FullMethodName              = "/cluster.services.api.ClusterService/Get"
+	ClusterService_SpawnBatch_FullMethodName       = "/cluster.services.api.ClusterService/SpawnBatch"
+	ClusterService_StopMany_FullMethodName         = "/cluster.services.api.ClusterService/StopMany"
+	ClusterService_RestartMany_FullMethodName      = "/cluster.services.api.ClusterService/RestartMany"
+	ClusterService_Config_FullMethodName           = "/cluster.services.api.ClusterService/Config"
+	ClusterService_Billing_FullMethodName          = "/cluster.services.api.ClusterService/Billing"
+	ClusterService_Topology_FullMethodName         = "/cluster.services.api.ClusterService/Topology"
+	ClusterService_Explain_FullMethodName          = "/cluster.services.api.ClusterService/Explain"
+	ClusterService_PlanSpawn_FullMethodName        = "/cluster.services.api.ClusterService/PlanSpawn"
+	ClusterService_CacheStatus_FullMethodName      = "/cluster.services.api.ClusterService/CacheStatus"
+	ClusterService_SnapshotVolume_FullMethodName   = "/cluster.services.api.ClusterService/SnapshotVolume"
+	ClusterService_ListSnapshots_FullMethodName    = "/cluster.services.api.ClusterService/ListSnapshots"
+	ClusterService_UpdateWorkload_FullMethodName   = "/cluster.services.api.ClusterService/UpdateWorkload"
+	ClusterService_Rollback_FullMethodName         = "/cluster.services.api.ClusterService/Rollback"
+	ClusterService_ListRevisions_FullMethodName    = "/cluster.services.api.ClusterService/ListRevisions"
+	ClusterService_Canary_FullMethodName           = "/cluster.services.api.ClusterService/Canary"
+	ClusterService_SetTrafficSplit_FullMethodName  = "/cluster.services.api.ClusterService/SetTrafficSplit"
+	ClusterService_Nodes_FullMethodName            = "/cluster.services.api.ClusterService/Nodes"
+	ClusterService_Join_FullMethodName             = "/cluster.services.api.ClusterService/Join"
+	ClusterService_Drain_FullMethodName            = "/cluster.services.api.ClusterService/Drain"
+	ClusterService_Leave_FullMethodName            = "/cluster.services.api.ClusterService/Leave"
+	ClusterService_CollectCrash_FullMethodName     = "/cluster.services.api.ClusterService/CollectCrash"
+	ClusterService_DescribeWorkload_FullMethodName = "/cluster.services.api.ClusterService/DescribeWorkload"
+	ClusterService_Status_FullMethodName           = "/cluster.services.api.ClusterService/Status"
+	ClusterService_Usage_FullMethodName            = "/cluster.services.api.ClusterService/Usage"
+	ClusterService_Adopt_FullMethodName            = "/cluster.services.api.ClusterService/Adopt"
+	ClusterService_Upgrade_FullMethodName          = "/cluster.services.api.ClusterService/Upgrade"
 )
 
 // ClusterServiceClient is the client API for ClusterService service.
@@ -27,6 +61,87 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ClusterServiceClient interface {
 	Spawn(ctx context.Context, in *VmSpawnRequest, opts ...grpc.CallOption) (*VmSpawnResponse, error)
+	Stop(ctx context.Context, in *VmStopRequest, opts ...grpc.CallOption) (*VmStopResponse, error)
+	// Stops a workload gracefully and creates it again on the same node,
+	// with the same ID, spec, volumes and ports
+	Restart(ctx context.Context, in *RestartRequest, opts ...grpc.CallOption) (*RestartResponse, error)
+	List(ctx context.Context, in *VmQueryRequest, opts ...grpc.CallOption) (*VmQueryResponse, error)
+	Logs(ctx context.Context, in *VmLogsRequest, opts ...grpc.CallOption) (*VmLogsResponse, error)
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEventsResponse], error)
+	Metrics(ctx context.Context, in *MetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error)
+	// Creates or updates the workload with the given name so it
+	// matches the spec, workloads are deleted with Stop
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*WorkloadDescription, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*WorkloadDescription, error)
+	// Places all the requests at once and spawns them concurrently,
+	// a result is streamed for each request as soon as it completes
+	SpawnBatch(ctx context.Context, in *SpawnBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SpawnBatchResponse], error)
+	// Stops the workloads matching a label selector or given by ID with
+	// bounded parallelism, streaming the result of each of them and a
+	// summary
+	StopMany(ctx context.Context, in *BulkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkProgress], error)
+	// Restarts the workloads matching a label selector or given by ID
+	// like StopMany
+	RestartMany(ctx context.Context, in *BulkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkProgress], error)
+	// Returns the effective tuning of the node's cluster agent
+	Config(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
+	// Returns the billing records of the workloads across the cluster
+	Billing(ctx context.Context, in *BillingRequest, opts ...grpc.CallOption) (*BillingResponse, error)
+	// Returns the connections the workloads across the cluster opened
+	// recently, as a graph of who talks to whom
+	Topology(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*TopologyResponse, error)
+	// Runs node selection for the request without spawning it
+	Explain(ctx context.Context, in *VmSpawnRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+	// Runs the placement of the request and of its replicas through the
+	// policies and the capacity of the nodes without creating anything,
+	// returning where they would land and the headroom left
+	PlanSpawn(ctx context.Context, in *VmSpawnRequest, opts ...grpc.CallOption) (*PlanSpawnResponse, error)
+	// Returns the images the nodes pulled ahead of the placements they
+	// are likely to get, and whether they are ready
+	CacheStatus(ctx context.Context, in *CacheStatusRequest, opts ...grpc.CallOption) (*CacheStatusResponse, error)
+	// Snapshots a persistent volume to the object store of the node
+	// holding it, uploading the blocks changed since its last snapshot
+	SnapshotVolume(ctx context.Context, in *SnapshotVolumeRequest, opts ...grpc.CallOption) (*VolumeSnapshot, error)
+	// Lists the snapshots of the volumes in the object store
+	ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error)
+	// Replaces the replicas of a workload one at a time with replicas
+	// running the updated spec, recording a new revision
+	UpdateWorkload(ctx context.Context, in *UpdateWorkloadRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error)
+	// Rolls a workload back to the spec of a previous revision
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error)
+	ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error)
+	// Spawns replicas of the updated spec next to the current ones and
+	// sends them a share of the traffic, promoting or rolling them back
+	// based on the error rate observed by the proxies
+	Canary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error)
+	// Sets how the traffic of a workload is split between its revisions
+	SetTrafficSplit(ctx context.Context, in *TrafficSplit, opts ...grpc.CallOption) (*TrafficSplit, error)
+	// Returns the lifecycle status of the nodes of the cluster
+	Nodes(ctx context.Context, in *NodesRequest, opts ...grpc.CallOption) (*NodesResponse, error)
+	// Makes the node join the cluster of another node
+	Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*Node, error)
+	// Stops placing workloads on a node and moves its workloads to the
+	// other nodes
+	Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*Node, error)
+	// Makes a node leave the cluster
+	Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*Node, error)
+	// Returns the crash bundles the shims wrote for a workload whose VM
+	// exited unexpectedly, from every node of the cluster
+	CollectCrash(ctx context.Context, in *CollectCrashRequest, opts ...grpc.CallOption) (*CollectCrashResponse, error)
+	// Returns the spec of a workload along with the firewall ruleset
+	// active in its network namespace, from the node running it
+	DescribeWorkload(ctx context.Context, in *DescribeWorkloadRequest, opts ...grpc.CallOption) (*DescribeWorkloadResponse, error)
+	// Returns a summary of the health of the cluster as seen by the node
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// Returns the recent resource usage of a workload, from the node
+	// running it
+	Usage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageResponse, error)
+	// Brings a container created outside of hypercore, in the containerd
+	// namespace of a node, under its management as a workload
+	Adopt(ctx context.Context, in *AdoptRequest, opts ...grpc.CallOption) (*AdoptResponse, error)
+	// Replaces the hypercore binary of a node and restarts its agent, the
+	// workloads of the node keep running
+	Upgrade(ctx context.Context, in *UpgradeRequest, opts ...grpc.CallOption) (*UpgradeResponse, error)
 }
 
 type clusterServiceClient struct {
@@ -47,73 +162,1503 @@ func (c *clusterServiceClient) Spawn(ctx context.Context, in *VmSpawnRequest, op
 	return out, nil
 }
 
+func (c *clusterServiceClient) Stop(ctx context.Context, in *VmStopRequest, opts ...grpc.CallOption) (*VmStopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VmStopResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Stop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Restart(ctx context.Context, in *RestartRequest, opts ...grpc.CallOption) (*RestartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestartResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Restart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) List(ctx context.Context, in *VmQueryRequest, opts ...grpc.CallOption) (*VmQueryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VmQueryResponse)
+	err := c.cc.Invoke(ctx, ClusterService_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Logs(ctx context.Context, in *VmLogsRequest, opts ...grpc.CallOption) (*VmLogsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VmLogsResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Logs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEventsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[0], ClusterService_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, WatchEventsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_WatchEventsClient = grpc.ServerStreamingClient[WatchEventsResponse]
+
+func (c *clusterServiceClient) Metrics(ctx context.Context, in *MetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MetricsResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Metrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*WorkloadDescription, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkloadDescription)
+	err := c.cc.Invoke(ctx, ClusterService_Apply_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*WorkloadDescription, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkloadDescription)
+	err := c.cc.Invoke(ctx, ClusterService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SpawnBatch(ctx context.Context, in *SpawnBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SpawnBatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[1], ClusterService_SpawnBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SpawnBatchRequest, SpawnBatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_SpawnBatchClient = grpc.ServerStreamingClient[SpawnBatchResponse]
+
+func (c *clusterServiceClient) StopMany(ctx context.Context, in *BulkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[2], ClusterService_StopMany_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BulkRequest, BulkProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_StopManyClient = grpc.ServerStreamingClient[BulkProgress]
+
+func (c *clusterServiceClient) RestartMany(ctx context.Context, in *BulkRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[3], ClusterService_RestartMany_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BulkRequest, BulkProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_RestartManyClient = grpc.ServerStreamingClient[BulkProgress]
+
+func (c *clusterServiceClient) Config(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Config_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Billing(ctx context.Context, in *BillingRequest, opts ...grpc.CallOption) (*BillingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BillingResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Billing_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Topology(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*TopologyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TopologyResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Topology_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Explain(ctx context.Context, in *VmSpawnRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Explain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) PlanSpawn(ctx context.Context, in *VmSpawnRequest, opts ...grpc.CallOption) (*PlanSpawnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PlanSpawnResponse)
+	err := c.cc.Invoke(ctx, ClusterService_PlanSpawn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) CacheStatus(ctx context.Context, in *CacheStatusRequest, opts ...grpc.CallOption) (*CacheStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CacheStatusResponse)
+	err := c.cc.Invoke(ctx, ClusterService_CacheStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SnapshotVolume(ctx context.Context, in *SnapshotVolumeRequest, opts ...grpc.CallOption) (*VolumeSnapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VolumeSnapshot)
+	err := c.cc.Invoke(ctx, ClusterService_SnapshotVolume_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSnapshotsResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListSnapshots_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) UpdateWorkload(ctx context.Context, in *UpdateWorkloadRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateWorkloadResponse)
+	err := c.cc.Invoke(ctx, ClusterService_UpdateWorkload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateWorkloadResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Rollback_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRevisionsResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Canary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*UpdateWorkloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateWorkloadResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Canary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SetTrafficSplit(ctx context.Context, in *TrafficSplit, opts ...grpc.CallOption) (*TrafficSplit, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TrafficSplit)
+	err := c.cc.Invoke(ctx, ClusterService_SetTrafficSplit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Nodes(ctx context.Context, in *NodesRequest, opts ...grpc.CallOption) (*NodesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NodesResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Nodes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*Node, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Node)
+	err := c.cc.Invoke(ctx, ClusterService_Join_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*Node, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Node)
+	err := c.cc.Invoke(ctx, ClusterService_Drain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*Node, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Node)
+	err := c.cc.Invoke(ctx, ClusterService_Leave_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) CollectCrash(ctx context.Context, in *CollectCrashRequest, opts ...grpc.CallOption) (*CollectCrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CollectCrashResponse)
+	err := c.cc.Invoke(ctx, ClusterService_CollectCrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) DescribeWorkload(ctx context.Context, in *DescribeWorkloadRequest, opts ...grpc.CallOption) (*DescribeWorkloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DescribeWorkloadResponse)
+	err := c.cc.Invoke(ctx, ClusterService_DescribeWorkload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Usage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UsageResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Usage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Adopt(ctx context.Context, in *AdoptRequest, opts ...grpc.CallOption) (*AdoptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdoptResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Adopt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Upgrade(ctx context.Context, in *UpgradeRequest, opts ...grpc.CallOption) (*UpgradeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpgradeResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Upgrade_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ClusterServiceServer is the server API for ClusterService service.
 // All implementations must embed UnimplementedClusterServiceServer
 // for forward compatibility.
-type ClusterServiceServer interface {
-	Spawn(context.Context, *VmSpawnRequest) (*VmSpawnResponse, error)
-	mustEmbedUnimplementedClusterServiceServer()
+type ClusterServiceServer interface {
+	Spawn(context.Context, *VmSpawnRequest) (*VmSpawnResponse, error)
+	Stop(context.Context, *VmStopRequest) (*VmStopResponse, error)
+	// Stops a workload gracefully and creates it again on the same node,
+	// with the same ID, spec, volumes and ports
+	Restart(context.Context, *RestartRequest) (*RestartResponse, error)
+	List(context.Context, *VmQueryRequest) (*VmQueryResponse, error)
+	Logs(context.Context, *VmLogsRequest) (*VmLogsResponse, error)
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[WatchEventsResponse]) error
+	Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error)
+	// Creates or updates the workload with the given name so it
+	// matches the spec, workloads are deleted with Stop
+	Apply(context.Context, *ApplyRequest) (*WorkloadDescription, error)
+	Get(context.Context, *GetRequest) (*WorkloadDescription, error)
+	// Places all the requests at once and spawns them concurrently,
+	// a result is streamed for each request as soon as it completes
+	SpawnBatch(*SpawnBatchRequest, grpc.ServerStreamingServer[SpawnBatchResponse]) error
+	// Stops the workloads matching a label selector or given by ID with
+	// bounded parallelism, streaming the result of each of them and a
+	// summary
+	StopMany(*BulkRequest, grpc.ServerStreamingServer[BulkProgress]) error
+	// Restarts the workloads matching a label selector or given by ID
+	// like StopMany
+	RestartMany(*BulkRequest, grpc.ServerStreamingServer[BulkProgress]) error
+	// Returns the effective tuning of the node's cluster agent
+	Config(context.Context, *ConfigRequest) (*ConfigResponse, error)
+	// Returns the billing records of the workloads across the cluster
+	Billing(context.Context, *BillingRequest) (*BillingResponse, error)
+	// Returns the connections the workloads across the cluster opened
+	// recently, as a graph of who talks to whom
+	Topology(context.Context, *TopologyRequest) (*TopologyResponse, error)
+	// Runs node selection for the request without spawning it
+	Explain(context.Context, *VmSpawnRequest) (*ExplainResponse, error)
+	// Runs the placement of the request and of its replicas through the
+	// policies and the capacity of the nodes without creating anything,
+	// returning where they would land and the headroom left
+	PlanSpawn(context.Context, *VmSpawnRequest) (*PlanSpawnResponse, error)
+	// Returns the images the nodes pulled ahead of the placements they
+	// are likely to get, and whether they are ready
+	CacheStatus(context.Context, *CacheStatusRequest) (*CacheStatusResponse, error)
+	// Snapshots a persistent volume to the object store of the node
+	// holding it, uploading the blocks changed since its last snapshot
+	SnapshotVolume(context.Context, *SnapshotVolumeRequest) (*VolumeSnapshot, error)
+	// Lists the snapshots of the volumes in the object store
+	ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error)
+	// Replaces the replicas of a workload one at a time with replicas
+	// running the updated spec, recording a new revision
+	UpdateWorkload(context.Context, *UpdateWorkloadRequest) (*UpdateWorkloadResponse, error)
+	// Rolls a workload back to the spec of a previous revision
+	Rollback(context.Context, *RollbackRequest) (*UpdateWorkloadResponse, error)
+	ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error)
+	// Spawns replicas of the updated spec next to the current ones and
+	// sends them a share of the traffic, promoting or rolling them back
+	// based on the error rate observed by the proxies
+	Canary(context.Context, *CanaryRequest) (*UpdateWorkloadResponse, error)
+	// Sets how the traffic of a workload is split between its revisions
+	SetTrafficSplit(context.Context, *TrafficSplit) (*TrafficSplit, error)
+	// Returns the lifecycle status of the nodes of the cluster
+	Nodes(context.Context, *NodesRequest) (*NodesResponse, error)
+	// Makes the node join the cluster of another node
+	Join(context.Context, *JoinRequest) (*Node, error)
+	// Stops placing workloads on a node and moves its workloads to the
+	// other nodes
+	Drain(context.Context, *DrainRequest) (*Node, error)
+	// Makes a node leave the cluster
+	Leave(context.Context, *LeaveRequest) (*Node, error)
+	// Returns the crash bundles the shims wrote for a workload whose VM
+	// exited unexpectedly, from every node of the cluster
+	CollectCrash(context.Context, *CollectCrashRequest) (*CollectCrashResponse, error)
+	// Returns the spec of a workload along with the firewall ruleset
+	// active in its network namespace, from the node running it
+	DescribeWorkload(context.Context, *DescribeWorkloadRequest) (*DescribeWorkloadResponse, error)
+	// Returns a summary of the health of the cluster as seen by the node
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// Returns the recent resource usage of a workload, from the node
+	// running it
+	Usage(context.Context, *UsageRequest) (*UsageResponse, error)
+	// Brings a container created outside of hypercore, in the containerd
+	// namespace of a node, under its management as a workload
+	Adopt(context.Context, *AdoptRequest) (*AdoptResponse, error)
+	// Replaces the hypercore binary of a node and restarts its agent, the
+	// workloads of the node keep running
+	Upgrade(context.Context, *UpgradeRequest) (*UpgradeResponse, error)
+	mustEmbedUnimplementedClusterServiceServer()
+}
+
+// UnimplementedClusterServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedClusterServiceServer struct{}
+
+func (UnimplementedClusterServiceServer) Spawn(context.Context, *VmSpawnRequest) (*VmSpawnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Spawn not implemented")
+}
+func (UnimplementedClusterServiceServer) Stop(context.Context, *VmStopRequest) (*VmStopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedClusterServiceServer) Restart(context.Context, *RestartRequest) (*RestartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restart not implemented")
+}
+func (UnimplementedClusterServiceServer) List(context.Context, *VmQueryRequest) (*VmQueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedClusterServiceServer) Logs(context.Context, *VmLogsRequest) (*VmLogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logs not implemented")
+}
+func (UnimplementedClusterServiceServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[WatchEventsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedClusterServiceServer) Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Metrics not implemented")
+}
+func (UnimplementedClusterServiceServer) Apply(context.Context, *ApplyRequest) (*WorkloadDescription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedClusterServiceServer) Get(context.Context, *GetRequest) (*WorkloadDescription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedClusterServiceServer) SpawnBatch(*SpawnBatchRequest, grpc.ServerStreamingServer[SpawnBatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SpawnBatch not implemented")
+}
+func (UnimplementedClusterServiceServer) StopMany(*BulkRequest, grpc.ServerStreamingServer[BulkProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method StopMany not implemented")
+}
+func (UnimplementedClusterServiceServer) RestartMany(*BulkRequest, grpc.ServerStreamingServer[BulkProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method RestartMany not implemented")
+}
+func (UnimplementedClusterServiceServer) Config(context.Context, *ConfigRequest) (*ConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Config not implemented")
+}
+func (UnimplementedClusterServiceServer) Billing(context.Context, *BillingRequest) (*BillingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Billing not implemented")
+}
+func (UnimplementedClusterServiceServer) Topology(context.Context, *TopologyRequest) (*TopologyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Topology not implemented")
+}
+func (UnimplementedClusterServiceServer) Explain(context.Context, *VmSpawnRequest) (*ExplainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
+func (UnimplementedClusterServiceServer) PlanSpawn(context.Context, *VmSpawnRequest) (*PlanSpawnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PlanSpawn not implemented")
+}
+func (UnimplementedClusterServiceServer) CacheStatus(context.Context, *CacheStatusRequest) (*CacheStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CacheStatus not implemented")
+}
+func (UnimplementedClusterServiceServer) SnapshotVolume(context.Context, *SnapshotVolumeRequest) (*VolumeSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SnapshotVolume not implemented")
+}
+func (UnimplementedClusterServiceServer) ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (UnimplementedClusterServiceServer) UpdateWorkload(context.Context, *UpdateWorkloadRequest) (*UpdateWorkloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateWorkload not implemented")
+}
+func (UnimplementedClusterServiceServer) Rollback(context.Context, *RollbackRequest) (*UpdateWorkloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedClusterServiceServer) ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRevisions not implemented")
+}
+func (UnimplementedClusterServiceServer) Canary(context.Context, *CanaryRequest) (*UpdateWorkloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Canary not implemented")
+}
+func (UnimplementedClusterServiceServer) SetTrafficSplit(context.Context, *TrafficSplit) (*TrafficSplit, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTrafficSplit not implemented")
+}
+func (UnimplementedClusterServiceServer) Nodes(context.Context, *NodesRequest) (*NodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Nodes not implemented")
+}
+func (UnimplementedClusterServiceServer) Join(context.Context, *JoinRequest) (*Node, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Join not implemented")
+}
+func (UnimplementedClusterServiceServer) Drain(context.Context, *DrainRequest) (*Node, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Drain not implemented")
+}
+func (UnimplementedClusterServiceServer) Leave(context.Context, *LeaveRequest) (*Node, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Leave not implemented")
+}
+func (UnimplementedClusterServiceServer) CollectCrash(context.Context, *CollectCrashRequest) (*CollectCrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CollectCrash not implemented")
+}
+func (UnimplementedClusterServiceServer) DescribeWorkload(context.Context, *DescribeWorkloadRequest) (*DescribeWorkloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DescribeWorkload not implemented")
+}
+func (UnimplementedClusterServiceServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedClusterServiceServer) Usage(context.Context, *UsageRequest) (*UsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Usage not implemented")
+}
+func (UnimplementedClusterServiceServer) Adopt(context.Context, *AdoptRequest) (*AdoptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Adopt not implemented")
+}
+func (UnimplementedClusterServiceServer) Upgrade(context.Context, *UpgradeRequest) (*UpgradeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Upgrade not implemented")
+}
+func (UnimplementedClusterServiceServer) mustEmbedUnimplementedClusterServiceServer() {}
+func (UnimplementedClusterServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeClusterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClusterServiceServer will
+// result in compilation errors.
+type UnsafeClusterServiceServer interface {
+	mustEmbedUnimplementedClusterServiceServer()
+}
+
+func RegisterClusterServiceServer(s grpc.ServiceRegistrar, srv ClusterServiceServer) {
+	// If the following call pancis, it indicates UnimplementedClusterServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ClusterService_ServiceDesc, srv)
+}
+
+func _ClusterService_Spawn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmSpawnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Spawn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Spawn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Spawn(ctx, req.(*VmSpawnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Stop(ctx, req.(*VmStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Restart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Restart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Restart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Restart(ctx, req.(*RestartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmQueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).List(ctx, req.(*VmQueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Logs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Logs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Logs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Logs(ctx, req.(*VmLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, WatchEventsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_WatchEventsServer = grpc.ServerStreamingServer[WatchEventsResponse]
+
+func _ClusterService_Metrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Metrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Metrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Metrics(ctx, req.(*MetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Apply_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Apply(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Apply_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Apply(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SpawnBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SpawnBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).SpawnBatch(m, &grpc.GenericServerStream[SpawnBatchRequest, SpawnBatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_SpawnBatchServer = grpc.ServerStreamingServer[SpawnBatchResponse]
+
+func _ClusterService_StopMany_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BulkRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).StopMany(m, &grpc.GenericServerStream[BulkRequest, BulkProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_StopManyServer = grpc.ServerStreamingServer[BulkProgress]
+
+func _ClusterService_RestartMany_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BulkRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).RestartMany(m, &grpc.GenericServerStream[BulkRequest, BulkProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClusterService_RestartManyServer = grpc.ServerStreamingServer[BulkProgress]
+
+func _ClusterService_Config_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Config(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Config_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Config(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Billing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BillingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Billing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Billing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Billing(ctx, req.(*BillingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Topology_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopologyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Topology(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Topology_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Topology(ctx, req.(*TopologyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmSpawnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Explain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Explain(ctx, req.(*VmSpawnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_PlanSpawn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VmSpawnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).PlanSpawn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_PlanSpawn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).PlanSpawn(ctx, req.(*VmSpawnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_CacheStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CacheStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).CacheStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_CacheStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).CacheStatus(ctx, req.(*CacheStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SnapshotVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).SnapshotVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_SnapshotVolume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).SnapshotVolume(ctx, req.(*SnapshotVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListSnapshots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListSnapshots(ctx, req.(*ListSnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_UpdateWorkload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateWorkloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).UpdateWorkload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_UpdateWorkload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).UpdateWorkload(ctx, req.(*UpdateWorkloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Rollback_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListRevisions(ctx, req.(*ListRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Canary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Canary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Canary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Canary(ctx, req.(*CanaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SetTrafficSplit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrafficSplit)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).SetTrafficSplit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_SetTrafficSplit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).SetTrafficSplit(ctx, req.(*TrafficSplit))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Nodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Nodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Nodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Nodes(ctx, req.(*NodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Join_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Join(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Join_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Join(ctx, req.(*JoinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Drain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Drain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Drain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Drain(ctx, req.(*DrainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Leave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Leave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Leave_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Leave(ctx, req.(*LeaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_CollectCrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CollectCrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).CollectCrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_CollectCrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).CollectCrash(ctx, req.(*CollectCrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_DescribeWorkload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeWorkloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).DescribeWorkload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_DescribeWorkload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).DescribeWorkload(ctx, req.(*DescribeWorkloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Usage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Usage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Usage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Usage(ctx, req.(*UsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Adopt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdoptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Adopt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Adopt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Adopt(ctx, req.(*AdoptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Upgrade_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpgradeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Upgrade(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Upgrade_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Upgrade(ctx, req.(*UpgradeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ClusterService_ServiceDesc is the grpc.ServiceDesc for ClusterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ClusterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cluster.services.api.ClusterService",
+	HandlerType: (*ClusterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Spawn",
+			Handler:    _ClusterService_Spawn_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _ClusterService_Stop_Handler,
+		},
+		{
+			MethodName: "Restart",
+			Handler:    _ClusterService_Restart_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _ClusterService_List_Handler,
+		},
+		{
+			MethodName: "Logs",
+			Handler:    _ClusterService_Logs_Handler,
+		},
+		{
+			MethodName: "Metrics",
+			Handler:    _ClusterService_Metrics_Handler,
+		},
+		{
+			MethodName: "Apply",
+			Handler:    _ClusterService_Apply_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _ClusterService_Get_Handler,
+		},
+		{
+			MethodName: "Config",
+			Handler:    _ClusterService_Config_Handler,
+		},
+		{
+			MethodName: "Billing",
+			Handler:    _ClusterService_Billing_Handler,
+		},
+		{
+			MethodName: "Topology",
+			Handler:    _ClusterService_Topology_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _ClusterService_Explain_Handler,
+		},
+		{
+			MethodName: "PlanSpawn",
+			Handler:    _ClusterService_PlanSpawn_Handler,
+		},
+		{
+			MethodName: "CacheStatus",
+			Handler:    _ClusterService_CacheStatus_Handler,
+		},
+		{
+			MethodName: "SnapshotVolume",
+			Handler:    _ClusterService_SnapshotVolume_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _ClusterService_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "UpdateWorkload",
+			Handler:    _ClusterService_UpdateWorkload_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _ClusterService_Rollback_Handler,
+		},
+		{
+			MethodName: "ListRevisions",
+			Handler:    _ClusterService_ListRevisions_Handler,
+		},
+		{
+			MethodName: "Canary",
+			Handler:    _ClusterService_Canary_Handler,
+		},
+		{
+			MethodName: "SetTrafficSplit",
+			Handler:    _ClusterService_SetTrafficSplit_Handler,
+		},
+		{
+			MethodName: "Nodes",
+			Handler:    _ClusterService_Nodes_Handler,
+		},
+		{
+			MethodName: "Join",
+			Handler:    _ClusterService_Join_Handler,
+		},
+		{
+			MethodName: "Drain",
+			Handler:    _ClusterService_Drain_Handler,
+		},
+		{
+			MethodName: "Leave",
+			Handler:    _ClusterService_Leave_Handler,
+		},
+		{
+			MethodName: "CollectCrash",
+			Handler:    _ClusterService_CollectCrash_Handler,
+		},
+		{
+			MethodName: "DescribeWorkload",
+			Handler:    _ClusterService_DescribeWorkload_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _ClusterService_Status_Handler,
+		},
+		{
+			MethodName: "Usage",
+			Handler:    _ClusterService_Usage_Handler,
+		},
+		{
+			MethodName: "Adopt",
+			Handler:    _ClusterService_Adopt_Handler,
+		},
+		{
+			MethodName: "Upgrade",
+			Handler:    _ClusterService_Upgrade_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _ClusterService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SpawnBatch",
+			Handler:       _ClusterService_SpawnBatch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StopMany",
+			Handler:       _ClusterService_StopMany_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RestartMany",
+			Handler:       _ClusterService_RestartMany_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/proto/cluster.proto",
+}
+
+const (
+	DebugService_SetFaults_FullMethodName = "/cluster.services.api.DebugService/SetFaults"
+	DebugService_GetFaults_FullMethodName = "/cluster.services.api.DebugService/GetFaults"
+)
+
+// DebugServiceClient is the client API for DebugService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Only served by nodes built with the chaos tag, to inject faults into
+// the cluster agent of the node
+type DebugServiceClient interface {
+	SetFaults(ctx context.Context, in *FaultConfig, opts ...grpc.CallOption) (*FaultConfig, error)
+	GetFaults(ctx context.Context, in *GetFaultsRequest, opts ...grpc.CallOption) (*FaultConfig, error)
+}
+
+type debugServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDebugServiceClient(cc grpc.ClientConnInterface) DebugServiceClient {
+	return &debugServiceClient{cc}
+}
+
+func (c *debugServiceClient) SetFaults(ctx context.Context, in *FaultConfig, opts ...grpc.CallOption) (*FaultConfig, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FaultConfig)
+	err := c.cc.Invoke(ctx, DebugService_SetFaults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *debugServiceClient) GetFaults(ctx context.Context, in *GetFaultsRequest, opts ...grpc.CallOption) (*FaultConfig, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FaultConfig)
+	err := c.cc.Invoke(ctx, DebugService_GetFaults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DebugServiceServer is the server API for DebugService service.
+// All implementations must embed UnimplementedDebugServiceServer
+// for forward compatibility.
+//
+// Only served by nodes built with the chaos tag, to inject faults into
+// the cluster agent of the node
+type DebugServiceServer interface {
+	SetFaults(context.Context, *FaultConfig) (*FaultConfig, error)
+	GetFaults(context.Context, *GetFaultsRequest) (*FaultConfig, error)
+	mustEmbedUnimplementedDebugServiceServer()
 }
 
-// UnimplementedClusterServiceServer must be embedded to have
+// UnimplementedDebugServiceServer must be embedded to have
 // forward compatible implementations.
 //
 // NOTE: this should be embedded by value instead of pointer to avoid a nil
 // pointer dereference when methods are called.
-type UnimplementedClusterServiceServer struct{}
+type UnimplementedDebugServiceServer struct{}
 
-func (UnimplementedClusterServiceServer) Spawn(context.Context, *VmSpawnRequest) (*VmSpawnResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Spawn not implemented")
+func (UnimplementedDebugServiceServer) SetFaults(context.Context, *FaultConfig) (*FaultConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFaults not implemented")
 }
-func (UnimplementedClusterServiceServer) mustEmbedUnimplementedClusterServiceServer() {}
-func (UnimplementedClusterServiceServer) testEmbeddedByValue()                        {}
+func (UnimplementedDebugServiceServer) GetFaults(context.Context, *GetFaultsRequest) (*FaultConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFaults not implemented")
+}
+func (UnimplementedDebugServiceServer) mustEmbedUnimplementedDebugServiceServer() {}
+func (UnimplementedDebugServiceServer) testEmbeddedByValue()                      {}
 
-// UnsafeClusterServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to ClusterServiceServer will
+// UnsafeDebugServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DebugServiceServer will
 // result in compilation errors.
-type UnsafeClusterServiceServer interface {
-	mustEmbedUnimplementedClusterServiceServer()
+type UnsafeDebugServiceServer interface {
+	mustEmbedUnimplementedDebugServiceServer()
 }
 
-func RegisterClusterServiceServer(s grpc.ServiceRegistrar, srv ClusterServiceServer) {
-	// If the following call pancis, it indicates UnimplementedClusterServiceServer was
+func RegisterDebugServiceServer(s grpc.ServiceRegistrar, srv DebugServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDebugServiceServer was
 	// embedded by pointer and is nil.  This will cause panics if an
 	// unimplemented method is ever invoked, so we test this at initialization
 	// time to prevent it from happening at runtime later due to I/O.
 	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
 		t.testEmbeddedByValue()
 	}
-	s.RegisterService(&ClusterService_ServiceDesc, srv)
+	s.RegisterService(&DebugService_ServiceDesc, srv)
 }
 
-func _ClusterService_Spawn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(VmSpawnRequest)
+func _DebugService_SetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FaultConfig)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ClusterServiceServer).Spawn(ctx, in)
+		return srv.(DebugServiceServer).SetFaults(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ClusterService_Spawn_FullMethodName,
+		FullMethod: DebugService_SetFaults_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ClusterServiceServer).Spawn(ctx, req.(*VmSpawnRequest))
+		return srv.(DebugServiceServer).SetFaults(ctx, req.(*FaultConfig))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-// ClusterService_ServiceDesc is the grpc.ServiceDesc for ClusterService service.
+func _DebugService_GetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFaultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DebugServiceServer).GetFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DebugService_GetFaults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DebugServiceServer).GetFaults(ctx, req.(*GetFaultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DebugService_ServiceDesc is the grpc.ServiceDesc for DebugService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
-var ClusterService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "cluster.services.api.ClusterService",
-	HandlerType: (*ClusterServiceServer)(nil),
+var DebugService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cluster.services.api.DebugService",
+	HandlerType: (*DebugServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "Spawn",
-			Handler:    _ClusterService_Spawn_Handler,
+			MethodName: "SetFaults",
+			Handler:    _DebugService_SetFaults_Handler,
+		},
+		{
+			MethodName: "GetFaults",
+			Handler:    _DebugService_GetFaults_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
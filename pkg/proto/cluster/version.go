@@ -0,0 +1,9 @@
+package cluster
+
+const (
+	// APIVersion is the version of the ClusterService API, bumped on
+	// breaking changes to its messages or semantics
+	APIVersion = "v1"
+	// Metadata key clients use to send the API version they were built against
+	APIVersionMetadataKey = "hypercore-api-version"
+)
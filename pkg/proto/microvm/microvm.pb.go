@@ -0,0 +1,945 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.28.3
+// source: pkg/proto/microvm.proto
+
+package microvm
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MicroVMState int32
+
+const (
+	MicroVMState_UNKNOWN MicroVMState = 0
+	MicroVMState_CREATED MicroVMState = 1
+	MicroVMState_RUNNING MicroVMState = 2
+	MicroVMState_STOPPED MicroVMState = 3
+	MicroVMState_PAUSED  MicroVMState = 4
+	MicroVMState_PAUSING MicroVMState = 5
+)
+
+// Enum value maps for MicroVMState.
+var (
+	MicroVMState_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "CREATED",
+		2: "RUNNING",
+		3: "STOPPED",
+		4: "PAUSED",
+		5: "PAUSING",
+	}
+	MicroVMState_value = map[string]int32{
+		"UNKNOWN": 0,
+		"CREATED": 1,
+		"RUNNING": 2,
+		"STOPPED": 3,
+		"PAUSED":  4,
+		"PAUSING": 5,
+	}
+)
+
+func (x MicroVMState) Enum() *MicroVMState {
+	p := new(MicroVMState)
+	*p = x
+	return p
+}
+
+func (x MicroVMState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MicroVMState) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_proto_microvm_proto_enumTypes[0].Descriptor()
+}
+
+func (MicroVMState) Type() protoreflect.EnumType {
+	return &file_pkg_proto_microvm_proto_enumTypes[0]
+}
+
+func (x MicroVMState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MicroVMState.Descriptor instead.
+func (MicroVMState) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{0}
+}
+
+type MicroVMSpec struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// firecracker or cloudhypervisor
+	Provider   string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	ImageRef   string `protobuf:"bytes,2,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	Vcpu       int32  `protobuf:"varint,3,opt,name=vcpu,proto3" json:"vcpu,omitempty"`
+	MemoryMb   int32  `protobuf:"varint,4,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	Kernel     string `protobuf:"bytes,5,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	RootfsPath string `protobuf:"bytes,6,opt,name=rootfs_path,json=rootfsPath,proto3" json:"rootfs_path,omitempty"`
+	HostNetDev string `protobuf:"bytes,7,opt,name=host_net_dev,json=hostNetDev,proto3" json:"host_net_dev,omitempty"`
+	GuestMac   string `protobuf:"bytes,8,opt,name=guest_mac,json=guestMac,proto3" json:"guest_mac,omitempty"`
+	// guest architecture, x86_64 or aarch64, the one of the host if empty
+	Arch string `protobuf:"bytes,9,opt,name=arch,proto3" json:"arch,omitempty"`
+}
+
+func (x *MicroVMSpec) Reset() {
+	*x = MicroVMSpec{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MicroVMSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MicroVMSpec) ProtoMessage() {}
+
+func (x *MicroVMSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MicroVMSpec.ProtoReflect.Descriptor instead.
+func (*MicroVMSpec) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MicroVMSpec) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetImageRef() string {
+	if x != nil {
+		return x.ImageRef
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetVcpu() int32 {
+	if x != nil {
+		return x.Vcpu
+	}
+	return 0
+}
+
+func (x *MicroVMSpec) GetMemoryMb() int32 {
+	if x != nil {
+		return x.MemoryMb
+	}
+	return 0
+}
+
+func (x *MicroVMSpec) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetRootfsPath() string {
+	if x != nil {
+		return x.RootfsPath
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetHostNetDev() string {
+	if x != nil {
+		return x.HostNetDev
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetGuestMac() string {
+	if x != nil {
+		return x.GuestMac
+	}
+	return ""
+}
+
+func (x *MicroVMSpec) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+type MicroVM struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id   string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Spec *MicroVMSpec `protobuf:"bytes,2,opt,name=spec,proto3" json:"spec,omitempty"`
+	// live state of the VM task
+	State      MicroVMState `protobuf:"varint,3,opt,name=state,proto3,enum=microvm.services.api.MicroVMState" json:"state,omitempty"`
+	Pid        uint32       `protobuf:"varint,4,opt,name=pid,proto3" json:"pid,omitempty"`
+	Ip         string       `protobuf:"bytes,5,opt,name=ip,proto3" json:"ip,omitempty"`
+	ExitStatus uint32       `protobuf:"varint,6,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
+}
+
+func (x *MicroVM) Reset() {
+	*x = MicroVM{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MicroVM) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MicroVM) ProtoMessage() {}
+
+func (x *MicroVM) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MicroVM.ProtoReflect.Descriptor instead.
+func (*MicroVM) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MicroVM) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MicroVM) GetSpec() *MicroVMSpec {
+	if x != nil {
+		return x.Spec
+	}
+	return nil
+}
+
+func (x *MicroVM) GetState() MicroVMState {
+	if x != nil {
+		return x.State
+	}
+	return MicroVMState_UNKNOWN
+}
+
+func (x *MicroVM) GetPid() uint32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *MicroVM) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *MicroVM) GetExitStatus() uint32 {
+	if x != nil {
+		return x.ExitStatus
+	}
+	return 0
+}
+
+type CreateMicroVMRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Spec *MicroVMSpec `protobuf:"bytes,1,opt,name=spec,proto3" json:"spec,omitempty"`
+}
+
+func (x *CreateMicroVMRequest) Reset() {
+	*x = CreateMicroVMRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateMicroVMRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateMicroVMRequest) ProtoMessage() {}
+
+func (x *CreateMicroVMRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateMicroVMRequest.ProtoReflect.Descriptor instead.
+func (*CreateMicroVMRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateMicroVMRequest) GetSpec() *MicroVMSpec {
+	if x != nil {
+		return x.Spec
+	}
+	return nil
+}
+
+type CreateMicroVMResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Microvm *MicroVM `protobuf:"bytes,1,opt,name=microvm,proto3" json:"microvm,omitempty"`
+}
+
+func (x *CreateMicroVMResponse) Reset() {
+	*x = CreateMicroVMResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateMicroVMResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateMicroVMResponse) ProtoMessage() {}
+
+func (x *CreateMicroVMResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateMicroVMResponse.ProtoReflect.Descriptor instead.
+func (*CreateMicroVMResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateMicroVMResponse) GetMicrovm() *MicroVM {
+	if x != nil {
+		return x.Microvm
+	}
+	return nil
+}
+
+type DeleteMicroVMRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *DeleteMicroVMRequest) Reset() {
+	*x = DeleteMicroVMRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteMicroVMRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMicroVMRequest) ProtoMessage() {}
+
+func (x *DeleteMicroVMRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMicroVMRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMicroVMRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteMicroVMRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteMicroVMResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ExitStatus uint32 `protobuf:"varint,1,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
+}
+
+func (x *DeleteMicroVMResponse) Reset() {
+	*x = DeleteMicroVMResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteMicroVMResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMicroVMResponse) ProtoMessage() {}
+
+func (x *DeleteMicroVMResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMicroVMResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMicroVMResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteMicroVMResponse) GetExitStatus() uint32 {
+	if x != nil {
+		return x.ExitStatus
+	}
+	return 0
+}
+
+type GetMicroVMRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *GetMicroVMRequest) Reset() {
+	*x = GetMicroVMRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetMicroVMRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMicroVMRequest) ProtoMessage() {}
+
+func (x *GetMicroVMRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMicroVMRequest.ProtoReflect.Descriptor instead.
+func (*GetMicroVMRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetMicroVMRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetMicroVMResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Microvm *MicroVM `protobuf:"bytes,1,opt,name=microvm,proto3" json:"microvm,omitempty"`
+}
+
+func (x *GetMicroVMResponse) Reset() {
+	*x = GetMicroVMResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetMicroVMResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMicroVMResponse) ProtoMessage() {}
+
+func (x *GetMicroVMResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMicroVMResponse.ProtoReflect.Descriptor instead.
+func (*GetMicroVMResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetMicroVMResponse) GetMicrovm() *MicroVM {
+	if x != nil {
+		return x.Microvm
+	}
+	return nil
+}
+
+type ListMicroVMsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListMicroVMsRequest) Reset() {
+	*x = ListMicroVMsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListMicroVMsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMicroVMsRequest) ProtoMessage() {}
+
+func (x *ListMicroVMsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMicroVMsRequest.ProtoReflect.Descriptor instead.
+func (*ListMicroVMsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{8}
+}
+
+type ListMicroVMsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Microvms []*MicroVM `protobuf:"bytes,1,rep,name=microvms,proto3" json:"microvms,omitempty"`
+}
+
+func (x *ListMicroVMsResponse) Reset() {
+	*x = ListMicroVMsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_microvm_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListMicroVMsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMicroVMsResponse) ProtoMessage() {}
+
+func (x *ListMicroVMsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_microvm_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMicroVMsResponse.ProtoReflect.Descriptor instead.
+func (*ListMicroVMsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_microvm_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListMicroVMsResponse) GetMicrovms() []*MicroVM {
+	if x != nil {
+		return x.Microvms
+	}
+	return nil
+}
+
+var File_pkg_proto_microvm_proto protoreflect.FileDescriptor
+
+var file_pkg_proto_microvm_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6d, 0x69, 0x63, 0x72,
+	0x6f, 0x76, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x6d, 0x69, 0x63, 0x72, 0x6f,
+	0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x22,
+	0x83, 0x02, 0x0a, 0x0b, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x53, 0x70, 0x65, 0x63, 0x12,
+	0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12, 0x1b, 0x0a, 0x09, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x5f, 0x72, 0x65, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x52, 0x65, 0x66, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x63, 0x70, 0x75,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x76, 0x63, 0x70, 0x75, 0x12, 0x1b, 0x0a, 0x09,
+	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6d, 0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x08, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x62, 0x12, 0x16, 0x0a, 0x06, 0x6b, 0x65, 0x72,
+	0x6e, 0x65, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6b, 0x65, 0x72, 0x6e, 0x65,
+	0x6c, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x5f, 0x70, 0x61, 0x74, 0x68,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x50, 0x61,
+	0x74, 0x68, 0x12, 0x20, 0x0a, 0x0c, 0x68, 0x6f, 0x73, 0x74, 0x5f, 0x6e, 0x65, 0x74, 0x5f, 0x64,
+	0x65, 0x76, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x4e, 0x65,
+	0x74, 0x44, 0x65, 0x76, 0x12, 0x1b, 0x0a, 0x09, 0x67, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x6d, 0x61,
+	0x63, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x67, 0x75, 0x65, 0x73, 0x74, 0x4d, 0x61,
+	0x63, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x63, 0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x61, 0x72, 0x63, 0x68, 0x22, 0xcd, 0x01, 0x0a, 0x07, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56,
+	0x4d, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x35, 0x0a, 0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x21, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x53, 0x70,
+	0x65, 0x63, 0x52, 0x04, 0x73, 0x70, 0x65, 0x63, 0x12, 0x38, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76,
+	0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4d,
+	0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x03, 0x70, 0x69, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x65, 0x78, 0x69, 0x74, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x4d, 0x0a, 0x14, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x4d,
+	0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x35, 0x0a,
+	0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6d, 0x69,
+	0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x53, 0x70, 0x65, 0x63, 0x52, 0x04,
+	0x73, 0x70, 0x65, 0x63, 0x22, 0x50, 0x0a, 0x15, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x4d, 0x69,
+	0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a,
+	0x07, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d,
+	0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x07, 0x6d,
+	0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x22, 0x26, 0x0a, 0x14, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x38,
+	0x0a, 0x15, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x69, 0x74, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x65, 0x78,
+	0x69, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x23, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x4d,
+	0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x4d, 0x0a,
+	0x12, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a, 0x07, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x69, 0x63, 0x72,
+	0x6f, 0x56, 0x4d, 0x52, 0x07, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x22, 0x15, 0x0a, 0x13,
+	0x4c, 0x69, 0x73, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x22, 0x51, 0x0a, 0x14, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f,
+	0x56, 0x4d, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x08, 0x6d,
+	0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e,
+	0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x08, 0x6d, 0x69,
+	0x63, 0x72, 0x6f, 0x76, 0x6d, 0x73, 0x2a, 0x5b, 0x0a, 0x0c, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56,
+	0x4d, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57,
+	0x4e, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44, 0x10, 0x01,
+	0x12, 0x0b, 0x0a, 0x07, 0x52, 0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x02, 0x12, 0x0b, 0x0a,
+	0x07, 0x53, 0x54, 0x4f, 0x50, 0x50, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x50, 0x41,
+	0x55, 0x53, 0x45, 0x44, 0x10, 0x04, 0x12, 0x0b, 0x0a, 0x07, 0x50, 0x41, 0x55, 0x53, 0x49, 0x4e,
+	0x47, 0x10, 0x05, 0x32, 0x8a, 0x03, 0x0a, 0x09, 0x56, 0x4d, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x61, 0x0a, 0x06, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x2a, 0x2e, 0x6d, 0x69,
+	0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76,
+	0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x61, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x2a,
+	0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x69, 0x63, 0x72,
+	0x6f, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x6d, 0x69, 0x63,
+	0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x58, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x27,
+	0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76,
+	0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x47,
+	0x65, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x5d, 0x0a, 0x04, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x29, 0x2e, 0x6d, 0x69, 0x63, 0x72,
+	0x6f, 0x76, 0x6d, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x2a, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4c, 0x69, 0x73, 0x74,
+	0x4d, 0x69, 0x63, 0x72, 0x6f, 0x56, 0x4d, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x42, 0x1b, 0x5a, 0x19, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6d, 0x69,
+	0x63, 0x72, 0x6f, 0x76, 0x6d, 0x3b, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x76, 0x6d, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pkg_proto_microvm_proto_rawDescOnce sync.Once
+	file_pkg_proto_microvm_proto_rawDescData = file_pkg_proto_microvm_proto_rawDesc
+)
+
+func file_pkg_proto_microvm_proto_rawDescGZIP() []byte {
+	file_pkg_proto_microvm_proto_rawDescOnce.Do(func() {
+		file_pkg_proto_microvm_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_proto_microvm_proto_rawDescData)
+	})
+	return file_pkg_proto_microvm_proto_rawDescData
+}
+
+var file_pkg_proto_microvm_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pkg_proto_microvm_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_pkg_proto_microvm_proto_goTypes = []any{
+	(MicroVMState)(0),             // 0: microvm.services.api.MicroVMState
+	(*MicroVMSpec)(nil),           // 1: microvm.services.api.MicroVMSpec
+	(*MicroVM)(nil),               // 2: microvm.services.api.MicroVM
+	(*CreateMicroVMRequest)(nil),  // 3: microvm.services.api.CreateMicroVMRequest
+	(*CreateMicroVMResponse)(nil), // 4: microvm.services.api.CreateMicroVMResponse
+	(*DeleteMicroVMRequest)(nil),  // 5: microvm.services.api.DeleteMicroVMRequest
+	(*DeleteMicroVMResponse)(nil), // 6: microvm.services.api.DeleteMicroVMResponse
+	(*GetMicroVMRequest)(nil),     // 7: microvm.services.api.GetMicroVMRequest
+	(*GetMicroVMResponse)(nil),    // 8: microvm.services.api.GetMicroVMResponse
+	(*ListMicroVMsRequest)(nil),   // 9: microvm.services.api.ListMicroVMsRequest
+	(*ListMicroVMsResponse)(nil),  // 10: microvm.services.api.ListMicroVMsResponse
+}
+var file_pkg_proto_microvm_proto_depIdxs = []int32{
+	1,  // 0: microvm.services.api.MicroVM.spec:type_name -> microvm.services.api.MicroVMSpec
+	0,  // 1: microvm.services.api.MicroVM.state:type_name -> microvm.services.api.MicroVMState
+	1,  // 2: microvm.services.api.CreateMicroVMRequest.spec:type_name -> microvm.services.api.MicroVMSpec
+	2,  // 3: microvm.services.api.CreateMicroVMResponse.microvm:type_name -> microvm.services.api.MicroVM
+	2,  // 4: microvm.services.api.GetMicroVMResponse.microvm:type_name -> microvm.services.api.MicroVM
+	2,  // 5: microvm.services.api.ListMicroVMsResponse.microvms:type_name -> microvm.services.api.MicroVM
+	3,  // 6: microvm.services.api.VMService.Create:input_type -> microvm.services.api.CreateMicroVMRequest
+	5,  // 7: microvm.services.api.VMService.Delete:input_type -> microvm.services.api.DeleteMicroVMRequest
+	7,  // 8: microvm.services.api.VMService.Get:input_type -> microvm.services.api.GetMicroVMRequest
+	9,  // 9: microvm.services.api.VMService.List:input_type -> microvm.services.api.ListMicroVMsRequest
+	4,  // 10: microvm.services.api.VMService.Create:output_type -> microvm.services.api.CreateMicroVMResponse
+	6,  // 11: microvm.services.api.VMService.Delete:output_type -> microvm.services.api.DeleteMicroVMResponse
+	8,  // 12: microvm.services.api.VMService.Get:output_type -> microvm.services.api.GetMicroVMResponse
+	10, // 13: microvm.services.api.VMService.List:output_type -> microvm.services.api.ListMicroVMsResponse
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_pkg_proto_microvm_proto_init() }
+func file_pkg_proto_microvm_proto_init() {
+	if File_pkg_proto_microvm_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pkg_proto_microvm_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*MicroVMSpec); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*MicroVM); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*CreateMicroVMRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*CreateMicroVMResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*DeleteMicroVMRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*DeleteMicroVMResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*GetMicroVMRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*GetMicroVMResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ListMicroVMsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_microvm_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*ListMicroVMsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pkg_proto_microvm_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_proto_microvm_proto_goTypes,
+		DependencyIndexes: file_pkg_proto_microvm_proto_depIdxs,
+		EnumInfos:         file_pkg_proto_microvm_proto_enumTypes,
+		MessageInfos:      file_pkg_proto_microvm_proto_msgTypes,
+	}.Build()
+	File_pkg_proto_microvm_proto = out.File
+	file_pkg_proto_microvm_proto_rawDesc = nil
+	file_pkg_proto_microvm_proto_goTypes = nil
+	file_pkg_proto_microvm_proto_depIdxs = nil
+}
@@ -0,0 +1,239 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: pkg/proto/microvm.proto
+
+package microvm
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	VMService_Create_FullMethodName = "/microvm.services.api.VMService/Create"
+	VMService_Delete_FullMethodName = "/microvm.services.api.VMService/Delete"
+	VMService_Get_FullMethodName    = "/microvm.services.api.VMService/Get"
+	VMService_List_FullMethodName   = "/microvm.services.api.VMService/List"
+)
+
+// VMServiceClient is the client API for VMService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// VMService manages the microVMs of a single hypercore node
+type VMServiceClient interface {
+	Create(ctx context.Context, in *CreateMicroVMRequest, opts ...grpc.CallOption) (*CreateMicroVMResponse, error)
+	Delete(ctx context.Context, in *DeleteMicroVMRequest, opts ...grpc.CallOption) (*DeleteMicroVMResponse, error)
+	Get(ctx context.Context, in *GetMicroVMRequest, opts ...grpc.CallOption) (*GetMicroVMResponse, error)
+	List(ctx context.Context, in *ListMicroVMsRequest, opts ...grpc.CallOption) (*ListMicroVMsResponse, error)
+}
+
+type vMServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVMServiceClient(cc grpc.ClientConnInterface) VMServiceClient {
+	return &vMServiceClient{cc}
+}
+
+func (c *vMServiceClient) Create(ctx context.Context, in *CreateMicroVMRequest, opts ...grpc.CallOption) (*CreateMicroVMResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateMicroVMResponse)
+	err := c.cc.Invoke(ctx, VMService_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServiceClient) Delete(ctx context.Context, in *DeleteMicroVMRequest, opts ...grpc.CallOption) (*DeleteMicroVMResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteMicroVMResponse)
+	err := c.cc.Invoke(ctx, VMService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServiceClient) Get(ctx context.Context, in *GetMicroVMRequest, opts ...grpc.CallOption) (*GetMicroVMResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMicroVMResponse)
+	err := c.cc.Invoke(ctx, VMService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServiceClient) List(ctx context.Context, in *ListMicroVMsRequest, opts ...grpc.CallOption) (*ListMicroVMsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMicroVMsResponse)
+	err := c.cc.Invoke(ctx, VMService_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VMServiceServer is the server API for VMService service.
+// All implementations must embed UnimplementedVMServiceServer
+// for forward compatibility.
+//
+// VMService manages the microVMs of a single hypercore node
+type VMServiceServer interface {
+	Create(context.Context, *CreateMicroVMRequest) (*CreateMicroVMResponse, error)
+	Delete(context.Context, *DeleteMicroVMRequest) (*DeleteMicroVMResponse, error)
+	Get(context.Context, *GetMicroVMRequest) (*GetMicroVMResponse, error)
+	List(context.Context, *ListMicroVMsRequest) (*ListMicroVMsResponse, error)
+	mustEmbedUnimplementedVMServiceServer()
+}
+
+// UnimplementedVMServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVMServiceServer struct{}
+
+func (UnimplementedVMServiceServer) Create(context.Context, *CreateMicroVMRequest) (*CreateMicroVMResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedVMServiceServer) Delete(context.Context, *DeleteMicroVMRequest) (*DeleteMicroVMResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedVMServiceServer) Get(context.Context, *GetMicroVMRequest) (*GetMicroVMResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedVMServiceServer) List(context.Context, *ListMicroVMsRequest) (*ListMicroVMsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedVMServiceServer) mustEmbedUnimplementedVMServiceServer() {}
+func (UnimplementedVMServiceServer) testEmbeddedByValue()                   {}
+
+// UnsafeVMServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VMServiceServer will
+// result in compilation errors.
+type UnsafeVMServiceServer interface {
+	mustEmbedUnimplementedVMServiceServer()
+}
+
+func RegisterVMServiceServer(s grpc.ServiceRegistrar, srv VMServiceServer) {
+	// If the following call pancis, it indicates UnimplementedVMServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VMService_ServiceDesc, srv)
+}
+
+func _VMService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateMicroVMRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServiceServer).Create(ctx, req.(*CreateMicroVMRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMicroVMRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServiceServer).Delete(ctx, req.(*DeleteMicroVMRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMicroVMRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServiceServer).Get(ctx, req.(*GetMicroVMRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMicroVMsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServiceServer).List(ctx, req.(*ListMicroVMsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// VMService_ServiceDesc is the grpc.ServiceDesc for VMService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VMService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "microvm.services.api.VMService",
+	HandlerType: (*VMServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _VMService_Create_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _VMService_Delete_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _VMService_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _VMService_List_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/proto/microvm.proto",
+}
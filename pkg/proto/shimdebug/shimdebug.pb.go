@@ -0,0 +1,2192 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.28.3
+// source: pkg/proto/shimdebug.proto
+
+package shimdebug
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type VMFailureReason int32
+
+const (
+	VMFailureReason_VM_FAILURE_UNKNOWN VMFailureReason = 0
+	VMFailureReason_KERNEL_PANIC       VMFailureReason = 1
+	VMFailureReason_GUEST_OOM          VMFailureReason = 2
+)
+
+// Enum value maps for VMFailureReason.
+var (
+	VMFailureReason_name = map[int32]string{
+		0: "VM_FAILURE_UNKNOWN",
+		1: "KERNEL_PANIC",
+		2: "GUEST_OOM",
+	}
+	VMFailureReason_value = map[string]int32{
+		"VM_FAILURE_UNKNOWN": 0,
+		"KERNEL_PANIC":       1,
+		"GUEST_OOM":          2,
+	}
+)
+
+func (x VMFailureReason) Enum() *VMFailureReason {
+	p := new(VMFailureReason)
+	*p = x
+	return p
+}
+
+func (x VMFailureReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (VMFailureReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_proto_shimdebug_proto_enumTypes[0].Descriptor()
+}
+
+func (VMFailureReason) Type() protoreflect.EnumType {
+	return &file_pkg_proto_shimdebug_proto_enumTypes[0]
+}
+
+func (x VMFailureReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use VMFailureReason.Descriptor instead.
+func (VMFailureReason) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{0}
+}
+
+type InspectRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *InspectRequest) Reset() {
+	*x = InspectRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InspectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectRequest) ProtoMessage() {}
+
+func (x *InspectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectRequest.ProtoReflect.Descriptor instead.
+func (*InspectRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{0}
+}
+
+type VMConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Provider    string `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Vcpu        int32  `protobuf:"varint,3,opt,name=vcpu,proto3" json:"vcpu,omitempty"`
+	MemoryMb    int32  `protobuf:"varint,4,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	Kernel      string `protobuf:"bytes,5,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	RootfsPath  string `protobuf:"bytes,6,opt,name=rootfs_path,json=rootfsPath,proto3" json:"rootfs_path,omitempty"`
+	ImagePath   string `protobuf:"bytes,7,opt,name=image_path,json=imagePath,proto3" json:"image_path,omitempty"`
+	HostNetDev  string `protobuf:"bytes,8,opt,name=host_net_dev,json=hostNetDev,proto3" json:"host_net_dev,omitempty"`
+	GuestMac    string `protobuf:"bytes,9,opt,name=guest_mac,json=guestMac,proto3" json:"guest_mac,omitempty"`
+	Arch        string `protobuf:"bytes,10,opt,name=arch,proto3" json:"arch,omitempty"`
+	VsockPath   string `protobuf:"bytes,11,opt,name=vsock_path,json=vsockPath,proto3" json:"vsock_path,omitempty"`
+	ConsolePath string `protobuf:"bytes,12,opt,name=console_path,json=consolePath,proto3" json:"console_path,omitempty"`
+	// set when the VM was started from a warm pool snapshot
+	Restored bool `protobuf:"varint,13,opt,name=restored,proto3" json:"restored,omitempty"`
+	// unix socket serving the serial console
+	ConsoleSocketPath string `protobuf:"bytes,14,opt,name=console_socket_path,json=consoleSocketPath,proto3" json:"console_socket_path,omitempty"`
+	// sev-snp or tdx for confidential VMs
+	Confidential string `protobuf:"bytes,15,opt,name=confidential,proto3" json:"confidential,omitempty"`
+}
+
+func (x *VMConfig) Reset() {
+	*x = VMConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VMConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VMConfig) ProtoMessage() {}
+
+func (x *VMConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VMConfig.ProtoReflect.Descriptor instead.
+func (*VMConfig) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *VMConfig) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VMConfig) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *VMConfig) GetVcpu() int32 {
+	if x != nil {
+		return x.Vcpu
+	}
+	return 0
+}
+
+func (x *VMConfig) GetMemoryMb() int32 {
+	if x != nil {
+		return x.MemoryMb
+	}
+	return 0
+}
+
+func (x *VMConfig) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *VMConfig) GetRootfsPath() string {
+	if x != nil {
+		return x.RootfsPath
+	}
+	return ""
+}
+
+func (x *VMConfig) GetImagePath() string {
+	if x != nil {
+		return x.ImagePath
+	}
+	return ""
+}
+
+func (x *VMConfig) GetHostNetDev() string {
+	if x != nil {
+		return x.HostNetDev
+	}
+	return ""
+}
+
+func (x *VMConfig) GetGuestMac() string {
+	if x != nil {
+		return x.GuestMac
+	}
+	return ""
+}
+
+func (x *VMConfig) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *VMConfig) GetVsockPath() string {
+	if x != nil {
+		return x.VsockPath
+	}
+	return ""
+}
+
+func (x *VMConfig) GetConsolePath() string {
+	if x != nil {
+		return x.ConsolePath
+	}
+	return ""
+}
+
+func (x *VMConfig) GetRestored() bool {
+	if x != nil {
+		return x.Restored
+	}
+	return false
+}
+
+func (x *VMConfig) GetConsoleSocketPath() string {
+	if x != nil {
+		return x.ConsoleSocketPath
+	}
+	return ""
+}
+
+func (x *VMConfig) GetConfidential() string {
+	if x != nil {
+		return x.Confidential
+	}
+	return ""
+}
+
+// VsockPorts are the guest vsock ports the IO of a process is proxied over
+type VsockPorts struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TaskId     string `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	ExecId     string `protobuf:"bytes,2,opt,name=exec_id,json=execId,proto3" json:"exec_id,omitempty"`
+	StdinPort  uint32 `protobuf:"varint,3,opt,name=stdin_port,json=stdinPort,proto3" json:"stdin_port,omitempty"`
+	StdoutPort uint32 `protobuf:"varint,4,opt,name=stdout_port,json=stdoutPort,proto3" json:"stdout_port,omitempty"`
+	StderrPort uint32 `protobuf:"varint,5,opt,name=stderr_port,json=stderrPort,proto3" json:"stderr_port,omitempty"`
+}
+
+func (x *VsockPorts) Reset() {
+	*x = VsockPorts{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VsockPorts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VsockPorts) ProtoMessage() {}
+
+func (x *VsockPorts) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VsockPorts.ProtoReflect.Descriptor instead.
+func (*VsockPorts) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *VsockPorts) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *VsockPorts) GetExecId() string {
+	if x != nil {
+		return x.ExecId
+	}
+	return ""
+}
+
+func (x *VsockPorts) GetStdinPort() uint32 {
+	if x != nil {
+		return x.StdinPort
+	}
+	return 0
+}
+
+func (x *VsockPorts) GetStdoutPort() uint32 {
+	if x != nil {
+		return x.StdoutPort
+	}
+	return 0
+}
+
+func (x *VsockPorts) GetStderrPort() uint32 {
+	if x != nil {
+		return x.StderrPort
+	}
+	return 0
+}
+
+// IOStream is the set of host FIFOs attached to a process
+type IOStream struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TaskId   string `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	ExecId   string `protobuf:"bytes,2,opt,name=exec_id,json=execId,proto3" json:"exec_id,omitempty"`
+	Terminal bool   `protobuf:"varint,3,opt,name=terminal,proto3" json:"terminal,omitempty"`
+	Stdin    string `protobuf:"bytes,4,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Stdout   string `protobuf:"bytes,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr   string `protobuf:"bytes,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+}
+
+func (x *IOStream) Reset() {
+	*x = IOStream{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IOStream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IOStream) ProtoMessage() {}
+
+func (x *IOStream) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IOStream.ProtoReflect.Descriptor instead.
+func (*IOStream) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *IOStream) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *IOStream) GetExecId() string {
+	if x != nil {
+		return x.ExecId
+	}
+	return ""
+}
+
+func (x *IOStream) GetTerminal() bool {
+	if x != nil {
+		return x.Terminal
+	}
+	return false
+}
+
+func (x *IOStream) GetStdin() string {
+	if x != nil {
+		return x.Stdin
+	}
+	return ""
+}
+
+func (x *IOStream) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *IOStream) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+type BalloonState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// false when the provider has no balloon device configured
+	Configured            bool  `protobuf:"varint,1,opt,name=configured,proto3" json:"configured,omitempty"`
+	AmountMib             int64 `protobuf:"varint,2,opt,name=amount_mib,json=amountMib,proto3" json:"amount_mib,omitempty"`
+	DeflateOnOom          bool  `protobuf:"varint,3,opt,name=deflate_on_oom,json=deflateOnOom,proto3" json:"deflate_on_oom,omitempty"`
+	StatsPollingIntervalS int64 `protobuf:"varint,4,opt,name=stats_polling_interval_s,json=statsPollingIntervalS,proto3" json:"stats_polling_interval_s,omitempty"`
+}
+
+func (x *BalloonState) Reset() {
+	*x = BalloonState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BalloonState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BalloonState) ProtoMessage() {}
+
+func (x *BalloonState) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BalloonState.ProtoReflect.Descriptor instead.
+func (*BalloonState) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BalloonState) GetConfigured() bool {
+	if x != nil {
+		return x.Configured
+	}
+	return false
+}
+
+func (x *BalloonState) GetAmountMib() int64 {
+	if x != nil {
+		return x.AmountMib
+	}
+	return 0
+}
+
+func (x *BalloonState) GetDeflateOnOom() bool {
+	if x != nil {
+		return x.DeflateOnOom
+	}
+	return false
+}
+
+func (x *BalloonState) GetStatsPollingIntervalS() int64 {
+	if x != nil {
+		return x.StatsPollingIntervalS
+	}
+	return 0
+}
+
+type ShimError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Message   string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *ShimError) Reset() {
+	*x = ShimError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShimError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShimError) ProtoMessage() {}
+
+func (x *ShimError) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShimError.ProtoReflect.Descriptor instead.
+func (*ShimError) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ShimError) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ShimError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type InspectResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ShimId  string `protobuf:"bytes,1,opt,name=shim_id,json=shimId,proto3" json:"shim_id,omitempty"`
+	ShimPid int32  `protobuf:"varint,2,opt,name=shim_pid,json=shimPid,proto3" json:"shim_pid,omitempty"`
+	// set for the sandbox task of a pod, which runs no VM
+	Sandbox bool      `protobuf:"varint,3,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	Vm      *VMConfig `protobuf:"bytes,4,opt,name=vm,proto3" json:"vm,omitempty"`
+	// vsock port the agent inside the VM listens on
+	AgentPort uint32        `protobuf:"varint,5,opt,name=agent_port,json=agentPort,proto3" json:"agent_port,omitempty"`
+	Ports     []*VsockPorts `protobuf:"bytes,6,rep,name=ports,proto3" json:"ports,omitempty"`
+	Streams   []*IOStream   `protobuf:"bytes,7,rep,name=streams,proto3" json:"streams,omitempty"`
+	Balloon   *BalloonState `protobuf:"bytes,8,opt,name=balloon,proto3" json:"balloon,omitempty"`
+	// most recent errors logged by the shim, oldest first
+	RecentErrors []*ShimError `protobuf:"bytes,9,rep,name=recent_errors,json=recentErrors,proto3" json:"recent_errors,omitempty"`
+	// set once the guest kernel reported a VM level failure
+	Failure *VMCrashed `protobuf:"bytes,10,opt,name=failure,proto3" json:"failure,omitempty"`
+	// protocol version negotiated with the agent, and its release if it
+	// reported one
+	AgentProtocolVersion uint32 `protobuf:"varint,11,opt,name=agent_protocol_version,json=agentProtocolVersion,proto3" json:"agent_protocol_version,omitempty"`
+	AgentVersion         string `protobuf:"bytes,12,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	// time from the creation of the task to the agent handshake and to
+	// the first start of the task, 0 until the stage is reached
+	BootAgentMs int64 `protobuf:"varint,13,opt,name=boot_agent_ms,json=bootAgentMs,proto3" json:"boot_agent_ms,omitempty"`
+	BootStartMs int64 `protobuf:"varint,14,opt,name=boot_start_ms,json=bootStartMs,proto3" json:"boot_start_ms,omitempty"`
+}
+
+func (x *InspectResponse) Reset() {
+	*x = InspectResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InspectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectResponse) ProtoMessage() {}
+
+func (x *InspectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectResponse.ProtoReflect.Descriptor instead.
+func (*InspectResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InspectResponse) GetShimId() string {
+	if x != nil {
+		return x.ShimId
+	}
+	return ""
+}
+
+func (x *InspectResponse) GetShimPid() int32 {
+	if x != nil {
+		return x.ShimPid
+	}
+	return 0
+}
+
+func (x *InspectResponse) GetSandbox() bool {
+	if x != nil {
+		return x.Sandbox
+	}
+	return false
+}
+
+func (x *InspectResponse) GetVm() *VMConfig {
+	if x != nil {
+		return x.Vm
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetAgentPort() uint32 {
+	if x != nil {
+		return x.AgentPort
+	}
+	return 0
+}
+
+func (x *InspectResponse) GetPorts() []*VsockPorts {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetStreams() []*IOStream {
+	if x != nil {
+		return x.Streams
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetBalloon() *BalloonState {
+	if x != nil {
+		return x.Balloon
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetRecentErrors() []*ShimError {
+	if x != nil {
+		return x.RecentErrors
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetFailure() *VMCrashed {
+	if x != nil {
+		return x.Failure
+	}
+	return nil
+}
+
+func (x *InspectResponse) GetAgentProtocolVersion() uint32 {
+	if x != nil {
+		return x.AgentProtocolVersion
+	}
+	return 0
+}
+
+func (x *InspectResponse) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+func (x *InspectResponse) GetBootAgentMs() int64 {
+	if x != nil {
+		return x.BootAgentMs
+	}
+	return 0
+}
+
+func (x *InspectResponse) GetBootStartMs() int64 {
+	if x != nil {
+		return x.BootStartMs
+	}
+	return 0
+}
+
+// TaskCrash is published along with the TaskExit event of a task whose VM
+// exited unexpectedly, containerd's TaskExit having no room for the bundle
+type TaskCrash struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	ExitStatus  uint32 `protobuf:"varint,2,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
+	ExitedAt    int64  `protobuf:"varint,3,opt,name=exited_at,json=exitedAt,proto3" json:"exited_at,omitempty"`
+	// directory holding the hypervisor stderr, the end of the serial
+	// console and the machine config of the VM
+	BundlePath string `protobuf:"bytes,4,opt,name=bundle_path,json=bundlePath,proto3" json:"bundle_path,omitempty"`
+}
+
+func (x *TaskCrash) Reset() {
+	*x = TaskCrash{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TaskCrash) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskCrash) ProtoMessage() {}
+
+func (x *TaskCrash) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskCrash.ProtoReflect.Descriptor instead.
+func (*TaskCrash) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TaskCrash) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *TaskCrash) GetExitStatus() uint32 {
+	if x != nil {
+		return x.ExitStatus
+	}
+	return 0
+}
+
+func (x *TaskCrash) GetExitedAt() int64 {
+	if x != nil {
+		return x.ExitedAt
+	}
+	return 0
+}
+
+func (x *TaskCrash) GetBundlePath() string {
+	if x != nil {
+		return x.BundlePath
+	}
+	return ""
+}
+
+type ConsoleInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *ConsoleInput) Reset() {
+	*x = ConsoleInput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConsoleInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsoleInput) ProtoMessage() {}
+
+func (x *ConsoleInput) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsoleInput.ProtoReflect.Descriptor instead.
+func (*ConsoleInput) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ConsoleInput) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ConsoleOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *ConsoleOutput) Reset() {
+	*x = ConsoleOutput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConsoleOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsoleOutput) ProtoMessage() {}
+
+func (x *ConsoleOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsoleOutput.ProtoReflect.Descriptor instead.
+func (*ConsoleOutput) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ConsoleOutput) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// VMCrashed is published when the serial console of a VM shows a failure
+// of the guest rather than of the workload running in it
+type VMCrashed struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ContainerId string          `protobuf:"bytes,1,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	Reason      VMFailureReason `protobuf:"varint,2,opt,name=reason,proto3,enum=shimdebug.services.api.VMFailureReason" json:"reason,omitempty"`
+	// console line the failure was detected on
+	Message   string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Timestamp int64  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *VMCrashed) Reset() {
+	*x = VMCrashed{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VMCrashed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VMCrashed) ProtoMessage() {}
+
+func (x *VMCrashed) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VMCrashed.ProtoReflect.Descriptor instead.
+func (*VMCrashed) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VMCrashed) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *VMCrashed) GetReason() VMFailureReason {
+	if x != nil {
+		return x.Reason
+	}
+	return VMFailureReason_VM_FAILURE_UNKNOWN
+}
+
+func (x *VMCrashed) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *VMCrashed) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type AttestationReportRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// up to 64 bytes bound into the report, e.g. a nonce of the verifier
+	ReportData []byte `protobuf:"bytes,1,opt,name=report_data,json=reportData,proto3" json:"report_data,omitempty"`
+}
+
+func (x *AttestationReportRequest) Reset() {
+	*x = AttestationReportRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttestationReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttestationReportRequest) ProtoMessage() {}
+
+func (x *AttestationReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttestationReportRequest.ProtoReflect.Descriptor instead.
+func (*AttestationReportRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AttestationReportRequest) GetReportData() []byte {
+	if x != nil {
+		return x.ReportData
+	}
+	return nil
+}
+
+// LaunchMeasurement lists the inputs of the measured launch of a
+// confidential VM, to compute the expected launch measurement of the
+// report with the tools of the CPU vendor
+type LaunchMeasurement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FirmwarePath   string `protobuf:"bytes,1,opt,name=firmware_path,json=firmwarePath,proto3" json:"firmware_path,omitempty"`
+	FirmwareSha256 string `protobuf:"bytes,2,opt,name=firmware_sha256,json=firmwareSha256,proto3" json:"firmware_sha256,omitempty"`
+	KernelPath     string `protobuf:"bytes,3,opt,name=kernel_path,json=kernelPath,proto3" json:"kernel_path,omitempty"`
+	KernelSha256   string `protobuf:"bytes,4,opt,name=kernel_sha256,json=kernelSha256,proto3" json:"kernel_sha256,omitempty"`
+	Cmdline        string `protobuf:"bytes,5,opt,name=cmdline,proto3" json:"cmdline,omitempty"`
+	Vcpu           int32  `protobuf:"varint,6,opt,name=vcpu,proto3" json:"vcpu,omitempty"`
+}
+
+func (x *LaunchMeasurement) Reset() {
+	*x = LaunchMeasurement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LaunchMeasurement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LaunchMeasurement) ProtoMessage() {}
+
+func (x *LaunchMeasurement) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LaunchMeasurement.ProtoReflect.Descriptor instead.
+func (*LaunchMeasurement) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *LaunchMeasurement) GetFirmwarePath() string {
+	if x != nil {
+		return x.FirmwarePath
+	}
+	return ""
+}
+
+func (x *LaunchMeasurement) GetFirmwareSha256() string {
+	if x != nil {
+		return x.FirmwareSha256
+	}
+	return ""
+}
+
+func (x *LaunchMeasurement) GetKernelPath() string {
+	if x != nil {
+		return x.KernelPath
+	}
+	return ""
+}
+
+func (x *LaunchMeasurement) GetKernelSha256() string {
+	if x != nil {
+		return x.KernelSha256
+	}
+	return ""
+}
+
+func (x *LaunchMeasurement) GetCmdline() string {
+	if x != nil {
+		return x.Cmdline
+	}
+	return ""
+}
+
+func (x *LaunchMeasurement) GetVcpu() int32 {
+	if x != nil {
+		return x.Vcpu
+	}
+	return 0
+}
+
+type AttestationReportResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// sev-snp or tdx
+	Technology string `protobuf:"bytes,1,opt,name=technology,proto3" json:"technology,omitempty"`
+	// signed by the CPU, an SEV-SNP attestation report or a TDX quote as
+	// returned by the guest
+	Report []byte             `protobuf:"bytes,2,opt,name=report,proto3" json:"report,omitempty"`
+	Launch *LaunchMeasurement `protobuf:"bytes,3,opt,name=launch,proto3" json:"launch,omitempty"`
+}
+
+func (x *AttestationReportResponse) Reset() {
+	*x = AttestationReportResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttestationReportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttestationReportResponse) ProtoMessage() {}
+
+func (x *AttestationReportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttestationReportResponse.ProtoReflect.Descriptor instead.
+func (*AttestationReportResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AttestationReportResponse) GetTechnology() string {
+	if x != nil {
+		return x.Technology
+	}
+	return ""
+}
+
+func (x *AttestationReportResponse) GetReport() []byte {
+	if x != nil {
+		return x.Report
+	}
+	return nil
+}
+
+func (x *AttestationReportResponse) GetLaunch() *LaunchMeasurement {
+	if x != nil {
+		return x.Launch
+	}
+	return nil
+}
+
+type DescribeVMRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DescribeVMRequest) Reset() {
+	*x = DescribeVMRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DescribeVMRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeVMRequest) ProtoMessage() {}
+
+func (x *DescribeVMRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeVMRequest.ProtoReflect.Descriptor instead.
+func (*DescribeVMRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{14}
+}
+
+// RateLimit is a token bucket of a rate limiter of a device
+type RateLimit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// rx or tx for the network devices of firecracker, empty when the
+	// limiter applies to both directions
+	Direction string `protobuf:"bytes,1,opt,name=direction,proto3" json:"direction,omitempty"`
+	// bandwidth, in bytes, or ops
+	Kind         string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Size         int64  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	OneTimeBurst int64  `protobuf:"varint,4,opt,name=one_time_burst,json=oneTimeBurst,proto3" json:"one_time_burst,omitempty"`
+	RefillTimeMs int64  `protobuf:"varint,5,opt,name=refill_time_ms,json=refillTimeMs,proto3" json:"refill_time_ms,omitempty"`
+}
+
+func (x *RateLimit) Reset() {
+	*x = RateLimit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RateLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimit) ProtoMessage() {}
+
+func (x *RateLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimit.ProtoReflect.Descriptor instead.
+func (*RateLimit) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RateLimit) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *RateLimit) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RateLimit) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *RateLimit) GetOneTimeBurst() int64 {
+	if x != nil {
+		return x.OneTimeBurst
+	}
+	return 0
+}
+
+func (x *RateLimit) GetRefillTimeMs() int64 {
+	if x != nil {
+		return x.RefillTimeMs
+	}
+	return 0
+}
+
+type VMDevice struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// drive, net or vsock
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Id   string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// image of a drive, tap device of a network device or unix socket of
+	// the vsock device
+	Path       string       `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	ReadOnly   bool         `protobuf:"varint,4,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	GuestMac   string       `protobuf:"bytes,5,opt,name=guest_mac,json=guestMac,proto3" json:"guest_mac,omitempty"`
+	RateLimits []*RateLimit `protobuf:"bytes,6,rep,name=rate_limits,json=rateLimits,proto3" json:"rate_limits,omitempty"`
+}
+
+func (x *VMDevice) Reset() {
+	*x = VMDevice{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VMDevice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VMDevice) ProtoMessage() {}
+
+func (x *VMDevice) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VMDevice.ProtoReflect.Descriptor instead.
+func (*VMDevice) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *VMDevice) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *VMDevice) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VMDevice) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *VMDevice) GetReadOnly() bool {
+	if x != nil {
+		return x.ReadOnly
+	}
+	return false
+}
+
+func (x *VMDevice) GetGuestMac() string {
+	if x != nil {
+		return x.GuestMac
+	}
+	return ""
+}
+
+func (x *VMDevice) GetRateLimits() []*RateLimit {
+	if x != nil {
+		return x.RateLimits
+	}
+	return nil
+}
+
+type DescribeVMResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Provider string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	// state of the VM as reported by the hypervisor
+	State      string      `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Vcpu       int64       `protobuf:"varint,3,opt,name=vcpu,proto3" json:"vcpu,omitempty"`
+	MemoryMib  int64       `protobuf:"varint,4,opt,name=memory_mib,json=memoryMib,proto3" json:"memory_mib,omitempty"`
+	Devices    []*VMDevice `protobuf:"bytes,5,rep,name=devices,proto3" json:"devices,omitempty"`
+	BalloonMib int64       `protobuf:"varint,6,opt,name=balloon_mib,json=balloonMib,proto3" json:"balloon_mib,omitempty"`
+	// differences between the spec the VM was created with and its
+	// configuration in the hypervisor
+	Drift []string `protobuf:"bytes,7,rep,name=drift,proto3" json:"drift,omitempty"`
+	// configuration and metrics as returned by the API of the hypervisor
+	ConfigJson  string `protobuf:"bytes,8,opt,name=config_json,json=configJson,proto3" json:"config_json,omitempty"`
+	MetricsJson string `protobuf:"bytes,9,opt,name=metrics_json,json=metricsJson,proto3" json:"metrics_json,omitempty"`
+}
+
+func (x *DescribeVMResponse) Reset() {
+	*x = DescribeVMResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DescribeVMResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeVMResponse) ProtoMessage() {}
+
+func (x *DescribeVMResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeVMResponse.ProtoReflect.Descriptor instead.
+func (*DescribeVMResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DescribeVMResponse) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *DescribeVMResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *DescribeVMResponse) GetVcpu() int64 {
+	if x != nil {
+		return x.Vcpu
+	}
+	return 0
+}
+
+func (x *DescribeVMResponse) GetMemoryMib() int64 {
+	if x != nil {
+		return x.MemoryMib
+	}
+	return 0
+}
+
+func (x *DescribeVMResponse) GetDevices() []*VMDevice {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *DescribeVMResponse) GetBalloonMib() int64 {
+	if x != nil {
+		return x.BalloonMib
+	}
+	return 0
+}
+
+func (x *DescribeVMResponse) GetDrift() []string {
+	if x != nil {
+		return x.Drift
+	}
+	return nil
+}
+
+func (x *DescribeVMResponse) GetConfigJson() string {
+	if x != nil {
+		return x.ConfigJson
+	}
+	return ""
+}
+
+func (x *DescribeVMResponse) GetMetricsJson() string {
+	if x != nil {
+		return x.MetricsJson
+	}
+	return ""
+}
+
+type ProfileRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// cpu, or a profile of runtime/pprof such as heap, goroutine or block
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// for cpu profiles, 30 seconds if 0
+	DurationSeconds int64 `protobuf:"varint,2,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+}
+
+func (x *ProfileRequest) Reset() {
+	*x = ProfileRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProfileRequest) ProtoMessage() {}
+
+func (x *ProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProfileRequest.ProtoReflect.Descriptor instead.
+func (*ProfileRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ProfileRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ProfileRequest) GetDurationSeconds() int64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+type ProfileResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// in the pprof format
+	Profile []byte `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+}
+
+func (x *ProfileResponse) Reset() {
+	*x = ProfileResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_proto_shimdebug_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProfileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProfileResponse) ProtoMessage() {}
+
+func (x *ProfileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_proto_shimdebug_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProfileResponse.ProtoReflect.Descriptor instead.
+func (*ProfileResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_proto_shimdebug_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ProfileResponse) GetProfile() []byte {
+	if x != nil {
+		return x.Profile
+	}
+	return nil
+}
+
+var File_pkg_proto_shimdebug_proto protoreflect.FileDescriptor
+
+var file_pkg_proto_shimdebug_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x68, 0x69, 0x6d,
+	0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x73, 0x68, 0x69,
+	0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e,
+	0x61, 0x70, 0x69, 0x22, 0x10, 0x0a, 0x0e, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xc4, 0x03, 0x0a, 0x08, 0x56, 0x4d, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x69, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x76, 0x63, 0x70, 0x75, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x76, 0x63,
+	0x70, 0x75, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6d, 0x62, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x62, 0x12,
+	0x16, 0x0a, 0x06, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x6f, 0x6f, 0x74, 0x66,
+	0x73, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x6f,
+	0x6f, 0x74, 0x66, 0x73, 0x50, 0x61, 0x74, 0x68, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x50, 0x61, 0x74, 0x68, 0x12, 0x20, 0x0a, 0x0c, 0x68, 0x6f, 0x73, 0x74, 0x5f,
+	0x6e, 0x65, 0x74, 0x5f, 0x64, 0x65, 0x76, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x68,
+	0x6f, 0x73, 0x74, 0x4e, 0x65, 0x74, 0x44, 0x65, 0x76, 0x12, 0x1b, 0x0a, 0x09, 0x67, 0x75, 0x65,
+	0x73, 0x74, 0x5f, 0x6d, 0x61, 0x63, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x67, 0x75,
+	0x65, 0x73, 0x74, 0x4d, 0x61, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x63, 0x68, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x72, 0x63, 0x68, 0x12, 0x1d, 0x0a, 0x0a, 0x76, 0x73,
+	0x6f, 0x63, 0x6b, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x76, 0x73, 0x6f, 0x63, 0x6b, 0x50, 0x61, 0x74, 0x68, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e,
+	0x73, 0x6f, 0x6c, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x65, 0x50, 0x61, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08,
+	0x72, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
+	0x72, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x12, 0x2e, 0x0a, 0x13, 0x63, 0x6f, 0x6e, 0x73,
+	0x6f, 0x6c, 0x65, 0x5f, 0x73, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x63, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x65, 0x53, 0x6f,
+	0x63, 0x6b, 0x65, 0x74, 0x50, 0x61, 0x74, 0x68, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x66,
+	0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x22, 0x9f, 0x01, 0x0a,
+	0x0a, 0x56, 0x73, 0x6f, 0x63, 0x6b, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x74,
+	0x61, 0x73, 0x6b, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61,
+	0x73, 0x6b, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78, 0x65, 0x63, 0x5f, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x78, 0x65, 0x63, 0x49, 0x64, 0x12, 0x1d, 0x0a,
+	0x0a, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x09, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1f, 0x0a, 0x0b,
+	0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0a, 0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1f, 0x0a,
+	0x0b, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0a, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72, 0x50, 0x6f, 0x72, 0x74, 0x22, 0x9e,
+	0x01, 0x0a, 0x08, 0x49, 0x4f, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x17, 0x0a, 0x07, 0x74,
+	0x61, 0x73, 0x6b, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61,
+	0x73, 0x6b, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78, 0x65, 0x63, 0x5f, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x78, 0x65, 0x63, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x08, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x64,
+	0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x65, 0x72,
+	0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72, 0x22,
+	0xac, 0x01, 0x0a, 0x0c, 0x42, 0x61, 0x6c, 0x6c, 0x6f, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x65, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x65, 0x64,
+	0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x5f, 0x6d, 0x69, 0x62, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x4d, 0x69, 0x62, 0x12,
+	0x24, 0x0a, 0x0e, 0x64, 0x65, 0x66, 0x6c, 0x61, 0x74, 0x65, 0x5f, 0x6f, 0x6e, 0x5f, 0x6f, 0x6f,
+	0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x64, 0x65, 0x66, 0x6c, 0x61, 0x74, 0x65,
+	0x4f, 0x6e, 0x4f, 0x6f, 0x6d, 0x12, 0x37, 0x0a, 0x18, 0x73, 0x74, 0x61, 0x74, 0x73, 0x5f, 0x70,
+	0x6f, 0x6c, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f,
+	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x15, 0x73, 0x74, 0x61, 0x74, 0x73, 0x50, 0x6f,
+	0x6c, 0x6c, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x53, 0x22, 0x43,
+	0x0a, 0x09, 0x53, 0x68, 0x69, 0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x22, 0x8e, 0x05, 0x0a, 0x0f, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x68, 0x69, 0x6d, 0x5f,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x68, 0x69, 0x6d, 0x49, 0x64,
+	0x12, 0x19, 0x0a, 0x08, 0x73, 0x68, 0x69, 0x6d, 0x5f, 0x70, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x07, 0x73, 0x68, 0x69, 0x6d, 0x50, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x73,
+	0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x61,
+	0x6e, 0x64, 0x62, 0x6f, 0x78, 0x12, 0x30, 0x0a, 0x02, 0x76, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x20, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x56, 0x4d, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x52, 0x02, 0x76, 0x6d, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74,
+	0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x61, 0x67, 0x65,
+	0x6e, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x38, 0x0a, 0x05, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x18,
+	0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75,
+	0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x56,
+	0x73, 0x6f, 0x63, 0x6b, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x52, 0x05, 0x70, 0x6f, 0x72, 0x74, 0x73,
+	0x12, 0x3a, 0x0a, 0x07, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x20, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x49, 0x4f, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x07, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x12, 0x3e, 0x0a, 0x07,
+	0x62, 0x61, 0x6c, 0x6c, 0x6f, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e,
+	0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x42, 0x61, 0x6c, 0x6c, 0x6f, 0x6f, 0x6e, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x52, 0x07, 0x62, 0x61, 0x6c, 0x6c, 0x6f, 0x6f, 0x6e, 0x12, 0x46, 0x0a, 0x0d,
+	0x72, 0x65, 0x63, 0x65, 0x6e, 0x74, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18, 0x09, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x68, 0x69,
+	0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x0c, 0x72, 0x65, 0x63, 0x65, 0x6e, 0x74, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x73, 0x12, 0x3b, 0x0a, 0x07, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75,
+	0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x56,
+	0x4d, 0x43, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x52, 0x07, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72,
+	0x65, 0x12, 0x34, 0x0a, 0x16, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0b, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x14, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x67, 0x65, 0x6e, 0x74,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c,
+	0x61, 0x67, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x22, 0x0a, 0x0d,
+	0x62, 0x6f, 0x6f, 0x74, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x6d, 0x73, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0b, 0x62, 0x6f, 0x6f, 0x74, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4d, 0x73,
+	0x12, 0x22, 0x0a, 0x0d, 0x62, 0x6f, 0x6f, 0x74, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x6d,
+	0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x62, 0x6f, 0x6f, 0x74, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x4d, 0x73, 0x22, 0x8d, 0x01, 0x0a, 0x09, 0x54, 0x61, 0x73, 0x6b, 0x43, 0x72, 0x61,
+	0x73, 0x68, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x72, 0x5f,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69,
+	0x6e, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x65, 0x78, 0x69, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x65, 0x78, 0x69, 0x74, 0x65, 0x64,
+	0x5f, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x65,
+	0x64, 0x41, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x62, 0x75, 0x6e, 0x64, 0x6c, 0x65, 0x5f, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x62, 0x75, 0x6e, 0x64, 0x6c, 0x65,
+	0x50, 0x61, 0x74, 0x68, 0x22, 0x22, 0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x65, 0x49,
+	0x6e, 0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x23, 0x0a, 0x0d, 0x43, 0x6f, 0x6e, 0x73,
+	0x6f, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xa7, 0x01,
+	0x0a, 0x09, 0x56, 0x4d, 0x43, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x63,
+	0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x12, 0x3f,
+	0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x27,
+	0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x56, 0x4d, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72,
+	0x65, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12,
+	0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x3b, 0x0a, 0x18, 0x41, 0x74, 0x74, 0x65, 0x73,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x44, 0x61, 0x74, 0x61, 0x22, 0xd5, 0x01, 0x0a, 0x11, 0x4c, 0x61, 0x75, 0x6e, 0x63, 0x68, 0x4d,
+	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x66, 0x69,
+	0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0c, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x50, 0x61, 0x74, 0x68, 0x12,
+	0x27, 0x0a, 0x0f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x73, 0x68, 0x61, 0x32,
+	0x35, 0x36, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61,
+	0x72, 0x65, 0x53, 0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x1f, 0x0a, 0x0b, 0x6b, 0x65, 0x72, 0x6e,
+	0x65, 0x6c, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6b,
+	0x65, 0x72, 0x6e, 0x65, 0x6c, 0x50, 0x61, 0x74, 0x68, 0x12, 0x23, 0x0a, 0x0d, 0x6b, 0x65, 0x72,
+	0x6e, 0x65, 0x6c, 0x5f, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0c, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x53, 0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x18,
+	0x0a, 0x07, 0x63, 0x6d, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x63, 0x6d, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x63, 0x70, 0x75,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x76, 0x63, 0x70, 0x75, 0x22, 0x96, 0x01, 0x0a,
+	0x19, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x65,
+	0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a,
+	0x74, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
+	0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x12, 0x41, 0x0a, 0x06, 0x6c, 0x61, 0x75, 0x6e, 0x63, 0x68, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x29, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x4c, 0x61, 0x75, 0x6e,
+	0x63, 0x68, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x6c,
+	0x61, 0x75, 0x6e, 0x63, 0x68, 0x22, 0x13, 0x0a, 0x11, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x62,
+	0x65, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x9d, 0x01, 0x0a, 0x09, 0x52,
+	0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x69, 0x72, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x69, 0x72,
+	0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x24,
+	0x0a, 0x0e, 0x6f, 0x6e, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x62, 0x75, 0x72, 0x73, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6f, 0x6e, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x42,
+	0x75, 0x72, 0x73, 0x74, 0x12, 0x24, 0x0a, 0x0e, 0x72, 0x65, 0x66, 0x69, 0x6c, 0x6c, 0x5f, 0x74,
+	0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x72, 0x65,
+	0x66, 0x69, 0x6c, 0x6c, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x22, 0xc0, 0x01, 0x0a, 0x08, 0x56,
+	0x4d, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12,
+	0x1b, 0x0a, 0x09, 0x72, 0x65, 0x61, 0x64, 0x5f, 0x6f, 0x6e, 0x6c, 0x79, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x08, 0x72, 0x65, 0x61, 0x64, 0x4f, 0x6e, 0x6c, 0x79, 0x12, 0x1b, 0x0a, 0x09,
+	0x67, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x6d, 0x61, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x67, 0x75, 0x65, 0x73, 0x74, 0x4d, 0x61, 0x63, 0x12, 0x42, 0x0a, 0x0b, 0x72, 0x61, 0x74,
+	0x65, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21,
+	0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69,
+	0x74, 0x52, 0x0a, 0x72, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x73, 0x22, 0xb0, 0x02,
+	0x0a, 0x12, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x63, 0x70, 0x75, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x76, 0x63, 0x70, 0x75, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x65,
+	0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6d, 0x69, 0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x69, 0x62, 0x12, 0x3a, 0x0a, 0x07, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x73, 0x68, 0x69,
+	0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x56, 0x4d, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x52, 0x07, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x62, 0x61, 0x6c, 0x6c, 0x6f, 0x6f, 0x6e,
+	0x5f, 0x6d, 0x69, 0x62, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x62, 0x61, 0x6c, 0x6c,
+	0x6f, 0x6f, 0x6e, 0x4d, 0x69, 0x62, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x72, 0x69, 0x66, 0x74, 0x18,
+	0x07, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x64, 0x72, 0x69, 0x66, 0x74, 0x12, 0x1f, 0x0a, 0x0b,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x6a, 0x73, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x4a, 0x73, 0x6f, 0x6e, 0x12, 0x21, 0x0a,
+	0x0c, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x5f, 0x6a, 0x73, 0x6f, 0x6e, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x4a, 0x73, 0x6f, 0x6e,
+	0x22, 0x4f, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x29, 0x0a, 0x10, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64,
+	0x73, 0x22, 0x2b, 0x0a, 0x0f, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x2a, 0x4a,
+	0x0a, 0x0f, 0x56, 0x4d, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x52, 0x65, 0x61, 0x73, 0x6f,
+	0x6e, 0x12, 0x16, 0x0a, 0x12, 0x56, 0x4d, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f,
+	0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x4b, 0x45, 0x52,
+	0x4e, 0x45, 0x4c, 0x5f, 0x50, 0x41, 0x4e, 0x49, 0x43, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x47,
+	0x55, 0x45, 0x53, 0x54, 0x5f, 0x4f, 0x4f, 0x4d, 0x10, 0x02, 0x32, 0x8b, 0x04, 0x0a, 0x10, 0x53,
+	0x68, 0x69, 0x6d, 0x44, 0x65, 0x62, 0x75, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x5a, 0x0a, 0x07, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x12, 0x26, 0x2e, 0x73, 0x68, 0x69,
+	0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x27, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x49, 0x6e, 0x73, 0x70,
+	0x65, 0x63, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x60, 0x0a, 0x0d, 0x41,
+	0x74, 0x74, 0x61, 0x63, 0x68, 0x43, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x65, 0x12, 0x24, 0x2e, 0x73,
+	0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x65, 0x49, 0x6e, 0x70,
+	0x75, 0x74, 0x1a, 0x25, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x6f, 0x6e, 0x73,
+	0x6f, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x28, 0x01, 0x30, 0x01, 0x12, 0x78, 0x0a,
+	0x11, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x12, 0x30, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x74, 0x74, 0x65,
+	0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x31, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67,
+	0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x63, 0x0a, 0x0a, 0x44, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x62, 0x65, 0x56, 0x4d, 0x12, 0x29, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75,
+	0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x56, 0x4d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x2a, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69,
+	0x62, 0x65, 0x56, 0x4d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5a, 0x0a, 0x07,
+	0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x26, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65,
+	0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x27, 0x2e, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x1f, 0x5a, 0x1d, 0x70, 0x6b, 0x67, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x3b,
+	0x73, 0x68, 0x69, 0x6d, 0x64, 0x65, 0x62, 0x75, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_pkg_proto_shimdebug_proto_rawDescOnce sync.Once
+	file_pkg_proto_shimdebug_proto_rawDescData = file_pkg_proto_shimdebug_proto_rawDesc
+)
+
+func file_pkg_proto_shimdebug_proto_rawDescGZIP() []byte {
+	file_pkg_proto_shimdebug_proto_rawDescOnce.Do(func() {
+		file_pkg_proto_shimdebug_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_proto_shimdebug_proto_rawDescData)
+	})
+	return file_pkg_proto_shimdebug_proto_rawDescData
+}
+
+var file_pkg_proto_shimdebug_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pkg_proto_shimdebug_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_pkg_proto_shimdebug_proto_goTypes = []any{
+	(VMFailureReason)(0),              // 0: shimdebug.services.api.VMFailureReason
+	(*InspectRequest)(nil),            // 1: shimdebug.services.api.InspectRequest
+	(*VMConfig)(nil),                  // 2: shimdebug.services.api.VMConfig
+	(*VsockPorts)(nil),                // 3: shimdebug.services.api.VsockPorts
+	(*IOStream)(nil),                  // 4: shimdebug.services.api.IOStream
+	(*BalloonState)(nil),              // 5: shimdebug.services.api.BalloonState
+	(*ShimError)(nil),                 // 6: shimdebug.services.api.ShimError
+	(*InspectResponse)(nil),           // 7: shimdebug.services.api.InspectResponse
+	(*TaskCrash)(nil),                 // 8: shimdebug.services.api.TaskCrash
+	(*ConsoleInput)(nil),              // 9: shimdebug.services.api.ConsoleInput
+	(*ConsoleOutput)(nil),             // 10: shimdebug.services.api.ConsoleOutput
+	(*VMCrashed)(nil),                 // 11: shimdebug.services.api.VMCrashed
+	(*AttestationReportRequest)(nil),  // 12: shimdebug.services.api.AttestationReportRequest
+	(*LaunchMeasurement)(nil),         // 13: shimdebug.services.api.LaunchMeasurement
+	(*AttestationReportResponse)(nil), // 14: shimdebug.services.api.AttestationReportResponse
+	(*DescribeVMRequest)(nil),         // 15: shimdebug.services.api.DescribeVMRequest
+	(*RateLimit)(nil),                 // 16: shimdebug.services.api.RateLimit
+	(*VMDevice)(nil),                  // 17: shimdebug.services.api.VMDevice
+	(*DescribeVMResponse)(nil),        // 18: shimdebug.services.api.DescribeVMResponse
+	(*ProfileRequest)(nil),            // 19: shimdebug.services.api.ProfileRequest
+	(*ProfileResponse)(nil),           // 20: shimdebug.services.api.ProfileResponse
+}
+var file_pkg_proto_shimdebug_proto_depIdxs = []int32{
+	2,  // 0: shimdebug.services.api.InspectResponse.vm:type_name -> shimdebug.services.api.VMConfig
+	3,  // 1: shimdebug.services.api.InspectResponse.ports:type_name -> shimdebug.services.api.VsockPorts
+	4,  // 2: shimdebug.services.api.InspectResponse.streams:type_name -> shimdebug.services.api.IOStream
+	5,  // 3: shimdebug.services.api.InspectResponse.balloon:type_name -> shimdebug.services.api.BalloonState
+	6,  // 4: shimdebug.services.api.InspectResponse.recent_errors:type_name -> shimdebug.services.api.ShimError
+	11, // 5: shimdebug.services.api.InspectResponse.failure:type_name -> shimdebug.services.api.VMCrashed
+	0,  // 6: shimdebug.services.api.VMCrashed.reason:type_name -> shimdebug.services.api.VMFailureReason
+	13, // 7: shimdebug.services.api.AttestationReportResponse.launch:type_name -> shimdebug.services.api.LaunchMeasurement
+	16, // 8: shimdebug.services.api.VMDevice.rate_limits:type_name -> shimdebug.services.api.RateLimit
+	17, // 9: shimdebug.services.api.DescribeVMResponse.devices:type_name -> shimdebug.services.api.VMDevice
+	1,  // 10: shimdebug.services.api.ShimDebugService.Inspect:input_type -> shimdebug.services.api.InspectRequest
+	9,  // 11: shimdebug.services.api.ShimDebugService.AttachConsole:input_type -> shimdebug.services.api.ConsoleInput
+	12, // 12: shimdebug.services.api.ShimDebugService.AttestationReport:input_type -> shimdebug.services.api.AttestationReportRequest
+	15, // 13: shimdebug.services.api.ShimDebugService.DescribeVM:input_type -> shimdebug.services.api.DescribeVMRequest
+	19, // 14: shimdebug.services.api.ShimDebugService.Profile:input_type -> shimdebug.services.api.ProfileRequest
+	7,  // 15: shimdebug.services.api.ShimDebugService.Inspect:output_type -> shimdebug.services.api.InspectResponse
+	10, // 16: shimdebug.services.api.ShimDebugService.AttachConsole:output_type -> shimdebug.services.api.ConsoleOutput
+	14, // 17: shimdebug.services.api.ShimDebugService.AttestationReport:output_type -> shimdebug.services.api.AttestationReportResponse
+	18, // 18: shimdebug.services.api.ShimDebugService.DescribeVM:output_type -> shimdebug.services.api.DescribeVMResponse
+	20, // 19: shimdebug.services.api.ShimDebugService.Profile:output_type -> shimdebug.services.api.ProfileResponse
+	15, // [15:20] is the sub-list for method output_type
+	10, // [10:15] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_pkg_proto_shimdebug_proto_init() }
+func file_pkg_proto_shimdebug_proto_init() {
+	if File_pkg_proto_shimdebug_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pkg_proto_shimdebug_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*InspectRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*VMConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*VsockPorts); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*IOStream); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*BalloonState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*ShimError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*InspectResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*TaskCrash); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ConsoleInput); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*ConsoleOutput); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*VMCrashed); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*AttestationReportRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*LaunchMeasurement); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*AttestationReportResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*DescribeVMRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[15].Exporter = func(v any, i int) any {
+			switch v := v.(*RateLimit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[16].Exporter = func(v any, i int) any {
+			switch v := v.(*VMDevice); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[17].Exporter = func(v any, i int) any {
+			switch v := v.(*DescribeVMResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[18].Exporter = func(v any, i int) any {
+			switch v := v.(*ProfileRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_proto_shimdebug_proto_msgTypes[19].Exporter = func(v any, i int) any {
+			switch v := v.(*ProfileResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pkg_proto_shimdebug_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_proto_shimdebug_proto_goTypes,
+		DependencyIndexes: file_pkg_proto_shimdebug_proto_depIdxs,
+		EnumInfos:         file_pkg_proto_shimdebug_proto_enumTypes,
+		MessageInfos:      file_pkg_proto_shimdebug_proto_msgTypes,
+	}.Build()
+	File_pkg_proto_shimdebug_proto = out.File
+	file_pkg_proto_shimdebug_proto_rawDesc = nil
+	file_pkg_proto_shimdebug_proto_goTypes = nil
+	file_pkg_proto_shimdebug_proto_depIdxs = nil
+}
@@ -0,0 +1,153 @@
+package pushmetrics
+
+import (
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const otlpScope = "vistara-node/pkg/pushmetrics"
+
+// OTLP temporality of counters, whose values are cumulative since start
+const otlpCumulative = 2
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+}
+
+// encodeOTLP encodes the samples as an OTLP ExportMetricsServiceRequest
+// in JSON, with the common labels as resource attributes
+func encodeOTLP(samples []Sample, labels map[string]string) ([]byte, error) {
+	var metrics []*otlpMetric
+	byName := make(map[string]*otlpMetric)
+
+	for _, sample := range samples {
+		metric, ok := byName[sample.Name]
+		if !ok {
+			metric = &otlpMetric{Name: sample.Name}
+			if sample.Counter {
+				metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			} else {
+				metric.Gauge = &otlpGauge{}
+			}
+
+			byName[sample.Name] = metric
+			metrics = append(metrics, metric)
+		}
+
+		point := otlpDataPoint{
+			Attributes:   otlpAttributes(sample.Labels),
+			TimeUnixNano: strconv.FormatInt(sample.Time.UnixNano(), 10),
+			AsDouble:     sample.Value,
+		}
+
+		if metric.Sum != nil {
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+		} else {
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+		}
+	}
+
+	request := map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes(labels)},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   map[string]any{"name": otlpScope},
+				"metrics": metrics,
+			}},
+		}},
+	}
+
+	return json.Marshal(request)
+}
+
+func otlpAttributes(labels map[string]string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		attribute := otlpAttribute{Key: key}
+		attribute.Value.StringValue = labels[key]
+		attributes = append(attributes, attribute)
+	}
+
+	return attributes
+}
+
+// encodeRemoteWrite encodes the samples as a snappy compressed Prometheus
+// remote-write WriteRequest, one time series per sample
+func encodeRemoteWrite(samples []Sample, labels map[string]string) []byte {
+	var request []byte
+
+	for _, sample := range samples {
+		all := maps.Clone(sample.Labels)
+		if all == nil {
+			all = make(map[string]string, len(labels)+1)
+		}
+
+		for key, value := range labels {
+			if _, ok := all[key]; !ok {
+				all[key] = value
+			}
+		}
+
+		all["__name__"] = sample.Name
+
+		var series []byte
+
+		// Remote-write requires the labels to be sorted by name
+		for _, key := range slices.Sorted(maps.Keys(all)) {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, key)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, all[key])
+
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, label)
+		}
+
+		var value []byte
+		value = protowire.AppendTag(value, 1, protowire.Fixed64Type)
+		value = protowire.AppendFixed64(value, math.Float64bits(sample.Value))
+		value = protowire.AppendTag(value, 2, protowire.VarintType)
+		value = protowire.AppendVarint(value, uint64(sample.Time.UnixMilli()))
+
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, value)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+
+	return s2.EncodeSnappy(nil, request)
+}
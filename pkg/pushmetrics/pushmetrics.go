@@ -0,0 +1,334 @@
+package pushmetrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ProtocolOTLP pushes to an OTLP/HTTP receiver with the JSON encoding
+	ProtocolOTLP = "otlp"
+	// ProtocolRemoteWrite pushes to a Prometheus remote-write endpoint
+	ProtocolRemoteWrite = "remote-write"
+
+	DefaultInterval   = time.Second * 30
+	DefaultBatchSize  = 500
+	DefaultMaxRetries = 5
+	// Samples kept while the endpoint can't be reached, the oldest are
+	// dropped beyond that
+	DefaultMaxPending = 10000
+
+	pushTimeout       = time.Second * 10
+	retryInitialDelay = time.Millisecond * 500
+	retryMaxDelay     = time.Second * 10
+	maxErrorBodyBytes = 1024
+)
+
+// Config is the push export of the metrics of a node, shared by the agent
+// and the shims of the node through a JSON file
+type Config struct {
+	Protocol string `json:"protocol"`
+	// OTLP receiver (the /v1/metrics path is appended) or remote-write URL
+	Endpoint string `json:"endpoint"`
+	// Extra headers of the requests, like an authorization
+	Headers map[string]string `json:"headers,omitempty"`
+	// Period of the collections, DefaultInterval if zero
+	Interval time.Duration `json:"interval,omitempty"`
+	// Samples sent per request, DefaultBatchSize if zero
+	BatchSize int `json:"batch_size,omitempty"`
+	// Attempts after the first failed one, DefaultMaxRetries if zero
+	MaxRetries int `json:"max_retries,omitempty"`
+	// Labels added to every sample, OTLP resource attributes
+	Labels map[string]string `json:"labels,omitempty"`
+	// Names of the labels holding the node and the tenant of the samples,
+	// "node" and "tenant" if empty
+	NodeLabel   string `json:"node_label,omitempty"`
+	TenantLabel string `json:"tenant_label,omitempty"`
+}
+
+// Validate checks the config and fills in the defaults
+func (c *Config) Validate() error {
+	if c.Protocol != ProtocolOTLP && c.Protocol != ProtocolRemoteWrite {
+		return fmt.Errorf("unknown metrics push protocol %q, expected %q or %q", c.Protocol, ProtocolOTLP, ProtocolRemoteWrite)
+	}
+
+	if c.Endpoint == "" {
+		return errors.New("no metrics push endpoint")
+	}
+
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+
+	if c.NodeLabel == "" {
+		c.NodeLabel = "node"
+	}
+
+	if c.TenantLabel == "" {
+		c.TenantLabel = "tenant"
+	}
+
+	return nil
+}
+
+// WriteConfig writes the config to the file the shims read it from
+func WriteConfig(path string, cfg *Config) error {
+	contents, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		return fmt.Errorf("failed to write metrics push config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ReadConfig reads the config written by the agent, nil if metrics
+// aren't pushed
+func ReadConfig(path string) (*Config, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read metrics push config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(contents, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics push config %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Sample is the value of a metric at the time it was collected
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	// Added as the tenant label if set
+	Tenant string
+	Value  float64
+	// Counters are cumulative, the others are gauges
+	Counter bool
+	Time    time.Time
+}
+
+// Pusher periodically collects samples and pushes them in batches,
+// retrying the failed batches with exponential backoff. Batches still
+// failing are kept until the next push, up to DefaultMaxPending samples
+type Pusher struct {
+	cfg     *Config
+	logger  *log.Entry
+	collect func() []Sample
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending []Sample
+}
+
+// NewPusher returns a pusher of the samples returned by collect, the
+// config must have been validated
+func NewPusher(logger *log.Entry, cfg *Config, collect func() []Sample) *Pusher {
+	return &Pusher{
+		cfg:     cfg,
+		logger:  logger.WithField("endpoint", cfg.Endpoint),
+		collect: collect,
+		client:  &http.Client{Timeout: pushTimeout},
+	}
+}
+
+// Run pushes the samples every interval until the context is done, then
+// makes a last attempt at pushing a final collection
+func (p *Pusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The context of the last push outlives the one of the pusher
+			flushCtx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+			p.Add(p.collect()...)
+			p.Flush(flushCtx)
+			cancel()
+
+			return
+		case <-ticker.C:
+			p.Add(p.collect()...)
+			p.Flush(ctx)
+		}
+	}
+}
+
+// Add queues samples for the next push
+func (p *Pusher) Add(samples ...Sample) {
+	for i, sample := range samples {
+		if sample.Tenant != "" {
+			samples[i].Labels = maps.Clone(sample.Labels)
+			if samples[i].Labels == nil {
+				samples[i].Labels = make(map[string]string, 1)
+			}
+
+			samples[i].Labels[p.cfg.TenantLabel] = sample.Tenant
+			samples[i].Tenant = ""
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = append(p.pending, samples...)
+
+	if dropped := len(p.pending) - DefaultMaxPending; dropped > 0 {
+		p.logger.Warnf("dropping %d metric samples that couldn't be pushed", dropped)
+		p.pending = p.pending[dropped:]
+	}
+}
+
+// Flush pushes the pending samples, the batches that failed stay pending
+// unless they were rejected
+func (p *Pusher) Flush(ctx context.Context) {
+	p.mu.Lock()
+	samples := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	var failed []Sample
+
+	for start := 0; start < len(samples); start += p.cfg.BatchSize {
+		batch := samples[start:min(start+p.cfg.BatchSize, len(samples))]
+
+		err := p.pushWithRetries(ctx, batch)
+
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			p.logger.WithError(err).Errorf("dropping %d metric samples rejected by the endpoint", len(batch))
+		} else if err != nil {
+			p.logger.WithError(err).Warnf("failed to push %d metric samples", len(batch))
+			failed = append(failed, batch...)
+		}
+	}
+
+	if len(failed) > 0 {
+		// Older than anything added since
+		p.mu.Lock()
+		added := p.pending
+		p.pending = failed
+		p.mu.Unlock()
+
+		p.Add(added...)
+	}
+}
+
+func (p *Pusher) pushWithRetries(ctx context.Context, batch []Sample) error {
+	body, contentType, err := p.encode(batch)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	delay := retryInitialDelay
+
+	for attempt := 0; ; attempt++ {
+		err := p.push(ctx, body, contentType)
+
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == p.cfg.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+func (p *Pusher) encode(batch []Sample) ([]byte, string, error) {
+	if p.cfg.Protocol == ProtocolRemoteWrite {
+		return encodeRemoteWrite(batch, p.cfg.Labels), "application/x-protobuf", nil
+	}
+
+	body, err := encodeOTLP(batch, p.cfg.Labels)
+
+	return body, "application/json", err
+}
+
+// permanentError is a rejection of a batch that retrying won't change
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (p *Pusher) push(ctx context.Context, body []byte, contentType string) error {
+	url := p.cfg.Endpoint
+	if p.cfg.Protocol == ProtocolOTLP {
+		url += "/v1/metrics"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	if p.cfg.Protocol == ProtocolRemoteWrite {
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+
+	for key, value := range p.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	err = fmt.Errorf("got status %s: %s", resp.Status, bytes.TrimSpace(message))
+
+	// Only throttling and server errors are retried
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return permanentError{err}
+	}
+
+	return err
+}
@@ -0,0 +1,521 @@
+// Package registry implements the parts of the OCI distribution API needed
+// to push images with docker, buildkit or oras and pull them with
+// containerd, storing them in a local directory. It is meant for
+// development clusters and has no authentication nor garbage collection
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	log "github.com/sirupsen/logrus"
+)
+
+// Largest manifest accepted, like the reference registry implementation
+const maxManifestSize = 4 << 20
+
+var (
+	// Repository names, path components separated by slashes
+	nameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*$`)
+	tagRegexp  = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// Registry serves the images stored under its root directory:
+//
+//	blobs/sha256/HEX                   layers, configs and manifests
+//	uploads/UUID                       blob uploads in progress
+//	repositories/NAME/tags/TAG         digest of the manifest tagged TAG
+//	repositories/NAME/manifests/HEX    media type of a manifest of NAME
+type Registry struct {
+	logger *log.Logger
+	root   string
+}
+
+func New(logger *log.Logger, root string) (*Registry, error) {
+	for _, dir := range []string{"blobs/sha256", "uploads", "repositories"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create registry dir: %w", err)
+		}
+	}
+
+	return &Registry{logger: logger, root: root}, nil
+}
+
+// Serve serves the registry on addr until ctx is done
+func (r *Registry) Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: r, ReadHeaderTimeout: time.Second * 10}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	r.logger.Infof("Registry listening on %s", listener.Addr())
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("registry server failed: %w", err)
+	}
+
+	return nil
+}
+
+// registryError is returned in the errors array of failed responses
+type registryError struct {
+	status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *registryError) Error() string {
+	return e.Message
+}
+
+func newRegistryError(status int, code, format string, args ...any) *registryError {
+	return &registryError{status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var regErr *registryError
+	if !errors.As(err, &regErr) {
+		regErr = newRegistryError(http.StatusInternalServerError, "UNKNOWN", "%s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(regErr.status)
+	_ = json.NewEncoder(w).Encode(map[string][]*registryError{"errors": {regErr}})
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if err := r.route(w, req); err != nil {
+		r.logger.WithError(err).Debugf("registry request %s %s failed", req.Method, req.URL.Path)
+		writeError(w, err)
+	}
+}
+
+func (r *Registry) route(w http.ResponseWriter, req *http.Request) error {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == req.URL.Path {
+		return newRegistryError(http.StatusNotFound, "UNSUPPORTED", "unsupported path %s", req.URL.Path)
+	}
+
+	if path == "" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+
+		return nil
+	}
+
+	// Repository names contain slashes, the operation is the last
+	// well-known part of the path
+	for _, op := range []string{"/blobs/uploads/", "/blobs/uploads", "/blobs/", "/manifests/", "/tags/list"} {
+		index := strings.LastIndex(path, op)
+		if index <= 0 {
+			continue
+		}
+
+		name, rest := path[:index], path[index+len(op):]
+		if !nameRegexp.MatchString(name) {
+			return newRegistryError(http.StatusBadRequest, "NAME_INVALID", "invalid repository name %q", name)
+		}
+
+		switch op {
+		case "/blobs/uploads/", "/blobs/uploads":
+			return r.handleUpload(w, req, name, rest)
+		case "/blobs/":
+			return r.handleBlob(w, req, rest)
+		case "/manifests/":
+			return r.handleManifest(w, req, name, rest)
+		default:
+			return r.handleTags(w, req, name)
+		}
+	}
+
+	return newRegistryError(http.StatusNotFound, "UNSUPPORTED", "unsupported path %s", req.URL.Path)
+}
+
+func (r *Registry) blobPath(dgst digest.Digest) string {
+	return filepath.Join(r.root, "blobs", "sha256", dgst.Encoded())
+}
+
+func (r *Registry) uploadPath(id string) string {
+	return filepath.Join(r.root, "uploads", id)
+}
+
+func (r *Registry) repositoryPath(name string, elem ...string) string {
+	return filepath.Join(append([]string{r.root, "repositories", filepath.FromSlash(name)}, elem...)...)
+}
+
+func parseDigest(value string) (digest.Digest, error) {
+	dgst, err := digest.Parse(value)
+	if err != nil || dgst.Algorithm() != digest.SHA256 {
+		return "", newRegistryError(http.StatusBadRequest, "DIGEST_INVALID", "invalid sha256 digest %q", value)
+	}
+
+	return dgst, nil
+}
+
+func (r *Registry) handleBlob(w http.ResponseWriter, req *http.Request, reference string) error {
+	dgst, err := parseDigest(reference)
+	if err != nil {
+		return err
+	}
+
+	switch req.Method {
+	case http.MethodHead, http.MethodGet:
+		f, err := os.Open(r.blobPath(dgst))
+		if errors.Is(err, os.ErrNotExist) {
+			return newRegistryError(http.StatusNotFound, "BLOB_UNKNOWN", "blob %s not found", dgst)
+		} else if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.Header().Set("Content-Type", "application/octet-stream")
+		// ServeContent handles HEAD and the range requests of
+		// resumed pulls
+		http.ServeContent(w, req, "", info.ModTime(), f)
+
+		return nil
+	default:
+		return newRegistryError(http.StatusMethodNotAllowed, "UNSUPPORTED", "method %s not allowed on blobs", req.Method)
+	}
+}
+
+func (r *Registry) handleUpload(w http.ResponseWriter, req *http.Request, name, id string) error {
+	if id == "" {
+		if req.Method != http.MethodPost {
+			return newRegistryError(http.StatusMethodNotAllowed, "UNSUPPORTED", "method %s not allowed on uploads", req.Method)
+		}
+
+		return r.startUpload(w, req, name)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return newRegistryError(http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload %q not found", id)
+	}
+
+	if _, err := os.Stat(r.uploadPath(id)); err != nil {
+		return newRegistryError(http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload %s not found", id)
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		return r.uploadStatus(w, name, id, http.StatusNoContent)
+	case http.MethodPatch:
+		if err := r.appendUpload(id, req.Body); err != nil {
+			return err
+		}
+
+		return r.uploadStatus(w, name, id, http.StatusAccepted)
+	case http.MethodPut:
+		dgst, err := parseDigest(req.URL.Query().Get("digest"))
+		if err != nil {
+			return err
+		}
+
+		if err := r.appendUpload(id, req.Body); err != nil {
+			return err
+		}
+
+		if err := r.commitUpload(id, dgst); err != nil {
+			return err
+		}
+
+		return blobCreated(w, name, dgst)
+	case http.MethodDelete:
+		if err := os.Remove(r.uploadPath(id)); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		return nil
+	default:
+		return newRegistryError(http.StatusMethodNotAllowed, "UNSUPPORTED", "method %s not allowed on uploads", req.Method)
+	}
+}
+
+// startUpload starts a chunked upload, or stores the blob at once for
+// monolithic uploads and cross-repository mounts
+func (r *Registry) startUpload(w http.ResponseWriter, req *http.Request, name string) error {
+	query := req.URL.Query()
+
+	// Blobs are shared by all repositories, mounting one is only
+	// checking it exists
+	if mount := query.Get("mount"); mount != "" {
+		if dgst, err := parseDigest(mount); err == nil {
+			if _, err := os.Stat(r.blobPath(dgst)); err == nil {
+				return blobCreated(w, name, dgst)
+			}
+		}
+	}
+
+	id := uuid.NewString()
+
+	f, err := os.Create(r.uploadPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	f.Close()
+
+	if value := query.Get("digest"); value != "" {
+		dgst, err := parseDigest(value)
+		if err != nil {
+			return err
+		}
+
+		if err := r.appendUpload(id, req.Body); err != nil {
+			return err
+		}
+
+		if err := r.commitUpload(id, dgst); err != nil {
+			return err
+		}
+
+		return blobCreated(w, name, dgst)
+	}
+
+	return r.uploadStatus(w, name, id, http.StatusAccepted)
+}
+
+func (r *Registry) appendUpload(id string, body io.Reader) error {
+	f, err := os.OpenFile(r.uploadPath(id), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open upload %s: %w", id, err)
+	}
+
+	defer f.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("failed to write upload %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// commitUpload moves a completed upload to the blobs once its content
+// matches the digest
+func (r *Registry) commitUpload(id string, dgst digest.Digest) error {
+	f, err := os.Open(r.uploadPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to open upload %s: %w", id, err)
+	}
+
+	verifier := dgst.Verifier()
+	_, err = io.Copy(verifier, f)
+	f.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to read upload %s: %w", id, err)
+	}
+
+	if !verifier.Verified() {
+		_ = os.Remove(r.uploadPath(id))
+
+		return newRegistryError(http.StatusBadRequest, "DIGEST_INVALID", "content of upload %s doesn't match digest %s", id, dgst)
+	}
+
+	if err := os.Rename(r.uploadPath(id), r.blobPath(dgst)); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", dgst, err)
+	}
+
+	return nil
+}
+
+func (r *Registry) uploadStatus(w http.ResponseWriter, name, id string, status int) error {
+	info, err := os.Stat(r.uploadPath(id))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Range", fmt.Sprintf("0-%d", max(info.Size()-1, 0)))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(status)
+
+	return nil
+}
+
+func blobCreated(w http.ResponseWriter, name string, dgst digest.Digest) error {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, dgst))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+// resolveManifest returns the digest of the manifest a tag or digest
+// reference points to in the repository
+func (r *Registry) resolveManifest(name, reference string) (digest.Digest, error) {
+	if dgst, err := digest.Parse(reference); err == nil {
+		if _, err := os.Stat(r.repositoryPath(name, "manifests", dgst.Encoded())); err != nil {
+			return "", newRegistryError(http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest %s not found in %s", dgst, name)
+		}
+
+		return dgst, nil
+	}
+
+	if !tagRegexp.MatchString(reference) {
+		return "", newRegistryError(http.StatusBadRequest, "TAG_INVALID", "invalid tag %q", reference)
+	}
+
+	value, err := os.ReadFile(r.repositoryPath(name, "tags", reference))
+	if err != nil {
+		return "", newRegistryError(http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest %s:%s not found", name, reference)
+	}
+
+	return parseDigest(string(value))
+}
+
+func (r *Registry) handleManifest(w http.ResponseWriter, req *http.Request, name, reference string) error {
+	switch req.Method {
+	case http.MethodHead, http.MethodGet:
+		dgst, err := r.resolveManifest(name, reference)
+		if err != nil {
+			return err
+		}
+
+		mediaType, err := os.ReadFile(r.repositoryPath(name, "manifests", dgst.Encoded()))
+		if err != nil {
+			return err
+		}
+
+		manifest, err := os.ReadFile(r.blobPath(dgst))
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", string(mediaType))
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(manifest)
+		}
+
+		return nil
+	case http.MethodPut:
+		return r.putManifest(w, req, name, reference)
+	default:
+		return newRegistryError(http.StatusMethodNotAllowed, "UNSUPPORTED", "method %s not allowed on manifests", req.Method)
+	}
+}
+
+func (r *Registry) putManifest(w http.ResponseWriter, req *http.Request, name, reference string) error {
+	manifest, err := io.ReadAll(io.LimitReader(req.Body, maxManifestSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if len(manifest) > maxManifestSize {
+		return newRegistryError(http.StatusRequestEntityTooLarge, "SIZE_INVALID", "manifest is larger than %d bytes", maxManifestSize)
+	}
+
+	mediaType := req.Header.Get("Content-Type")
+	if mediaType == "" {
+		var described struct {
+			MediaType string `json:"mediaType"`
+		}
+		if err := json.Unmarshal(manifest, &described); err != nil || described.MediaType == "" {
+			return newRegistryError(http.StatusBadRequest, "MANIFEST_INVALID", "manifest has no media type")
+		}
+
+		mediaType = described.MediaType
+	}
+
+	dgst := digest.FromBytes(manifest)
+
+	tag := ""
+	if expected, err := digest.Parse(reference); err == nil {
+		if expected != dgst {
+			return newRegistryError(http.StatusBadRequest, "DIGEST_INVALID", "manifest doesn't match digest %s", expected)
+		}
+	} else if tagRegexp.MatchString(reference) {
+		tag = reference
+	} else {
+		return newRegistryError(http.StatusBadRequest, "TAG_INVALID", "invalid tag %q", reference)
+	}
+
+	if err := os.WriteFile(r.blobPath(dgst), manifest, 0o644); err != nil {
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+
+	for _, dir := range []string{"manifests", "tags"} {
+		if err := os.MkdirAll(r.repositoryPath(name, dir), 0o755); err != nil {
+			return fmt.Errorf("failed to create repository %s: %w", name, err)
+		}
+	}
+
+	if err := os.WriteFile(r.repositoryPath(name, "manifests", dgst.Encoded()), []byte(mediaType), 0o644); err != nil {
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+
+	if tag != "" {
+		if err := os.WriteFile(r.repositoryPath(name, "tags", tag), []byte(dgst.String()), 0o644); err != nil {
+			return fmt.Errorf("failed to tag manifest: %w", err)
+		}
+	}
+
+	r.logger.Infof("Pushed %s:%s (%s)", name, reference, dgst)
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, dgst))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+func (r *Registry) handleTags(w http.ResponseWriter, req *http.Request, name string) error {
+	if req.Method != http.MethodGet {
+		return newRegistryError(http.StatusMethodNotAllowed, "UNSUPPORTED", "method %s not allowed on tags", req.Method)
+	}
+
+	entries, err := os.ReadDir(r.repositoryPath(name, "tags"))
+	if errors.Is(err, os.ErrNotExist) {
+		return newRegistryError(http.StatusNotFound, "NAME_UNKNOWN", "repository %s not found", name)
+	} else if err != nil {
+		return err
+	}
+
+	tags := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		tags = append(tags, entry.Name())
+	}
+	slices.Sort(tags)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	return json.NewEncoder(w).Encode(map[string]any{"name": name, "tags": tags})
+}
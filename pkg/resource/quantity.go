@@ -0,0 +1,177 @@
+package resource
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Bytes is a memory size
+type Bytes uint64
+
+const (
+	Byte Bytes = 1
+
+	KB Bytes = 1000
+	MB Bytes = 1000 * KB
+	GB Bytes = 1000 * MB
+	TB Bytes = 1000 * GB
+
+	KiB Bytes = 1024
+	MiB Bytes = 1024 * KiB
+	GiB Bytes = 1024 * MiB
+	TiB Bytes = 1024 * GiB
+)
+
+// memoryUnits are the suffixes of the memory sizes, matched case
+// insensitively. The decimal units are powers of 1000, the binary ones
+// (with an i) powers of 1024
+var memoryUnits = map[string]Bytes{
+	"b":  Byte,
+	"k":  KB,
+	"kb": KB,
+	"m":  MB,
+	"mb": MB,
+	"g":  GB,
+	"gb": GB,
+	"t":  TB,
+	"tb": TB,
+
+	"ki":  KiB,
+	"kib": KiB,
+	"mi":  MiB,
+	"mib": MiB,
+	"gi":  GiB,
+	"gib": GiB,
+	"ti":  TiB,
+	"tib": TiB,
+}
+
+// ParseMemory parses a memory size such as "512MiB", "2G" or "1.5Gi",
+// numbers without a unit are in defaultUnit. Fractions of a byte are
+// rounded up
+func ParseMemory(value string, defaultUnit Bytes) (Bytes, error) {
+	number, suffix := splitQuantity(value)
+
+	unit := defaultUnit
+	if suffix != "" {
+		var ok bool
+		if unit, ok = memoryUnits[strings.ToLower(suffix)]; !ok {
+			return 0, fmt.Errorf("invalid memory size %q: unknown unit %q", value, suffix)
+		}
+	}
+
+	bytes, err := scale(number, uint64(unit))
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size %q: %w", value, err)
+	}
+
+	return Bytes(bytes), nil
+}
+
+// MiB returns the size in MiB, rounded up
+func (b Bytes) MiB() uint64 {
+	return (uint64(b) + uint64(MiB) - 1) / uint64(MiB)
+}
+
+// String formats the size with the largest binary unit it is a multiple
+// of, e.g. "512MiB"
+func (b Bytes) String() string {
+	for _, unit := range []struct {
+		suffix string
+		size   Bytes
+	}{{"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB}} {
+		if b != 0 && b%unit.size == 0 {
+			return strconv.FormatUint(uint64(b/unit.size), 10) + unit.suffix
+		}
+	}
+
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// MilliCPU is an amount of CPU, in thousandths of a vCPU
+type MilliCPU uint64
+
+// ParseCPU parses an amount of CPU such as "2", "1.5" or "500m". Fractions
+// of a millicore are rounded up
+func ParseCPU(value string) (MilliCPU, error) {
+	number, suffix := splitQuantity(value)
+
+	unit := uint64(1000)
+
+	switch suffix {
+	case "":
+	case "m":
+		unit = 1
+	default:
+		return 0, fmt.Errorf("invalid CPU amount %q: unknown unit %q", value, suffix)
+	}
+
+	millis, err := scale(number, unit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU amount %q: %w", value, err)
+	}
+
+	return MilliCPU(millis), nil
+}
+
+// Cores returns the number of whole vCPUs covering the amount
+func (c MilliCPU) Cores() uint64 {
+	return (uint64(c) + 999) / 1000
+}
+
+// String formats the amount as vCPUs if it is a whole number of them,
+// otherwise in millicores, e.g. "2" or "1500m"
+func (c MilliCPU) String() string {
+	if c%1000 == 0 {
+		return strconv.FormatUint(uint64(c/1000), 10)
+	}
+
+	return strconv.FormatUint(uint64(c), 10) + "m"
+}
+
+// splitQuantity splits a quantity into its number and unit suffix
+func splitQuantity(value string) (string, string) {
+	value = strings.TrimSpace(value)
+
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end == -1 {
+		return value, ""
+	}
+
+	return value[:end], strings.TrimSpace(value[end:])
+}
+
+// scale multiplies a non-negative decimal number by the unit without
+// going through floats, so "1.1" GB is exactly 1100000000 bytes, rounding
+// up the result
+func scale(number string, unit uint64) (uint64, error) {
+	if number == "" {
+		return 0, fmt.Errorf("no number")
+	}
+
+	if strings.Count(number, ".") > 1 || strings.HasPrefix(number, ".") || strings.HasSuffix(number, ".") {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+
+	rat, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+
+	rat.Mul(rat, new(big.Rat).SetUint64(unit))
+
+	result, remainder := new(big.Int).QuoRem(rat.Num(), rat.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		result.Add(result, big.NewInt(1))
+	}
+
+	if !result.IsUint64() {
+		return 0, fmt.Errorf("%s is too large", number)
+	}
+
+	return result.Uint64(), nil
+}
@@ -0,0 +1,255 @@
+package resource_test
+
+import (
+	"strings"
+	"testing"
+
+	"vistara-node/pkg/resource"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		value       string
+		defaultUnit resource.Bytes
+		want        resource.Bytes
+		err         string
+	}{
+		{value: "512", defaultUnit: resource.MiB, want: 512 * resource.MiB},
+		{value: "512", defaultUnit: resource.Byte, want: 512},
+		{value: "100b", defaultUnit: resource.MiB, want: 100},
+		{value: "1Ki", defaultUnit: resource.MiB, want: resource.KiB},
+		{value: "1KiB", defaultUnit: resource.MiB, want: resource.KiB},
+		{value: "512Mi", defaultUnit: resource.MiB, want: 512 * resource.MiB},
+		{value: "512MiB", defaultUnit: resource.MiB, want: 512 * resource.MiB},
+		{value: "2Gi", defaultUnit: resource.MiB, want: 2 * resource.GiB},
+		{value: "1Ti", defaultUnit: resource.MiB, want: resource.TiB},
+		{value: "1k", defaultUnit: resource.MiB, want: resource.KB},
+		{value: "1kB", defaultUnit: resource.MiB, want: resource.KB},
+		{value: "3M", defaultUnit: resource.MiB, want: 3 * resource.MB},
+		{value: "2G", defaultUnit: resource.MiB, want: 2 * resource.GB},
+		{value: "2gb", defaultUnit: resource.MiB, want: 2 * resource.GB},
+		{value: "1T", defaultUnit: resource.MiB, want: resource.TB},
+		{value: "1.5Gi", defaultUnit: resource.MiB, want: 1536 * resource.MiB},
+		{value: "1.1G", defaultUnit: resource.MiB, want: 1100 * resource.MB},
+		{value: "0.5", defaultUnit: resource.GiB, want: 512 * resource.MiB},
+		{value: " 64 Mi ", defaultUnit: resource.MiB, want: 64 * resource.MiB},
+		{value: "0", defaultUnit: resource.MiB, want: 0},
+		// fractions of a byte are rounded up
+		{value: "1.0001k", defaultUnit: resource.MiB, want: 1001},
+		{value: "0.1b", defaultUnit: resource.MiB, want: 1},
+
+		{value: "16Ei", defaultUnit: resource.MiB, err: "unknown unit"},
+		{value: "16384Pi", defaultUnit: resource.MiB, err: "unknown unit"},
+		{value: "16777216Ti", defaultUnit: resource.MiB, err: "too large"},
+		{value: "18446744073709551616", defaultUnit: resource.Byte, err: "too large"},
+		{value: "18446744073709551615.5", defaultUnit: resource.Byte, err: "too large"},
+		{value: "18446744073709551615", defaultUnit: resource.Byte, want: 18446744073709551615},
+
+		{value: "", defaultUnit: resource.MiB, err: "no number"},
+		{value: "Gi", defaultUnit: resource.MiB, err: "no number"},
+		{value: "-1Gi", defaultUnit: resource.MiB, err: "unknown unit"},
+		{value: "1.2.3Gi", defaultUnit: resource.MiB, err: "invalid number"},
+		{value: ".5Gi", defaultUnit: resource.MiB, err: "invalid number"},
+		{value: "5.Gi", defaultUnit: resource.MiB, err: "invalid number"},
+		{value: "1Gx", defaultUnit: resource.MiB, err: "unknown unit"},
+		{value: "1 2Gi", defaultUnit: resource.MiB, err: "unknown unit"},
+		{value: "1e3", defaultUnit: resource.MiB, err: "unknown unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := resource.ParseMemory(tt.value, tt.defaultUnit)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v (%d)", tt.err, err, got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected %d bytes, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBytesString(t *testing.T) {
+	tests := []struct {
+		bytes resource.Bytes
+		want  string
+	}{
+		{bytes: 0, want: "0B"},
+		{bytes: 1, want: "1B"},
+		{bytes: resource.KB, want: "1000B"},
+		{bytes: resource.KiB, want: "1KiB"},
+		{bytes: 1536, want: "1536B"},
+		{bytes: 1536 * resource.KiB, want: "1536KiB"},
+		{bytes: 512 * resource.MiB, want: "512MiB"},
+		{bytes: 1536 * resource.MiB, want: "1536MiB"},
+		{bytes: 2 * resource.GiB, want: "2GiB"},
+		{bytes: resource.GB, want: "1000000000B"},
+		{bytes: 3 * resource.TiB, want: "3TiB"},
+		{bytes: 1024 * resource.TiB, want: "1024TiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.bytes.String(); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBytesMiB(t *testing.T) {
+	tests := []struct {
+		bytes resource.Bytes
+		want  uint64
+	}{
+		{bytes: 0, want: 0},
+		{bytes: 1, want: 1},
+		{bytes: resource.MiB, want: 1},
+		{bytes: resource.MiB + 1, want: 2},
+		{bytes: resource.GB, want: 954},
+		{bytes: 2 * resource.GiB, want: 2048},
+	}
+
+	for _, tt := range tests {
+		if got := tt.bytes.MiB(); got != tt.want {
+			t.Errorf("expected %d bytes to be %d MiB, got %d", tt.bytes, tt.want, got)
+		}
+	}
+}
+
+// Formatted sizes parse back to the same size
+func TestBytesRoundTrip(t *testing.T) {
+	for _, bytes := range []resource.Bytes{
+		0, 1, 999, resource.KB, resource.KiB, 1536, 64 * resource.MiB, 1536 * resource.MiB,
+		resource.GB, 7 * resource.GiB, resource.TiB, 16383 * resource.TiB, 18446744073709551615,
+	} {
+		formatted := bytes.String()
+
+		parsed, err := resource.ParseMemory(formatted, resource.MiB)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", formatted, err)
+		}
+
+		if parsed != bytes {
+			t.Errorf("%d bytes formatted as %q parsed back to %d", bytes, formatted, parsed)
+		}
+	}
+}
+
+func TestParseCPU(t *testing.T) {
+	tests := []struct {
+		value string
+		want  resource.MilliCPU
+		err   string
+	}{
+		{value: "2", want: 2000},
+		{value: "0", want: 0},
+		{value: "1.5", want: 1500},
+		{value: "0.25", want: 250},
+		{value: "500m", want: 500},
+		{value: "1500m", want: 1500},
+		{value: " 250m ", want: 250},
+		// fractions of a millicore are rounded up
+		{value: "0.0001", want: 1},
+		{value: "1.5m", want: 2},
+
+		{value: "18446744073709551", want: 18446744073709551000},
+		{value: "18446744073709552", err: "too large"},
+		{value: "18446744073709551616m", err: "too large"},
+
+		{value: "", err: "no number"},
+		{value: "m", err: "no number"},
+		{value: "-1", err: "unknown unit"},
+		{value: "1..5", err: "invalid number"},
+		{value: ".5", err: "invalid number"},
+		{value: "2.", err: "invalid number"},
+		{value: "500M", err: "unknown unit"},
+		{value: "2cores", err: "unknown unit"},
+		{value: "1Gi", err: "unknown unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := resource.ParseCPU(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v (%d)", tt.err, err, got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected %d millicores, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMilliCPUString(t *testing.T) {
+	tests := []struct {
+		cpu  resource.MilliCPU
+		want string
+	}{
+		{cpu: 0, want: "0"},
+		{cpu: 1, want: "1m"},
+		{cpu: 500, want: "500m"},
+		{cpu: 1000, want: "1"},
+		{cpu: 1500, want: "1500m"},
+		{cpu: 64000, want: "64"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cpu.String(); got != tt.want {
+			t.Errorf("expected %d millicores to be formatted as %q, got %q", tt.cpu, tt.want, got)
+		}
+	}
+}
+
+func TestMilliCPUCores(t *testing.T) {
+	tests := []struct {
+		cpu  resource.MilliCPU
+		want uint64
+	}{
+		{cpu: 0, want: 0},
+		{cpu: 1, want: 1},
+		{cpu: 1000, want: 1},
+		{cpu: 1001, want: 2},
+		{cpu: 2500, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cpu.Cores(); got != tt.want {
+			t.Errorf("expected %d millicores to cover %d cores, got %d", tt.cpu, tt.want, got)
+		}
+	}
+}
+
+// Formatted amounts parse back to the same amount
+func TestMilliCPURoundTrip(t *testing.T) {
+	for _, cpu := range []resource.MilliCPU{0, 1, 250, 999, 1000, 1001, 2500, 64000, 18446744073709551615} {
+		formatted := cpu.String()
+
+		parsed, err := resource.ParseCPU(formatted)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", formatted, err)
+		}
+
+		if parsed != cpu {
+			t.Errorf("%d millicores formatted as %q parsed back to %d", cpu, formatted, parsed)
+		}
+	}
+}
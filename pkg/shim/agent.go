@@ -0,0 +1,79 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/firecracker-microvm/firecracker-go-sdk/vsock"
+)
+
+const (
+	// Overall time the guest agent has to accept a connection after the
+	// VM was started
+	agentDialDeadline = time.Second * 10
+	// Bounds a single attempt, the vsock socket may not exist yet or the
+	// agent may not be listening
+	agentDialAttemptTimeout = time.Millisecond * 250
+
+	agentDialInitialBackoff = time.Millisecond * 5
+	agentDialMaxBackoff     = time.Millisecond * 500
+)
+
+// dialAgent connects to the guest agent, retrying with exponential backoff
+// until agentDialDeadline
+func dialAgent(ctx context.Context, vsockPath string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, agentDialDeadline)
+	defer cancel()
+
+	backoff := agentDialInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, agentDialAttemptTimeout)
+		conn, err := vsock.DialContext(attemptCtx, vsockPath, VSockPort,
+			vsock.WithRetryInterval(time.Millisecond),
+			vsock.WithDialTimeout(agentDialAttemptTimeout),
+			vsock.WithLogger(log.G(ctx)))
+		cancelAttempt()
+
+		if err == nil {
+			log.G(ctx).Debugf("connected to guest agent after %d attempts", attempt)
+
+			return conn, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to connect to guest agent after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, agentDialMaxBackoff)
+	}
+}
+
+// phaseTimer measures the consecutive phases of a task creation
+type phaseTimer struct {
+	start  time.Time
+	last   time.Time
+	phases map[string]time.Duration
+}
+
+func newPhaseTimer() *phaseTimer {
+	now := time.Now()
+
+	return &phaseTimer{start: now, last: now, phases: make(map[string]time.Duration)}
+}
+
+// done records the time elapsed since the previous phase ended
+func (t *phaseTimer) done(phase string) {
+	now := time.Now()
+	t.phases[phase] = now.Sub(t.last)
+	t.last = now
+}
+
+func (t *phaseTimer) total() time.Duration {
+	return time.Since(t.start)
+}
@@ -0,0 +1,135 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/log"
+	"github.com/containerd/ttrpc"
+	ioproxy "github.com/vistara-labs/firecracker-containerd/proto/service/ioproxy/ttrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "vistara-node/pkg/proto/agent"
+)
+
+// Protocol versions of the guest agent the shim speaks, it supports the
+// agents of the previous version along with the current one
+const (
+	// Agents of the vistara firecracker-containerd fork serving the task
+	// and IO proxy services, without version negotiation
+	agentProtocolLegacy = 1
+	// Agents serving the AgentVersion service
+	agentProtocolNegotiated = 2
+
+	minAgentProtocol = agentProtocolLegacy
+	maxAgentProtocol = agentProtocolNegotiated
+)
+
+const (
+	agentVersionService = "agent.services.api.AgentVersion"
+	agentTaskService    = "containerd.task.v2.Task"
+	agentIOProxyService = "IOProxy"
+
+	// Bounds each call negotiating with the agent, which is already
+	// accepting connections
+	agentNegotiationTimeout = time.Second * 5
+)
+
+// Services the shim calls, served by agents of every supported version
+var requiredAgentServices = []string{agentTaskService, agentIOProxyService}
+
+// agentProtocol is the protocol negotiated with the guest agent
+type agentProtocol struct {
+	version uint32
+	// release of the agent, empty for legacy agents
+	agentVersion string
+}
+
+// negotiateAgentProtocol agrees with the guest agent on the protocol
+// version they speak before the task is created. Legacy agents don't serve
+// the version service, the services the shim calls are probed instead. An
+// incompatible agent fails with an error naming what doesn't match rather
+// than the ttrpc error of the first call it can't answer
+func negotiateAgentProtocol(ctx context.Context, client *ttrpc.Client) (*agentProtocol, error) {
+	callCtx, cancel := context.WithTimeout(ctx, agentNegotiationTimeout)
+	defer cancel()
+
+	var resp pb.VersionResponse
+
+	err := client.Call(callCtx, agentVersionService, "Version", &pb.VersionRequest{MinVersion: minAgentProtocol, MaxVersion: maxAgentProtocol}, &resp)
+	if status.Code(err) == codes.Unimplemented {
+		return negotiateLegacyAgent(ctx, client)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to negotiate the protocol version of the guest agent: %w", err)
+	}
+
+	var problems []string
+
+	if resp.GetVersion() < minAgentProtocol || resp.GetVersion() > maxAgentProtocol {
+		problems = append(problems, fmt.Sprintf("protocol version %d, this shim speaks versions %d to %d", resp.GetVersion(), minAgentProtocol, maxAgentProtocol))
+	}
+
+	for _, service := range requiredAgentServices {
+		if !slices.Contains(resp.GetServices(), service) {
+			problems = append(problems, fmt.Sprintf("no %s service", service))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, incompatibleAgentError(resp.GetAgentVersion(), problems)
+	}
+
+	log.G(ctx).Debugf("negotiated protocol version %d with guest agent %s", resp.GetVersion(), resp.GetAgentVersion())
+
+	return &agentProtocol{version: resp.GetVersion(), agentVersion: resp.GetAgentVersion()}, nil
+}
+
+// negotiateLegacyAgent probes the services the shim calls on an agent
+// predating version negotiation. Any answer but Unimplemented, such as
+// the NotFound of the task that isn't created yet, means it is served
+func negotiateLegacyAgent(ctx context.Context, client *ttrpc.Client) (*agentProtocol, error) {
+	probes := []struct {
+		service, method string
+		req, resp       any
+	}{
+		{agentTaskService, "Connect", &taskAPI.ConnectRequest{}, &taskAPI.ConnectResponse{}},
+		{agentIOProxyService, "State", &ioproxy.StateRequest{}, &ioproxy.StateResponse{}},
+	}
+
+	var problems []string
+
+	for _, probe := range probes {
+		callCtx, cancel := context.WithTimeout(ctx, agentNegotiationTimeout)
+		err := client.Call(callCtx, probe.service, probe.method, probe.req, probe.resp)
+		cancel()
+
+		if status.Code(err) == codes.Unimplemented {
+			problems = append(problems, fmt.Sprintf("no %s.%s method", probe.service, probe.method))
+		} else if errors.Is(err, ttrpc.ErrClosed) {
+			return nil, fmt.Errorf("failed to probe the services of the guest agent: %w", err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, incompatibleAgentError("", problems)
+	}
+
+	log.G(ctx).Debugf("guest agent predates version negotiation, speaking protocol version %d", agentProtocolLegacy)
+
+	return &agentProtocol{version: agentProtocolLegacy}, nil
+}
+
+func incompatibleAgentError(agentVersion string, problems []string) error {
+	agent := "guest agent"
+	if agentVersion != "" {
+		agent += " " + agentVersion
+	}
+
+	return fmt.Errorf("%s is incompatible with this shim, rebuild the image with a supported agent: %s", agent, strings.Join(problems, "; "))
+}
@@ -0,0 +1,113 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/firecracker-microvm/firecracker-go-sdk/vsock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"vistara-node/pkg/hypervisor/cloudhypervisor"
+	pb "vistara-node/pkg/proto/shimdebug"
+)
+
+// AttestationPort is the vsock port the attestation helper of confidential
+// guests listens on. It reads the 64 bytes of report data, then writes the
+// report of the CPU binding them, an SEV-SNP attestation report or a TDX
+// quote, and closes the connection
+const AttestationPort = 10788
+
+const (
+	reportDataSize      = 64
+	maxReportSize       = 1 << 16
+	attestationDeadline = 30 * time.Second
+)
+
+// AttestationReport fetches an attestation report from the guest, so the
+// tenant can verify its workload runs in an encrypted VM launched from the
+// expected firmware and kernel
+func (d *debugServer) AttestationReport(ctx context.Context, req *pb.AttestationReportRequest) (*pb.AttestationReportResponse, error) {
+	vmState := d.shim.vmState
+	if vmState == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no VM runs in this shim")
+	}
+
+	if vmState.vm.Spec.Confidential == "" {
+		return nil, status.Error(codes.FailedPrecondition, "the VM is not confidential")
+	}
+
+	if len(req.GetReportData()) > reportDataSize {
+		return nil, status.Errorf(codes.InvalidArgument, "report data is %d bytes, at most %d are supported", len(req.GetReportData()), reportDataSize)
+	}
+
+	if err := d.shim.checkNotPaused(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	chSvc, ok := vmState.vmSvc.(*cloudhypervisor.Service)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "no attestation for %s VMs", vmState.vm.Spec.Provider)
+	}
+
+	launch, err := chSvc.LaunchMeasurement(vmState.vm)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reportData := make([]byte, reportDataSize)
+	copy(reportData, req.GetReportData())
+
+	report, err := fetchAttestationReport(ctx, vmState.vmSvc.VSockPath(vmState.vm), reportData)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to fetch attestation report from the guest: %v", err)
+	}
+
+	return &pb.AttestationReportResponse{
+		Technology: vmState.vm.Spec.Confidential,
+		Report:     report,
+		Launch: &pb.LaunchMeasurement{
+			FirmwarePath:   launch.FirmwarePath,
+			FirmwareSha256: launch.FirmwareSHA256,
+			KernelPath:     launch.KernelPath,
+			KernelSha256:   launch.KernelSHA256,
+			Cmdline:        launch.Cmdline,
+			Vcpu:           launch.VCPU,
+		},
+	}, nil
+}
+
+func fetchAttestationReport(ctx context.Context, vsockPath string, reportData []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, attestationDeadline)
+	defer cancel()
+
+	conn, err := vsock.DialContext(ctx, vsockPath, AttestationPort, vsock.WithLogger(log.G(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(reportData); err != nil {
+		return nil, fmt.Errorf("failed to send report data: %w", err)
+	}
+
+	report, err := io.ReadAll(io.LimitReader(conn, maxReportSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	if len(report) == 0 {
+		return nil, errors.New("the guest returned no report")
+	}
+
+	return report, nil
+}
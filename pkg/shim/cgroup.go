@@ -0,0 +1,81 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+
+	"vistara-node/pkg/cgroup"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/resource"
+
+	"github.com/containerd/log"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// HypervisorMemoryOverhead is the memory the hypervisor of a VM uses on
+// top of the memory of the guest, its memory limit leaves room for it
+const HypervisorMemoryOverhead = 128 * resource.MiB
+
+// hypervisorResources returns the limits of the hypervisor of a VM: its
+// vCPUs and memory, along with the memory high and disk limits of the
+// workload
+func hypervisorResources(spec models.MicroVMSpec, workload *specs.LinuxResources) (*specs.LinuxResources, error) {
+	mode := cgroup.DetectMode()
+
+	limits := &cgroup.Limits{
+		CPUFraction: float64(spec.VCPU),
+		MemoryBytes: uint64(spec.Memory() + HypervisorMemoryOverhead),
+	}
+
+	if workload == nil {
+		return limits.Resources(mode, nil)
+	}
+
+	if high := cgroup.MemoryHigh(workload); high > 0 {
+		limits.MemoryHighBytes = high + uint64(HypervisorMemoryOverhead)
+	}
+
+	resources, err := limits.Resources(mode, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.BlockIO = workload.BlockIO
+	if ioMax, ok := workload.Unified["io.max"]; ok {
+		resources.Unified["io.max"] = ioMax
+	}
+
+	return resources, nil
+}
+
+// limitHypervisor moves the hypervisor of the VM into a cgroup of its
+// own, limited like the workload would be with runc and accounted for as
+// the workload by the agent
+func (s *HyperShim) limitHypervisor(ctx context.Context, taskID string, workload *specs.LinuxResources) error {
+	resources, err := hypervisorResources(s.vmState.vm.Spec, workload)
+	if err != nil {
+		return err
+	}
+
+	pid, err := s.vmState.vmSvc.Pid(ctx, s.vmState.vm)
+	if err != nil {
+		return fmt.Errorf("failed to get the pid of the hypervisor: %w", err)
+	}
+
+	group, err := cgroup.NewGroup(cgroup.HypervisorGroup(taskID), resources)
+	if err != nil {
+		return err
+	}
+
+	if err := group.Add(pid); err != nil {
+		_ = group.Delete()
+
+		return err
+	}
+
+	s.vmState.cgroup = group
+
+	log.G(ctx).Infof("limited hypervisor %d with cgroup %s", pid, cgroup.DetectMode())
+
+	return nil
+}
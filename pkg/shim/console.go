@@ -0,0 +1,193 @@
+package shim
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"vistara-node/pkg/defaults"
+	pb "vistara-node/pkg/proto/shimdebug"
+)
+
+const consolePollInterval = time.Second
+
+// Kernel log lines emitted by the guest when the OOM killer is invoked
+var guestOOMPatterns = []string{
+	"Out of memory: Killed process",
+	"Memory cgroup out of memory",
+}
+
+// Kernel log lines emitted by the guest when it can't go on
+var guestPanicPatterns = []string{
+	"Kernel panic - not syncing",
+	"BUG: kernel NULL pointer dereference",
+}
+
+// watchConsole follows the serial console of the VM for failures of the
+// guest kernel, publishing a TaskOOM event whenever it reports an OOM kill,
+// mirroring what the runc shim does for cgroup OOM notifications
+func (s *HyperShim) watchConsole(ctx context.Context, containerID string) {
+	consolePath := s.vmState.vmSvc.ConsolePath(s.vmState.vm)
+
+	file, err := os.Open(consolePath)
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to open console %s", consolePath)
+
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	partial := ""
+
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial += chunk
+
+		if errors.Is(err, io.EOF) {
+			select {
+			case <-s.vmState.vmStopped:
+				return
+			case <-time.After(consolePollInterval):
+			}
+
+			continue
+		}
+
+		if err != nil {
+			log.G(ctx).WithError(err).Errorf("failed to read console %s", consolePath)
+
+			return
+		}
+
+		line := strings.TrimSpace(partial)
+		partial = ""
+
+		switch reason := guestFailure(line); reason {
+		case pb.VMFailureReason_GUEST_OOM:
+			log.G(ctx).Warnf("guest OOM detected for container %s: %s", containerID, line)
+
+			if err := s.remotePublisher.Publish(ctx, runtime.TaskOOMEventTopic, &apievents.TaskOOM{ContainerID: containerID}); err != nil {
+				log.G(ctx).WithError(err).Error("failed to publish OOM event")
+			}
+
+			s.markVMFailed(ctx, containerID, reason, line)
+		case pb.VMFailureReason_KERNEL_PANIC:
+			log.G(ctx).Errorf("guest kernel panic detected for container %s: %s", containerID, line)
+
+			s.markVMFailed(ctx, containerID, reason, line)
+
+			// The guest is gone, stopping the VM reports the exit of the
+			// task along with a crash bundle
+			if err := s.vmState.vmSvc.Stop(ctx, s.vmState.vm); err != nil {
+				log.G(ctx).WithError(err).Error("failed to stop VM after kernel panic")
+			}
+		case pb.VMFailureReason_VM_FAILURE_UNKNOWN:
+		}
+	}
+}
+
+func guestFailure(line string) pb.VMFailureReason {
+	for _, pattern := range guestPanicPatterns {
+		if strings.Contains(line, pattern) {
+			return pb.VMFailureReason_KERNEL_PANIC
+		}
+	}
+
+	for _, pattern := range guestOOMPatterns {
+		if strings.Contains(line, pattern) {
+			return pb.VMFailureReason_GUEST_OOM
+		}
+	}
+
+	return pb.VMFailureReason_VM_FAILURE_UNKNOWN
+}
+
+// markVMFailed records the failure of the guest, a kernel panic taking
+// precedence over an OOM kill, and publishes a VMCrashed event
+func (s *HyperShim) markVMFailed(ctx context.Context, containerID string, reason pb.VMFailureReason, line string) {
+	failure := &pb.VMCrashed{
+		ContainerId: containerID,
+		Reason:      reason,
+		Message:     line,
+		Timestamp:   time.Now().Unix(),
+	}
+
+	s.failureMu.Lock()
+	if s.failure == nil || reason == pb.VMFailureReason_KERNEL_PANIC {
+		s.failure = failure
+	}
+	s.failureMu.Unlock()
+
+	s.recordFailure(reason.String())
+
+	if err := s.remotePublisher.Publish(ctx, defaults.VMCrashedEventTopic, failure); err != nil {
+		log.G(ctx).WithError(err).Error("failed to publish VM crashed event")
+	}
+}
+
+func (s *HyperShim) vmFailure() *pb.VMCrashed {
+	s.failureMu.Lock()
+	defer s.failureMu.Unlock()
+
+	return s.failure
+}
+
+// AttachConsole proxies the stream to the console socket of the VM until
+// either the client detaches or the VM stops
+func (d *debugServer) AttachConsole(stream pb.ShimDebugService_AttachConsoleServer) error {
+	vmState := d.shim.vmState
+	if vmState == nil {
+		return status.Error(codes.FailedPrecondition, "no VM runs in this shim")
+	}
+
+	socketPath := vmState.vmSvc.ConsoleSocketPath(vmState.vm)
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to connect to console %s: %v", socketPath, err)
+	}
+	defer conn.Close()
+
+	go func() {
+		// Unblocks the read below once the client detached
+		defer conn.Close()
+
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			if _, err := conn.Write(in.GetData()); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.ConsoleOutput{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/protobuf"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/log"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/ports"
+	pb "vistara-node/pkg/proto/shimdebug"
+)
+
+// Amount of serial console kept in a crash bundle
+const crashConsoleTailBytes = 64 * 1024
+
+// CrashBundleDir is the directory holding the crash bundles of a task
+func CrashBundleDir(taskID string) string {
+	return filepath.Join(defaults.CrashDir, filepath.Base(taskID))
+}
+
+// reportCrash collects a crash bundle for a VM that exited while the shim
+// wasn't stopping it and publishes the exit of its task
+func (s *HyperShim) reportCrash(ctx context.Context, waitErr error) {
+	exitedAt := time.Now()
+	exitStatus := vmExitStatus(waitErr)
+	s.recordCrash()
+
+	bundle, err := s.writeCrashBundle(exitedAt, waitErr)
+	if err != nil {
+		log.G(ctx).WithError(err).Error("failed to write crash bundle")
+	} else {
+		log.G(ctx).Errorf("VM exited unexpectedly with status %d, crash bundle written to %s", exitStatus, bundle)
+	}
+
+	pid, _ := s.vmState.vmSvc.Pid(ctx, s.vmState.vm)
+
+	if err := s.remotePublisher.Publish(ctx, runtime.TaskExitEventTopic, &apievents.TaskExit{
+		ContainerID: s.taskID,
+		ID:          s.taskID,
+		Pid:         uint32(pid),
+		ExitStatus:  exitStatus,
+		ExitedAt:    protobuf.ToTimestamp(exitedAt),
+	}); err != nil {
+		log.G(ctx).WithError(err).Error("failed to publish exit event")
+	}
+
+	if err := s.remotePublisher.Publish(ctx, defaults.CrashEventTopic, &pb.TaskCrash{
+		ContainerId: s.taskID,
+		ExitStatus:  exitStatus,
+		ExitedAt:    exitedAt.Unix(),
+		BundlePath:  bundle,
+	}); err != nil {
+		log.G(ctx).WithError(err).Error("failed to publish crash event")
+	}
+}
+
+// vmExitStatus maps the exit of the hypervisor process to a task exit status
+func vmExitStatus(waitErr error) uint32 {
+	if waitErr == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(waitErr, &exitErr) {
+		return 255
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + uint32(status.Signal())
+	}
+
+	return uint32(exitErr.ExitCode())
+}
+
+// writeCrashBundle gathers the hypervisor stderr, the end of the serial
+// console and the machine config of the VM under CrashBundleDir
+func (s *HyperShim) writeCrashBundle(exitedAt time.Time, waitErr error) (string, error) {
+	dir := filepath.Join(CrashBundleDir(s.taskID), strconv.FormatInt(exitedAt.Unix(), 10))
+
+	if err := os.MkdirAll(dir, defaults.DataDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create crash bundle dir %s: %w", dir, err)
+	}
+
+	machine := struct {
+		VM       any       `json:"vm"`
+		Restored bool      `json:"restored"`
+		ExitedAt time.Time `json:"exited_at"`
+		WaitErr  string    `json:"wait_error,omitempty"`
+		Failure  string    `json:"failure,omitempty"`
+	}{VM: s.vmState.vm, Restored: s.restored, ExitedAt: exitedAt}
+
+	if failure := s.vmFailure(); failure != nil {
+		machine.Failure = fmt.Sprintf("%s: %s", failure.GetReason(), failure.GetMessage())
+	}
+
+	if waitErr != nil {
+		machine.WaitErr = waitErr.Error()
+	}
+
+	contents, err := json.MarshalIndent(machine, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal machine config: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "machine.json"), contents, defaults.DataFilePerm); err != nil {
+		return "", fmt.Errorf("failed to write machine config: %w", err)
+	}
+
+	if err := copyFileTail(s.vmState.vmSvc.ConsolePath(s.vmState.vm), filepath.Join(dir, "console.log"), crashConsoleTailBytes); err != nil {
+		log.G(s.shimCtx).WithError(err).Warn("failed to collect console of crashed VM")
+	}
+
+	if reporter, ok := s.vmState.vmSvc.(ports.MicroVMCrashReporter); ok {
+		for name, path := range reporter.CrashFiles(s.vmState.vm) {
+			if err := copyFileTail(path, filepath.Join(dir, name), 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.G(s.shimCtx).WithError(err).Warnf("failed to collect %s of crashed VM", name)
+			}
+		}
+	}
+
+	return dir, nil
+}
+
+// copyFileTail copies the last tailBytes of src to dst, the whole file if
+// tailBytes is 0
+func copyFileTail(src, dst string, tailBytes int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if tailBytes > 0 {
+		info, err := in.Stat()
+		if err != nil {
+			return err
+		}
+
+		if info.Size() > tailBytes {
+			if _, err := in.Seek(info.Size()-tailBytes, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaults.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
@@ -0,0 +1,219 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/containerd/log"
+	"github.com/sirupsen/logrus"
+	"github.com/vistara-labs/firecracker-containerd/proto"
+	"google.golang.org/grpc"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/hypervisor/firecracker"
+	pb "vistara-node/pkg/proto/shimdebug"
+)
+
+// Number of logged errors kept for the introspection endpoint
+const recentErrorsSize = 32
+
+// DebugSocketPath is the unix socket the shim of the task serves its
+// introspection endpoint on
+func DebugSocketPath(taskID string) string {
+	return filepath.Join(defaults.StateRootDir, "shim", "debug", taskID+".sock")
+}
+
+// recentErrors keeps the last errors logged by the shim, it is registered
+// as a logrus hook so every error logged through log.G is recorded
+type recentErrors struct {
+	mu      sync.Mutex
+	entries []*pb.ShimError
+}
+
+func (r *recentErrors) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+func (r *recentErrors) Fire(entry *logrus.Entry) error {
+	message := entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		message = fmt.Sprintf("%s: %v", message, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == recentErrorsSize {
+		r.entries = r.entries[1:]
+	}
+
+	r.entries = append(r.entries, &pb.ShimError{Timestamp: entry.Time.Unix(), Message: message})
+
+	return nil
+}
+
+func (r *recentErrors) list() []*pb.ShimError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*pb.ShimError(nil), r.entries...)
+}
+
+type debugServer struct {
+	pb.UnimplementedShimDebugServiceServer
+
+	shim *HyperShim
+}
+
+// startDebugServer serves the introspection endpoint until the shim exits
+func (s *HyperShim) startDebugServer(ctx context.Context) {
+	s.debugOnce.Do(func() {
+		logrus.AddHook(s.recentErrors)
+
+		path := DebugSocketPath(s.id)
+
+		if err := os.MkdirAll(filepath.Dir(path), defaults.DataDirPerm); err != nil {
+			log.G(ctx).WithError(err).Warn("failed to create debug socket directory")
+
+			return
+		}
+
+		_ = os.Remove(path)
+
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("failed to listen on debug socket %s", path)
+
+			return
+		}
+
+		server := grpc.NewServer()
+		pb.RegisterShimDebugServiceServer(server, &debugServer{shim: s})
+
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				log.G(ctx).WithError(err).Warn("debug server stopped")
+			}
+		}()
+
+		go func() {
+			<-s.shimCtx.Done()
+			server.Stop()
+			_ = os.Remove(path)
+		}()
+	})
+}
+
+// recordPorts remembers the vsock ports the IO of the process is proxied
+// over, a reattached process replaces its previous ports
+func (s *HyperShim) recordPorts(taskID, execID string, extraData *proto.ExtraData) {
+	s.portCountMutex.Lock()
+	defer s.portCountMutex.Unlock()
+
+	if _, exists := s.ports[taskID]; !exists {
+		s.ports[taskID] = make(map[string]*proto.ExtraData)
+	}
+
+	s.ports[taskID][execID] = extraData
+}
+
+func (d *debugServer) Inspect(_ context.Context, _ *pb.InspectRequest) (*pb.InspectResponse, error) {
+	s := d.shim
+
+	resp := &pb.InspectResponse{
+		ShimId:       s.id,
+		ShimPid:      int32(os.Getpid()),
+		Sandbox:      s.sandbox != nil,
+		AgentPort:    VSockPort,
+		RecentErrors: s.recentErrors.list(),
+		Failure:      s.vmFailure(),
+	}
+
+	bootAgent, bootStart := s.bootTimes()
+	resp.BootAgentMs = bootAgent.Milliseconds()
+	resp.BootStartMs = bootStart.Milliseconds()
+
+	if s.vmState != nil && s.vmState.agentProtocol != nil {
+		resp.AgentProtocolVersion = s.vmState.agentProtocol.version
+		resp.AgentVersion = s.vmState.agentProtocol.agentVersion
+	}
+
+	s.portCountMutex.Lock()
+	for taskID, execs := range s.ports {
+		for execID, extraData := range execs {
+			resp.Ports = append(resp.Ports, &pb.VsockPorts{
+				TaskId:     taskID,
+				ExecId:     execID,
+				StdinPort:  extraData.GetStdinPort(),
+				StdoutPort: extraData.GetStdoutPort(),
+				StderrPort: extraData.GetStderrPort(),
+			})
+		}
+	}
+	s.portCountMutex.Unlock()
+
+	s.fifosMutex.Lock()
+	for taskID, execs := range s.fifos {
+		for execID, config := range execs {
+			resp.Streams = append(resp.Streams, &pb.IOStream{
+				TaskId:   taskID,
+				ExecId:   execID,
+				Terminal: config.Terminal,
+				Stdin:    config.Stdin,
+				Stdout:   config.Stdout,
+				Stderr:   config.Stderr,
+			})
+		}
+	}
+	s.fifosMutex.Unlock()
+
+	// vmState is set once the VM started
+	vmState := s.vmState
+	if vmState == nil {
+		return resp, nil
+	}
+
+	spec := vmState.vm.Spec
+	resp.Vm = &pb.VMConfig{
+		Id:                vmState.vm.ID,
+		Provider:          spec.Provider,
+		Vcpu:              spec.VCPU,
+		MemoryMb:          spec.MemoryInMb,
+		Kernel:            spec.Kernel,
+		RootfsPath:        spec.RootfsPath,
+		ImagePath:         spec.ImagePath,
+		HostNetDev:        spec.HostNetDev,
+		GuestMac:          spec.GuestMAC,
+		Arch:              spec.Arch,
+		VsockPath:         vmState.vmSvc.VSockPath(vmState.vm),
+		ConsolePath:       vmState.vmSvc.ConsolePath(vmState.vm),
+		Restored:          s.restored,
+		ConsoleSocketPath: vmState.vmSvc.ConsoleSocketPath(vmState.vm),
+		Confidential:      spec.Confidential,
+	}
+
+	resp.Balloon = &pb.BalloonState{}
+
+	if fcSvc, ok := vmState.vmSvc.(*firecracker.Service); ok {
+		balloon, err := fcSvc.BalloonConfig(vmState.vm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read balloon config: %w", err)
+		}
+
+		if balloon != nil {
+			resp.Balloon = &pb.BalloonState{
+				Configured:            true,
+				AmountMib:             balloon.AmountMib,
+				DeflateOnOom:          balloon.DeflateOnOOM,
+				StatsPollingIntervalS: balloon.StatsPollingInterval,
+			}
+		}
+	}
+
+	return resp, nil
+}
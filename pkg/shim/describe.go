@@ -0,0 +1,111 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/ports"
+	pb "vistara-node/pkg/proto/shimdebug"
+	"vistara-node/pkg/resource"
+)
+
+// DescribeVM returns the configuration of the VM as reported by the API of
+// the hypervisor, along with the ways it differs from the spec of the VM
+func (d *debugServer) DescribeVM(ctx context.Context, _ *pb.DescribeVMRequest) (*pb.DescribeVMResponse, error) {
+	vmState := d.shim.vmState
+	if vmState == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no VM runs in this shim")
+	}
+
+	describer, ok := vmState.vmSvc.(ports.MicroVMDescriber)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "%s VMs can't be described", vmState.vm.Spec.Provider)
+	}
+
+	desc, err := describer.Describe(ctx, vmState.vm)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to describe the VM: %v", err)
+	}
+
+	resp := &pb.DescribeVMResponse{
+		Provider:    vmState.vm.Spec.Provider,
+		State:       desc.State,
+		Vcpu:        desc.VCPU,
+		MemoryMib:   desc.MemoryMiB,
+		BalloonMib:  desc.BalloonMiB,
+		Drift:       vmDrift(vmState.vm.Spec, desc, d.shim.restored),
+		ConfigJson:  string(desc.Config),
+		MetricsJson: string(desc.Metrics),
+	}
+
+	for _, device := range desc.Devices {
+		dev := &pb.VMDevice{
+			Kind:     device.Kind,
+			Id:       device.ID,
+			Path:     device.Path,
+			ReadOnly: device.ReadOnly,
+			GuestMac: device.GuestMAC,
+		}
+
+		for _, limit := range device.Limits {
+			dev.RateLimits = append(dev.RateLimits, &pb.RateLimit{
+				Direction:    limit.Direction,
+				Kind:         limit.Kind,
+				Size:         limit.Size,
+				OneTimeBurst: limit.OneTimeBurst,
+				RefillTimeMs: limit.RefillTimeMs,
+			})
+		}
+
+		resp.Devices = append(resp.Devices, dev)
+	}
+
+	return resp, nil
+}
+
+// vmDrift lists the differences between the spec and the description of
+// the VM. The drives of a VM restored from a warm pool snapshot are those
+// of the template it was restored from, so they are only compared for the
+// VMs booted from their spec
+func vmDrift(spec models.MicroVMSpec, desc *models.MicroVMDescription, restored bool) []string {
+	var drift []string
+
+	if desc.VCPU != int64(spec.VCPU) {
+		drift = append(drift, fmt.Sprintf("vcpu: requested %d, running with %d", spec.VCPU, desc.VCPU))
+	}
+
+	if running := resource.Bytes(desc.MemoryMiB) * resource.MiB; running != spec.Memory() {
+		drift = append(drift, fmt.Sprintf("memory: requested %s, running with %s", spec.Memory(), running))
+	}
+
+	if restored {
+		return drift
+	}
+
+	drives := make(map[string]models.DeviceDescription)
+
+	for _, device := range desc.Devices {
+		if device.Kind == models.DeviceKindDrive {
+			drives[device.Path] = device
+		}
+	}
+
+	expected := append([]models.Disk{{Path: spec.RootfsPath, ReadOnly: true}}, spec.Disks...)
+
+	for _, disk := range expected {
+		drive, ok := drives[disk.Path]
+
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("drive %s: missing", disk.Path))
+		case drive.ReadOnly != disk.ReadOnly:
+			drift = append(drift, fmt.Sprintf("drive %s: requested read-only %t, attached with %t", disk.Path, disk.ReadOnly, drive.ReadOnly))
+		}
+	}
+
+	return drift
+}
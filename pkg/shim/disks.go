@@ -0,0 +1,30 @@
+package shim
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"vistara-node/pkg/models"
+)
+
+// mountDisks mounts the disks of the VM that have a mount path into the
+// workload. The OCI spec is handed to the agent, which mounts the guest
+// devices of the disks like the other mounts of the container
+func mountDisks(ociSpec *specs.Spec, disks []models.Disk) {
+	for i, disk := range disks {
+		if disk.MountPath == "" {
+			continue
+		}
+
+		options := []string{"rw"}
+		if disk.ReadOnly {
+			options = []string{"ro"}
+		}
+
+		ociSpec.Mounts = append(ociSpec.Mounts, specs.Mount{
+			Destination: disk.MountPath,
+			Type:        "ext4",
+			Source:      models.DiskDevice(i),
+			Options:     options,
+		})
+	}
+}
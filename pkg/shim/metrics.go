@@ -0,0 +1,150 @@
+package shim
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containerd/log"
+
+	"vistara-node/pkg/defaults"
+	"vistara-node/pkg/pool"
+	"vistara-node/pkg/pushmetrics"
+)
+
+// shimMetrics are the metrics of the task of the shim pushed along with
+// the ones of the agent, if the agent pushes them
+type shimMetrics struct {
+	once sync.Once
+
+	mu           sync.Mutex
+	createPhases map[string]time.Duration
+	createTotal  time.Duration
+	// Boot of the VM, from the entry of Create to the agent handshake
+	// and to the first start of the task
+	createStart time.Time
+	bootAgent   time.Duration
+	bootStart   time.Duration
+	failures    map[string]uint64
+	crashes     uint64
+}
+
+// startMetricsPush starts pushing the metrics of the shim if the agent
+// shared a push config
+func (s *HyperShim) startMetricsPush(ctx context.Context) {
+	s.metrics.once.Do(func() {
+		cfg, err := pushmetrics.ReadConfig(defaults.MetricsPushConfigFile)
+		if err != nil {
+			log.G(ctx).WithError(err).Warn("failed to read metrics push config")
+
+			return
+		} else if cfg == nil {
+			return
+		}
+
+		pusher := pushmetrics.NewPusher(log.G(s.shimCtx), cfg, s.metricSamples)
+		go pusher.Run(s.shimCtx)
+	})
+}
+
+func (s *HyperShim) recordCreate(timer *phaseTimer, agentReady time.Duration) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	s.metrics.createPhases = timer.phases
+	s.metrics.createTotal = timer.total()
+	s.metrics.createStart = timer.start
+	s.metrics.bootAgent = agentReady
+}
+
+// recordStart records the time from the creation of the task to its first
+// start, returning it if the process started is that first start
+func (s *HyperShim) recordStart(execID string) (time.Duration, bool) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	if execID != "" || s.metrics.createStart.IsZero() || s.metrics.bootStart > 0 {
+		return 0, false
+	}
+
+	s.metrics.bootStart = time.Since(s.metrics.createStart)
+
+	return s.metrics.bootStart, true
+}
+
+// bootTimes returns the time the VM took to reach the agent handshake and
+// the first start of its task, zero for the stages not reached yet
+func (s *HyperShim) bootTimes() (time.Duration, time.Duration) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	return s.metrics.bootAgent, s.metrics.bootStart
+}
+
+func (s *HyperShim) recordFailure(reason string) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	if s.metrics.failures == nil {
+		s.metrics.failures = make(map[string]uint64)
+	}
+
+	s.metrics.failures[reason]++
+}
+
+func (s *HyperShim) recordCrash() {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	s.metrics.crashes++
+}
+
+func (s *HyperShim) metricSamples() []pushmetrics.Sample {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	now := time.Now()
+	task := map[string]string{"task": s.taskID}
+
+	samples := []pushmetrics.Sample{
+		{Name: "hypercore_shim_vm_crashes_total", Labels: task, Value: float64(s.metrics.crashes), Counter: true, Time: now},
+	}
+
+	if s.metrics.createTotal > 0 {
+		samples = append(samples, pushmetrics.Sample{
+			Name: "hypercore_shim_create_seconds", Labels: task, Value: s.metrics.createTotal.Seconds(), Time: now,
+		})
+	}
+
+	for stage, elapsed := range map[string]time.Duration{pool.BootStageAgent: s.metrics.bootAgent, pool.BootStageStart: s.metrics.bootStart} {
+		if elapsed > 0 {
+			samples = append(samples, pushmetrics.Sample{
+				Name:   "hypercore_shim_boot_seconds",
+				Labels: map[string]string{"task": s.taskID, "stage": stage},
+				Value:  elapsed.Seconds(),
+				Time:   now,
+			})
+		}
+	}
+
+	for phase, elapsed := range s.metrics.createPhases {
+		samples = append(samples, pushmetrics.Sample{
+			Name:   "hypercore_shim_create_phase_seconds",
+			Labels: map[string]string{"task": s.taskID, "phase": phase},
+			Value:  elapsed.Seconds(),
+			Time:   now,
+		})
+	}
+
+	for reason, count := range s.metrics.failures {
+		samples = append(samples, pushmetrics.Sample{
+			Name:    "hypercore_shim_vm_failures_total",
+			Labels:  map[string]string{"task": s.taskID, "reason": reason},
+			Value:   float64(count),
+			Counter: true,
+			Time:    now,
+		})
+	}
+
+	return samples
+}
@@ -0,0 +1,198 @@
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/containerd/containerd/protobuf/types"
+	"github.com/containerd/log"
+	"github.com/containerd/typeurl/v2"
+	"google.golang.org/protobuf/proto"
+
+	"vistara-node/pkg/hypervisor/cloudhypervisor"
+	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/proto/vmoptions"
+)
+
+// Bounds of the resources of a VM
+const (
+	maxVCPU       = 64
+	maxMemoryInMb = 32768
+)
+
+// parseOpts decodes the runtime options hypercore passes, typed
+// vmoptions.MicroVMOptions or the JSON encoded models.MicroVMSpec of older
+// releases, accepted until it is removed
+func parseOpts(ctx context.Context, options *types.Any) (models.MicroVMSpec, error) {
+	switch {
+	case typeurl.Is(options, &vmoptions.MicroVMOptions{}):
+		var vmOptions vmoptions.MicroVMOptions
+		if err := typeurl.UnmarshalTo(options, &vmOptions); err != nil {
+			return models.MicroVMSpec{}, fmt.Errorf("failed to unmarshal runtime options: %w", err)
+		}
+
+		return models.MicroVMSpecFromOptions(&vmOptions), nil
+	case options.GetTypeUrl() == models.LegacyMicroVMSpecTypeURL:
+		log.G(ctx).Warnf("runtime options of type %s are deprecated, pass %s instead", options.GetTypeUrl(), proto.MessageName(&vmoptions.MicroVMOptions{}))
+
+		var spec models.MicroVMSpec
+		if err := json.Unmarshal(options.GetValue(), &spec); err != nil {
+			return spec, fmt.Errorf("failed to parse JSON runtime options: %w", err)
+		}
+
+		return spec, nil
+	default:
+		return models.MicroVMSpec{}, fmt.Errorf("unsupported runtime options of type %q, expected %s", options.GetTypeUrl(), proto.MessageName(&vmoptions.MicroVMOptions{}))
+	}
+}
+
+// validateSpec checks the resolved spec of a VM, reporting all the invalid
+// fields at once by their runtime options name
+func validateSpec(spec models.MicroVMSpec) error {
+	var problems []string
+
+	invalid := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch spec.Provider {
+	case firecracker.HypervisorName, cloudhypervisor.HypervisorName:
+	default:
+		invalid("provider", "unsupported provider %q, expected %s or %s", spec.Provider, firecracker.HypervisorName, cloudhypervisor.HypervisorName)
+	}
+
+	if spec.VCPU < 1 || spec.VCPU > maxVCPU {
+		invalid("vcpu", "%d is not between 1 and %d", spec.VCPU, maxVCPU)
+	}
+
+	if spec.MemoryInMb < 1 || spec.MemoryInMb > maxMemoryInMb {
+		invalid("memory_mb", "%d is not between 1 and %d", spec.MemoryInMb, maxMemoryInMb)
+	}
+
+	switch spec.Arch {
+	case "", "x86_64", "aarch64":
+	default:
+		invalid("arch", "unsupported architecture %q, expected x86_64 or aarch64", spec.Arch)
+	}
+
+	if spec.GuestMAC != "" {
+		if _, err := net.ParseMAC(spec.GuestMAC); err != nil {
+			invalid("guest_mac", "%v", err)
+		}
+	}
+
+	for i, rule := range spec.IngressRules {
+		for _, cidr := range rule.SourceCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				invalid(fmt.Sprintf("ingress_rules[%d].source_cidrs", i), "%v", err)
+			}
+		}
+
+		for _, port := range rule.Ports {
+			if port == 0 || port > 0xffff {
+				invalid(fmt.Sprintf("ingress_rules[%d].ports", i), "invalid port %d", port)
+			}
+		}
+	}
+
+	if len(spec.Disks) > models.MaxDisks {
+		invalid("disks", "%d disks, at most %d are supported", len(spec.Disks), models.MaxDisks)
+	}
+
+	mountPaths := make(map[string]struct{})
+
+	for i, disk := range spec.Disks {
+		if disk.Path == "" {
+			invalid(fmt.Sprintf("disks[%d].path", i), "required")
+		}
+
+		switch disk.CacheMode {
+		case "", models.DiskCacheUnsafe, models.DiskCacheWriteBack:
+		default:
+			invalid(fmt.Sprintf("disks[%d].cache_mode", i), "unsupported cache mode %q, expected %s or %s", disk.CacheMode, models.DiskCacheUnsafe, models.DiskCacheWriteBack)
+		}
+
+		switch disk.Format {
+		case "", models.DiskFormatRaw:
+		case models.DiskFormatQcow2:
+			if spec.Provider != cloudhypervisor.HypervisorName {
+				invalid(fmt.Sprintf("disks[%d].format", i), "%s images are only supported by %s", disk.Format, cloudhypervisor.HypervisorName)
+			}
+		default:
+			invalid(fmt.Sprintf("disks[%d].format", i), "unsupported format %q, expected %s or %s", disk.Format, models.DiskFormatRaw, models.DiskFormatQcow2)
+		}
+
+		if disk.MountPath == "" {
+			continue
+		}
+
+		if !path.IsAbs(disk.MountPath) {
+			invalid(fmt.Sprintf("disks[%d].mount_path", i), "%q is not absolute", disk.MountPath)
+		}
+
+		if _, ok := mountPaths[path.Clean(disk.MountPath)]; ok {
+			invalid(fmt.Sprintf("disks[%d].mount_path", i), "%q is the mount path of another disk", disk.MountPath)
+		}
+
+		mountPaths[path.Clean(disk.MountPath)] = struct{}{}
+	}
+
+	if (spec.CPUTemplate != "" || len(spec.CPUIDModifiers) > 0) && spec.Provider != firecracker.HypervisorName {
+		invalid("cpu_template", "CPU templates and CPUID modifiers are only supported by %s", firecracker.HypervisorName)
+	}
+
+	if spec.CPUTemplate != "" && len(spec.CPUIDModifiers) > 0 {
+		invalid("cpuid_modifiers", "exclusive with cpu_template")
+	}
+
+	switch spec.Confidential {
+	case "":
+	case models.ConfidentialSEVSNP, models.ConfidentialTDX:
+		if spec.Provider != cloudhypervisor.HypervisorName {
+			invalid("confidential", "confidential VMs are only supported by %s", cloudhypervisor.HypervisorName)
+		}
+
+		if spec.Firmware == "" {
+			invalid("firmware", "required for confidential VMs")
+		}
+	default:
+		invalid("confidential", "unsupported technology %q, expected %s or %s", spec.Confidential, models.ConfidentialSEVSNP, models.ConfidentialTDX)
+	}
+
+	if spec.NestedVirt && spec.Provider != cloudhypervisor.HypervisorName {
+		invalid("nested_virt", "nested virtualization is only supported by %s", cloudhypervisor.HypervisorName)
+	}
+
+	if spec.DNS != nil {
+		if err := spec.DNS.Validate(); err != nil {
+			invalid("dns", "%v", err)
+		}
+	}
+
+	if err := models.ValidateHostEntries(spec.ExtraHosts); err != nil {
+		invalid("extra_hosts", "%v", err)
+	}
+
+	for i, modifier := range spec.CPUIDModifiers {
+		switch modifier.Register {
+		case "eax", "ebx", "ecx", "edx":
+		default:
+			invalid(fmt.Sprintf("cpuid_modifiers[%d].register", i), "unknown register %q, expected eax, ebx, ecx or edx", modifier.Register)
+		}
+
+		if len(modifier.Bitmap) != 32 || strings.Trim(modifier.Bitmap, "01x") != "" {
+			invalid(fmt.Sprintf("cpuid_modifiers[%d].bitmap", i), "%q is not 32 characters among 0, 1 and x", modifier.Bitmap)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid runtime options: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
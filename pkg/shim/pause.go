@@ -0,0 +1,118 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+
+	apievents "github.com/containerd/containerd/api/events"
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/log"
+
+	"vistara-node/pkg/ports"
+)
+
+// errVMPaused is returned for the requests the agent can't serve while the
+// VM is paused
+var errVMPaused = fmt.Errorf("%w: the VM is paused", errdefs.ErrFailedPrecondition)
+
+// pausedState returns the state of a process while the VM is paused, ok
+// is false if it isn't
+func (s *HyperShim) pausedState(req *taskAPI.StateRequest) (_ *taskAPI.StateResponse, ok bool, _ error) {
+	states := s.pausedStates.Load()
+	if states == nil {
+		return nil, false, nil
+	}
+
+	state, ok := (*states)[req.GetExecID()]
+	if !ok {
+		return nil, true, errVMPaused
+	}
+
+	return state, true, nil
+}
+
+func (s *HyperShim) checkNotPaused() error {
+	if s.pausedStates.Load() != nil {
+		return errVMPaused
+	}
+
+	return nil
+}
+
+// pauseVM pauses the whole VM through the hypervisor instead of freezing
+// the processes of the task in the guest. The agent can't answer until the
+// VM is resumed, so the states of the processes are taken beforehand
+func (s *HyperShim) pauseVM(ctx context.Context, taskID string) error {
+	pauser, ok := s.vmState.vmSvc.(ports.MicroVMPauseService)
+	if !ok {
+		return fmt.Errorf("%w: pausing %s VMs", errdefs.ErrNotImplemented, s.vmState.vm.Spec.Provider)
+	}
+
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	if s.pausedStates.Load() != nil {
+		return fmt.Errorf("%w: the VM is already paused", errdefs.ErrFailedPrecondition)
+	}
+
+	s.fifosMutex.Lock()
+	execIDs := make([]string, 0, len(s.fifos[taskID]))
+	for execID := range s.fifos[taskID] {
+		execIDs = append(execIDs, execID)
+	}
+	s.fifosMutex.Unlock()
+
+	states := make(map[string]*taskAPI.StateResponse, len(execIDs))
+
+	for _, execID := range execIDs {
+		state, err := s.State(ctx, &taskAPI.StateRequest{ID: taskID, ExecID: execID})
+		if err != nil {
+			return err
+		}
+
+		if state.GetStatus() == task.Status_RUNNING {
+			state.Status = task.Status_PAUSED
+		}
+
+		states[execID] = state
+	}
+
+	if err := pauser.Pause(ctx, s.vmState.vm); err != nil {
+		return err
+	}
+
+	s.pausedStates.Store(&states)
+
+	if err := s.remotePublisher.Publish(ctx, runtime.TaskPausedEventTopic, &apievents.TaskPaused{ContainerID: taskID}); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to publish task paused event")
+	}
+
+	return nil
+}
+
+func (s *HyperShim) resumeVM(ctx context.Context, taskID string) error {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	if s.pausedStates.Load() == nil {
+		return fmt.Errorf("%w: the VM is not paused", errdefs.ErrFailedPrecondition)
+	}
+
+	//nolint:forcetypeassert // only paused by pauseVM
+	pauser := s.vmState.vmSvc.(ports.MicroVMPauseService)
+
+	if err := pauser.Resume(ctx, s.vmState.vm); err != nil {
+		return err
+	}
+
+	s.pausedStates.Store(nil)
+
+	if err := s.remotePublisher.Publish(ctx, runtime.TaskResumedEventTopic, &apievents.TaskResumed{ContainerID: taskID}); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to publish task resumed event")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package shim
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "vistara-node/pkg/proto/shimdebug"
+)
+
+const (
+	defaultProfileDuration = 30 * time.Second
+	maxProfileDuration     = 10 * time.Minute
+)
+
+// cpuProfileMu serializes the CPU profiles, the runtime only runs one at
+// a time
+var cpuProfileMu sync.Mutex
+
+// Profile returns a profile of the shim, sampling the CPU for the requested
+// duration or snapshotting one of the profiles of runtime/pprof
+func (d *debugServer) Profile(ctx context.Context, req *pb.ProfileRequest) (*pb.ProfileResponse, error) {
+	var buf bytes.Buffer
+
+	if req.GetKind() != "cpu" {
+		profile := pprof.Lookup(req.GetKind())
+		if profile == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown profile %q", req.GetKind())
+		}
+
+		if err := profile.WriteTo(&buf, 0); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		return &pb.ProfileResponse{Profile: buf.Bytes()}, nil
+	}
+
+	duration := time.Duration(req.GetDurationSeconds()) * time.Second
+	if duration == 0 {
+		duration = defaultProfileDuration
+	}
+
+	if duration < 0 || duration > maxProfileDuration {
+		return nil, status.Errorf(codes.InvalidArgument, "profile duration must be at most %s", maxProfileDuration)
+	}
+
+	if !cpuProfileMu.TryLock() {
+		return nil, status.Error(codes.FailedPrecondition, "a CPU profile is already running")
+	}
+	defer cpuProfileMu.Unlock()
+
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+	}
+
+	pprof.StopCPUProfile()
+
+	return &pb.ProfileResponse{Profile: buf.Bytes()}, nil
+}
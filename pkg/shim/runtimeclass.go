@@ -0,0 +1,216 @@
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	criannotations "github.com/containerd/containerd/pkg/cri/annotations"
+	runtimeoptions "github.com/containerd/containerd/pkg/runtimeoptions/v1"
+	"github.com/containerd/containerd/protobuf/types"
+	"github.com/containerd/typeurl/v2"
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"vistara-node/pkg/hypervisor/firecracker"
+	"vistara-node/pkg/models"
+	"vistara-node/pkg/resource"
+)
+
+// Pod annotations understood when the shim is used through a Kubernetes
+// RuntimeClass, they take precedence over the runtime options
+const (
+	AnnotationProvider   = "hypercore.io/provider"
+	AnnotationKernel     = "hypercore.io/kernel"
+	AnnotationRootfs     = "hypercore.io/rootfs"
+	AnnotationVCPU       = "hypercore.io/vcpu"
+	AnnotationMemory     = "hypercore.io/memory"
+	AnnotationHostNetDev = "hypercore.io/host-net-dev"
+	AnnotationArch       = "hypercore.io/arch"
+	// "true" leaves out the virtio-rng device of the VM
+	AnnotationDisableEntropy = "hypercore.io/disable-entropy"
+	// "true" pauses the whole VM when the task is paused
+	AnnotationPauseVM = "hypercore.io/pause-vm"
+	// Static CPU template of firecracker, e.g. T2S
+	AnnotationCPUTemplate = "hypercore.io/cpu-template"
+	// sev-snp or tdx, along with the firmware path on the node
+	AnnotationConfidential = "hypercore.io/confidential"
+	AnnotationFirmware     = "hypercore.io/firmware"
+	// "true" to expose the virtualization extensions of the host CPU
+	AnnotationNestedVirt = "hypercore.io/nested-virt"
+)
+
+const (
+	defaultVCPU   = 1
+	minMemoryInMb = 1024
+)
+
+// isSandbox returns whether the OCI spec is the one of a CRI pod sandbox
+// (pause container) rather than a workload container
+func isSandbox(ociSpec *specs.Spec) bool {
+	return ociSpec.Annotations[criannotations.ContainerType] == criannotations.ContainerTypeSandbox
+}
+
+// resolveSpec builds the VM spec of a container. hypercore passes it as the
+// runtime options, while the CRI plugin passes runtimeoptions.Options whose
+// ConfigPath points to a JSON encoded models.MicroVMSpec with the node
+// defaults. Pod annotations and the container resources are applied on top
+func resolveSpec(ctx context.Context, options *types.Any, ociSpec *specs.Spec) (models.MicroVMSpec, error) {
+	var spec models.MicroVMSpec
+
+	switch {
+	case options == nil:
+	case typeurl.Is(options, &runtimeoptions.Options{}):
+		var criOpts runtimeoptions.Options
+		if err := typeurl.UnmarshalTo(options, &criOpts); err != nil {
+			return spec, fmt.Errorf("failed to unmarshal CRI runtime options: %w", err)
+		}
+
+		if criOpts.GetConfigPath() != "" {
+			data, err := os.ReadFile(criOpts.GetConfigPath())
+			if err != nil {
+				return spec, fmt.Errorf("failed to read runtime config %s: %w", criOpts.GetConfigPath(), err)
+			}
+
+			if err := json.Unmarshal(data, &spec); err != nil {
+				return spec, fmt.Errorf("failed to parse runtime config %s: %w", criOpts.GetConfigPath(), err)
+			}
+		}
+	default:
+		var err error
+		if spec, err = parseOpts(ctx, options); err != nil {
+			return spec, err
+		}
+	}
+
+	if err := applyAnnotations(&spec, ociSpec.Annotations); err != nil {
+		return spec, err
+	}
+
+	applyResources(&spec, ociSpec.Linux)
+
+	if spec.Provider == "" {
+		spec.Provider = firecracker.HypervisorName
+	}
+
+	return spec, validateSpec(spec)
+}
+
+func applyAnnotations(spec *models.MicroVMSpec, annotations map[string]string) error {
+	if value, ok := annotations[AnnotationProvider]; ok {
+		spec.Provider = value
+	}
+
+	if value, ok := annotations[AnnotationKernel]; ok {
+		spec.Kernel = value
+	}
+
+	if value, ok := annotations[AnnotationRootfs]; ok {
+		spec.RootfsPath = value
+	}
+
+	if value, ok := annotations[AnnotationHostNetDev]; ok {
+		spec.HostNetDev = value
+	}
+
+	if value, ok := annotations[AnnotationArch]; ok {
+		spec.Arch = value
+	}
+
+	if value, ok := annotations[AnnotationCPUTemplate]; ok {
+		spec.CPUTemplate = value
+	}
+
+	if value, ok := annotations[AnnotationConfidential]; ok {
+		spec.Confidential = value
+	}
+
+	if value, ok := annotations[AnnotationFirmware]; ok {
+		spec.Firmware = value
+	}
+
+	if value, ok := annotations[AnnotationDisableEntropy]; ok {
+		disable, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %w", AnnotationDisableEntropy, value, err)
+		}
+
+		spec.DisableEntropy = disable
+	}
+
+	if value, ok := annotations[AnnotationPauseVM]; ok {
+		pauseVM, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %w", AnnotationPauseVM, value, err)
+		}
+
+		spec.PauseVM = pauseVM
+	}
+
+	if value, ok := annotations[AnnotationNestedVirt]; ok {
+		nestedVirt, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %w", AnnotationNestedVirt, value, err)
+		}
+
+		spec.NestedVirt = nestedVirt
+	}
+
+	if value, ok := annotations[AnnotationVCPU]; ok {
+		// Fractions of a vCPU such as 1500m are rounded up
+		vcpu, err := resource.ParseCPU(value)
+		if err == nil && vcpu.Cores() > math.MaxInt32 {
+			err = fmt.Errorf("too many vCPUs")
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %w", AnnotationVCPU, value, err)
+		}
+
+		spec.VCPU = int32(vcpu.Cores())
+	}
+
+	if value, ok := annotations[AnnotationMemory]; ok {
+		// Plain numbers are in MB, as before sizes with units were accepted
+		memory, err := resource.ParseMemory(value, resource.MiB)
+		if err == nil && memory.MiB() > math.MaxInt32 {
+			err = fmt.Errorf("too much memory")
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %w", AnnotationMemory, value, err)
+		}
+
+		spec.MemoryInMb = int32(memory.MiB())
+	}
+
+	return nil
+}
+
+// applyResources sizes the VM from the container limits set by the kubelet
+// when neither the options nor the annotations did
+func applyResources(spec *models.MicroVMSpec, linux *specs.Linux) {
+	var resources specs.LinuxResources
+	if linux != nil && linux.Resources != nil {
+		resources = *linux.Resources
+	}
+
+	if spec.VCPU == 0 {
+		spec.VCPU = defaultVCPU
+
+		if cpu := resources.CPU; cpu != nil && cpu.Quota != nil && cpu.Period != nil && *cpu.Quota > 0 && *cpu.Period > 0 {
+			// Round up, a VM can't have a fraction of a vCPU
+			spec.VCPU = int32((uint64(*cpu.Quota) + *cpu.Period - 1) / *cpu.Period)
+		}
+	}
+
+	if spec.MemoryInMb == 0 {
+		spec.MemoryInMb = minMemoryInMb
+
+		if memory := resources.Memory; memory != nil && memory.Limit != nil && *memory.Limit > 0 {
+			spec.MemoryInMb = max(int32(resource.Bytes(*memory.Limit).MiB()), minMemoryInMb)
+		}
+	}
+}
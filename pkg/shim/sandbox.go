@@ -0,0 +1,151 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/protobuf"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/log"
+)
+
+var errSandboxUnsupported = fmt.Errorf("%w: not supported by the pod sandbox", errdefs.ErrNotImplemented)
+
+// sandboxTask stands in for the pause container of a CRI pod. The pod
+// network namespace is created and pinned by the CRI plugin, so there is no
+// need to boot a VM for it: the workload containers each get their own VM
+// attached to that namespace. The task lives as long as the shim and exits
+// when killed
+type sandboxTask struct {
+	id     string
+	bundle string
+	pid    uint32
+
+	mu         sync.Mutex
+	status     task.Status
+	exitStatus uint32
+	exitedAt   time.Time
+	exited     chan struct{}
+}
+
+func newSandboxTask(req *taskAPI.CreateTaskRequest) *sandboxTask {
+	return &sandboxTask{
+		id:     req.GetID(),
+		bundle: req.GetBundle(),
+		pid:    uint32(os.Getpid()),
+		status: task.Status_CREATED,
+		exited: make(chan struct{}),
+	}
+}
+
+func (t *sandboxTask) state() *taskAPI.StateResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resp := &taskAPI.StateResponse{
+		ID:         t.id,
+		Bundle:     t.bundle,
+		Pid:        t.pid,
+		Status:     t.status,
+		ExitStatus: t.exitStatus,
+	}
+
+	if t.status == task.Status_STOPPED {
+		resp.ExitedAt = protobuf.ToTimestamp(t.exitedAt)
+	}
+
+	return resp
+}
+
+func (t *sandboxTask) start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != task.Status_CREATED {
+		return fmt.Errorf("sandbox %s is %s", t.id, t.status)
+	}
+
+	t.status = task.Status_RUNNING
+
+	return nil
+}
+
+// kill stops the sandbox, it returns false if it had already exited
+func (t *sandboxTask) kill(signal uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status == task.Status_STOPPED {
+		return false
+	}
+
+	t.status = task.Status_STOPPED
+	t.exitStatus = 128 + signal
+	t.exitedAt = time.Now()
+	close(t.exited)
+
+	return true
+}
+
+func (s *HyperShim) createSandbox(ctx context.Context, req *taskAPI.CreateTaskRequest) (*taskAPI.CreateTaskResponse, error) {
+	if s.sandbox != nil || s.vmState != nil {
+		return nil, errors.New("create called multiple times")
+	}
+
+	log.G(ctx).Infof("creating sandbox %s without a VM", req.GetID())
+
+	s.sandbox = newSandboxTask(req)
+
+	return &taskAPI.CreateTaskResponse{Pid: s.sandbox.pid}, nil
+}
+
+func (s *HyperShim) killSandbox(ctx context.Context, req *taskAPI.KillRequest) {
+	if !s.sandbox.kill(req.GetSignal()) {
+		return
+	}
+
+	state := s.sandbox.state()
+
+	if err := s.remotePublisher.Publish(ctx, runtime.TaskExitEventTopic, &apievents.TaskExit{
+		ContainerID: state.GetID(),
+		ID:          state.GetID(),
+		Pid:         state.GetPid(),
+		ExitStatus:  state.GetExitStatus(),
+		ExitedAt:    state.GetExitedAt(),
+	}); err != nil {
+		log.G(ctx).WithError(err).Error("failed to publish sandbox exit event")
+	}
+}
+
+func (s *HyperShim) waitSandbox(ctx context.Context) (*taskAPI.WaitResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.sandbox.exited:
+	}
+
+	state := s.sandbox.state()
+
+	return &taskAPI.WaitResponse{ExitStatus: state.GetExitStatus(), ExitedAt: state.GetExitedAt()}, nil
+}
+
+func (s *HyperShim) deleteSandbox(ctx context.Context, req *taskAPI.DeleteRequest) *taskAPI.DeleteResponse {
+	s.killSandbox(ctx, &taskAPI.KillRequest{ID: req.GetID(), Signal: uint32(syscall.SIGKILL)})
+
+	state := s.sandbox.state()
+
+	return &taskAPI.DeleteResponse{
+		Pid:        state.GetPid(),
+		ExitStatus: state.GetExitStatus(),
+		ExitedAt:   state.GetExitedAt(),
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -15,6 +16,7 @@ import (
 	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
 	"github.com/containerd/containerd/api/types/task"
 	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/events/exchange"
 	"github.com/containerd/containerd/oci"
 	"github.com/containerd/containerd/protobuf"
@@ -24,7 +26,6 @@ import (
 	"github.com/containerd/ttrpc"
 	"github.com/containerd/typeurl/v2"
 	"github.com/containernetworking/plugins/pkg/ns"
-	"github.com/firecracker-microvm/firecracker-go-sdk/vsock"
 	"github.com/google/uuid"
 	"github.com/spf13/afero"
 	"github.com/vistara-labs/firecracker-containerd/proto"
@@ -32,27 +33,39 @@ import (
 	"github.com/vistara-labs/firecracker-containerd/utils"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 
+	"vistara-node/pkg/cgroup"
 	"vistara-node/pkg/defaults"
 	"vistara-node/pkg/hypervisor/cloudhypervisor"
 	"vistara-node/pkg/hypervisor/firecracker"
 	"vistara-node/pkg/models"
+	"vistara-node/pkg/network"
+	"vistara-node/pkg/pool"
 	"vistara-node/pkg/ports"
+	"vistara-node/pkg/proto/shimdebug"
+	"vistara-node/pkg/stdio"
 )
 
 const ShimID = "hypercore.example"
 const VSockPort = 10789
 
+// Exit status of the tasks of a VM whose guest kernel panicked
+const panicExitStatus = 128 + uint32(unix.SIGKILL)
+
 type HypervisorState struct {
 	fsSvc         afero.Fs
 	vmSvc         ports.MicroVMService
 	vm            *models.MicroVM
 	agentClient   taskAPI.TaskService
 	ioProxyClient ioproxy.IOProxyService
+	agentProtocol *agentProtocol
 	vmStopped     chan struct{}
+	// cgroup limiting the hypervisor, nil if it couldn't be created
+	cgroup *cgroup.Group
 }
 
 type HyperShim struct {
 	id        string
+	taskID    string
 	stateRoot string
 	//nolint:containedctx
 	shimCtx         context.Context
@@ -60,18 +73,25 @@ type HyperShim struct {
 	eventExchange   *exchange.Exchange
 	taskManager     utils.TaskManager
 	vmState         *HypervisorState
+	sandbox         *sandboxTask
 	fifos           map[string]map[string]cio.Config
 	fifosMutex      sync.Mutex
 	portCountMutex  sync.Mutex
 	portCount       uint32
+	ports           map[string]map[string]*proto.ExtraData
+	ioProxiesMu     sync.Mutex
+	ioProxies       map[string]map[string]*stdio.Proxy
+	restored        bool
+	recentErrors    *recentErrors
+	debugOnce       sync.Once
+	stopping        atomic.Bool
+	failureMu       sync.Mutex
+	failure         *shimdebug.VMCrashed
+	metrics         shimMetrics
 	shimCancel      func()
-}
-
-func parseOpts(options *types.Any) (models.MicroVMSpec, error) {
-	var metadata models.MicroVMSpec
-	err := json.Unmarshal(options.GetValue(), &metadata)
-
-	return metadata, err
+	pauseMu         sync.Mutex
+	// states of the processes while the VM is paused, by exec ID
+	pausedStates atomic.Pointer[map[string]*taskAPI.StateResponse]
 }
 
 func generateExtraData(baseVSockPort uint32, jsonBytes []byte, options *types.Any) *proto.ExtraData {
@@ -155,6 +175,25 @@ func (s *HyperShim) addFIFOs(taskID string, execID string, config cio.Config) er
 }
 
 func (s *HyperShim) State(ctx context.Context, req *taskAPI.StateRequest) (*taskAPI.StateResponse, error) {
+	if s.sandbox != nil {
+		return s.sandbox.state(), nil
+	}
+
+	// The agent died with the guest kernel
+	if failure := s.vmFailure(); failure.GetReason() == shimdebug.VMFailureReason_KERNEL_PANIC {
+		return &taskAPI.StateResponse{
+			ID:         req.GetID(),
+			ExecID:     req.GetExecID(),
+			Status:     task.Status_STOPPED,
+			ExitStatus: panicExitStatus,
+			ExitedAt:   protobuf.ToTimestamp(time.Unix(failure.GetTimestamp(), 0)),
+		}, nil
+	}
+
+	if resp, paused, err := s.pausedState(req); paused {
+		return resp, err
+	}
+
 	resp, err := s.vmState.agentClient.State(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("request to agent failed: %w", err)
@@ -186,6 +225,8 @@ func (s *HyperShim) State(ctx context.Context, req *taskAPI.StateRequest) (*task
 	}
 
 	extraData := generateExtraData(s.getAndIncrementPortCount(), nil, nil)
+	s.recordPorts(req.GetID(), req.GetExecID(), extraData)
+
 	attach := ioproxy.AttachRequest{
 		ID:         req.GetID(),
 		ExecID:     req.GetExecID(),
@@ -199,15 +240,24 @@ func (s *HyperShim) State(ctx context.Context, req *taskAPI.StateRequest) (*task
 		return nil, fmt.Errorf("failed to attach IO Proxy: %w", err)
 	}
 
-	ioConnectorSet, err := utils.NewIOProxy(log.G(ctx), host.Stdin, host.Stdout, host.Stderr, s.vmState.vmSvc.VSockPath(s.vmState.vm), extraData)
+	proxy, err := s.proxyIO(ctx, req.GetID(), req.GetExecID(), host.Stdin, host.Stdout, host.Stderr, extraData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create IO Proxy: %w", err)
+		return nil, err
 	}
 
-	if err := s.taskManager.AttachIO(ctx, req.GetID(), req.GetExecID(), ioConnectorSet); err != nil {
+	if err := s.taskManager.AttachIO(ctx, req.GetID(), req.GetExecID(), noIOProxy); err != nil {
 		return nil, fmt.Errorf("failed to attach IO Proxy: %w", err)
 	}
 
+	if proxy != nil {
+		go func() {
+			if err := proxy.Ready(); err != nil {
+				log.G(s.shimCtx).WithError(err).Error("failed to initialize an io proxy")
+				proxy.Close(0)
+			}
+		}()
+	}
+
 	return resp, nil
 }
 
@@ -216,16 +266,34 @@ func (s *HyperShim) vmCompletion(waitErr error) {
 		log.G(s.shimCtx).WithError(waitErr).Error("failed to wait for process")
 	}
 
+	if !s.stopping.Load() {
+		s.reportCrash(s.shimCtx, waitErr)
+	}
+
+	if s.vmState.cgroup != nil {
+		if err := s.vmState.cgroup.Delete(); err != nil {
+			log.G(s.shimCtx).WithError(err).Warn("failed to delete the cgroup of the hypervisor")
+		}
+	}
+
 	close(s.vmState.vmStopped)
 	s.shimCancel()
 }
 
 func (s *HyperShim) Create(ctx context.Context, req *taskAPI.CreateTaskRequest) (_ *taskAPI.CreateTaskResponse, retErr error) {
+	timer := newPhaseTimer()
+
+	s.startDebugServer(ctx)
+
 	ociSpec, err := oci.ReadSpec(req.GetBundle() + "/config.json")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read spec at %s", req.GetBundle())
 	}
 
+	if isSandbox(ociSpec) {
+		return s.createSandbox(ctx, req)
+	}
+
 	networkNs := ""
 	filteredNs := make([]specs.LinuxNamespace, 0)
 	for _, ns := range ociSpec.Linux.Namespaces {
@@ -255,9 +323,9 @@ func (s *HyperShim) Create(ctx context.Context, req *taskAPI.CreateTaskRequest)
 		return nil, fmt.Errorf("got non-ext4 rootfs: %s", rootfs.GetType())
 	}
 
-	spec, err := parseOpts(req.GetOptions())
+	spec, err := resolveSpec(ctx, req.GetOptions(), ociSpec)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse options: %w", err)
+		return nil, fmt.Errorf("failed to resolve VM spec: %w", err)
 	}
 
 	spec.ImagePath = rootfs.GetSource()
@@ -273,18 +341,99 @@ func (s *HyperShim) Create(ctx context.Context, req *taskAPI.CreateTaskRequest)
 		Spec: spec,
 	}
 
-	if err := ns.WithNetNSPath(networkNs, func(_ ns.NetNS) error {
+	timer.done("prepare")
+
+	// The image will be exposed as an unmounted block device
+	// in the guest, /dev/vdb (/dev/vda is the rootfs)
+	req.Rootfs[0].Source = "/dev/vdb"
+
+	// The additional disks follow, from /dev/vdc
+	mountDisks(ociSpec, spec.Disks)
+
+	// The limits of the workload are enforced on the hypervisor, those
+	// handed to the guest are those its runc applies whatever its cgroup
+	// mode
+	workloadResources := ociSpec.Linux.Resources
+	ociSpec.Linux.Resources = cgroup.Portable(workloadResources)
+
+	// Build the config handed to the agent while the VM boots
+	type agentConfig struct {
+		extraData *proto.ExtraData
+		options   *types.Any
+		elapsed   time.Duration
+		err       error
+	}
+
+	agentConfigCh := make(chan agentConfig, 1)
+
+	go func() {
+		start := time.Now()
+
+		ociConfig, err := json.Marshal(ociSpec)
+		if err != nil {
+			agentConfigCh <- agentConfig{err: fmt.Errorf("failed to marshal OCI spec: %w", err)}
+
+			return
+		}
+
+		extraData := generateExtraData(s.getAndIncrementPortCount(), ociConfig, nil)
+		s.recordPorts(req.GetID(), "", extraData)
+
+		options, err := protobuf.MarshalAnyToProto(extraData)
+		if err != nil {
+			agentConfigCh <- agentConfig{err: fmt.Errorf("failed to marshal options: %w", err)}
+
+			return
+		}
+
+		agentConfigCh <- agentConfig{extraData: extraData, options: options, elapsed: time.Since(start)}
+	}()
+
+	restored := false
+
+	startErr := ns.WithNetNSPath(networkNs, func(_ ns.NetNS) error {
+		// Redirect the traffic of the CNI interface to the VM, unless a
+		// plugin like tc-redirect-tap already set a tap device up
+		if err := network.SetupTapRedirect("eth0"); err != nil {
+			return err
+		}
+
+		if len(spec.IngressRules) > 0 {
+			if err := applyTapIngressRules(spec.IngressRules); err != nil {
+				return err
+			}
+		}
+
+		restored = s.restoreFromPool(ctx, hypervisorState)
+		if restored {
+			return nil
+		}
+
 		return hypervisorState.vmSvc.Start(ctx, hypervisorState.vm, s.vmCompletion)
-	}); err != nil {
-		return nil, fmt.Errorf("failed to exec under ns %s: %w", networkNs, err)
+	})
+
+	config := <-agentConfigCh
+
+	if startErr != nil {
+		return nil, fmt.Errorf("failed to exec under ns %s: %w", networkNs, startErr)
 	}
 
+	timer.done("vm_start")
+
+	s.taskID = req.GetID()
+	s.restored = restored
 	s.vmState = hypervisorState
 
+	if err := s.limitHypervisor(ctx, req.GetID(), workloadResources); err != nil {
+		log.G(ctx).WithError(err).Warn("running the hypervisor without resource limits")
+	}
+
 	defer func() {
 		if retErr != nil {
 			log.G(ctx).WithError(retErr).Error("Create failed, cleaning up VM and cancelling shim")
 
+			s.stopping.Store(true)
+
 			if err := s.vmState.vmSvc.Stop(ctx, s.vmState.vm); err != nil {
 				log.G(ctx).WithError(err).Error("failed to stop VM")
 			}
@@ -293,42 +442,48 @@ func (s *HyperShim) Create(ctx context.Context, req *taskAPI.CreateTaskRequest)
 		}
 	}()
 
-	// Set the dial timeout to 1 second to give enough time to firecracker or
-	// cloud-hypervisor to create the VSOCK file
-	conn, err := vsock.DialContext(ctx, hypervisorState.vmSvc.VSockPath(s.vmState.vm), VSockPort, vsock.WithDialTimeout(time.Second), vsock.WithLogger(log.G(ctx)))
+	if config.err != nil {
+		return nil, config.err
+	}
+
+	timer.phases["agent_config"] = config.elapsed
+
+	conn, err := dialAgent(ctx, hypervisorState.vmSvc.VSockPath(s.vmState.vm))
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial vsock connection: %w", err)
 	}
 
+	timer.done("agent_dial")
+
 	rpcClient := ttrpc.NewClient(conn, ttrpc.WithOnClose(func() { _ = conn.Close() }))
 
+	s.vmState.agentProtocol, err = negotiateAgentProtocol(ctx, rpcClient)
+	if err != nil {
+		return nil, err
+	}
+
+	timer.done("agent_negotiate")
+
+	agentReady := timer.total()
+
 	s.vmState.agentClient = taskAPI.NewTaskClient(rpcClient)
 	s.vmState.ioProxyClient = ioproxy.NewIOProxyClient(rpcClient)
 
-	// The image will be exposed as an unmounted block device
-	// in the guest, /dev/vdb (/dev/vda is the rootfs)
-	req.Rootfs[0].Source = "/dev/vdb"
+	req.Options = config.options
 
-	ociConfig, err := json.Marshal(ociSpec)
+	proxy, err := s.proxyIO(ctx, req.GetID(), "", req.GetStdin(), req.GetStdout(), req.GetStderr(), config.extraData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal OCI spec: %w", err)
+		return nil, err
 	}
 
-	extraData := generateExtraData(s.getAndIncrementPortCount(), ociConfig, nil)
-
-	req.Options, err = protobuf.MarshalAnyToProto(extraData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal options: %w", err)
+	res, err := s.taskManager.CreateTask(ctx, req, s.vmState.agentClient, noIOProxy)
+	if err == nil && proxy != nil {
+		err = proxy.Ready()
 	}
 
-	ioConnectorSet, err := utils.NewIOProxy(log.G(ctx), req.GetStdin(), req.GetStdout(), req.GetStderr(), s.vmState.vmSvc.VSockPath(s.vmState.vm), extraData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create IO Proxy: %w", err)
-	}
-
-	res, err := s.taskManager.CreateTask(ctx, req, s.vmState.agentClient, ioConnectorSet)
+		s.closeIO(req.GetID(), "")
 
-	if err != nil {
 		return nil, fmt.Errorf("failed to create task: %w", err)
 	}
 
@@ -341,44 +496,199 @@ func (s *HyperShim) Create(ctx context.Context, req *taskAPI.CreateTaskRequest)
 		return nil, fmt.Errorf("failed to add FIFOs: %w", err)
 	}
 
+	go s.watchConsole(s.shimCtx, req.GetID())
+
+	timer.done("task_create")
+
+	entry := log.G(ctx).WithField("restored", restored)
+	for phase, elapsed := range timer.phases {
+		entry = entry.WithField(phase, elapsed)
+	}
+	entry.Infof("created task %s in %s", req.GetID(), timer.total())
+
+	if err := pool.RecordCreate(defaults.PoolDir, restored, timer.total(), timer.phases); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to record pool stats")
+	}
+
+	if err := pool.RecordBoot(defaults.PoolDir, s.vmState.vm.Spec.Provider, pool.BootStageAgent, restored, agentReady); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to record boot time")
+	}
+
+	s.recordCreate(timer, agentReady)
+	s.startMetricsPush(ctx)
+
 	return res, nil
 }
 
+// applyTapIngressRules filters the connections redirected to the tap
+// device of the VM in the current network namespace
+func applyTapIngressRules(rules []models.IngressRule) error {
+	gateway, err := network.DefaultGateway()
+	if err != nil {
+		return err
+	}
+
+	ruleset, err := network.TapIngressRuleset(network.TapName, rules, gateway)
+	if err != nil {
+		return err
+	}
+
+	return network.ApplyRuleset(ruleset)
+}
+
+// restoreFromPool starts the VM from a warm pool snapshot matching its
+// spec, it returns false if there is none or restoring it failed
+func (s *HyperShim) restoreFromPool(ctx context.Context, hypervisorState *HypervisorState) bool {
+	snapshotSvc, ok := hypervisorState.vmSvc.(ports.MicroVMSnapshotService)
+	if !ok {
+		return false
+	}
+
+	dir, ok := pool.SnapshotDir(defaults.PoolDir, hypervisorState.vm.Spec)
+	if !ok {
+		return false
+	}
+
+	if err := snapshotSvc.Restore(ctx, hypervisorState.vm, dir, s.vmCompletion); err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to restore VM from %s, booting it instead", dir)
+
+		return false
+	}
+
+	return true
+}
+
 func (s *HyperShim) Start(ctx context.Context, req *taskAPI.StartRequest) (*taskAPI.StartResponse, error) {
-	return s.vmState.agentClient.Start(ctx, req)
+	if s.sandbox != nil {
+		if err := s.sandbox.start(); err != nil {
+			return nil, err
+		}
+
+		return &taskAPI.StartResponse{Pid: s.sandbox.pid}, nil
+	}
+
+	resp, err := s.vmState.agentClient.Start(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if elapsed, first := s.recordStart(req.GetExecID()); first {
+		if err := pool.RecordBoot(defaults.PoolDir, s.vmState.vm.Spec.Provider, pool.BootStageStart, s.restored, elapsed); err != nil {
+			log.G(ctx).WithError(err).Warn("failed to record boot time")
+		}
+	}
+
+	return resp, nil
 }
 
 func (s *HyperShim) Delete(ctx context.Context, req *taskAPI.DeleteRequest) (*taskAPI.DeleteResponse, error) {
 	log.G(ctx).Error(s.stateRoot)
+	if s.sandbox != nil {
+		return s.deleteSandbox(ctx, req), nil
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	if s.vmState != nil && s.vmState.agentClient != nil {
-		return s.taskManager.DeleteProcess(ctx, req, s.vmState.agentClient)
+		resp, err := s.taskManager.DeleteProcess(ctx, req, s.vmState.agentClient)
+		if err != nil {
+			return nil, err
+		}
+
+		s.closeIO(req.GetID(), req.GetExecID())
+
+		return resp, nil
 	}
 
 	return nil, errors.New("VM not spawned")
 }
 
 func (s *HyperShim) Pids(ctx context.Context, req *taskAPI.PidsRequest) (*taskAPI.PidsResponse, error) {
+	if s.sandbox != nil {
+		return &taskAPI.PidsResponse{Processes: []*task.ProcessInfo{{Pid: s.sandbox.pid}}}, nil
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	return s.vmState.agentClient.Pids(ctx, req)
 }
 
 func (s *HyperShim) Pause(ctx context.Context, req *taskAPI.PauseRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if s.vmState.vm.Spec.PauseVM {
+		if err := s.pauseVM(ctx, req.GetID()); err != nil {
+			return nil, err
+		}
+
+		return &types.Empty{}, nil
+	}
+
 	return s.vmState.agentClient.Pause(ctx, req)
 }
 
 func (s *HyperShim) Resume(ctx context.Context, req *taskAPI.ResumeRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if s.vmState.vm.Spec.PauseVM {
+		if err := s.resumeVM(ctx, req.GetID()); err != nil {
+			return nil, err
+		}
+
+		return &types.Empty{}, nil
+	}
+
 	return s.vmState.agentClient.Resume(ctx, req)
 }
 
 func (s *HyperShim) Checkpoint(ctx context.Context, req *taskAPI.CheckpointTaskRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	return s.vmState.agentClient.Checkpoint(ctx, req)
 }
 
 func (s *HyperShim) Kill(ctx context.Context, req *taskAPI.KillRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		s.killSandbox(ctx, req)
+
+		return &types.Empty{}, nil
+	}
+
+	// The signal is delivered once the VM runs again, as for paused containers
+	if s.pausedStates.Load() != nil {
+		if err := s.resumeVM(ctx, req.GetID()); err != nil && !errdefs.IsFailedPrecondition(err) {
+			return nil, fmt.Errorf("failed to resume VM: %w", err)
+		}
+	}
+
 	return s.vmState.agentClient.Kill(ctx, req)
 }
 
 func (s *HyperShim) Exec(ctx context.Context, req *taskAPI.ExecProcessRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	extraData := generateExtraData(s.getAndIncrementPortCount(), nil, req.GetSpec())
+	s.recordPorts(req.GetID(), req.GetExecID(), extraData)
 
 	var err error
 	req.Spec, err = protobuf.MarshalAnyToProto(extraData)
@@ -387,10 +697,9 @@ func (s *HyperShim) Exec(ctx context.Context, req *taskAPI.ExecProcessRequest) (
 		return nil, fmt.Errorf("failed to create Any: %w", err)
 	}
 
-	ioConnectorSet, err := utils.NewIOProxy(log.G(ctx), req.GetStdin(), req.GetStdout(), req.GetStderr(),
-		s.vmState.vmSvc.VSockPath(s.vmState.vm), extraData)
+	proxy, err := s.proxyIO(ctx, req.GetID(), req.GetExecID(), req.GetStdin(), req.GetStdout(), req.GetStderr(), extraData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create IO Proxy: %w", err)
+		return nil, err
 	}
 
 	if err := s.addFIFOs(req.GetID(), req.GetExecID(), cio.Config{
@@ -402,38 +711,120 @@ func (s *HyperShim) Exec(ctx context.Context, req *taskAPI.ExecProcessRequest) (
 		return nil, fmt.Errorf("failed to add FIFOs: %w", err)
 	}
 
-	return s.taskManager.ExecProcess(ctx, req, s.vmState.agentClient, ioConnectorSet)
+	resp, err := s.taskManager.ExecProcess(ctx, req, s.vmState.agentClient, noIOProxy)
+	if err == nil && proxy != nil {
+		err = proxy.Ready()
+	}
+
+	if err != nil {
+		s.closeIO(req.GetID(), req.GetExecID())
+
+		return nil, err
+	}
+
+	return resp, nil
 }
 
 func (s *HyperShim) ResizePty(ctx context.Context, req *taskAPI.ResizePtyRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	return s.vmState.agentClient.ResizePty(ctx, req)
 }
 
 func (s *HyperShim) CloseIO(ctx context.Context, req *taskAPI.CloseIORequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	return s.vmState.agentClient.CloseIO(ctx, req)
 }
 
 func (s *HyperShim) Update(ctx context.Context, req *taskAPI.UpdateTaskRequest) (*emptypb.Empty, error) {
+	// The sandbox holds no resources, the limits apply to the workload VMs
+	if s.sandbox != nil {
+		return &types.Empty{}, nil
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
+	if req.GetResources() != nil {
+		var resources specs.LinuxResources
+		if err := typeurl.UnmarshalTo(req.GetResources(), &resources); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resources: %w", err)
+		}
+
+		portable, err := protobuf.MarshalAnyToProto(cgroup.Portable(&resources))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resources: %w", err)
+		}
+
+		req.Resources = portable
+	}
+
 	return s.vmState.agentClient.Update(ctx, req)
 }
 
 func (s *HyperShim) Wait(ctx context.Context, req *taskAPI.WaitRequest) (*taskAPI.WaitResponse, error) {
+	if s.sandbox != nil {
+		return s.waitSandbox(ctx)
+	}
+
+	if failure := s.vmFailure(); failure.GetReason() == shimdebug.VMFailureReason_KERNEL_PANIC {
+		return &taskAPI.WaitResponse{
+			ExitStatus: panicExitStatus,
+			ExitedAt:   protobuf.ToTimestamp(time.Unix(failure.GetTimestamp(), 0)),
+		}, nil
+	}
+
 	return s.vmState.agentClient.Wait(ctx, req)
 }
 
 func (s *HyperShim) Stats(ctx context.Context, req *taskAPI.StatsRequest) (*taskAPI.StatsResponse, error) {
+	if s.sandbox != nil {
+		return nil, errSandboxUnsupported
+	}
+
+	if err := s.checkNotPaused(); err != nil {
+		return nil, err
+	}
+
 	return s.vmState.agentClient.Stats(ctx, req)
 }
 
 func (s *HyperShim) Connect(ctx context.Context, req *taskAPI.ConnectRequest) (*taskAPI.ConnectResponse, error) {
+	if s.sandbox != nil {
+		return &taskAPI.ConnectResponse{ShimPid: s.sandbox.pid, TaskPid: s.sandbox.pid}, nil
+	}
+
 	return s.vmState.agentClient.Connect(ctx, req)
 }
 
 func (s *HyperShim) Shutdown(ctx context.Context, req *taskAPI.ShutdownRequest) (*emptypb.Empty, error) {
+	if s.sandbox != nil {
+		s.shimCancel()
+
+		return &types.Empty{}, nil
+	}
+
 	// vmState being non-nil means that the VM was started
 	//nolint:nestif
 	if s.taskManager.ShutdownIfEmpty() && s.vmState != nil {
-		if s.vmState.agentClient != nil {
+		s.stopping.Store(true)
+
+		// The agent of a paused VM can't answer
+		if s.vmState.agentClient != nil && s.pausedStates.Load() == nil {
 			_, err := s.vmState.agentClient.Shutdown(ctx, req)
 
 			if err != nil {
@@ -519,8 +910,6 @@ func (s *HyperShim) StartShim(ctx context.Context, opts shim.StartOpts) (string,
 }
 
 func Run() {
-	typeurl.Register(&models.MicroVMSpec{})
-
 	shim.Run(
 		ShimID,
 		func(ctx context.Context, id string, remotePublisher shim.Publisher, shimCancel func()) (shim.Shim, error) {
@@ -532,6 +921,9 @@ func Run() {
 				eventExchange:   exchange.NewExchange(),
 				taskManager:     utils.NewTaskManager(ctx, log.G(ctx)),
 				fifos:           make(map[string]map[string]cio.Config),
+				ports:           make(map[string]map[string]*proto.ExtraData),
+				ioProxies:       make(map[string]map[string]*stdio.Proxy),
+				recentErrors:    &recentErrors{},
 				shimCancel:      shimCancel,
 			}
 
@@ -0,0 +1,82 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/vistara-labs/firecracker-containerd/proto"
+	"github.com/vistara-labs/firecracker-containerd/utils"
+
+	"vistara-node/pkg/stdio"
+)
+
+// Time the output of a deleted process has to be copied to its FIFOs, as
+// in firecracker-containerd
+const ioFlushTimeout = 5 * time.Second
+
+// noIOProxy is handed to the task manager in place of its IO proxy, the
+// shim proxies the IO of the processes itself
+var noIOProxy, _ = utils.NewIOProxy(log.L, "", "", "", "", nil)
+
+// proxyIO starts forwarding the stdio of a process between its FIFOs and
+// the vsock ports of its extra data, replacing the proxy of a reattached
+// process. It returns nil if the agent handles the IO of the process
+func (s *HyperShim) proxyIO(ctx context.Context, taskID, execID, stdin, stdout, stderr string, extraData *proto.ExtraData) (*stdio.Proxy, error) {
+	if agentOnlyIO(stdout) {
+		return nil, nil
+	}
+
+	proxy, err := stdio.Start(ctx, s.vmState.vmSvc.VSockPath(s.vmState.vm), stdio.Streams{
+		Stdin:      stdin,
+		Stdout:     stdout,
+		Stderr:     stderr,
+		StdinPort:  extraData.GetStdinPort(),
+		StdoutPort: extraData.GetStdoutPort(),
+		StderrPort: extraData.GetStderrPort(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IO Proxy: %w", err)
+	}
+
+	s.ioProxiesMu.Lock()
+	defer s.ioProxiesMu.Unlock()
+
+	if _, exists := s.ioProxies[taskID]; !exists {
+		s.ioProxies[taskID] = make(map[string]*stdio.Proxy)
+	}
+
+	if previous := s.ioProxies[taskID][execID]; previous != nil {
+		go previous.Close(0)
+	}
+
+	s.ioProxies[taskID][execID] = proxy
+
+	return proxy, nil
+}
+
+// closeIO stops proxying the IO of a deleted process once its output was
+// copied
+func (s *HyperShim) closeIO(taskID, execID string) {
+	s.ioProxiesMu.Lock()
+	proxy := s.ioProxies[taskID][execID]
+	delete(s.ioProxies[taskID], execID)
+	s.ioProxiesMu.Unlock()
+
+	if proxy != nil {
+		proxy.Close(ioFlushTimeout)
+	}
+}
+
+// agentOnlyIO reports whether the output of a process is redirected by the
+// agent within the VM, for the binary and file IO of containerd
+func agentOnlyIO(stdout string) bool {
+	parsed, err := url.Parse(stdout)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "binary" || parsed.Scheme == "file"
+}
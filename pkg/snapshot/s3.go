@@ -0,0 +1,335 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Environment variables holding the credentials of the store, as read
+	// by the AWS tools
+	AccessKeyIDEnv     = "AWS_ACCESS_KEY_ID"
+	SecretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	SessionTokenEnv    = "AWS_SESSION_TOKEN"
+
+	DefaultRegion = "us-east-1"
+
+	requestTimeout    = time.Minute
+	maxRetries        = 3
+	retryInitialDelay = time.Millisecond * 500
+	maxErrorBodyBytes = 1024
+)
+
+// ErrNotFound is returned for the objects missing from the store
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore holds the blocks and manifests of the snapshots
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose key starts with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Object is an object of the store, as listed
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// S3Config is a bucket of an S3-compatible store, objects are addressed
+// with path-style requests so any endpoint works
+type S3Config struct {
+	// e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Endpoint string
+	// DefaultRegion if empty
+	Region string
+	Bucket string
+	// The credentials are read from the environment if empty
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3 is an ObjectStore signing its requests with AWS signature version 4
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 returns the store of the bucket
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("the snapshot store needs an endpoint and a bucket")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid snapshot store endpoint %q", cfg.Endpoint)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv(AccessKeyIDEnv)
+		cfg.SecretAccessKey = os.Getenv(SecretAccessKeyEnv)
+		cfg.SessionToken = os.Getenv(SessionTokenEnv)
+	}
+
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no credentials for the snapshot store, set %s and %s", AccessKeyIDEnv, SecretAccessKeyEnv)
+	}
+
+	return &S3{cfg: cfg, endpoint: endpoint, client: &http.Client{Timeout: requestTimeout}}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, key, nil, data)
+
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse the listing of %s: %w", prefix, err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	slices.SortFunc(objects, func(a, b Object) int { return strings.Compare(a.Key, b.Key) })
+
+	return objects, nil
+}
+
+// do sends a request for the object with the given key, or for the bucket
+// if it is empty, retrying network errors and 5xx statuses
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	delay := retryInitialDelay
+
+	for attempt := 0; ; attempt++ {
+		resp, err := s.send(ctx, method, key, query, body)
+		if err == nil || attempt == maxRetries || !retryable(err) {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// statusError is returned for the requests failing with an HTTP status
+type statusError struct {
+	method string
+	key    string
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.method, e.key, e.status, e.body)
+}
+
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= http.StatusInternalServerError
+	}
+
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (s *S3) send(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.cfg.Bucket + "/" + key
+	target.RawPath = uriEncode(s.endpoint.Path, false) + "/" + uriEncode(s.cfg.Bucket, true) + "/" + uriEncode(key, false)
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = int64(len(body))
+	s.sign(req, target.RawPath, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+
+		return nil, &statusError{method: method, key: key, status: resp.StatusCode, body: strings.TrimSpace(string(message))}
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// sign adds the AWS signature version 4 of the request to its headers
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (s *S3) sign(req *http.Request, canonicalURI string, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(headers))
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+// canonicalQuery encodes the query with its keys sorted, as signed
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[key] {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters,
+// and the slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
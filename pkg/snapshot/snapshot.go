@@ -0,0 +1,540 @@
+// Package snapshot backs up persistent volumes and VM disk images to an
+// object store. Files are split into fixed size blocks stored by their
+// hash, so a snapshot only uploads the blocks that changed since the
+// previous snapshot of the same volume, and a manifest per snapshot lists
+// the blocks of each file
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Size of the blocks files are split into
+	BlockSize = 4 * 1024 * 1024
+
+	// Unreferenced blocks uploaded more recently than this aren't garbage
+	// collected, as they may belong to a snapshot being created
+	GCGracePeriod = time.Hour
+
+	idFormat        = "20060102T150405.000Z"
+	blocksDir       = "blocks"
+	manifestsDir    = "snapshots"
+	manifestExt     = ".json"
+	restoringSuffix = ".restoring"
+	maxNameLength   = 128
+)
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Manifest describes a snapshot of a volume or disk image
+type Manifest struct {
+	// Volume or disk image the snapshot is of
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	// Tenant of the volume, only its workloads can be restored from it
+	Tenant    string    `json:"tenant,omitempty"`
+	Created   time.Time `json:"created"`
+	BlockSize int64     `json:"block_size"`
+	Files     []File    `json:"files"`
+	// Bytes of the files, and of the blocks the snapshot had to upload
+	Size     int64 `json:"size"`
+	Uploaded int64 `json:"uploaded"`
+}
+
+// File is a file, directory or symlink of a snapshot
+type File struct {
+	// Relative to the snapshotted directory, "." for the directory itself
+	// or for a single file
+	Path string      `json:"path"`
+	Mode fs.FileMode `json:"mode"`
+	Size int64       `json:"size,omitempty"`
+	// Target of the symlinks
+	Target string `json:"target,omitempty"`
+	// Hashes of the blocks of the regular files, empty for the blocks of
+	// zeroes, which are left as holes on restore
+	Blocks []string `json:"blocks,omitempty"`
+}
+
+// Ref returns the reference of the snapshot, NAME@ID
+func (m *Manifest) Ref() string {
+	return m.Name + "@" + m.ID
+}
+
+// ParseRef splits a snapshot reference, NAME or NAME@ID, the ID being
+// empty for the latest snapshot
+func ParseRef(ref string) (name, id string, err error) {
+	name, id, _ = strings.Cut(ref, "@")
+	if err := ValidateName(name); err != nil {
+		return "", "", err
+	}
+
+	if strings.Contains(ref, "@") {
+		if _, err := time.Parse(idFormat, id); err != nil {
+			return "", "", fmt.Errorf("invalid snapshot ID %q in %q", id, ref)
+		}
+	}
+
+	return name, id, nil
+}
+
+// ValidateName checks the name of a volume or disk image can name its
+// snapshots
+func ValidateName(name string) error {
+	if len(name) > maxNameLength || !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q, must be alphanumeric characters, '.', '_' or '-'", name)
+	}
+
+	return nil
+}
+
+// Retention is how many snapshots of a volume are kept, the latest one
+// always is
+type Retention struct {
+	// 0 to keep them all
+	KeepLast int
+	// 0 to keep them whatever their age
+	MaxAge time.Duration
+}
+
+// Store holds the snapshots under a prefix of an object store
+type Store struct {
+	objects ObjectStore
+	prefix  string
+}
+
+func NewStore(objects ObjectStore, prefix string) *Store {
+	return &Store{objects: objects, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *Store) key(elem ...string) string {
+	return path.Join(append([]string{s.prefix}, elem...)...)
+}
+
+func (s *Store) manifestKey(name, id string) string {
+	return s.key(manifestsDir, name, id+manifestExt)
+}
+
+// Create snapshots source, a directory or a regular file, uploading the
+// blocks that aren't part of the latest snapshot of the same name. The
+// files aren't frozen, those written to during the snapshot may be
+// captured partially
+func (s *Store) Create(ctx context.Context, name, tenant, source string) (*Manifest, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s isn't a directory or a regular file", source)
+	}
+
+	known := make(map[string]bool)
+
+	previous, err := s.Latest(ctx, name)
+	switch {
+	case err == nil:
+		for _, file := range previous.Files {
+			for _, hash := range file.Blocks {
+				known[hash] = true
+			}
+		}
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	manifest := &Manifest{Name: name, ID: now.Format(idFormat), Tenant: tenant, Created: now, BlockSize: BlockSize}
+
+	if info.Mode().IsRegular() {
+		if err := s.addFile(ctx, manifest, source, ".", info, known); err != nil {
+			return nil, err
+		}
+	} else {
+		err := filepath.WalkDir(source, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(source, p)
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			return s.addFile(ctx, manifest, p, filepath.ToSlash(rel), info, known)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest is uploaded last so the snapshot only shows up once
+	// all its blocks are there
+	if err := s.objects.Put(ctx, s.manifestKey(name, manifest.ID), data); err != nil {
+		return nil, fmt.Errorf("failed to upload the manifest of snapshot %s: %w", manifest.Ref(), err)
+	}
+
+	return manifest, nil
+}
+
+// addFile adds a file to the manifest, uploading the blocks of regular
+// files missing from known. Sockets, devices and pipes are skipped
+func (s *Store) addFile(ctx context.Context, manifest *Manifest, p, rel string, info fs.FileInfo, known map[string]bool) error {
+	file := File{Path: rel, Mode: info.Mode()}
+
+	switch {
+	case info.IsDir():
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+
+		file.Target = target
+	case info.Mode().IsRegular():
+		if err := s.uploadBlocks(ctx, manifest, p, &file, known); err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", p, err)
+		}
+	default:
+		return nil
+	}
+
+	manifest.Files = append(manifest.Files, file)
+
+	return nil
+}
+
+func (s *Store) uploadBlocks(ctx context.Context, manifest *Manifest, p string, file *File, known map[string]bool) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, BlockSize)
+
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			block := buf[:n]
+			hash := ""
+
+			if !zeroes(block) {
+				hash = sha256Hex(block)
+
+				if !known[hash] {
+					if err := s.objects.Put(ctx, s.key(blocksDir, hash), block); err != nil {
+						return err
+					}
+
+					known[hash] = true
+					manifest.Uploaded += int64(n)
+				}
+			}
+
+			file.Blocks = append(file.Blocks, hash)
+			file.Size += int64(n)
+			manifest.Size += int64(n)
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func zeroes(block []byte) bool {
+	for _, b := range block {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// List returns the snapshots of a volume or disk image, of all of them if
+// name is empty, oldest first
+func (s *Store) List(ctx context.Context, name string) ([]*Manifest, error) {
+	prefix := s.key(manifestsDir) + "/"
+	if name != "" {
+		prefix = s.key(manifestsDir, name) + "/"
+	}
+
+	objects, err := s.objects.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]*Manifest, 0, len(objects))
+
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, manifestExt) {
+			continue
+		}
+
+		manifest, err := s.manifest(ctx, object.Key)
+		if err != nil {
+			// Pruned since listed
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	slices.SortStableFunc(manifests, func(a, b *Manifest) int { return a.Created.Compare(b.Created) })
+
+	return manifests, nil
+}
+
+func (s *Store) manifest(ctx context.Context, key string) (*Manifest, error) {
+	data, err := s.objects.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest %s: %w", key, err)
+	}
+
+	return &manifest, nil
+}
+
+// Latest returns the latest snapshot of a volume or disk image, ErrNotFound
+// if it has none
+func (s *Store) Latest(ctx context.Context, name string) (*Manifest, error) {
+	objects, err := s.objects.List(ctx, s.key(manifestsDir, name)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	objects = slices.DeleteFunc(objects, func(object Object) bool {
+		return !strings.HasSuffix(object.Key, manifestExt)
+	})
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no snapshot of %s: %w", name, ErrNotFound)
+	}
+
+	// The IDs sort like the times they were taken at
+	return s.manifest(ctx, objects[len(objects)-1].Key)
+}
+
+// Find returns the snapshot a reference, NAME or NAME@ID, points to
+func (s *Store) Find(ctx context.Context, ref string) (*Manifest, error) {
+	name, id, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		return s.Latest(ctx, name)
+	}
+
+	return s.manifest(ctx, s.manifestKey(name, id))
+}
+
+// Restore writes the files of a snapshot to dst, which must not exist. The
+// files are restored next to it and moved into place once complete
+func (s *Store) Restore(ctx context.Context, manifest *Manifest, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+
+	tmp := dst + restoringSuffix
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+
+	if err := s.restoreFiles(ctx, manifest, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+
+		return fmt.Errorf("failed to restore snapshot %s: %w", manifest.Ref(), err)
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func (s *Store) restoreFiles(ctx context.Context, manifest *Manifest, root string) error {
+	var dirs []File
+
+	for _, file := range manifest.Files {
+		if file.Path != "." && !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return fmt.Errorf("invalid path %q", file.Path)
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(file.Path))
+
+		switch {
+		case file.Mode.IsDir():
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+
+			dirs = append(dirs, file)
+		case file.Mode&fs.ModeSymlink != 0:
+			if err := os.Symlink(file.Target, target); err != nil {
+				return err
+			}
+		case file.Mode.IsRegular():
+			if err := s.restoreBlocks(ctx, manifest.BlockSize, file, target); err != nil {
+				return fmt.Errorf("%s: %w", file.Path, err)
+			}
+		}
+	}
+
+	// The modes of the directories are set once their files are written,
+	// deepest first, in case they aren't writable
+	for _, dir := range slices.Backward(dirs) {
+		if err := os.Chmod(filepath.Join(root, filepath.FromSlash(dir.Path)), dir.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) restoreBlocks(ctx context.Context, blockSize int64, file File, target string) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(file.Size); err != nil {
+		return err
+	}
+
+	for i, hash := range file.Blocks {
+		if hash == "" {
+			continue
+		}
+
+		block, err := s.objects.Get(ctx, s.key(blocksDir, hash))
+		if err != nil {
+			return err
+		}
+
+		if sha256Hex(block) != hash {
+			return fmt.Errorf("block %s is corrupted", hash)
+		}
+
+		if _, err := f.WriteAt(block, int64(i)*blockSize); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Chmod(file.Mode.Perm()); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Prune deletes the snapshots of a volume or disk image the retention
+// doesn't keep, then the blocks no snapshot references anymore. It
+// returns the deleted snapshots
+func (s *Store) Prune(ctx context.Context, name string, retention Retention, now time.Time) ([]*Manifest, error) {
+	manifests, err := s.List(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []*Manifest
+
+	for i, manifest := range manifests {
+		newer := len(manifests) - 1 - i
+		if newer == 0 {
+			break
+		}
+
+		tooMany := retention.KeepLast > 0 && newer >= retention.KeepLast
+		tooOld := retention.MaxAge > 0 && now.Sub(manifest.Created) > retention.MaxAge
+
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := s.objects.Delete(ctx, s.manifestKey(manifest.Name, manifest.ID)); err != nil {
+			return pruned, err
+		}
+
+		pruned = append(pruned, manifest)
+	}
+
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
+	return pruned, s.collectGarbage(ctx, now)
+}
+
+// collectGarbage deletes the blocks no snapshot references
+func (s *Store) collectGarbage(ctx context.Context, now time.Time) error {
+	manifests, err := s.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool)
+
+	for _, manifest := range manifests {
+		for _, file := range manifest.Files {
+			for _, hash := range file.Blocks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	blocks, err := s.objects.List(ctx, s.key(blocksDir)+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if referenced[path.Base(block.Key)] || now.Sub(block.LastModified) < GCGracePeriod {
+			continue
+		}
+
+		if err := s.objects.Delete(ctx, block.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
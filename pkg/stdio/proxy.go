@@ -0,0 +1,224 @@
+package stdio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/containerd/fifo"
+	"github.com/containerd/log"
+	"github.com/firecracker-microvm/firecracker-go-sdk/vsock"
+)
+
+// Time the guest agent has to listen on the vsock port of a stream after
+// the proxy was started, as in firecracker-containerd
+const dialTimeout = 5 * time.Second
+
+// Streams are the FIFOs of the stdio of a process on the host and the
+// vsock ports the guest agent serves them on, empty paths are not proxied
+type Streams struct {
+	Stdin      string
+	Stdout     string
+	Stderr     string
+	StdinPort  uint32
+	StdoutPort uint32
+	StderrPort uint32
+}
+
+// Proxy forwards the stdio of a process in a VM between its FIFOs and the
+// vsock of the VM, splicing the data in the kernel where possible
+type Proxy struct {
+	vsockPath string
+	//nolint:containedctx
+	ctx     context.Context
+	cancel  func()
+	streams []*stream
+	ready   chan error
+}
+
+type stream struct {
+	name  string
+	input bool
+	done  chan struct{}
+
+	mu      sync.Mutex
+	closers []io.Closer
+	closed  bool
+}
+
+// Start opens the FIFOs of the process and connects them to the vsock
+// ports in the background, the guest agent listening on them once it
+// handles the creation of the process
+func Start(ctx context.Context, vsockPath string, streams Streams) (*Proxy, error) {
+	proxyCtx, cancel := context.WithCancel(context.Background())
+	p := &Proxy{vsockPath: vsockPath, ctx: proxyCtx, cancel: cancel, ready: make(chan error, 3)}
+
+	for _, s := range []struct {
+		name  string
+		path  string
+		port  uint32
+		input bool
+	}{
+		{"stdin", streams.Stdin, streams.StdinPort, true},
+		{"stdout", streams.Stdout, streams.StdoutPort, false},
+		{"stderr", streams.Stderr, streams.StderrPort, false},
+	} {
+		if s.path == "" {
+			continue
+		}
+
+		if err := p.start(ctx, s.name, s.path, s.port, s.input); err != nil {
+			p.Close(0)
+
+			return nil, fmt.Errorf("failed to open %s: %w", s.name, err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Proxy) start(ctx context.Context, name, path string, port uint32, input bool) error {
+	flag := syscall.O_WRONLY
+	if input {
+		flag = syscall.O_RDONLY
+	}
+
+	// Opened synchronously so the FIFO exists before the process is
+	// created, O_NONBLOCK only defers the open until the other end is
+	file, err := fifo.OpenFifo(p.ctx, path, syscall.O_CREAT|syscall.O_NONBLOCK|flag, 0o300)
+	if err != nil {
+		return err
+	}
+
+	s := &stream{name: name, input: input, done: make(chan struct{}), closers: []io.Closer{file}}
+	p.streams = append(p.streams, s)
+
+	logger := log.G(ctx).WithField("stream", name)
+
+	go func() {
+		defer close(s.done)
+		defer s.close()
+
+		dialCtx, cancel := context.WithTimeout(p.ctx, dialTimeout)
+		conn, err := vsock.DialContext(dialCtx, p.vsockPath, port, vsock.WithLogger(logger))
+		cancel()
+
+		if err != nil {
+			p.ready <- fmt.Errorf("failed to connect %s: %w", name, err)
+
+			return
+		}
+
+		p.ready <- nil
+
+		if !s.add(conn) {
+			return
+		}
+
+		var (
+			written int64
+			spliced bool
+		)
+
+		if input {
+			written, spliced, err = copyStream(conn, file)
+		} else {
+			written, spliced, err = copyStream(file, conn)
+		}
+
+		logger = logger.WithField("spliced", spliced)
+		if err != nil && !s.isClosed() {
+			logger.WithError(err).Errorf("failed to copy %s after %d bytes", name, written)
+
+			return
+		}
+
+		logger.Debugf("copied %d bytes of %s", written, name)
+	}()
+
+	return nil
+}
+
+// Ready waits until the streams are connected to the guest agent
+func (p *Proxy) Ready() error {
+	for range p.streams {
+		if err := <-p.ready; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes stdin and waits up to the flush timeout for the output of
+// the process to be copied before closing the remaining streams
+func (p *Proxy) Close(flush time.Duration) {
+	flushed := make(chan struct{})
+
+	go func() {
+		defer close(flushed)
+
+		for _, s := range p.streams {
+			if s.input {
+				s.close()
+			}
+
+			<-s.done
+		}
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(flush):
+	}
+
+	p.cancel()
+
+	for _, s := range p.streams {
+		s.close()
+	}
+
+	<-flushed
+}
+
+// add registers a connection to close along with the stream, closing it
+// right away if the stream already was
+func (s *stream) add(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		_ = c.Close()
+
+		return false
+	}
+
+	s.closers = append(s.closers, c)
+
+	return true
+}
+
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+
+	for _, c := range s.closers {
+		_ = c.Close()
+	}
+}
+
+func (s *stream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
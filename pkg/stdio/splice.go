@@ -0,0 +1,163 @@
+package stdio
+
+import (
+	"errors"
+	"io"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Capacity requested for the pipes the data is spliced through, the
+	// most an unprivileged process may ask for by default
+	splicePipeSize = 1 << 20
+	// Size of the buffers of the copies through userspace, used when the
+	// streams can't be spliced
+	copyBufferSize = 64 << 10
+)
+
+// copyStream copies src to dst until EOF, splicing the data between their
+// file descriptors in the kernel when both expose one and falling back to
+// a copy through userspace otherwise. It reports whether it was spliced
+func copyStream(dst io.Writer, src io.Reader) (written int64, spliced bool, err error) {
+	dstConn, dstOK := dst.(syscall.Conn)
+	srcConn, srcOK := src.(syscall.Conn)
+
+	if dstOK && srcOK {
+		var pending []byte
+
+		written, pending, err = spliceStream(dstConn, srcConn)
+		if !errors.Is(err, errSpliceUnsupported) {
+			return written, true, err
+		}
+
+		// Data spliced out of the source before the destination refused it
+		if len(pending) > 0 {
+			if _, err := dst.Write(pending); err != nil {
+				return 0, false, err
+			}
+		}
+
+		written = int64(len(pending))
+	}
+
+	copied, err := io.CopyBuffer(dst, src, make([]byte, copyBufferSize))
+
+	return written + copied, false, err
+}
+
+var errSpliceUnsupported = errors.New("splice unsupported")
+
+// spliceStream moves the data of src to dst through a pipe, returning
+// errSpliceUnsupported if the kernel refused to splice either of them
+// before anything was written, with the data already taken from src
+func spliceStream(dst, src syscall.Conn) (int64, []byte, error) {
+	srcRaw, err := src.SyscallConn()
+	if err != nil {
+		return 0, nil, errSpliceUnsupported
+	}
+
+	dstRaw, err := dst.SyscallConn()
+	if err != nil {
+		return 0, nil, errSpliceUnsupported
+	}
+
+	var pipe [2]int
+	if err := unix.Pipe2(pipe[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return 0, nil, errSpliceUnsupported
+	}
+
+	defer unix.Close(pipe[0])
+	defer unix.Close(pipe[1])
+
+	size, err := unix.FcntlInt(uintptr(pipe[1]), unix.F_SETPIPE_SZ, splicePipeSize)
+	if err != nil {
+		// The pipe keeps its default capacity
+		size = 64 << 10
+	}
+
+	var written int64
+
+	for {
+		// The pipe is drained after each fill, so EAGAIN means the source
+		// has nothing to read yet
+		var filled int64
+
+		var spliceErr error
+		if err := srcRaw.Read(func(fd uintptr) bool {
+			filled, spliceErr = splice(int(fd), pipe[1], size)
+
+			return !errors.Is(spliceErr, unix.EAGAIN)
+		}); err != nil {
+			return written, nil, err
+		}
+
+		if spliceErr != nil {
+			if written == 0 && unsupported(spliceErr) {
+				return 0, nil, errSpliceUnsupported
+			}
+
+			return written, nil, spliceErr
+		}
+
+		if filled == 0 {
+			return written, nil, nil
+		}
+
+		for filled > 0 {
+			var drained int64
+			if err := dstRaw.Write(func(fd uintptr) bool {
+				drained, spliceErr = splice(pipe[0], int(fd), int(filled))
+
+				return !errors.Is(spliceErr, unix.EAGAIN)
+			}); err != nil {
+				return written, nil, err
+			}
+
+			if spliceErr != nil {
+				if written == 0 && unsupported(spliceErr) {
+					return 0, drainPipe(pipe[0], filled), errSpliceUnsupported
+				}
+
+				return written, nil, spliceErr
+			}
+
+			filled -= drained
+			written += drained
+		}
+	}
+}
+
+func splice(in, out, size int) (int64, error) {
+	for {
+		n, err := unix.Splice(in, nil, out, nil, size, unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+		if !errors.Is(err, unix.EINTR) {
+			return n, err
+		}
+	}
+}
+
+// unsupported reports whether a splice failed because of the type of the
+// file descriptors rather than of the stream
+func unsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// drainPipe reads the bytes left in the pipe
+func drainPipe(fd int, size int64) []byte {
+	buf := make([]byte, size)
+
+	var read int
+
+	for read < len(buf) {
+		n, err := unix.Read(fd, buf[read:])
+		if n <= 0 || err != nil {
+			break
+		}
+
+		read += n
+	}
+
+	return buf[:read]
+}
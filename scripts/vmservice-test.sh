@@ -0,0 +1,51 @@
+#!/bin/bash
+
+# Integration test of the VMService, run against a node serving it with
+# `hypercore serve` after setting up containerd with scripts/containerd.sh
+
+set -euo pipefail
+
+ADDR="${VMSERVICE_ADDR:-127.0.0.1:8001}"
+PROVIDER="${PROVIDER:-firecracker}"
+IMAGE_REF="${IMAGE_REF:?set IMAGE_REF to the microVM image to test with}"
+
+call() {
+	grpcurl -plaintext -d "$2" "$ADDR" "microvm.services.api.VMService/$1"
+}
+
+fail() {
+	echo "FAIL: $*" >&2
+	exit 1
+}
+
+echo "Creating microVM"
+ID=$(call Create "{\"spec\": {\"provider\": \"$PROVIDER\", \"image_ref\": \"$IMAGE_REF\", \"vcpu\": 1, \"memory_mb\": 1024}}" | jq -r '.microvm.id')
+[ -n "$ID" ] && [ "$ID" != "null" ] || fail "create did not return an ID"
+
+trap 'call Delete "{\"id\": \"$ID\"}" >/dev/null 2>&1 || true' EXIT
+
+echo "Waiting for microVM $ID to be running"
+for _ in $(seq 1 30); do
+	STATE=$(call Get "{\"id\": \"$ID\"}" | jq -r '.microvm.state')
+	[ "$STATE" = "RUNNING" ] && break
+	sleep 1
+done
+[ "$STATE" = "RUNNING" ] || fail "microVM $ID is $STATE"
+
+echo "Checking microVM $ID is listed"
+call List '{}' | jq -e --arg id "$ID" '.microvms[] | select(.id == $id)' >/dev/null || fail "microVM $ID not listed"
+
+echo "Checking invalid specs are rejected"
+if call Create '{"spec": {"provider": "qemu"}}' >/dev/null 2>&1; then
+	fail "invalid spec was accepted"
+fi
+
+echo "Deleting microVM $ID"
+call Delete "{\"id\": \"$ID\"}" >/dev/null
+trap - EXIT
+
+if call Get "{\"id\": \"$ID\"}" >/dev/null 2>&1; then
+	fail "microVM $ID still exists after delete"
+fi
+
+echo "PASS"